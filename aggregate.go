@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AggregatedScrobble is a play found in one or more sinks.
+type AggregatedScrobble struct {
+	Scrobble
+	// names of the sinks that contain the play, and of those that do not
+	Sinks   []string `json:"sinks"`
+	Missing []string `json:"missing"`
+}
+
+// FetchScrobbles loads the scrobbles of all sinks in parallel, by sink name.
+// Sinks that return an error are left out, their errors are returned in the
+// order of sinks.
+func FetchScrobbles(sinks []Sink, limit int, from, to time.Time) (map[string][]Scrobble, []error) {
+	var mutex sync.Mutex
+	scrobbles := map[string][]Scrobble{}
+
+	errs := DispatchSinks(sinks, 0, func(sink Sink) error {
+		fetched, err := sink.GetScrobbles(limit, from, to)
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		scrobbles[sink.Name()] = fetched
+		return nil
+	})
+
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Errorf("%s: %s", sinks[i].Name(), err.Error()))
+		}
+	}
+	return scrobbles, failed
+}
+
+// AggregateScrobbles merges the scrobbles of several sinks, newest first.
+// Scrobbles are the same play under the same rules as in MissingScrobbles, so
+// every play lists the sinks it was saved to. Each play keeps the fields of
+// the first sink (by name) that contains it.
+func AggregateScrobbles(scrobbles map[string][]Scrobble, tolerance time.Duration) []AggregatedScrobble {
+	names := slices.Sorted(maps.Keys(scrobbles))
+
+	var aggregated []*AggregatedScrobble
+	for _, name := range names {
+		for _, scrobble := range scrobbles[name] {
+			key := dedupeKey(scrobble)
+
+			var closest *AggregatedScrobble
+			var closestDistance time.Duration
+			for _, a := range aggregated {
+				distance := a.Timestamp.Sub(scrobble.Timestamp).Abs()
+				if slices.Contains(a.Sinks, name) || distance > tolerance || dedupeKey(a.Scrobble) != key {
+					continue
+				}
+				if closest == nil || distance < closestDistance {
+					closest = a
+					closestDistance = distance
+				}
+			}
+
+			if closest == nil {
+				aggregated = append(aggregated, &AggregatedScrobble{Scrobble: scrobble, Sinks: []string{name}, Missing: nil})
+				continue
+			}
+			closest.Sinks = append(closest.Sinks, name)
+		}
+	}
+
+	result := make([]AggregatedScrobble, 0, len(aggregated))
+	for _, a := range aggregated {
+		a.Missing = []string{}
+		for _, name := range names {
+			if !slices.Contains(a.Sinks, name) {
+				a.Missing = append(a.Missing, name)
+			}
+		}
+		result = append(result, *a)
+	}
+
+	slices.SortStableFunc(result, func(a, b AggregatedScrobble) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+	return result
+}
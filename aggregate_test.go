@@ -0,0 +1,65 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateScrobbles(t *testing.T) {
+	at := func(scrobble main.Scrobble, offset time.Duration) main.Scrobble {
+		scrobble.Timestamp = defaultScrobble.Timestamp.Add(offset)
+		return scrobble
+	}
+	other := defaultScrobble
+	other.Track = "Pure Morning"
+
+	aggregated := main.AggregateScrobbles(map[string][]main.Scrobble{
+		"csv:default": {
+			at(defaultScrobble, 0),
+			at(defaultScrobble, 251*time.Second),
+			at(other, 10*time.Minute),
+		},
+		"last.fm:default": {
+			at(defaultScrobble, 30*time.Second),
+			at(other, 11*time.Minute),
+			at(other, time.Hour),
+		},
+	}, main.DefaultSyncTolerance)
+
+	require.Len(t, aggregated, 4)
+
+	require.Equal(t, "Pure Morning", aggregated[0].Track)
+	require.Equal(t, []string{"last.fm:default"}, aggregated[0].Sinks)
+	require.Equal(t, []string{"csv:default"}, aggregated[0].Missing)
+
+	require.Equal(t, "Pure Morning", aggregated[1].Track)
+	require.Equal(t, []string{"csv:default", "last.fm:default"}, aggregated[1].Sinks)
+	require.Empty(t, aggregated[1].Missing)
+	// the first sink by name keeps its timestamp
+	require.True(t, defaultScrobble.Timestamp.Add(10*time.Minute).Equal(aggregated[1].Timestamp))
+
+	// played again on repeat
+	require.Equal(t, []string{"csv:default"}, aggregated[2].Sinks)
+	require.Equal(t, []string{"last.fm:default"}, aggregated[2].Missing)
+
+	require.Equal(t, []string{"csv:default", "last.fm:default"}, aggregated[3].Sinks)
+	require.True(t, defaultScrobble.Timestamp.Equal(aggregated[3].Timestamp))
+
+	require.Empty(t, main.AggregateScrobbles(nil, main.DefaultSyncTolerance))
+}
+
+func TestFetchScrobbles(t *testing.T) {
+	local := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv")}
+	require.NoError(t, local.Scrobble(defaultScrobble))
+	missing := main.CSVSink{Key: "missing", Filename: filepath.Join(t.TempDir(), "missing", "scrobbles.csv")}
+
+	fetched, errs := main.FetchScrobbles([]main.Sink{local, missing}, 10, time.Time{}, time.Now())
+	require.Len(t, fetched, 1)
+	require.Len(t, fetched[local.Name()], 1)
+	require.Len(t, errs, 1)
+	require.ErrorContains(t, errs[0], missing.Name())
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// a play that ended this close to the end of the track counts as completed,
+// the last poll before a track change is usually a few seconds before its end
+const completionMargin = 15 * time.Second
+
+type PlayOutcome string
+
+const (
+	// the track played until (almost) the end
+	PlayCompleted = PlayOutcome("completed")
+	// the next track was started or the player was closed during playback
+	PlaySkipped = PlayOutcome("skipped")
+	// the track was paused and never resumed
+	PlayAbandoned = PlayOutcome("abandoned")
+)
+
+// PlayRecord describes how a detected play ended, independent of whether it
+// was scrobbled.
+type PlayRecord struct {
+	Scrobble
+	Outcome PlayOutcome
+	// playback position when the play ended
+	Played time.Duration
+}
+
+// PlayRecorder is implemented by local sinks that store play outcomes.
+type PlayRecorder interface {
+	RecordPlay(PlayRecord) error
+	Plays() ([]PlayRecord, error)
+}
+
+// PlayOutcomeOf returns the outcome of a play based on the last status
+// observed before the track changed or the player disappeared.
+func PlayOutcomeOf(last PlaybackStatus) PlayOutcome {
+	switch {
+	case last.State != PlaybackPlaying:
+		return PlayAbandoned
+	case last.Duration-last.Position <= max(completionMargin, last.Duration/20):
+		return PlayCompleted
+	default:
+		return PlaySkipped
+	}
+}
+
+func (p PlayRecord) ToStringSlice() []string {
+	return append(p.Scrobble.ToStringSlice(), string(p.Outcome), strconv.FormatInt(p.Played.Milliseconds(), 10))
+}
+
+func PlayRecordFromRecord(parts []string) (PlayRecord, error) {
+	if len(parts) != unsignedRecordColumns+2 {
+		return PlayRecord{}, errors.New("input has invalid number of columns")
+	}
+
+	scrobble, err := ScrobbleFromRecord(parts[:unsignedRecordColumns])
+	if err != nil {
+		return PlayRecord{}, err
+	}
+
+	millis, err := strconv.ParseInt(parts[unsignedRecordColumns+1], 10, 64)
+	if err != nil {
+		return PlayRecord{}, err
+	}
+
+	return PlayRecord{
+		Scrobble: scrobble,
+		Outcome:  PlayOutcome(parts[unsignedRecordColumns]),
+		Played:   time.Millisecond * time.Duration(millis),
+	}, nil
+}
+
+// SkipStats summarizes the play outcomes of a single artist.
+type SkipStats struct {
+	Artist    string
+	Plays     int
+	Completed int
+	Skipped   int
+	Abandoned int
+}
+
+func (s SkipStats) SkipRate() float64 {
+	if s.Plays == 0 {
+		return 0
+	}
+	return float64(s.Skipped) / float64(s.Plays)
+}
+
+// SkipStatsByArtist groups plays by artist, sorted by the number of plays.
+// Plays with multiple artists count for each of them.
+func SkipStatsByArtist(plays []PlayRecord) []SkipStats {
+	byArtist := map[string]*SkipStats{}
+	for _, play := range plays {
+		for _, artist := range play.Artists {
+			stats, ok := byArtist[artist]
+			if !ok {
+				stats = &SkipStats{Artist: artist, Plays: 0, Completed: 0, Skipped: 0, Abandoned: 0}
+				byArtist[artist] = stats
+			}
+
+			stats.Plays++
+			switch play.Outcome {
+			case PlayCompleted:
+				stats.Completed++
+			case PlaySkipped:
+				stats.Skipped++
+			case PlayAbandoned:
+				stats.Abandoned++
+			}
+		}
+	}
+
+	result := make([]SkipStats, 0, len(byArtist))
+	for _, stats := range byArtist {
+		result = append(result, *stats)
+	}
+	slices.SortFunc(result, func(a, b SkipStats) int {
+		return cmp.Or(cmp.Compare(b.Plays, a.Plays), cmp.Compare(a.Artist, b.Artist))
+	})
+
+	return result
+}
+
+// RecordPlay stores the outcome of a play in all sinks that record plays. The
+// play started with started and was last seen with last.
+func RecordPlay(player string, sinks []Sink, started, last PlaybackStatus) {
+	if !started.IsValid() || !last.Equals(started) {
+		return
+	}
+
+	play := PlayRecord{Scrobble: started.Scrobble, Outcome: PlayOutcomeOf(last), Played: last.Position}
+
+	log.Debug().
+		Str("player", player).
+		Str("outcome", string(play.Outcome)).
+		Interface("scrobble", play.Scrobble).
+		Msg("play ended")
+
+	for _, sink := range sinks {
+		recorder, ok := UnwrapSink(sink).(PlayRecorder)
+		if !ok {
+			continue
+		}
+
+		if err := recorder.RecordPlay(play); err != nil {
+			log.Error().
+				Err(err).
+				Str("sink", sink.Name()).
+				Msg("error recording play")
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayOutcomeOf(t *testing.T) {
+	status := func(state main.PlaybackState, position, duration time.Duration) main.PlaybackStatus {
+		return main.PlaybackStatus{
+			Scrobble: main.Scrobble{Duration: duration},
+			State:    state,
+			Position: position,
+		}
+	}
+
+	require.Equal(t, main.PlayCompleted, main.PlayOutcomeOf(status(main.PlaybackPlaying, 240*time.Second, 251*time.Second)))
+	require.Equal(t, main.PlayCompleted, main.PlayOutcomeOf(status(main.PlaybackPlaying, 570*time.Second, 600*time.Second)))
+	require.Equal(t, main.PlaySkipped, main.PlayOutcomeOf(status(main.PlaybackPlaying, 60*time.Second, 251*time.Second)))
+	require.Equal(t, main.PlayAbandoned, main.PlayOutcomeOf(status(main.PlaybackPaused, 60*time.Second, 251*time.Second)))
+	require.Equal(t, main.PlayAbandoned, main.PlayOutcomeOf(status(main.PlaybackPaused, 250*time.Second, 251*time.Second)))
+}
+
+func TestRecordPlay(t *testing.T) {
+	frames := replayFrames(t)
+
+	paused, err := main.InjectReplayEvent(frames[:3], main.ReplayEvent{Type: main.ReplayPause, Player: "player", Frame: 2, Value: 1})
+	require.NoError(t, err)
+
+	directory := t.TempDir()
+	sink := main.CSVSink{
+		Key:           "default",
+		Filename:      filepath.Join(directory, "scrobbles.csv"),
+		PlaysFilename: filepath.Join(directory, "plays.csv"),
+	}
+
+	notifier := FakeNotifier{}
+
+	// each recording ends with the player disappearing
+	for _, recording := range [][]main.ReplayFrame{frames, frames[:3], paused[:3]} {
+		source := main.NewReplaySource(recording)
+		state := main.NewLoopState()
+		for !source.Done() {
+			main.RunMainLoopOnce(state, replayOptions(), []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+		}
+		main.RunMainLoopOnce(state, replayOptions(), []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	}
+
+	plays, err := sink.Plays()
+	require.NoError(t, err)
+	require.Len(t, plays, 3)
+
+	require.Equal(t, main.PlayCompleted, plays[0].Outcome)
+	require.Equal(t, 240*time.Second, plays[0].Played)
+	require.Equal(t, main.PlaySkipped, plays[1].Outcome)
+	require.Equal(t, 60*time.Second, plays[1].Played)
+	require.Equal(t, main.PlayAbandoned, plays[2].Outcome)
+	require.Equal(t, "Without You I'm Nothing", plays[2].Track)
+
+	stats := main.SkipStatsByArtist(plays)
+	require.Len(t, stats, 1)
+	require.Equal(t, main.SkipStats{Artist: "Placebo", Plays: 3, Completed: 1, Skipped: 1, Abandoned: 1}, stats[0])
+	require.InDelta(t, 1.0/3, stats[0].SkipRate(), 0.001)
+}
+
+func TestSkipStatsByArtist(t *testing.T) {
+	plays := []main.PlayRecord{
+		{Scrobble: main.Scrobble{Artists: []string{"Placebo"}}, Outcome: main.PlaySkipped},
+		{Scrobble: main.Scrobble{Artists: []string{"Placebo", "David Bowie"}}, Outcome: main.PlayCompleted},
+		{Scrobble: main.Scrobble{Artists: []string{"Muse"}}, Outcome: main.PlayCompleted},
+	}
+
+	stats := main.SkipStatsByArtist(plays)
+	require.Len(t, stats, 3)
+	require.Equal(t, "Placebo", stats[0].Artist)
+	require.Equal(t, 2, stats[0].Plays)
+	require.InDelta(t, 0.5, stats[0].SkipRate(), 0.001)
+	require.Equal(t, "David Bowie", stats[1].Artist)
+	require.Equal(t, "Muse", stats[2].Artist)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const DefaultAPIAddress = "127.0.0.1:7636"
+
+// limits of the HTTP servers, so slow or idle clients cannot keep connections
+// open forever
+const (
+	httpReadHeaderTimeout = 10 * time.Second
+	httpReadTimeout       = 30 * time.Second
+	httpIdleTimeout       = 2 * time.Minute
+)
+
+// NewHTTPServer returns a server for handler that limits the time to read
+// requests. There is no write timeout, since the event stream of the HTTP API
+// stays open.
+func NewHTTPServer(handler http.Handler) *http.Server {
+	//nolint:exhaustruct // the zero values are the defaults of net/http
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		ReadTimeout:       httpReadTimeout,
+		IdleTimeout:       httpIdleTimeout,
+	}
+}
+
+// APIServer exposes the control socket handlers over HTTP, e.g. for a remote
+// control on a phone. All requests except for `/healthz` require a bearer
+// token.
+type APIServer struct {
+	Token string
+
+	control  *ControlServer
+	mutex    sync.Mutex
+	listener net.Listener
+	// streamed at /api/events, guarded by mutex
+	events     *EventBus
+	eventTypes EventTypeFlags
+}
+
+func NewAPIServer(token string, control *ControlServer) *APIServer {
+	return &APIServer{
+		Token:      token,
+		control:    control,
+		mutex:      sync.Mutex{},
+		listener:   nil,
+		events:     nil,
+		eventTypes: nil,
+	}
+}
+
+// StreamEvents enables the server-sent events stream at `/api/events` for
+// the given event types. A nil server does nothing.
+func (s *APIServer) StreamEvents(events *EventBus, types EventTypeFlags) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.events = events
+	s.eventTypes = types
+}
+
+// Listen starts serving API requests on the given address in the background.
+func (s *APIServer) Listen(address string) error {
+	if s.Token == "" {
+		return errors.New("HTTP API requires a token")
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.listener = listener
+	s.mutex.Unlock()
+
+	log.Info().
+		Str("address", listener.Addr().String()).
+		Msg("listening for HTTP API requests")
+
+	go func() {
+		if err := NewHTTPServer(s.Handler()).Serve(listener); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Error().
+				Err(err).
+				Msg("HTTP API server stopped")
+		}
+	}()
+
+	return nil
+}
+
+// Close stops serving API requests. A nil server does nothing.
+func (s *APIServer) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *APIServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /api/status", s.handleCommand("status"))
+	mux.HandleFunc("GET /api/now-playing", s.handleCommand("now-playing"))
+	mux.HandleFunc("GET /api/scrobbles", s.handleScrobbles)
+	mux.HandleFunc("GET /api/queue", s.handleCommand("queue"))
+	mux.HandleFunc("POST /api/pause", s.handleCommand("pause"))
+	mux.HandleFunc("POST /api/resume", s.handleCommand("resume"))
+	mux.HandleFunc("POST /api/skip", s.handleCommand("skip"))
+	mux.HandleFunc("POST /api/scrobble", s.handleScrobble)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	return mux
+}
+
+func (s *APIServer) handleCommand(command string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorize(w, r) {
+			return
+		}
+		s.dispatch(w, ControlRequest{Command: command, Arguments: nil, Scrobble: nil})
+	}
+}
+
+// handleHealth does not require a token, so it can be used by container
+// health checks. It responds with 503 if the daemon is unhealthy.
+func (s *APIServer) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	response := s.control.Dispatch(ControlRequest{Command: "health", Arguments: nil, Scrobble: nil})
+	if response.Health == nil {
+		http.Error(w, response.Error, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !response.Health.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(response.Health); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("error writing HTTP API response")
+	}
+}
+
+// handleScrobbles returns recent scrobbles of the sink given in the `sink`
+// query parameter, limited to `limit` (default 20).
+func (s *APIServer) handleScrobbles(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	arguments := []string{r.URL.Query().Get("sink")}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		arguments = append(arguments, limit)
+	}
+	s.dispatch(w, ControlRequest{Command: "scrobbles", Arguments: arguments, Scrobble: nil})
+}
+
+// handleScrobble submits a manual scrobble. The body is the same as for the
+// webhook source.
+func (s *APIServer) handleScrobble(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	var payload WebhookPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&payload); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if err := payload.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	scrobble := payload.Scrobble()
+	s.dispatch(w, ControlRequest{Command: "scrobble", Arguments: nil, Scrobble: &scrobble})
+}
+
+// handleEvents streams events as server-sent events until the client
+// disconnects. The event name is the event type, the data is the JSON
+// encoded event.
+func (s *APIServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	s.mutex.Lock()
+	bus, types := s.events, s.eventTypes
+	s.mutex.Unlock()
+
+	flusher, ok := w.(http.Flusher)
+	if bus == nil || !ok {
+		http.Error(w, "event stream is not available", http.StatusNotFound)
+		return
+	}
+
+	events, cancel := bus.Subscribe(types)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Debug().
+					Err(err).
+					Msg("error encoding event")
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *APIServer) dispatch(w http.ResponseWriter, request ControlRequest) {
+	response := s.control.Dispatch(request)
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Error != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("error writing HTTP API response")
+	}
+}
+
+func (s *APIServer) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if !BearerTokenValid(r, s.Token) {
+		log.Warn().
+			Str("remote", r.RemoteAddr).
+			Str("path", r.URL.Path).
+			Msg("rejected unauthorized HTTP API request")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	log.Debug().
+		Str("remote", r.RemoteAddr).
+		Str("path", r.URL.Path).
+		Msg("received HTTP API request")
+
+	return true
+}
+
+// BearerTokenValid compares the bearer token of a request in constant time.
+func BearerTokenValid(r *http.Request, token string) bool {
+	received := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(received), []byte(token)) == 1
+}
+
+// SubmitScrobble sends a scrobble that was not detected by a source (e.g.,
+// submitted using the HTTP API) to all given sinks.
+func SubmitScrobble(state *LoopState, sinks []Sink, scrobble Scrobble, now time.Time) error {
+	if scrobble.Timestamp.IsZero() {
+		scrobble.Timestamp = now
+	}
+
+	log.Info().
+		Interface("scrobble", scrobble).
+		Msg("scrobbling manually submitted track")
+
+	state.Stats.CountScrobble(now)
+	state.LastScrobble = scrobble
+
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Scrobble(scrobble); err != nil {
+			log.Error().
+				Err(err).
+				Str("sink", sink.Name()).
+				Interface("scrobble", scrobble).
+				Msg("error saving scrobble")
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
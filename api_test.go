@@ -0,0 +1,96 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIServer(t *testing.T) {
+	var received []main.ControlRequest
+
+	control := main.NewControlServer()
+	control.Handle("now-playing", func(request main.ControlRequest) main.ControlResponse {
+		received = append(received, request)
+		response := main.ControlMessage("")
+		response.NowPlaying = map[string]main.PlaybackStatus{"spotify": {Scrobble: defaultScrobble, State: main.PlaybackPlaying}}
+		return response
+	})
+	control.Handle("scrobbles", func(request main.ControlRequest) main.ControlResponse {
+		received = append(received, request)
+		return main.ControlError("invalid sink name")
+	})
+	control.Handle("scrobble", func(request main.ControlRequest) main.ControlResponse {
+		received = append(received, request)
+		return main.ControlMessage("scrobbled " + request.Scrobble.Track)
+	})
+
+	handler := main.NewAPIServer("secret", control).Handler()
+
+	send := func(method, path, token, body string) (int, main.ControlResponse) {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		var response main.ControlResponse
+		_ = json.NewDecoder(recorder.Body).Decode(&response)
+		return recorder.Code, response
+	}
+
+	code, _ := send(http.MethodGet, "/api/now-playing", "wrong", "")
+	require.Equal(t, http.StatusUnauthorized, code)
+	require.Empty(t, received)
+
+	code, response := send(http.MethodGet, "/api/now-playing", "secret", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "Without You I'm Nothing", response.NowPlaying["spotify"].Track)
+
+	code, response = send(http.MethodGet, "/api/scrobbles?sink=csv&limit=5", "secret", "")
+	require.Equal(t, http.StatusInternalServerError, code)
+	require.Equal(t, "invalid sink name", response.Error)
+	require.Equal(t, []string{"csv", "5"}, received[1].Arguments)
+
+	code, _ = send(http.MethodPost, "/api/scrobble", "secret", `{"track":"Meds"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, response = send(http.MethodPost, "/api/scrobble", "secret", `{"artists":["Placebo"],"track":"Meds","duration":163}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "scrobbled Meds", response.Message)
+	require.Equal(t, 163*time.Second, received[2].Scrobble.Duration)
+
+	code, _ = send(http.MethodPost, "/api/now-playing", "secret", "")
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+}
+
+func TestSubmitScrobble(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	state := main.NewLoopState()
+
+	fakeSink := &FakeSink{}
+	failingSink := &FakeSink{Error: true}
+
+	scrobble := main.Scrobble{Artists: []string{"Placebo"}, Track: "Meds"}
+	require.Error(t, main.SubmitScrobble(state, []main.Sink{fakeSink, failingSink}, scrobble, now))
+
+	require.Len(t, fakeSink.ScrobbleLog, 1)
+	require.Equal(t, now, fakeSink.ScrobbleLog[0].Timestamp)
+	require.Equal(t, "Meds", state.LastScrobble.Track)
+	require.Equal(t, 1, state.Stats.ScrobblesOn(now))
+}
+
+func TestNewHTTPServer(t *testing.T) {
+	server := main.NewHTTPServer(http.NotFoundHandler())
+	require.NotNil(t, server.Handler)
+	require.Positive(t, server.ReadHeaderTimeout)
+	require.Positive(t, server.ReadTimeout)
+	require.Positive(t, server.IdleTimeout)
+	// the event stream stays open
+	require.Zero(t, server.WriteTimeout)
+}
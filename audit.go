@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const AuditLogFileName = "audit.jsonl"
+
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Sink     string    `json:"sink"`
+	Scrobble Scrobble  `json:"scrobble"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// AuditSink appends every submitted scrobble, successful or not, to a JSON
+// lines file in the state directory.
+type AuditSink struct {
+	Sink
+	Filename string
+}
+
+func AuditLogFilename() string {
+	return filepath.Join(StateDir(), AuditLogFileName)
+}
+
+func (s AuditSink) Unwrap() Sink {
+	return s.Sink
+}
+
+func (s AuditSink) Scrobble(scrobble Scrobble) error {
+	err := s.Sink.Scrobble(scrobble)
+
+	entry := AuditEntry{
+		Time:     time.Now(),
+		Sink:     s.Name(),
+		Scrobble: scrobble,
+		Error:    "",
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if auditErr := AppendAuditEntry(s.Filename, entry); auditErr != nil {
+		log.Error().
+			Err(auditErr).
+			Str("filename", s.Filename).
+			Msg("error writing audit log entry")
+	}
+
+	return err
+}
+
+func AppendAuditEntry(filename string, entry AuditEntry) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	//nolint:gosec
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(file)
+
+	return json.NewEncoder(file).Encode(entry)
+}
+
+func ReadAuditLog(filename string) ([]AuditEntry, error) {
+	//nolint:gosec
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseLogged(file)
+
+	log.Debug().
+		Str("filename", filename).
+		Msg("reading audit log")
+
+	var entries []AuditEntry
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// AuditedScrobbles returns all scrobbles that were saved by at least one sink,
+// sorted by timestamp. The same play submitted to multiple sinks is only
+// returned once.
+func AuditedScrobbles(entries []AuditEntry) []Scrobble {
+	seen := map[string]bool{}
+
+	var scrobbles []Scrobble
+	for _, entry := range entries {
+		if entry.Error != "" {
+			continue
+		}
+
+		key := entry.Scrobble.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		scrobbles = append(scrobbles, entry.Scrobble)
+	}
+
+	SortScrobbles(scrobbles)
+	return scrobbles
+}
+
+func SortScrobbles(scrobbles []Scrobble) {
+	slices.SortStableFunc(scrobbles, func(a, b Scrobble) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+}
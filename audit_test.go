@@ -0,0 +1,46 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditSink(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), main.AuditLogFileName)
+
+	fakeSink := &FakeSink{}
+	sink := main.AuditSink{Sink: fakeSink, Filename: filename}
+
+	require.NoError(t, sink.Scrobble(defaultScrobble))
+
+	fakeSink.Error = true
+	require.Error(t, sink.Scrobble(defaultScrobble))
+
+	entries, err := main.ReadAuditLog(filename)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "fake sink", entries[0].Sink)
+	require.Empty(t, entries[0].Error)
+	require.Equal(t, "fake error", entries[1].Error)
+	require.True(t, defaultScrobble.Timestamp.Equal(entries[0].Scrobble.Timestamp))
+	require.Equal(t, fakeSink, main.UnwrapSink(sink))
+}
+
+func TestAuditedScrobbles(t *testing.T) {
+	older := defaultScrobble
+	older.Timestamp = defaultScrobble.Timestamp.Add(-time.Hour)
+
+	entries := []main.AuditEntry{
+		{Time: time.Now(), Sink: "csv:default", Scrobble: defaultScrobble, Error: ""},
+		{Time: time.Now(), Sink: "last.fm:default", Scrobble: defaultScrobble, Error: ""},
+		{Time: time.Now(), Sink: "csv:default", Scrobble: older, Error: ""},
+		{Time: time.Now(), Sink: "last.fm:default", Scrobble: older, Error: "fake error"},
+	}
+
+	scrobbles := main.AuditedScrobbles(entries)
+	require.Equal(t, []main.Scrobble{older, defaultScrobble}, scrobbles)
+}
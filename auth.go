@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	lastfm "github.com/p-mng/lastfm-go"
+)
+
+// AuthStatus describes the stored credentials of a sink or source.
+type AuthStatus struct {
+	Authenticated bool
+	Account       string
+	// zero if the credentials do not expire
+	Expires time.Time
+	Scopes  []string
+}
+
+func (s AuthStatus) State(now time.Time) string {
+	switch {
+	case !s.Authenticated:
+		return "not authenticated"
+	case !s.Expires.IsZero() && !now.Before(s.Expires):
+		return "expired"
+	default:
+		return "authenticated"
+	}
+}
+
+func (s AuthStatus) PrettyExpires() string {
+	if s.Expires.IsZero() {
+		return "never"
+	}
+	return s.Expires.Format(time.RFC1123)
+}
+
+func (s AuthStatus) PrettyScopes() string {
+	if len(s.Scopes) == 0 {
+		return "-"
+	}
+	return strings.Join(s.Scopes, ", ")
+}
+
+// LoginOptions change how the user is asked for authorization.
+type LoginOptions struct {
+	// print the authorization URL instead of opening it, so it can be opened
+	// on another device (e.g., on servers only reachable using SSH)
+	NoBrowser bool
+	// keep credentials in the config file, even if a system keyring is
+	// available
+	NoKeyring bool
+}
+
+// Authenticator manages the credentials of a sink or source, which are
+// stored in the configuration or the system keyring. It is named like the sink or source it
+// belongs to.
+type Authenticator interface {
+	Name() string
+	Status(c Config) AuthStatus
+	// Login interactively authenticates the user and stores the credentials.
+	// It reports whether the credentials were stored in the configuration,
+	// which then has to be written.
+	Login(c *Config, options LoginOptions) (bool, error)
+	// Logout removes the stored credentials and reports whether the
+	// configuration was changed.
+	Logout(c *Config) (bool, error)
+}
+
+// Authenticators returns an authenticator for every configured sink and
+// source that requires authentication.
+func (c Config) Authenticators() []Authenticator {
+	var authenticators []Authenticator
+	for _, key := range slices.Sorted(maps.Keys(c.Sinks.LastFm)) {
+		authenticators = append(authenticators, LastFmAuthenticator{Key: key})
+	}
+	if c.Sources.Spotify != nil {
+		authenticators = append(authenticators, OAuthAuthenticator{
+			Integration: SpotifyTokenName,
+			Config:      SpotifyOAuthConfig(c.Sources.Spotify.ClientID),
+			Store:       NewTokenStore(),
+		})
+	}
+	return authenticators
+}
+
+// FindAuthenticator looks up an authenticator by its full name (e.g.,
+// `last.fm:default`). The type alone (e.g., `last.fm`) is accepted if only one
+// authenticator of that type exists.
+func FindAuthenticator(authenticators []Authenticator, name string) (Authenticator, error) {
+	if name == "" {
+		return nil, errors.New("no sink provided (run `goscrobble auth list` to list all sinks that require authentication)")
+	}
+
+	var matches []Authenticator
+	for _, authenticator := range authenticators {
+		if authenticator.Name() == name {
+			return authenticator, nil
+		}
+		if strings.HasPrefix(authenticator.Name(), name+":") {
+			matches = append(matches, authenticator)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, errors.New("invalid sink name (run `goscrobble auth list` to list all sinks that require authentication)")
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, errors.New("ambiguous sink name (run `goscrobble auth list` to list all sinks that require authentication)")
+	}
+}
+
+// LastFmAuthenticator uses the last.fm desktop authentication flow. Session
+// keys do not expire and last.fm has no scopes.
+type LastFmAuthenticator struct {
+	Key string
+}
+
+func (a LastFmAuthenticator) Name() string {
+	return fmt.Sprintf("last.fm:%s", a.Key)
+}
+
+func (a LastFmAuthenticator) Status(c Config) AuthStatus {
+	sinkConfig := c.Sinks.LastFm[a.Key]
+	return AuthStatus{
+		Authenticated: sinkConfig.SessionKey != "" && sinkConfig.Username != "",
+		Account:       sinkConfig.Username,
+		Expires:       time.Time{},
+		Scopes:        nil,
+	}
+}
+
+func (a LastFmAuthenticator) Login(c *Config, options LoginOptions) (bool, error) {
+	sinkConfig, ok := c.Sinks.LastFm[a.Key]
+	if !ok {
+		return false, errors.New("no last.fm sink with this key exists")
+	}
+
+	if a.Status(*c).Authenticated {
+		return false, fmt.Errorf("last.fm is already authenticated (run `goscrobble auth logout %s` first)", a.Name())
+	}
+
+	client, err := lastfm.NewDesktopClient(lastfm.BaseURL, sinkConfig.Key, sinkConfig.Secret)
+	if err != nil {
+		return false, fmt.Errorf("cannot set up last.fm client: %s", err.Error())
+	}
+
+	token, err := client.AuthGetToken()
+	if err != nil {
+		return false, fmt.Errorf("cannot get authorization token: %s", err.Error())
+	}
+
+	authURL := client.DesktopAuthorizationURL(token.Token)
+
+	var session lastfm.AuthGetSessionResponse
+	if options.NoBrowser {
+		fmt.Println("Please open the following URL on any device and authorize the application:", authURL)
+		fmt.Println("Waiting for authorization...")
+
+		session, err = WaitForLastFmSession(func() (lastfm.AuthGetSessionResponse, error) {
+			return client.AuthGetSession(token.Token)
+		}, lastFmSessionPollInterval, lastFmTokenLifetime)
+	} else {
+		if err := OpenURL(c.Opener, authURL); err != nil {
+			fmt.Println("Error opening URL in default browser:", err.Error())
+		}
+
+		fmt.Println("Please open the following URL in your browser and authorize the application:", authURL)
+		fmt.Print("Finished authorization? [Y/n] ")
+
+		input := bufio.NewScanner(os.Stdin)
+		input.Scan()
+
+		response := strings.ToLower(strings.TrimSpace(input.Text()))
+		if response != "y" && response != "" {
+			return false, errors.New("invalid input")
+		}
+
+		session, err = client.AuthGetSession(token.Token)
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot fetch session key from last.fm API: %s", err.Error())
+	}
+
+	fmt.Println("Logged in with user:", session.Session.Name)
+
+	sinkConfig.SessionKey = session.Session.Key
+	sinkConfig.Username = session.Session.Name
+	c.Sinks.LastFm[a.Key] = sinkConfig
+
+	switch {
+	case len(sinkConfig.SessionKeyCmd) > 0:
+		fmt.Println("session_key_cmd is set, so the session key is not written to the config file")
+		fmt.Println("Save it where session_key_cmd reads it from:", session.Session.Key)
+	case !options.NoKeyring && KeyringAvailable():
+		if err := c.StoreInKeyring(a.sessionKeySubject(), session.Session.Key, KeyringSet); err != nil {
+			fmt.Println("Error saving the session key in the system keyring, saving it in the config file instead:", err.Error())
+		} else {
+			fmt.Println("Saved the session key in the system keyring")
+		}
+	}
+
+	return true, nil
+}
+
+const (
+	// returned by auth.getSession until the user authorized the token
+	lastFmUnauthorizedTokenCode = 14
+	lastFmSessionPollInterval   = 5 * time.Second
+	// authorization tokens are valid for 60 minutes
+	lastFmTokenLifetime = time.Hour
+)
+
+// WaitForLastFmSession calls getSession every interval until the user
+// authorized the token, another error occurs, or the timeout expires.
+func WaitForLastFmSession(
+	getSession func() (lastfm.AuthGetSessionResponse, error),
+	interval, timeout time.Duration,
+) (lastfm.AuthGetSessionResponse, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		session, err := getSession()
+		if err == nil || !strings.HasSuffix(err.Error(), fmt.Sprintf("(code %d)", lastFmUnauthorizedTokenCode)) {
+			return session, err
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return lastfm.AuthGetSessionResponse{}, errors.New("timed out waiting for authorization")
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Logout removes the session key from the configuration. last.fm has no API
+// to revoke session keys, this can only be done in the account settings.
+func (a LastFmAuthenticator) Logout(c *Config) (bool, error) {
+	sinkConfig, ok := c.Sinks.LastFm[a.Key]
+	if !ok {
+		return false, errors.New("no last.fm sink with this key exists")
+	}
+
+	if err := c.RemoveFromKeyring(a.sessionKeySubject(), KeyringDelete); err != nil {
+		return false, fmt.Errorf("cannot remove session key from the system keyring: %s", err.Error())
+	}
+
+	sinkConfig.SessionKey = ""
+	sinkConfig.Username = ""
+	c.Sinks.LastFm[a.Key] = sinkConfig
+
+	return true, nil
+}
+
+// sessionKeySubject is the name of the session key in the system keyring.
+func (a LastFmAuthenticator) sessionKeySubject() string {
+	return "sinks.lastfm." + a.Key + ".session_key"
+}
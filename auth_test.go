@@ -0,0 +1,81 @@
+package main_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	lastfm "github.com/p-mng/lastfm-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticators(t *testing.T) {
+	config := main.DefaultConfig
+	config.Sinks.LastFm = map[string]main.LastFmConfig{
+		"default": {Key: "key", Secret: "secret", SessionKey: "session", Username: "user"},
+		"other":   {Key: "key", Secret: "secret"},
+	}
+
+	authenticators := config.Authenticators()
+	require.Len(t, authenticators, 2)
+	require.Equal(t, "last.fm:default", authenticators[0].Name())
+
+	now := time.Now()
+	status := authenticators[0].Status(config)
+	require.Equal(t, "authenticated", status.State(now))
+	require.Equal(t, "user", status.Account)
+	require.Equal(t, "never", status.PrettyExpires())
+	require.Equal(t, "-", status.PrettyScopes())
+	require.Equal(t, "not authenticated", authenticators[1].Status(config).State(now))
+
+	_, err := main.FindAuthenticator(authenticators, "last.fm")
+	require.Error(t, err)
+
+	authenticator, err := main.FindAuthenticator(authenticators, "last.fm:default")
+	require.NoError(t, err)
+	_, err = authenticator.Login(&config, main.LoginOptions{NoBrowser: false, NoKeyring: true})
+	require.Error(t, err)
+
+	changed, err := authenticator.Logout(&config)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Empty(t, config.Sinks.LastFm["default"].SessionKey)
+	require.Equal(t, "not authenticated", authenticator.Status(config).State(now))
+
+	expired := main.AuthStatus{Authenticated: true, Expires: now.Add(-time.Minute), Scopes: []string{"read", "write"}}
+	require.Equal(t, "expired", expired.State(now))
+	require.Equal(t, "read, write", expired.PrettyScopes())
+}
+
+func TestWaitForLastFmSession(t *testing.T) {
+	calls := 0
+	getSession := func() (lastfm.AuthGetSessionResponse, error) {
+		calls++
+		if calls < 3 {
+			return lastfm.AuthGetSessionResponse{}, errors.New("Unauthorized Token - This token has not been authorized (code 14)")
+		}
+		var session lastfm.AuthGetSessionResponse
+		session.Session.Name = "user"
+		return session, nil
+	}
+
+	session, err := main.WaitForLastFmSession(getSession, time.Millisecond, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, "user", session.Session.Name)
+	require.Equal(t, 3, calls)
+
+	// other errors are returned immediately
+	calls = 0
+	_, err = main.WaitForLastFmSession(func() (lastfm.AuthGetSessionResponse, error) {
+		calls++
+		return lastfm.AuthGetSessionResponse{}, errors.New("Invalid API key (code 10)")
+	}, time.Millisecond, time.Second)
+	require.ErrorContains(t, err, "code 10")
+	require.Equal(t, 1, calls)
+
+	_, err = main.WaitForLastFmSession(func() (lastfm.AuthGetSessionResponse, error) {
+		return lastfm.AuthGetSessionResponse{}, errors.New("Unauthorized Token - This token has not been authorized (code 14)")
+	}, 10*time.Millisecond, 30*time.Millisecond)
+	require.ErrorContains(t, err, "timed out")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	BackfillFileName = "backfill.json"
+	// pages fetched before they are written to the destination, every write
+	// rewrites the whole file of a local sink
+	DefaultBackfillBatchPages = 10
+)
+
+// PagedSink is implemented by remote sinks whose full history can be fetched
+// page by page (e.g., LastFmSink).
+type PagedSink interface {
+	Sink
+	ScrobblesPage(to time.Time, page int) ([]Scrobble, int, error)
+}
+
+// BackfillProgress is the state of an unfinished backfill, so it can continue
+// where it stopped.
+type BackfillProgress struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// only scrobbles before this time are fetched, so the pages do not shift
+	// while new scrobbles are added
+	To time.Time `json:"to"`
+	// the next page to fetch
+	Page       int `json:"page"`
+	TotalPages int `json:"total_pages"`
+	Imported   int `json:"imported"`
+}
+
+func BackfillFilename() string {
+	return filepath.Join(StateDir(), BackfillFileName)
+}
+
+func ReadBackfillProgress(filename string) ([]BackfillProgress, error) {
+	//nolint:gosec
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var progress []BackfillProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, fmt.Errorf("invalid backfill progress: %s", err.Error())
+	}
+	return progress, nil
+}
+
+// SaveBackfillProgress replaces the progress of the same source and
+// destination, or removes it if remove is set.
+func SaveBackfillProgress(filename string, progress BackfillProgress, remove bool) error {
+	saved, err := ReadBackfillProgress(filename)
+	if err != nil {
+		return err
+	}
+
+	saved = slices.DeleteFunc(saved, func(p BackfillProgress) bool {
+		return p.Source == progress.Source && p.Destination == progress.Destination
+	})
+	if !remove {
+		saved = append(saved, progress)
+	}
+
+	if len(saved) == 0 {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0600)
+}
+
+// Backfill copies the full history of a remote sink to a local sink. The
+// progress is saved after every batch of pages, so a long history can be
+// fetched across multiple runs.
+type Backfill struct {
+	Source      PagedSink
+	Destination Sink
+	Filename    string
+	BatchPages  int
+	// called after every batch written to the destination
+	OnProgress func(BackfillProgress)
+}
+
+// Run continues the saved backfill of the source and destination, or starts
+// a new one with the scrobbles before now. The saved progress is removed once
+// all pages were fetched.
+func (b Backfill) Run(now time.Time) (BackfillProgress, error) {
+	if _, ok := UnwrapSink(b.Destination).(EditableSink); !ok {
+		return BackfillProgress{}, fmt.Errorf("cannot backfill sink %s", b.Destination.Name())
+	}
+
+	progress := BackfillProgress{
+		Source:      b.Source.Name(),
+		Destination: b.Destination.Name(),
+		To:          now,
+		Page:        1,
+		TotalPages:  0,
+		Imported:    0,
+	}
+
+	saved, err := ReadBackfillProgress(b.Filename)
+	if err != nil {
+		return progress, err
+	}
+	for _, p := range saved {
+		if p.Source == progress.Source && p.Destination == progress.Destination {
+			progress = p
+			log.Info().
+				Int("page", p.Page).
+				Int("pages", p.TotalPages).
+				Msg("continuing backfill")
+		}
+	}
+
+	var batch []Scrobble
+	pages := 0
+	for progress.TotalPages == 0 || progress.Page <= progress.TotalPages {
+		scrobbles, totalPages, err := b.Source.ScrobblesPage(progress.To, progress.Page)
+
+		var rateLimitErr RateLimitError
+		if errors.As(err, &rateLimitErr) && time.Until(rateLimitErr.Until) <= lastFmMaxPaginationWait {
+			log.Debug().
+				Int("page", progress.Page).
+				Time("until", rateLimitErr.Until).
+				Msg("waiting for rate limit before loading next page")
+			time.Sleep(time.Until(rateLimitErr.Until))
+			continue
+		}
+		if err != nil {
+			// keep the pages fetched so far
+			if flushErr := b.flush(&progress, batch); flushErr != nil {
+				return progress, flushErr
+			}
+			return progress, fmt.Errorf("error fetching page %d: %s", progress.Page, err.Error())
+		}
+
+		batch = append(batch, scrobbles...)
+		progress.TotalPages = totalPages
+		progress.Page++
+		pages++
+
+		if totalPages == 0 {
+			break
+		}
+		if pages%max(b.BatchPages, 1) == 0 {
+			if err := b.flush(&progress, batch); err != nil {
+				return progress, err
+			}
+			batch = nil
+		}
+	}
+
+	if err := b.flush(&progress, batch); err != nil {
+		return progress, err
+	}
+	return progress, SaveBackfillProgress(b.Filename, progress, true)
+}
+
+func (b Backfill) flush(progress *BackfillProgress, batch []Scrobble) error {
+	if len(batch) > 0 {
+		added, err := ImportScrobbles(b.Destination, batch)
+		if err != nil {
+			return fmt.Errorf("error writing scrobbles: %s", err.Error())
+		}
+		progress.Imported += added
+	}
+
+	if err := SaveBackfillProgress(b.Filename, *progress, false); err != nil {
+		return fmt.Errorf("error saving backfill progress: %s", err.Error())
+	}
+	if b.OnProgress != nil {
+		b.OnProgress(*progress)
+	}
+	return nil
+}
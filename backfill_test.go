@@ -0,0 +1,91 @@
+package main_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+type PagedSink struct {
+	FakeSink
+	Pages    [][]main.Scrobble
+	FailPage int
+	Requests []int
+}
+
+func (s *PagedSink) ScrobblesPage(_ time.Time, page int) ([]main.Scrobble, int, error) {
+	s.Requests = append(s.Requests, page)
+	if page == s.FailPage {
+		s.FailPage = 0
+		return nil, 0, errors.New("connection reset")
+	}
+	return s.Pages[page-1], len(s.Pages), nil
+}
+
+func TestBackfill(t *testing.T) {
+	var pages [][]main.Scrobble
+	for page := range 5 {
+		scrobble := defaultScrobble
+		scrobble.Timestamp = defaultScrobble.Timestamp.Add(-time.Duration(page) * time.Hour)
+		pages = append(pages, []main.Scrobble{scrobble})
+	}
+
+	directory := t.TempDir()
+	source := &PagedSink{Pages: pages, FailPage: 4}
+	destination := main.CSVSink{Key: "default", Filename: filepath.Join(directory, "scrobbles.csv")}
+	backfill := main.Backfill{
+		Source:      source,
+		Destination: destination,
+		Filename:    filepath.Join(directory, main.BackfillFileName),
+		BatchPages:  2,
+	}
+	now := time.Now()
+
+	progress, err := backfill.Run(now)
+	require.Error(t, err)
+	require.Equal(t, 4, progress.Page)
+	require.Equal(t, 3, progress.Imported)
+
+	saved, err := main.ReadBackfillProgress(backfill.Filename)
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	require.Equal(t, 4, saved[0].Page)
+	require.True(t, now.Equal(saved[0].To))
+
+	// the second run continues with the failed page
+	progress, err = backfill.Run(now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 5, progress.Imported)
+	require.Equal(t, []int{1, 2, 3, 4, 4, 5}, source.Requests)
+
+	saved, err = main.ReadBackfillProgress(backfill.Filename)
+	require.NoError(t, err)
+	require.Empty(t, saved)
+
+	scrobbles, err := destination.GetScrobbles(0, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 5)
+
+	_, err = main.Backfill{Source: source, Destination: &FakeSink{}, Filename: backfill.Filename, BatchPages: 2}.Run(now)
+	require.Error(t, err)
+}
+
+func TestBackfillEmpty(t *testing.T) {
+	directory := t.TempDir()
+	source := &PagedSink{Pages: [][]main.Scrobble{nil}}
+	backfill := main.Backfill{
+		Source:      source,
+		Destination: main.CSVSink{Key: "default", Filename: filepath.Join(directory, "scrobbles.csv")},
+		Filename:    filepath.Join(directory, main.BackfillFileName),
+		BatchPages:  main.DefaultBackfillBatchPages,
+	}
+
+	progress, err := backfill.Run(time.Now())
+	require.NoError(t, err)
+	require.Zero(t, progress.Imported)
+	require.Equal(t, []int{1}, source.Requests)
+}
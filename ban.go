@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// BanListFileName is read from the directory of the config file.
+const BanListFileName = "bans.toml"
+
+// Ban excludes tracks from scrobbling, e.g. as added by `goscrobble ban`. All
+// fields that are not empty must match, case-insensitively. The artist
+// matches any of the artists of a track or all of them joined.
+type Ban struct {
+	Artist string `toml:"artist"`
+	Track  string `toml:"track,omitempty"`
+	Album  string `toml:"album,omitempty"`
+}
+
+type BanList struct {
+	Bans []Ban `toml:"ban"`
+}
+
+func BanListFilename(configFilename string) string {
+	return filepath.Join(filepath.Dir(configFilename), BanListFileName)
+}
+
+// ReadBanList reads the bans of a ban list file. A missing file has no bans.
+func ReadBanList(filename string) ([]Ban, error) {
+	var list BanList
+	if _, err := toml.DecodeFile(filename, &list); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return list.Bans, nil
+}
+
+// AddBan appends a ban to the ban list file and reports whether it was added,
+// i.e. it was not in the list already.
+func AddBan(filename string, ban Ban) (bool, error) {
+	bans, err := ReadBanList(filename)
+	if err != nil {
+		return false, err
+	}
+	if slices.ContainsFunc(bans, func(b Ban) bool { return b.key() == ban.key() }) {
+		return false, nil
+	}
+
+	var builder strings.Builder
+	if err := toml.NewEncoder(&builder).Encode(BanList{Bans: append(bans, ban)}); err != nil {
+		return false, err
+	}
+
+	temporary := filename + ".tmp"
+	if err := os.WriteFile(temporary, []byte(builder.String()), 0600); err != nil {
+		return false, err
+	}
+	return true, os.Rename(temporary, filename)
+}
+
+// NewBan returns the ban for the artist, track, or album of a scrobble. Only
+// the first artist is banned, tracks and albums are banned for all artists of
+// the scrobble.
+func NewBan(scrobble Scrobble, field string) (Ban, error) {
+	if len(scrobble.Artists) == 0 {
+		return Ban{}, errors.New("the track has no artist")
+	}
+
+	switch field {
+	case "artist":
+		return Ban{Artist: scrobble.Artists[0], Track: "", Album: ""}, nil
+	case "track":
+		return Ban{Artist: scrobble.JoinArtists(), Track: scrobble.Track, Album: ""}, nil
+	case "album":
+		if scrobble.Album == "" {
+			return Ban{}, errors.New("the track has no album")
+		}
+		return Ban{Artist: scrobble.JoinArtists(), Track: "", Album: scrobble.Album}, nil
+	default:
+		return Ban{}, errors.New("invalid ban field: " + field)
+	}
+}
+
+func (b Ban) key() string {
+	return strings.ToLower(b.Artist + "\x00" + b.Track + "\x00" + b.Album)
+}
+
+func (b Ban) String() string {
+	switch {
+	case b.Track != "":
+		return b.Artist + " " + string(RuneEmDash) + " " + b.Track
+	case b.Album != "":
+		return b.Artist + " " + string(RuneEmDash) + " " + b.Album + " (album)"
+	default:
+		return b.Artist
+	}
+}
+
+func (b Ban) Matches(scrobble Scrobble) bool {
+	if b.Artist == "" && b.Track == "" && b.Album == "" {
+		return false
+	}
+
+	if b.Artist != "" && !strings.EqualFold(b.Artist, scrobble.JoinArtists()) &&
+		!slices.ContainsFunc(scrobble.Artists, func(artist string) bool { return strings.EqualFold(b.Artist, artist) }) {
+		return false
+	}
+	if b.Track != "" && !strings.EqualFold(b.Track, scrobble.Track) {
+		return false
+	}
+	return b.Album == "" || strings.EqualFold(b.Album, scrobble.Album)
+}
+
+// IsBanned reports whether any of the bans matches the scrobble.
+func IsBanned(bans []Ban, scrobble Scrobble) bool {
+	return slices.ContainsFunc(bans, func(b Ban) bool { return b.Matches(scrobble) })
+}
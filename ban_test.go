@@ -0,0 +1,73 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBanMatches(t *testing.T) {
+	// defaultScrobble is "Placebo, David Bowie — Without You I'm Nothing"
+	require.True(t, main.Ban{Artist: "david bowie", Track: "", Album: ""}.Matches(defaultScrobble))
+	require.True(t, main.Ban{Artist: "Placebo, David Bowie", Track: "without you i'm nothing", Album: ""}.Matches(defaultScrobble))
+	require.True(t, main.Ban{Artist: "Placebo", Track: "", Album: "A Place For Us To Dream"}.Matches(defaultScrobble))
+	require.False(t, main.Ban{Artist: "Placebo", Track: "Pure Morning", Album: ""}.Matches(defaultScrobble))
+	require.False(t, main.Ban{Artist: "Bowie", Track: "", Album: ""}.Matches(defaultScrobble))
+	require.False(t, main.Ban{}.Matches(defaultScrobble))
+
+	require.False(t, main.IsBanned(nil, defaultScrobble))
+}
+
+func TestNewBan(t *testing.T) {
+	ban, err := main.NewBan(defaultScrobble, "artist")
+	require.NoError(t, err)
+	require.Equal(t, main.Ban{Artist: "Placebo", Track: "", Album: ""}, ban)
+
+	ban, err = main.NewBan(defaultScrobble, "track")
+	require.NoError(t, err)
+	require.Equal(t, main.Ban{Artist: "Placebo, David Bowie", Track: "Without You I'm Nothing", Album: ""}, ban)
+
+	noAlbum := defaultScrobble
+	noAlbum.Album = ""
+	_, err = main.NewBan(noAlbum, "album")
+	require.Error(t, err)
+}
+
+func TestAddBan(t *testing.T) {
+	configFilename := filepath.Join(t.TempDir(), "config.toml")
+	filename := main.BanListFilename(configFilename)
+
+	bans, err := main.ReadBanList(filename)
+	require.NoError(t, err)
+	require.Empty(t, bans)
+
+	ban := main.Ban{Artist: "Placebo", Track: "Pure Morning", Album: ""}
+	added, err := main.AddBan(filename, ban)
+	require.NoError(t, err)
+	require.True(t, added)
+
+	added, err = main.AddBan(filename, main.Ban{Artist: "placebo", Track: "pure morning", Album: ""})
+	require.NoError(t, err)
+	require.False(t, added)
+
+	config, err := main.ReadConfig(configFilename)
+	require.NoError(t, err)
+	require.Equal(t, []main.Ban{ban}, config.Bans)
+	require.Equal(t, []main.Ban{ban}, config.LoopOptions().Bans)
+}
+
+func TestBannedTracksAreIgnored(t *testing.T) {
+	state := main.NewLoopState()
+	source := &FakeSource{Empty: false, Error: false, PlaybackStatus: defaultPlaybackStatus}
+	sink := &FakeSink{}
+
+	config := main.DefaultConfig
+	config.Bans = []main.Ban{{Artist: "Placebo", Track: "", Album: ""}}
+	options := config.LoopOptions()
+
+	main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, (&FakeNotifier{}).SendNotification)
+	require.Empty(t, state.CurrentlyPlaying)
+	require.Empty(t, sink.NowPlayingLog)
+}
@@ -0,0 +1,8 @@
+//go:build minimal
+
+package main
+
+// MinimalBuild is set for binaries built with the minimal tag, which leave out
+// subsystems with large dependencies that are rarely needed on embedded
+// devices.
+const MinimalBuild = true
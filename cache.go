@@ -0,0 +1,239 @@
+package main
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CacheDirName = "cache"
+	// one day
+	DefaultCacheTTL = 24 * 60 * 60
+	// megabytes
+	DefaultCacheMaxSize = 50
+	cacheFileExtension  = ".json"
+)
+
+// Cache stores the responses of read-only lookups (e.g., the scrobble history
+// of a remote sink) on disk, so repeated lookups are fast and do not count
+// against API limits. Entries expire after TTL and the oldest entries are
+// removed once the cache grows beyond MaxSize bytes. A nil cache stores
+// nothing.
+type Cache struct {
+	Dir     string
+	TTL     time.Duration
+	MaxSize int64
+}
+
+type cacheEntry struct {
+	Key     string          `json:"key"`
+	Expires time.Time       `json:"expires"`
+	Value   json.RawMessage `json:"value"`
+}
+
+func CacheDirname() string {
+	return filepath.Join(StateDir(), CacheDirName)
+}
+
+func (c Config) SetupCache() *Cache {
+	if c.Cache == nil {
+		return nil
+	}
+
+	return &Cache{
+		Dir:     CacheDirname(),
+		TTL:     time.Duration(c.Cache.TTL) * time.Second,
+		MaxSize: int64(c.Cache.MaxSize) * 1024 * 1024,
+	}
+}
+
+func (c *Cache) filename(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(hash[:])+cacheFileExtension)
+}
+
+// Get decodes the cached value of key into value and reports whether an
+// unexpired entry was found.
+func (c *Cache) Get(key string, value any, now time.Time) bool {
+	if c == nil {
+		return false
+	}
+
+	//nolint:gosec
+	data, err := os.ReadFile(c.filename(key))
+	if err != nil {
+		return false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key || !now.Before(entry.Expires) {
+		return false
+	}
+
+	if err := json.Unmarshal(entry.Value, value); err != nil {
+		log.Warn().
+			Err(err).
+			Str("key", key).
+			Msg("error decoding cache entry")
+		return false
+	}
+
+	log.Debug().Str("key", key).Msg("using cached value")
+	return true
+}
+
+// Set stores the value of key and removes expired and, if the cache is too
+// large, the oldest entries.
+func (c *Cache) Set(key string, value any, now time.Time) error {
+	if c == nil {
+		return nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(cacheEntry{Key: key, Expires: now.Add(c.TTL), Value: encoded})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.filename(key), data, 0600); err != nil {
+		return err
+	}
+
+	return c.Prune(now)
+}
+
+type cacheFile struct {
+	Path     string
+	Size     int64
+	Modified time.Time
+}
+
+func (c *Cache) files() ([]cacheFile, error) {
+	entries, err := os.ReadDir(c.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var files []cacheFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), cacheFileExtension) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{
+			Path:     filepath.Join(c.Dir, entry.Name()),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+// Prune removes expired entries, then the oldest entries until the cache is
+// no larger than MaxSize. A MaxSize of zero disables the size limit.
+func (c *Cache) Prune(now time.Time) error {
+	if c == nil {
+		return nil
+	}
+
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	var kept []cacheFile
+	for _, file := range files {
+		// entries are written once, so the modification time is the time
+		// they were stored
+		if !now.Before(file.Modified.Add(c.TTL)) {
+			if err := os.Remove(file.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		size += file.Size
+		kept = append(kept, file)
+	}
+
+	if c.MaxSize <= 0 {
+		return nil
+	}
+
+	slices.SortFunc(kept, func(a, b cacheFile) int {
+		return cmp.Compare(a.Modified.UnixNano(), b.Modified.UnixNano())
+	})
+	for _, file := range kept {
+		if size <= c.MaxSize {
+			break
+		}
+		log.Debug().Str("filename", file.Path).Msg("cache is too large, removing oldest entry")
+		if err := os.Remove(file.Path); err != nil {
+			return err
+		}
+		size -= file.Size
+	}
+
+	return nil
+}
+
+// ClearCache removes all entries from the cache directory and returns how
+// many were removed.
+func ClearCache(dir string) (int, error) {
+	files, err := (&Cache{Dir: dir, TTL: 0, MaxSize: 0}).files()
+	if err != nil {
+		return 0, err
+	}
+
+	for i, file := range files {
+		if err := os.Remove(file.Path); err != nil {
+			return i, err
+		}
+	}
+	return len(files), nil
+}
+
+// CachedScrobbles returns all scrobbles of the sink since from, using the
+// cache if possible. Only use this where a slightly outdated history is fine
+// (e.g., listen-again suggestions).
+func CachedScrobbles(cache *Cache, sink Sink, from, now time.Time) ([]Scrobble, error) {
+	key := "scrobbles:" + sink.Name() + ":" + from.UTC().Format(time.RFC3339)
+
+	var scrobbles []Scrobble
+	if cache.Get(key, &scrobbles, now) {
+		return scrobbles, nil
+	}
+
+	scrobbles, err := sink.GetScrobbles(0, from, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cache.Set(key, scrobbles, now); err != nil {
+		log.Warn().
+			Err(err).
+			Str("key", key).
+			Msg("error writing cache entry")
+	}
+	return scrobbles, nil
+}
@@ -0,0 +1,73 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	cache := &main.Cache{Dir: t.TempDir(), TTL: time.Hour, MaxSize: 0}
+	now := time.Now()
+
+	var value []string
+	require.False(t, cache.Get("key", &value, now))
+
+	require.NoError(t, cache.Set("key", []string{"Placebo"}, now))
+	require.True(t, cache.Get("key", &value, now))
+	require.Equal(t, []string{"Placebo"}, value)
+
+	require.False(t, cache.Get("other key", &value, now))
+	require.False(t, cache.Get("key", &value, now.Add(2*time.Hour)))
+
+	removed, err := main.ClearCache(cache.Dir)
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	require.False(t, cache.Get("key", &value, now))
+
+	var nilCache *main.Cache
+	require.NoError(t, nilCache.Set("key", value, now))
+	require.False(t, nilCache.Get("key", &value, now))
+}
+
+func TestCacheMaxSize(t *testing.T) {
+	cache := &main.Cache{Dir: t.TempDir(), TTL: time.Hour, MaxSize: 100}
+	now := time.Now()
+
+	require.NoError(t, cache.Set("first", "Without You I'm Nothing", now))
+	// the modification time is used to find the oldest entry
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, cache.Set("second", "Black Market Music", now))
+
+	var value string
+	require.False(t, cache.Get("first", &value, now))
+	require.True(t, cache.Get("second", &value, now))
+	require.Equal(t, "Black Market Music", value)
+}
+
+func TestCachedScrobbles(t *testing.T) {
+	cache := &main.Cache{Dir: t.TempDir(), TTL: time.Hour, MaxSize: 0}
+	sink := &CountingSink{}
+	now := time.Now()
+
+	for range 2 {
+		scrobbles, err := main.CachedScrobbles(cache, sink, time.Time{}, now)
+		require.NoError(t, err)
+		require.Len(t, scrobbles, 1)
+		require.Equal(t, defaultScrobble.Track, scrobbles[0].Track)
+		require.True(t, defaultScrobble.Timestamp.Equal(scrobbles[0].Timestamp))
+	}
+	require.Equal(t, 1, sink.Requests)
+}
+
+type CountingSink struct {
+	FakeSink
+	Requests int
+}
+
+func (s *CountingSink) GetScrobbles(_ int, _, _ time.Time) ([]main.Scrobble, error) {
+	s.Requests++
+	return []main.Scrobble{defaultScrobble}, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// last.fm ignores scrobbles older than two weeks
+	lastFmMaxScrobbleAge = 14 * 24 * time.Hour
+	// allows for small differences between the local and the last.fm clock
+	maxFutureTimestamp = 5 * time.Minute
+)
+
+// earliestPlausibleTime is earlier than any correct clock running this
+// version. Devices without a real-time clock (e.g., a Raspberry Pi before NTP
+// synced the clock) start at the epoch or a saved time instead.
+var earliestPlausibleTime = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// HeldScrobble is a scrobble that was held back until the system clock is
+// set.
+type HeldScrobble struct {
+	Player string
+	Status PlaybackStatus
+}
+
+// ClockPlausible reports whether the system clock appears to be set.
+func ClockPlausible(now time.Time) bool {
+	return !now.Before(earliestPlausibleTime)
+}
+
+// CorrectTimestamp moves a timestamp taken before the clock was set to the
+// correct time, using the monotonic clock that is not affected by setting the
+// clock. Timestamps without a monotonic reading (e.g., read from a file) are
+// returned unchanged.
+func CorrectTimestamp(timestamp, now time.Time) time.Time {
+	if timestamp.Round(0) == timestamp {
+		return timestamp
+	}
+	return now.Round(0).Add(-now.Sub(timestamp))
+}
+
+// CheckTimestamp returns the timestamp to submit for a scrobble: timestamps in
+// the future are replaced with now, and timestamps last.fm would ignore are
+// logged.
+func CheckTimestamp(timestamp, now time.Time) time.Time {
+	switch {
+	case timestamp.After(now.Add(maxFutureTimestamp)):
+		log.Warn().
+			Time("timestamp", timestamp).
+			Msg("scrobble timestamp is in the future, using current time")
+		return now
+	case now.Sub(timestamp) > lastFmMaxScrobbleAge:
+		log.Warn().
+			Time("timestamp", timestamp).
+			Msg("scrobble is older than two weeks and will be ignored by last.fm")
+	}
+	return timestamp
+}
+
+// ReleaseHeldScrobbles sends the scrobbles that were held back as soon as the
+// system clock is set, with their timestamps corrected.
+func (s *LoopState) ReleaseHeldScrobbles(options LoopOptions, sinks []Sink, notifier NotifierFunc, now time.Time) {
+	if len(s.HeldScrobbles) == 0 || !ClockPlausible(now) {
+		return
+	}
+
+	log.Info().
+		Int("scrobbles", len(s.HeldScrobbles)).
+		Msg("system clock was set, sending held scrobbles")
+
+	held := s.HeldScrobbles
+	s.HeldScrobbles = nil
+	for _, scrobble := range held {
+		scrobble.Status.Timestamp = CorrectTimestamp(scrobble.Status.Timestamp, now)
+		sendScrobble(s, options, scrobble.Player, sinks, scrobble.Status, notifier)
+	}
+}
@@ -0,0 +1,51 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockPlausible(t *testing.T) {
+	require.True(t, main.ClockPlausible(time.Now()))
+	require.False(t, main.ClockPlausible(time.Unix(0, 0)))
+}
+
+func TestCorrectTimestamp(t *testing.T) {
+	now := time.Now()
+	timestamp := now.Add(-time.Minute)
+	require.True(t, now.Add(-time.Minute).Equal(main.CorrectTimestamp(timestamp, now)))
+
+	// timestamps without a monotonic clock reading cannot be corrected
+	stripped := time.Unix(60, 0)
+	require.Equal(t, stripped, main.CorrectTimestamp(stripped, now))
+}
+
+func TestCheckTimestamp(t *testing.T) {
+	now := time.Now()
+	require.Equal(t, now, main.CheckTimestamp(now.Add(time.Hour), now))
+	require.Equal(t, now.Add(-time.Hour), main.CheckTimestamp(now.Add(-time.Hour), now))
+	require.Equal(t, now.Add(time.Minute), main.CheckTimestamp(now.Add(time.Minute), now))
+}
+
+func TestReleaseHeldScrobbles(t *testing.T) {
+	state := main.NewLoopState()
+	sink := &FakeSink{}
+	notifier := FakeNotifier{}
+
+	status := defaultPlaybackStatus
+	status.Timestamp = time.Now().Add(-time.Minute)
+	state.HeldScrobbles = []main.HeldScrobble{{Player: "player", Status: status}}
+
+	// the clock is not set yet
+	state.ReleaseHeldScrobbles(replayOptions(), []main.Sink{sink}, notifier.SendNotification, time.Unix(0, 0))
+	require.Empty(t, sink.ScrobbleLog)
+	require.Len(t, state.HeldScrobbles, 1)
+
+	state.ReleaseHeldScrobbles(replayOptions(), []main.Sink{sink}, notifier.SendNotification, time.Now())
+	require.Empty(t, state.HeldScrobbles)
+	require.Len(t, sink.ScrobbleLog, 1)
+	require.WithinDuration(t, time.Now().Add(-time.Minute), sink.ScrobbleLog[0].Timestamp, time.Second)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ScrobbleColumn is a column of the scrobbles table that can be selected
+// with `--columns` and sorted by with `--sort`.
+type ScrobbleColumn struct {
+	// lowercase name and alternative names used in flags
+	Name    string
+	Aliases []string
+	Header  string
+	Value   func(Scrobble) string
+	Compare func(a, b Scrobble) int
+}
+
+var ScrobbleColumns = []ScrobbleColumn{
+	{
+		Name:    "artists",
+		Aliases: []string{"artist"},
+		Header:  "ARTISTS",
+		Value:   Scrobble.JoinArtists,
+		Compare: func(a, b Scrobble) int { return compareText(a.JoinArtists(), b.JoinArtists()) },
+	},
+	{
+		Name:    "track",
+		Aliases: nil,
+		Header:  "TRACK",
+		Value:   func(s Scrobble) string { return s.Track },
+		Compare: func(a, b Scrobble) int { return compareText(a.Track, b.Track) },
+	},
+	{
+		Name:    "album",
+		Aliases: nil,
+		Header:  "ALBUM",
+		Value:   func(s Scrobble) string { return s.Album },
+		Compare: func(a, b Scrobble) int { return compareText(a.Album, b.Album) },
+	},
+	{
+		Name:    "duration",
+		Aliases: nil,
+		Header:  "DURATION",
+		Value:   Scrobble.PrettyDuration,
+		Compare: func(a, b Scrobble) int { return cmp.Compare(a.Duration, b.Duration) },
+	},
+	{
+		Name:    "timestamp",
+		Aliases: []string{"time"},
+		Header:  "TIMESTAMP",
+		Value:   func(s Scrobble) string { return s.Timestamp.Format(time.RFC1123) },
+		Compare: func(a, b Scrobble) int { return a.Timestamp.Compare(b.Timestamp) },
+	},
+}
+
+func compareText(a, b string) int {
+	return cmp.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// FindScrobbleColumn returns the column with the given name or alias.
+func FindScrobbleColumn(name string) (ScrobbleColumn, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, column := range ScrobbleColumns {
+		if column.Name == name || slices.Contains(column.Aliases, name) {
+			return column, nil
+		}
+	}
+
+	names := make([]string, 0, len(ScrobbleColumns))
+	for _, column := range ScrobbleColumns {
+		names = append(names, column.Name)
+	}
+	return ScrobbleColumn{}, fmt.Errorf("unknown column %q (must be one of %s)", name, strings.Join(names, ", "))
+}
+
+// SelectScrobbleColumns returns the columns with the given names in the given
+// order, or the default columns if names is empty.
+func SelectScrobbleColumns(names []string, defaults []string) ([]ScrobbleColumn, error) {
+	if len(names) == 0 {
+		names = defaults
+	}
+
+	var columns []ScrobbleColumn
+	for _, name := range names {
+		column, err := FindScrobbleColumn(name)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// OrderScrobbles sorts the items by the column with the given name in
+// ascending order, keeping the order of equal items. Without a column, the
+// items keep their order (newest first for all sinks). If reverse is set, the
+// result is reversed.
+func OrderScrobbles[T any](items []T, scrobble func(T) Scrobble, column string, reverse bool) error {
+	if column != "" {
+		sortColumn, err := FindScrobbleColumn(column)
+		if err != nil {
+			return err
+		}
+		slices.SortStableFunc(items, func(a, b T) int {
+			return sortColumn.Compare(scrobble(a), scrobble(b))
+		})
+	}
+
+	if reverse {
+		slices.Reverse(items)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectScrobbleColumns(t *testing.T) {
+	columns, err := main.SelectScrobbleColumns(nil, []string{"artists", "track"})
+	require.NoError(t, err)
+	require.Len(t, columns, 2)
+	require.Equal(t, "ARTISTS", columns[0].Header)
+
+	columns, err = main.SelectScrobbleColumns([]string{"Track", " artist", "time"}, []string{"artists"})
+	require.NoError(t, err)
+	require.Len(t, columns, 3)
+	require.Equal(t, "TRACK", columns[0].Header)
+	require.Equal(t, "ARTISTS", columns[1].Header)
+	require.Equal(t, "TIMESTAMP", columns[2].Header)
+	require.Equal(t, "Placebo, David Bowie", columns[1].Value(defaultScrobble))
+
+	_, err = main.SelectScrobbleColumns([]string{"genre"}, nil)
+	require.Error(t, err)
+}
+
+func TestOrderScrobbles(t *testing.T) {
+	base := time.Unix(1699225080, 0)
+	scrobbles := []main.Scrobble{
+		{Artists: []string{"placebo"}, Track: "Meds", Album: "", Duration: 3 * time.Minute, Timestamp: base.Add(2 * time.Hour)},
+		{Artists: []string{"Muse"}, Track: "Uprising", Album: "", Duration: 5 * time.Minute, Timestamp: base.Add(time.Hour)},
+		{Artists: []string{"Placebo"}, Track: "Pure Morning", Album: "", Duration: 4 * time.Minute, Timestamp: base},
+	}
+	tracks := func(scrobbles []main.Scrobble) []string {
+		var result []string
+		for _, s := range scrobbles {
+			result = append(result, s.Track)
+		}
+		return result
+	}
+	identity := func(s main.Scrobble) main.Scrobble { return s }
+
+	// artists are compared case-insensitively, equal ones keep their order
+	require.NoError(t, main.OrderScrobbles(scrobbles, identity, "artist", false))
+	require.Equal(t, []string{"Uprising", "Meds", "Pure Morning"}, tracks(scrobbles))
+
+	require.NoError(t, main.OrderScrobbles(scrobbles, identity, "duration", true))
+	require.Equal(t, []string{"Uprising", "Pure Morning", "Meds"}, tracks(scrobbles))
+
+	require.NoError(t, main.OrderScrobbles(scrobbles, identity, "timestamp", false))
+	require.Equal(t, []string{"Pure Morning", "Uprising", "Meds"}, tracks(scrobbles))
+
+	// without a column, the order is only reversed
+	require.NoError(t, main.OrderScrobbles(scrobbles, identity, "", true))
+	require.Equal(t, []string{"Meds", "Uprising", "Pure Morning"}, tracks(scrobbles))
+
+	require.Error(t, main.OrderScrobbles(scrobbles, identity, "genre", false))
+}
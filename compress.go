@@ -0,0 +1,145 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+type Compression string
+
+const (
+	CompressionNone = Compression("")
+	CompressionGzip = Compression("gzip")
+	CompressionZstd = Compression("zstd")
+)
+
+// CompressionFromFilename detects the compression of local sink files based on
+// their file extension (`.gz` or `.zst`).
+func CompressionFromFilename(filename string) Compression {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gz":
+		return CompressionGzip
+	case ".zst":
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+type compressedReader struct {
+	io.Reader
+	closers []func() error
+}
+
+func (r compressedReader) Close() error {
+	var err error
+	for _, closer := range r.closers {
+		if closeErr := closer(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// OpenCompressed opens a file for reading and transparently decompresses it.
+func OpenCompressed(filename string) (io.ReadCloser, error) {
+	//nolint:gosec
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch CompressionFromFilename(filename) {
+	case CompressionGzip:
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			CloseLogged(file)
+			return nil, err
+		}
+		return compressedReader{Reader: reader, closers: []func() error{reader.Close, file.Close}}, nil
+	case CompressionZstd:
+		decoder, closeDecoder, err := newZstdReader(file)
+		if err != nil {
+			CloseLogged(file)
+			return nil, err
+		}
+		return compressedReader{Reader: decoder, closers: []func() error{closeDecoder, file.Close}}, nil
+	default:
+		return file, nil
+	}
+}
+
+// NewCompressedWriter wraps w according to the compression of the given
+// filename. The returned writer must be closed to flush all data, but does not
+// close w.
+func NewCompressedWriter(w io.Writer, filename string) (io.WriteCloser, error) {
+	switch CompressionFromFilename(filename) {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return newZstdWriter(w)
+	default:
+		return nopWriteCloser{Writer: w}, nil
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// WriteCompressedFile writes a (possibly compressed) file to a temporary
+// location and renames it over the existing one, so the file is never left
+// half-written. The permissions of an existing file are preserved.
+func WriteCompressedFile(filename string, write func(w io.Writer) error) error {
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(filename); err == nil {
+		mode = stat.Mode().Perm()
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(tempFile.Name()); err != nil && !os.IsNotExist(err) {
+			log.Error().
+				Err(err).
+				Str("filename", tempFile.Name()).
+				Msg("error removing temporary file")
+		}
+	}()
+
+	writer, err := NewCompressedWriter(tempFile, filename)
+	if err != nil {
+		CloseLogged(tempFile)
+		return err
+	}
+
+	if err := write(writer); err != nil {
+		CloseLogged(writer)
+		CloseLogged(tempFile)
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		CloseLogged(tempFile)
+		return err
+	}
+	if err := tempFile.Chmod(mode); err != nil {
+		CloseLogged(tempFile)
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), filename)
+}
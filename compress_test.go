@@ -0,0 +1,43 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionFromFilename(t *testing.T) {
+	require.Equal(t, main.CompressionGzip, main.CompressionFromFilename("scrobbles.csv.gz"))
+	require.Equal(t, main.CompressionZstd, main.CompressionFromFilename("scrobbles.csv.zst"))
+	require.Equal(t, main.CompressionNone, main.CompressionFromFilename("scrobbles.csv"))
+}
+
+func TestCompressedCSVSink(t *testing.T) {
+	for _, filename := range []string{"scrobbles.csv", "scrobbles.csv.gz", "scrobbles.csv.zst"} {
+		t.Run(filename, func(t *testing.T) {
+			if main.MinimalBuild && main.CompressionFromFilename(filename) == main.CompressionZstd {
+				t.Skip("zstd compression is not included in minimal builds")
+			}
+
+			sink := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), filename)}
+
+			require.NoError(t, sink.Scrobble(defaultScrobble))
+			require.NoError(t, sink.Scrobble(defaultScrobble))
+
+			scrobbles, err := sink.GetScrobbles(0, time.Time{}, time.Now())
+			require.NoError(t, err)
+			require.Len(t, scrobbles, 2)
+			require.Equal(t, defaultScrobble.Track, scrobbles[0].Track)
+
+			if main.CompressionFromFilename(filename) != main.CompressionNone {
+				contents, err := os.ReadFile(sink.Filename)
+				require.NoError(t, err)
+				require.NotContains(t, string(contents), defaultScrobble.Track)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !minimal
+
+package main
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// newZstdReader returns a decompressing reader and a function to release it.
+func newZstdReader(r io.Reader) (io.Reader, func() error, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeDecoder := func() error {
+		decoder.Close()
+		return nil
+	}
+	return decoder, closeDecoder, nil
+}
+
+func newZstdWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
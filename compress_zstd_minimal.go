@@ -0,0 +1,13 @@
+//go:build minimal
+
+package main
+
+import "io"
+
+func newZstdReader(_ io.Reader) (io.Reader, func() error, error) {
+	return nil, nil, MinimalBuildError{Feature: "zstd compression"}
+}
+
+func newZstdWriter(_ io.Writer) (io.WriteCloser, error) {
+	return nil, MinimalBuildError{Feature: "zstd compression"}
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/godbus/dbus/v5"
@@ -15,41 +21,165 @@ const DefaultConfigFileName = "config.toml"
 
 var DefaultConfig = Config{
 	PollRate:            2,
+	IdlePollRate:        DefaultIdlePollRate,
 	MinPlaybackDuration: 4 * 60,
 	MinPlaybackPercent:  50,
-	Blacklist:           []string{},
+	SinkTimeout:         DefaultSinkTimeout,
+	NowPlayingRefresh:   DefaultNowPlayingRefresh,
+	DedupeWindow:        DefaultDedupeWindow,
+	UndoWindow:          DefaultUndoWindow,
+	ScrobbleTimestamp:   ScrobbleTimestampStart,
+	UnknownDuration:     UnknownDurationIgnore,
+	Blacklist:           []FilterRule{},
+	Whitelist:           []FilterRule{},
 	Regexes:             []RegexReplace{},
+	PlayerGroups:        []PlayerGroup{},
+	PlayerPolicy:        PlayerPolicyAll,
+	PlayerPriority:      []string{},
+	Players:             map[string]PlayerConfig{},
+	Truncate:            nil,
+	NotifyTruncate:      nil,
+	InhibitIdle:         nil,
+	Resurface:           nil,
+	API:                 nil,
+	Events:              nil,
+	Cache:               &CacheConfig{TTL: DefaultCacheTTL, MaxSize: DefaultCacheMaxSize},
+	Plugins:             nil,
 	NotifyOnScrobble:    false,
 	NotifyOnError:       true,
+	HoldUntilClockSync:  false,
+	AuditLog:            true,
+	OfflineQueue:        true,
+	PlaybackJournal:     true,
+	WatchConfig:         false,
+	DBusService:         false,
+	Opener:              []string{},
+	LintIgnore:          []string{},
 	Sources: SourcesConfig{
-		DBus:         &DBusConfig{Address: ""},
-		MediaControl: &MediaControlConfig{Command: "media-control", Arguments: []string{"get", "--now"}},
+		DBus: &DBusConfig{Address: "", FilterOptions: FilterOptions{Regexes: nil, Blacklist: nil, Whitelist: nil}},
+		MediaControl: &MediaControlConfig{
+			Command:       "media-control",
+			Arguments:     []string{"get", "--now"},
+			FilterOptions: FilterOptions{Regexes: nil, Blacklist: nil, Whitelist: nil},
+		},
+		Webhook:     nil,
+		AppleScript: nil,
+		UPnP:        nil,
+		Roon:        nil,
+		Spotify:     nil,
 	},
 	Sinks: SinksConfig{
 		LastFm: map[string]LastFmConfig{"default": {
-			BaseURL:    lastfm.BaseURL,
-			Key:        "last.fm API key",
-			Secret:     "last.fm API secret",
-			SessionKey: "",
-			Username:   "",
+			BaseURL:       lastfm.BaseURL,
+			Key:           "last.fm API key",
+			Secret:        "last.fm API secret",
+			SessionKey:    "",
+			Username:      "",
+			KeyCmd:        nil,
+			SecretCmd:     nil,
+			SessionKeyCmd: nil,
+			SinkOptions: SinkOptions{
+				Template: nil,
+				Truncate: nil,
+				Retry:    nil,
+				Guest:    "",
+				DryRun:   false,
+				FilterOptions: FilterOptions{
+					Regexes:   nil,
+					Blacklist: nil,
+					Whitelist: nil,
+				},
+			},
 		}},
 		CSV: map[string]CSVConfig{"default": {
 			Filename: filepath.Join(os.Getenv("HOME"), "scrobbles.csv"),
+			Sign:     false,
+			Plays:    "",
+			SinkOptions: SinkOptions{
+				Template: nil,
+				Truncate: nil,
+				Retry:    nil,
+				Guest:    "",
+				DryRun:   false,
+				FilterOptions: FilterOptions{
+					Regexes:   nil,
+					Blacklist: nil,
+					Whitelist: nil,
+				},
+			},
 		}},
 	},
+	Users: map[string]UserConfig{},
+	Bans:  nil,
+
+	keyringSecrets: nil,
+	dropIns:        nil,
 }
 
 type Config struct {
-	PollRate            int            `toml:"poll_rate"`
-	MinPlaybackDuration int            `toml:"min_playback_duration"`
-	MinPlaybackPercent  int            `toml:"min_playback_percent"`
-	NotifyOnScrobble    bool           `toml:"notify_on_scrobble"`
-	NotifyOnError       bool           `toml:"notify_on_error"`
-	Blacklist           []string       `toml:"blacklist"`
-	Regexes             []RegexReplace `toml:"regexes"`
+	PollRate            int               `toml:"poll_rate"`
+	IdlePollRate        int               `toml:"idle_poll_rate"`
+	MinPlaybackDuration int               `toml:"min_playback_duration"`
+	MinPlaybackPercent  int               `toml:"min_playback_percent"`
+	SinkTimeout         int               `toml:"sink_timeout"`
+	NowPlayingRefresh   int               `toml:"now_playing_refresh"`
+	DedupeWindow        int               `toml:"dedupe_window"`
+	UndoWindow          int               `toml:"undo_window"`
+	ScrobbleTimestamp   ScrobbleTimestamp `toml:"scrobble_timestamp"`
+	UnknownDuration     UnknownDuration   `toml:"unknown_duration"`
+	NotifyOnScrobble    bool              `toml:"notify_on_scrobble"`
+	NotifyOnError       bool              `toml:"notify_on_error"`
+	HoldUntilClockSync  bool              `toml:"hold_until_clock_sync"`
+	AuditLog            bool              `toml:"audit_log"`
+	OfflineQueue        bool              `toml:"offline_queue"`
+	PlaybackJournal     bool              `toml:"playback_journal"`
+	WatchConfig         bool              `toml:"watch_config"`
+	DBusService         bool              `toml:"dbus_service"`
+	Opener              []string          `toml:"opener"`
+	LintIgnore          []string          `toml:"lint_ignore"`
+	Blacklist           []FilterRule      `toml:"blacklist"`
+	Whitelist           []FilterRule      `toml:"whitelist"`
+	Regexes             []RegexReplace    `toml:"regexes"`
+	PlayerGroups        []PlayerGroup     `toml:"player_groups"`
+	PlayerPolicy        PlayerPolicy      `toml:"player_policy"`
+	PlayerPriority      []string          `toml:"player_priority"`
+
+	// overrides of the minimum playback duration and percentage, keyed by
+	// player name, player identity (e.g., spotify), or source name
+	Players map[string]PlayerConfig `toml:"players"`
+
+	// default limits for all outputs, overridden by NotifyTruncate and
+	// the options of each sink
+	Truncate       *TruncateConfig `toml:"truncate"`
+	NotifyTruncate *TruncateConfig `toml:"notify_truncate"`
+
+	// inhibit screen locking and suspend while music plays, nil disables it
+	InhibitIdle *InhibitIdleConfig `toml:"inhibit_idle"`
+	// daily listen-again reminders, nil disables them
+	Resurface *ResurfaceConfig `toml:"resurface"`
+	// HTTP API for status and remote control, nil disables it
+	API *APIConfig `toml:"api"`
+	// event outputs for automations, nil disables them
+	Events *EventsConfig `toml:"events"`
+	// on-disk cache for read-only lookups, nil disables it
+	Cache *CacheConfig `toml:"cache"`
+
+	// WebAssembly modules that filter or transform every track, in order
+	Plugins []PluginConfig `toml:"plugins"`
 
 	Sources SourcesConfig `toml:"sources"`
 	Sinks   SinksConfig   `toml:"sinks"`
+
+	// additional users with their own sources and sinks, keyed by user name
+	Users map[string]UserConfig `toml:"users"`
+
+	// read from the ban list file next to the config file
+	Bans []Ban `toml:"-"`
+
+	// names of the secrets read from the system keyring and their account
+	keyringSecrets map[string]string
+	// drop-in files merged into the config file
+	dropIns []string
 }
 
 type RegexReplace struct {
@@ -60,9 +190,81 @@ type RegexReplace struct {
 	Album   bool   `toml:"album"`
 }
 
+type InhibitIdleConfig struct {
+	Players []string `toml:"players"`
+}
+
+type ResurfaceConfig struct {
+	Sink     string `toml:"sink"`
+	Days     int    `toml:"days"`
+	MinPlays int    `toml:"min_plays"`
+}
+
+type APIConfig struct {
+	Address  string   `toml:"address"`
+	Token    string   `toml:"token"`
+	TokenCmd []string `toml:"token_cmd"`
+}
+
+type PluginConfig struct {
+	// path to the .wasm file
+	Path string `toml:"path"`
+	// megabytes, defaults to DefaultPluginMemoryLimit
+	MemoryLimit int `toml:"memory_limit"`
+	// milliseconds per call, defaults to DefaultPluginTimeout
+	Timeout int `toml:"timeout"`
+}
+
+type EventsConfig struct {
+	// event types streamed by the HTTP API at /api/events
+	SSE     EventTypeFlags      `toml:"sse"`
+	Webhook *EventWebhookConfig `toml:"webhook"`
+	MQTT    *EventMQTTConfig    `toml:"mqtt"`
+}
+
+type EventWebhookConfig struct {
+	URL      string         `toml:"url"`
+	Token    string         `toml:"token"`
+	TokenCmd []string       `toml:"token_cmd"`
+	Types    EventTypeFlags `toml:"types"`
+}
+
+type EventMQTTConfig struct {
+	Broker      string         `toml:"broker"`
+	ClientID    string         `toml:"client_id"`
+	Username    string         `toml:"username"`
+	Password    string         `toml:"password"`
+	PasswordCmd []string       `toml:"password_cmd"`
+	Topic       string         `toml:"topic"`
+	Types       EventTypeFlags `toml:"types"`
+}
+
+type CacheConfig struct {
+	// seconds
+	TTL int `toml:"ttl"`
+	// megabytes
+	MaxSize int `toml:"max_size"`
+}
+
+type PlayerConfig struct {
+	MinPlaybackDuration int `toml:"min_playback_duration"`
+	MinPlaybackPercent  int `toml:"min_playback_percent"`
+}
+
+type PlayerGroup struct {
+	Name    string   `toml:"name"`
+	Players []string `toml:"players"`
+	Window  int      `toml:"window"`
+}
+
 type SourcesConfig struct {
 	DBus         *DBusConfig         `toml:"dbus"`
 	MediaControl *MediaControlConfig `toml:"media-control"`
+	Webhook      *WebhookConfig      `toml:"webhook"`
+	AppleScript  *AppleScriptConfig  `toml:"applescript"`
+	UPnP         *UPnPConfig         `toml:"upnp"`
+	Roon         *RoonConfig         `toml:"roon"`
+	Spotify      *SpotifyConfig      `toml:"spotify"`
 }
 
 type SinksConfig struct {
@@ -72,11 +274,51 @@ type SinksConfig struct {
 
 type DBusConfig struct {
 	Address string `toml:"address"`
+
+	FilterOptions
 }
 
 type MediaControlConfig struct {
 	Command   string   `toml:"command"`
 	Arguments []string `toml:"arguments"`
+
+	FilterOptions
+}
+
+type AppleScriptConfig struct {
+	Players []string `toml:"players"`
+
+	FilterOptions
+}
+
+type UPnPConfig struct {
+	Devices         []string `toml:"devices"`
+	Discover        bool     `toml:"discover"`
+	CallbackAddress string   `toml:"callback_address"`
+
+	FilterOptions
+}
+
+type RoonConfig struct {
+	Address string `toml:"address"`
+
+	FilterOptions
+}
+
+type SpotifyConfig struct {
+	// client ID of a Spotify app, the client secret is not required
+	ClientID string `toml:"client_id"`
+
+	FilterOptions
+}
+
+type WebhookConfig struct {
+	Address    string         `toml:"address"`
+	Token      string         `toml:"token"`
+	TokenCmd   []string       `toml:"token_cmd"`
+	Timestamps TimestampTrust `toml:"timestamps"`
+
+	FilterOptions
 }
 
 type LastFmConfig struct {
@@ -85,14 +327,67 @@ type LastFmConfig struct {
 	Secret     string `toml:"secret"`
 	SessionKey string `toml:"session_key"`
 	Username   string `toml:"username"`
+	// commands printing the secrets above, e.g. ["pass", "show", "lastfm/secret"]
+	KeyCmd        []string `toml:"key_cmd"`
+	SecretCmd     []string `toml:"secret_cmd"`
+	SessionKeyCmd []string `toml:"session_key_cmd"`
+
+	SinkOptions
 }
 
 type CSVConfig struct {
 	Filename string `toml:"filename"`
+	Sign     bool   `toml:"sign"`
+	Plays    string `toml:"plays"`
+
+	SinkOptions
+}
+
+// SinkOptions are shared by all sink types.
+type SinkOptions struct {
+	Template *TemplateConfig `toml:"template"`
+	Truncate *TruncateConfig `toml:"truncate"`
+	Retry    *RetryConfig    `toml:"retry"`
+	Guest    GuestRouting    `toml:"guest"`
+	// log submissions instead of sending them
+	DryRun bool `toml:"dry_run"`
+
+	FilterOptions
+}
+
+type RetryConfig struct {
+	MaxAttempts  int `toml:"max_attempts"`
+	InitialDelay int `toml:"initial_delay"`
+	MaxDelay     int `toml:"max_delay"`
+	MaxAge       int `toml:"max_age"`
+}
+
+type TruncateConfig struct {
+	Artist int `toml:"artist"`
+	Track  int `toml:"track"`
+	Album  int `toml:"album"`
+}
+
+type TemplateConfig struct {
+	Artist string `toml:"artist"`
+	Track  string `toml:"track"`
+	Album  string `toml:"album"`
 }
 
 func (c Config) SetupSources() []Source {
 	var sources []Source
+	add := func(source Source, options FilterOptions) {
+		wrapped, err := WrapSourceFilter(source, options)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("source", source.Name()).
+				Msg("error setting up source filter")
+			CloseSources([]Source{source})
+			return
+		}
+		sources = append(sources, wrapped)
+	}
 
 	if c.Sources.DBus != nil {
 		log.Debug().Msg("setting up dbus source")
@@ -113,16 +408,69 @@ func (c Config) SetupSources() []Source {
 				Str("address", c.Sources.DBus.Address).
 				Msg("failed to connect to bus")
 		} else {
-			sources = append(sources, DBusSource{Conn: conn})
+			add(DBusSource{Conn: conn}, c.Sources.DBus.FilterOptions)
 		}
 	}
 
 	if c.Sources.MediaControl != nil {
 		log.Debug().Msg("setting up media-control source")
-		sources = append(sources, MediaControlSource{
+		add(MediaControlSource{
 			Command:   c.Sources.MediaControl.Command,
 			Arguments: c.Sources.MediaControl.Arguments,
-		})
+		}, c.Sources.MediaControl.FilterOptions)
+	}
+
+	if c.Sources.AppleScript != nil {
+		log.Debug().Msg("setting up applescript source")
+		add(AppleScriptSource{Players: c.Sources.AppleScript.Players}, c.Sources.AppleScript.FilterOptions)
+	}
+
+	if c.Sources.UPnP != nil {
+		log.Debug().Msg("setting up UPnP source")
+
+		source, err := NewUPnPSource(*c.Sources.UPnP)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("address", c.Sources.UPnP.CallbackAddress).
+				Msg("failed to set up UPnP source")
+		} else {
+			add(source, c.Sources.UPnP.FilterOptions)
+		}
+	}
+
+	if c.Sources.Roon != nil {
+		log.Debug().
+			Str("address", c.Sources.Roon.Address).
+			Msg("setting up Roon source")
+		add(NewRoonSource(c.Sources.Roon.Address), c.Sources.Roon.FilterOptions)
+	}
+
+	if c.Sources.Spotify != nil {
+		log.Debug().Msg("setting up Spotify source")
+
+		source, err := NewSpotifySource(*c.Sources.Spotify, NewTokenStore(), SendNotification)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("failed to set up Spotify source")
+		} else {
+			add(source, c.Sources.Spotify.FilterOptions)
+		}
+	}
+
+	if c.Sources.Webhook != nil {
+		log.Debug().Msg("setting up webhook source")
+
+		source := NewWebhookSource(c.Sources.Webhook.Token, c.Sources.Webhook.Timestamps)
+		if err := source.Listen(c.Sources.Webhook.Address); err != nil {
+			log.Error().
+				Err(err).
+				Str("address", c.Sources.Webhook.Address).
+				Msg("failed to set up webhook source")
+		} else {
+			add(source, c.Sources.Webhook.FilterOptions)
+		}
 	}
 
 	if len(sources) == 0 {
@@ -131,30 +479,57 @@ func (c Config) SetupSources() []Source {
 		log.Debug().Msg("set up sources")
 	}
 
-	return sources
+	return WrapSourcesPlugins(sources, c.Plugins)
 }
 
 func (c Config) SetupSinks() []Sink {
 	var sinks []Sink
 
-	for _, sinkConfig := range c.Sinks.LastFm {
-		log.Debug().Msg("setting up last.fm sink")
+	lastFmHTTPClient := NewLastFmHTTPClient()
+	for key, sinkConfig := range c.Sinks.LastFm {
+		log.Debug().Str("key", key).Msg("setting up last.fm sink")
 
-		sink, err := LastFmSinkFromConfig(sinkConfig)
+		sink, err := LastFmSinkFromConfig(key, sinkConfig, lastFmHTTPClient)
 		if err != nil {
 			log.Error().
 				Err(err).
+				Str("key", key).
 				Msg("error setting up last.fm sink")
-		} else {
-			sinks = append(sinks, sink)
+			continue
+		}
+
+		wrapped, err := c.WrapSink(sink, sinkConfig.SinkOptions)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("key", key).
+				Msg("error setting up last.fm sink")
+			continue
 		}
+		sinks = append(sinks, wrapped)
 	}
 
-	for _, sinkConfig := range c.Sinks.CSV {
-		log.Debug().Msg("setting up CSV sink")
+	for key, sinkConfig := range c.Sinks.CSV {
+		log.Debug().Str("key", key).Msg("setting up CSV sink")
 
-		sink := CSVSinkFromConfig(sinkConfig)
-		sinks = append(sinks, sink)
+		sink, err := CSVSinkFromConfig(key, sinkConfig)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("key", key).
+				Msg("error setting up CSV sink")
+			continue
+		}
+
+		wrapped, err := c.WrapSink(sink, sinkConfig.SinkOptions)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("key", key).
+				Msg("error setting up CSV sink")
+			continue
+		}
+		sinks = append(sinks, wrapped)
 	}
 
 	if len(sinks) == 0 {
@@ -166,6 +541,53 @@ func (c Config) SetupSinks() []Sink {
 	return sinks
 }
 
+// WrapSink applies the options shared by all sink types.
+func (c Config) WrapSink(sink Sink, options SinkOptions) (Sink, error) {
+	wrapped, err := WrapSinkTemplate(sink, options.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped = WrapSinkTruncate(wrapped, c.TruncateRules(options.Truncate))
+	if options.DryRun {
+		// nothing is submitted, so there is nothing to retry, audit, or queue
+		return WrapSinkFilter(WrapSinkGuest(DryRunSink{Sink: wrapped}, options.Guest), options.FilterOptions)
+	}
+
+	wrapped = WrapSinkRetry(wrapped, options.Retry)
+	wrapped = WrapSinkGuest(wrapped, options.Guest)
+
+	if c.AuditLog {
+		wrapped = AuditSink{Sink: wrapped, Filename: AuditLogFilename()}
+	}
+
+	// filtered scrobbles are neither recorded in the audit log nor queued
+	if wrapped, err = WrapSinkFilter(wrapped, options.FilterOptions); err != nil {
+		return nil, err
+	}
+
+	// queued sinks are detected without unwrapping, so the queue has to stay
+	// the outermost decorator
+	if c.OfflineQueue {
+		wrapped = NewQueueSink(wrapped, ScrobbleQueue{Filename: QueueFilename()})
+	}
+
+	return wrapped, nil
+}
+
+// TruncateRules returns the given per-output override, falling back to the
+// global limits.
+func (c Config) TruncateRules(override *TruncateConfig) TruncateConfig {
+	switch {
+	case override != nil:
+		return *override
+	case c.Truncate != nil:
+		return *c.Truncate
+	default:
+		return TruncateConfig{Artist: 0, Track: 0, Album: 0}
+	}
+}
+
 func (c Config) ParseRegexes() []ParsedRegexReplace {
 	var parsed []ParsedRegexReplace
 
@@ -200,68 +622,289 @@ func ReadConfig(filename string) (Config, error) {
 
 	log.Debug().Msg("reading config")
 	var config Config
-	_, err := toml.DecodeFile(filename, &config)
-
-	switch {
-	case os.IsNotExist(err):
-		config = DefaultConfig
+	metadata, err := DecodeConfigFile(filename, &config)
 
+	if os.IsNotExist(err) {
 		log.Info().
 			Str("filename", filename).
 			Msg("creating default configuration file")
 		if err := DefaultConfig.Write(filename); err != nil {
 			return Config{}, err
 		}
-	case err != nil:
+
+		config = Config{}
+		metadata, err = DecodeConfigFile(filename, &config)
+	}
+	if err != nil {
+		if applied, _, _ := MigrateConfigFile(filename, "", true); len(applied) > 0 {
+			return Config{}, fmt.Errorf("%s (run `goscrobble config migrate` to upgrade the config file)", err.Error())
+		}
 		return Config{}, err
 	}
 
 	log.Debug().Msg("successfully read configuration")
 
-	config.Validate()
+	if err := config.ResolveSecretCommands(); err != nil {
+		return Config{}, err
+	}
+	if err := config.ResolveKeyringSecrets(KeyringGet); err != nil {
+		return Config{}, err
+	}
+
+	if config.Bans, err = ReadBanList(BanListFilename(filename)); err != nil {
+		return Config{}, fmt.Errorf("cannot read ban list: %s", err.Error())
+	}
+
+	config.fillDefaults(metadata)
+	for _, warning := range config.Validate() {
+		warning.Log()
+	}
+
+	if !config.NotifyOnError {
+		log.Warn().Msg("goscrobble will not send desktop notifications on failed scrobbles")
+	}
 
 	return config, nil
 }
 
-func (c *Config) Validate() {
+// ConfigWarning is a setting that Validate replaced or ignored.
+type ConfigWarning struct {
+	// the setting and its value, e.g. `poll_rate=0`, or empty if the warning is
+	// about the config as a whole
+	Subject string
+	Message string
+}
+
+func (w ConfigWarning) Log() {
+	event := log.Warn()
+	if w.Subject != "" {
+		event = event.Str("setting", w.Subject)
+	}
+	event.Msg(w.Message)
+}
+
+// configWarnings collects the warnings of Validate.
+type configWarnings []ConfigWarning
+
+func (w *configWarnings) add(subject, message string) {
+	*w = append(*w, ConfigWarning{Subject: subject, Message: message})
+}
+
+// fillDefaults sets the required settings that are left out of the config
+// file to their defaults, so Validate only warns about values that were set.
+func (c *Config) fillDefaults(metadata toml.MetaData) {
+	if !metadata.IsDefined("poll_rate") {
+		c.PollRate = DefaultConfig.PollRate
+	}
+	if !metadata.IsDefined("min_playback_duration") {
+		c.MinPlaybackDuration = DefaultConfig.MinPlaybackDuration
+	}
+	if !metadata.IsDefined("min_playback_percent") {
+		c.MinPlaybackPercent = DefaultConfig.MinPlaybackPercent
+	}
+}
+
+// Validate replaces invalid settings by their defaults and returns a warning
+// for each of them.
+func (c *Config) Validate() []ConfigWarning {
 	log.Debug().Msg("validating configuration")
 
+	var warnings configWarnings
+
 	if c.PollRate <= 0 || c.PollRate > 60 {
-		log.Warn().
-			Int("poll_rate", c.PollRate).
-			Msg("invalid poll rate, using default value")
+		warnings.add(fmt.Sprintf("poll_rate=%d", c.PollRate), "invalid poll rate, using default value")
 		c.PollRate = 2
 	}
+	if c.IdlePollRate < 0 || c.IdlePollRate > 10*60 {
+		warnings.add(fmt.Sprintf("idle_poll_rate=%d", c.IdlePollRate), "invalid idle poll rate, using default value")
+		c.IdlePollRate = DefaultIdlePollRate
+	}
 	if c.MinPlaybackDuration <= 0 || c.MinPlaybackDuration > 20*60 {
-		log.Warn().
-			Int("min_playback_duration", c.MinPlaybackDuration).
-			Msg("invalid minimum playback duration, using default value")
+		warnings.add(fmt.Sprintf("min_playback_duration=%d", c.MinPlaybackDuration), "invalid minimum playback duration, using default value")
 		// https://www.last.fm/api/scrobbling#when-is-a-scrobble-a-scrobble
 		c.MinPlaybackDuration = 4 * 60
 	}
 	if c.MinPlaybackPercent <= 0 || c.MinPlaybackPercent > 100 {
-		log.Warn().
-			Int("min_playback_percent", c.MinPlaybackPercent).
-			Msg("invalid minimum playback percentage, using default value")
+		warnings.add(fmt.Sprintf("min_playback_percent=%d", c.MinPlaybackPercent), "invalid minimum playback percentage, using default value")
 		c.MinPlaybackPercent = 50
 	}
 
-	if !c.NotifyOnError {
-		log.Warn().Msg("goscrobble will not send desktop notifications on failed scrobbles")
+	if c.SinkTimeout == 0 {
+		c.SinkTimeout = DefaultSinkTimeout
+	} else if c.SinkTimeout < 0 {
+		warnings.add(fmt.Sprintf("sink_timeout=%d", c.SinkTimeout), "invalid sink timeout, using default value")
+		c.SinkTimeout = DefaultSinkTimeout
+	}
+
+	if c.NowPlayingRefresh < 0 {
+		warnings.add(fmt.Sprintf("now_playing_refresh=%d", c.NowPlayingRefresh), "invalid now playing refresh interval, disabling it")
+		c.NowPlayingRefresh = 0
+	} else if c.NowPlayingRefresh > 0 && c.NowPlayingRefresh < MinNowPlayingRefresh {
+		warnings.add(fmt.Sprintf("now_playing_refresh=%d", c.NowPlayingRefresh), "now playing refresh interval is too short, using minimum value")
+		c.NowPlayingRefresh = MinNowPlayingRefresh
 	}
 
-	if c.Sources.MediaControl != nil && len(c.Sources.MediaControl.Arguments) == 0 {
-		log.Warn().Msg("no arguments for media-control specified, using `get --now`")
-		c.Sources.MediaControl.Arguments = []string{"get", "--now"}
+	if c.DedupeWindow < 0 {
+		warnings.add(fmt.Sprintf("dedupe_window=%d", c.DedupeWindow), "invalid duplicate suppression window, disabling it")
+		c.DedupeWindow = 0
+	}
+
+	if c.UndoWindow < 0 {
+		warnings.add(fmt.Sprintf("undo_window=%d", c.UndoWindow), "invalid undo window, using default value")
+	}
+	if c.UndoWindow <= 0 {
+		c.UndoWindow = DefaultUndoWindow
+	}
+
+	for key, player := range c.Players {
+		if player.MinPlaybackDuration < 0 || player.MinPlaybackDuration > 20*60 {
+			warnings.add(fmt.Sprintf("player=%s min_playback_duration=%d", key, player.MinPlaybackDuration), "invalid minimum playback duration for player, using global value")
+			player.MinPlaybackDuration = 0
+		}
+		if player.MinPlaybackPercent < 0 || player.MinPlaybackPercent > 100 {
+			warnings.add(fmt.Sprintf("player=%s min_playback_percent=%d", key, player.MinPlaybackPercent), "invalid minimum playback percentage for player, using global value")
+			player.MinPlaybackPercent = 0
+		}
+		c.Players[key] = player
+	}
+
+	switch c.PlayerPolicy {
+	case PlayerPolicyAll, PlayerPolicyRecent:
+	case "":
+		c.PlayerPolicy = PlayerPolicyAll
+	case PlayerPolicyPriority:
+		if len(c.PlayerPriority) == 0 {
+			warnings.add("", "no player priority specified, using `recent`")
+			c.PlayerPolicy = PlayerPolicyRecent
+		}
+	default:
+		warnings.add(fmt.Sprintf("player_policy=%s", c.PlayerPolicy), "invalid player policy, using `all`")
+		c.PlayerPolicy = PlayerPolicyAll
+	}
+
+	switch c.ScrobbleTimestamp {
+	case ScrobbleTimestampStart, ScrobbleTimestampThreshold:
+	case "":
+		c.ScrobbleTimestamp = ScrobbleTimestampStart
+	default:
+		warnings.add(fmt.Sprintf("scrobble_timestamp=%s", c.ScrobbleTimestamp), "invalid scrobble timestamp, using `start`")
+		c.ScrobbleTimestamp = ScrobbleTimestampStart
+	}
+
+	switch c.UnknownDuration {
+	case UnknownDurationIgnore, UnknownDurationMinDuration, UnknownDurationLookup:
+	case "":
+		c.UnknownDuration = UnknownDurationIgnore
+	default:
+		warnings.add(fmt.Sprintf("unknown_duration=%s", c.UnknownDuration), "invalid unknown duration policy, using `ignore`")
+		c.UnknownDuration = UnknownDurationIgnore
+	}
+
+	warnings = append(warnings, c.Sources.Validate()...)
+	warnings = append(warnings, c.validateUsers()...)
+
+	if c.API != nil && c.API.Address == "" {
+		warnings.add("", "no address for HTTP API specified, using `127.0.0.1:7636`")
+		c.API.Address = DefaultAPIAddress
+	}
+
+	if c.Events != nil {
+		warnings = append(warnings, c.Events.Validate()...)
+	}
+
+	warnings = append(warnings, c.DisableExcluded()...)
+
+	if c.Cache != nil && c.Cache.TTL <= 0 {
+		warnings.add(fmt.Sprintf("ttl=%d", c.Cache.TTL), "invalid cache TTL, using default value")
+		c.Cache.TTL = DefaultCacheTTL
+	}
+	if c.Cache != nil && c.Cache.MaxSize < 0 {
+		warnings.add(fmt.Sprintf("max_size=%d", c.Cache.MaxSize), "invalid cache size, using default value")
+		c.Cache.MaxSize = DefaultCacheMaxSize
+	}
+
+	if c.Resurface != nil && c.Resurface.Sink == "" {
+		warnings.add("resurface.sink=", "no sink for listen-again reminders specified, using `csv`")
+		c.Resurface.Sink = "csv"
+	}
+	if c.Resurface != nil && !IsLocalSink(c.Resurface.Sink) {
+		warnings.add(
+			fmt.Sprintf("resurface.sink=%s", c.Resurface.Sink),
+			"listen-again reminders read the whole history of their sink, which is only supported for CSV sinks, disabling them",
+		)
+		c.Resurface = nil
+	}
+	if c.Resurface != nil && c.Resurface.Days <= 0 {
+		c.Resurface.Days = int(DefaultResurfaceAge.Hours() / 24)
+	}
+	if c.Resurface != nil && c.Resurface.MinPlays <= 0 {
+		c.Resurface.MinPlays = DefaultResurfaceMinPlays
 	}
 
 	log.Debug().Msg("validated configuration")
+	return warnings
+}
+
+// Validate fixes invalid source options, both of the main profile and of user
+// profiles.
+func (s *SourcesConfig) Validate() []ConfigWarning {
+	var warnings configWarnings
+
+	if s.MediaControl != nil && len(s.MediaControl.Arguments) == 0 {
+		warnings.add("", "no arguments for media-control specified, using `get --now`")
+		s.MediaControl.Arguments = []string{"get", "--now"}
+	}
+
+	if s.AppleScript != nil && runtime.GOOS != "darwin" {
+		warnings.add("", "applescript source is only supported on macOS, disabling it")
+		s.AppleScript = nil
+	}
+	if s.AppleScript != nil {
+		if len(s.AppleScript.Players) == 0 {
+			warnings.add("", "no players for applescript source specified, using all supported players")
+			s.AppleScript.Players = slices.Sorted(maps.Keys(AppleScriptPlayers))
+		}
+		for _, player := range s.AppleScript.Players {
+			if _, ok := AppleScriptPlayers[player]; !ok {
+				warnings.add(fmt.Sprintf("player=%s", player), "player is not supported by the applescript source")
+			}
+		}
+	}
+
+	if s.UPnP != nil && len(s.UPnP.Devices) == 0 && !s.UPnP.Discover {
+		warnings.add("", "no UPnP devices configured and discovery is disabled, enabling discovery")
+		s.UPnP.Discover = true
+	}
+
+	if s.Roon != nil && s.Roon.Address != "" && !strings.Contains(s.Roon.Address, ":") {
+		warnings.add("", "no port for Roon core specified, using 9330")
+		s.Roon.Address += ":9330"
+	}
+
+	if s.Webhook != nil && s.Webhook.Address == "" {
+		warnings.add("", "no address for webhook source specified, using `127.0.0.1:7635`")
+		s.Webhook.Address = "127.0.0.1:7635"
+	}
+	if s.Webhook != nil {
+		switch s.Webhook.Timestamps {
+		case TimestampTrustDaemon, TimestampTrustSource:
+		case "":
+			s.Webhook.Timestamps = TimestampTrustDaemon
+		default:
+			warnings.add(fmt.Sprintf("timestamps=%s", s.Webhook.Timestamps), "invalid timestamp trust for webhook source, using `daemon`")
+			s.Webhook.Timestamps = TimestampTrustDaemon
+		}
+	}
+	return warnings
 }
 
 func (c Config) Write(filename string) error {
 	log.Debug().
 		Str("filename", filename).
 		Msg("writing config file")
+	if len(c.dropIns) > 0 {
+		return c.writeOwnSettings(filename)
+	}
 
 	//nolint:gosec
 	file, err := os.Create(filename)
@@ -269,12 +912,26 @@ func (c Config) Write(filename string) error {
 		return err
 	}
 
-	encoder := toml.NewEncoder(file)
+	return c.persisted().Encode(file)
+}
+
+// Encode writes the config as TOML, in the same format as Write.
+func (c Config) Encode(w io.Writer) error {
+	encoder := toml.NewEncoder(w)
 	encoder.Indent = ""
 
 	return encoder.Encode(c)
 }
 
+func StateDir() string {
+	// https://specifications.freedesktop.org/basedir-spec/latest/
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome != "" {
+		return filepath.Join(stateHome, "goscrobble")
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "state", "goscrobble")
+}
+
 func ConfigDir() string {
 	// https://specifications.freedesktop.org/basedir-spec/latest/
 	configHome := os.Getenv("XDG_CONFIG_HOME")
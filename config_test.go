@@ -52,13 +52,23 @@ func TestConfigValidate(t *testing.T) {
 		PollRate:            -20,
 		MinPlaybackDuration: -20,
 		MinPlaybackPercent:  200,
+		ScrobbleTimestamp:   "end",
+		UnknownDuration:     "guess",
 		// ...
 	}
-	invalidConfig.Validate()
+	require.Equal(t, []main.ConfigWarning{
+		{Subject: "poll_rate=-20", Message: "invalid poll rate, using default value"},
+		{Subject: "min_playback_duration=-20", Message: "invalid minimum playback duration, using default value"},
+		{Subject: "min_playback_percent=200", Message: "invalid minimum playback percentage, using default value"},
+		{Subject: "scrobble_timestamp=end", Message: "invalid scrobble timestamp, using `start`"},
+		{Subject: "unknown_duration=guess", Message: "invalid unknown duration policy, using `ignore`"},
+	}, invalidConfig.Validate())
 
 	require.Equal(t, 2, invalidConfig.PollRate)
 	require.Equal(t, 4*60, invalidConfig.MinPlaybackDuration)
 	require.Equal(t, 50, invalidConfig.MinPlaybackPercent)
+	require.Equal(t, main.ScrobbleTimestampStart, invalidConfig.ScrobbleTimestamp)
+	require.Equal(t, main.UnknownDurationIgnore, invalidConfig.UnknownDuration)
 }
 
 func TestConfigWrite(t *testing.T) {
@@ -92,3 +102,18 @@ func TestConfigDir(t *testing.T) {
 		require.Equal(t, "/home/user/.config/goscrobble", configDir)
 	})
 }
+
+func TestStateDir(t *testing.T) {
+	t.Run("$XDG_STATE_HOME", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		t.Setenv("XDG_STATE_HOME", "/home/user/my-state-dir")
+		stateDir := main.StateDir()
+		require.Equal(t, "/home/user/my-state-dir/goscrobble", stateDir)
+	})
+	t.Run("$HOME", func(t *testing.T) {
+		t.Setenv("HOME", "/home/user")
+		t.Setenv("XDG_STATE_HOME", "")
+		stateDir := main.StateDir()
+		require.Equal(t, "/home/user/.local/state/goscrobble", stateDir)
+	})
+}
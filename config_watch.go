@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// editors often write a file in several steps, so changes are only reported
+// once the file was not modified for this long
+const configWatchDebounce = 500 * time.Millisecond
+
+// ConfigWatcher reports changes to the configuration file and its drop-ins on
+// Changes. The directories are watched instead of the files themselves, since
+// many editors replace the file when saving.
+type ConfigWatcher struct {
+	Changes chan struct{}
+
+	watcher *fsnotify.Watcher
+}
+
+func WatchConfig(filename string) (*ConfigWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := watcher.Add(filepath.Dir(filename)); err != nil {
+		CloseLogged(watcher)
+		return nil, err
+	}
+	// the drop-in directory is added once it is created
+	if err := watcher.Add(ConfigDropInDir(filename)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		CloseLogged(watcher)
+		return nil, err
+	}
+
+	log.Debug().
+		Str("filename", filename).
+		Msg("watching configuration file for changes")
+
+	w := &ConfigWatcher{
+		Changes: make(chan struct{}, 1),
+		watcher: watcher,
+	}
+	go w.run(filepath.Clean(filename))
+
+	return w, nil
+}
+
+func (w *ConfigWatcher) run(filename string) {
+	var debounce <-chan time.Time
+	dropInDir := ConfigDropInDir(filename)
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			name := filepath.Clean(event.Name)
+			switch {
+			case name == filename && event.Has(fsnotify.Write|fsnotify.Create):
+			case name == dropInDir && event.Has(fsnotify.Create):
+				if err := w.watcher.Add(dropInDir); err != nil {
+					log.Error().
+						Err(err).
+						Msg("error watching drop-in directory")
+				}
+			case filepath.Dir(name) == dropInDir && filepath.Ext(name) == ".toml":
+				// removing or renaming a drop-in changes the configuration
+			default:
+				continue
+			}
+			debounce = time.After(configWatchDebounce)
+		case <-debounce:
+			debounce = nil
+			// a pending change is enough, the file is read again anyway
+			select {
+			case w.Changes <- struct{}{}:
+			default:
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Error().
+				Err(err).
+				Msg("error watching configuration file")
+		}
+	}
+}
+
+func (w *ConfigWatcher) Close() error {
+	return w.watcher.Close()
+}
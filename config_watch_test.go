@@ -0,0 +1,80 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchConfig(t *testing.T) {
+	directory := t.TempDir()
+	filename := filepath.Join(directory, main.DefaultConfigFileName)
+	require.NoError(t, os.WriteFile(filename, []byte("poll_rate = 2\n"), 0600))
+
+	watcher, err := main.WatchConfig(filename)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, watcher.Close()) }()
+
+	t.Run("unrelated file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(directory, "other.toml"), []byte{}, 0600))
+
+		select {
+		case <-watcher.Changes:
+			require.FailNow(t, "unexpected change")
+		case <-time.After(time.Second):
+		}
+	})
+
+	t.Run("replaced file", func(t *testing.T) {
+		temporary := filepath.Join(directory, "config.toml.tmp")
+		require.NoError(t, os.WriteFile(temporary, []byte("poll_rate = 5\n"), 0600))
+		require.NoError(t, os.Rename(temporary, filename))
+
+		select {
+		case <-watcher.Changes:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "change was not reported")
+		}
+	})
+
+	t.Run("modified file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filename, []byte("poll_rate = 3\n"), 0600))
+		require.NoError(t, os.WriteFile(filename, []byte("poll_rate = 4\n"), 0600))
+
+		select {
+		case <-watcher.Changes:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "change was not reported")
+		}
+
+		// both writes are reported as a single change
+		select {
+		case <-watcher.Changes:
+			require.FailNow(t, "unexpected change")
+		case <-time.After(time.Second):
+		}
+	})
+
+	t.Run("drop-in file", func(t *testing.T) {
+		directory := main.ConfigDropInDir(filename)
+		require.NoError(t, os.Mkdir(directory, 0700))
+
+		select {
+		case <-watcher.Changes:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "new drop-in directory was not reported")
+		}
+
+		require.NoError(t, os.WriteFile(filepath.Join(directory, "10-regexes.toml"), []byte{}, 0600))
+
+		select {
+		case <-watcher.Changes:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "change was not reported")
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	// settings that are invalid and replaced during validation
+	CheckInvalidValue = "invalid-value"
+	// settings that goscrobble does not know, e.g. because of a typo
+	CheckUnknownKey = "unknown-key"
+	// config files that cannot be parsed
+	CheckSyntax = "syntax"
+)
+
+type FindingSeverity string
+
+const (
+	FindingError   FindingSeverity = "error"
+	FindingWarning FindingSeverity = "warning"
+)
+
+// ConfigFinding is a problem found by `goscrobble check-config`.
+type ConfigFinding struct {
+	Severity FindingSeverity `json:"severity"`
+	Rule     string          `json:"rule"`
+	Subject  string          `json:"subject"`
+	Message  string          `json:"message"`
+}
+
+func (f ConfigFinding) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s", f.Severity, f.Rule, f.Subject, f.Message)
+}
+
+// CheckConfigFile reads a config file and returns the settings that are
+// invalid or unknown, followed by the warnings of Lint. Invalid settings are
+// replaced by their defaults and unknown ones are ignored while running, so
+// they are only warnings, unless strict is set. Findings whose rule is in
+// `lint_ignore` are left out.
+func CheckConfigFile(filename string, strict, keyring bool) []ConfigFinding {
+	var config Config
+	metadata, err := DecodeConfigFile(filename, &config)
+	if err != nil {
+		return []ConfigFinding{{Severity: FindingError, Rule: CheckSyntax, Subject: filename, Message: err.Error()}}
+	}
+
+	severity := FindingWarning
+	if strict {
+		severity = FindingError
+	}
+
+	findings := []ConfigFinding{}
+	add := func(severity FindingSeverity, rule, subject, message string) {
+		if slices.Contains(config.LintIgnore, rule) {
+			return
+		}
+		findings = append(findings, ConfigFinding{Severity: severity, Rule: rule, Subject: subject, Message: message})
+	}
+
+	for _, key := range metadata.Undecoded() {
+		add(severity, CheckUnknownKey, key.String(), "unknown setting, it is ignored")
+	}
+	config.fillDefaults(metadata)
+	for _, warning := range config.Validate() {
+		subject := warning.Subject
+		if subject == "" {
+			subject = "config"
+		}
+		add(severity, CheckInvalidValue, subject, warning.Message)
+	}
+	for _, warning := range config.Lint(keyring) {
+		add(FindingWarning, warning.Rule, warning.Subject, warning.Message)
+	}
+
+	return findings
+}
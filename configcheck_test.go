@@ -0,0 +1,47 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckConfigFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), main.DefaultConfigFileName)
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0600))
+	}
+
+	write("poll_rate = 2\nmin_playback_duration = 240\nmin_playback_percent = 50\n")
+	require.Empty(t, main.CheckConfigFile(filename, true, false))
+
+	// left out settings use their defaults
+	write("idle_poll_rate = 30\n")
+	require.Empty(t, main.CheckConfigFile(filename, true, false))
+
+	write("poll_rate = 0\npol_rate = 3\nmin_playback_duration = 240\nmin_playback_percent = 50\n")
+	require.Equal(t, []main.ConfigFinding{
+		{Severity: main.FindingWarning, Rule: main.CheckUnknownKey, Subject: "pol_rate", Message: "unknown setting, it is ignored"},
+		{Severity: main.FindingWarning, Rule: main.CheckInvalidValue, Subject: "poll_rate=0", Message: "invalid poll rate, using default value"},
+	}, main.CheckConfigFile(filename, false, false))
+
+	findings := main.CheckConfigFile(filename, true, false)
+	require.Len(t, findings, 2)
+	for _, finding := range findings {
+		require.Equal(t, main.FindingError, finding.Severity)
+	}
+
+	write("poll_rate = 0\npol_rate = 3\nmin_playback_duration = 240\nmin_playback_percent = 50\nlint_ignore = [\"unknown-key\"]\n")
+	findings = main.CheckConfigFile(filename, true, false)
+	require.Len(t, findings, 1)
+	require.Equal(t, main.CheckInvalidValue, findings[0].Rule)
+
+	write("poll_rate = \n")
+	findings = main.CheckConfigFile(filename, false, false)
+	require.Len(t, findings, 1)
+	require.Equal(t, main.FindingError, findings[0].Severity)
+	require.Equal(t, main.CheckSyntax, findings[0].Rule)
+}
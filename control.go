@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ControlSocketFileName = "goscrobble.sock"
+	controlTimeout        = 5 * time.Second
+)
+
+// ControlRequest is sent by goscrobble commands to a running daemon as a single
+// line of JSON.
+type ControlRequest struct {
+	Command   string   `json:"command"`
+	Arguments []string `json:"arguments,omitempty"`
+	// submitted by the `scrobble` command
+	Scrobble *Scrobble `json:"scrobble,omitempty"`
+}
+
+type ControlResponse struct {
+	Error      string                    `json:"error,omitempty"`
+	Message    string                    `json:"message,omitempty"`
+	Status     *DaemonStatus             `json:"status,omitempty"`
+	NowPlaying map[string]PlaybackStatus `json:"now_playing,omitempty"`
+	Scrobbles  []Scrobble                `json:"scrobbles,omitempty"`
+	// queued scrobbles by sink name
+	Queue  map[string][]Scrobble `json:"queue,omitempty"`
+	Health *HealthReport         `json:"health,omitempty"`
+}
+
+type ControlHandler func(ControlRequest) ControlResponse
+
+// ControlMessage returns a successful response with a message for the user.
+func ControlMessage(message string) ControlResponse {
+	return ControlResponse{
+		Error:      "",
+		Message:    message,
+		Status:     nil,
+		NowPlaying: nil,
+		Scrobbles:  nil,
+		Queue:      nil,
+		Health:     nil,
+	}
+}
+
+func ControlError(message string) ControlResponse {
+	return ControlResponse{
+		Error:      message,
+		Message:    "",
+		Status:     nil,
+		NowPlaying: nil,
+		Scrobbles:  nil,
+		Queue:      nil,
+		Health:     nil,
+	}
+}
+
+// DaemonStatus is a summary of the runtime state of the daemon.
+type DaemonStatus struct {
+	Started        time.Time `json:"started"`
+	TracksSeen     int       `json:"tracks_seen"`
+	ScrobblesToday int       `json:"scrobbles_today"`
+	QueueDepth     int       `json:"queue_depth"`
+	Paused         bool      `json:"paused"`
+	// zero if the pause does not end automatically
+	PausedUntil time.Time `json:"paused_until,omitzero"`
+	Guest       bool      `json:"guest"`
+	// players with a track and the time until it is scrobbled
+	Players []PlayerProgress `json:"players,omitempty"`
+	// last scrobble saved by each sink
+	LastScrobbles map[string]Scrobble `json:"last_scrobbles,omitempty"`
+	Errors        []RecentError       `json:"errors,omitempty"`
+}
+
+func (s DaemonStatus) Summary(now time.Time) string {
+	summary := fmt.Sprintf(
+		"daemon running for %s, %d tracks seen, %d scrobbles today, %d queued",
+		now.Sub(s.Started).Truncate(time.Second),
+		s.TracksSeen,
+		s.ScrobblesToday,
+		s.QueueDepth,
+	)
+	if s.Paused && !s.PausedUntil.IsZero() {
+		summary += fmt.Sprintf(" (scrobbling paused for %s)", s.PausedUntil.Sub(now).Truncate(time.Second))
+	} else if s.Paused {
+		summary += " (scrobbling paused)"
+	}
+	if s.Guest {
+		summary += " (guest mode)"
+	}
+	return summary
+}
+
+// ControlSocketFilename returns the path of the control socket, preferring
+// the per-user runtime directory.
+func ControlSocketFilename() string {
+	// https://specifications.freedesktop.org/basedir-spec/latest/
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir != "" {
+		return filepath.Join(runtimeDir, ControlSocketFileName)
+	}
+	return filepath.Join(StateDir(), ControlSocketFileName)
+}
+
+// ControlServer answers requests on a unix socket while the daemon is running.
+type ControlServer struct {
+	mutex    sync.Mutex
+	handlers map[string]ControlHandler
+	// streamed by the `watch` command, nil if not available
+	events *EventBus
+	// set by Listen, nil once closed
+	listener net.Listener
+	conns    map[net.Conn]bool
+	// the accepting and serving goroutines
+	group sync.WaitGroup
+}
+
+func NewControlServer() *ControlServer {
+	return &ControlServer{
+		mutex:    sync.Mutex{},
+		handlers: map[string]ControlHandler{},
+		events:   nil,
+		listener: nil,
+		conns:    map[net.Conn]bool{},
+		group:    sync.WaitGroup{},
+	}
+}
+
+// StreamEvents enables the `watch` command, which streams the events of the
+// bus until the client disconnects.
+func (s *ControlServer) StreamEvents(events *EventBus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.events = events
+}
+
+func (s *ControlServer) Handle(command string, handler ControlHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.handlers[command] = handler
+}
+
+// Listen starts serving requests in the background. A stale socket left by a
+// crashed daemon is removed, but an active one is never taken over.
+func (s *ControlServer) Listen(filename string) error {
+	if _, err := os.Stat(filename); err == nil {
+		if conn, err := net.DialTimeout("unix", filename, controlTimeout); err == nil {
+			CloseLogged(conn)
+			return errors.New("another daemon is already running")
+		}
+		if err := os.Remove(filename); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("unix", filename)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(filename, 0600); err != nil {
+		CloseLogged(listener)
+		return err
+	}
+
+	log.Debug().
+		Str("filename", filename).
+		Msg("listening on control socket")
+
+	s.mutex.Lock()
+	s.listener = listener
+	s.mutex.Unlock()
+
+	s.group.Add(1)
+	go func() {
+		defer s.group.Done()
+
+		for {
+			conn, err := listener.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if err != nil {
+				log.Error().
+					Err(err).
+					Msg("error accepting control connection")
+				return
+			}
+
+			s.mutex.Lock()
+			if s.listener == nil {
+				s.mutex.Unlock()
+				CloseLogged(conn)
+				return
+			}
+			s.conns[conn] = true
+			s.mutex.Unlock()
+
+			s.group.Add(1)
+			go func() {
+				defer s.group.Done()
+				s.serve(conn)
+
+				// connections are closed only once, either here or by Close
+				s.mutex.Lock()
+				defer s.mutex.Unlock()
+				if s.conns[conn] {
+					delete(s.conns, conn)
+					CloseLogged(conn)
+				}
+			}()
+		}
+	}()
+
+	return nil
+}
+
+// Close stops listening and closes open connections (e.g., of `watch`). It
+// returns once no request is served anymore.
+func (s *ControlServer) Close() error {
+	s.mutex.Lock()
+	listener := s.listener
+	s.listener = nil
+	for conn := range s.conns {
+		delete(s.conns, conn)
+		CloseLogged(conn)
+	}
+	s.mutex.Unlock()
+
+	var err error
+	if listener != nil {
+		err = listener.Close()
+	}
+	s.group.Wait()
+	return err
+}
+
+// Dispatch runs the handler of a request, so other interfaces (e.g., D-Bus)
+// can share the handlers of the control socket.
+func (s *ControlServer) Dispatch(request ControlRequest) ControlResponse {
+	s.mutex.Lock()
+	handler, ok := s.handlers[request.Command]
+	s.mutex.Unlock()
+
+	if !ok {
+		return ControlError(fmt.Sprintf("unknown command: %s", request.Command))
+	}
+	return handler(request)
+}
+
+func (s *ControlServer) serve(conn net.Conn) {
+	if err := conn.SetDeadline(time.Now().Add(controlTimeout)); err != nil {
+		return
+	}
+
+	var request ControlRequest
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("invalid control request")
+		return
+	}
+
+	if request.Command == "watch" {
+		s.watch(conn, request)
+		return
+	}
+
+	if err := json.NewEncoder(conn).Encode(s.Dispatch(request)); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("error writing control response")
+	}
+}
+
+// SendControlRequest sends a request to a running daemon. It fails quickly if
+// no daemon is listening.
+func SendControlRequest(filename string, request ControlRequest) (ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", filename, controlTimeout)
+	if err != nil {
+		return ControlResponse{}, err
+	}
+	defer CloseLogged(conn)
+
+	if err := conn.SetDeadline(time.Now().Add(controlTimeout)); err != nil {
+		return ControlResponse{}, err
+	}
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return ControlResponse{}, err
+	}
+
+	var response ControlResponse
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&response); err != nil {
+		return ControlResponse{}, err
+	}
+	if response.Error != "" {
+		return response, errors.New(response.Error)
+	}
+
+	return response, nil
+}
+
+// QueryDaemonStatus returns the status of a running daemon, if any.
+func QueryDaemonStatus() (DaemonStatus, bool) {
+	response, err := SendControlRequest(ControlSocketFilename(), ControlRequest{Command: "status", Arguments: nil, Scrobble: nil})
+	if err != nil || response.Status == nil {
+		return DaemonStatus{}, false
+	}
+	return *response.Status, true
+}
@@ -0,0 +1,69 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlServer(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), main.ControlSocketFileName)
+
+	started := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	status := main.DaemonStatus{Started: started, TracksSeen: 3, ScrobblesToday: 2, QueueDepth: 1}
+
+	server := main.NewControlServer()
+	server.Handle("status", func(main.ControlRequest) main.ControlResponse {
+		return main.ControlResponse{Error: "", Status: &status}
+	})
+	require.NoError(t, server.Listen(filename))
+
+	response, err := main.SendControlRequest(filename, main.ControlRequest{Command: "status"})
+	require.NoError(t, err)
+	require.NotNil(t, response.Status)
+	require.True(t, started.Equal(response.Status.Started))
+	require.Equal(t, 3, response.Status.TracksSeen)
+
+	_, err = main.SendControlRequest(filename, main.ControlRequest{Command: "invalid"})
+	require.Error(t, err)
+
+	require.Error(t, main.NewControlServer().Listen(filename))
+
+	// closed servers stop listening
+	require.NoError(t, server.Close())
+	_, err = main.SendControlRequest(filename, main.ControlRequest{Command: "status"})
+	require.Error(t, err)
+
+	require.Equal(t,
+		"daemon running for 1h30m0s, 3 tracks seen, 2 scrobbles today, 1 queued",
+		status.Summary(started.Add(90*time.Minute)),
+	)
+
+	status.Paused = true
+	require.Equal(t,
+		"daemon running for 1h30m0s, 3 tracks seen, 2 scrobbles today, 1 queued (scrobbling paused)",
+		status.Summary(started.Add(90*time.Minute)),
+	)
+
+	status.PausedUntil = started.Add(2 * time.Hour)
+	require.Equal(t,
+		"daemon running for 1h30m0s, 3 tracks seen, 2 scrobbles today, 1 queued (scrobbling paused for 30m0s)",
+		status.Summary(started.Add(90*time.Minute)),
+	)
+}
+
+func TestLoopStats(t *testing.T) {
+	var stats main.LoopStats
+
+	day := time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	stats.CountScrobble(day)
+	stats.CountScrobble(day.Add(time.Hour))
+	require.Equal(t, 2, stats.ScrobblesOn(day))
+	require.Equal(t, 0, stats.ScrobblesOn(day.Add(24*time.Hour)))
+
+	stats.CountScrobble(day.Add(24 * time.Hour))
+	require.Equal(t, 1, stats.ScrobblesOn(day.Add(24*time.Hour)))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"math"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DBusServiceName      = "org.goscrobble.Daemon"
+	DBusServicePath      = dbus.ObjectPath("/org/goscrobble/Daemon")
+	DBusServiceInterface = "org.goscrobble.Daemon"
+)
+
+// DaemonService exports the state of the daemon on the session bus, so status
+// bar widgets can display it. Methods are handled by the control socket
+// handlers. A nil service does nothing.
+type DaemonService struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+}
+
+// DaemonServiceState is published as properties of the D-Bus service.
+type DaemonServiceState struct {
+	CurrentTrack string
+	LastScrobble string
+	QueueDepth   int
+	Paused       bool
+}
+
+type daemonServiceMethods struct {
+	control *ControlServer
+}
+
+func (m daemonServiceMethods) Pause() *dbus.Error {
+	return m.dispatch("pause")
+}
+
+func (m daemonServiceMethods) Resume() *dbus.Error {
+	return m.dispatch("resume")
+}
+
+func (m daemonServiceMethods) SkipCurrent() *dbus.Error {
+	return m.dispatch("skip")
+}
+
+func (m daemonServiceMethods) dispatch(command string) *dbus.Error {
+	response := m.control.Dispatch(ControlRequest{Command: command, Arguments: nil, Scrobble: nil})
+	if response.Error != "" {
+		return dbus.MakeFailedError(errors.New(response.Error))
+	}
+	return nil
+}
+
+func NewDaemonService(control *ControlServer) (*DaemonService, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := exportDaemonService(conn, control)
+	if err != nil {
+		CloseLogged(conn)
+		return nil, err
+	}
+
+	log.Debug().
+		Str("name", DBusServiceName).
+		Msg("registered dbus service")
+
+	return service, nil
+}
+
+func exportDaemonService(conn *dbus.Conn, control *ControlServer) (*DaemonService, error) {
+	reply, err := conn.RequestName(DBusServiceName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return nil, err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return nil, errors.New("another daemon already owns " + DBusServiceName)
+	}
+
+	methods := daemonServiceMethods{control: control}
+	if err := conn.Export(methods, DBusServicePath, DBusServiceInterface); err != nil {
+		return nil, err
+	}
+
+	props, err := prop.Export(conn, DBusServicePath, prop.Map{
+		DBusServiceInterface: {
+			"CurrentTrack": {Value: "", Writable: false, Emit: prop.EmitTrue, Callback: nil},
+			"LastScrobble": {Value: "", Writable: false, Emit: prop.EmitTrue, Callback: nil},
+			"QueueDepth":   {Value: int32(0), Writable: false, Emit: prop.EmitTrue, Callback: nil},
+			"Paused":       {Value: false, Writable: false, Emit: prop.EmitTrue, Callback: nil},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	node := &introspect.Node{
+		XMLName: xml.Name{},
+		Name:    string(DBusServicePath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:        DBusServiceInterface,
+				Methods:     introspect.Methods(methods),
+				Signals:     nil,
+				Properties:  props.Introspection(DBusServiceInterface),
+				Annotations: nil,
+			},
+		},
+		Children: nil,
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), DBusServicePath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return nil, err
+	}
+
+	return &DaemonService{conn: conn, props: props}, nil
+}
+
+// Update publishes the given state. PropertiesChanged is only emitted for
+// values that changed.
+func (s *DaemonService) Update(state DaemonServiceState) {
+	if s == nil {
+		return
+	}
+
+	s.set("CurrentTrack", state.CurrentTrack)
+	s.set("LastScrobble", state.LastScrobble)
+	s.set("QueueDepth", int32(min(state.QueueDepth, math.MaxInt32)))
+	s.set("Paused", state.Paused)
+}
+
+func (s *DaemonService) set(property string, value any) {
+	if s.props.GetMust(DBusServiceInterface, property) == value {
+		return
+	}
+
+	// SetMust panics if emitting PropertiesChanged fails (e.g., after the
+	// connection to the bus was lost), which must not stop the daemon
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().
+				Interface("error", r).
+				Str("property", property).
+				Msg("error updating dbus property")
+		}
+	}()
+	s.props.SetMust(DBusServiceInterface, property, value)
+}
+
+func (s *DaemonService) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// NewDaemonServiceState summarizes the loop state for the D-Bus service.
+// Tracks are formatted as `<artists> — <track>`, or empty if there is none.
+func NewDaemonServiceState(state *LoopState, queueDepth int, paused bool) DaemonServiceState {
+	current := ""
+	if _, status, ok := KioskPlayer(state.CurrentlyPlaying); ok && status.State == PlaybackPlaying {
+		current = status.JoinArtists() + " " + string(RuneEmDash) + " " + status.Track
+	}
+
+	last := ""
+	if state.LastScrobble.IsValid() {
+		last = state.LastScrobble.JoinArtists() + " " + string(RuneEmDash) + " " + state.LastScrobble.Track
+	}
+
+	return DaemonServiceState{
+		CurrentTrack: current,
+		LastScrobble: last,
+		QueueDepth:   queueDepth,
+		Paused:       paused,
+	}
+}
@@ -0,0 +1,39 @@
+package main_test
+
+import (
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDaemonServiceState(t *testing.T) {
+	state := main.NewLoopState()
+
+	serviceState := main.NewDaemonServiceState(state, 0, false)
+	require.Equal(t, main.DaemonServiceState{CurrentTrack: "", LastScrobble: "", QueueDepth: 0, Paused: false}, serviceState)
+
+	state.CurrentlyPlaying = map[string]main.PlaybackStatus{"dbus:spotify": defaultPlaybackStatus}
+	state.LastScrobble = defaultScrobble
+
+	serviceState = main.NewDaemonServiceState(state, 2, true)
+	require.Equal(t, "Placebo, David Bowie — Without You I'm Nothing", serviceState.CurrentTrack)
+	require.Equal(t, "Placebo, David Bowie — Without You I'm Nothing", serviceState.LastScrobble)
+	require.Equal(t, 2, serviceState.QueueDepth)
+	require.True(t, serviceState.Paused)
+
+	paused := defaultPlaybackStatus
+	paused.State = main.PlaybackPaused
+	state.CurrentlyPlaying = map[string]main.PlaybackStatus{"dbus:spotify": paused}
+	require.Empty(t, main.NewDaemonServiceState(state, 0, false).CurrentTrack)
+}
+
+func TestControlServerDispatch(t *testing.T) {
+	server := main.NewControlServer()
+	server.Handle("pause", func(main.ControlRequest) main.ControlResponse {
+		return main.ControlResponse{Message: "paused scrobbling"}
+	})
+
+	require.Equal(t, "paused scrobbling", server.Dispatch(main.ControlRequest{Command: "pause"}).Message)
+	require.Equal(t, "unknown command: resume", server.Dispatch(main.ControlRequest{Command: "resume"}).Error)
+}
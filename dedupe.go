@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// seconds
+const DefaultDedupeWindow = 300
+
+func dedupeKey(scrobble Scrobble) string {
+	return strings.ToLower(fmt.Sprintf("%s\x00%s", scrobble.JoinArtists(), scrobble.Track))
+}
+
+// IsDuplicate records a scrobble and reports whether the same artist and
+// track was already scrobbled within the window, e.g. because a source
+// announced the track again after a seek or reconnect. The window never
+// exceeds the track duration, and the main loop forgets the previous scrobble
+// of a track that started again, so a track on repeat is still scrobbled
+// every time. A window of zero disables the check.
+func (s *LoopState) IsDuplicate(window time.Duration, scrobble Scrobble, now time.Time) bool {
+	if window <= 0 {
+		return false
+	}
+	if scrobble.Duration > 0 {
+		window = min(window, scrobble.Duration)
+	}
+
+	for key, scrobbled := range s.RecentScrobbles {
+		if now.Sub(scrobbled.Time) > scrobbled.Window {
+			delete(s.RecentScrobbles, key)
+		}
+	}
+
+	key := dedupeKey(scrobble)
+	if _, ok := s.RecentScrobbles[key]; ok {
+		return true
+	}
+
+	s.RecentScrobbles[key] = RecentScrobble{Time: now, Window: window}
+	return false
+}
+
+// FindDuplicates splits stored scrobbles into the ones to keep and the
+// duplicates, which have the same artist and track (ignoring case) as a kept
+// scrobble less than window earlier. As with IsDuplicate, the window never
+// exceeds the track duration, so a track on repeat is kept every time. Both
+// are returned sorted by timestamp.
+func FindDuplicates(scrobbles []Scrobble, window time.Duration) ([]Scrobble, []Scrobble) {
+	sorted := slices.Clone(scrobbles)
+	SortScrobbles(sorted)
+
+	lastKept := map[string]time.Time{}
+	var kept, duplicates []Scrobble
+	for _, scrobble := range sorted {
+		limit := window
+		if scrobble.Duration > 0 {
+			limit = min(limit, scrobble.Duration)
+		}
+
+		key := dedupeKey(scrobble)
+		if previous, ok := lastKept[key]; ok && scrobble.Timestamp.Sub(previous) < limit {
+			duplicates = append(duplicates, scrobble)
+			continue
+		}
+
+		lastKept[key] = scrobble.Timestamp
+		kept = append(kept, scrobble)
+	}
+
+	return kept, duplicates
+}
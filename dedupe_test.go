@@ -0,0 +1,106 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsDuplicate(t *testing.T) {
+	state := main.NewLoopState()
+	now := time.Now()
+
+	require.False(t, state.IsDuplicate(5*time.Minute, defaultScrobble, now))
+	require.True(t, state.IsDuplicate(5*time.Minute, defaultScrobble, now.Add(time.Minute)))
+
+	other := defaultScrobble
+	other.Track = "Pure Morning"
+	require.False(t, state.IsDuplicate(5*time.Minute, other, now.Add(time.Minute)))
+
+	// the window is limited to the track duration
+	require.False(t, state.IsDuplicate(time.Hour, defaultScrobble, now.Add(defaultScrobble.Duration+time.Second)))
+
+	require.False(t, main.NewLoopState().IsDuplicate(0, defaultScrobble, now))
+}
+
+func TestLoopDedupe(t *testing.T) {
+	state := main.NewLoopState()
+	options := replayOptions()
+	options.DedupeWindow = 5 * time.Minute
+	sink := &FakeSink{}
+	notifier := FakeNotifier{}
+
+	run := func(status main.PlaybackStatus) {
+		source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+		main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	}
+
+	playing := defaultPlaybackStatus
+	playing.Position = 0
+	run(playing)
+	playing.Position = 200 * time.Second
+	run(playing)
+	require.Len(t, sink.ScrobbleLog, 1)
+
+	// the source briefly reports other metadata and then announces the same
+	// track again
+	flapped := playing
+	flapped.Album = "Unknown Album"
+	run(flapped)
+	run(playing)
+	run(playing)
+	require.Len(t, sink.ScrobbleLog, 1)
+
+	// the track is on repeat and the next iteration crosses the threshold
+	// less than one track duration after the previous scrobble
+	repeated := playing
+	repeated.Position = 2 * time.Second
+	run(repeated)
+	repeated.Position = 200 * time.Second
+	run(repeated)
+	require.Len(t, sink.ScrobbleLog, 2)
+
+	// the same iteration is still not scrobbled twice
+	run(flapped)
+	run(repeated)
+	require.Len(t, sink.ScrobbleLog, 2)
+}
+
+func TestFindDuplicates(t *testing.T) {
+	at := func(track string, offset time.Duration) main.Scrobble {
+		scrobble := defaultScrobble
+		scrobble.Track = track
+		scrobble.Timestamp = defaultScrobble.Timestamp.Add(offset)
+		return scrobble
+	}
+
+	scrobbles := []main.Scrobble{
+		at("Pure Morning", 10*time.Minute),
+		at(defaultScrobble.Track, 0),
+		// submitted twice
+		at(defaultScrobble.Track, 0),
+		at("without you i'm nothing", 30*time.Second),
+		// played again on repeat
+		at(defaultScrobble.Track, defaultScrobble.Duration),
+		at("Pure Morning", 10*time.Minute+50*time.Second),
+	}
+
+	kept, duplicates := main.FindDuplicates(scrobbles, time.Minute)
+	require.Len(t, kept, 3)
+	require.Len(t, duplicates, 3)
+	require.True(t, defaultScrobble.Timestamp.Equal(kept[0].Timestamp))
+	require.True(t, defaultScrobble.Timestamp.Add(defaultScrobble.Duration).Equal(kept[1].Timestamp))
+	require.Equal(t, "Pure Morning", kept[2].Track)
+	require.Equal(t, "without you i'm nothing", duplicates[1].Track)
+
+	// the window is limited to the track duration
+	kept, duplicates = main.FindDuplicates(scrobbles, time.Hour)
+	require.Len(t, kept, 3)
+	require.Len(t, duplicates, 3)
+
+	kept, duplicates = main.FindDuplicates(scrobbles, 0)
+	require.Len(t, kept, 6)
+	require.Empty(t, duplicates)
+}
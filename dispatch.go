@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// seconds
+const (
+	DefaultSinkTimeout = 60
+	// the now playing status of last.fm expires after a few minutes
+	DefaultNowPlayingRefresh = 180
+	MinNowPlayingRefresh     = 30
+)
+
+// SinkTimeoutError is returned for sinks that did not respond in time. The
+// request keeps running in the background, so it may still succeed.
+type SinkTimeoutError struct {
+	Sink    string
+	Timeout time.Duration
+}
+
+func (e SinkTimeoutError) Error() string {
+	return fmt.Sprintf("%s did not respond within %s", e.Sink, e.Timeout)
+}
+
+var (
+	sinkLocksMutex sync.Mutex
+	sinkLocks      = map[string]*sync.Mutex{}
+)
+
+// sinkLock returns the lock serializing requests to the sink with the given
+// name, which is kept when the configuration is reloaded.
+func sinkLock(name string) *sync.Mutex {
+	sinkLocksMutex.Lock()
+	defer sinkLocksMutex.Unlock()
+
+	lock, ok := sinkLocks[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		sinkLocks[name] = lock
+	}
+	return lock
+}
+
+type dispatchResult struct {
+	index int
+	err   error
+}
+
+// DispatchSinks calls send for all sinks in parallel and returns their errors
+// in the order of sinks, so a slow or hanging sink does not delay the others.
+// Sinks that do not return within timeout get a SinkTimeoutError. Requests to
+// the same sink are never sent in parallel, so a hanging sink receives further
+// requests only once it responded. A timeout of zero waits for all sinks.
+func DispatchSinks(sinks []Sink, timeout time.Duration, send func(Sink) error) []error {
+	errs := make([]error, len(sinks))
+	pending := make([]bool, len(sinks))
+	results := make(chan dispatchResult, len(sinks))
+	expired := make(chan struct{})
+
+	for i, sink := range sinks {
+		pending[i] = true
+		go func() {
+			err := dispatchSink(sink, send)
+			select {
+			case <-expired:
+				log.Warn().
+					Err(err).
+					Str("sink", sink.Name()).
+					Msg("sink responded after timeout")
+			default:
+			}
+			results <- dispatchResult{index: i, err: err}
+		}()
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for range sinks {
+		select {
+		case result := <-results:
+			errs[result.index] = result.err
+			pending[result.index] = false
+		case <-deadline:
+			close(expired)
+			for i, sink := range sinks {
+				if pending[i] {
+					errs[i] = SinkTimeoutError{Sink: sink.Name(), Timeout: timeout}
+				}
+			}
+			return errs
+		}
+	}
+
+	return errs
+}
+
+// dispatchSink sends a request to a single sink, turning panics into errors so
+// a broken sink does not take down the daemon.
+func dispatchSink(sink Sink, send func(Sink) error) (err error) {
+	lock := sinkLock(sink.Name())
+	lock.Lock()
+	defer lock.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sink panicked: %v", r)
+		}
+	}()
+
+	return send(sink)
+}
@@ -0,0 +1,55 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+type HangingSink struct {
+	FakeSink
+	Release chan struct{}
+}
+
+func (*HangingSink) Name() string {
+	return "hanging sink"
+}
+
+func (s *HangingSink) Scrobble(_ main.Scrobble) error {
+	<-s.Release
+	return nil
+}
+
+type PanickingSink struct {
+	FakeSink
+}
+
+func (*PanickingSink) Name() string {
+	return "panicking sink"
+}
+
+func (*PanickingSink) Scrobble(_ main.Scrobble) error {
+	panic("broken sink")
+}
+
+func TestDispatchSinks(t *testing.T) {
+	hangingSink := &HangingSink{Release: make(chan struct{})}
+	defer close(hangingSink.Release)
+	fakeSink := &FakeSink{}
+	sinks := []main.Sink{hangingSink, &PanickingSink{}, fakeSink}
+
+	start := time.Now()
+	errs := main.DispatchSinks(sinks, 100*time.Millisecond, func(sink main.Sink) error {
+		return sink.Scrobble(defaultScrobble)
+	})
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	var timeoutErr main.SinkTimeoutError
+	require.ErrorAs(t, errs[0], &timeoutErr)
+	require.Equal(t, "hanging sink", timeoutErr.Sink)
+	require.ErrorContains(t, errs[1], "broken sink")
+	require.NoError(t, errs[2])
+	require.Len(t, fakeSink.ScrobbleLog, 1)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+type DoctorStatus string
+
+const (
+	DoctorOK      = DoctorStatus("ok")
+	DoctorWarning = DoctorStatus("warning")
+	DoctorError   = DoctorStatus("error")
+)
+
+// DoctorCheck is the result of a single check of the doctor command.
+type DoctorCheck struct {
+	Name    string
+	Status  DoctorStatus
+	Message string
+	// what to do about a warning or error
+	Hint string
+}
+
+// Doctor checks the configuration and the environment goscrobble runs in:
+// the running daemon, the configured sources, desktop notifications, and the
+// authentication and reachability of all sinks.
+func (c Config) Doctor(now time.Time) []DoctorCheck {
+	checks := []DoctorCheck{{Name: "config", Status: DoctorOK, Message: "configuration is valid", Hint: ""}}
+	for _, warning := range c.Lint(KeyringAvailable()) {
+		checks = append(checks, DoctorCheck{
+			Name:    "config",
+			Status:  DoctorWarning,
+			Message: warning.String(),
+			Hint:    fmt.Sprintf("fix the configuration or add %s to lint_ignore", warning.Rule),
+		})
+	}
+
+	if status, ok := QueryDaemonStatus(); ok {
+		checks = append(checks, DoctorCheck{Name: "daemon", Status: DoctorOK, Message: status.Summary(now), Hint: ""})
+	} else {
+		checks = append(checks, DoctorCheck{
+			Name:    "daemon",
+			Status:  DoctorWarning,
+			Message: "goscrobble is not running",
+			Hint:    "start it with `goscrobble run` or set up autostart with `goscrobble service install`",
+		})
+	}
+
+	if c.Sources.DBus != nil {
+		checks = append(checks, c.doctorDBus())
+	}
+	if c.Sources.MediaControl != nil {
+		checks = append(checks, DoctorCommand("media-control", c.Sources.MediaControl.Command,
+			"install media-control (https://github.com/ungive/media-control) or set its path in [sources.media-control]"))
+	}
+	if c.NotifyOnScrobble || c.NotifyOnError {
+		checks = append(checks, doctorNotifications())
+	}
+
+	for _, authenticator := range c.Authenticators() {
+		checks = append(checks, doctorAuth(authenticator.Name(), authenticator.Status(c), now))
+	}
+
+	sinks := c.SetupSinks()
+	if len(sinks) == 0 {
+		checks = append(checks, DoctorCheck{
+			Name:    "sinks",
+			Status:  DoctorError,
+			Message: "no sinks are set up, scrobbles are not saved",
+			Hint:    "configure a sink in the config file",
+		})
+	}
+	return append(checks, DoctorSinks(sinks, now)...)
+}
+
+func (c Config) doctorDBus() DoctorCheck {
+	check := DoctorCheck{Name: "dbus", Status: DoctorOK, Message: "", Hint: ""}
+
+	var conn *dbus.Conn
+	var err error
+	if c.Sources.DBus.Address == "" {
+		conn, err = dbus.ConnectSessionBus()
+	} else {
+		conn, err = dbus.Connect(c.Sources.DBus.Address)
+	}
+	if err != nil {
+		check.Status = DoctorError
+		check.Message = fmt.Sprintf("cannot connect to bus: %s", err.Error())
+		check.Hint = "make sure a D-Bus session bus is running (DBUS_SESSION_BUS_ADDRESS) or set the address in [sources.dbus]"
+		return check
+	}
+	defer CloseLogged(conn)
+
+	players, err := DBusSource{Conn: conn}.GetInfo(c.ParseBlacklist(), nil)
+	switch {
+	case err != nil:
+		check.Status = DoctorError
+		check.Message = fmt.Sprintf("cannot list players: %s", err.Error())
+		check.Hint = "check that the bus allows listing names"
+	case len(players) == 0:
+		check.Status = DoctorWarning
+		check.Message = "connected, but no MPRIS players were found"
+		check.Hint = "start a media player with MPRIS support, or check the blacklist"
+	default:
+		check.Message = fmt.Sprintf("%d players: %s", len(players), strings.Join(slices.Sorted(maps.Keys(players)), ", "))
+	}
+	return check
+}
+
+// DoctorCommand checks that a command is installed.
+func DoctorCommand(name, command, hint string) DoctorCheck {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return DoctorCheck{Name: name, Status: DoctorError, Message: fmt.Sprintf("%s not found", command), Hint: hint}
+	}
+	return DoctorCheck{Name: name, Status: DoctorOK, Message: path, Hint: ""}
+}
+
+func doctorNotifications() DoctorCheck {
+	if err := NotificationsAvailable(); err != nil {
+		return DoctorCheck{
+			Name:    "notifications",
+			Status:  DoctorWarning,
+			Message: err.Error(),
+			Hint:    "set notify_on_scrobble and notify_on_error to false if you do not need desktop notifications",
+		}
+	}
+	return DoctorCheck{Name: "notifications", Status: DoctorOK, Message: "desktop notifications are available", Hint: ""}
+}
+
+func doctorAuth(name string, status AuthStatus, now time.Time) DoctorCheck {
+	check := DoctorCheck{Name: "auth " + name, Status: DoctorOK, Message: status.State(now), Hint: ""}
+	if status.Account != "" {
+		check.Message += " as " + status.Account
+	}
+	if status.State(now) != "authenticated" {
+		check.Status = DoctorError
+		check.Hint = fmt.Sprintf("run `goscrobble auth login %s`", name)
+	}
+	return check
+}
+
+// DoctorSinks loads the most recent scrobble of every sink, which shows that
+// local files can be read and remote APIs can be reached.
+func DoctorSinks(sinks []Sink, now time.Time) []DoctorCheck {
+	var checks []DoctorCheck
+	for _, sink := range sinks {
+		check := DoctorCheck{Name: "sink " + sink.Name(), Status: DoctorOK, Message: "reachable", Hint: ""}
+
+		_, err := sink.GetScrobbles(1, now.Add(-24*time.Hour), now)
+		var netErr net.Error
+		switch {
+		case err == nil:
+		case errors.Is(err, fs.ErrNotExist):
+			check.Message = "file does not exist yet, it is created with the first scrobble"
+		case errors.As(err, &netErr):
+			check.Status = DoctorError
+			check.Message = err.Error()
+			check.Hint = "check your network connection and proxy settings"
+		default:
+			check.Status = DoctorError
+			check.Message = err.Error()
+			check.Hint = "check the configuration and credentials of the sink"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
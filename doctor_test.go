@@ -0,0 +1,51 @@
+package main_test
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+type unreachableSink struct {
+	FakeSink
+}
+
+func (*unreachableSink) GetScrobbles(_ int, _, _ time.Time) ([]main.Scrobble, error) {
+	return nil, &url.Error{Op: "Get", URL: "https://ws.audioscrobbler.com/2.0/", Err: errors.New("connection refused")}
+}
+
+func TestDoctorSinks(t *testing.T) {
+	now := time.Now()
+	sinks := []main.Sink{
+		&FakeSink{},
+		main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "missing.csv")},
+		&unreachableSink{},
+	}
+
+	checks := main.DoctorSinks(sinks, now)
+	require.Len(t, checks, 3)
+	require.Equal(t, "sink fake sink", checks[0].Name)
+	require.Equal(t, main.DoctorOK, checks[0].Status)
+	require.Equal(t, main.DoctorOK, checks[1].Status)
+	require.Contains(t, checks[1].Message, "does not exist yet")
+	require.Equal(t, main.DoctorError, checks[2].Status)
+	require.Contains(t, checks[2].Hint, "network connection")
+}
+
+func TestDoctorCommand(t *testing.T) {
+	executable, err := os.Executable()
+	require.NoError(t, err)
+
+	check := main.DoctorCommand("test", executable, "")
+	require.Equal(t, main.DoctorOK, check.Status)
+
+	check = main.DoctorCommand("media-control", "goscrobble-missing-command", "install it")
+	require.Equal(t, main.DoctorError, check.Status)
+	require.Equal(t, "install it", check.Hint)
+}
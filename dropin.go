@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ConfigDropInDir returns the directory whose files are merged into a config
+// file, e.g. `config.toml.d` for `config.toml`.
+func ConfigDropInDir(filename string) string {
+	return filename + ".d"
+}
+
+// ConfigDropIns returns the `.toml` files of the drop-in directory of a config
+// file in lexical order. A missing directory has no drop-ins.
+func ConfigDropIns(filename string) ([]string, error) {
+	directory := ConfigDropInDir(filename)
+	entries, err := os.ReadDir(directory)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// os.ReadDir sorts the entries by name
+	var dropIns []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".toml" {
+			continue
+		}
+		dropIns = append(dropIns, filepath.Join(directory, name))
+	}
+	return dropIns, nil
+}
+
+// DecodeConfigFile decodes a config file and its drop-ins into config. Drop-ins
+// are merged into the config file in lexical order, see MergeConfigTables.
+func DecodeConfigFile(filename string, config *Config) (toml.MetaData, error) {
+	dropIns, err := ConfigDropIns(filename)
+	if err != nil {
+		return toml.MetaData{}, fmt.Errorf("cannot read drop-in directory: %s", err.Error())
+	}
+	if len(dropIns) == 0 {
+		return toml.DecodeFile(filename, config)
+	}
+
+	merged := map[string]any{}
+	for _, name := range append([]string{filename}, dropIns...) {
+		table := map[string]any{}
+		if _, err := toml.DecodeFile(name, &table); err != nil {
+			if name == filename {
+				return toml.MetaData{}, err
+			}
+			return toml.MetaData{}, fmt.Errorf("%s: %s", name, err.Error())
+		}
+		MergeConfigTables(merged, table)
+	}
+
+	var buffer bytes.Buffer
+	if err := toml.NewEncoder(&buffer).Encode(merged); err != nil {
+		return toml.MetaData{}, err
+	}
+	metadata, err := toml.NewDecoder(&buffer).Decode(config)
+	if err != nil {
+		return toml.MetaData{}, fmt.Errorf("error merging drop-ins: %s", err.Error())
+	}
+
+	config.dropIns = dropIns
+	return metadata, nil
+}
+
+// DropIns returns the drop-in files that were merged into the config.
+func (c Config) DropIns() []string {
+	return c.dropIns
+}
+
+// MergeConfigTables merges a decoded drop-in into the decoded config. Tables
+// are merged key by key, arrays of tables (e.g., `[[regexes]]`) are appended,
+// and all other values replace those of the config.
+func MergeConfigTables(config, dropIn map[string]any) {
+	for key, value := range dropIn {
+		if table, ok := value.(map[string]any); ok {
+			if existing, ok := config[key].(map[string]any); ok {
+				MergeConfigTables(existing, table)
+				continue
+			}
+		}
+
+		if tables, ok := tableArray(value); ok {
+			if existing, ok := tableArray(config[key]); ok {
+				config[key] = append(existing, tables...)
+				continue
+			}
+		}
+
+		config[key] = value
+	}
+}
+
+// tableArray returns the tables of an array that only contains tables, both
+// for `[[key]]` and inline tables.
+func tableArray(value any) ([]map[string]any, bool) {
+	switch value := value.(type) {
+	case []map[string]any:
+		return value, true
+	case []any:
+		tables := make([]map[string]any, 0, len(value))
+		for _, element := range value {
+			table, ok := element.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			tables = append(tables, table)
+		}
+		return tables, true
+	default:
+		return nil, false
+	}
+}
+
+// writeOwnSettings writes a config read with drop-ins back to the config file.
+// Only the settings that changed since reading it are written, so settings of
+// drop-ins are not copied into the config file. Settings of drop-ins cannot be
+// changed this way, since the drop-in would still override them.
+func (c Config) writeOwnSettings(filename string) error {
+	own := map[string]any{}
+	if _, err := toml.DecodeFile(filename, &own); err != nil {
+		return err
+	}
+
+	// the config as it was read, compared in the same format as the changed
+	// config
+	var read Config
+	if _, err := DecodeConfigFile(filename, &read); err != nil {
+		return err
+	}
+	_ = read.Validate()
+	original, err := read.table()
+	if err != nil {
+		return err
+	}
+	current, err := c.persisted().table()
+	if err != nil {
+		return err
+	}
+
+	dropIns := make([]map[string]any, 0, len(read.dropIns))
+	for _, name := range read.dropIns {
+		dropIn := map[string]any{}
+		if _, err := toml.DecodeFile(name, &dropIn); err != nil {
+			return fmt.Errorf("%s: %s", name, err.Error())
+		}
+		dropIns = append(dropIns, dropIn)
+	}
+
+	if err := applyConfigChanges(own, original, current, dropIns, nil); err != nil {
+		return err
+	}
+
+	//nolint:gosec
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(file)
+
+	encoder := toml.NewEncoder(file)
+	encoder.Indent = ""
+
+	return encoder.Encode(own)
+}
+
+// table returns the config as decoded TOML.
+func (c Config) table() (map[string]any, error) {
+	var buffer bytes.Buffer
+	if err := c.Encode(&buffer); err != nil {
+		return nil, err
+	}
+
+	table := map[string]any{}
+	if _, err := toml.NewDecoder(&buffer).Decode(&table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// applyConfigChanges sets the values of current that differ from original in
+// own, the config file. Tables are compared key by key and all other values as
+// a whole.
+func applyConfigChanges(own, original, current map[string]any, dropIns []map[string]any, path []string) error {
+	for _, key := range slices.Sorted(maps.Keys(current)) {
+		value := current[key]
+		previous, existed := original[key]
+
+		table, isTable := value.(map[string]any)
+		previousTable, wasTable := previous.(map[string]any)
+		if isTable && wasTable {
+			ownTable, ok := own[key].(map[string]any)
+			if !ok {
+				ownTable = map[string]any{}
+			}
+			if err := applyConfigChanges(ownTable, previousTable, table, dropInTables(dropIns, key), append(path, key)); err != nil {
+				return err
+			}
+			if len(ownTable) > 0 {
+				own[key] = ownTable
+			}
+			continue
+		}
+
+		if existed && reflect.DeepEqual(previous, value) {
+			continue
+		}
+		if err := checkDropInSetting(dropIns, key, path); err != nil {
+			return err
+		}
+		own[key] = value
+	}
+
+	for key := range original {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		if err := checkDropInSetting(dropIns, key, path); err != nil {
+			return err
+		}
+		delete(own, key)
+	}
+
+	return nil
+}
+
+// dropInTables returns the tables of drop-ins with the given key.
+func dropInTables(dropIns []map[string]any, key string) []map[string]any {
+	var tables []map[string]any
+	for _, dropIn := range dropIns {
+		if table, ok := dropIn[key].(map[string]any); ok {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+func checkDropInSetting(dropIns []map[string]any, key string, path []string) error {
+	for _, dropIn := range dropIns {
+		if _, ok := dropIn[key]; ok {
+			setting := strings.Join(append(slices.Clone(path), key), ".")
+			return fmt.Errorf("%s is set by a drop-in file and has to be changed there", setting)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+const dropInConfig = `poll_rate = 2
+player_priority = ["spotify"]
+
+[[regexes]]
+match = " - Remastered$"
+replace = ""
+track = true
+
+[sinks.lastfm.default]
+key = "key"
+secret = "secret"
+`
+
+func TestReadConfigDropIns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), main.DefaultConfigFileName)
+	require.NoError(t, os.WriteFile(filename, []byte(dropInConfig), 0600))
+
+	directory := main.ConfigDropInDir(filename)
+	require.NoError(t, os.Mkdir(directory, 0700))
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(directory, name), []byte(content), 0600))
+	}
+	write("10-regexes.toml", "[[regexes]]\nmatch = \" \\\\(Live\\\\)$\"\nreplace = \"\"\ntrack = true\n")
+	write("20-credentials.toml", "[sinks.lastfm.default]\nsession_key = \"session key\"\n")
+	write("90-machine.toml", "poll_rate = 5\nplayer_priority = [\"^upnp:\"]\n")
+	// only .toml files are merged
+	write("99-disabled.toml.bak", "poll_rate = 10\n")
+	write(".hidden.toml", "poll_rate = 10\n")
+
+	config, err := main.ReadConfig(filename)
+	require.NoError(t, err)
+	require.Equal(t, 5, config.PollRate)
+	require.Equal(t, []string{"^upnp:"}, config.PlayerPriority)
+	require.Equal(t, []main.RegexReplace{
+		{Match: " - Remastered$", Replace: "", Track: true},
+		{Match: ` \(Live\)$`, Replace: "", Track: true},
+	}, config.Regexes)
+	require.Equal(t, main.LastFmConfig{Key: "key", Secret: "secret", SessionKey: "session key"}, config.Sinks.LastFm["default"])
+	require.Equal(t, []string{
+		filepath.Join(directory, "10-regexes.toml"),
+		filepath.Join(directory, "20-credentials.toml"),
+		filepath.Join(directory, "90-machine.toml"),
+	}, config.DropIns())
+
+	// unknown settings of drop-ins are reported like those of the config file
+	write("50-typo.toml", "pol_rate = 3\n")
+	findings := main.CheckConfigFile(filename, false, false)
+	require.Contains(t, findings, main.ConfigFinding{
+		Severity: main.FindingWarning,
+		Rule:     main.CheckUnknownKey,
+		Subject:  "pol_rate",
+		Message:  "unknown setting, it is ignored",
+	})
+
+	write("50-typo.toml", "poll_rate = \n")
+	_, err = main.ReadConfig(filename)
+	require.ErrorContains(t, err, "50-typo.toml")
+}
+
+func TestWriteConfigDropIns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), main.DefaultConfigFileName)
+	require.NoError(t, os.WriteFile(filename, []byte(dropInConfig), 0600))
+
+	directory := main.ConfigDropInDir(filename)
+	require.NoError(t, os.Mkdir(directory, 0700))
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(directory, name), []byte(content), 0600))
+	}
+	write("10-regexes.toml", "[[regexes]]\nmatch = \" \\\\(Live\\\\)$\"\nreplace = \"\"\ntrack = true\n")
+	write("20-credentials.toml", "[sinks.lastfm.default]\nsession_key = \"session key\"\n")
+	write("90-machine.toml", "poll_rate = 5\n")
+
+	config, err := main.ReadConfig(filename)
+	require.NoError(t, err)
+
+	config.MinPlaybackPercent = 75
+	sink := config.Sinks.LastFm["default"]
+	sink.Key = "new key"
+	config.Sinks.LastFm["default"] = sink
+	require.NoError(t, config.Write(filename))
+
+	// settings of drop-ins are not copied into the config file
+	var own main.Config
+	_, err = toml.DecodeFile(filename, &own)
+	require.NoError(t, err)
+	require.Equal(t, 2, own.PollRate)
+	require.Equal(t, 75, own.MinPlaybackPercent)
+	require.Len(t, own.Regexes, 1)
+	require.Equal(t, main.LastFmConfig{Key: "new key", Secret: "secret", SessionKey: ""}, own.Sinks.LastFm["default"])
+
+	reread, err := main.ReadConfig(filename)
+	require.NoError(t, err)
+	require.Equal(t, config, reread)
+
+	// settings of drop-ins have to be changed in the drop-in
+	//nolint:gosec
+	written, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	config.PollRate = 10
+	require.EqualError(t, config.Write(filename), "poll_rate is set by a drop-in file and has to be changed there")
+
+	config.PollRate = 5
+	sink.SessionKey = ""
+	config.Sinks.LastFm["default"] = sink
+	require.EqualError(t, config.Write(filename), "sinks.lastfm.default.session_key is set by a drop-in file and has to be changed there")
+
+	//nolint:gosec
+	unchanged, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Equal(t, written, unchanged)
+}
+
+func TestMergeConfigTables(t *testing.T) {
+	config := map[string]any{
+		"poll_rate": int64(2),
+		"blacklist": []map[string]any{{"match": "chromium", "player": true}},
+		"sinks":     map[string]any{"csv": map[string]any{"default": map[string]any{"filename": "scrobbles.csv"}}},
+	}
+	main.MergeConfigTables(config, map[string]any{
+		"poll_rate": int64(5),
+		"blacklist": []any{map[string]any{"match": "Podcast", "album": true}},
+		"sinks":     map[string]any{"csv": map[string]any{"archive": map[string]any{"filename": "archive.csv"}}},
+	})
+
+	require.Equal(t, map[string]any{
+		"poll_rate": int64(5),
+		"blacklist": []map[string]any{
+			{"match": "chromium", "player": true},
+			{"match": "Podcast", "album": true},
+		},
+		"sinks": map[string]any{"csv": map[string]any{
+			"default": map[string]any{"filename": "scrobbles.csv"},
+			"archive": map[string]any{"filename": "archive.csv"},
+		}},
+	}, config)
+}
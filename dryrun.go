@@ -0,0 +1,71 @@
+package main
+
+import (
+	"maps"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DryRunSink logs scrobbles and now playing updates instead of passing them on
+// to the wrapped sink, e.g. while trying out a new remote sink. Reading
+// scrobbles is not affected.
+type DryRunSink struct {
+	Sink
+}
+
+func (s DryRunSink) Unwrap() Sink {
+	return s.Sink
+}
+
+func (s DryRunSink) NowPlaying(scrobble Scrobble) error {
+	log.Info().
+		Str("sink", s.Name()).
+		Interface("scrobble", scrobble).
+		Msg("dry run: not updating now playing status")
+	return nil
+}
+
+func (s DryRunSink) Scrobble(scrobble Scrobble) error {
+	log.Info().
+		Str("sink", s.Name()).
+		Interface("scrobble", scrobble).
+		Msg("dry run: not saving scrobble")
+	return nil
+}
+
+// DryRunConfig returns a copy of the config for `goscrobble run --dry-run`.
+// All sinks, including those of user profiles, are switched to dry runs. The
+// audit log, offline queue, and playback journal are disabled, so a dry run
+// leaves no trace that a later real run would pick up.
+func (c Config) DryRunConfig() Config {
+	c.AuditLog = false
+	c.OfflineQueue = false
+	c.PlaybackJournal = false
+
+	c.Sinks = c.Sinks.dryRun()
+
+	c.Users = maps.Clone(c.Users)
+	for name, user := range c.Users {
+		user.Sinks = user.Sinks.dryRun()
+		c.Users[name] = user
+	}
+
+	return c
+}
+
+// dryRun returns a copy of the sinks with dry_run enabled.
+func (s SinksConfig) dryRun() SinksConfig {
+	s.LastFm = maps.Clone(s.LastFm)
+	for key, sinkConfig := range s.LastFm {
+		sinkConfig.DryRun = true
+		s.LastFm[key] = sinkConfig
+	}
+
+	s.CSV = maps.Clone(s.CSV)
+	for key, sinkConfig := range s.CSV {
+		sinkConfig.DryRun = true
+		s.CSV[key] = sinkConfig
+	}
+
+	return s
+}
@@ -0,0 +1,57 @@
+package main_test
+
+import (
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunSink(t *testing.T) {
+	fakeSink := &FakeSink{}
+
+	config := main.DefaultConfig
+	sink, err := config.WrapSink(fakeSink, main.SinkOptions{DryRun: true})
+	require.NoError(t, err)
+
+	require.NoError(t, sink.NowPlaying(defaultScrobble))
+	require.NoError(t, sink.Scrobble(defaultScrobble))
+	require.Empty(t, fakeSink.NowPlayingLog)
+	require.Empty(t, fakeSink.ScrobbleLog)
+
+	// dry runs are neither audited nor queued
+	_, ok := sink.(main.AuditSink)
+	require.False(t, ok)
+	_, ok = sink.(main.QueuedSink)
+	require.False(t, ok)
+
+	require.Equal(t, "fake sink", sink.Name())
+	require.Equal(t, fakeSink, main.UnwrapSink(sink))
+}
+
+func TestDryRunConfig(t *testing.T) {
+	config := main.DefaultConfig
+	config.AuditLog = true
+	config.OfflineQueue = true
+	config.PlaybackJournal = true
+	config.Sinks.CSV = map[string]main.CSVConfig{"default": {Filename: "scrobbles.csv"}}
+	config.Users = map[string]main.UserConfig{"guest": {
+		Sinks: main.SinksConfig{LastFm: map[string]main.LastFmConfig{"default": {Key: "key"}}},
+	}}
+
+	dryRun := config.DryRunConfig()
+	require.True(t, dryRun.Sinks.CSV["default"].DryRun)
+	require.True(t, dryRun.Users["guest"].Sinks.LastFm["default"].DryRun)
+	require.False(t, dryRun.AuditLog)
+	require.False(t, dryRun.OfflineQueue)
+	require.False(t, dryRun.PlaybackJournal)
+
+	// the original config is unchanged
+	require.False(t, config.Sinks.CSV["default"].DryRun)
+	require.False(t, config.Users["guest"].Sinks.LastFm["default"].DryRun)
+
+	for _, sink := range dryRun.SetupSinks() {
+		_, ok := sink.(main.DryRunSink)
+		require.True(t, ok, sink.Name())
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// UnknownDuration decides how tracks are handled whose source does not report
+// a duration (e.g., streams and some browsers).
+type UnknownDuration string
+
+const (
+	// tracks without a duration are never scrobbled (default)
+	UnknownDurationIgnore = UnknownDuration("ignore")
+	// tracks without a duration are scrobbled after min_playback_duration
+	UnknownDurationMinDuration = UnknownDuration("min_duration")
+	// the duration is looked up by the first sink that supports it (e.g.,
+	// last.fm), tracks that are not found are never scrobbled
+	UnknownDurationLookup = UnknownDuration("lookup")
+)
+
+// DurationLookup is implemented by sinks that can look up the duration of a
+// track (e.g., LastFmSink).
+type DurationLookup interface {
+	TrackDuration(Scrobble) (time.Duration, error)
+}
+
+// Accepts reports whether a track can be scrobbled under the policy.
+func (p UnknownDuration) Accepts(scrobble Scrobble) bool {
+	return scrobble.IsValid() || p == UnknownDurationMinDuration && scrobble.HasMetadata()
+}
+
+// ResolveDurations looks up the duration of tracks without one if the policy
+// is UnknownDurationLookup. Results are kept while a track plays, so every
+// track is looked up only once per play.
+func (s *LoopState) ResolveDurations(policy UnknownDuration, sinks []Sink, playbackStatus map[string]PlaybackStatus) {
+	if policy != UnknownDurationLookup {
+		return
+	}
+
+	durations := map[string]time.Duration{}
+	for player, status := range playbackStatus {
+		if status.Duration != 0 || !status.HasMetadata() {
+			continue
+		}
+
+		key := dedupeKey(status.Scrobble)
+		duration, ok := s.Durations[key]
+		if !ok {
+			duration = LookupDuration(sinks, status.Scrobble)
+		}
+		durations[key] = duration
+
+		status.Duration = duration
+		playbackStatus[player] = status
+	}
+	s.Durations = durations
+}
+
+// LookupDuration returns the duration of a track from the first sink that
+// knows it, or zero if no sink does.
+func LookupDuration(sinks []Sink, scrobble Scrobble) time.Duration {
+	for _, sink := range sinks {
+		lookup, ok := UnwrapSink(sink).(DurationLookup)
+		if !ok {
+			continue
+		}
+
+		duration, err := lookup.TrackDuration(scrobble)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("sink", sink.Name()).
+				Interface("scrobble", scrobble).
+				Msg("error looking up track duration")
+			continue
+		}
+		if duration > 0 {
+			log.Debug().
+				Str("sink", sink.Name()).
+				Interface("scrobble", scrobble).
+				Dur("duration", duration).
+				Msg("looked up track duration")
+			return duration
+		}
+	}
+
+	log.Info().
+		Interface("scrobble", scrobble).
+		Msg("duration of track is unknown, not scrobbling it")
+	return 0
+}
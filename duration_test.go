@@ -0,0 +1,82 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+type LookupSink struct {
+	FakeSink
+	Duration time.Duration
+	Lookups  int
+}
+
+func (s *LookupSink) TrackDuration(_ main.Scrobble) (time.Duration, error) {
+	s.Lookups++
+	return s.Duration, nil
+}
+
+func unknownDurationRun(state *main.LoopState, options main.LoopOptions, sink main.Sink, position time.Duration) {
+	status := defaultPlaybackStatus
+	status.Duration = 0
+	status.Position = position
+	source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+	notifier := FakeNotifier{}
+	main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+}
+
+func TestUnknownDurationIgnore(t *testing.T) {
+	state := main.NewLoopState()
+	sink := &FakeSink{}
+
+	for _, position := range []time.Duration{0, 10 * time.Minute} {
+		unknownDurationRun(state, replayOptions(), sink, position)
+	}
+	require.Empty(t, sink.NowPlayingLog)
+	require.Empty(t, sink.ScrobbleLog)
+}
+
+func TestUnknownDurationMinDuration(t *testing.T) {
+	state := main.NewLoopState()
+	sink := &FakeSink{}
+	options := replayOptions()
+	options.UnknownDuration = main.UnknownDurationMinDuration
+
+	for _, position := range []time.Duration{0, 200 * time.Second} {
+		unknownDurationRun(state, options, sink, position)
+	}
+	require.Len(t, sink.NowPlayingLog, 1)
+	require.Empty(t, sink.ScrobbleLog)
+
+	// min_playback_duration is 240 seconds
+	unknownDurationRun(state, options, sink, 250*time.Second)
+	require.Len(t, sink.ScrobbleLog, 1)
+	require.Zero(t, sink.ScrobbleLog[0].Duration)
+}
+
+func TestUnknownDurationLookup(t *testing.T) {
+	state := main.NewLoopState()
+	sink := &LookupSink{Duration: defaultScrobble.Duration}
+	options := replayOptions()
+	options.UnknownDuration = main.UnknownDurationLookup
+
+	// the threshold is half of the looked up duration
+	for _, position := range []time.Duration{0, 130 * time.Second} {
+		unknownDurationRun(state, options, sink, position)
+	}
+	require.Equal(t, 1, sink.Lookups)
+	require.Len(t, sink.ScrobbleLog, 1)
+	require.Equal(t, defaultScrobble.Duration, sink.ScrobbleLog[0].Duration)
+
+	// tracks that are not found are not scrobbled
+	state = main.NewLoopState()
+	sink = &LookupSink{Duration: 0}
+	for _, position := range []time.Duration{0, 10 * time.Minute} {
+		unknownDurationRun(state, options, sink, position)
+	}
+	require.Equal(t, 1, sink.Lookups)
+	require.Empty(t, sink.ScrobbleLog)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formats accepted for the timestamp of a single scrobble, read in the local
+// time zone if they have no time zone
+var scrobbleTimeLayouts = []string{
+	time.RFC1123,
+	time.RFC3339,
+	time.DateTime,
+}
+
+// ParseScrobbleTime parses the timestamp of a scrobble as printed by the
+// scrobbles command, in RFC 3339 format, or as unix timestamp.
+func ParseScrobbleTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+
+	for _, layout := range scrobbleTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format: %q", value)
+}
+
+// SelectScrobbles returns the scrobbles matching the query. If at is not
+// zero, only scrobbles with that timestamp (to the second) are returned.
+func SelectScrobbles(scrobbles []Scrobble, at time.Time, query SearchQuery) []Scrobble {
+	var selected []Scrobble
+	for _, scrobble := range scrobbles {
+		if !at.IsZero() && scrobble.Timestamp.Unix() != at.Unix() {
+			continue
+		}
+		if query.Match(scrobble) {
+			selected = append(selected, scrobble)
+		}
+	}
+	return selected
+}
+
+// ScrobbleEdit changes the fields of scrobbles. Empty fields are left
+// unchanged.
+type ScrobbleEdit struct {
+	Artists []string
+	Track   string
+	Album   string
+}
+
+func (e ScrobbleEdit) Empty() bool {
+	return len(e.Artists) == 0 && e.Track == "" && e.Album == ""
+}
+
+func (e ScrobbleEdit) Apply(scrobble Scrobble) Scrobble {
+	if len(e.Artists) > 0 {
+		scrobble.Artists = e.Artists
+	}
+	if e.Track != "" {
+		scrobble.Track = e.Track
+	}
+	if e.Album != "" {
+		scrobble.Album = e.Album
+	}
+	return scrobble
+}
+
+// EditScrobbles applies the edit to the selected scrobbles of an editable
+// sink and returns the edited scrobbles. The file is rewritten at once, so it
+// is never left half-edited. If the sink stores tombstones, the original
+// scrobbles are marked as deleted, so a later sync does not add them again.
+func EditScrobbles(sink Sink, selected []Scrobble, edit ScrobbleEdit) ([]Scrobble, error) {
+	editable, ok := UnwrapSink(sink).(EditableSink)
+	if !ok {
+		return nil, fmt.Errorf("sink %s cannot be edited", sink.Name())
+	}
+
+	scrobbles, err := sink.GetScrobbles(0, time.Time{}, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+
+	keys := map[string]bool{}
+	for _, scrobble := range selected {
+		keys[scrobble.Key()] = true
+	}
+
+	var edited, replaced []Scrobble
+	for i, scrobble := range scrobbles {
+		if !keys[scrobble.Key()] {
+			continue
+		}
+		scrobbles[i] = edit.Apply(scrobble)
+		edited = append(edited, scrobbles[i])
+		if scrobbles[i].Key() != scrobble.Key() {
+			replaced = append(replaced, scrobble)
+		}
+	}
+	if len(edited) == 0 {
+		return nil, nil
+	}
+
+	SortScrobbles(scrobbles)
+	if err := editable.ReplaceScrobbles(scrobbles); err != nil {
+		return nil, fmt.Errorf("error saving scrobbles: %s", err.Error())
+	}
+
+	if tombstoneSink, ok := UnwrapSink(sink).(TombstoneSink); ok && len(replaced) > 0 {
+		if err := tombstoneSink.DeleteScrobbles(replaced); err != nil {
+			return edited, fmt.Errorf("error saving tombstones: %s", err.Error())
+		}
+	}
+	return edited, nil
+}
@@ -0,0 +1,93 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScrobbleTime(t *testing.T) {
+	for _, value := range []string{
+		"1699225080",
+		"Sun, 05 Nov 2023 22:58:00 UTC",
+		"2023-11-05T23:58:00+01:00",
+	} {
+		parsed, err := main.ParseScrobbleTime(value)
+		require.NoError(t, err, value)
+		require.Equal(t, defaultScrobble.Timestamp.Unix(), parsed.Unix(), value)
+	}
+
+	parsed, err := main.ParseScrobbleTime("2023-11-05 22:58:00")
+	require.NoError(t, err)
+	require.Equal(t, time.Local, parsed.Location())
+
+	_, err = main.ParseScrobbleTime("yesterday")
+	require.Error(t, err)
+}
+
+func TestSelectScrobbles(t *testing.T) {
+	other := defaultScrobble
+	other.Track = "Pure Morning"
+	other.Timestamp = defaultScrobble.Timestamp.Add(time.Hour)
+	scrobbles := []main.Scrobble{defaultScrobble, other}
+
+	all, err := main.ParseSearchQuery(nil, false)
+	require.NoError(t, err)
+	require.Equal(t, []main.Scrobble{other}, main.SelectScrobbles(scrobbles, other.Timestamp, all))
+
+	query, err := main.ParseSearchQuery([]string{"track:pure"}, false)
+	require.NoError(t, err)
+	require.Equal(t, []main.Scrobble{other}, main.SelectScrobbles(scrobbles, time.Time{}, query))
+	require.Empty(t, main.SelectScrobbles(scrobbles, defaultScrobble.Timestamp, query))
+}
+
+func TestEditScrobbles(t *testing.T) {
+	sink := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv")}
+	other := defaultScrobble
+	other.Track = "Pure Morning"
+	other.Timestamp = defaultScrobble.Timestamp.Add(time.Hour)
+	require.NoError(t, sink.ReplaceScrobbles([]main.Scrobble{defaultScrobble, other}))
+
+	edit := main.ScrobbleEdit{Artists: []string{"Placebo"}, Track: "", Album: "Without You I'm Nothing"}
+	require.False(t, edit.Empty())
+
+	edited, err := main.EditScrobbles(sink, []main.Scrobble{defaultScrobble}, edit)
+	require.NoError(t, err)
+	require.Len(t, edited, 1)
+
+	scrobbles, err := sink.GetScrobbles(0, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 2)
+	require.Equal(t, other, scrobbles[0])
+	require.Equal(t, []string{"Placebo"}, scrobbles[1].Artists)
+	require.Equal(t, defaultScrobble.Track, scrobbles[1].Track)
+	require.Equal(t, "Without You I'm Nothing", scrobbles[1].Album)
+	require.True(t, defaultScrobble.Timestamp.Equal(scrobbles[1].Timestamp))
+
+	// the original scrobble is not restored by a later sync
+	tombstones, err := sink.Tombstones()
+	require.NoError(t, err)
+	require.Len(t, tombstones, 1)
+	require.Equal(t, defaultScrobble.JoinArtists(), tombstones[0].Scrobble.JoinArtists())
+
+	// undoing the edit restores the original scrobble
+	undo := main.ScrobbleEdit{Artists: defaultScrobble.Artists, Track: "", Album: defaultScrobble.Album}
+	_, err = main.EditScrobbles(sink, []main.Scrobble{scrobbles[1]}, undo)
+	require.NoError(t, err)
+
+	scrobbles, err = sink.GetScrobbles(0, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 2)
+	require.Equal(t, defaultScrobble.Key(), scrobbles[1].Key())
+
+	tombstones, err = sink.Tombstones()
+	require.NoError(t, err)
+	require.Len(t, tombstones, 1)
+	require.Equal(t, []string{"Placebo"}, tombstones[0].Scrobble.Artists)
+
+	_, err = main.EditScrobbles(&FakeSink{}, []main.Scrobble{defaultScrobble}, edit)
+	require.Error(t, err)
+}
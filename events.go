@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EventType is the kind of an event sent to event outputs.
+type EventType string
+
+const (
+	// a new track started playing
+	EventNowPlaying = EventType("now_playing")
+	// a track was played long enough to be scrobbled
+	EventThresholdReached = EventType("threshold_reached")
+	// a track was saved by at least one sink
+	EventScrobbled = EventType("scrobbled")
+	// a track ended before it was played long enough to be scrobbled
+	EventSkipped = EventType("skipped")
+	// a sink failed to save a scrobble or now playing update
+	EventError = EventType("error")
+)
+
+var EventTypes = []EventType{EventNowPlaying, EventThresholdReached, EventScrobbled, EventSkipped, EventError}
+
+const (
+	DefaultMQTTTopic = "goscrobble"
+	// events are dropped if an output falls this far behind
+	eventBufferSize     = 64
+	eventWebhookTimeout = 10 * time.Second
+)
+
+// Event is the JSON envelope shared by all event outputs.
+type Event struct {
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	Player   string    `json:"player,omitempty"`
+	Scrobble *Scrobble `json:"scrobble,omitempty"`
+	// sinks that saved the scrobble, only set for scrobbled events
+	Sinks []string `json:"sinks,omitempty"`
+	// failing sink and its error, only set for error events
+	Sink  string `json:"sink,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func NewEvent(eventType EventType, player string, scrobble Scrobble) Event {
+	return Event{
+		Type:     eventType,
+		Time:     time.Now(),
+		Player:   player,
+		Scrobble: &scrobble,
+		Sinks:    nil,
+		Sink:     "",
+		Error:    "",
+	}
+}
+
+// EventTypeFlags enables or disables single event types. Event types that are
+// not listed are enabled.
+type EventTypeFlags map[EventType]bool
+
+func (f EventTypeFlags) Enabled(eventType EventType) bool {
+	enabled, ok := f[eventType]
+	return !ok || enabled
+}
+
+// EventOutput sends events to an external system, e.g. a webhook.
+type EventOutput interface {
+	Name() string
+	Send(event Event) error
+	Close() error
+}
+
+// FilteredEventOutput is an output with the event types it receives.
+type FilteredEventOutput struct {
+	Output EventOutput
+	Types  EventTypeFlags
+}
+
+type eventSubscription struct {
+	output EventOutput
+	types  EventTypeFlags
+	events chan Event
+}
+
+// EventBus publishes events to all outputs and subscribers without blocking
+// the main loop. Each output is served by its own goroutine. A nil bus drops
+// all events.
+type EventBus struct {
+	mutex       sync.Mutex
+	outputs     []eventSubscription
+	subscribers map[chan Event]EventTypeFlags
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{mutex: sync.Mutex{}, outputs: nil, subscribers: map[chan Event]EventTypeFlags{}}
+}
+
+// SetOutputs replaces all outputs. Previous outputs are closed once they sent
+// all pending events.
+func (b *EventBus) SetOutputs(outputs []FilteredEventOutput) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, subscription := range b.outputs {
+		close(subscription.events)
+	}
+	b.outputs = nil
+
+	for _, output := range outputs {
+		subscription := eventSubscription{output: output.Output, types: output.Types, events: make(chan Event, eventBufferSize)}
+		b.outputs = append(b.outputs, subscription)
+		go subscription.run()
+	}
+}
+
+func (s eventSubscription) run() {
+	defer CloseLogged(s.output)
+
+	for event := range s.events {
+		if err := s.output.Send(event); err != nil {
+			log.Error().
+				Err(err).
+				Str("output", s.output.Name()).
+				Str("event", string(event.Type)).
+				Msg("error sending event")
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the enabled event types and a
+// function to cancel the subscription.
+func (b *EventBus) Subscribe(types EventTypeFlags) (<-chan Event, func()) {
+	events := make(chan Event, eventBufferSize)
+
+	b.mutex.Lock()
+	b.subscribers[events] = types
+	b.mutex.Unlock()
+
+	return events, func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.subscribers, events)
+	}
+}
+
+func (b *EventBus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, subscription := range b.outputs {
+		if subscription.types.Enabled(event.Type) {
+			sendEvent(subscription.events, event, subscription.output.Name())
+		}
+	}
+	for events, types := range b.subscribers {
+		if types.Enabled(event.Type) {
+			sendEvent(events, event, "subscriber")
+		}
+	}
+}
+
+func sendEvent(events chan Event, event Event, output string) {
+	select {
+	case events <- event:
+	default:
+		log.Warn().
+			Str("output", output).
+			Str("event", string(event.Type)).
+			Msg("event output is too slow, dropping event")
+	}
+}
+
+// Close closes all outputs.
+func (b *EventBus) Close() error {
+	if b == nil {
+		return nil
+	}
+
+	b.SetOutputs(nil)
+	return nil
+}
+
+// SetupEventOutputs returns the configured event outputs. Outputs that cannot
+// be set up are skipped.
+func (c Config) SetupEventOutputs() []FilteredEventOutput {
+	var outputs []FilteredEventOutput
+	if c.Events == nil {
+		return outputs
+	}
+
+	if c.Events.Webhook != nil {
+		outputs = append(outputs, FilteredEventOutput{
+			Output: NewWebhookOutput(c.Events.Webhook.URL, c.Events.Webhook.Token),
+			Types:  c.Events.Webhook.Types,
+		})
+	}
+
+	if c.Events.MQTT != nil {
+		output, err := NewMQTTOutput(*c.Events.MQTT)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("broker", c.Events.MQTT.Broker).
+				Msg("error setting up MQTT event output")
+		} else {
+			outputs = append(outputs, FilteredEventOutput{Output: output, Types: c.Events.MQTT.Types})
+		}
+	}
+
+	return outputs
+}
+
+// SSETypes returns the event types streamed by the HTTP API.
+func (c Config) SSETypes() EventTypeFlags {
+	if c.Events == nil {
+		return nil
+	}
+	return c.Events.SSE
+}
+
+// WebhookOutput posts events as JSON to a URL.
+type WebhookOutput struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+func NewWebhookOutput(url, token string) WebhookOutput {
+	//nolint:exhaustruct
+	return WebhookOutput{URL: url, Token: token, Client: &http.Client{Timeout: eventWebhookTimeout}}
+}
+
+func (o WebhookOutput) Name() string {
+	return "webhook"
+}
+
+func (o WebhookOutput) Send(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, o.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+o.Token)
+	}
+
+	res, err := o.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", res.Status)
+	}
+	return nil
+}
+
+func (o WebhookOutput) Close() error {
+	return nil
+}
+
+func (c *EventsConfig) Validate() []ConfigWarning {
+	var warnings configWarnings
+
+	if c.Webhook != nil && c.Webhook.URL == "" {
+		warnings.add("", "no URL for event webhook specified, disabling it")
+		c.Webhook = nil
+	}
+	if c.MQTT != nil && c.MQTT.Topic == "" {
+		warnings.add("", "no MQTT topic specified, using `goscrobble`")
+		c.MQTT.Topic = DefaultMQTTTopic
+	}
+
+	for _, types := range []EventTypeFlags{c.SSE, c.webhookTypes(), c.mqttTypes()} {
+		for eventType := range types {
+			if !slices.Contains(EventTypes, eventType) {
+				warnings.add(fmt.Sprintf("event=%s", eventType), "unknown event type")
+			}
+		}
+	}
+	return warnings
+}
+
+func (c *EventsConfig) webhookTypes() EventTypeFlags {
+	if c.Webhook == nil {
+		return nil
+	}
+	return c.Webhook.Types
+}
+
+func (c *EventsConfig) mqttTypes() EventTypeFlags {
+	if c.MQTT == nil {
+		return nil
+	}
+	return c.MQTT.Types
+}
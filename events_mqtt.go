@@ -0,0 +1,67 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const mqttTimeout = 10 * time.Second
+
+// MQTTOutput publishes events to `<topic>/<event type>`. The connection is
+// retried in the background, so the broker does not have to be available
+// when the daemon starts.
+type MQTTOutput struct {
+	Client mqtt.Client
+	Topic  string
+}
+
+func NewMQTTOutput(c EventMQTTConfig) (*MQTTOutput, error) {
+	if c.Broker == "" {
+		return nil, errors.New("no MQTT broker specified")
+	}
+
+	options := mqtt.NewClientOptions().
+		AddBroker(c.Broker).
+		SetClientID(c.ClientID).
+		SetUsername(c.Username).
+		SetPassword(c.Password).
+		SetConnectTimeout(mqttTimeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true)
+
+	client := mqtt.NewClient(options)
+	// with SetConnectRetry, the token only completes once connected, so it
+	// is not waited for
+	client.Connect()
+
+	return &MQTTOutput{Client: client, Topic: c.Topic}, nil
+}
+
+func (o *MQTTOutput) Name() string {
+	return "mqtt"
+}
+
+func (o *MQTTOutput) Send(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	token := o.Client.Publish(fmt.Sprintf("%s/%s", o.Topic, event.Type), 1, false, payload)
+	if !token.WaitTimeout(mqttTimeout) {
+		return errors.New("timeout publishing MQTT message")
+	}
+	return token.Error()
+}
+
+func (o *MQTTOutput) Close() error {
+	// wait up to 250ms for pending messages
+	o.Client.Disconnect(250)
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build minimal
+
+package main
+
+func NewMQTTOutput(_ EventMQTTConfig) (EventOutput, error) {
+	return nil, MinimalBuildError{Feature: "MQTT event output"}
+}
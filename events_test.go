@@ -0,0 +1,138 @@
+package main_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func receivedEventTypes(events <-chan main.Event) []main.EventType {
+	var types []main.EventType
+	for {
+		select {
+		case event := <-events:
+			types = append(types, event.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func TestEventTypeFlags(t *testing.T) {
+	flags := main.EventTypeFlags{main.EventNowPlaying: false, main.EventScrobbled: true}
+	require.False(t, flags.Enabled(main.EventNowPlaying))
+	require.True(t, flags.Enabled(main.EventScrobbled))
+	require.True(t, flags.Enabled(main.EventSkipped))
+	require.True(t, main.EventTypeFlags(nil).Enabled(main.EventError))
+}
+
+func TestLoopEvents(t *testing.T) {
+	bus := main.NewEventBus()
+	events, cancel := bus.Subscribe(nil)
+	defer cancel()
+
+	state := main.NewLoopState()
+	options := replayOptions()
+	options.MinPlaybackDuration = 60
+	options.Events = bus
+	notifier := FakeNotifier{}
+	sink := &FakeSink{}
+	failingSink := &FakeSink{Error: true}
+	sinks := []main.Sink{sink, failingSink}
+
+	run := func(status main.PlaybackStatus) {
+		source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+		main.RunMainLoopOnce(state, options, []main.Source{source}, sinks, notifier.SendNotification)
+	}
+
+	started := defaultPlaybackStatus
+	started.Position = 0
+	run(started)
+	require.Equal(t, []main.EventType{main.EventNowPlaying, main.EventError}, receivedEventTypes(events))
+
+	playing := started
+	playing.Position = 90 * time.Second
+	run(playing)
+	require.Equal(t, []main.EventType{main.EventThresholdReached, main.EventError, main.EventScrobbled}, receivedEventTypes(events))
+
+	next := started
+	next.Track = "Every You Every Me"
+	run(next)
+	require.Equal(t, []main.EventType{main.EventNowPlaying, main.EventError}, receivedEventTypes(events))
+
+	last := started
+	last.Track = "Pure Morning"
+	run(last)
+	require.Equal(t, []main.EventType{main.EventSkipped, main.EventNowPlaying, main.EventError}, receivedEventTypes(events))
+}
+
+func TestEventBusOutputs(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan main.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var event main.Event
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		received <- r
+		bodies <- event
+	}))
+	defer server.Close()
+
+	bus := main.NewEventBus()
+	bus.SetOutputs([]main.FilteredEventOutput{{
+		Output: main.NewWebhookOutput(server.URL, "secret"),
+		Types:  main.EventTypeFlags{main.EventNowPlaying: false},
+	}})
+	defer bus.Close()
+
+	bus.Publish(main.NewEvent(main.EventNowPlaying, "player", defaultScrobble))
+	bus.Publish(main.NewEvent(main.EventSkipped, "player", defaultScrobble))
+
+	select {
+	case request := <-received:
+		require.Equal(t, "Bearer secret", request.Header.Get("Authorization"))
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "webhook was not called")
+	}
+
+	event := <-bodies
+	require.Equal(t, main.EventSkipped, event.Type)
+	require.Equal(t, "player", event.Player)
+	require.Equal(t, defaultScrobble.Track, event.Scrobble.Track)
+}
+
+func TestAPIEvents(t *testing.T) {
+	bus := main.NewEventBus()
+	api := main.NewAPIServer("secret", main.NewControlServer())
+	api.StreamEvents(bus, main.EventTypeFlags{main.EventError: false})
+
+	server := httptest.NewServer(api.Handler())
+	defer server.Close()
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/api/events", nil)
+	require.NoError(t, err)
+	request.Header.Set("Authorization", "Bearer secret")
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer main.CloseLogged(response.Body)
+	require.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	bus.Publish(main.NewEvent(main.EventError, "player", defaultScrobble))
+	bus.Publish(main.NewEvent(main.EventScrobbled, "player", defaultScrobble))
+
+	reader := bufio.NewReader(response.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "event: scrobbled\n", line)
+
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, `data: {"type":"scrobbled"`))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type ExportFormat string
+
+const (
+	// the format of CSV sinks, so an export can be used as a sink file
+	ExportCSV          = ExportFormat("csv")
+	ExportJSON         = ExportFormat("json")
+	ExportJSONLines    = ExportFormat("jsonl")
+	ExportScrobblerLog = ExportFormat("scrobbler-log")
+)
+
+var ExportFormats = []ExportFormat{ExportCSV, ExportJSON, ExportJSONLines, ExportScrobblerLog}
+
+// ExportScrobbles writes the scrobbles to w in the given format.
+func ExportScrobbles(w io.Writer, format ExportFormat, scrobbles []Scrobble) error {
+	switch format {
+	case ExportCSV:
+		writer := csv.NewWriter(w)
+		for _, scrobble := range scrobbles {
+			if err := writer.Write(scrobble.ToStringSlice()); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case ExportJSON:
+		if scrobbles == nil {
+			scrobbles = []Scrobble{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(scrobbles)
+	case ExportJSONLines:
+		encoder := json.NewEncoder(w)
+		for _, scrobble := range scrobbles {
+			if err := encoder.Encode(scrobble); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ExportScrobblerLog:
+		return exportScrobblerLog(w, scrobbles)
+	default:
+		return fmt.Errorf("invalid export format: %s", format)
+	}
+}
+
+// exportScrobblerLog writes the scrobbles in the Audioscrobbler 1.1 format
+// read by ParseScrobblerLog, with UTC timestamps.
+func exportScrobblerLog(w io.Writer, scrobbles []Scrobble) error {
+	// errors of the buffered writer are returned by Flush
+	writer := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(writer, "%s1.1\n%sUTC\n#CLIENT/goscrobble\n", scrobblerLogHeader, scrobblerLogTZ)
+
+	for _, scrobble := range scrobbles {
+		columns := []string{
+			scrobblerLogField(scrobble.JoinArtists()),
+			scrobblerLogField(scrobble.Album),
+			scrobblerLogField(scrobble.Track),
+			// track number
+			"",
+			strconv.FormatInt(int64(scrobble.Duration.Seconds()), 10),
+			scrobblerLogListened,
+			strconv.FormatInt(scrobble.Timestamp.Unix(), 10),
+			// MusicBrainz ID
+			"",
+		}
+		_, _ = fmt.Fprintln(writer, strings.Join(columns, "\t"))
+	}
+
+	return writer.Flush()
+}
+
+// scrobblerLogField replaces tabs and line breaks, the format has no quoting.
+func scrobblerLogField(value string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(value)
+}
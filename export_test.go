@@ -0,0 +1,56 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportScrobbles(t *testing.T) {
+	scrobbles := []main.Scrobble{defaultScrobble, defaultScrobble}
+
+	var output bytes.Buffer
+	require.NoError(t, main.ExportScrobbles(&output, main.ExportCSV, scrobbles))
+	line, _, _ := strings.Cut(output.String(), "\n")
+	parsed, err := main.ScrobbleFromCSV(line)
+	require.NoError(t, err)
+	require.Equal(t, defaultScrobble.Track, parsed.Track)
+
+	output.Reset()
+	require.NoError(t, main.ExportScrobbles(&output, main.ExportJSON, scrobbles))
+	var decoded []main.Scrobble
+	require.NoError(t, json.Unmarshal(output.Bytes(), &decoded))
+	require.Len(t, decoded, 2)
+	require.Equal(t, defaultScrobble.Track, decoded[0].Track)
+
+	output.Reset()
+	require.NoError(t, main.ExportScrobbles(&output, main.ExportJSON, nil))
+	require.Equal(t, "[]\n", output.String())
+
+	output.Reset()
+	require.NoError(t, main.ExportScrobbles(&output, main.ExportJSONLines, scrobbles))
+	require.Equal(t, 2, strings.Count(output.String(), "\n"))
+
+	require.Error(t, main.ExportScrobbles(&output, main.ExportFormat("xml"), scrobbles))
+}
+
+func TestExportScrobblerLog(t *testing.T) {
+	scrobble := defaultScrobble
+	scrobble.Track = "Pure\tMorning"
+
+	var output bytes.Buffer
+	require.NoError(t, main.ExportScrobbles(&output, main.ExportScrobblerLog, []main.Scrobble{scrobble}))
+
+	parsed, err := main.ParseScrobblerLog(output.Bytes())
+	require.NoError(t, err)
+	require.Len(t, parsed, 1)
+	require.Equal(t, scrobble.JoinArtists(), parsed[0].JoinArtists())
+	require.Equal(t, "Pure Morning", parsed[0].Track)
+	require.Equal(t, scrobble.Album, parsed[0].Album)
+	require.Equal(t, scrobble.Duration, parsed[0].Duration)
+	require.True(t, scrobble.Timestamp.Equal(parsed[0].Timestamp))
+}
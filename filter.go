@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FilterOptions decide which scrobbles of a source are used, or which
+// scrobbles are sent to a sink.
+type FilterOptions struct {
+	// match/replace expressions applied before the filters, only for this
+	// source or sink
+	Regexes []RegexReplace `toml:"regexes"`
+	// scrobbles matching any of these rules are dropped
+	Blacklist []FilterRule `toml:"blacklist"`
+	// if not empty, only scrobbles matching one of these rules are kept
+	Whitelist []FilterRule `toml:"whitelist"`
+}
+
+// MatchMode controls how the pattern of a FilterRule is compared.
+type MatchMode string
+
+const (
+	// Go regular expression (default)
+	MatchRegex = MatchMode("regex")
+	// whole field, case-insensitive
+	MatchExact = MatchMode("exact")
+	// part of the field, case-insensitive
+	MatchSubstring = MatchMode("substring")
+	// shell pattern with `*` and `?` matching the whole field, case-insensitive
+	MatchGlob = MatchMode("glob")
+)
+
+// FilterRule is a pattern matched against the fields of a scrobble, e.g. in
+// the blacklist of a sink.
+type FilterRule struct {
+	Match  string    `toml:"match"`
+	Mode   MatchMode `toml:"mode"`
+	Artist bool      `toml:"artist"`
+	Track  bool      `toml:"track"`
+	Album  bool      `toml:"album"`
+	// the player name or identity (e.g., `firefox`), only known to sources
+	// and the global blacklist and whitelist
+	Player bool `toml:"player"`
+}
+
+type ParsedFilterRule struct {
+	Match  *regexp.Regexp
+	Artist bool
+	Track  bool
+	Album  bool
+	Player bool
+}
+
+// Expression returns the regular expression a rule is compiled to.
+func (r FilterRule) Expression() (string, error) {
+	switch r.Mode {
+	case "", MatchRegex:
+		return r.Match, nil
+	case MatchExact:
+		return "(?i)^" + regexp.QuoteMeta(r.Match) + "$", nil
+	case MatchSubstring:
+		return "(?i)" + regexp.QuoteMeta(r.Match), nil
+	case MatchGlob:
+		var builder strings.Builder
+		builder.WriteString("(?i)^")
+		for _, char := range r.Match {
+			switch char {
+			case '*':
+				builder.WriteString(".*")
+			case '?':
+				builder.WriteString(".")
+			default:
+				builder.WriteString(regexp.QuoteMeta(string(char)))
+			}
+		}
+		builder.WriteString("$")
+		return builder.String(), nil
+	default:
+		return "", fmt.Errorf("invalid mode `%s`", r.Mode)
+	}
+}
+
+// Matches reports whether the pattern matches any of the enabled fields. The
+// artist matches any of the artists of a track or all of them joined. The
+// player is empty for sinks, so it is not matched.
+func (r ParsedFilterRule) Matches(player string, scrobble Scrobble) bool {
+	if r.MatchesPlayer(player) {
+		return true
+	}
+	if r.Artist && (r.Match.MatchString(scrobble.JoinArtists()) || slices.ContainsFunc(scrobble.Artists, r.Match.MatchString)) {
+		return true
+	}
+	if r.Track && r.Match.MatchString(scrobble.Track) {
+		return true
+	}
+	return r.Album && r.Match.MatchString(scrobble.Album)
+}
+
+// MatchesPlayer reports whether the pattern matches the name of a player
+// (e.g., `dbus:org.mpris.MediaPlayer2.firefox.instance_1_84`, or the name
+// reported to a source without the prefix) or its identity (e.g., `firefox`).
+func (r ParsedFilterRule) MatchesPlayer(player string) bool {
+	if !r.Player || player == "" {
+		return false
+	}
+	source, _, _ := strings.Cut(player, ":")
+	return r.Match.MatchString(player) || r.Match.MatchString(PlayerIdentity(player, source))
+}
+
+// ScrobbleFilter decides which scrobbles of a single source are used or which
+// scrobbles are sent to a single sink, after applying its match/replace
+// expressions.
+type ScrobbleFilter struct {
+	Regexes []ParsedRegexReplace
+	// scrobbles matching any of these rules are dropped
+	Blacklist []ParsedFilterRule
+	// if not empty, scrobbles matching none of these rules are dropped
+	Whitelist []ParsedFilterRule
+}
+
+func ParseScrobbleFilter(options FilterOptions) (ScrobbleFilter, error) {
+	var filter ScrobbleFilter
+
+	for _, r := range options.Regexes {
+		match, err := regexp.Compile(r.Match)
+		if err != nil {
+			return ScrobbleFilter{}, fmt.Errorf("invalid match/replace expression `%s`: %s", r.Match, err.Error())
+		}
+		filter.Regexes = append(filter.Regexes, ParsedRegexReplace{
+			Match:   match,
+			Replace: r.Replace,
+			Artist:  r.Artist,
+			Track:   r.Track,
+			Album:   r.Album,
+		})
+	}
+
+	var err error
+	if filter.Blacklist, err = parseFilterRules("blacklist", options.Blacklist); err != nil {
+		return ScrobbleFilter{}, err
+	}
+	if filter.Whitelist, err = parseFilterRules("whitelist", options.Whitelist); err != nil {
+		return ScrobbleFilter{}, err
+	}
+
+	return filter, nil
+}
+
+func parseFilterRules(name string, rules []FilterRule) ([]ParsedFilterRule, error) {
+	var parsed []ParsedFilterRule
+	for _, r := range rules {
+		expression, err := r.Expression()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry `%s`: %s", name, r.Match, err.Error())
+		}
+		match, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s expression `%s`: %s", name, r.Match, err.Error())
+		}
+		parsed = append(parsed, ParsedFilterRule{Match: match, Artist: r.Artist, Track: r.Track, Album: r.Album, Player: r.Player})
+	}
+	return parsed, nil
+}
+
+func (o FilterOptions) empty() bool {
+	return len(o.Regexes) == 0 && len(o.Blacklist) == 0 && len(o.Whitelist) == 0
+}
+
+// Apply returns the scrobble with the match/replace expressions applied and
+// whether it passes the blacklist and whitelist. The player is empty for sinks.
+func (f ScrobbleFilter) Apply(player string, scrobble Scrobble) (Scrobble, bool) {
+	scrobble.RegexReplace(f.Regexes)
+
+	if AnyRuleMatches(f.Blacklist, player, scrobble) {
+		return scrobble, false
+	}
+	return scrobble, len(f.Whitelist) == 0 || AnyRuleMatches(f.Whitelist, player, scrobble)
+}
+
+// ParseBlacklist compiles the global blacklist. Like match/replace
+// expressions, invalid entries are logged and left out.
+func (c Config) ParseBlacklist() []ParsedFilterRule {
+	return parseGlobalFilterRules("blacklist", c.Blacklist)
+}
+
+// ParseWhitelist compiles the global whitelist. Like match/replace
+// expressions, invalid entries are logged and left out.
+func (c Config) ParseWhitelist() []ParsedFilterRule {
+	return parseGlobalFilterRules("whitelist", c.Whitelist)
+}
+
+func parseGlobalFilterRules(name string, rules []FilterRule) []ParsedFilterRule {
+	var parsed []ParsedFilterRule
+	for _, r := range rules {
+		rule, err := parseFilterRules(name, []FilterRule{r})
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("expression", r.Match).
+				Msg("error compiling " + name + " entry")
+			continue
+		}
+		parsed = append(parsed, rule...)
+	}
+	return parsed
+}
+
+// IsPlayerBlacklisted reports whether any player rule of the blacklist
+// matches the player. Sources use it to skip players before reading them,
+// rules for other fields are checked by the main loop.
+func IsPlayerBlacklisted(blacklist []ParsedFilterRule, player string) bool {
+	return slices.ContainsFunc(blacklist, func(r ParsedFilterRule) bool { return r.MatchesPlayer(player) })
+}
+
+// AnyRuleMatches reports whether any of the rules matches the track of a
+// player, e.g. to check the global blacklist.
+func AnyRuleMatches(rules []ParsedFilterRule, player string, scrobble Scrobble) bool {
+	return slices.ContainsFunc(rules, func(r ParsedFilterRule) bool { return r.Matches(player, scrobble) })
+}
+
+// IsWhitelisted reports whether the track of a player matches any of the
+// rules of the global whitelist. Without a whitelist, all tracks are used.
+func IsWhitelisted(whitelist []ParsedFilterRule, player string, scrobble Scrobble) bool {
+	return len(whitelist) == 0 || AnyRuleMatches(whitelist, player, scrobble)
+}
+
+// FilterSink applies the match/replace expressions, blacklist, and whitelist of
+// a sink. Scrobbles and now playing updates that do not pass the filter are
+// dropped without an error, so they are not retried or queued.
+type FilterSink struct {
+	Sink
+	Filter ScrobbleFilter
+}
+
+// WrapSinkFilter returns the sink unchanged if no filter is configured.
+func WrapSinkFilter(sink Sink, options FilterOptions) (Sink, error) {
+	if options.empty() {
+		return sink, nil
+	}
+
+	filter, err := ParseScrobbleFilter(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return FilterSink{Sink: sink, Filter: filter}, nil
+}
+
+func (s FilterSink) Unwrap() Sink {
+	return s.Sink
+}
+
+func (s FilterSink) NowPlaying(scrobble Scrobble) error {
+	filtered, ok := s.Filter.Apply("", scrobble)
+	if !ok {
+		log.Debug().
+			Str("sink", s.Name()).
+			Interface("scrobble", filtered).
+			Msg("not updating now playing status, track is filtered for this sink")
+		return nil
+	}
+	return s.Sink.NowPlaying(filtered)
+}
+
+func (s FilterSink) Scrobble(scrobble Scrobble) error {
+	filtered, ok := s.Filter.Apply("", scrobble)
+	if !ok {
+		log.Info().
+			Str("sink", s.Name()).
+			Interface("scrobble", filtered).
+			Msg("not saving scrobble, track is filtered for this sink")
+		return nil
+	}
+	return s.Sink.Scrobble(filtered)
+}
+
+// FilterSource applies the match/replace expressions, blacklist, and whitelist
+// of a source to the raw playback status of its players, before the global
+// match/replace expressions.
+type FilterSource struct {
+	Source
+	Filter ScrobbleFilter
+}
+
+// WrapSourceFilter returns the source unchanged if no filter is configured.
+func WrapSourceFilter(source Source, options FilterOptions) (Source, error) {
+	if options.empty() {
+		return source, nil
+	}
+
+	filter, err := ParseScrobbleFilter(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return FilterSource{Source: source, Filter: filter}, nil
+}
+
+func (s FilterSource) GetInfo(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) (map[string]PlaybackStatus, error) {
+	statuses, err := s.Source.GetInfo(playerBlacklist, nil)
+
+	filtered := map[string]PlaybackStatus{}
+	for player, status := range statuses {
+		scrobble, ok := s.Filter.Apply(player, status.Scrobble)
+		if !ok {
+			continue
+		}
+		status.Scrobble = scrobble
+		status.RegexReplace(regexes)
+		filtered[player] = status
+	}
+
+	return filtered, err
+}
+
+// ReceivedScrobbles filters the scrobbles received by the wrapped source.
+// Sources that do not receive scrobbles return none.
+func (s FilterSource) ReceivedScrobbles(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) map[string][]Scrobble {
+	scrobbleSource, ok := s.Source.(ScrobbleSource)
+	if !ok {
+		return nil
+	}
+
+	filtered := map[string][]Scrobble{}
+	for player, scrobbles := range scrobbleSource.ReceivedScrobbles(playerBlacklist, nil) {
+		for _, scrobble := range scrobbles {
+			scrobble, ok := s.Filter.Apply(player, scrobble)
+			if !ok {
+				log.Info().
+					Str("source", s.Name()).
+					Str("player", player).
+					Interface("scrobble", scrobble).
+					Msg("ignoring received scrobble, track is filtered for this source")
+				continue
+			}
+			scrobble.RegexReplace(regexes)
+			filtered[player] = append(filtered[player], scrobble)
+		}
+	}
+	return filtered
+}
+
+func (s FilterSource) Close() error {
+	if closer, ok := s.Source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
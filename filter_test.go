@@ -0,0 +1,238 @@
+package main_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterSink(t *testing.T) {
+	fakeSink := &FakeSink{}
+
+	config := main.DefaultConfig
+	sink, err := config.WrapSink(fakeSink, main.SinkOptions{FilterOptions: main.FilterOptions{
+		Regexes: []main.RegexReplace{{Match: ` - Remastered$`, Replace: "", Track: true}},
+		Blacklist: []main.FilterRule{
+			{Match: `(?i)podcast`, Album: true},
+			{Match: `^Guilty Pleasure$`, Track: true},
+			// sinks do not know the player
+			{Match: `.*`, Player: true},
+		},
+	}})
+	require.NoError(t, err)
+
+	podcast := defaultScrobble
+	podcast.Album = "Song Exploder (Podcast)"
+	require.NoError(t, sink.NowPlaying(podcast))
+	require.NoError(t, sink.Scrobble(podcast))
+	require.Empty(t, fakeSink.NowPlayingLog)
+	require.Empty(t, fakeSink.ScrobbleLog)
+
+	// the blacklist is matched after the match/replace expressions
+	guilty := defaultScrobble
+	guilty.Track = "Guilty Pleasure - Remastered"
+	require.NoError(t, sink.Scrobble(guilty))
+	require.Empty(t, fakeSink.ScrobbleLog)
+
+	remastered := defaultScrobble
+	remastered.Track = "Without You I'm Nothing - Remastered"
+	require.NoError(t, sink.Scrobble(remastered))
+	require.Equal(t, []main.Scrobble{defaultScrobble}, fakeSink.ScrobbleLog)
+
+	require.Equal(t, fakeSink, main.UnwrapSink(sink))
+
+	_, err = config.WrapSink(fakeSink, main.SinkOptions{FilterOptions: main.FilterOptions{
+		Whitelist: []main.FilterRule{{Match: `(`, Track: true}},
+	}})
+	require.ErrorContains(t, err, "whitelist")
+}
+
+func TestScrobbleFilter(t *testing.T) {
+	filter, err := main.ParseScrobbleFilter(main.FilterOptions{
+		Blacklist: []main.FilterRule{{Match: `^David Bowie$`, Artist: true}},
+		Whitelist: []main.FilterRule{
+			{Match: `^Placebo$`, Artist: true},
+			{Match: `Dream`, Album: true},
+		},
+	})
+	require.NoError(t, err)
+
+	other := main.Scrobble{Artists: []string{"Muse"}, Track: "Hysteria", Album: "Absolution"}
+	_, ok := filter.Apply("", other)
+	require.False(t, ok)
+
+	// any of the artists matches
+	_, ok = filter.Apply("", main.Scrobble{Artists: []string{"Placebo"}, Track: "Pure Morning", Album: "Without You I'm Nothing"})
+	require.True(t, ok)
+	_, ok = filter.Apply("", defaultScrobble)
+	require.False(t, ok)
+
+	other.Album = "Dreams"
+	_, ok = filter.Apply("", other)
+	require.True(t, ok)
+
+	// rules without fields never match
+	filter, err = main.ParseScrobbleFilter(main.FilterOptions{Blacklist: []main.FilterRule{{Match: `.*`}}})
+	require.NoError(t, err)
+	_, ok = filter.Apply("", defaultScrobble)
+	require.True(t, ok)
+}
+
+func TestFilterSource(t *testing.T) {
+	source := main.NewWebhookSource("secret", main.TimestampTrustDaemon)
+	post := func(path, body string) {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer secret")
+		recorder := httptest.NewRecorder()
+		source.Handler().ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusNoContent, recorder.Code)
+	}
+
+	filtered, err := main.WrapSourceFilter(source, main.FilterOptions{
+		Regexes:   []main.RegexReplace{{Match: `^Pl\.$`, Replace: "Placebo", Artist: true}},
+		Blacklist: []main.FilterRule{{Match: `^webhook:browser$`, Player: true}},
+		Whitelist: []main.FilterRule{{Match: `^Placebo$`, Artist: true}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "webhook", filtered.Name())
+
+	post("/api/now-playing", `{"player":"phone","artists":["Pl."],"track":"Meds"}`)
+	post("/api/now-playing", `{"player":"browser","artists":["Placebo"],"track":"Meds"}`)
+	post("/api/now-playing", `{"player":"tablet","artists":["Muse"],"track":"Hysteria"}`)
+
+	// the filters of the source are applied before the global expressions
+	regexes := []main.ParsedRegexReplace{{Match: regexp.MustCompile(`^Placebo$`), Replace: "PLACEBO", Artist: true}}
+	status, err := filtered.GetInfo(nil, regexes)
+	require.NoError(t, err)
+	require.Len(t, status, 1)
+	require.Equal(t, []string{"PLACEBO"}, status["webhook:phone"].Artists)
+
+	post("/api/scrobble", `{"player":"browser","artists":["Placebo"],"track":"Meds"}`)
+	post("/api/scrobble", `{"player":"phone","artists":["Placebo"],"track":"Meds"}`)
+
+	scrobbleSource, ok := filtered.(main.ScrobbleSource)
+	require.True(t, ok)
+	received := scrobbleSource.ReceivedScrobbles(nil, nil)
+	require.Len(t, received, 1)
+	require.Len(t, received["webhook:phone"], 1)
+
+	closer, ok := filtered.(io.Closer)
+	require.True(t, ok)
+	require.NoError(t, closer.Close())
+
+	unchanged, err := main.WrapSourceFilter(source, main.FilterOptions{})
+	require.NoError(t, err)
+	require.Equal(t, main.Source(source), unchanged)
+}
+
+func TestLintFilters(t *testing.T) {
+	config := main.DefaultConfig
+	config.Sinks = main.SinksConfig{CSV: map[string]main.CSVConfig{"default": {
+		Filename: "scrobbles.csv",
+		SinkOptions: main.SinkOptions{FilterOptions: main.FilterOptions{
+			Regexes:   []main.RegexReplace{{Match: `x`}},
+			Blacklist: []main.FilterRule{{Match: `a^b`, Track: true}},
+			Whitelist: []main.FilterRule{{Match: `Placebo`, Artist: true}, {Match: `firefox`, Player: true}},
+		}},
+	}}}
+	config.Sources = main.SourcesConfig{Roon: &main.RoonConfig{FilterOptions: main.FilterOptions{
+		Whitelist: []main.FilterRule{{Match: `^Living Room$`, Player: true}, {Match: `Placebo`}},
+	}}}
+	config.PollRate = 10
+
+	require.Equal(t, []string{
+		"dead-regex sinks.csv.default.regexes[0]",
+		"dead-regex sinks.csv.default.blacklist[0]",
+		"dead-regex sinks.csv.default.whitelist[1]",
+		"dead-regex sources.roon.whitelist[1]",
+	}, lintRules(config.Lint(false)))
+}
+
+func TestWhitelistOnlyMode(t *testing.T) {
+	config := main.DefaultConfig
+	config.Whitelist = []main.FilterRule{
+		{Match: `^Placebo$`, Artist: true},
+		{Match: `^fake player$`, Player: true},
+		{Match: `(`, Album: true},
+	}
+	whitelist := config.ParseWhitelist()
+	require.Len(t, whitelist, 2)
+
+	other := main.Scrobble{Artists: []string{"Muse"}, Track: "Hysteria", Album: "Absolution"}
+	require.True(t, main.IsWhitelisted(whitelist, "", defaultScrobble))
+	require.True(t, main.IsWhitelisted(whitelist, "fake player", other))
+	require.False(t, main.IsWhitelisted(whitelist, "spotify", other))
+	require.True(t, main.IsWhitelisted(nil, "spotify", other))
+
+	config.Whitelist = []main.FilterRule{{Match: `^Muse$`, Artist: true}}
+
+	state := main.NewLoopState()
+	source := &FakeSource{Empty: false, Error: false, PlaybackStatus: defaultPlaybackStatus}
+	sink := &FakeSink{}
+
+	main.RunMainLoopOnce(state, config.LoopOptions(), []main.Source{source}, []main.Sink{sink}, (&FakeNotifier{}).SendNotification)
+	require.Empty(t, state.CurrentlyPlaying)
+	require.Empty(t, sink.NowPlayingLog)
+
+	source.PlaybackStatus.Artists = []string{"Muse"}
+	main.RunMainLoopOnce(state, config.LoopOptions(), []main.Source{source}, []main.Sink{sink}, (&FakeNotifier{}).SendNotification)
+	require.Len(t, state.CurrentlyPlaying, 1)
+	require.Len(t, sink.NowPlayingLog, 1)
+}
+
+func TestFilterRuleModes(t *testing.T) {
+	parse := func(rule main.FilterRule) main.ParsedFilterRule {
+		filter, err := main.ParseScrobbleFilter(main.FilterOptions{Blacklist: []main.FilterRule{rule}})
+		require.NoError(t, err)
+		return filter.Blacklist[0]
+	}
+
+	exact := parse(main.FilterRule{Match: "placebo", Mode: main.MatchExact, Artist: true})
+	require.True(t, exact.Matches("", defaultScrobble))
+	require.False(t, exact.Matches("", main.Scrobble{Artists: []string{"Placebo World"}}))
+
+	substring := parse(main.FilterRule{Match: "(podcast)", Mode: main.MatchSubstring, Album: true})
+	require.True(t, substring.Matches("", main.Scrobble{Album: "Song Exploder (Podcast)"}))
+	require.False(t, substring.Matches("", defaultScrobble))
+
+	glob := parse(main.FilterRule{Match: "without you*", Mode: main.MatchGlob, Track: true})
+	require.True(t, glob.Matches("", defaultScrobble))
+	require.False(t, glob.Matches("", main.Scrobble{Track: "Live: Without You I'm Nothing"}))
+
+	// player rules match the name of the player or its identity
+	player := parse(main.FilterRule{Match: "firefox", Mode: main.MatchExact, Player: true})
+	require.True(t, player.MatchesPlayer("dbus:org.mpris.MediaPlayer2.firefox.instance_1_84"))
+	require.True(t, player.MatchesPlayer("org.mpris.MediaPlayer2.firefox.instance_1_84"))
+	require.False(t, player.MatchesPlayer("dbus:org.mpris.MediaPlayer2.chromium.instance_2_1"))
+	require.True(t, main.IsPlayerBlacklisted([]main.ParsedFilterRule{player}, "Firefox"))
+
+	_, err := main.ParseScrobbleFilter(main.FilterOptions{Blacklist: []main.FilterRule{{Match: "x", Mode: "fuzzy", Track: true}}})
+	require.ErrorContains(t, err, "invalid mode")
+}
+
+func TestGlobalBlacklist(t *testing.T) {
+	config := main.DefaultConfig
+	config.Blacklist = []main.FilterRule{
+		{Match: "placebo", Mode: main.MatchExact, Artist: true},
+		{Match: "x", Mode: "fuzzy", Track: true},
+	}
+	require.Len(t, config.ParseBlacklist(), 1)
+
+	state := main.NewLoopState()
+	source := &FakeSource{Empty: false, Error: false, PlaybackStatus: defaultPlaybackStatus}
+	sink := &FakeSink{}
+
+	main.RunMainLoopOnce(state, config.LoopOptions(), []main.Source{source}, []main.Sink{sink}, (&FakeNotifier{}).SendNotification)
+	require.Empty(t, state.CurrentlyPlaying)
+	require.Empty(t, sink.NowPlayingLog)
+
+	source.PlaybackStatus.Artists = []string{"Muse"}
+	main.RunMainLoopOnce(state, config.LoopOptions(), []main.Source{source}, []main.Sink{sink}, (&FakeNotifier{}).SendNotification)
+	require.Len(t, sink.NowPlayingLog, 1)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ParsedPlayerGroup is a set of players (e.g., rooms of a multi-room system)
+// that may play the same track at the same time. Overlapping plays of the same
+// track within a group are stitched into a single scrobble.
+type ParsedPlayerGroup struct {
+	Name    string
+	Players []*regexp.Regexp
+	Window  time.Duration
+}
+
+type GroupScrobble struct {
+	Player string
+	Track  string
+	Time   time.Time
+	Window time.Duration
+}
+
+func (c Config) ParsePlayerGroups() []ParsedPlayerGroup {
+	var parsed []ParsedPlayerGroup
+
+	for _, group := range c.PlayerGroups {
+		var players []*regexp.Regexp
+		for _, expression := range group.Players {
+			compiled, err := regexp.Compile(expression)
+			if err != nil {
+				log.Warn().
+					Err(err).
+					Str("group", group.Name).
+					Str("expression", expression).
+					Msg("error compiling player group expression")
+				continue
+			}
+			players = append(players, compiled)
+		}
+
+		parsed = append(parsed, ParsedPlayerGroup{
+			Name:    group.Name,
+			Players: players,
+			Window:  time.Duration(group.Window) * time.Second,
+		})
+	}
+
+	log.Debug().Msg("parsed player groups")
+	return parsed
+}
+
+func (g ParsedPlayerGroup) Contains(player string) bool {
+	return IsBlacklisted(g.Players, player)
+}
+
+// StitchScrobble records a scrobble for all groups the player belongs to. It
+// returns the name of the group if another player in that group already
+// scrobbled the same track within the group's window (or the track duration,
+// if no window is configured).
+func (s *LoopState) StitchScrobble(
+	groups []ParsedPlayerGroup,
+	player string,
+	scrobble Scrobble,
+	now time.Time,
+) (string, bool) {
+	track := fmt.Sprintf("%s\x00%s", scrobble.JoinArtists(), scrobble.Track)
+
+	stitched := ""
+	for _, group := range groups {
+		if !group.Contains(player) {
+			continue
+		}
+
+		var recent []GroupScrobble
+		for _, entry := range s.GroupScrobbles[group.Name] {
+			if now.Sub(entry.Time) > entry.Window {
+				continue
+			}
+			recent = append(recent, entry)
+
+			if entry.Track == track && entry.Player != player && stitched == "" {
+				stitched = group.Name
+			}
+		}
+
+		window := group.Window
+		if window <= 0 {
+			window = scrobble.Duration
+		}
+
+		s.GroupScrobbles[group.Name] = append(recent, GroupScrobble{
+			Player: player,
+			Track:  track,
+			Time:   now,
+			Window: window,
+		})
+	}
+
+	return stitched, stitched != ""
+}
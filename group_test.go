@@ -0,0 +1,34 @@
+package main_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStitchScrobble(t *testing.T) {
+	state := main.NewLoopState()
+	groups := []main.ParsedPlayerGroup{{
+		Name:    "house",
+		Players: []*regexp.Regexp{regexp.MustCompile("^upnp:")},
+		Window:  0,
+	}}
+	now := time.Now()
+
+	_, ok := state.StitchScrobble(groups, "upnp:Kitchen", defaultScrobble, now)
+	require.False(t, ok)
+
+	group, ok := state.StitchScrobble(groups, "upnp:Living Room", defaultScrobble, now.Add(time.Minute))
+	require.True(t, ok)
+	require.Equal(t, "house", group)
+
+	_, ok = state.StitchScrobble(groups, "dbus:org.mpris.MediaPlayer2.spotify", defaultScrobble, now)
+	require.False(t, ok)
+
+	// the kitchen entry is older than the track duration
+	_, ok = state.StitchScrobble(groups, "upnp:Bedroom", defaultScrobble, now.Add(time.Minute+defaultScrobble.Duration+time.Second))
+	require.False(t, ok)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// GuestRouting controls whether a sink receives scrobbles in guest mode.
+type GuestRouting string
+
+const (
+	// only used outside of guest mode (default)
+	GuestRoutingSkip = GuestRouting("skip")
+	// used both in and outside of guest mode
+	GuestRoutingAlso = GuestRouting("also")
+	// only used in guest mode
+	GuestRoutingOnly = GuestRouting("only")
+)
+
+// GuestSink marks a sink with its guest mode routing. It does not change the
+// behavior of the wrapped sink.
+type GuestSink struct {
+	Sink
+	Routing GuestRouting
+}
+
+func (s GuestSink) Unwrap() Sink {
+	return s.Sink
+}
+
+func WrapSinkGuest(sink Sink, routing GuestRouting) Sink {
+	switch routing {
+	case "", GuestRoutingSkip:
+		return sink
+	case GuestRoutingAlso, GuestRoutingOnly:
+		return GuestSink{Sink: sink, Routing: routing}
+	default:
+		log.Warn().
+			Str("sink", sink.Name()).
+			Str("guest", string(routing)).
+			Msg("invalid guest mode routing, using `skip`")
+		return sink
+	}
+}
+
+func guestRouting(sink Sink) GuestRouting {
+	for {
+		if guestSink, ok := sink.(GuestSink); ok {
+			return guestSink.Routing
+		}
+
+		wrapped, ok := sink.(WrappedSink)
+		if !ok {
+			return GuestRoutingSkip
+		}
+		sink = wrapped.Unwrap()
+	}
+}
+
+// RouteSinks returns the sinks that receive scrobbles in or outside of guest
+// mode.
+func RouteSinks(sinks []Sink, guest bool) []Sink {
+	var routed []Sink
+	for _, sink := range sinks {
+		routing := guestRouting(sink)
+		if (guest && routing != GuestRoutingSkip) || (!guest && routing != GuestRoutingOnly) {
+			routed = append(routed, sink)
+		}
+	}
+	return routed
+}
+
+// GuestMode is toggled using the control socket, optionally for a limited
+// time.
+type GuestMode struct {
+	mutex   sync.Mutex
+	enabled bool
+	// zero if guest mode does not end automatically
+	until time.Time
+}
+
+// Enable turns on guest mode. If duration is positive, it is turned off
+// automatically after that time.
+func (g *GuestMode) Enable(now time.Time, duration time.Duration) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.enabled = true
+	g.until = time.Time{}
+	if duration > 0 {
+		g.until = now.Add(duration)
+	}
+}
+
+func (g *GuestMode) Disable() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.enabled = false
+	g.until = time.Time{}
+}
+
+// Active reports whether guest mode is on, turning it off once it expired.
+func (g *GuestMode) Active(now time.Time) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.enabled && !g.until.IsZero() && !now.Before(g.until) {
+		log.Info().Msg("guest mode expired")
+		g.enabled = false
+		g.until = time.Time{}
+	}
+	return g.enabled
+}
@@ -0,0 +1,38 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteSinks(t *testing.T) {
+	own := &FakeSink{}
+	shared := main.WrapSinkGuest(&FakeSink{}, main.GuestRoutingAlso)
+	guest := main.NewQueueSink(main.WrapSinkGuest(&FakeSink{}, main.GuestRoutingOnly), main.ScrobbleQueue{Filename: ""})
+	invalid := main.WrapSinkGuest(&FakeSink{}, main.GuestRouting("invalid"))
+
+	sinks := []main.Sink{own, shared, guest, invalid}
+	require.Equal(t, []main.Sink{own, shared, invalid}, main.RouteSinks(sinks, false))
+	require.Equal(t, []main.Sink{shared, guest}, main.RouteSinks(sinks, true))
+}
+
+func TestGuestMode(t *testing.T) {
+	now := time.Date(2025, 1, 1, 20, 0, 0, 0, time.UTC)
+
+	var guestMode main.GuestMode
+	require.False(t, guestMode.Active(now))
+
+	guestMode.Enable(now, 0)
+	require.True(t, guestMode.Active(now.Add(24*time.Hour)))
+
+	guestMode.Disable()
+	require.False(t, guestMode.Active(now))
+
+	guestMode.Enable(now, 3*time.Hour)
+	require.True(t, guestMode.Active(now.Add(2*time.Hour)))
+	require.False(t, guestMode.Active(now.Add(3*time.Hour)))
+	require.False(t, guestMode.Active(now.Add(2*time.Hour)))
+}
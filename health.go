@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// HealthMaxLoopAge is the time after which a main loop that did not complete
+// an iteration is considered hung.
+const HealthMaxLoopAge = 2 * time.Minute
+
+// HealthReport is served by `/healthz`. Sources and sinks map to "ok" or the
+// last error, which is cleared by the next successful request.
+type HealthReport struct {
+	Healthy  bool              `json:"healthy"`
+	LastLoop time.Time         `json:"last_loop"`
+	Sources  map[string]string `json:"sources"`
+	Sinks    map[string]string `json:"sinks"`
+}
+
+func NewHealthReport(state *LoopState, sources []Source, sinks []Sink, now time.Time) HealthReport {
+	report := HealthReport{
+		Healthy:  true,
+		LastLoop: now,
+		Sources:  map[string]string{},
+		Sinks:    map[string]string{},
+	}
+
+	for _, source := range sources {
+		report.Sources[source.Name()] = healthOf(state.SourceErrors, source.Name(), &report.Healthy)
+	}
+	for _, sink := range sinks {
+		report.Sinks[sink.Name()] = healthOf(state.SinkErrors, sink.Name(), &report.Healthy)
+	}
+
+	return report
+}
+
+// At returns the report as seen at the given time, which is unhealthy if the
+// main loop did not complete an iteration recently.
+func (r HealthReport) At(now time.Time) HealthReport {
+	if now.Sub(r.LastLoop) > HealthMaxLoopAge {
+		r.Healthy = false
+	}
+	return r
+}
+
+func (r HealthReport) Summary(now time.Time) string {
+	state := "healthy"
+	if !r.Healthy {
+		state = "unhealthy"
+	}
+
+	summary := fmt.Sprintf("%s, last main loop iteration %s ago", state, now.Sub(r.LastLoop).Truncate(time.Second))
+	for _, name := range slices.Sorted(maps.Keys(r.Sources)) {
+		summary += fmt.Sprintf("\nsource %s: %s", name, r.Sources[name])
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.Sinks)) {
+		summary += fmt.Sprintf("\nsink %s: %s", name, r.Sinks[name])
+	}
+	return summary
+}
+
+func healthOf(errs map[string]string, name string, healthy *bool) string {
+	if err, ok := errs[name]; ok {
+		*healthy = false
+		return err
+	}
+	return "ok"
+}
+
+func recordError(errs map[string]string, name string, err error) {
+	if err != nil {
+		errs[name] = err.Error()
+	} else {
+		delete(errs, name)
+	}
+}
+
+// QueryHealth requests the health report from the HTTP API at the given
+// listen address. Unspecified addresses (e.g., 0.0.0.0) are queried on the
+// loopback interface.
+func QueryHealth(address string) (HealthReport, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return HealthReport{}, err
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+
+	client := http.Client{Transport: nil, CheckRedirect: nil, Jar: nil, Timeout: controlTimeout}
+	response, err := client.Get("http://" + net.JoinHostPort(host, port) + "/healthz")
+	if err != nil {
+		return HealthReport{}, err
+	}
+	defer CloseLogged(response.Body)
+
+	var report HealthReport
+	if err := json.NewDecoder(response.Body).Decode(&report); err != nil {
+		return HealthReport{}, fmt.Errorf("invalid health report: %s", err.Error())
+	}
+	if response.StatusCode != http.StatusOK && report.Healthy {
+		return HealthReport{}, errors.New(response.Status)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,74 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthReport(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	state := main.NewLoopState()
+	notifier := FakeNotifier{}
+
+	source := FakeSource{Error: true, PlaybackStatus: defaultPlaybackStatus}
+	sink := &FakeSink{Error: true}
+
+	main.RunMainLoopOnce(state, replayOptions(), []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+
+	report := main.NewHealthReport(state, []main.Source{source}, []main.Sink{sink}, now)
+	require.False(t, report.Healthy)
+	require.Equal(t, "fake error", report.Sources["fake source"])
+	require.Equal(t, "fake error", report.Sinks["fake sink"])
+	require.Equal(t,
+		"unhealthy, last main loop iteration 30s ago\nsource fake source: fake error\nsink fake sink: fake error",
+		report.Summary(now.Add(30*time.Second)),
+	)
+
+	source.Error = false
+	sink.Error = false
+	state = main.NewLoopState()
+	main.RunMainLoopOnce(state, replayOptions(), []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+
+	report = main.NewHealthReport(state, []main.Source{source}, []main.Sink{sink}, now)
+	require.True(t, report.Healthy)
+	require.Equal(t, "ok", report.Sinks["fake sink"])
+	require.True(t, report.At(now.Add(time.Minute)).Healthy)
+	require.False(t, report.At(now.Add(main.HealthMaxLoopAge+time.Second)).Healthy)
+}
+
+func TestHealthEndpoint(t *testing.T) {
+	report := main.HealthReport{Healthy: true, LastLoop: time.Now(), Sources: map[string]string{"dbus": "ok"}}
+
+	control := main.NewControlServer()
+	control.Handle("health", func(main.ControlRequest) main.ControlResponse {
+		response := main.ControlMessage("")
+		response.Health = &report
+		return response
+	})
+
+	server := httptest.NewServer(main.NewAPIServer("secret", control).Handler())
+	defer server.Close()
+
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	queried, err := main.QueryHealth(address)
+	require.NoError(t, err)
+	require.True(t, queried.Healthy)
+	require.Equal(t, "ok", queried.Sources["dbus"])
+
+	report.Healthy = false
+	response, err := http.Get(server.URL + "/healthz")
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+
+	queried, err = main.QueryHealth(address)
+	require.NoError(t, err)
+	require.False(t, queried.Healthy)
+}
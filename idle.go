@@ -0,0 +1,103 @@
+package main
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const inhibitRetryInterval = time.Minute
+
+// InhibitFunc prevents the screen from locking and the system from suspending
+// due to inactivity until release is called.
+type InhibitFunc func(reason string) (release func() error, err error)
+
+// IdleInhibitor inhibits screen locking and suspend while a matching player
+// is playing. A nil inhibitor does nothing.
+type IdleInhibitor struct {
+	// if empty, all players are matched
+	Players []*regexp.Regexp
+	Inhibit InhibitFunc
+
+	release     func() error
+	lastAttempt time.Time
+}
+
+func (c Config) IdleInhibitor() *IdleInhibitor {
+	if c.InhibitIdle == nil {
+		return nil
+	}
+
+	var players []*regexp.Regexp
+	for _, expression := range c.InhibitIdle.Players {
+		compiled, err := regexp.Compile(expression)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("expression", expression).
+				Msg("error compiling idle inhibit player expression")
+			continue
+		}
+		players = append(players, compiled)
+	}
+
+	return &IdleInhibitor{
+		Players:     players,
+		Inhibit:     InhibitIdle,
+		release:     nil,
+		lastAttempt: time.Time{},
+	}
+}
+
+// Update takes or releases the inhibitor depending on the current playback
+// status of all players.
+func (i *IdleInhibitor) Update(playing map[string]PlaybackStatus) {
+	if i == nil {
+		return
+	}
+
+	active := false
+	for player, status := range playing {
+		if status.State == PlaybackPlaying && (len(i.Players) == 0 || IsBlacklisted(i.Players, player)) {
+			active = true
+			break
+		}
+	}
+
+	switch {
+	case active && i.release == nil:
+		// the inhibitor may be unavailable, e.g. without a desktop session
+		if time.Since(i.lastAttempt) < inhibitRetryInterval {
+			return
+		}
+		i.lastAttempt = time.Now()
+
+		release, err := i.Inhibit("music is playing")
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("error inhibiting screen lock and suspend")
+			return
+		}
+
+		log.Debug().Msg("inhibited screen lock and suspend")
+		i.release = release
+	case !active && i.release != nil:
+		CloseLogged(i)
+	}
+}
+
+// Close releases the inhibitor, if taken.
+func (i *IdleInhibitor) Close() error {
+	if i == nil || i.release == nil {
+		return nil
+	}
+
+	release := i.release
+	i.release = nil
+	i.lastAttempt = time.Time{}
+
+	log.Debug().Msg("released screen lock and suspend inhibitor")
+	return release()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+)
+
+// InhibitIdle runs the system `caffeinate` binary until release is called. It
+// exits on its own if goscrobble exits.
+func InhibitIdle(reason string) (func() error, error) {
+	log.Debug().
+		Str("reason", reason).
+		Msg("inhibiting display and idle sleep via caffeinate")
+
+	// -d prevents display sleep, -i prevents idle sleep, -w exits with goscrobble
+	cmd := exec.Command("/usr/bin/caffeinate", "-d", "-i", "-w", strconv.Itoa(os.Getpid()))
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		if err := cmd.Process.Kill(); err != nil {
+			return err
+		}
+		// caffeinate always exits with an error after being killed
+		_ = cmd.Wait()
+		return nil
+	}, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// InhibitIdle uses the org.freedesktop.ScreenSaver interface, which is
+// provided by most desktop environments on both X11 and Wayland. The inhibitor
+// is also released if goscrobble exits and the connection is closed.
+func InhibitIdle(reason string) (func() error, error) {
+	if InSandbox() {
+		return PortalInhibit(reason)
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug().
+		Str("reason", reason).
+		Msg("inhibiting screen lock via dbus")
+
+	object := conn.Object("org.freedesktop.ScreenSaver", "/org/freedesktop/ScreenSaver")
+
+	var cookie uint32
+	if err := object.Call("org.freedesktop.ScreenSaver.Inhibit", 0, "goscrobble", reason).Store(&cookie); err != nil {
+		CloseLogged(conn)
+		return nil, err
+	}
+
+	return func() error {
+		defer CloseLogged(conn)
+		return object.Call("org.freedesktop.ScreenSaver.UnInhibit", 0, cookie).Err
+	}, nil
+}
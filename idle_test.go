@@ -0,0 +1,88 @@
+package main_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeInhibitor struct {
+	Inhibited int
+	Released  int
+	Err       error
+}
+
+func (f *fakeInhibitor) Inhibit(string) (func() error, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	f.Inhibited++
+	return func() error {
+		f.Released++
+		return nil
+	}, nil
+}
+
+func TestIdleInhibitor(t *testing.T) {
+	playing := main.PlaybackStatus{State: main.PlaybackPlaying}
+	paused := main.PlaybackStatus{State: main.PlaybackPaused}
+
+	t.Run("all players", func(t *testing.T) {
+		fake := &fakeInhibitor{}
+		inhibitor := &main.IdleInhibitor{Inhibit: fake.Inhibit}
+
+		inhibitor.Update(map[string]main.PlaybackStatus{"dbus:spotify": paused})
+		require.Equal(t, 0, fake.Inhibited)
+
+		inhibitor.Update(map[string]main.PlaybackStatus{"dbus:spotify": playing})
+		inhibitor.Update(map[string]main.PlaybackStatus{"dbus:spotify": playing, "dbus:vlc": playing})
+		require.Equal(t, 1, fake.Inhibited)
+		require.Equal(t, 0, fake.Released)
+
+		inhibitor.Update(map[string]main.PlaybackStatus{})
+		require.Equal(t, 1, fake.Released)
+
+		inhibitor.Update(map[string]main.PlaybackStatus{"dbus:vlc": playing})
+		require.NoError(t, inhibitor.Close())
+		require.Equal(t, 2, fake.Inhibited)
+		require.Equal(t, 2, fake.Released)
+	})
+
+	t.Run("matching players", func(t *testing.T) {
+		fake := &fakeInhibitor{}
+		inhibitor := &main.IdleInhibitor{
+			Players: []*regexp.Regexp{regexp.MustCompile(`^upnp:`)},
+			Inhibit: fake.Inhibit,
+		}
+
+		inhibitor.Update(map[string]main.PlaybackStatus{"dbus:spotify": playing})
+		require.Equal(t, 0, fake.Inhibited)
+
+		inhibitor.Update(map[string]main.PlaybackStatus{"dbus:spotify": playing, "upnp:Kitchen": playing})
+		require.Equal(t, 1, fake.Inhibited)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		fake := &fakeInhibitor{Err: errors.New("no desktop session")}
+		inhibitor := &main.IdleInhibitor{Inhibit: fake.Inhibit}
+
+		inhibitor.Update(map[string]main.PlaybackStatus{"dbus:spotify": playing})
+		fake.Err = nil
+
+		// failed attempts are not retried on every poll
+		inhibitor.Update(map[string]main.PlaybackStatus{"dbus:spotify": playing})
+		require.Equal(t, 0, fake.Inhibited)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		config := main.DefaultConfig
+		inhibitor := config.IdleInhibitor()
+		require.Nil(t, inhibitor)
+
+		inhibitor.Update(map[string]main.PlaybackStatus{"dbus:spotify": playing})
+		require.NoError(t, inhibitor.Close())
+	})
+}
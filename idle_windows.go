@@ -0,0 +1,47 @@
+package main
+
+import (
+	"runtime"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	esContinuous      = 0x80000000
+	esSystemRequired  = 0x00000001
+	esDisplayRequired = 0x00000002
+)
+
+var setThreadExecutionState = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetThreadExecutionState")
+
+// InhibitIdle prevents display and idle sleep using SetThreadExecutionState
+// until release is called. The execution state belongs to a thread, so it is
+// set and reset from the same locked thread.
+func InhibitIdle(reason string) (func() error, error) {
+	log.Debug().
+		Str("reason", reason).
+		Msg("inhibiting display and idle sleep via SetThreadExecutionState")
+
+	if err := setThreadExecutionState.Find(); err != nil {
+		return nil, err
+	}
+
+	released := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(done)
+
+		_, _, _ = setThreadExecutionState.Call(esContinuous | esSystemRequired | esDisplayRequired)
+		<-released
+		_, _, _ = setThreadExecutionState.Call(esContinuous)
+	}()
+
+	return func() error {
+		close(released)
+		<-done
+		return nil
+	}, nil
+}
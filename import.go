@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timestamp formats used by export files, read as UTC if they have no time
+// zone
+var importTimeLayouts = []string{
+	"02 Jan 2006 15:04",
+	"2 Jan 2006 15:04",
+	"02 Jan 2006, 15:04",
+	"2 Jan 2006, 15:04",
+	time.DateTime,
+	time.RFC3339,
+}
+
+// ImportParser reads the scrobbles of an export file.
+type ImportParser func(data []byte) ([]Scrobble, error)
+
+// MergeScrobbles adds the imported scrobbles to the existing ones and returns
+// all scrobbles sorted by timestamp and the number of scrobbles added. Plays
+// that are already present (e.g., because the same export was imported twice)
+// are skipped.
+func MergeScrobbles(existing, imported []Scrobble) ([]Scrobble, int) {
+	seen := map[string]bool{}
+	for _, scrobble := range existing {
+		seen[scrobble.Key()] = true
+	}
+
+	merged := existing
+	for _, scrobble := range imported {
+		key := scrobble.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, scrobble)
+	}
+
+	SortScrobbles(merged)
+	return merged, len(merged) - len(existing)
+}
+
+// ImportScrobbles merges the imported scrobbles into a local sink and returns
+// the number of scrobbles added. Deleted scrobbles are not imported again.
+func ImportScrobbles(sink Sink, imported []Scrobble) (int, error) {
+	editable, ok := UnwrapSink(sink).(EditableSink)
+	if !ok {
+		return 0, fmt.Errorf("cannot import into sink %s", sink.Name())
+	}
+
+	// the file of a new local sink does not exist yet
+	existing, err := sink.GetScrobbles(0, time.Time{}, time.Now())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+
+	imported, err = WithoutDeleted(sink, imported)
+	if err != nil {
+		return 0, err
+	}
+
+	merged, added := MergeScrobbles(existing, imported)
+	if added == 0 {
+		return 0, nil
+	}
+
+	if err := editable.ReplaceScrobbles(merged); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// FindImportSink returns the sink with the given name, or the only local sink
+// if name is empty.
+func FindImportSink(sinks []Sink, name string) (Sink, error) {
+	if name != "" {
+		return FindSink(sinks, name)
+	}
+
+	var local []Sink
+	for _, sink := range sinks {
+		if _, ok := UnwrapSink(sink).(EditableSink); ok {
+			local = append(local, sink)
+		}
+	}
+
+	switch len(local) {
+	case 0:
+		return nil, errors.New("no local sink configured")
+	case 1:
+		return local[0], nil
+	default:
+		names := make([]string, 0, len(local))
+		for _, sink := range local {
+			names = append(names, sink.Name())
+		}
+		return nil, fmt.Errorf("multiple local sinks configured, choose one with --sink (%s)", strings.Join(names, ", "))
+	}
+}
+
+func parseImportTime(value string) (time.Time, error) {
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// some exports use milliseconds
+		if unix > 1e11 {
+			return time.UnixMilli(unix).UTC(), nil
+		}
+		return time.Unix(unix, 0).UTC(), nil
+	}
+
+	for _, layout := range importTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format: %q", value)
+}
+
+func importedScrobble(artist, track, album string, timestamp time.Time) (Scrobble, error) {
+	scrobble := Scrobble{
+		Artists:   []string{strings.TrimSpace(artist)},
+		Track:     strings.TrimSpace(track),
+		Album:     strings.TrimSpace(album),
+		Duration:  0,
+		Timestamp: timestamp,
+	}
+	// exports often lack the album of some tracks
+	if scrobble.JoinArtists() == "" || scrobble.Track == "" {
+		return Scrobble{}, errors.New("missing artist or track")
+	}
+	return scrobble, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// column names of CSV exports with a header row
+var (
+	lastFmArtistColumns    = []string{"artist", "artist_name", "artist name"}
+	lastFmAlbumColumns     = []string{"album", "album_name", "album name"}
+	lastFmTrackColumns     = []string{"track", "track_name", "track name", "title", "name"}
+	lastFmTimestampColumns = []string{"uts", "timestamp", "utc_time", "date", "time"}
+)
+
+// ParseLastFmExport reads a last.fm export in JSON or CSV format. JSON exports
+// contain the tracks in the format of the last.fm API, either as a list of
+// pages of user.getRecentTracks responses or as a list of tracks. CSV exports
+// either have a header row naming the columns or the columns artist, album,
+// track, and date without a header.
+func ParseLastFmExport(data []byte) ([]Scrobble, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return parseLastFmJSON(trimmed)
+	}
+	return parseLastFmCSV(data)
+}
+
+// lastFmText is a string in the last.fm API, which is either a plain string,
+// a number, or an object with the string in "#text" or "name".
+type lastFmText string
+
+func (t *lastFmText) UnmarshalJSON(data []byte) error {
+	switch {
+	case bytes.HasPrefix(data, []byte(`"`)):
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*t = lastFmText(text)
+	case bytes.HasPrefix(data, []byte("{")):
+		var object struct {
+			Text string `json:"#text"`
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(data, &object); err != nil {
+			return err
+		}
+		*t = lastFmText(cmp.Or(object.Text, object.Name))
+	case bytes.Equal(data, []byte("null")):
+		*t = ""
+	default:
+		*t = lastFmText(data)
+	}
+	return nil
+}
+
+type lastFmExportTrack struct {
+	Artist lastFmText `json:"artist"`
+	Album  lastFmText `json:"album"`
+	Name   lastFmText `json:"name"`
+	Date   *struct {
+		UTS lastFmText `json:"uts"`
+	} `json:"date"`
+}
+
+type lastFmExportPage struct {
+	Track        []lastFmExportTrack `json:"track"`
+	RecentTracks *struct {
+		Track []lastFmExportTrack `json:"track"`
+	} `json:"recenttracks"`
+}
+
+func (p lastFmExportPage) tracks() []lastFmExportTrack {
+	if p.RecentTracks != nil {
+		return p.RecentTracks.Track
+	}
+	return p.Track
+}
+
+func parseLastFmJSON(data []byte) ([]Scrobble, error) {
+	var elements []json.RawMessage
+	if data[0] == '{' {
+		elements = []json.RawMessage{data}
+	} else if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, fmt.Errorf("invalid last.fm export: %s", err.Error())
+	}
+
+	var tracks []lastFmExportTrack
+	for _, element := range elements {
+		var page lastFmExportPage
+		if err := json.Unmarshal(element, &page); err != nil {
+			return nil, fmt.Errorf("invalid last.fm export: %s", err.Error())
+		}
+		if pageTracks := page.tracks(); pageTracks != nil {
+			tracks = append(tracks, pageTracks...)
+			continue
+		}
+
+		var track lastFmExportTrack
+		if err := json.Unmarshal(element, &track); err != nil {
+			return nil, fmt.Errorf("invalid last.fm export: %s", err.Error())
+		}
+		tracks = append(tracks, track)
+	}
+
+	var scrobbles []Scrobble
+	for i, track := range tracks {
+		// the currently playing track has no date
+		if track.Date == nil {
+			continue
+		}
+
+		timestamp, err := parseImportTime(string(track.Date.UTS))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp of track %d: %s", i+1, err.Error())
+		}
+
+		scrobble, err := importedScrobble(string(track.Artist), string(track.Name), string(track.Album), timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid track %d: %s", i+1, err.Error())
+		}
+		scrobbles = append(scrobbles, scrobble)
+	}
+	return scrobbles, nil
+}
+
+func parseLastFmCSV(data []byte) ([]Scrobble, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid last.fm export: %s", err.Error())
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	artist, album, track, timestamp := 0, 1, 2, 3
+	if header := records[0]; slices.ContainsFunc(header, func(column string) bool {
+		return slices.Contains(lastFmArtistColumns, normalizeColumn(column))
+	}) {
+		artist = findColumn(header, lastFmArtistColumns)
+		album = findColumn(header, lastFmAlbumColumns)
+		track = findColumn(header, lastFmTrackColumns)
+		timestamp = findColumn(header, lastFmTimestampColumns)
+		if track < 0 || timestamp < 0 {
+			return nil, errors.New("invalid last.fm export: missing track or timestamp column")
+		}
+		records = records[1:]
+	}
+
+	var scrobbles []Scrobble
+	for i, record := range records {
+		column := func(index int) string {
+			if index < 0 || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		parsed, err := parseImportTime(column(timestamp))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp in row %d: %s", i+1, err.Error())
+		}
+
+		scrobble, err := importedScrobble(column(artist), column(track), column(album), parsed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row %d: %s", i+1, err.Error())
+		}
+		scrobbles = append(scrobbles, scrobble)
+	}
+	return scrobbles, nil
+}
+
+func normalizeColumn(column string) string {
+	return strings.ToLower(strings.TrimSpace(column))
+}
+
+func findColumn(header []string, names []string) int {
+	// the order of names decides which column is used if there are multiple
+	// matching columns (e.g., uts and utc_time)
+	for _, name := range names {
+		for i, column := range header {
+			if normalizeColumn(column) == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
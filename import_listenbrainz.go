@@ -0,0 +1,138 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+type listenBrainzListen struct {
+	ListenedAt    json.RawMessage `json:"listened_at"`
+	TrackMetadata struct {
+		ArtistName     string `json:"artist_name"`
+		TrackName      string `json:"track_name"`
+		ReleaseName    string `json:"release_name"`
+		AdditionalInfo struct {
+			DurationMs int64 `json:"duration_ms"`
+			Duration   int64 `json:"duration"`
+		} `json:"additional_info"`
+	} `json:"track_metadata"`
+}
+
+// ParseListenBrainzExport reads a ListenBrainz export, either the ZIP archive
+// of the user data export (with the listens in JSON lines files, one per
+// month) or a single JSON or JSON lines file with the listens.
+func ParseListenBrainzExport(data []byte) ([]Scrobble, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if errors.Is(err, zip.ErrFormat) {
+		return parseListenBrainzListens(data)
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid ListenBrainz export: %s", err.Error())
+	}
+
+	var files []*zip.File
+	for _, file := range archive.File {
+		ext := path.Ext(file.Name)
+		if !file.FileInfo().IsDir() && strings.Contains(file.Name, "listens") && (ext == ".json" || ext == ".jsonl") {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("invalid ListenBrainz export: no listens found in archive")
+	}
+	slices.SortFunc(files, func(a, b *zip.File) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	var scrobbles []Scrobble
+	for _, file := range files {
+		listens, err := readZipFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %s", file.Name, err.Error())
+		}
+
+		parsed, err := parseListenBrainzListens(listens)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.Name, err.Error())
+		}
+		scrobbles = append(scrobbles, parsed...)
+	}
+	return scrobbles, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer CloseLogged(reader)
+
+	return io.ReadAll(reader)
+}
+
+func parseListenBrainzListens(data []byte) ([]Scrobble, error) {
+	var listens []listenBrainzListen
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &listens); err != nil {
+			return nil, fmt.Errorf("invalid ListenBrainz export: %s", err.Error())
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		// listens with many additional fields can exceed the default limit
+		scanner.Buffer(nil, 1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+
+			var listen listenBrainzListen
+			if err := json.Unmarshal(scanner.Bytes(), &listen); err != nil {
+				return nil, fmt.Errorf("invalid listen in line %d: %s", line, err.Error())
+			}
+			listens = append(listens, listen)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	scrobbles := make([]Scrobble, 0, len(listens))
+	for i, listen := range listens {
+		timestamp, err := parseListenBrainzTime(listen.ListenedAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp of listen %d: %s", i+1, err.Error())
+		}
+
+		metadata := listen.TrackMetadata
+		scrobble, err := importedScrobble(metadata.ArtistName, metadata.TrackName, metadata.ReleaseName, timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listen %d: %s", i+1, err.Error())
+		}
+
+		if metadata.AdditionalInfo.DurationMs > 0 {
+			scrobble.Duration = time.Duration(metadata.AdditionalInfo.DurationMs) * time.Millisecond
+		} else {
+			scrobble.Duration = time.Duration(metadata.AdditionalInfo.Duration) * time.Second
+		}
+		scrobbles = append(scrobbles, scrobble)
+	}
+	return scrobbles, nil
+}
+
+// parseListenBrainzTime reads listened_at, which is a unix timestamp in older
+// exports and an ISO 8601 string in newer ones.
+func parseListenBrainzTime(value json.RawMessage) (time.Time, error) {
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		text = string(value)
+	}
+	return parseImportTime(text)
+}
@@ -0,0 +1,52 @@
+package main_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+const listenBrainzListens = `{"listened_at": 1612121640, "track_metadata": {"artist_name": "Placebo", "track_name": "Pure Morning", "release_name": "Without You I'm Nothing", "additional_info": {"duration_ms": 251000}}}
+{"listened_at": "2021-01-31T19:38:00+00:00", "track_metadata": {"artist_name": "Placebo", "track_name": "Special K", "additional_info": {"duration": 240}}}
+`
+
+func TestParseListenBrainzExport(t *testing.T) {
+	var archive bytes.Buffer
+	writer := zip.NewWriter(&archive)
+	_, err := writer.Create("user.json")
+	require.NoError(t, err)
+	file, err := writer.Create("listens/2021/1.jsonl")
+	require.NoError(t, err)
+	_, err = file.Write([]byte(listenBrainzListens))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	for _, data := range [][]byte{archive.Bytes(), []byte(listenBrainzListens)} {
+		scrobbles, err := main.ParseListenBrainzExport(data)
+		require.NoError(t, err)
+		require.Len(t, scrobbles, 2)
+
+		require.Equal(t, []string{"Placebo"}, scrobbles[0].Artists)
+		require.Equal(t, "Pure Morning", scrobbles[0].Track)
+		require.Equal(t, "Without You I'm Nothing", scrobbles[0].Album)
+		require.Equal(t, 251*time.Second, scrobbles[0].Duration)
+		require.True(t, time.Date(2021, time.January, 31, 19, 34, 0, 0, time.UTC).Equal(scrobbles[0].Timestamp))
+
+		require.Equal(t, "Special K", scrobbles[1].Track)
+		require.Empty(t, scrobbles[1].Album)
+		require.Equal(t, 240*time.Second, scrobbles[1].Duration)
+		require.True(t, time.Date(2021, time.January, 31, 19, 38, 0, 0, time.UTC).Equal(scrobbles[1].Timestamp))
+	}
+
+	scrobbles, err := main.ParseListenBrainzExport([]byte(`[{"listened_at": 1612121640, "track_metadata": {"artist_name": "Placebo", "track_name": "Pure Morning"}}]`))
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 1)
+	require.Zero(t, scrobbles[0].Duration)
+
+	_, err = main.ParseListenBrainzExport([]byte(`{"listened_at": 1612121640, "track_metadata": {"track_name": "Pure Morning"}}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	scrobblerLogHeader = "#AUDIOSCROBBLER/"
+	scrobblerLogTZ     = "#TZ/"
+	// the track was played, skipped tracks are marked with "S"
+	scrobblerLogListened = "L"
+)
+
+// ParseScrobblerLog reads a .scrobbler.log file in the Audioscrobbler 1.1
+// format written by portable players (e.g., Rockbox). Skipped tracks are
+// ignored. If the time zone of the log is unknown, the timestamps are the
+// local time of the player and are read in the local time zone.
+func ParseScrobblerLog(data []byte) ([]Scrobble, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	if !scanner.Scan() || !strings.HasPrefix(scanner.Text(), scrobblerLogHeader) {
+		return nil, errors.New("invalid scrobbler log: missing #AUDIOSCROBBLER header")
+	}
+
+	localTime := false
+	var scrobbles []Scrobble
+	for line := 2; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "#") {
+			if tz, ok := strings.CutPrefix(text, scrobblerLogTZ); ok {
+				localTime = tz != "UTC"
+			}
+			continue
+		}
+
+		// the MusicBrainz ID in the last column is optional
+		columns := strings.Split(text, "\t")
+		if len(columns) != 7 && len(columns) != 8 {
+			return nil, fmt.Errorf("invalid scrobbler log: line %d has %d columns", line, len(columns))
+		}
+		if columns[5] != scrobblerLogListened {
+			continue
+		}
+
+		unix, err := strconv.ParseInt(columns[6], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp in line %d: %s", line, err.Error())
+		}
+		timestamp := time.Unix(unix, 0).UTC()
+		if localTime {
+			timestamp = time.Date(
+				timestamp.Year(), timestamp.Month(), timestamp.Day(),
+				timestamp.Hour(), timestamp.Minute(), timestamp.Second(), 0,
+				time.Local,
+			)
+		}
+
+		scrobble, err := importedScrobble(columns[0], columns[2], columns[1], timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid track in line %d: %s", line, err.Error())
+		}
+		if seconds, err := strconv.Atoi(columns[4]); err == nil {
+			scrobble.Duration = time.Duration(seconds) * time.Second
+		}
+		scrobbles = append(scrobbles, scrobble)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return scrobbles, nil
+}
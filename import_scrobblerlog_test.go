@@ -0,0 +1,40 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScrobblerLog(t *testing.T) {
+	log := "#AUDIOSCROBBLER/1.1\n" +
+		"#TZ/UTC\n" +
+		"#CLIENT/Rockbox ipodvideo $Revision$\n" +
+		"Placebo\tWithout You I'm Nothing\tPure Morning\t1\t251\tL\t1612121640\t\n" +
+		"Placebo\tBlack Market Music\tSpecial K\t4\t240\tS\t1612121900\n" +
+		"Placebo\tBlack Market Music\tSlave to the Wage\t5\t290\tL\t1612122000\n"
+
+	scrobbles, err := main.ParseScrobblerLog([]byte(log))
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 2)
+	require.Equal(t, []string{"Placebo"}, scrobbles[0].Artists)
+	require.Equal(t, "Pure Morning", scrobbles[0].Track)
+	require.Equal(t, "Without You I'm Nothing", scrobbles[0].Album)
+	require.Equal(t, 251*time.Second, scrobbles[0].Duration)
+	require.True(t, time.Date(2021, time.January, 31, 19, 34, 0, 0, time.UTC).Equal(scrobbles[0].Timestamp))
+	require.Equal(t, "Slave to the Wage", scrobbles[1].Track)
+
+	// without a time zone, the timestamps are the local time of the player
+	unknown := "#AUDIOSCROBBLER/1.1\n#TZ/UNKNOWN\nPlacebo\tWithout You I'm Nothing\tPure Morning\t1\t251\tL\t1612121640\n"
+	scrobbles, err = main.ParseScrobblerLog([]byte(unknown))
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 1)
+	require.True(t, time.Date(2021, time.January, 31, 19, 34, 0, 0, time.Local).Equal(scrobbles[0].Timestamp))
+
+	_, err = main.ParseScrobblerLog([]byte("Placebo\tWithout You I'm Nothing\tPure Morning\t1\t251\tL\t1612121640\n"))
+	require.Error(t, err)
+	_, err = main.ParseScrobblerLog([]byte("#AUDIOSCROBBLER/1.1\nPlacebo\tPure Morning\n"))
+	require.Error(t, err)
+}
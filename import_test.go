@@ -0,0 +1,110 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeScrobbles(t *testing.T) {
+	older := defaultScrobble
+	older.Track = "Pure Morning"
+	older.Timestamp = defaultScrobble.Timestamp.Add(-time.Hour)
+
+	merged, added := main.MergeScrobbles([]main.Scrobble{defaultScrobble}, []main.Scrobble{older, defaultScrobble, older})
+	require.Equal(t, 1, added)
+	require.Len(t, merged, 2)
+	require.Equal(t, "Pure Morning", merged[0].Track)
+	require.Equal(t, defaultScrobble.Track, merged[1].Track)
+}
+
+func TestImportScrobbles(t *testing.T) {
+	sink := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv")}
+	require.NoError(t, sink.Scrobble(defaultScrobble))
+
+	imported := defaultScrobble
+	imported.Track = "Pure Morning"
+	imported.Duration = 0
+	imported.Timestamp = defaultScrobble.Timestamp.Add(-time.Hour)
+
+	for _, expected := range []int{1, 0} {
+		added, err := main.ImportScrobbles(sink, []main.Scrobble{imported, defaultScrobble})
+		require.NoError(t, err)
+		require.Equal(t, expected, added)
+	}
+
+	scrobbles, err := sink.GetScrobbles(0, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 2)
+
+	_, err = main.ImportScrobbles(&FakeSink{}, []main.Scrobble{imported})
+	require.Error(t, err)
+}
+
+func TestFindImportSink(t *testing.T) {
+	csv := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv")}
+
+	sink, err := main.FindImportSink([]main.Sink{&FakeSink{}, csv}, "")
+	require.NoError(t, err)
+	require.Equal(t, "csv:default", sink.Name())
+
+	other := main.CSVSink{Key: "other", Filename: filepath.Join(t.TempDir(), "other.csv")}
+	_, err = main.FindImportSink([]main.Sink{csv, other}, "")
+	require.Error(t, err)
+
+	sink, err = main.FindImportSink([]main.Sink{csv, other}, "csv:other")
+	require.NoError(t, err)
+	require.Equal(t, "csv:other", sink.Name())
+
+	_, err = main.FindImportSink([]main.Sink{&FakeSink{}}, "")
+	require.Error(t, err)
+}
+
+func TestParseLastFmExportCSV(t *testing.T) {
+	expected := time.Date(2021, time.January, 31, 19, 34, 0, 0, time.UTC)
+
+	scrobbles, err := main.ParseLastFmExport([]byte("Placebo,Without You I'm Nothing,Pure Morning,31 Jan 2021 19:34\n"))
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 1)
+	require.Equal(t, []string{"Placebo"}, scrobbles[0].Artists)
+	require.Equal(t, "Pure Morning", scrobbles[0].Track)
+	require.Equal(t, "Without You I'm Nothing", scrobbles[0].Album)
+	require.True(t, expected.Equal(scrobbles[0].Timestamp))
+
+	scrobbles, err = main.ParseLastFmExport([]byte("uts,utc_time,artist,artist_mbid,album,album_mbid,track,track_mbid\n" +
+		"1612121640,\"31 Jan 2021, 19:34\",Placebo,,,,Pure Morning,\n"))
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 1)
+	require.Equal(t, "Pure Morning", scrobbles[0].Track)
+	require.Empty(t, scrobbles[0].Album)
+	require.True(t, expected.Equal(scrobbles[0].Timestamp))
+
+	_, err = main.ParseLastFmExport([]byte("Placebo,Without You I'm Nothing,Pure Morning,yesterday\n"))
+	require.Error(t, err)
+}
+
+func TestParseLastFmExportJSON(t *testing.T) {
+	expected := time.Date(2021, time.January, 31, 19, 34, 0, 0, time.UTC)
+
+	pages := `[{"track": [
+		{"artist": {"#text": "Placebo"}, "name": "Special K", "album": {"#text": "Black Market Music"}, "@attr": {"nowplaying": "true"}},
+		{"artist": {"#text": "Placebo"}, "name": "Pure Morning", "album": {"#text": "Without You I'm Nothing"}, "date": {"uts": "1612121640", "#text": "31 Jan 2021, 19:34"}}
+	]}]`
+	response := `{"recenttracks": {"track": [
+		{"artist": {"name": "Placebo"}, "name": "Pure Morning", "album": {"#text": "Without You I'm Nothing"}, "date": {"uts": 1612121640}}
+	]}}`
+	tracks := `[{"artist": "Placebo", "name": "Pure Morning", "album": "Without You I'm Nothing", "date": {"uts": "1612121640"}}]`
+
+	for _, data := range []string{pages, response, tracks} {
+		scrobbles, err := main.ParseLastFmExport([]byte(data))
+		require.NoError(t, err)
+		require.Len(t, scrobbles, 1)
+		require.Equal(t, []string{"Placebo"}, scrobbles[0].Artists)
+		require.Equal(t, "Pure Morning", scrobbles[0].Track)
+		require.Equal(t, "Without You I'm Nothing", scrobbles[0].Album)
+		require.True(t, expected.Equal(scrobbles[0].Timestamp))
+	}
+}
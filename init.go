@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// configComments are written above the keys and tables of the same name by
+// WriteCommented. Nested keys are prefixed with their table.
+var configComments = map[string]string{
+	"poll_rate":             "track position update frequency in seconds",
+	"idle_poll_rate":        "poll frequency in seconds while no player is running (0 always uses poll_rate)",
+	"min_playback_duration": "minimum playback duration in seconds",
+	"min_playback_percent":  "minimum playback percentage",
+	"sink_timeout":          "stop waiting for a sink after this many seconds",
+	"now_playing_refresh":   "send the now playing status again after this many seconds while a track plays (0 disables it)",
+	"dedupe_window":         "do not scrobble the same artist and track again within this many seconds (0 disables it)",
+	"undo_window":           "maximum age in seconds of scrobbles retracted by `goscrobble undo`",
+	"scrobble_timestamp":    `submit the time a track "start"ed playing or reached the "threshold"`,
+	"unknown_duration": `tracks without a duration (e.g., streams) are never scrobbled ("ignore"),
+scrobbled after min_playback_duration ("min_duration"), or their duration is
+looked up on last.fm ("lookup")`,
+	"notify_on_scrobble":    "send a desktop notification when a scrobble is saved",
+	"notify_on_error":       "send a desktop notification when a scrobble cannot be saved",
+	"hold_until_clock_sync": "hold scrobbles while the system clock is obviously wrong (e.g., before NTP synced it)",
+	"audit_log":             "record every submitted scrobble in $XDG_STATE_HOME/goscrobble/audit.jsonl",
+	"offline_queue":         "keep scrobbles that could not be submitted and retry them later",
+	"playback_journal":      "keep the current playback to recover it after a crash",
+	"watch_config":          "reload the configuration automatically when this file changes",
+	"dbus_service":          "register org.goscrobble.Daemon on the session bus (e.g., for status bar widgets)",
+	"opener": `command used to open URLs during authentication (e.g., ["firefox", "--new-window"])
+"%s" is replaced with the URL, if empty, $BROWSER is tried before xdg-open/open`,
+	"lint_ignore":   `rule IDs of check-config warnings to suppress (e.g., ["plaintext-secret"])`,
+	"blacklist":     "tracks and players matching any of these rules are ignored, see the README",
+	"whitelist":     "if not empty, only tracks matching one of these rules are scrobbled, see the README",
+	"regexes":       "regex match/replace of artist, track, and album names, see the README",
+	"player_groups": "multi-room session stitching, see the README",
+	"player_policy": `which players are scrobbled when several play at the same time: "all",
+"priority" (first match of player_priority), or "recent" (started last)`,
+	"player_priority": "regular expressions matched against player names, highest priority first",
+	"[players]": `override min_playback_duration and min_playback_percent for single players,
+keyed by player name, player identity, or source name`,
+	"[cache]":                         "on-disk cache for read-only lookups",
+	"cache.ttl":                       "seconds until a cached lookup expires",
+	"cache.max_size":                  "megabytes, the oldest entries are removed when the cache grows larger",
+	"[sources.dbus]":                  "MPRIS2 dbus interface\nhttps://specifications.freedesktop.org/mpris/latest/",
+	"sources.dbus.address":            "dbus address: if empty, connect to the session bus",
+	"[sources.media-control]":         "ungive/media-control\nhttps://github.com/ungive/media-control",
+	"sources.media-control.command":   `path to the "media-control" binary`,
+	"sources.media-control.arguments": "media-control arguments",
+	"[sources.applescript]":           "built-in macOS source for Apple Music and Spotify only, using AppleScript\nother players (e.g., browsers) are only read by media-control",
+	"sources.applescript.players":     "bundle identifiers of players to query, if empty use all supported players",
+	"[sources.spotify]":               "Spotify Web API, also reads Spotify Connect devices\nrun \"goscrobble auth login spotify\" to authenticate",
+	"sources.spotify.client_id":       "client ID of an app created at https://developer.spotify.com/dashboard",
+	"[sinks.lastfm]": `last.fm, create an API account at https://www.last.fm/api/account/create
+and run "goscrobble auth login" to authenticate`,
+	"[sinks.csv]": "local CSV file",
+	"[users]":     "additional users with their own sources and sinks",
+}
+
+// WriteCommented writes the configuration like Write, with a comment above
+// every known key and table.
+func (c Config) WriteCommented(filename string) error {
+	var buffer bytes.Buffer
+	encoder := toml.NewEncoder(&buffer)
+	encoder.Indent = ""
+	if err := encoder.Encode(c.persisted()); err != nil {
+		return err
+	}
+
+	var output strings.Builder
+	output.WriteString("# goscrobble configuration, see https://github.com/p-mng/goscrobble#configuration\n\n")
+
+	table := ""
+	for line := range strings.Lines(buffer.String()) {
+		key := ""
+		switch {
+		case strings.HasPrefix(line, "["):
+			table = strings.Trim(strings.TrimSpace(line), "[]")
+			key = "[" + table + "]"
+		case strings.Contains(line, " = "):
+			key = strings.SplitN(line, " = ", 2)[0]
+			if table != "" {
+				key = table + "." + key
+			}
+		}
+
+		if comment, ok := configComments[key]; ok {
+			for commentLine := range strings.Lines(comment) {
+				output.WriteString("# " + strings.TrimSuffix(commentLine, "\n") + "\n")
+			}
+		}
+		output.WriteString(line)
+	}
+
+	//nolint:gosec
+	return os.WriteFile(filename, []byte(output.String()), 0600)
+}
+
+// InitWizard interactively creates a configuration file.
+type InitWizard struct {
+	input  *bufio.Scanner
+	output io.Writer
+}
+
+func NewInitWizard(input io.Reader, output io.Writer) *InitWizard {
+	return &InitWizard{input: bufio.NewScanner(input), output: output}
+}
+
+// Ask prints the question and returns the answer, or the default value if
+// the answer is empty.
+func (w *InitWizard) Ask(question, defaultValue string) string {
+	if defaultValue != "" {
+		_, _ = fmt.Fprintf(w.output, "%s [%s] ", question, defaultValue)
+	} else {
+		_, _ = fmt.Fprintf(w.output, "%s ", question)
+	}
+
+	if !w.input.Scan() {
+		return defaultValue
+	}
+	answer := strings.TrimSpace(w.input.Text())
+	if answer == "" {
+		return defaultValue
+	}
+	return answer
+}
+
+// Confirm asks a yes/no question.
+func (w *InitWizard) Confirm(question string, defaultValue bool) bool {
+	options := "[y/N]"
+	if defaultValue {
+		options = "[Y/n]"
+	}
+
+	switch strings.ToLower(w.Ask(question+" "+options, "")) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return defaultValue
+	}
+}
+
+// Run asks for the sources and sinks to use. All other options keep their
+// default values. The operating system (e.g., runtime.GOOS) decides which
+// sources are suggested.
+func (w *InitWizard) Run(goos string) (Config, error) {
+	config := DefaultConfig
+	config.Sources = SourcesConfig{DBus: nil, MediaControl: nil, Webhook: nil, AppleScript: nil, UPnP: nil, Roon: nil, Spotify: nil}
+	config.Sinks = SinksConfig{LastFm: map[string]LastFmConfig{}, CSV: map[string]CSVConfig{}}
+
+	_, _ = fmt.Fprintln(w.output, "Sources (network sources like UPnP and Roon can be added to the config file later)")
+	if w.Confirm("Read players using MPRIS over D-Bus (Linux)?", goos == "linux") {
+		config.Sources.DBus = &DBusConfig{Address: "", FilterOptions: FilterOptions{Regexes: nil, Blacklist: nil, Whitelist: nil}}
+	}
+	if w.Confirm("Read players using media-control (macOS)?", goos == "darwin") {
+		config.Sources.MediaControl = &MediaControlConfig{
+			Command:       w.Ask("Path to the media-control binary", "media-control"),
+			Arguments:     []string{"get", "--now"},
+			FilterOptions: FilterOptions{Regexes: nil, Blacklist: nil, Whitelist: nil},
+		}
+	}
+	if w.Confirm("Read Apple Music and Spotify using AppleScript (macOS)?", false) {
+		config.Sources.AppleScript = &AppleScriptConfig{
+			Players:       []string{},
+			FilterOptions: FilterOptions{Regexes: nil, Blacklist: nil, Whitelist: nil},
+		}
+	}
+
+	if blacklist := w.Ask("Players to ignore (parts of their names, comma-separated, e.g., firefox)", ""); blacklist != "" {
+		for _, player := range strings.Split(blacklist, ",") {
+			config.Blacklist = append(config.Blacklist, FilterRule{
+				Match:  strings.TrimSpace(player),
+				Mode:   MatchSubstring,
+				Artist: false,
+				Track:  false,
+				Album:  false,
+				Player: true,
+			})
+		}
+	}
+
+	_, _ = fmt.Fprintln(w.output)
+	_, _ = fmt.Fprintln(w.output, "Sinks")
+	if w.Confirm("Save scrobbles to a local CSV file?", true) {
+		csvConfig := DefaultConfig.Sinks.CSV["default"]
+		csvConfig.Filename = w.Ask("File name", csvConfig.Filename)
+		config.Sinks.CSV["default"] = csvConfig
+	}
+
+	if w.Confirm("Scrobble to last.fm?", true) {
+		_, _ = fmt.Fprintln(w.output, "Create an API account at https://www.last.fm/api/account/create (the callback URL can be left empty)")
+
+		lastFmConfig := DefaultConfig.Sinks.LastFm["default"]
+		lastFmConfig.Key = w.Ask("last.fm API key:", "")
+		lastFmConfig.Secret = w.Ask("last.fm shared secret:", "")
+		if lastFmConfig.Key == "" || lastFmConfig.Secret == "" {
+			return config, errors.New("the last.fm API key and shared secret are required")
+		}
+		config.Sinks.LastFm["default"] = lastFmConfig
+	}
+
+	if len(config.Sinks.LastFm) == 0 && len(config.Sinks.CSV) == 0 {
+		return config, errors.New("at least one sink is required to save scrobbles")
+	}
+	return config, nil
+}
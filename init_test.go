@@ -0,0 +1,60 @@
+package main_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitWizard(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "scrobbles.csv")
+	answers := strings.Join([]string{
+		// dbus, media-control, applescript
+		"", "n", "",
+		"firefox, chromium",
+		// csv
+		"y", filename,
+		// last.fm
+		"", "key", "secret",
+	}, "\n") + "\n"
+
+	config, err := main.NewInitWizard(strings.NewReader(answers), io.Discard).Run("linux")
+	require.NoError(t, err)
+	require.NotNil(t, config.Sources.DBus)
+	require.Nil(t, config.Sources.MediaControl)
+	require.Nil(t, config.Sources.AppleScript)
+	require.Equal(t, []main.FilterRule{
+		{Match: "firefox", Mode: main.MatchSubstring, Player: true},
+		{Match: "chromium", Mode: main.MatchSubstring, Player: true},
+	}, config.Blacklist)
+	require.Equal(t, filename, config.Sinks.CSV["default"].Filename)
+	require.Equal(t, "key", config.Sinks.LastFm["default"].Key)
+	require.Equal(t, "secret", config.Sinks.LastFm["default"].Secret)
+	require.True(t, config.AuditLog)
+
+	_, err = main.NewInitWizard(strings.NewReader("n\nn\nn\n\nn\ny\nkey\n\n"), io.Discard).Run("linux")
+	require.ErrorContains(t, err, "shared secret")
+
+	_, err = main.NewInitWizard(strings.NewReader("n\nn\nn\n\nn\nn\n"), io.Discard).Run("linux")
+	require.ErrorContains(t, err, "at least one sink")
+}
+
+func TestWriteCommented(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), main.DefaultConfigFileName)
+	require.NoError(t, main.DefaultConfig.WriteCommented(filename))
+
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "# minimum playback percentage\nmin_playback_percent = 50\n")
+	require.Contains(t, string(data), "# dbus address: if empty, connect to the session bus\naddress = \"\"\n")
+
+	config, err := main.ReadConfig(filename)
+	require.NoError(t, err)
+	require.Equal(t, main.DefaultConfig.MinPlaybackPercent, config.MinPlaybackPercent)
+	require.Equal(t, main.DefaultConfig.Sinks.CSV, config.Sinks.CSV)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	JournalFileName = "playback.json"
+	// the journal is written at least this often while the tracks do not
+	// change, to keep the number of writes low on flash storage
+	journalInterval = 10 * time.Second
+)
+
+// JournalEntry is the in-progress playback of a single player.
+type JournalEntry struct {
+	Player string `json:"player"`
+	Source string `json:"source"`
+	// the timestamp of the scrobble is the time playback started
+	Status    PlaybackStatus `json:"status"`
+	Played    time.Duration  `json:"played"`
+	Scrobbled bool           `json:"scrobbled"`
+	Updated   time.Time      `json:"updated"`
+	// the position at the last poll, so the time played until the next poll
+	// after a restart is counted
+	Position time.Duration `json:"position"`
+}
+
+// PlaybackJournal persists the in-progress playback, so a qualifying play is
+// still scrobbled if the daemon crashes or the machine reboots before the
+// scrobble was sent, and a track that keeps playing across a restart is
+// neither scrobbled twice nor with the wrong timestamp. A nil journal does
+// nothing.
+type PlaybackJournal struct {
+	Filename string
+
+	written time.Time
+	key     string
+}
+
+func JournalFilename() string {
+	return filepath.Join(StateDir(), JournalFileName)
+}
+
+func NewPlaybackJournal(filename string) *PlaybackJournal {
+	return &PlaybackJournal{Filename: filename, written: time.Time{}, key: ""}
+}
+
+// JournalEntries returns the entries for all players with a track.
+func (s *LoopState) JournalEntries(now time.Time) []JournalEntry {
+	var entries []JournalEntry
+	for player, status := range s.PreviouslyPlaying {
+		if !status.HasMetadata() {
+			continue
+		}
+
+		entries = append(entries, JournalEntry{
+			Player:    player,
+			Source:    s.PlayerSources[player],
+			Status:    status,
+			Played:    s.PlayedTime[player],
+			Scrobbled: s.ScrobbledPrevious[player],
+			Updated:   now,
+			Position:  s.CurrentlyPlaying[player].Position,
+		})
+	}
+
+	slices.SortFunc(entries, func(a, b JournalEntry) int {
+		return strings.Compare(a.Player, b.Player)
+	})
+	return entries
+}
+
+func journalKey(entries []JournalEntry) string {
+	var key strings.Builder
+	for _, entry := range entries {
+		fmt.Fprintf(&key, "%s\x00%s\x00%t\n", entry.Player, entry.Status.Key(), entry.Scrobbled)
+	}
+	return key.String()
+}
+
+// Save writes the playback state if a track changed or was scrobbled, and
+// otherwise at most every journalInterval. Use force to write it regardless.
+func (j *PlaybackJournal) Save(state *LoopState, now time.Time, force bool) {
+	if j == nil {
+		return
+	}
+
+	entries := state.JournalEntries(now)
+	key := journalKey(entries)
+	if !force && key == j.key && now.Sub(j.written) < journalInterval {
+		return
+	}
+
+	if err := j.write(entries); err != nil {
+		log.Error().
+			Err(err).
+			Str("filename", j.Filename).
+			Msg("error writing playback journal")
+		return
+	}
+
+	j.key = key
+	j.written = now
+}
+
+func (j *PlaybackJournal) write(entries []JournalEntry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(j.Filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.Filename), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so a crash while writing does not
+	// corrupt the journal
+	temporary := j.Filename + ".tmp"
+	if err := os.WriteFile(temporary, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temporary, j.Filename)
+}
+
+func (j *PlaybackJournal) Read() ([]JournalEntry, error) {
+	//nolint:gosec
+	data, err := os.ReadFile(j.Filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entries []JournalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid playback journal: %s", err.Error())
+	}
+	return entries, nil
+}
+
+// Recover restores the playback state written before the daemon stopped.
+// Plays that were long enough are scrobbled with their original timestamp,
+// all other tracks continue if the player still plays them.
+func (j *PlaybackJournal) Recover(
+	state *LoopState,
+	options LoopOptions,
+	sinks []Sink,
+	notifier NotifierFunc,
+) {
+	if j == nil {
+		return
+	}
+
+	entries, err := j.Read()
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("filename", j.Filename).
+			Msg("error reading playback journal")
+		return
+	}
+
+	for _, entry := range entries {
+		state.PreviouslyPlaying[entry.Player] = entry.Status
+		state.ScrobbledPrevious[entry.Player] = entry.Scrobbled
+		state.PlayerSources[entry.Player] = entry.Source
+		state.PlayedTime[entry.Player] = entry.Played
+
+		lastSeen := entry.Status
+		lastSeen.Position = entry.Position
+		state.CurrentlyPlaying[entry.Player] = lastSeen
+		if entry.Updated.After(state.LastPoll) {
+			state.LastPoll = entry.Updated
+		}
+
+		if entry.Scrobbled {
+			continue
+		}
+
+		minPlaybackDuration, minPlaybackPercent := options.PlayerThresholds(entry.Player, entry.Source)
+		minPlayTime, err := MinPlayTime(entry.Status.Duration, minPlaybackDuration, minPlaybackPercent)
+		if err != nil || entry.Played < minPlayTime {
+			log.Debug().
+				Str("player", entry.Player).
+				Interface("status", entry.Status).
+				Msg("restored playback from journal")
+			continue
+		}
+
+		log.Info().
+			Str("player", entry.Player).
+			Interface("status", entry.Status).
+			Dur("played", entry.Played).
+			Msg("scrobbling track interrupted by restart")
+
+		state.ScrobbledPrevious[entry.Player] = true
+		state.Stats.CountScrobble(time.Now())
+		state.LastScrobble = entry.Status.Scrobble
+
+		status := entry.Status
+		status.Position = entry.Played
+		sendScrobble(state, options, entry.Player, sinks, status, notifier)
+	}
+
+	j.Save(state, time.Now(), true)
+}
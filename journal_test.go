@@ -0,0 +1,115 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlaybackJournal(t *testing.T) {
+	journal := main.NewPlaybackJournal(filepath.Join(t.TempDir(), main.JournalFileName))
+	state := main.NewLoopState()
+	state.Journal = journal
+	sink := &FakeSink{}
+	notifier := FakeNotifier{}
+
+	run := func(position time.Duration) {
+		status := defaultPlaybackStatus
+		status.Position = position
+		source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+		main.RunMainLoopOnce(state, replayOptions(), []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	}
+
+	run(0)
+	entries, err := journal.Read()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "player", entries[0].Player)
+	require.Equal(t, "dbus", entries[0].Source)
+	require.Equal(t, defaultScrobble.Track, entries[0].Status.Track)
+	require.False(t, entries[0].Scrobbled)
+
+	run(200 * time.Second)
+	require.Len(t, sink.ScrobbleLog, 1)
+	entries, err = journal.Read()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.True(t, entries[0].Scrobbled)
+
+	main.RunMainLoopOnce(state, replayOptions(), []main.Source{}, []main.Sink{sink}, notifier.SendNotification)
+	entries, err = journal.Read()
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestPlaybackJournalRecover(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), main.JournalFileName)
+	started := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+
+	status := defaultPlaybackStatus
+	status.Timestamp = started
+	interrupted := status
+	interrupted.Track = "Pure Morning"
+
+	previous := main.NewLoopState()
+	previous.PreviouslyPlaying = map[string]main.PlaybackStatus{"qualified": status, "interrupted": interrupted}
+	previous.PlayedTime = map[string]time.Duration{"qualified": 200 * time.Second, "interrupted": 30 * time.Second}
+	main.NewPlaybackJournal(filename).Save(previous, time.Now(), true)
+
+	state := main.NewLoopState()
+	state.Journal = main.NewPlaybackJournal(filename)
+	sink := &FakeSink{}
+	notifier := FakeNotifier{}
+	state.Journal.Recover(state, replayOptions(), []main.Sink{sink}, notifier.SendNotification)
+
+	require.Len(t, sink.ScrobbleLog, 1)
+	require.Equal(t, defaultScrobble.Track, sink.ScrobbleLog[0].Track)
+	require.True(t, started.Equal(sink.ScrobbleLog[0].Timestamp))
+
+	// the interrupted track continues with its original timestamp and the
+	// time played before the restart
+	for _, position := range []time.Duration{40 * time.Second, 140 * time.Second} {
+		playing := interrupted
+		playing.Position = position
+		source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"interrupted": playing}, err: nil}
+		main.RunMainLoopOnce(state, replayOptions(), []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	}
+
+	require.Len(t, sink.ScrobbleLog, 2)
+	require.Equal(t, "Pure Morning", sink.ScrobbleLog[1].Track)
+	require.True(t, started.Equal(sink.ScrobbleLog[1].Timestamp))
+}
+
+func TestPlaybackJournalAcrossRuns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), main.JournalFileName)
+	sink := &FakeSink{}
+	notifier := FakeNotifier{}
+
+	// every run (e.g., of `goscrobble run --once`) starts with a new state
+	run := func(position time.Duration, saved time.Time) {
+		state := main.NewLoopState()
+		state.Journal = main.NewPlaybackJournal(filename)
+		state.Journal.Recover(state, replayOptions(), []main.Sink{sink}, notifier.SendNotification)
+
+		status := defaultPlaybackStatus
+		status.Position = position
+		source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+		main.RunMainLoopOnce(state, replayOptions(), []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+		state.Journal.Save(state, saved, true)
+	}
+
+	run(10*time.Second, time.Now().Add(-3*time.Minute))
+	entries, err := main.NewPlaybackJournal(filename).Read()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, 10*time.Second, entries[0].Position)
+	require.Empty(t, sink.ScrobbleLog)
+
+	// the track played on for three minutes between the runs
+	run(190*time.Second, time.Now())
+	require.Len(t, sink.ScrobbleLog, 1)
+	require.Equal(t, defaultScrobble.Track, sink.ScrobbleLog[0].Track)
+}
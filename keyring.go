@@ -0,0 +1,152 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+const (
+	// secrets are stored in the system keyring under this service name, with
+	// the name of the secret (e.g., `sinks.lastfm.default.session_key`) as
+	// account
+	keyringService = "goscrobble"
+	// KeyringPrefix marks secrets in the config file that are stored in the
+	// system keyring, followed by the account (e.g.,
+	// `keyring:sinks.lastfm.default.session_key`). Without an account, the
+	// name of the secret is used.
+	KeyringPrefix = "keyring:"
+)
+
+var ErrKeyringNotFound = errors.New("secret not found in the system keyring")
+
+// ResolveKeyringSecrets replaces all secrets that reference the system
+// keyring with the secrets returned by get (e.g., KeyringGet). Secrets read
+// from commands are left out.
+func (c *Config) ResolveKeyringSecrets(get func(account string) (string, error)) error {
+	resolved := map[string]string{}
+	err := c.eachSecret(func(subject string, command []string, secret *string) error {
+		account, ok := strings.CutPrefix(*secret, KeyringPrefix)
+		if len(command) > 0 || !ok {
+			return nil
+		}
+		if account == "" {
+			account = subject
+		}
+
+		value, err := get(account)
+		if err != nil {
+			return fmt.Errorf("error reading %s from the system keyring: %s", subject, err.Error())
+		}
+		*secret = value
+		resolved[subject] = account
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(resolved) > 0 {
+		c.keyringSecrets = resolved
+	}
+	return nil
+}
+
+// InKeyring reports whether the secret with the given name (e.g.,
+// `sinks.lastfm.default.session_key`) was read from the system keyring.
+func (c Config) InKeyring(subject string) bool {
+	_, ok := c.keyringSecrets[subject]
+	return ok
+}
+
+// StoreInKeyring saves a secret in the system keyring using set (e.g.,
+// KeyringSet). When the config is written, the secret is replaced by a
+// reference to the keyring.
+func (c *Config) StoreInKeyring(subject, secret string, set func(account, secret string) error) error {
+	account, ok := c.keyringSecrets[subject]
+	if !ok {
+		account = subject
+	}
+	if err := set(account, secret); err != nil {
+		return err
+	}
+
+	c.keyringSecrets = maps.Clone(c.keyringSecrets)
+	if c.keyringSecrets == nil {
+		c.keyringSecrets = map[string]string{}
+	}
+	c.keyringSecrets[subject] = account
+	return nil
+}
+
+// RemoveFromKeyring deletes a secret stored in the system keyring using
+// remove (e.g., KeyringDelete), so it is written to the config file again.
+// Secrets that are not stored in the keyring are ignored.
+func (c *Config) RemoveFromKeyring(subject string, remove func(account string) error) error {
+	account, ok := c.keyringSecrets[subject]
+	if !ok {
+		return nil
+	}
+	if err := remove(account); err != nil && !errors.Is(err, ErrKeyringNotFound) {
+		return err
+	}
+
+	c.keyringSecrets = maps.Clone(c.keyringSecrets)
+	delete(c.keyringSecrets, subject)
+	return nil
+}
+
+// SecretStorage describes where a secret of the config is stored.
+type SecretStorage string
+
+const (
+	SecretUnset     = SecretStorage("unset")
+	SecretCommand   = SecretStorage("command")
+	SecretKeyring   = SecretStorage("keyring")
+	SecretPlaintext = SecretStorage("config file")
+)
+
+// ConfigSecret is a secret of the config, e.g. for `goscrobble keyring list`.
+type ConfigSecret struct {
+	// e.g. `sinks.lastfm.default.session_key`
+	Name    string
+	Storage SecretStorage
+	// the account in the system keyring, if stored there
+	Account string
+}
+
+// Secrets returns all secrets of the config that can be stored in the system
+// keyring or read from a command. Secrets that reference the keyring are
+// reported as stored there, whether they were resolved or not.
+func (c Config) Secrets() []ConfigSecret {
+	var secrets []ConfigSecret
+	_ = c.eachSecret(func(subject string, command []string, secret *string) error {
+		storage, account := SecretUnset, ""
+		reference, isReference := strings.CutPrefix(*secret, KeyringPrefix)
+		switch {
+		case len(command) > 0:
+			storage = SecretCommand
+		case c.InKeyring(subject):
+			storage, account = SecretKeyring, c.keyringSecrets[subject]
+		case isReference:
+			storage, account = SecretKeyring, cmp.Or(reference, subject)
+		case *secret != "":
+			storage = SecretPlaintext
+		}
+		secrets = append(secrets, ConfigSecret{Name: subject, Storage: storage, Account: account})
+		return nil
+	})
+	return secrets
+}
+
+// FindSecret looks up a secret of the config by its name.
+func (c Config) FindSecret(name string) (ConfigSecret, error) {
+	for _, secret := range c.Secrets() {
+		if secret.Name == name {
+			return secret, nil
+		}
+	}
+	return ConfigSecret{}, fmt.Errorf("unknown secret: %s (run `goscrobble keyring list` to list all secrets)", name)
+}
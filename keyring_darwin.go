@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// exit status of the security tool if an item does not exist
+const securityItemNotFound = 44
+
+// accounts are passed to the interactive mode of the security tool, which
+// splits commands on whitespace
+var keychainAccount = regexp.MustCompile(`^[A-Za-z0-9._:@-]+$`)
+
+// KeyringAvailable always returns true, since the login keychain is available
+// to all user sessions.
+func KeyringAvailable() bool {
+	return true
+}
+
+// KeyringGet reads a generic password from the login keychain.
+func KeyringGet(account string) (string, error) {
+	output, err := exec.Command("/usr/bin/security", "find-generic-password", "-s", keyringService, "-a", account, "-w").Output()
+	if exitErr := (*exec.ExitError)(nil); errors.As(err, &exitErr) && exitErr.ExitCode() == securityItemNotFound {
+		return "", ErrKeyringNotFound
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
+
+// KeyringSet saves a generic password in the login keychain, replacing an
+// existing one of the same account. The secret is written to the standard
+// input of the security tool, so it does not show up in the process list.
+func KeyringSet(account, secret string) error {
+	if !keychainAccount.MatchString(account) {
+		return fmt.Errorf("invalid keychain account: %q", account)
+	}
+
+	cmd := exec.Command("/usr/bin/security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf(
+		"add-generic-password -U -s %s -a %s -X %s\n",
+		keyringService, account, hex.EncodeToString([]byte(secret)),
+	))
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// KeyringDelete removes a generic password from the login keychain.
+func KeyringDelete(account string) error {
+	err := exec.Command("/usr/bin/security", "delete-generic-password", "-s", keyringService, "-a", account).Run()
+	if exitErr := (*exec.ExitError)(nil); errors.As(err, &exitErr) && exitErr.ExitCode() == securityItemNotFound {
+		return ErrKeyringNotFound
+	}
+	return err
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretServiceName       = "org.freedesktop.secrets"
+	secretServicePath       = "/org/freedesktop/secrets"
+	secretServiceInterface  = "org.freedesktop.Secret.Service"
+	secretDefaultCollection = "/org/freedesktop/secrets/aliases/default"
+	// unlocking the keyring may show a password prompt
+	secretPromptTimeout = 2 * time.Minute
+)
+
+// KeyringAvailable reports whether a Secret Service provider (e.g., GNOME
+// Keyring or KWallet) is running or can be activated on the session bus.
+func KeyringAvailable() bool {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return false
+	}
+	defer CloseLogged(conn)
+
+	var names []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err == nil &&
+		slices.Contains(names, secretServiceName) {
+		return true
+	}
+
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListActivatableNames", 0).Store(&names); err == nil &&
+		slices.Contains(names, secretServiceName) {
+		return true
+	}
+
+	return false
+}
+
+// KeyringGet reads a secret from the Secret Service.
+func KeyringGet(account string) (string, error) {
+	service, err := openSecretService()
+	if err != nil {
+		return "", err
+	}
+	defer CloseLogged(service)
+
+	item, err := service.find(account)
+	if err != nil {
+		return "", err
+	}
+
+	var secret secretServiceSecret
+	if err := service.object(item).Call("org.freedesktop.Secret.Item.GetSecret", 0, service.session).Store(&secret); err != nil {
+		return "", err
+	}
+	return string(secret.Value), nil
+}
+
+// KeyringSet saves a secret in the default collection of the Secret Service,
+// replacing an existing secret of the same account.
+func KeyringSet(account, secret string) error {
+	service, err := openSecretService()
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(service)
+
+	if err := service.unlock(secretDefaultCollection); err != nil {
+		return err
+	}
+
+	properties := map[string]dbus.Variant{
+		"org.freedesktop.Secret.Item.Label":      dbus.MakeVariant(keyringService + ": " + account),
+		"org.freedesktop.Secret.Item.Attributes": dbus.MakeVariant(secretAttributes(account)),
+	}
+	value := secretServiceSecret{Session: service.session, Parameters: []byte{}, Value: []byte(secret), ContentType: "text/plain"}
+
+	var item, prompt dbus.ObjectPath
+	if err := service.object(secretDefaultCollection).
+		Call("org.freedesktop.Secret.Collection.CreateItem", 0, properties, value, true).
+		Store(&item, &prompt); err != nil {
+		return err
+	}
+	return service.prompt(prompt)
+}
+
+// KeyringDelete removes a secret from the Secret Service.
+func KeyringDelete(account string) error {
+	service, err := openSecretService()
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(service)
+
+	item, err := service.find(account)
+	if err != nil {
+		return err
+	}
+
+	var prompt dbus.ObjectPath
+	if err := service.object(item).Call("org.freedesktop.Secret.Item.Delete", 0).Store(&prompt); err != nil {
+		return err
+	}
+	return service.prompt(prompt)
+}
+
+// secretServiceSecret is the Secret struct of the Secret Service API.
+type secretServiceSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+func secretAttributes(account string) map[string]string {
+	return map[string]string{"service": keyringService, "account": account}
+}
+
+// secretService is a session with the Secret Service. Secrets are
+// transferred without encryption, which is fine on the session bus.
+type secretService struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+func openSecretService() (*secretService, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	if err := conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceInterface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session); err != nil {
+		CloseLogged(conn)
+		return nil, err
+	}
+
+	return &secretService{conn: conn, session: session}, nil
+}
+
+func (s *secretService) object(path dbus.ObjectPath) dbus.BusObject {
+	return s.conn.Object(secretServiceName, path)
+}
+
+func (s *secretService) Close() error {
+	_ = s.object(s.session).Call("org.freedesktop.Secret.Session.Close", 0).Err
+	return s.conn.Close()
+}
+
+// find returns the item of the account, unlocking it if needed.
+func (s *secretService) find(account string) (dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	if err := s.object(secretServicePath).
+		Call(secretServiceInterface+".SearchItems", 0, secretAttributes(account)).
+		Store(&unlocked, &locked); err != nil {
+		return "", err
+	}
+
+	switch {
+	case len(unlocked) > 0:
+		return unlocked[0], nil
+	case len(locked) > 0:
+		return locked[0], s.unlock(locked[0])
+	default:
+		return "", ErrKeyringNotFound
+	}
+}
+
+func (s *secretService) unlock(path dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	if err := s.object(secretServicePath).
+		Call(secretServiceInterface+".Unlock", 0, []dbus.ObjectPath{path}).
+		Store(&unlocked, &prompt); err != nil {
+		return err
+	}
+	return s.prompt(prompt)
+}
+
+// prompt shows a prompt of the Secret Service (e.g., to unlock the keyring)
+// and waits until the user completed or dismissed it. The path `/` means that
+// no prompt is needed.
+func (s *secretService) prompt(path dbus.ObjectPath) error {
+	if path == "/" || path == "" {
+		return nil
+	}
+
+	options := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface("org.freedesktop.Secret.Prompt"),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := s.conn.AddMatchSignal(options...); err != nil {
+		return err
+	}
+	defer func() { _ = s.conn.RemoveMatchSignal(options...) }()
+
+	signals := make(chan *dbus.Signal, 1)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+
+	if err := s.object(path).Call("org.freedesktop.Secret.Prompt.Prompt", 0, "").Err; err != nil {
+		return err
+	}
+
+	timeout := time.After(secretPromptTimeout)
+	for {
+		select {
+		case signal, ok := <-signals:
+			if !ok {
+				return errors.New("connection to the session bus closed")
+			}
+			if signal.Path != path || signal.Name != "org.freedesktop.Secret.Prompt.Completed" {
+				continue
+			}
+			if len(signal.Body) > 0 && signal.Body[0] == true {
+				return errors.New("the keyring prompt was dismissed")
+			}
+			return nil
+		case <-timeout:
+			return errors.New("timed out waiting for the keyring prompt")
+		}
+	}
+}
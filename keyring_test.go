@@ -0,0 +1,120 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKeyring map[string]string
+
+func (k fakeKeyring) Get(account string) (string, error) {
+	secret, ok := k[account]
+	if !ok {
+		return "", main.ErrKeyringNotFound
+	}
+	return secret, nil
+}
+
+func (k fakeKeyring) Set(account, secret string) error {
+	k[account] = secret
+	return nil
+}
+
+func (k fakeKeyring) Delete(account string) error {
+	if _, ok := k[account]; !ok {
+		return main.ErrKeyringNotFound
+	}
+	delete(k, account)
+	return nil
+}
+
+func TestResolveKeyringSecrets(t *testing.T) {
+	keyring := fakeKeyring{
+		"sinks.lastfm.default.session_key": "session key",
+		"api":                              "api token",
+	}
+
+	config := main.Config{
+		API: &main.APIConfig{Address: "127.0.0.1:0", Token: "keyring:api"},
+		Sinks: main.SinksConfig{LastFm: map[string]main.LastFmConfig{
+			"default": {Key: "key", Secret: "secret", SessionKey: "keyring:", Username: "user"},
+		}},
+	}
+	require.NoError(t, config.ResolveKeyringSecrets(keyring.Get))
+	require.Equal(t, "api token", config.API.Token)
+	require.Equal(t, "session key", config.Sinks.LastFm["default"].SessionKey)
+	require.Equal(t, "secret", config.Sinks.LastFm["default"].Secret)
+	require.True(t, config.InKeyring("api.token"))
+	require.True(t, config.InKeyring("sinks.lastfm.default.session_key"))
+	require.False(t, config.InKeyring("sinks.lastfm.default.secret"))
+
+	// secrets read from the keyring are not reported as plaintext
+	warnings := config.Lint(true)
+	require.Len(t, warnings, 1)
+	require.Equal(t, "sinks.lastfm.default", warnings[0].Subject)
+	require.Contains(t, warnings[0].Message, "API secret")
+
+	config.Sinks.LastFm["default"] = main.LastFmConfig{SessionKey: "keyring:missing"}
+	require.ErrorContains(t, config.ResolveKeyringSecrets(keyring.Get), "sinks.lastfm.default.session_key")
+}
+
+func TestStoreInKeyring(t *testing.T) {
+	keyring := fakeKeyring{}
+	filename := filepath.Join(t.TempDir(), "config.toml")
+
+	config := main.DefaultConfig
+	config.Sinks.LastFm = map[string]main.LastFmConfig{
+		"default": {Key: "key", Secret: "secret", SessionKey: "session key", Username: "user"},
+	}
+	require.NoError(t, config.StoreInKeyring("sinks.lastfm.default.session_key", "session key", keyring.Set))
+	require.Equal(t, fakeKeyring{"sinks.lastfm.default.session_key": "session key"}, keyring)
+	require.False(t, main.DefaultConfig.InKeyring("sinks.lastfm.default.session_key"))
+
+	// secrets stored in the keyring are written as references
+	require.NoError(t, config.Write(filename))
+	written, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Contains(t, string(written), `session_key = "keyring:sinks.lastfm.default.session_key"`)
+	require.NotContains(t, string(written), "session key")
+	require.Equal(t, "session key", config.Sinks.LastFm["default"].SessionKey)
+
+	secret, err := config.FindSecret("sinks.lastfm.default.session_key")
+	require.NoError(t, err)
+	require.Equal(t, main.ConfigSecret{
+		Name:    "sinks.lastfm.default.session_key",
+		Storage: main.SecretKeyring,
+		Account: "sinks.lastfm.default.session_key",
+	}, secret)
+
+	_, err = config.FindSecret("sinks.lastfm.default.username")
+	require.Error(t, err)
+
+	require.NoError(t, config.RemoveFromKeyring("sinks.lastfm.default.session_key", keyring.Delete))
+	require.Empty(t, keyring)
+	require.False(t, config.InKeyring("sinks.lastfm.default.session_key"))
+
+	// secrets that are already gone from the keyring are ignored
+	require.NoError(t, config.StoreInKeyring("sinks.lastfm.default.session_key", "session key", keyring.Set))
+	delete(keyring, "sinks.lastfm.default.session_key")
+	require.NoError(t, config.RemoveFromKeyring("sinks.lastfm.default.session_key", keyring.Delete))
+}
+
+func TestConfigSecrets(t *testing.T) {
+	config := main.Config{
+		API: &main.APIConfig{Address: "127.0.0.1:0", TokenCmd: []string{"pass", "goscrobble"}},
+		Sinks: main.SinksConfig{LastFm: map[string]main.LastFmConfig{
+			"default": {Key: "key", Secret: "keyring:lastfm"},
+		}},
+	}
+
+	require.Equal(t, []main.ConfigSecret{
+		{Name: "api.token", Storage: main.SecretCommand, Account: ""},
+		{Name: "sinks.lastfm.default.key", Storage: main.SecretPlaintext, Account: ""},
+		{Name: "sinks.lastfm.default.secret", Storage: main.SecretKeyring, Account: "lastfm"},
+		{Name: "sinks.lastfm.default.session_key", Storage: main.SecretUnset, Account: ""},
+	}, config.Secrets())
+}
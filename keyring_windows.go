@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+)
+
+var (
+	advapi32    = windows.NewLazySystemDLL("advapi32.dll")
+	credReadW   = advapi32.NewProc("CredReadW")
+	credWriteW  = advapi32.NewProc("CredWriteW")
+	credDeleteW = advapi32.NewProc("CredDeleteW")
+	credFree    = advapi32.NewProc("CredFree")
+)
+
+// credential is the CREDENTIALW struct of the Credential Manager API.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// KeyringAvailable always returns true, since the Windows Credential Manager
+// is available to all user sessions.
+func KeyringAvailable() bool {
+	return true
+}
+
+func credentialTarget(account string) (*uint16, error) {
+	return windows.UTF16PtrFromString(keyringService + ":" + account)
+}
+
+// KeyringGet reads a generic credential from the Credential Manager.
+func KeyringGet(account string) (string, error) {
+	target, err := credentialTarget(account)
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	if ok, _, err := credReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); ok == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return "", ErrKeyringNotFound
+		}
+		return "", err
+	}
+	defer func() { _, _, _ = credFree.Call(uintptr(unsafe.Pointer(cred))) }()
+
+	if cred.CredentialBlobSize == 0 {
+		return "", nil
+	}
+	return string(unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)), nil
+}
+
+// KeyringSet saves a generic credential in the Credential Manager, replacing
+// an existing one of the same account.
+func KeyringSet(account, secret string) error {
+	target, err := credentialTarget(account)
+	if err != nil {
+		return err
+	}
+	userName, err := windows.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(secret)
+	cred := credential{
+		Flags:              0,
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		Comment:            nil,
+		LastWritten:        windows.Filetime{LowDateTime: 0, HighDateTime: 0},
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     nil,
+		Persist:            credPersistLocalMachine,
+		AttributeCount:     0,
+		Attributes:         0,
+		TargetAlias:        nil,
+		UserName:           userName,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+
+	if ok, _, err := credWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); ok == 0 {
+		return err
+	}
+	return nil
+}
+
+// KeyringDelete removes a generic credential from the Credential Manager.
+func KeyringDelete(account string) error {
+	target, err := credentialTarget(account)
+	if err != nil {
+		return err
+	}
+
+	if ok, _, err := credDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); ok == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return ErrKeyringNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/term"
+)
+
+const (
+	ansiClearScreen = "\x1b[H\x1b[2J"
+	ansiHideCursor  = "\x1b[?25l"
+	ansiShowCursor  = "\x1b[?25h"
+	ansiBold        = "\x1b[1m"
+	ansiDim         = "\x1b[2m"
+	ansiReset       = "\x1b[0m"
+)
+
+// RunKiosk renders the current track full-screen until interrupted. It never
+// sends anything to sinks. While the daemon is running, it shows the players
+// reported on the control socket, otherwise it polls the sources of
+// KioskConfig itself. Album art is not displayed, since terminals have no
+// portable way to show images. In accessible mode, the current track is
+// printed as linear text whenever it changes instead.
+func RunKiosk(config Config, accessible bool) {
+	config = KioskConfig(config)
+	playerBlacklist := config.ParseBlacklist()
+	parsedRegexes := config.ParseRegexes()
+	sources := config.SetupSources()
+	defer CloseSources(sources)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	ticker := time.NewTicker(time.Second * time.Duration(config.PollRate))
+	defer ticker.Stop()
+
+	if !accessible {
+		fmt.Print(ansiHideCursor)
+		defer fmt.Print(ansiClearScreen + ansiShowCursor)
+	}
+
+	printed := ""
+	for {
+		var playbackStatus map[string]PlaybackStatus
+		if status, ok := QueryDaemonStatus(); ok {
+			playbackStatus = DaemonPlaybackStatus(status)
+		} else {
+			playbackStatus = map[string]PlaybackStatus{}
+			for _, source := range sources {
+				status, err := source.GetInfo(playerBlacklist, parsedRegexes)
+				if err != nil {
+					log.Debug().
+						Err(err).
+						Str("source", source.Name()).
+						Msg("error getting current playback status")
+				}
+				maps.Copy(playbackStatus, status)
+			}
+		}
+
+		player, status, ok := KioskPlayer(playbackStatus)
+		if !ok {
+			player = ""
+		}
+
+		if accessible {
+			text := RenderKioskAccessible(player, status, ok)
+			if text != printed {
+				fmt.Println(text)
+				printed = text
+			}
+		} else {
+			width, height, err := term.GetSize(int(os.Stdout.Fd()))
+			if err != nil {
+				width, height = 80, 24
+			}
+
+			if ok {
+				fmt.Print(RenderKiosk(player, &status, width, height))
+			} else {
+				fmt.Print(RenderKiosk("", nil, width, height))
+			}
+		}
+
+		select {
+		case <-signals:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// KioskConfig removes the sources that listen for updates from the
+// configuration, since their addresses belong to the daemon. Their players are
+// only shown while the daemon is running.
+func KioskConfig(config Config) Config {
+	if config.Sources.Webhook != nil || config.Sources.UPnP != nil {
+		log.Info().Msg("webhook and UPnP sources are only shown while the daemon is running")
+	}
+
+	config.Sources.Webhook = nil
+	config.Sources.UPnP = nil
+	return config
+}
+
+// DaemonPlaybackStatus returns the playback status of the players reported by
+// a running daemon.
+func DaemonPlaybackStatus(status DaemonStatus) map[string]PlaybackStatus {
+	playbackStatus := map[string]PlaybackStatus{}
+	for _, progress := range status.Players {
+		playbackStatus[progress.Player] = progress.Status
+	}
+	return playbackStatus
+}
+
+// KioskPlayer picks the player to display, preferring players that are
+// currently playing. Ties are broken by player name to avoid flickering.
+func KioskPlayer(playbackStatus map[string]PlaybackStatus) (string, PlaybackStatus, bool) {
+	var fallback string
+	for _, player := range slices.Sorted(maps.Keys(playbackStatus)) {
+		status := playbackStatus[player]
+		if !status.IsValid() {
+			continue
+		}
+		if status.State == PlaybackPlaying {
+			return player, status, true
+		}
+		if fallback == "" && status.State == PlaybackPaused {
+			fallback = player
+		}
+	}
+
+	if fallback != "" {
+		return fallback, playbackStatus[fallback], true
+	}
+	return "", PlaybackStatus{}, false
+}
+
+func RenderKiosk(player string, status *PlaybackStatus, width, height int) string {
+	var lines []string
+	if status == nil {
+		lines = []string{ansiDim + centerText("nothing playing", width) + ansiReset}
+	} else {
+		barWidth := max(width-20, 10)
+		progress := 0.0
+		if status.Duration > 0 {
+			progress = min(float64(status.Position)/float64(status.Duration), 1)
+		}
+		filled := int(progress * float64(barWidth))
+
+		position := formatDuration(status.Position)
+		if position == "" {
+			position = "00:00"
+		}
+
+		bar := fmt.Sprintf("%s %s%s %s",
+			position,
+			strings.Repeat("━", filled),
+			strings.Repeat("─", barWidth-filled),
+			status.PrettyDuration(),
+		)
+
+		state := string(RuneBeamedSixteenthNotes)
+		if status.State != PlaybackPlaying {
+			state = strings.ToLower(string(status.State))
+		}
+
+		lines = []string{
+			ansiBold + centerText(status.Track, width) + ansiReset,
+			"",
+			centerText(status.JoinArtists(), width),
+			ansiDim + centerText(status.Album, width) + ansiReset,
+			"",
+			"",
+			centerText(bar, width),
+			"",
+			ansiDim + centerText(fmt.Sprintf("%s %c %s", state, RuneEmDash, player), width) + ansiReset,
+		}
+	}
+
+	padding := max((height-len(lines))/2, 0)
+
+	var builder strings.Builder
+	builder.WriteString(ansiClearScreen)
+	builder.WriteString(strings.Repeat("\n", padding))
+	builder.WriteString(strings.Join(lines, "\n"))
+	return builder.String()
+}
+
+// RenderKioskAccessible describes the current track as `label: value` lines.
+// The position is left out, so the text only changes with the track or the
+// playback state.
+func RenderKioskAccessible(player string, status PlaybackStatus, ok bool) string {
+	if !ok {
+		return "Nothing playing\n"
+	}
+
+	return fmt.Sprintf("Track: %s\nArtists: %s\nAlbum: %s\nDuration: %s\nState: %s\nPlayer: %s\n",
+		status.Track,
+		status.JoinArtists(),
+		status.Album,
+		status.PrettyDuration(),
+		strings.ToLower(string(status.State)),
+		player,
+	)
+}
+
+func centerText(text string, width int) string {
+	text = TruncateText(text, width)
+	return strings.Repeat(" ", (width-utf8.RuneCountInString(text))/2) + text
+}
@@ -0,0 +1,78 @@
+package main_test
+
+import (
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKioskPlayer(t *testing.T) {
+	paused := defaultPlaybackStatus
+	paused.State = main.PlaybackPaused
+
+	player, status, ok := main.KioskPlayer(map[string]main.PlaybackStatus{
+		"a": paused,
+		"b": defaultPlaybackStatus,
+		"c": {},
+	})
+	require.True(t, ok)
+	require.Equal(t, "b", player)
+	require.Equal(t, main.PlaybackPlaying, status.State)
+
+	player, _, ok = main.KioskPlayer(map[string]main.PlaybackStatus{"a": paused})
+	require.True(t, ok)
+	require.Equal(t, "a", player)
+
+	_, _, ok = main.KioskPlayer(map[string]main.PlaybackStatus{})
+	require.False(t, ok)
+}
+
+func TestRenderKiosk(t *testing.T) {
+	rendered := main.RenderKiosk("fake player", &defaultPlaybackStatus, 80, 24)
+	require.Contains(t, rendered, defaultPlaybackStatus.Track)
+	require.Contains(t, rendered, "Placebo, David Bowie")
+	require.Contains(t, rendered, "01:50")
+	require.Contains(t, rendered, "04:11")
+
+	rendered = main.RenderKiosk("", nil, 80, 24)
+	require.Contains(t, rendered, "nothing playing")
+}
+
+func TestRenderKioskAccessible(t *testing.T) {
+	rendered := main.RenderKioskAccessible("fake player", defaultPlaybackStatus, true)
+	require.Contains(t, rendered, "Track: "+defaultPlaybackStatus.Track+"\n")
+	require.Contains(t, rendered, "Artists: Placebo, David Bowie\n")
+	require.Contains(t, rendered, "State: playing\n")
+	require.NotContains(t, rendered, "01:50")
+
+	moved := defaultPlaybackStatus
+	moved.Position += 10
+	require.Equal(t, rendered, main.RenderKioskAccessible("fake player", moved, true))
+
+	require.Equal(t, "Nothing playing\n", main.RenderKioskAccessible("", main.PlaybackStatus{}, false))
+}
+
+func TestKioskConfig(t *testing.T) {
+	config := main.DefaultConfig
+	config.Sources.DBus = &main.DBusConfig{}
+	config.Sources.Webhook = &main.WebhookConfig{Address: ":9000"}
+	config.Sources.UPnP = &main.UPnPConfig{CallbackAddress: ":9001"}
+
+	kiosk := main.KioskConfig(config)
+	require.NotNil(t, kiosk.Sources.DBus)
+	require.Nil(t, kiosk.Sources.Webhook)
+	require.Nil(t, kiosk.Sources.UPnP)
+
+	// the configuration of the caller is kept
+	require.NotNil(t, config.Sources.Webhook)
+}
+
+func TestDaemonPlaybackStatus(t *testing.T) {
+	playbackStatus := main.DaemonPlaybackStatus(main.DaemonStatus{
+		Players: []main.PlayerProgress{{Player: "dbus:spotify", Status: defaultPlaybackStatus}},
+	})
+	require.Equal(t, map[string]main.PlaybackStatus{"dbus:spotify": defaultPlaybackStatus}, playbackStatus)
+
+	require.Empty(t, main.DaemonPlaybackStatus(main.DaemonStatus{}))
+}
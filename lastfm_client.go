@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	lastfm "github.com/p-mng/lastfm-go"
+)
+
+var lastFmMD5 = regexp.MustCompile("^[a-f0-9]{32}$")
+
+// LastFmClient sends the API requests of last.fm sinks using its own HTTP
+// client. lastfm-go always uses the default HTTP client, so only its response
+// types are used.
+type LastFmClient struct {
+	BaseURL string
+	Key     string
+	Secret  string
+	HTTP    *http.Client
+}
+
+func NewLastFmClient(baseURL, key, secret string, httpClient *http.Client) (LastFmClient, error) {
+	switch {
+	case baseURL == "":
+		return LastFmClient{}, errors.New("invalid URL")
+	case !lastFmMD5.MatchString(key):
+		return LastFmClient{}, errors.New("invalid API key")
+	case !lastFmMD5.MatchString(secret):
+		return LastFmClient{}, errors.New("invalid API secret")
+	}
+
+	if _, err := url.Parse(baseURL); err != nil {
+		return LastFmClient{}, fmt.Errorf("invalid URL: %s", err.Error())
+	}
+
+	return LastFmClient{BaseURL: baseURL, Key: key, Secret: secret, HTTP: httpClient}, nil
+}
+
+func (c LastFmClient) TrackUpdateNowPlaying(params lastfm.P) (lastfm.TrackUpdateNowPlayingResponse, error) {
+	return lastFmRequest[lastfm.TrackUpdateNowPlayingResponse](c, http.MethodPost, "track.updateNowPlaying", params)
+}
+
+func (c LastFmClient) TrackScrobble(params lastfm.P) (lastfm.TrackScrobbleResponse, error) {
+	return lastFmRequest[lastfm.TrackScrobbleResponse](c, http.MethodPost, "track.scrobble", params)
+}
+
+func (c LastFmClient) TrackLove(params lastfm.P) (lastfm.TrackLoveResponse, error) {
+	return lastFmRequest[lastfm.TrackLoveResponse](c, http.MethodPost, "track.love", params)
+}
+
+func (c LastFmClient) TrackUnlove(params lastfm.P) (lastfm.TrackUnloveResponse, error) {
+	return lastFmRequest[lastfm.TrackUnloveResponse](c, http.MethodPost, "track.unlove", params)
+}
+
+func (c LastFmClient) TrackGetInfo(params lastfm.P) (lastfm.TrackGetInfoResponse, error) {
+	return lastFmRequest[lastfm.TrackGetInfoResponse](c, http.MethodGet, "track.getInfo", params)
+}
+
+func (c LastFmClient) UserGetRecentTracks(params lastfm.P) (lastfm.UserGetRecentTracksResponse, error) {
+	return lastFmRequest[lastfm.UserGetRecentTracksResponse](c, http.MethodGet, "user.getRecentTracks", params)
+}
+
+// LastFmError is an error returned by the last.fm API.
+//
+// https://www.last.fm/api/errorcodes
+type LastFmError struct {
+	Code    int64
+	Message string
+}
+
+// same format as lastfm-go, e.g. for IsLastFmRateLimit
+func (e LastFmError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// lastFmRequest sends a request to the last.fm API and decodes the response.
+// POST requests write data and are signed.
+//
+// https://www.last.fm/api/rest
+func lastFmRequest[T any](c LastFmClient, httpMethod, apiMethod string, params lastfm.P) (T, error) {
+	var decoded T
+
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, fmt.Sprint(value))
+	}
+	values.Set("method", apiMethod)
+	values.Set("api_key", c.Key)
+
+	var request *http.Request
+	var err error
+	if httpMethod == http.MethodPost {
+		values.Set("api_sig", lastFmSignature(values, c.Secret))
+		request, err = http.NewRequest(http.MethodPost, c.BaseURL, strings.NewReader(values.Encode()))
+		if err == nil {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		request, err = http.NewRequest(http.MethodGet, c.BaseURL+"?"+values.Encode(), nil)
+	}
+	if err != nil {
+		return decoded, err
+	}
+
+	response, err := c.HTTP.Do(request)
+	if err != nil {
+		return decoded, err
+	}
+	defer CloseLogged(response.Body)
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return decoded, err
+	}
+
+	var base lastfm.BaseResponse
+	if err := xml.Unmarshal(body, &base); err != nil {
+		return decoded, err
+	}
+	switch base.Status {
+	case "ok":
+	case "failed":
+		return decoded, LastFmError{Code: base.Error.Code, Message: strings.TrimSpace(base.Error.Message)}
+	default:
+		return decoded, errors.New("API returned invalid status (must be ok or failed)")
+	}
+
+	return decoded, xml.Unmarshal(body, &decoded)
+}
+
+// lastFmSignature signs the parameters of a request.
+//
+// https://www.last.fm/api/authspec#_8-signing-calls
+func lastFmSignature(values url.Values, secret string) string {
+	var signature strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		signature.WriteString(key)
+		signature.WriteString(values.Get(key))
+	}
+	signature.WriteString(secret)
+
+	//nolint:gosec
+	hash := md5.Sum([]byte(signature.String()))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,79 @@
+package main_test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	lastfm "github.com/p-mng/lastfm-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastFmClient(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+
+		switch r.Method {
+		case http.MethodPost:
+			require.Equal(t, "track.scrobble", r.PostForm.Get("method"))
+			//nolint:gosec
+			signature := md5.Sum([]byte("api_key" + secret + "artistPlacebomethodtrack.scrobblesksessiontimestamp1699225080" + secret))
+			require.Equal(t, hex.EncodeToString(signature[:]), r.PostForm.Get("api_sig"))
+			_, _ = w.Write([]byte(`<lfm status="ok"><scrobbles accepted="1" ignored="0"></scrobbles></lfm>`))
+		default:
+			require.Equal(t, "track.getInfo", r.Form.Get("method"))
+			require.Empty(t, r.Form.Get("api_sig"))
+			_, _ = w.Write([]byte(`<lfm status="failed"><error code="6">Track not found</error></lfm>`))
+		}
+	}))
+	defer server.Close()
+
+	_, err := main.NewLastFmClient(server.URL, "invalid", secret, server.Client())
+	require.Error(t, err)
+
+	client, err := main.NewLastFmClient(server.URL, secret, secret, server.Client())
+	require.NoError(t, err)
+
+	response, err := client.TrackScrobble(lastfm.P{"artist": "Placebo", "sk": "session", "timestamp": 1699225080})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), response.Scrobbles.Accepted)
+
+	_, err = client.TrackGetInfo(lastfm.P{"artist": "Placebo", "track": "Meds"})
+	require.EqualError(t, err, "Track not found (code 6)")
+}
+
+func TestLastFmSinkRejected(t *testing.T) {
+	response := ""
+	sink := lastFmTestSink(t, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(response))
+	})
+
+	response = `<lfm status="failed"><error code="6">Invalid parameters</error></lfm>`
+	require.True(t, main.IsRejected(sink.Scrobble(defaultScrobble)))
+
+	response = `<lfm status="ok"><scrobbles accepted="0" ignored="1"><scrobble>` +
+		`<ignoredMessage code="3">Timestamp too old</ignoredMessage></scrobble></scrobbles></lfm>`
+	err := sink.Scrobble(defaultScrobble)
+	require.True(t, main.IsRejected(err))
+	require.ErrorContains(t, err, "Timestamp too old (code 3)")
+
+	// scrobbles above the daily limit are accepted again later
+	response = `<lfm status="ok"><scrobbles accepted="0" ignored="1"><scrobble>` +
+		`<ignoredMessage code="5">Daily scrobble limit exceeded</ignoredMessage></scrobble></scrobbles></lfm>`
+	err = sink.Scrobble(defaultScrobble)
+	require.Error(t, err)
+	require.False(t, main.IsRejected(err))
+
+	response = `<lfm status="failed"><error code="11">Service Offline</error></lfm>`
+	err = sink.Scrobble(defaultScrobble)
+	require.Error(t, err)
+	require.False(t, main.IsRejected(err))
+
+	response = `<lfm status="ok"><scrobbles accepted="1" ignored="0"></scrobbles></lfm>`
+	require.NoError(t, sink.Scrobble(defaultScrobble))
+}
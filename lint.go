@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp/syntax"
+	"slices"
+	"strings"
+)
+
+const (
+	// secrets stored in the config file although a keyring is available
+	LintPlaintextSecret = "plaintext-secret"
+	// poll rate higher than needed when all sources push updates
+	LintPollRate = "poll-rate"
+	// regular expressions that can never match
+	LintDeadRegex = "dead-regex"
+	// sinks that are configured, but not authenticated
+	LintUnauthenticatedSink = "unauthenticated-sink"
+)
+
+// event-driven sources only need to be polled to detect stopped players, so
+// a lower poll rate does not delay scrobbles
+const lintEventDrivenPollRate = 5
+
+// LintWarning is an opinionated warning about a valid configuration.
+// Warnings can be suppressed by adding the rule ID to `lint_ignore`.
+type LintWarning struct {
+	Rule    string
+	Subject string
+	Message string
+}
+
+func (w LintWarning) String() string {
+	return fmt.Sprintf("[%s] %s: %s", w.Rule, w.Subject, w.Message)
+}
+
+// Lint checks the configuration for best practices. The keyring parameter
+// reports whether a system keyring is available to store secrets.
+func (c Config) Lint(keyring bool) []LintWarning {
+	var warnings []LintWarning
+	warn := func(rule, subject, message string) {
+		if slices.Contains(c.LintIgnore, rule) {
+			return
+		}
+		warnings = append(warnings, LintWarning{Rule: rule, Subject: subject, Message: message})
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(c.Sinks.LastFm)) {
+		sinkConfig := c.Sinks.LastFm[key]
+		subject := "sinks.lastfm." + key
+
+		if keyring && c.plaintextSecret(subject+".secret", sinkConfig.SecretCmd, sinkConfig.Secret) &&
+			sinkConfig.Secret != DefaultConfig.Sinks.LastFm["default"].Secret {
+			warn(LintPlaintextSecret, subject, "API secret is stored in plaintext, but a system keyring is available")
+		}
+		if keyring && c.plaintextSecret(subject+".session_key", sinkConfig.SessionKeyCmd, sinkConfig.SessionKey) {
+			warn(LintPlaintextSecret, subject, "session key is stored in plaintext, but a system keyring is available")
+		}
+
+		switch {
+		case len(sinkConfig.KeyCmd) == 0 && (sinkConfig.Key == "" || sinkConfig.Key == DefaultConfig.Sinks.LastFm["default"].Key):
+			warn(LintUnauthenticatedSink, subject, "API key is not set")
+		case (len(sinkConfig.SessionKeyCmd) == 0 && sinkConfig.SessionKey == "") || sinkConfig.Username == "":
+			warn(LintUnauthenticatedSink, subject, fmt.Sprintf("not authenticated, run `goscrobble auth login last.fm:%s`", key))
+		}
+	}
+
+	if c.Sources.DBus == nil && c.Sources.MediaControl == nil && c.Sources.AppleScript == nil && c.Sources.UPnP == nil &&
+		c.Sources.Spotify == nil && (c.Sources.Roon != nil || c.Sources.Webhook != nil) && c.PollRate < lintEventDrivenPollRate {
+		warn(LintPollRate, "poll_rate", fmt.Sprintf(
+			"all sources push updates, a poll rate of %d or more seconds saves resources without delaying scrobbles",
+			lintEventDrivenPollRate,
+		))
+	}
+
+	for i, r := range c.Regexes {
+		lintFilterRule(warn, fmt.Sprintf("regexes[%d]", i), r.filterRule())
+	}
+
+	for i, r := range c.Blacklist {
+		lintFilterRule(warn, fmt.Sprintf("blacklist[%d]", i), r)
+	}
+
+	for i, r := range c.Whitelist {
+		lintFilterRule(warn, fmt.Sprintf("whitelist[%d]", i), r)
+	}
+
+	sinkFilters := c.Sinks.filterOptions("sinks")
+	for _, name := range slices.Sorted(maps.Keys(sinkFilters)) {
+		lintFilterOptions(warn, name, sinkFilters[name], false)
+	}
+
+	sourceFilters := c.Sources.filterOptions("sources")
+	for _, name := range slices.Sorted(maps.Keys(sourceFilters)) {
+		lintFilterOptions(warn, name, sourceFilters[name], true)
+	}
+
+	return warnings
+}
+
+// filterRule returns the expression and fields of a match/replace expression.
+func (r RegexReplace) filterRule() FilterRule {
+	return FilterRule{Match: r.Match, Mode: MatchRegex, Artist: r.Artist, Track: r.Track, Album: r.Album, Player: false}
+}
+
+func lintFilterRule(warn func(rule, subject, message string), subject string, r FilterRule) {
+	switch {
+	case !r.Artist && !r.Track && !r.Album && !r.Player:
+		warn(LintDeadRegex, subject, "expression is not applied to any field")
+	case (r.Mode == "" || r.Mode == MatchRegex) && RegexNeverMatches(r.Match):
+		warn(LintDeadRegex, subject, fmt.Sprintf("expression `%s` can never match", r.Match))
+	}
+}
+
+// lintFilterOptions checks the filters of a source or sink. Sinks do not know
+// the player, so rules matching only the player never match there.
+func lintFilterOptions(warn func(rule, subject, message string), name string, options FilterOptions, player bool) {
+	for i, r := range options.Regexes {
+		lintFilterRule(warn, fmt.Sprintf("%s.regexes[%d]", name, i), r.filterRule())
+	}
+	for i, r := range options.Blacklist {
+		r.Player = r.Player && player
+		lintFilterRule(warn, fmt.Sprintf("%s.blacklist[%d]", name, i), r)
+	}
+	for i, r := range options.Whitelist {
+		r.Player = r.Player && player
+		lintFilterRule(warn, fmt.Sprintf("%s.whitelist[%d]", name, i), r)
+	}
+}
+
+// filterOptions returns the filters of all sinks, keyed by their name in the
+// config file (e.g., `sinks.csv.default`).
+func (s SinksConfig) filterOptions(prefix string) map[string]FilterOptions {
+	options := map[string]FilterOptions{}
+	for key, sinkConfig := range s.LastFm {
+		options[prefix+".lastfm."+key] = sinkConfig.FilterOptions
+	}
+	for key, sinkConfig := range s.CSV {
+		options[prefix+".csv."+key] = sinkConfig.FilterOptions
+	}
+	return options
+}
+
+// filterOptions returns the filters of all configured sources, keyed by their
+// name in the config file (e.g., `sources.upnp`).
+func (s SourcesConfig) filterOptions(prefix string) map[string]FilterOptions {
+	options := map[string]FilterOptions{}
+	if s.DBus != nil {
+		options[prefix+".dbus"] = s.DBus.FilterOptions
+	}
+	if s.MediaControl != nil {
+		options[prefix+".media-control"] = s.MediaControl.FilterOptions
+	}
+	if s.Webhook != nil {
+		options[prefix+".webhook"] = s.Webhook.FilterOptions
+	}
+	if s.AppleScript != nil {
+		options[prefix+".applescript"] = s.AppleScript.FilterOptions
+	}
+	if s.UPnP != nil {
+		options[prefix+".upnp"] = s.UPnP.FilterOptions
+	}
+	if s.Roon != nil {
+		options[prefix+".roon"] = s.Roon.FilterOptions
+	}
+	if s.Spotify != nil {
+		options[prefix+".spotify"] = s.Spotify.FilterOptions
+	}
+	return options
+}
+
+// plaintextSecret reports whether a secret is stored in the config file, i.e.
+// it is set, but neither read from a command nor from the system keyring.
+func (c Config) plaintextSecret(subject string, command []string, secret string) bool {
+	return secret != "" && len(command) == 0 && !strings.HasPrefix(secret, KeyringPrefix) && !c.InKeyring(subject)
+}
+
+// RegexNeverMatches reports whether an expression can not match any input,
+// e.g., because text follows the end of input. Expressions that fail to parse
+// are reported by Validate and ignored here. The check is conservative, so
+// some expressions that never match are not detected.
+func RegexNeverMatches(expression string) bool {
+	parsed, err := syntax.Parse(expression, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	return neverMatches(parsed)
+}
+
+func neverMatches(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return true
+	case syntax.OpCharClass:
+		// empty character class, e.g., [^\x00-\x{10FFFF}]
+		return len(re.Rune) == 0
+	case syntax.OpCapture, syntax.OpPlus:
+		return neverMatches(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min > 0 && neverMatches(re.Sub[0])
+	case syntax.OpAlternate:
+		return !slices.ContainsFunc(re.Sub, func(sub *syntax.Regexp) bool { return !neverMatches(sub) })
+	case syntax.OpConcat:
+		consumed, ended := false, false
+		for _, sub := range re.Sub {
+			if neverMatches(sub) {
+				return true
+			}
+			if consumes(sub) {
+				if ended {
+					// text after the end of input
+					return true
+				}
+				consumed = true
+			}
+			switch sub.Op {
+			case syntax.OpEndText:
+				ended = true
+			case syntax.OpBeginText:
+				if consumed {
+					// text before the start of input
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// consumes reports whether every match of the expression is non-empty.
+func consumes(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return len(re.Rune) > 0
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return true
+	case syntax.OpCapture, syntax.OpPlus:
+		return consumes(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min > 0 && consumes(re.Sub[0])
+	case syntax.OpConcat:
+		return slices.ContainsFunc(re.Sub, consumes)
+	case syntax.OpAlternate:
+		return !slices.ContainsFunc(re.Sub, func(sub *syntax.Regexp) bool { return !consumes(sub) })
+	}
+	return false
+}
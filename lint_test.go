@@ -0,0 +1,65 @@
+package main_test
+
+import (
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func lintRules(warnings []main.LintWarning) []string {
+	var rules []string
+	for _, warning := range warnings {
+		rules = append(rules, warning.Rule+" "+warning.Subject)
+	}
+	return rules
+}
+
+func TestLint(t *testing.T) {
+	config := main.DefaultConfig
+	config.Sinks.LastFm = map[string]main.LastFmConfig{
+		"default": {Key: "key", Secret: "secret", SessionKey: "session", Username: "user"},
+		"new":     {Key: "key", Secret: "secret"},
+	}
+	config.Regexes = []main.RegexReplace{
+		{Match: `\s+$`, Artist: true},
+		{Match: `feat\.$ and`, Track: true},
+		{Match: `Remastered`},
+	}
+	config.Blacklist = []main.FilterRule{{Match: "chromium", Player: true}, {Match: "a^b", Player: true}, {Match: "a^b", Mode: main.MatchExact, Track: true}}
+
+	require.Equal(t, []string{
+		"unauthenticated-sink sinks.lastfm.new",
+		"dead-regex regexes[1]",
+		"dead-regex regexes[2]",
+		"dead-regex blacklist[1]",
+	}, lintRules(config.Lint(false)))
+
+	warnings := config.Lint(true)
+	require.Len(t, warnings, 7)
+	require.Equal(t, "[plaintext-secret] sinks.lastfm.default: session key is stored in plaintext, but a system keyring is available", warnings[1].String())
+
+	config.LintIgnore = []string{main.LintPlaintextSecret, main.LintDeadRegex}
+	require.Equal(t, []string{"unauthenticated-sink sinks.lastfm.new"}, lintRules(config.Lint(true)))
+}
+
+func TestLintPollRate(t *testing.T) {
+	config := main.DefaultConfig
+	config.Sinks = main.SinksConfig{LastFm: nil, CSV: nil}
+	require.Empty(t, config.Lint(false))
+
+	config.Sources = main.SourcesConfig{Roon: &main.RoonConfig{Address: ""}}
+	require.Equal(t, []string{"poll-rate poll_rate"}, lintRules(config.Lint(false)))
+
+	config.PollRate = 10
+	require.Empty(t, config.Lint(false))
+}
+
+func TestRegexNeverMatches(t *testing.T) {
+	for _, expression := range []string{`$a`, `a\zb`, `a^`, `(x|y)$.`, `[^\x00-\x{10FFFF}]`, `(a$b)+`} {
+		require.True(t, main.RegexNeverMatches(expression), expression)
+	}
+	for _, expression := range []string{`^a$`, `a$|b`, `a$\s*`, `(?m)a$\nb`, `x*^a`, `(`} {
+		require.False(t, main.RegexNeverMatches(expression), expression)
+	}
+}
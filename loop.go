@@ -3,7 +3,12 @@ package main
 import (
 	"fmt"
 	"maps"
+	"os"
+	"os/signal"
 	"regexp"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -18,6 +23,10 @@ var (
 	}
 )
 
+// SourceErrorGrace is the time for which the players of a failing source are
+// kept.
+const SourceErrorGrace = 30 * time.Second
+
 const (
 	RuneBeamedSixteenthNotes = '\u266C'
 	RuneCheckMark            = '\u2713'
@@ -25,97 +34,888 @@ const (
 	RuneWarningSign          = '\u26A0'
 )
 
-func RunMainLoop(config Config) {
+// LoopState is the playback state kept between main loop iterations.
+type LoopState struct {
+	PreviouslyPlaying map[string]PlaybackStatus
+	ScrobbledPrevious map[string]bool
+	GroupScrobbles    map[string][]GroupScrobble
+	Stats             LoopStats
+	CurrentlyPlaying  map[string]PlaybackStatus
+	LastScrobble      Scrobble
+	// last error of each source and sink, removed after the next success
+	SourceErrors map[string]string
+	SinkErrors   map[string]string
+	// source that reported each player
+	PlayerSources map[string]string
+	// time each failing source started failing
+	SourceFailures map[string]time.Time
+	// time each player started playing, only contains playing players
+	PlayingSince map[string]time.Time
+	// time each player actually played its current track, excluding seeks
+	PlayedTime map[string]time.Duration
+	// time of the previous main loop iteration
+	LastPoll time.Time
+	// scrobbles within the duplicate suppression window, keyed by artist
+	// and track
+	RecentScrobbles map[string]RecentScrobble
+	// players whose current play started by playing the same track again
+	Repeated map[string]bool
+	// looked up durations of the tracks that are playing, keyed by artist
+	// and track
+	Durations map[string]time.Duration
+	// time the now playing status of each player was last sent
+	NowPlayingSent map[string]time.Time
+	// scrobbles waiting for the system clock to be set
+	HeldScrobbles []HeldScrobble
+	// last scrobble saved by each sink
+	LastSinkScrobbles map[string]Scrobble
+	// most recent errors of sources and sinks, oldest first
+	RecentErrors []RecentError
+	// set by the main loop if the playback journal is enabled
+	Journal *PlaybackJournal
+}
+
+type RecentScrobble struct {
+	Time   time.Time
+	Window time.Duration
+}
+
+// LoopStats are counters reported by the control socket.
+type LoopStats struct {
+	TracksSeen     int
+	ScrobblesToday int
+	// local date the scrobbles were counted on
+	Day string
+}
+
+// LoopOptions are derived from the configuration and do not change between
+// main loop iterations.
+type LoopOptions struct {
+	Blacklist           []ParsedFilterRule
+	Bans                []Ban
+	Whitelist           []ParsedFilterRule
+	ParsedRegexes       []ParsedRegexReplace
+	PlayerGroups        []ParsedPlayerGroup
+	PlayerPolicy        PlayerPolicy
+	PlayerPriority      []*regexp.Regexp
+	MinPlaybackDuration int
+	MinPlaybackPercent  int
+	Players             map[string]PlayerConfig
+	DedupeWindow        time.Duration
+	ScrobbleTimestamp   ScrobbleTimestamp
+	UnknownDuration     UnknownDuration
+	// zero disables seek detection
+	PollInterval time.Duration
+	// used instead of PollInterval while no player is reported
+	IdlePollInterval time.Duration
+	NotifyOnScrobble bool
+	NotifyOnError    bool
+	// hold scrobbles while the system clock is not set
+	HoldUntilClockSync bool
+	NotifyTruncate     TruncateConfig
+	// zero waits for sinks without a timeout
+	SinkTimeout time.Duration
+	// zero sends the now playing status only when a track starts
+	NowPlayingRefresh time.Duration
+	// set by the main loop, since it is kept when the configuration is reloaded
+	Events *EventBus
+}
+
+func NewLoopState() *LoopState {
+	return &LoopState{
+		PreviouslyPlaying: map[string]PlaybackStatus{},
+		ScrobbledPrevious: map[string]bool{},
+		GroupScrobbles:    map[string][]GroupScrobble{},
+		Stats:             LoopStats{TracksSeen: 0, ScrobblesToday: 0, Day: ""},
+		CurrentlyPlaying:  map[string]PlaybackStatus{},
+		LastScrobble: Scrobble{
+			Artists:   []string{},
+			Track:     "",
+			Album:     "",
+			Duration:  0,
+			Timestamp: time.Time{},
+		},
+		SourceErrors:      map[string]string{},
+		SinkErrors:        map[string]string{},
+		PlayerSources:     map[string]string{},
+		SourceFailures:    map[string]time.Time{},
+		PlayingSince:      map[string]time.Time{},
+		PlayedTime:        map[string]time.Duration{},
+		LastPoll:          time.Time{},
+		RecentScrobbles:   map[string]RecentScrobble{},
+		Repeated:          map[string]bool{},
+		Durations:         map[string]time.Duration{},
+		NowPlayingSent:    map[string]time.Time{},
+		HeldScrobbles:     []HeldScrobble{},
+		LastSinkScrobbles: map[string]Scrobble{},
+		RecentErrors:      []RecentError{},
+		Journal:           nil,
+	}
+}
+
+// SkipCurrent prevents the tracks that are currently playing from being
+// scrobbled and returns the number of skipped tracks.
+func (s *LoopState) SkipCurrent() int {
+	skipped := 0
+	for player, status := range s.CurrentlyPlaying {
+		scrobbled, ok := s.ScrobbledPrevious[player]
+		if !ok || scrobbled || status.State != PlaybackPlaying {
+			continue
+		}
+		s.ScrobbledPrevious[player] = true
+		skipped++
+	}
+	return skipped
+}
+
+// keepPlayers returns the players last reported by a failing source, so a
+// temporary error does not end their tracks. Once the source has been
+// failing for SourceErrorGrace, its players are dropped.
+func (s *LoopState) keepPlayers(source string, now time.Time) map[string]PlaybackStatus {
+	failing, ok := s.SourceFailures[source]
+	if !ok {
+		failing = now
+		s.SourceFailures[source] = now
+	}
+
+	kept := map[string]PlaybackStatus{}
+	if now.Sub(failing) >= SourceErrorGrace {
+		return kept
+	}
+
+	for player, status := range s.CurrentlyPlaying {
+		if s.PlayerSources[player] == source {
+			kept[player] = status
+		}
+	}
+
+	if len(kept) > 0 {
+		log.Debug().
+			Str("source", source).
+			Int("players", len(kept)).
+			Msg("keeping players of failing source")
+	}
+	return kept
+}
+
+// DiscardPlayback prevents the tracks that were playing or paused before a
+// system suspend from being scrobbled, since their playback was interrupted.
+// It returns the number of discarded tracks.
+func (s *LoopState) DiscardPlayback() int {
+	discarded := 0
+	for player, scrobbled := range s.ScrobbledPrevious {
+		if scrobbled || !s.PreviouslyPlaying[player].HasMetadata() {
+			continue
+		}
+		s.ScrobbledPrevious[player] = true
+		discarded++
+	}
+	return discarded
+}
+
+func (s *LoopStats) CountScrobble(now time.Time) {
+	day := now.Format(time.DateOnly)
+	if s.Day != day {
+		s.Day = day
+		s.ScrobblesToday = 0
+	}
+	s.ScrobblesToday++
+}
+
+// ScrobblesOn returns the number of scrobbles counted on the day of now.
+func (s LoopStats) ScrobblesOn(now time.Time) int {
+	if s.Day != now.Format(time.DateOnly) {
+		return 0
+	}
+	return s.ScrobblesToday
+}
+
+func (c Config) LoopOptions() LoopOptions {
+	return LoopOptions{
+		Blacklist:           c.ParseBlacklist(),
+		Bans:                c.Bans,
+		Whitelist:           c.ParseWhitelist(),
+		ParsedRegexes:       c.ParseRegexes(),
+		PlayerGroups:        c.ParsePlayerGroups(),
+		PlayerPolicy:        c.PlayerPolicy,
+		PlayerPriority:      CompilePlayerBlacklist(c.PlayerPriority),
+		MinPlaybackDuration: c.MinPlaybackDuration,
+		MinPlaybackPercent:  c.MinPlaybackPercent,
+		Players:             c.Players,
+		DedupeWindow:        time.Duration(c.DedupeWindow) * time.Second,
+		ScrobbleTimestamp:   c.ScrobbleTimestamp,
+		UnknownDuration:     c.UnknownDuration,
+		PollInterval:        time.Duration(c.PollRate) * time.Second,
+		IdlePollInterval:    time.Duration(c.IdlePollRate) * time.Second,
+		NotifyOnScrobble:    c.NotifyOnScrobble,
+		NotifyOnError:       c.NotifyOnError,
+		HoldUntilClockSync:  c.HoldUntilClockSync,
+		NotifyTruncate:      c.TruncateRules(c.NotifyTruncate),
+		SinkTimeout:         time.Duration(c.SinkTimeout) * time.Second,
+		NowPlayingRefresh:   time.Duration(c.NowPlayingRefresh) * time.Second,
+		Events:              nil,
+	}
+}
+
+// RunOptions are the options of `goscrobble run` that are not part of the
+// configuration file.
+type RunOptions struct {
+	// log what would be submitted instead of calling any sink
+	DryRun bool
+}
+
+// Apply returns the configuration to run with.
+func (r RunOptions) Apply(config Config) Config {
+	if r.DryRun {
+		log.Warn().Msg("dry run: scrobbles and now playing updates are only logged, no sink is called")
+		return config.DryRunConfig()
+	}
+	return config
+}
+
+func RunMainLoop(config Config, configFilename string, run RunOptions) {
 	log.Debug().Msg("starting main loop")
 
-	previouslyPlaying := map[string]PlaybackStatus{}
-	scrobbledPrevious := map[string]bool{}
+	config = run.Apply(config)
 
-	playerBlacklist := CompilePlayerBlacklist(config.Blacklist)
-	parsedRegexes := config.ParseRegexes()
+	state := NewLoopState()
+	events := NewEventBus()
+	events.SetOutputs(config.SetupEventOutputs())
+	options := config.LoopOptions()
+	options.Events = events
 
 	sources := config.SetupSources()
 	sinks := config.SetupSinks()
 
-	ticker := time.NewTicker(time.Second * time.Duration(config.PollRate))
+	if config.PlaybackJournal {
+		state.Journal = NewPlaybackJournal(JournalFilename())
+		state.Journal.Recover(state, options, sinks, SendNotification)
+	}
+	profiles := config.SetupUserProfiles(nil, options)
+
+	pollInterval := options.PollInterval
+	ticker := time.NewTicker(pollInterval)
 
 	for _, line := range logoLines {
 		log.Info().Msg(line)
 	}
 
+	started := time.Now()
+	// guards stats, sinks, and players, which are read by control requests
+	var statsMutex sync.Mutex
+	var stats LoopStats
+	controlSinks := sinks
+	nowPlaying := map[string]PlaybackStatus{}
+	var progress []PlayerProgress
+	lastScrobbles := map[string]Scrobble{}
+	var recentErrors []RecentError
+	health := NewHealthReport(state, sources, sinks, started)
+
+	var pauseMode PauseMode
+	var guestMode GuestMode
+
+	// control requests that modify sources or sinks are run on the main loop
+	controlActions := make(chan func())
+	runOnMainLoop := func(action func() ControlResponse) ControlResponse {
+		response := make(chan ControlResponse, 1)
+		controlActions <- func() {
+			response <- action()
+		}
+		return <-response
+	}
+
+	control := NewControlServer()
+	control.Handle("status", func(ControlRequest) ControlResponse {
+		statsMutex.Lock()
+		status := DaemonStatus{
+			Started:        started,
+			TracksSeen:     stats.TracksSeen,
+			ScrobblesToday: stats.ScrobblesOn(time.Now()),
+			QueueDepth:     0,
+			Paused:         pauseMode.Active(time.Now()),
+			PausedUntil:    pauseMode.Until(),
+			Guest:          guestMode.Active(time.Now()),
+			Players:        progress,
+			LastScrobbles:  lastScrobbles,
+			Errors:         recentErrors,
+		}
+		currentSinks := controlSinks
+		statsMutex.Unlock()
+
+		status.QueueDepth = QueueDepth(currentSinks)
+		response := ControlMessage("")
+		response.Status = &status
+		return response
+	})
+	control.Handle("now-playing", func(ControlRequest) ControlResponse {
+		statsMutex.Lock()
+		defer statsMutex.Unlock()
+
+		response := ControlMessage("")
+		response.NowPlaying = nowPlaying
+		return response
+	})
+	control.Handle("health", func(ControlRequest) ControlResponse {
+		statsMutex.Lock()
+		report := health.At(time.Now())
+		statsMutex.Unlock()
+
+		response := ControlMessage("")
+		response.Health = &report
+		return response
+	})
+	control.Handle("queue", func(ControlRequest) ControlResponse {
+		statsMutex.Lock()
+		currentSinks := controlSinks
+		statsMutex.Unlock()
+
+		queue, err := QueuedScrobbles(currentSinks)
+		if err != nil {
+			return ControlError(err.Error())
+		}
+
+		response := ControlMessage(fmt.Sprintf("%d scrobbles queued", QueueDepth(currentSinks)))
+		response.Queue = queue
+		return response
+	})
+	control.Handle("pause", func(request ControlRequest) ControlResponse {
+		var duration time.Duration
+		if len(request.Arguments) > 0 {
+			parsed, err := time.ParseDuration(request.Arguments[0])
+			if err != nil {
+				return ControlError(fmt.Sprintf("invalid duration: %s", err.Error()))
+			}
+			duration = parsed
+		}
+
+		pauseMode.Pause(time.Now(), duration)
+		log.Info().
+			Dur("duration", duration).
+			Msg("paused scrobbling")
+
+		if duration > 0 {
+			return ControlMessage(fmt.Sprintf("paused scrobbling for %s", duration))
+		}
+		return ControlMessage("paused scrobbling")
+	})
+	control.Handle("resume", func(ControlRequest) ControlResponse {
+		pauseMode.Resume()
+		log.Info().Msg("resumed scrobbling")
+		return ControlMessage("resumed scrobbling")
+	})
+
+	serviceNotifier := NewServiceNotifier()
+	serviceNotifier.Ready()
+
+	// the idle poll interval can be longer than the watchdog interval
+	var keepalive <-chan time.Time
+	if interval := serviceNotifier.KeepaliveInterval(); interval > 0 {
+		keepaliveTicker := time.NewTicker(interval)
+		defer keepaliveTicker.Stop()
+		keepalive = keepaliveTicker.C
+	}
+
+	idleInhibitor := config.IdleInhibitor()
+	resurfacer := config.Resurfacer()
+
+	var suspendDetector SuspendDetector
+	sleepWatcher := WatchSleep()
+	discardPlayback := func(reason string) {
+		discarded := state.DiscardPlayback()
+		for _, profile := range profiles {
+			discarded += profile.State.DiscardPlayback()
+		}
+		log.Info().
+			Int("tracks", discarded).
+			Str("reason", reason).
+			Msg("discarded playback interrupted by system suspend")
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	// a nil channel never receives, so changes are ignored while not watching
+	var configWatcher *ConfigWatcher
+	var configChanges chan struct{}
+	watchConfig := func(enabled bool) {
+		switch {
+		case enabled && configWatcher == nil:
+			watcher, err := WatchConfig(configFilename)
+			if err != nil {
+				log.Error().
+					Err(err).
+					Msg("error watching configuration file")
+				return
+			}
+			configWatcher = watcher
+			configChanges = watcher.Changes
+		case !enabled && configWatcher != nil:
+			CloseLogged(configWatcher)
+			configWatcher = nil
+			configChanges = nil
+		}
+	}
+	watchConfig(config.WatchConfig)
+
+	var apiServer *APIServer
+	var apiConfig *APIConfig
+	startAPI := func(reloaded *APIConfig) {
+		unchanged := (apiConfig == nil && reloaded == nil) ||
+			(apiConfig != nil && reloaded != nil && apiConfig.Address == reloaded.Address && apiConfig.Token == reloaded.Token)
+		if apiServer != nil && unchanged {
+			return
+		}
+
+		CloseLogged(apiServer)
+		apiServer = nil
+		apiConfig = reloaded
+
+		if reloaded == nil {
+			return
+		}
+
+		server := NewAPIServer(reloaded.Token, control)
+		if err := server.Listen(reloaded.Address); err != nil {
+			log.Error().
+				Err(err).
+				Str("address", reloaded.Address).
+				Msg("error setting up HTTP API")
+			return
+		}
+		apiServer = server
+	}
+
+	reloadConfig := func() error {
+		reloaded, err := ReloadConfig(configFilename, append(UserSources(profiles), sources...), run.DryRun)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("error reloading configuration, keeping current configuration")
+			return err
+		}
+
+		options = reloaded.Options
+		options.Events = events
+		events.SetOutputs(reloaded.Config.SetupEventOutputs())
+		sources = reloaded.Sources
+		sinks = reloaded.Sinks
+		switch {
+		case !reloaded.Config.PlaybackJournal:
+			state.Journal = nil
+		case state.Journal == nil:
+			state.Journal = NewPlaybackJournal(JournalFilename())
+		}
+		profiles = reloaded.Config.SetupUserProfiles(profiles, options)
+		pollInterval = options.PollInterval
+		ticker.Reset(pollInterval)
+		watchConfig(reloaded.Config.WatchConfig)
+		startAPI(reloaded.Config.API)
+		apiServer.StreamEvents(events, reloaded.Config.SSETypes())
+
+		CloseLogged(idleInhibitor)
+		idleInhibitor = reloaded.Config.IdleInhibitor()
+
+		// do not send another reminder on the same day
+		previousResurfacer := resurfacer
+		resurfacer = reloaded.Config.Resurfacer()
+		if previousResurfacer != nil && resurfacer != nil {
+			resurfacer.day = previousResurfacer.day
+		}
+
+		statsMutex.Lock()
+		controlSinks = sinks
+		statsMutex.Unlock()
+
+		log.Info().Msg("reloaded configuration")
+		return nil
+	}
+
+	control.Handle("guest", func(request ControlRequest) ControlResponse {
+		if len(request.Arguments) == 0 {
+			return ControlError("missing argument: on or off")
+		}
+
+		switch request.Arguments[0] {
+		case "on":
+			var duration time.Duration
+			if len(request.Arguments) > 1 {
+				parsed, err := time.ParseDuration(request.Arguments[1])
+				if err != nil {
+					return ControlError(fmt.Sprintf("invalid duration: %s", err.Error()))
+				}
+				duration = parsed
+			}
+
+			guestMode.Enable(time.Now(), duration)
+			log.Info().
+				Dur("duration", duration).
+				Msg("enabled guest mode")
+
+			if duration > 0 {
+				return ControlMessage(fmt.Sprintf("enabled guest mode for %s", duration))
+			}
+			return ControlMessage("enabled guest mode")
+		case "off":
+			guestMode.Disable()
+			log.Info().Msg("disabled guest mode")
+			return ControlMessage("disabled guest mode")
+		default:
+			return ControlError(fmt.Sprintf("invalid argument: %s", request.Arguments[0]))
+		}
+	})
+	control.Handle("skip", func(ControlRequest) ControlResponse {
+		return runOnMainLoop(func() ControlResponse {
+			skipped := state.SkipCurrent()
+			log.Info().
+				Int("tracks", skipped).
+				Msg("skipped scrobbling current tracks")
+			return ControlMessage(fmt.Sprintf("skipped %d tracks", skipped))
+		})
+	})
+	control.Handle("reload", func(ControlRequest) ControlResponse {
+		return runOnMainLoop(func() ControlResponse {
+			if err := reloadConfig(); err != nil {
+				return ControlError(err.Error())
+			}
+			return ControlMessage("reloaded configuration")
+		})
+	})
+	control.Handle("scrobbles", func(request ControlRequest) ControlResponse {
+		if len(request.Arguments) == 0 || request.Arguments[0] == "" {
+			return ControlError("missing argument: sink")
+		}
+
+		limit := 20
+		if len(request.Arguments) > 1 {
+			parsed, err := strconv.Atoi(request.Arguments[1])
+			if err != nil || parsed <= 0 {
+				return ControlError(fmt.Sprintf("invalid limit: %s", request.Arguments[1]))
+			}
+			limit = parsed
+		}
+
+		return runOnMainLoop(func() ControlResponse {
+			sink, err := FindSink(sinks, request.Arguments[0])
+			if err != nil {
+				return ControlError(err.Error())
+			}
+
+			scrobbles, err := sink.GetScrobbles(limit, time.Time{}, time.Now())
+			if err != nil {
+				return ControlError(fmt.Sprintf("error reading scrobbles: %s", err.Error()))
+			}
+
+			response := ControlMessage("")
+			response.Scrobbles = scrobbles
+			return response
+		})
+	})
+	control.Handle("scrobble", func(request ControlRequest) ControlResponse {
+		if request.Scrobble == nil || len(request.Scrobble.Artists) == 0 || request.Scrobble.Track == "" {
+			return ControlError("missing scrobble with artists and track")
+		}
+
+		return runOnMainLoop(func() ControlResponse {
+			now := time.Now()
+
+			// sinks selected by name are used regardless of the guest mode
+			selected := RouteSinks(sinks, guestMode.Active(now))
+			if len(request.Arguments) > 0 {
+				selected = nil
+				for _, name := range request.Arguments {
+					sink, err := FindSink(sinks, name)
+					if err != nil {
+						return ControlError(err.Error())
+					}
+					selected = append(selected, sink)
+				}
+			}
+
+			if err := SubmitScrobble(state, selected, *request.Scrobble, now); err != nil {
+				return ControlError(err.Error())
+			}
+			return ControlMessage("scrobbled " + request.Scrobble.Track)
+		})
+	})
+	control.Handle("flush-queue", func(request ControlRequest) ControlResponse {
+		return runOnMainLoop(func() ControlResponse {
+			flushed := sinks
+			if len(request.Arguments) > 0 {
+				sink, err := FindSink(sinks, request.Arguments[0])
+				if err != nil {
+					return ControlError(err.Error())
+				}
+				flushed = []Sink{sink}
+			}
+
+			remaining, err := FlushQueues(flushed)
+			if err != nil {
+				return ControlError(err.Error())
+			}
+			return ControlMessage(fmt.Sprintf("%d scrobbles still queued", remaining))
+		})
+	})
+
+	control.StreamEvents(events)
+
+	if err := control.Listen(ControlSocketFilename()); err != nil {
+		log.Error().
+			Err(err).
+			Msg("error setting up control socket")
+	}
+
+	startAPI(config.API)
+	apiServer.StreamEvents(events, config.SSETypes())
+
+	var daemonService *DaemonService
+	if config.DBusService {
+		service, err := NewDaemonService(control)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("error registering dbus service")
+		}
+		daemonService = service
+	}
+
 	for {
-		RunMainLoopOnce(
-			previouslyPlaying,
-			scrobbledPrevious,
-			playerBlacklist,
-			parsedRegexes,
-			sources,
-			sinks,
-			config.MinPlaybackDuration,
-			config.MinPlaybackPercent,
-			config.NotifyOnScrobble,
-			config.NotifyOnError,
-			SendNotification,
-		)
+		if suspended, ok := suspendDetector.Check(time.Now()); ok {
+			log.Info().
+				Dur("duration", suspended).
+				Msg("system was suspended")
+			discardPlayback("clock jump")
+		}
 
-		timestamp := <-ticker.C
-		log.Debug().
-			Time("timestamp", timestamp).
-			Msg("completed main loop iteration")
+		if pauseMode.Active(time.Now()) {
+			log.Debug().Msg("scrobbling is paused, skipping main loop iteration")
+		} else {
+			RunMainLoopOnce(state, options, sources, RouteSinks(sinks, guestMode.Active(time.Now())), SendNotification)
+			for _, profile := range profiles {
+				RunMainLoopOnce(profile.State, options, profile.Sources, profile.Sinks, SendNotification)
+			}
+
+			if pauseMode.Resumed() {
+				skipped := state.SkipCurrent()
+				for _, profile := range profiles {
+					skipped += profile.State.SkipCurrent()
+				}
+				log.Info().
+					Int("tracks", skipped).
+					Msg("skipped scrobbling tracks played during pause")
+			}
+		}
+		playing := CurrentlyPlaying(state, profiles)
+
+		serviceNotifier.SetStatus(ServiceStatus(playing))
+		serviceNotifier.Keepalive(time.Now())
+		idleInhibitor.Update(playing)
+		resurfacer.Check(time.Now(), sinks, SendNotification)
+
+		statsMutex.Lock()
+		stats = state.Stats
+		nowPlaying = playing
+		progress = PlayersProgress(state, options, profiles)
+		lastScrobbles = LastSinkScrobbles(state, profiles)
+		recentErrors = RecentErrors(state, profiles)
+		health = NewHealthReport(state, sources, sinks, time.Now())
+		statsMutex.Unlock()
+
+		if daemonService != nil {
+			daemonService.Update(NewDaemonServiceState(state, QueueDepth(sinks), pauseMode.Active(time.Now())))
+		}
+
+		if interval := options.NextPollInterval(playing); interval != pollInterval {
+			log.Debug().
+				Dur("interval", interval).
+				Msg("changing poll interval")
+			pollInterval = interval
+			ticker.Reset(pollInterval)
+		}
+
+		for {
+			select {
+			case timestamp := <-ticker.C:
+				log.Debug().
+					Time("timestamp", timestamp).
+					Msg("completed main loop iteration")
+			case now := <-keepalive:
+				// keep waiting, so the sources are not polled before the next tick
+				serviceNotifier.Keepalive(now)
+				continue
+			case <-hangup:
+				_ = reloadConfig()
+			case <-configChanges:
+				_ = reloadConfig()
+			case action := <-controlActions:
+				action()
+			case <-sleepWatcher.Channel():
+				discardPlayback("logind")
+			}
+			break
+		}
 	}
 }
 
 func RunMainLoopOnce(
-	previouslyPlaying map[string]PlaybackStatus,
-	scrobbledPrevious map[string]bool,
-	playerBlacklist []*regexp.Regexp,
-	parsedRegexes []ParsedRegexReplace,
+	state *LoopState,
+	options LoopOptions,
 	sources []Source,
 	sinks []Sink,
-	minPlaybackDuration int,
-	minPlaybackPercent int,
-	notifyOnScrobble bool,
-	notifyOnError bool,
 	notifier NotifierFunc,
 ) {
 	playbackStatus := make(map[string]PlaybackStatus)
+	playerSources := make(map[string]string)
+	receivedScrobbles := make(map[string][]Scrobble)
 
 	for _, source := range sources {
-		status, err := source.GetInfo(playerBlacklist, parsedRegexes)
+		status, err := source.GetInfo(options.Blacklist, options.ParsedRegexes)
 		if err != nil {
 			log.Error().
 				Err(err).
 				Str("source", source.Name()).
 				Msg("error getting current playback status")
 		}
-		maps.Copy(playbackStatus, status)
+		recordError(state.SourceErrors, source.Name(), err)
+		state.rememberError(source.Name(), err, time.Now())
+
+		if err != nil && len(status) == 0 {
+			status = state.keepPlayers(source.Name(), time.Now())
+		} else {
+			delete(state.SourceFailures, source.Name())
+		}
+
+		for player, playerStatus := range status {
+			if IsBanned(options.Bans, playerStatus.Scrobble) {
+				log.Debug().
+					Str("player", player).
+					Interface("status", playerStatus).
+					Msg("ignoring banned track")
+				continue
+			}
+			if AnyRuleMatches(options.Blacklist, player, playerStatus.Scrobble) {
+				log.Debug().
+					Str("player", player).
+					Interface("status", playerStatus).
+					Msg("ignoring blacklisted track")
+				continue
+			}
+			if !IsWhitelisted(options.Whitelist, player, playerStatus.Scrobble) {
+				log.Debug().
+					Str("player", player).
+					Interface("status", playerStatus).
+					Msg("ignoring track not matching the whitelist")
+				continue
+			}
+			if owner, ok := playerSources[player]; ok && owner != source.Name() {
+				// another source already reported a player with this name
+				player = fmt.Sprintf("%s:%s", source.Name(), player)
+			}
+			playbackStatus[player] = playerStatus
+			playerSources[player] = source.Name()
+		}
+
+		if scrobbleSource, ok := source.(ScrobbleSource); ok {
+			maps.Copy(receivedScrobbles, scrobbleSource.ReceivedScrobbles(options.Blacklist, options.ParsedRegexes))
+		}
+	}
+
+	state.ResolveDurations(options.UnknownDuration, sinks, playbackStatus)
+	state.ReleaseHeldScrobbles(options, sinks, notifier, time.Now())
+
+	lastSeen := state.CurrentlyPlaying
+	maxAdvance := options.maxAdvance(time.Since(state.LastPoll))
+	state.LastPoll = time.Now()
+	state.CurrentlyPlaying = playbackStatus
+	state.PlayerSources = playerSources
+	state.UpdatePlayingSince(playbackStatus, time.Now())
+
+	active, exclusive := ActivePlayer(options.PlayerPolicy, options.PlayerPriority, playbackStatus, state.PlayingSince)
+
+	retryQueued(options, sinks)
+
+	for player, scrobbles := range receivedScrobbles {
+		for _, scrobble := range scrobbles {
+			if IsBanned(options.Bans, scrobble) {
+				log.Info().
+					Str("player", player).
+					Interface("scrobble", scrobble).
+					Msg("ignoring banned received track")
+				continue
+			}
+			if AnyRuleMatches(options.Blacklist, player, scrobble) {
+				log.Info().
+					Str("player", player).
+					Interface("scrobble", scrobble).
+					Msg("ignoring blacklisted received track")
+				continue
+			}
+			if !IsWhitelisted(options.Whitelist, player, scrobble) {
+				log.Info().
+					Str("player", player).
+					Interface("scrobble", scrobble).
+					Msg("ignoring received track not matching the whitelist")
+				continue
+			}
+			if state.IsDuplicate(options.DedupeWindow, scrobble, time.Now()) {
+				log.Info().
+					Str("player", player).
+					Interface("scrobble", scrobble).
+					Msg("same track was scrobbled recently, ignoring received track")
+				continue
+			}
+
+			log.Info().
+				Str("player", player).
+				Interface("scrobble", scrobble).
+				Msg("scrobbling received track")
+
+			status := PlaybackStatus{
+				Scrobble: scrobble,
+				State:    PlaybackStopped,
+				Position: scrobble.Duration,
+			}
+			state.Stats.CountScrobble(time.Now())
+			state.LastScrobble = scrobble
+
+			sendScrobble(state, options, player, sinks, status, notifier)
+		}
 	}
 
 	for player := range playbackStatus {
-		if _, ok := previouslyPlaying[player]; !ok {
+		if _, ok := state.PreviouslyPlaying[player]; !ok {
 			log.Info().
 				Str("player", player).
 				Msg("new player found")
-			previouslyPlaying[player] = PlaybackStatus{}
-			scrobbledPrevious[player] = false
+			state.PreviouslyPlaying[player] = PlaybackStatus{}
+			state.ScrobbledPrevious[player] = false
 		}
 	}
 
-	for player := range previouslyPlaying {
+	for player := range state.PreviouslyPlaying {
 		if _, ok := playbackStatus[player]; !ok {
 			log.Info().
 				Str("player", player).
 				Msg("player disappeared")
-			delete(previouslyPlaying, player)
-			delete(scrobbledPrevious, player)
+			state.publishSkipped(options, player)
+			RecordPlay(player, sinks, state.PreviouslyPlaying[player], lastSeen[player])
+			delete(state.PreviouslyPlaying, player)
+			delete(state.ScrobbledPrevious, player)
+			delete(state.PlayedTime, player)
+			delete(state.NowPlayingSent, player)
+			delete(state.Repeated, player)
 		}
 	}
 
 	for player, status := range playbackStatus {
-		if !status.IsValid() {
+		if !options.UnknownDuration.Accepts(status.Scrobble) {
+			continue
+		}
+		if exclusive && player != active {
+			// keep the progress of the track in case the player wins again
 			continue
 		}
 
+		minPlaybackDuration, minPlaybackPercent := options.PlayerThresholds(player, playerSources[player])
 		minPlayTime, err := MinPlayTime(
 			status.Duration,
 			minPlaybackDuration,
@@ -130,23 +930,40 @@ func RunMainLoopOnce(
 			continue
 		}
 
-		if !status.Equals(previouslyPlaying[player]) && status.State == PlaybackPlaying {
+		restarted := IsRestart(lastSeen[player], status)
+		if restarted {
+			log.Info().
+				Str("player", player).
+				Interface("status", status).
+				Msg("track started again")
+		}
+
+		if (!status.Equals(state.PreviouslyPlaying[player]) || restarted) && status.State == PlaybackPlaying {
+			state.publishSkipped(options, player)
+			RecordPlay(player, sinks, state.PreviouslyPlaying[player], lastSeen[player])
+
+			// the part played before the track was first seen (e.g., if the
+			// daemon was started during playback) is trusted
+			state.PlayedTime[player] = status.Position
+			status.Timestamp = StartTime(time.Now(), status.Position)
 			status.Position = time.Duration(0)
-			status.Timestamp = time.Now()
 
-			previouslyPlaying[player] = status
-			scrobbledPrevious[player] = false
+			state.PreviouslyPlaying[player] = status
+			state.ScrobbledPrevious[player] = false
+			state.Repeated[player] = restarted
+			state.Stats.TracksSeen++
 
 			log.Debug().
 				Str("player", player).
 				Interface("status", status).
 				Msg("started playback of new track")
 
-			if notifyOnScrobble {
+			if options.NotifyOnScrobble {
+				shown := options.NotifyTruncate.Apply(status.Scrobble)
 				newID, err := notifier(
 					nowPlayingNotificationID,
-					fmt.Sprintf("%c now playing: %s", RuneBeamedSixteenthNotes, status.Track),
-					fmt.Sprintf("%s %c %s", status.JoinArtists(), RuneEmDash, status.Album),
+					fmt.Sprintf("%c now playing: %s", RuneBeamedSixteenthNotes, shown.Track),
+					fmt.Sprintf("%s %c %s", shown.JoinArtists(), RuneEmDash, shown.Album),
 				)
 				if err != nil {
 					log.Error().
@@ -157,29 +974,79 @@ func RunMainLoopOnce(
 				}
 			}
 
-			for _, sink := range sinks {
-				SendNowPlaying(player, sink, status, notifyOnError, notifier)
-			}
+			options.Events.Publish(NewEvent(EventNowPlaying, player, status.Scrobble))
+			sendNowPlaying(state, options, player, sinks, status, notifier)
 
 			continue
 		}
 
-		status.Timestamp = previouslyPlaying[player].Timestamp
+		status.Timestamp = state.PreviouslyPlaying[player].Timestamp
+
+		if played := PlayedBetween(lastSeen[player], status, maxAdvance); played > 0 {
+			state.PlayedTime[player] += played
+		} else if lastSeen[player].Equals(status) &&
+			playedBetween(lastSeen[player], status) &&
+			status.Position > lastSeen[player].Position {
+			log.Debug().
+				Str("player", player).
+				Dur("from", lastSeen[player].Position).
+				Dur("to", status.Position).
+				Msg("detected seek, not counting skipped part as played")
+		}
 
-		if status.Position < minPlayTime || status.State != PlaybackPlaying || scrobbledPrevious[player] {
+		if options.NowPlayingRefresh > 0 &&
+			status.State == PlaybackPlaying &&
+			time.Since(state.NowPlayingSent[player]) >= options.NowPlayingRefresh {
+			log.Debug().
+				Str("player", player).
+				Interface("status", status).
+				Msg("refreshing now playing status")
+			sendNowPlaying(state, options, player, sinks, status, notifier)
+		}
+
+		if state.PlayedTime[player] < minPlayTime || status.State != PlaybackPlaying || state.ScrobbledPrevious[player] {
 			continue
 		}
 
+		if group, ok := state.StitchScrobble(options.PlayerGroups, player, status.Scrobble, time.Now()); ok {
+			log.Info().
+				Str("player", player).
+				Str("group", group).
+				Interface("status", status).
+				Msg("track was already scrobbled by another player in the same group")
+			state.ScrobbledPrevious[player] = true
+			continue
+		}
+
+		// a track on repeat can reach the threshold sooner than one track
+		// duration after it was last scrobbled
+		if state.Repeated[player] {
+			delete(state.RecentScrobbles, dedupeKey(status.Scrobble))
+		}
+		if state.IsDuplicate(options.DedupeWindow, status.Scrobble, time.Now()) {
+			log.Info().
+				Str("player", player).
+				Interface("status", status).
+				Msg("same track was scrobbled recently, not scrobbling it again")
+			state.ScrobbledPrevious[player] = true
+			continue
+		}
+
+		if options.ScrobbleTimestamp == ScrobbleTimestampThreshold {
+			status.Timestamp = time.Now()
+		}
+
 		log.Info().
 			Str("player", player).
 			Interface("status", status).
 			Msg("scrobbling track")
 
-		if notifyOnScrobble {
+		if options.NotifyOnScrobble {
+			shown := options.NotifyTruncate.Apply(status.Scrobble)
 			if _, err := notifier(
 				uint32(0),
-				fmt.Sprintf("%c scrobbling: %s", RuneCheckMark, status.Track),
-				fmt.Sprintf("%s %c %s", status.JoinArtists(), RuneEmDash, status.Album),
+				fmt.Sprintf("%c scrobbling: %s", RuneCheckMark, shown.Track),
+				fmt.Sprintf("%s %c %s", shown.JoinArtists(), RuneEmDash, shown.Album),
 			); err != nil {
 				log.Error().
 					Err(err).
@@ -187,14 +1054,139 @@ func RunMainLoopOnce(
 			}
 		}
 
-		scrobbledPrevious[player] = true
+		// the track still counts as not scrobbled in the journal, so it is
+		// scrobbled after a restart if the daemon crashes while sending it
+		state.Journal.Save(state, time.Now(), true)
+
+		state.ScrobbledPrevious[player] = true
+		state.Stats.CountScrobble(time.Now())
+		state.LastScrobble = status.Scrobble
 
-		for _, sink := range sinks {
-			SendScrobble(player, sink, status, notifyOnError, notifier)
+		options.Events.Publish(NewEvent(EventThresholdReached, player, status.Scrobble))
+		sendScrobble(state, options, player, sinks, status, notifier)
+	}
+
+	state.Journal.Save(state, time.Now(), false)
+}
+
+// retryQueued submits the queued scrobbles of all sinks in parallel, with the
+// same timeout as other requests, so a hanging sink does not stall the loop.
+func retryQueued(options LoopOptions, sinks []Sink) {
+	var queuedSinks []Sink
+	for _, sink := range sinks {
+		if _, ok := sink.(QueuedSink); ok {
+			queuedSinks = append(queuedSinks, sink)
+		}
+	}
+
+	errs := DispatchSinks(queuedSinks, options.SinkTimeout, func(sink Sink) error {
+		if queuedSink, ok := sink.(QueuedSink); ok {
+			queuedSink.RetryQueued()
+		}
+		return nil
+	})
+	for i, sink := range queuedSinks {
+		if errs[i] != nil {
+			log.Warn().
+				Err(errs[i]).
+				Str("sink", sink.Name()).
+				Msg("error retrying queued scrobbles")
 		}
 	}
 }
 
+// sendNowPlaying updates the now playing status of all sinks in parallel.
+func sendNowPlaying(
+	state *LoopState,
+	options LoopOptions,
+	player string,
+	sinks []Sink,
+	status PlaybackStatus,
+	notifier NotifierFunc,
+) {
+	state.NowPlayingSent[player] = time.Now()
+
+	errs := DispatchSinks(sinks, options.SinkTimeout, func(sink Sink) error {
+		return updateNowPlaying(player, sink, status)
+	})
+	for i, sink := range sinks {
+		reportNowPlaying(player, sink, status, errs[i], options.NotifyOnError, notifier)
+		recordError(state.SinkErrors, sink.Name(), errs[i])
+		state.rememberError(sink.Name(), errs[i], time.Now())
+		publishError(options, player, sink, status.Scrobble, errs[i])
+	}
+}
+
+// sendScrobble sends a scrobble to all sinks in parallel and publishes the
+// result.
+func sendScrobble(
+	state *LoopState,
+	options LoopOptions,
+	player string,
+	sinks []Sink,
+	status PlaybackStatus,
+	notifier NotifierFunc,
+) {
+	if !ClockPlausible(time.Now()) {
+		if options.HoldUntilClockSync {
+			log.Warn().
+				Str("player", player).
+				Interface("status", status).
+				Msg("system clock is not set, holding scrobble until it is")
+			state.HeldScrobbles = append(state.HeldScrobbles, HeldScrobble{Player: player, Status: status})
+			return
+		}
+		log.Warn().
+			Str("player", player).
+			Interface("status", status).
+			Msg("system clock is not set, scrobble timestamp is wrong")
+	}
+	status.Timestamp = CheckTimestamp(status.Timestamp, time.Now())
+
+	errs := DispatchSinks(sinks, options.SinkTimeout, func(sink Sink) error {
+		return saveScrobble(player, sink, status)
+	})
+
+	var saved []string
+	for i, sink := range sinks {
+		err := errs[i]
+		reportScrobble(player, sink, status, err, options.NotifyOnError, notifier)
+		recordError(state.SinkErrors, sink.Name(), err)
+		state.rememberError(sink.Name(), err, time.Now())
+		publishError(options, player, sink, status.Scrobble, err)
+
+		if err == nil {
+			saved = append(saved, sink.Name())
+			state.LastSinkScrobbles[sink.Name()] = status.Scrobble
+		}
+	}
+
+	if len(saved) > 0 {
+		event := NewEvent(EventScrobbled, player, status.Scrobble)
+		event.Sinks = saved
+		options.Events.Publish(event)
+	}
+}
+
+func publishError(options LoopOptions, player string, sink Sink, scrobble Scrobble, err error) {
+	if err == nil {
+		return
+	}
+
+	event := NewEvent(EventError, player, scrobble)
+	event.Sink = sink.Name()
+	event.Error = err.Error()
+	options.Events.Publish(event)
+}
+
+// publishSkipped publishes a skipped event if the track the player was
+// playing before was not scrobbled.
+func (s *LoopState) publishSkipped(options LoopOptions, player string) {
+	if previous := s.PreviouslyPlaying[player]; previous.HasMetadata() && !s.ScrobbledPrevious[player] {
+		options.Events.Publish(NewEvent(EventSkipped, player, previous.Scrobble))
+	}
+}
+
 func CompilePlayerBlacklist(blacklist []string) []*regexp.Regexp {
 	var playerBlacklist []*regexp.Regexp
 
@@ -217,19 +1209,39 @@ func CompilePlayerBlacklist(blacklist []string) []*regexp.Regexp {
 	return playerBlacklist
 }
 
+// SendNowPlaying logs and reports errors of the sink and returns them.
 func SendNowPlaying(player string,
 	sink Sink,
 	status PlaybackStatus,
 	notifyOnError bool,
 	notifier NotifierFunc,
-) {
+) error {
+	err := updateNowPlaying(player, sink, status)
+	reportNowPlaying(player, sink, status, err, notifyOnError, notifier)
+	return err
+}
+
+func updateNowPlaying(player string, sink Sink, status PlaybackStatus) error {
 	log.Debug().
 		Str("player", player).
 		Str("sink", sink.Name()).
 		Interface("status", status).
 		Msg("updating now playing status")
 
-	if err := sink.NowPlaying(status.Scrobble); err != nil {
+	return sink.NowPlaying(status.Scrobble)
+}
+
+// reportNowPlaying logs the result of a now playing update and sends a
+// notification on errors. It is called from the main loop, so notifications
+// are never sent in parallel.
+func reportNowPlaying(player string,
+	sink Sink,
+	status PlaybackStatus,
+	err error,
+	notifyOnError bool,
+	notifier NotifierFunc,
+) {
+	if err != nil {
 		log.Error().
 			Str("player", player).
 			Str("sink", sink.Name()).
@@ -257,19 +1269,38 @@ func SendNowPlaying(player string,
 	}
 }
 
+// SendScrobble logs and reports errors of the sink and returns them.
 func SendScrobble(player string,
 	sink Sink,
 	status PlaybackStatus,
 	notifyOnError bool,
 	notifier NotifierFunc,
-) {
+) error {
+	err := saveScrobble(player, sink, status)
+	reportScrobble(player, sink, status, err, notifyOnError, notifier)
+	return err
+}
+
+func saveScrobble(player string, sink Sink, status PlaybackStatus) error {
 	log.Debug().
 		Str("player", player).
 		Str("sink", sink.Name()).
 		Interface("status", status).
 		Msg("saving scrobble")
 
-	if err := sink.Scrobble(status.Scrobble); err != nil {
+	return sink.Scrobble(status.Scrobble)
+}
+
+// reportScrobble logs the result of a scrobble and sends a notification on
+// errors.
+func reportScrobble(player string,
+	sink Sink,
+	status PlaybackStatus,
+	err error,
+	notifyOnError bool,
+	notifier NotifierFunc,
+) {
+	if err != nil {
 		log.Error().
 			Str("player", player).
 			Str("sink", sink.Name()).
@@ -307,6 +1338,10 @@ func MinPlayTime(
 	}
 
 	configDuration := time.Duration(minPlaybackDuration * int(time.Second))
+	// the duration of the track is unknown
+	if duration == 0 {
+		return configDuration, nil
+	}
 	halfDuration := time.Duration(minPlaybackPercent * int(duration/100))
 
 	return min(configDuration, halfDuration), nil
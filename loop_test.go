@@ -1,6 +1,7 @@
 package main_test
 
 import (
+	"errors"
 	"regexp"
 	"testing"
 	"time"
@@ -10,11 +11,7 @@ import (
 )
 
 func TestMainLoop(t *testing.T) {
-	previouslyPlaying := map[string]main.PlaybackStatus{}
-	scrobbledPrevious := map[string]bool{}
-
-	playerBlacklist := []*regexp.Regexp{}
-	parsedRegexes := []main.ParsedRegexReplace{}
+	state := main.NewLoopState()
 
 	fakeSource := &FakeSource{
 		Empty:          true,
@@ -26,26 +23,39 @@ func TestMainLoop(t *testing.T) {
 	fakeSink := &FakeSink{}
 	sinks := []main.Sink{fakeSink}
 
-	minPlaybackDuration := 4 * 60
-	minPlaybackPercent := 50
-
-	notifyOnScrobble := true
-	notifyOnError := true
+	options := main.LoopOptions{
+		Blacklist:           nil,
+		Bans:                nil,
+		Whitelist:           nil,
+		ParsedRegexes:       []main.ParsedRegexReplace{},
+		PlayerGroups:        []main.ParsedPlayerGroup{},
+		PlayerPolicy:        main.PlayerPolicyAll,
+		PlayerPriority:      []*regexp.Regexp{},
+		MinPlaybackDuration: 4 * 60,
+		MinPlaybackPercent:  50,
+		Players:             map[string]main.PlayerConfig{},
+		DedupeWindow:        0,
+		ScrobbleTimestamp:   main.ScrobbleTimestampStart,
+		UnknownDuration:     main.UnknownDurationIgnore,
+		PollInterval:        0,
+		IdlePollInterval:    0,
+		NotifyOnScrobble:    true,
+		NotifyOnError:       true,
+		HoldUntilClockSync:  false,
+		NotifyTruncate:      main.TruncateConfig{Artist: 0, Track: 0, Album: 0},
+		SinkTimeout:         0,
+		NowPlayingRefresh:   0,
+		Events:              nil,
+	}
 
 	fakeNotifier := FakeNotifier{}
 
 	runLoop := func() {
 		main.RunMainLoopOnce(
-			previouslyPlaying,
-			scrobbledPrevious,
-			playerBlacklist,
-			parsedRegexes,
+			state,
+			options,
 			sources,
 			sinks,
-			minPlaybackDuration,
-			minPlaybackPercent,
-			notifyOnScrobble,
-			notifyOnError,
 			fakeNotifier.SendNotification,
 		)
 	}
@@ -205,6 +215,131 @@ func TestMinPlayTime(t *testing.T) {
 		require.Equal(t, v, actual)
 	}
 
-	_, err := main.MinPlayTime(time.Duration(-time.Second), minPlaybackDuration, minPlaybackPercent)
+	// unknown duration
+	actual, err := main.MinPlayTime(0, minPlaybackDuration, minPlaybackPercent)
+	require.NoError(t, err)
+	require.Equal(t, 4*time.Minute, actual)
+
+	_, err = main.MinPlayTime(time.Duration(-time.Second), minPlaybackDuration, minPlaybackPercent)
 	require.Error(t, err)
 }
+
+func TestSkipCurrent(t *testing.T) {
+	state := main.NewLoopState()
+	fakeSource := &FakeSource{PlaybackStatus: defaultPlaybackStatus}
+	fakeSink := &FakeSink{}
+
+	options := replayOptions()
+	options.MinPlaybackDuration = 60
+	fakeNotifier := FakeNotifier{}
+
+	main.RunMainLoopOnce(state, options, []main.Source{fakeSource}, []main.Sink{fakeSink}, fakeNotifier.SendNotification)
+	require.Len(t, fakeSink.NowPlayingLog, 1)
+	require.Equal(t, 1, state.SkipCurrent())
+	require.Equal(t, 0, state.SkipCurrent())
+
+	fakeSource.PlaybackStatus.Position = 200 * time.Second
+	main.RunMainLoopOnce(state, options, []main.Source{fakeSource}, []main.Sink{fakeSink}, fakeNotifier.SendNotification)
+	require.Empty(t, fakeSink.ScrobbleLog)
+}
+
+type multiPlayerSource struct {
+	name    string
+	players map[string]main.PlaybackStatus
+	err     error
+}
+
+func (s *multiPlayerSource) Name() string {
+	return s.name
+}
+
+func (s *multiPlayerSource) GetInfo(
+	_ []main.ParsedFilterRule,
+	_ []main.ParsedRegexReplace,
+) (map[string]main.PlaybackStatus, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.players, nil
+}
+
+func TestMultiplePlayers(t *testing.T) {
+	state := main.NewLoopState()
+	options := replayOptions()
+	options.MinPlaybackDuration = 60
+	notifier := FakeNotifier{}
+	sink := &FakeSink{}
+
+	started := defaultPlaybackStatus
+	started.Position = 0
+	other := started
+	other.Track = "Every You Every Me"
+
+	desktop := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"spotify": started}}
+	cast := &multiPlayerSource{name: "upnp", players: map[string]main.PlaybackStatus{"spotify": other}}
+	sources := []main.Source{desktop, cast}
+
+	main.RunMainLoopOnce(state, options, sources, []main.Sink{sink}, notifier.SendNotification)
+	require.Len(t, state.CurrentlyPlaying, 2)
+	require.Equal(t, "Every You Every Me", state.CurrentlyPlaying["upnp:spotify"].Track)
+	require.Len(t, sink.NowPlayingLog, 2)
+
+	// a temporary error does not end the track of the cast device
+	cast.err = errors.New("connection refused")
+	for _, position := range []time.Duration{30 * time.Second, 90 * time.Second} {
+		for player, status := range desktop.players {
+			status.Position = position
+			desktop.players[player] = status
+		}
+		main.RunMainLoopOnce(state, options, sources, []main.Sink{sink}, notifier.SendNotification)
+		require.Len(t, state.CurrentlyPlaying, 2)
+	}
+	require.Len(t, sink.NowPlayingLog, 2)
+	require.Len(t, sink.ScrobbleLog, 1)
+
+	cast.err = nil
+	main.RunMainLoopOnce(state, options, sources, []main.Sink{sink}, notifier.SendNotification)
+	require.Len(t, sink.NowPlayingLog, 2)
+
+	// players of a source that keeps failing are dropped eventually
+	cast.err = errors.New("connection refused")
+	main.RunMainLoopOnce(state, options, sources, []main.Sink{sink}, notifier.SendNotification)
+	state.SourceFailures["upnp"] = time.Now().Add(-main.SourceErrorGrace)
+	main.RunMainLoopOnce(state, options, sources, []main.Sink{sink}, notifier.SendNotification)
+	require.Len(t, state.CurrentlyPlaying, 1)
+}
+
+func TestNowPlayingRefresh(t *testing.T) {
+	state := main.NewLoopState()
+	sink := &FakeSink{}
+	notifier := FakeNotifier{}
+	options := replayOptions()
+	options.NowPlayingRefresh = time.Hour
+
+	run := func(playbackState main.PlaybackState) {
+		status := defaultPlaybackStatus
+		status.State = playbackState
+		status.Position = 0
+		source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+		main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	}
+
+	run(main.PlaybackPlaying)
+	run(main.PlaybackPlaying)
+	require.Len(t, sink.NowPlayingLog, 1)
+
+	// the now playing status is sent again once the interval passed
+	state.NowPlayingSent["player"] = time.Now().Add(-2 * time.Hour)
+	run(main.PlaybackPlaying)
+	require.Len(t, sink.NowPlayingLog, 2)
+
+	// but not while paused
+	state.NowPlayingSent["player"] = time.Now().Add(-2 * time.Hour)
+	run(main.PlaybackPaused)
+	require.Len(t, sink.NowPlayingLog, 2)
+
+	// or when disabled
+	options.NowPlayingRefresh = 0
+	run(main.PlaybackPlaying)
+	require.Len(t, sink.NowPlayingLog, 2)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// LovingSink is implemented by sinks that can mark tracks as loved (e.g.,
+// LastFmSink).
+type LovingSink interface {
+	Sink
+	Love(scrobble Scrobble, love bool) error
+}
+
+// PlayingTrack returns the track of the given player, or of the only player
+// that is playing if player is empty.
+func PlayingTrack(playing map[string]PlaybackStatus, player string) (Scrobble, error) {
+	if player != "" {
+		status, ok := playing[player]
+		if !ok || len(status.Artists) == 0 || status.Track == "" {
+			return Scrobble{}, fmt.Errorf("player %s is not playing a track", player)
+		}
+		return status.Scrobble, nil
+	}
+
+	var players []string
+	for _, name := range slices.Sorted(maps.Keys(playing)) {
+		status := playing[name]
+		if status.State != PlaybackPlaying || len(status.Artists) == 0 || status.Track == "" {
+			continue
+		}
+		// the same track may be reported by more than one source
+		if len(players) > 0 && dedupeKey(playing[players[0]].Scrobble) == dedupeKey(status.Scrobble) {
+			continue
+		}
+		players = append(players, name)
+	}
+
+	switch len(players) {
+	case 0:
+		return Scrobble{}, errors.New("no track is playing")
+	case 1:
+		return playing[players[0]].Scrobble, nil
+	default:
+		return Scrobble{}, fmt.Errorf("more than one player is playing (%s), choose one with --player", strings.Join(players, ", "))
+	}
+}
+
+// LoveTrack loves (or unloves) the track on all sinks that support it and
+// returns the names of the sinks that succeeded.
+func LoveTrack(sinks []Sink, scrobble Scrobble, love bool) ([]string, error) {
+	var loved []string
+	var errs []error
+	supported := false
+
+	for _, sink := range sinks {
+		lovingSink, ok := UnwrapSink(sink).(LovingSink)
+		if !ok {
+			continue
+		}
+		supported = true
+
+		if err := lovingSink.Love(scrobble, love); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", sink.Name(), err.Error()))
+			continue
+		}
+		loved = append(loved, sink.Name())
+	}
+
+	if !supported {
+		return nil, errors.New("no configured sink supports loving tracks")
+	}
+	return loved, errors.Join(errs...)
+}
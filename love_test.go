@@ -0,0 +1,73 @@
+package main_test
+
+import (
+	"errors"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+type LovingSink struct {
+	FakeSink
+	Loved map[string]bool
+	Err   error
+}
+
+func (s *LovingSink) Love(scrobble main.Scrobble, love bool) error {
+	if s.Err != nil {
+		return s.Err
+	}
+	s.Loved[scrobble.Track] = love
+	return nil
+}
+
+func TestPlayingTrack(t *testing.T) {
+	paused := defaultPlaybackStatus
+	paused.State = main.PlaybackPaused
+	other := defaultPlaybackStatus
+	other.Track = "Every You Every Me"
+
+	_, err := main.PlayingTrack(map[string]main.PlaybackStatus{}, "")
+	require.Error(t, err)
+
+	scrobble, err := main.PlayingTrack(map[string]main.PlaybackStatus{
+		"spotify": defaultPlaybackStatus,
+		"vlc":     paused,
+		// the same track reported by another source
+		"upnp:spotify": defaultPlaybackStatus,
+	}, "")
+	require.NoError(t, err)
+	require.Equal(t, defaultScrobble.Track, scrobble.Track)
+
+	playing := map[string]main.PlaybackStatus{"spotify": defaultPlaybackStatus, "mpd": other}
+	_, err = main.PlayingTrack(playing, "")
+	require.ErrorContains(t, err, "mpd, spotify")
+
+	scrobble, err = main.PlayingTrack(playing, "mpd")
+	require.NoError(t, err)
+	require.Equal(t, "Every You Every Me", scrobble.Track)
+
+	_, err = main.PlayingTrack(playing, "vlc")
+	require.Error(t, err)
+}
+
+func TestLoveTrack(t *testing.T) {
+	_, err := main.LoveTrack([]main.Sink{&FakeSink{}}, defaultScrobble, true)
+	require.Error(t, err)
+
+	sink := &LovingSink{Loved: map[string]bool{}}
+	loved, err := main.LoveTrack([]main.Sink{&FakeSink{}, sink}, defaultScrobble, true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"fake sink"}, loved)
+	require.True(t, sink.Loved[defaultScrobble.Track])
+
+	_, err = main.LoveTrack([]main.Sink{sink}, defaultScrobble, false)
+	require.NoError(t, err)
+	require.False(t, sink.Loved[defaultScrobble.Track])
+
+	sink.Err = errors.New("invalid session key")
+	loved, err = main.LoveTrack([]main.Sink{sink}, defaultScrobble, true)
+	require.ErrorContains(t, err, "invalid session key")
+	require.Empty(t, loved)
+}
@@ -2,19 +2,23 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"time"
 
-	lastfm "github.com/p-mng/lastfm-go"
-	"github.com/rodaine/table"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
 )
 
 type ContextKey int
@@ -41,16 +45,30 @@ func main() {
 				Aliases: []string{"c"},
 				Usage:   "use a different configuration file",
 			},
+			&cli.BoolFlag{
+				Name:  "accessible",
+				Usage: "print screen reader friendly text instead of tables and full-screen output",
+			},
 		},
 		Commands: []*cli.Command{
 			{
-				Name:   "run",
-				Usage:  "Watch sources and send scrobbles to configured sinks",
+				Name:  "run",
+				Usage: "Watch sources and send scrobbles to configured sinks",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "only log what would be sent to which sink, without calling any sink",
+					},
+					&cli.BoolFlag{
+						Name:  "once",
+						Usage: "poll the sources once, submit the tracks that reached the threshold, and exit (e.g., from cron)",
+					},
+				},
 				Action: ActionRun,
 			},
 			{
 				Name:  "scrobbles",
-				Usage: "Print scrobbles for the given sink",
+				Usage: "Print scrobbles for the given sinks",
 				Flags: []cli.Flag{
 					&cli.IntFlag{
 						Name:    "limit",
@@ -72,108 +90,2640 @@ func main() {
 						DefaultText: "current datetime",
 						Usage:       "only display scrobbles before this time",
 					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print a JSON array instead of a table",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "print each scrobble using a Go template (e.g., '{{.Track}} — {{.JoinArtists}}')",
+					},
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "merge the scrobbles of all sinks",
+					},
+					&cli.StringSliceFlag{
+						Name:  "columns",
+						Usage: "columns of the table, in order (artists, track, album, duration, timestamp)",
+					},
+					&cli.StringFlag{
+						Name:  "sort",
+						Usage: "sort scrobbles by a column in ascending order instead of newest first",
+					},
+					&cli.BoolFlag{
+						Name:    "reverse",
+						Aliases: []string{"r"},
+						Usage:   "reverse the order of scrobbles",
+					},
 				},
 				Arguments: []cli.Argument{
-					&cli.StringArg{Name: "sink"},
+					&cli.StringArgs{Name: "sink", Min: 0, Max: -1},
 				},
 				Action: ActionScrobbles,
 			},
 			{
-				Name:   "check-config",
-				Usage:  "Check the config file, creating it if needed",
-				Action: ActionCheckConfig,
+				Name:  "search",
+				Usage: "Search the scrobbles of a sink (e.g., 'artist:placebo', 'album:^black market')",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:    "limit",
+						Aliases: []string{"l"},
+						Value:   10,
+						Usage:   "maximum number of scrobbles to display, newest first (0 displays all)",
+					},
+					&cli.TimestampFlag{
+						Name:        "from",
+						Aliases:     []string{"f"},
+						DefaultText: "first scrobble",
+						Usage:       "only search scrobbles after this time",
+					},
+					&cli.TimestampFlag{
+						Name:        "to",
+						Aliases:     []string{"t"},
+						Value:       time.Now(),
+						DefaultText: "current datetime",
+						Usage:       "only search scrobbles before this time",
+					},
+					&cli.BoolFlag{
+						Name:    "regex",
+						Aliases: []string{"r"},
+						Usage:   "treat the search terms as regular expressions",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print a JSON array instead of a table",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "print each scrobble using a Go template (e.g., '{{.Track}} — {{.JoinArtists}}')",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+					&cli.StringArgs{Name: "query", Min: 1, Max: -1},
+				},
+				Action: ActionSearch,
 			},
 			{
-				Name:   "list-sources",
-				Usage:  "Print all configured sources",
-				Action: ActionListSources,
+				Name:  "edit",
+				Usage: "Change the artist, track, or album of scrobbles in a local sink, selected by timestamp or search query",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "at",
+						Usage: "only select the scrobble with this timestamp (e.g., as printed by `goscrobble scrobbles`)",
+					},
+					&cli.BoolFlag{
+						Name:    "regex",
+						Aliases: []string{"r"},
+						Usage:   "treat the search terms as regular expressions",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "do not ask for confirmation",
+					},
+					&cli.StringFlag{
+						Name:  "artist",
+						Usage: "new artist",
+					},
+					&cli.StringFlag{
+						Name:  "track",
+						Usage: "new track",
+					},
+					&cli.StringFlag{
+						Name:  "album",
+						Usage: "new album",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+					&cli.StringArgs{Name: "query", Min: 0, Max: -1},
+				},
+				Action: ActionEdit,
 			},
 			{
-				Name:   "list-sinks",
-				Usage:  "Print all configured sinks",
-				Action: ActionListSinks,
+				Name:  "delete",
+				Usage: "Delete scrobbles from a local sink, selected by timestamp or search query",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "at",
+						Usage: "only select the scrobble with this timestamp (e.g., as printed by `goscrobble scrobbles`)",
+					},
+					&cli.BoolFlag{
+						Name:    "regex",
+						Aliases: []string{"r"},
+						Usage:   "treat the search terms as regular expressions",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "do not ask for confirmation",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+					&cli.StringArgs{Name: "query", Min: 0, Max: -1},
+				},
+				Action: ActionDelete,
 			},
 			{
-				Name:   "lastfm-auth",
-				Usage:  "Authenticate last.fm and save session key and username",
-				Action: ActionLastFmAuth,
+				Name:      "undo",
+				Usage:     "Retract the most recent scrobble from all sinks that can delete scrobbles, or only from the given sink",
+				UsageText: "goscrobble undo [options] [sink]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "do not ask for confirmation",
+					},
+				},
 				Arguments: []cli.Argument{
-					&cli.StringArg{Name: "key"},
+					&cli.StringArg{Name: "sink"},
 				},
+				Action: ActionUndo,
 			},
-		},
+			{
+				Name:      "top",
+				Usage:     "Print the most scrobbled artists, albums, or tracks of a sink",
+				UsageText: "goscrobble top [options] <artists|albums|tracks> <sink>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "since",
+						Value: "30d",
+						Usage: "time range ending now, in days (e.g., 30d), weeks (e.g., 2w), or all",
+					},
+					&cli.IntFlag{
+						Name:    "limit",
+						Aliases: []string{"l"},
+						Value:   DefaultTopLimit,
+						Usage:   "maximum number of entries to display (0 for all)",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print a JSON array instead of a chart",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "chart"},
+					&cli.StringArg{Name: "sink"},
+				},
+				Action: ActionTop,
+			},
+			{
+				Name:  "report",
+				Usage: "Print a digest of the last week or month with top artists, albums, and tracks",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "period",
+						Value: string(ReportWeek),
+						Usage: "period of the report, ending now (week or month)",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: string(ReportText),
+						Usage: "output format (text, markdown, or html)",
+					},
+					&cli.IntFlag{
+						Name:    "limit",
+						Aliases: []string{"l"},
+						Value:   DefaultReportLimit,
+						Usage:   "number of top artists, albums, and tracks",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "write to this file instead of stdout",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+				},
+				Action: ActionReport,
+			},
+			{
+				Name:  "export",
+				Usage: "Write the scrobbles of a sink to stdout or a file",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Value: string(ExportCSV),
+						Usage: "output format (csv, json, jsonl, or scrobbler-log)",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "write to this file instead of stdout",
+					},
+					&cli.TimestampFlag{
+						Name:        "from",
+						Aliases:     []string{"f"},
+						DefaultText: "first scrobble",
+						Usage:       "only export scrobbles after this time",
+					},
+					&cli.TimestampFlag{
+						Name:        "to",
+						Aliases:     []string{"t"},
+						Value:       time.Now(),
+						DefaultText: "current datetime",
+						Usage:       "only export scrobbles before this time",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+				},
+				Action: ActionExport,
+			},
+			{
+				Name:  "sync",
+				Usage: "Submit scrobbles missing from one sink to another",
+				Flags: []cli.Flag{
+					&cli.TimestampFlag{
+						Name:        "from",
+						Aliases:     []string{"f"},
+						Value:       time.Now().Add(-14 * 24 * time.Hour),
+						DefaultText: "current datetime minus 14 days",
+						Usage:       "only sync scrobbles after this time",
+					},
+					&cli.TimestampFlag{
+						Name:        "to",
+						Aliases:     []string{"t"},
+						Value:       time.Now(),
+						DefaultText: "current datetime",
+						Usage:       "only sync scrobbles before this time",
+					},
+					&cli.DurationFlag{
+						Name:  "tolerance",
+						Value: DefaultSyncTolerance,
+						Usage: "treat scrobbles of the same track at most this far apart as the same play",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "only print the missing scrobbles",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "source"},
+					&cli.StringArg{Name: "destination"},
+				},
+				Action: ActionSync,
+			},
+			{
+				Name:  "backfill",
+				Usage: "Copy the full history of a last.fm sink to a local sink, continuing where the last run stopped",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "restart",
+						Usage: "discard the saved progress and start from the newest scrobble",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "source"},
+					&cli.StringArg{Name: "destination"},
+				},
+				Action: ActionBackfill,
+			},
+			{
+				Name:   "status",
+				Usage:  "Print the current tracks, last scrobbles, and recent errors of the running daemon",
+				Action: ActionStatus,
+			},
+			{
+				Name:  "now-playing",
+				Usage: "Query all sources once and print the currently playing tracks",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print JSON instead of a table",
+					},
+				},
+				Action: ActionNowPlaying,
+			},
+			{
+				Name:  "love",
+				Usage: "Love the currently playing track (or the given track) on all sinks that support it",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "sink",
+						Usage: "only love the track on this sink",
+					},
+					&cli.StringFlag{
+						Name:  "player",
+						Usage: "love the track of this player if more than one is playing",
+					},
+					&cli.StringFlag{
+						Name:  "artist",
+						Usage: "love a track of this artist instead of the playing track (requires --track)",
+					},
+					&cli.StringFlag{
+						Name:  "track",
+						Usage: "love this track instead of the playing track (requires --artist)",
+					},
+					&cli.BoolFlag{
+						Name:  "unlove",
+						Usage: "remove the track from the loved tracks",
+					},
+				},
+				Action: ActionLove,
+			},
+			{
+				Name:      "ban",
+				Usage:     "Never scrobble the currently playing track, album, or artist again",
+				UsageText: "goscrobble ban [--artist|--track|--album] [--player <player>]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "artist",
+						Usage: "ban the artist of the playing track",
+					},
+					&cli.BoolFlag{
+						Name:  "track",
+						Usage: "ban the playing track (default)",
+					},
+					&cli.BoolFlag{
+						Name:  "album",
+						Usage: "ban the album of the playing track",
+					},
+					&cli.StringFlag{
+						Name:  "player",
+						Usage: "ban the track of this player if more than one is playing",
+					},
+				},
+				Action: ActionBan,
+			},
+			{
+				Name:  "scrobble",
+				Usage: "Scrobble a track manually, e.g. when listening to a record",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:     "artist",
+						Usage:    "artist of the track (repeat for multiple artists)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "track",
+						Usage:    "title of the track",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "album",
+						Usage: "album of the track",
+					},
+					&cli.DurationFlag{
+						Name:  "duration",
+						Usage: "length of the track (e.g., 4m11s)",
+					},
+					&cli.StringFlag{
+						Name:  "timestamp",
+						Usage: "time the track was played (as printed by `goscrobble scrobbles`, in RFC 3339 format, or as unix timestamp)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "sink",
+						Usage: "only scrobble to this sink (repeat for multiple sinks)",
+					},
+				},
+				Action: ActionScrobble,
+			},
+			{
+				Name:   "kiosk",
+				Usage:  "Display the current track full-screen (e.g., on a small display)",
+				Action: ActionKiosk,
+			},
+			{
+				Name:  "watch",
+				Usage: "Print the now playing, scrobble, and error events of the running daemon as they happen",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print every event as a line of JSON",
+					},
+					&cli.StringSliceFlag{
+						Name:  "type",
+						Usage: "only print events of this type (now_playing, threshold_reached, scrobbled, skipped, or error)",
+					},
+				},
+				Action: ActionWatch,
+			},
+			{
+				Name:   "tui",
+				Usage:  "Show the current tracks, recent scrobbles, and errors of the running daemon",
+				Action: ActionTUI,
+			},
+			{
+				Name:  "rebuild",
+				Usage: "Reconstruct a local sink from the audit log or another sink",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "from",
+						Usage: "copy scrobbles from this sink instead of the audit log",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "do not ask for confirmation",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+				},
+				Action: ActionRebuild,
+			},
+			{
+				Name:  "stats",
+				Usage: "Print listening statistics, skip and completion rates per artist, or listen-again suggestions for a sink",
+				Flags: []cli.Flag{
+					&cli.TimestampFlag{
+						Name:        "from",
+						Aliases:     []string{"f"},
+						Value:       time.Now().Add(-30 * 24 * time.Hour),
+						DefaultText: "current datetime minus 30 days",
+						Usage:       "only count scrobbles after this time",
+					},
+					&cli.TimestampFlag{
+						Name:        "to",
+						Aliases:     []string{"t"},
+						Value:       time.Now(),
+						DefaultText: "current datetime",
+						Usage:       "only count scrobbles before this time",
+					},
+					&cli.IntFlag{
+						Name:    "limit",
+						Aliases: []string{"l"},
+						Value:   20,
+						Usage:   "with --skips or --resurface, maximum number of artists to display",
+					},
+					&cli.BoolFlag{
+						Name:  "skips",
+						Usage: "print skip and completion rates per artist instead",
+					},
+					&cli.BoolFlag{
+						Name:  "resurface",
+						Usage: "list artists you played a lot but not for a long time instead",
+					},
+					&cli.DurationFlag{
+						Name:  "not-played-for",
+						Value: DefaultResurfaceAge,
+						Usage: "with --resurface, only list artists not played for this duration",
+					},
+					&cli.IntFlag{
+						Name:  "min-plays",
+						Value: DefaultResurfaceMinPlays,
+						Usage: "with --resurface, only list artists with at least this many scrobbles",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+				},
+				Action: ActionStats,
+			},
+			{
+				Name:  "dedupe",
+				Usage: "Remove duplicate scrobbles from a local sink",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "window",
+						Value: time.Minute,
+						Usage: "treat scrobbles of the same track at most this far apart as duplicates",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "only print the duplicates",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "do not ask for confirmation",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+				},
+				Action: ActionDedupe,
+			},
+			{
+				Name:  "purge",
+				Usage: "Remove tombstones of deleted scrobbles from a local sink",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "older-than",
+						Usage: "only remove tombstones of scrobbles deleted before this duration (e.g., 720h)",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+				},
+				Action: ActionPurge,
+			},
+			{
+				Name:  "replay",
+				Usage: "Feed a recorded playback stream through the scrobbling logic and print the result",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "pause",
+						Usage: "pause `player@frame:frames` for the given number of frames",
+					},
+					&cli.StringSliceFlag{
+						Name:  "seek",
+						Usage: "seek `player@frame:seconds` forward (or backward if negative)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "flap",
+						Usage: "remove the metadata of `player@frame:frames` for the given number of frames",
+					},
+					&cli.StringSliceFlag{
+						Name:  "crash",
+						Usage: "remove `player@frame:frames` for the given number of frames",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "file"},
+				},
+				Action: ActionReplay,
+			},
+			{
+				Name:  "soak",
+				Usage: "Run the scrobbling logic against configured sinks at high speed and report error rates, latencies, and memory growth",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "duration",
+						Value: DefaultSoakDuration,
+						Usage: "run the soak test for this duration",
+					},
+					&cli.DurationFlag{
+						Name:  "interval",
+						Value: DefaultSoakInterval,
+						Usage: "pause between main loop iterations",
+					},
+					&cli.BoolFlag{
+						Name:  "submit",
+						Usage: "actually submit scrobbles instead of dry runs (only use this with sandbox servers)",
+					},
+				},
+				Action: ActionSoak,
+			},
+			{
+				Name:  "verify-archive",
+				Usage: "Verify the signatures of a signed CSV sink",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "file",
+						Usage: "verify this file (e.g., an exported copy) instead of the sink file",
+					},
+					&cli.StringFlag{
+						Name:  "public-key",
+						Usage: "verify using this public key instead of the one in the state directory",
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+				},
+				Action: ActionVerifyArchive,
+			},
+			{
+				Name:  "service",
+				Usage: "Manage autostart using systemd, launchd, or the Windows task scheduler",
+				Commands: []*cli.Command{
+					{
+						Name:   "install",
+						Usage:  "Install and start the service for the current binary and config file",
+						Flags:  []cli.Flag{nativeServiceFlag},
+						Action: ActionServiceInstall,
+					},
+					{
+						Name:   "uninstall",
+						Usage:  "Stop and remove the service",
+						Flags:  []cli.Flag{nativeServiceFlag},
+						Action: ActionServiceUninstall,
+					},
+					{
+						Name:   "status",
+						Usage:  "Print the status reported by the service manager",
+						Flags:  []cli.Flag{nativeServiceFlag},
+						Action: ActionServiceStatus,
+					},
+				},
+			},
+			{
+				Name:  "queue",
+				Usage: "Inspect the offline queue",
+				Commands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "Print the queued scrobbles of all sinks or the given sink",
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "sink"},
+						},
+						Action: ActionQueueList,
+					},
+					{
+						Name:  "flush",
+						Usage: "Submit the queued scrobbles of all sinks or the given sink now",
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "sink"},
+						},
+						Action: ActionQueueFlush,
+					},
+					{
+						Name:  "clear",
+						Usage: "Remove queued scrobbles of all sinks or the given sink without submitting them",
+						Flags: []cli.Flag{
+							&cli.DurationFlag{
+								Name:  "older-than",
+								Usage: "only remove scrobbles older than this (e.g., 336h for scrobbles last.fm no longer accepts)",
+							},
+							&cli.BoolFlag{
+								Name:    "yes",
+								Aliases: []string{"y"},
+								Usage:   "do not ask for confirmation",
+							},
+						},
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "sink"},
+						},
+						Action: ActionQueueClear,
+					},
+				},
+			},
+			{
+				Name:  "ctl",
+				Usage: "Control the running daemon",
+				Commands: []*cli.Command{
+					{
+						Name:  "guest",
+						Usage: "Send scrobbles only to guest sinks (on) or to your own sinks again (off)",
+						Flags: []cli.Flag{
+							&cli.DurationFlag{
+								Name:  "for",
+								Usage: "turn guest mode off automatically after this duration (e.g., 3h)",
+							},
+						},
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "state"},
+						},
+						Action: ActionCtlGuest,
+					},
+					{
+						Name:  "pause",
+						Usage: "Stop scrobbling until it is resumed",
+						Flags: []cli.Flag{
+							&cli.DurationFlag{
+								Name:  "for",
+								Usage: "resume scrobbling automatically after this duration (e.g., 2h)",
+							},
+						},
+						Action: ActionCtlPause,
+					},
+					{
+						Name:   "resume",
+						Usage:  "Continue scrobbling, the tracks playing right now are not scrobbled",
+						Action: ActionCtlResume,
+					},
+				},
+			},
+			{
+				Name:  "import",
+				Usage: "Import listening history from other services into a local sink",
+				Commands: []*cli.Command{
+					{
+						Name:  "lastfm",
+						Usage: "Import a last.fm export in CSV or JSON format",
+						Flags: []cli.Flag{importSinkFlag},
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "file"},
+						},
+						Action: ActionImportLastFm,
+					},
+					{
+						Name:  "listenbrainz",
+						Usage: "Import a ListenBrainz export (the ZIP archive or a JSON file with the listens)",
+						Flags: []cli.Flag{importSinkFlag},
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "file"},
+						},
+						Action: ActionImportListenBrainz,
+					},
+					{
+						Name:  "scrobbler-log",
+						Usage: "Import a .scrobbler.log file of a portable player (e.g., Rockbox)",
+						Flags: []cli.Flag{importSinkFlag},
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "file"},
+						},
+						Action: ActionImportScrobblerLog,
+					},
+				},
+			},
+			{
+				Name:  "cache",
+				Usage: "Manage the cache of read-only lookups",
+				Commands: []*cli.Command{
+					{
+						Name:   "clear",
+						Usage:  "Remove all cached lookups",
+						Action: ActionCacheClear,
+					},
+				},
+			},
+			{
+				Name:   "health",
+				Usage:  "Query the health of the running daemon using the HTTP API (e.g., for container health checks)",
+				Action: ActionHealth,
+			},
+			{
+				Name:   "init",
+				Usage:  "Create the config file interactively",
+				Action: ActionInit,
+			},
+			{
+				Name:  "check-config",
+				Usage: "Check the config file, creating it if needed",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "strict",
+						Usage: "treat invalid and unknown settings as errors instead of replacing or ignoring them",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print the findings as JSON",
+					},
+				},
+				Action: ActionCheckConfig,
+			},
+			{
+				Name:  "config",
+				Usage: "Inspect the configuration",
+				Commands: []*cli.Command{
+					{
+						Name:   "show",
+						Usage:  "Print the effective configuration after defaults and validation, with secrets redacted",
+						Action: ActionConfigShow,
+					},
+					{
+						Name:  "migrate",
+						Usage: "Upgrade an older config file layout to the current one, keeping a backup of the original",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "only print the migrated config file, without changing it",
+							},
+						},
+						Action: ActionConfigMigrate,
+					},
+				},
+			},
+			{
+				Name:   "doctor",
+				Usage:  "Check the config file, sources, notifications, and sinks, and print what to fix",
+				Action: ActionDoctor,
+			},
+			{
+				Name:   "list-sources",
+				Usage:  "Print all configured sources",
+				Action: ActionListSources,
+			},
+			{
+				Name:   "list-sinks",
+				Usage:  "Print all configured sinks",
+				Action: ActionListSinks,
+			},
+			{
+				Name:  "auth",
+				Usage: "Manage the credentials of sinks and sources, `auth <sink>` is short for `auth login <sink>`",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "no-browser",
+						Usage: "print the authorization URL instead of opening it, e.g., on a server only reachable using SSH",
+					},
+					&cli.BoolFlag{
+						Name:  "no-keyring",
+						Usage: "save credentials in the config file, even if a system keyring is available",
+					},
+				},
+				Action: ActionAuthLogin,
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "sink"},
+				},
+				Commands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "Print the authentication status of all sinks and sources",
+						Action: ActionAuthList,
+					},
+					{
+						Name:  "login",
+						Usage: "Authenticate a sink or source and save its credentials",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "no-browser",
+								Usage: "print the authorization URL instead of opening it, e.g., on a server only reachable using SSH",
+							},
+							&cli.BoolFlag{
+								Name:  "no-keyring",
+								Usage: "save credentials in the config file, even if a system keyring is available",
+							},
+						},
+						Action: ActionAuthLogin,
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "sink"},
+						},
+					},
+					{
+						Name:   "logout",
+						Usage:  "Remove the saved credentials of a sink or source",
+						Action: ActionAuthLogout,
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "sink"},
+						},
+					},
+				},
+			},
+			{
+				Name:  "keyring",
+				Usage: "Manage secrets stored in the system keyring",
+				Commands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "Print all secrets of the config file and where they are stored",
+						Action: ActionKeyringList,
+					},
+					{
+						Name:   "set",
+						Usage:  "Save a secret in the system keyring, reading it from standard input",
+						Action: ActionKeyringSet,
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "secret"},
+						},
+					},
+					{
+						Name:   "delete",
+						Usage:  "Remove a secret from the system keyring",
+						Action: ActionKeyringDelete,
+						Arguments: []cli.Argument{
+							&cli.StringArg{Name: "secret"},
+						},
+					},
+				},
+			},
+			{
+				Name:   "lastfm-auth",
+				Usage:  "Authenticate last.fm and save session key and username (deprecated, use `auth <sink>`)",
+				Hidden: true,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "no-browser",
+						Usage: "print the authorization URL instead of opening it, e.g., on a server only reachable using SSH",
+					},
+					&cli.BoolFlag{
+						Name:  "no-keyring",
+						Usage: "save credentials in the config file, even if a system keyring is available",
+					},
+				},
+				Action: ActionLastFmAuth,
+				Arguments: []cli.Argument{
+					&cli.StringArg{Name: "key"},
+				},
+			},
+		},
+	}
+
+	cmd.Before = func(ctx context.Context, _ *cli.Command) (context.Context, error) {
+		SetupLogger(cmd)
+
+		// the init command creates the config file itself, check-config
+		// reports the errors of the config file instead of failing, config
+		// migrate reads config files that cannot be decoded anymore, and the
+		// keyring commands must work while secrets are missing from the keyring
+		args := cmd.Args().Slice()
+		if first := cmd.Args().First(); first == "init" || first == "check-config" {
+			return ctx, nil
+		}
+		if len(args) > 1 && args[0] == "config" && args[1] == "migrate" {
+			return ctx, nil
+		}
+		if first := cmd.Args().First(); first == "keyring" {
+			return ctx, nil
+		}
+
+		filename := ConfigFilename(cmd)
+		config, err := ReadConfig(filename)
+		if err != nil {
+			return ctx, fmt.Errorf("cannot read config file: %s", err.Error())
+		}
+
+		ctx = context.WithValue(ctx, ContextConfigKey, config)
+
+		return ctx, nil
+	}
+
+	if err := cmd.Run(context.Background(), os.Args); err != nil {
+		fmt.Println("Error:", err.Error())
+		os.Exit(1)
+	}
+}
+
+func ActionRun(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	run := RunOptions{DryRun: cmd.Bool("dry-run")}
+	if cmd.Bool("once") {
+		return RunOnce(config, run)
+	}
+
+	if RunningAsWindowsService() {
+		return RunWindowsService(func() {
+			RunMainLoop(config, ConfigFilename(cmd), run)
+		})
+	}
+
+	RunMainLoop(config, ConfigFilename(cmd), run)
+
+	return nil
+}
+
+func ActionStatus(_ context.Context, cmd *cli.Command) error {
+	status, ok := QueryDaemonStatus()
+	if !ok {
+		return errors.New("goscrobble is not running")
+	}
+
+	accessible := cmd.Bool("accessible")
+	now := time.Now()
+
+	fmt.Println(status.Summary(now))
+
+	fmt.Println()
+	players := NewTable(accessible, "PLAYER", "STATE", "TRACK", "POSITION", "SCROBBLE")
+	for _, p := range status.Players {
+		scrobble := "in " + p.UntilScrobble.Truncate(time.Second).String()
+		if p.Scrobbled {
+			scrobble = "scrobbled"
+		}
+		track := fmt.Sprintf("%s %c %s", p.Status.JoinArtists(), RuneEmDash, p.Status.Track)
+		players.AddRow(p.Player, string(p.Status.State), track, p.PrettyPosition(), scrobble)
+	}
+	players.Print()
+
+	fmt.Println()
+	sinks := NewTable(accessible, "SINK", "LAST SCROBBLE", "TIMESTAMP")
+	for _, name := range slices.Sorted(maps.Keys(status.LastScrobbles)) {
+		s := status.LastScrobbles[name]
+		sinks.AddRow(name, fmt.Sprintf("%s %c %s", s.JoinArtists(), RuneEmDash, s.Track), s.Timestamp.Format(time.RFC1123))
+	}
+	sinks.Print()
+
+	if len(status.Errors) > 0 {
+		fmt.Println()
+		errs := NewTable(accessible, "TIME", "SOURCE OR SINK", "ERROR")
+		for _, e := range slices.Backward(status.Errors) {
+			errs.AddRow(e.Time.Format(time.RFC1123), e.Name, e.Error)
+		}
+		errs.Print()
+	}
+
+	return nil
+}
+
+func ActionLove(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	var scrobble Scrobble
+	switch {
+	case cmd.String("artist") != "" && cmd.String("track") != "":
+		scrobble.Artists = []string{cmd.String("artist")}
+		scrobble.Track = cmd.String("track")
+	case cmd.String("artist") != "" || cmd.String("track") != "":
+		return errors.New("--artist and --track must be used together")
+	default:
+		playing, err := PlayingTrack(playingTracks(config), cmd.String("player"))
+		if err != nil {
+			return err
+		}
+		scrobble = playing
+	}
+
+	sinks := config.SetupSinks()
+	if cmd.String("sink") != "" {
+		sink, err := FindSink(sinks, cmd.String("sink"))
+		if err != nil {
+			return err
+		}
+		sinks = []Sink{sink}
+	}
+
+	verb := "loved"
+	if cmd.Bool("unlove") {
+		verb = "unloved"
+	}
+
+	loved, err := LoveTrack(sinks, scrobble, !cmd.Bool("unlove"))
+	for _, name := range loved {
+		fmt.Printf("%s %s %c %s on %s\n", verb, scrobble.JoinArtists(), RuneEmDash, scrobble.Track, name)
+	}
+	return err
+}
+
+func ActionScrobble(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	scrobble := Scrobble{
+		Artists:   cmd.StringSlice("artist"),
+		Track:     cmd.String("track"),
+		Album:     cmd.String("album"),
+		Duration:  cmd.Duration("duration"),
+		Timestamp: time.Time{},
+	}
+	if cmd.String("timestamp") != "" {
+		timestamp, err := ParseScrobbleTime(cmd.String("timestamp"))
+		if err != nil {
+			return err
+		}
+		scrobble.Timestamp = timestamp
+	}
+
+	now := time.Now()
+	scrobble, err := config.ManualScrobble(scrobble, now)
+	if err != nil {
+		return err
+	}
+
+	sinks := config.SetupSinks()
+	selected := RouteSinks(sinks, false)
+	var names []string
+	if len(cmd.StringSlice("sink")) > 0 {
+		selected = nil
+		for _, name := range cmd.StringSlice("sink") {
+			sink, err := FindSink(sinks, name)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, sink)
+			names = append(names, sink.Name())
+		}
+	}
+
+	// the running daemon submits the scrobble, so it counts for its
+	// statistics and shares its rate limits
+	if _, ok := QueryDaemonStatus(); ok {
+		response, err := SendControlRequest(ControlSocketFilename(), ControlRequest{Command: "scrobble", Arguments: names, Scrobble: &scrobble})
+		if response.Error != "" {
+			return errors.New(response.Error)
+		} else if err != nil {
+			return fmt.Errorf("cannot send command to daemon: %s", err.Error())
+		}
+	} else if err := SubmitScrobble(NewLoopState(), selected, scrobble, now); err != nil {
+		return err
+	}
+
+	fmt.Printf("scrobbled %s %c %s at %s\n", scrobble.JoinArtists(), RuneEmDash, scrobble.Track, scrobble.Timestamp.Format(time.RFC1123))
+	return nil
+}
+
+// playingTracks returns the players of the running daemon, or queries the
+// sources if it is not running.
+func playingTracks(config Config) map[string]PlaybackStatus {
+	response, err := SendControlRequest(ControlSocketFilename(), ControlRequest{Command: "now-playing", Arguments: nil, Scrobble: nil})
+	if err == nil {
+		return response.NowPlaying
+	}
+
+	sources := config.SetupSources()
+	defer CloseSources(sources)
+
+	playing := map[string]PlaybackStatus{}
+	for _, e := range QueryNowPlaying(sources, config.ParseBlacklist(), config.ParseRegexes(), "") {
+		playing[e.Player] = PlaybackStatus{
+			Scrobble: Scrobble{
+				Artists:   e.Artists,
+				Track:     e.Track,
+				Album:     e.Album,
+				Duration:  time.Duration(e.Duration) * time.Second,
+				Timestamp: time.Time{},
+			},
+			State:    e.State,
+			Position: time.Duration(e.Position) * time.Second,
+		}
+	}
+	return playing
+}
+
+func ActionBan(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	field := ""
+	for _, name := range []string{"artist", "track", "album"} {
+		if !cmd.Bool(name) {
+			continue
+		}
+		if field != "" {
+			return errors.New("only one of --artist, --track, and --album can be used")
+		}
+		field = name
+	}
+	if field == "" {
+		field = "track"
+	}
+
+	playing, err := PlayingTrack(playingTracks(config), cmd.String("player"))
+	if err != nil {
+		return err
+	}
+
+	ban, err := NewBan(playing, field)
+	if err != nil {
+		return err
+	}
+
+	filename := BanListFilename(ConfigFilename(cmd))
+	added, err := AddBan(filename, ban)
+	if err != nil {
+		return fmt.Errorf("cannot update ban list: %s", err.Error())
+	}
+	if !added {
+		fmt.Printf("%s is already banned\n", ban)
+		return nil
+	}
+	fmt.Printf("banned %s in %s\n", ban, filename)
+
+	if _, ok := QueryDaemonStatus(); ok {
+		return sendDaemonCommand("reload")
+	}
+	return nil
+}
+
+func ActionNowPlaying(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	playerBlacklist := config.ParseBlacklist()
+	parsedRegexes := config.ParseRegexes()
+
+	sources := config.SetupSources()
+	defer CloseSources(sources)
+	entries := QueryNowPlaying(sources, playerBlacklist, parsedRegexes, "")
+
+	for _, name := range slices.Sorted(maps.Keys(config.Users)) {
+		userSources := config.ForUser(name).SetupSources()
+		entries = append(entries, QueryNowPlaying(userSources, playerBlacklist, parsedRegexes, name+"/")...)
+		CloseSources(userSources)
+	}
+
+	if cmd.Bool("json") {
+		if entries == nil {
+			entries = []NowPlayingEntry{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	tbl := NewTable(cmd.Bool("accessible"), "PLAYER", "SOURCE", "STATE", "ARTISTS", "TRACK", "ALBUM", "POSITION")
+	for _, e := range entries {
+		tbl.AddRow(e.Player, e.Source, e.State, strings.Join(e.Artists, ", "), e.Track, e.Album, e.PrettyPosition())
+	}
+	tbl.Print()
+
+	return nil
+}
+
+func ActionKiosk(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	RunKiosk(config, cmd.Bool("accessible"))
+
+	return nil
+}
+
+func ActionWatch(_ context.Context, cmd *cli.Command) error {
+	if _, ok := QueryDaemonStatus(); !ok {
+		return errors.New("goscrobble is not running (start it with `goscrobble run`)")
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	return WatchEvents(ControlSocketFilename(), cmd.StringSlice("type"), func(event Event) error {
+		if cmd.Bool("json") {
+			return encoder.Encode(event)
+		}
+		_, err := fmt.Println(event.Summary())
+		return err
+	})
+}
+
+func ActionTUI(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	var sinks []string
+	for _, sink := range config.SetupSinks() {
+		sinks = append(sinks, sink.Name())
+	}
+
+	RunTUI(sinks, time.Second, cmd.Bool("accessible"))
+
+	return nil
+}
+
+func ActionScrobbles(ctx context.Context, cmd *cli.Command) error {
+	limit := cmd.Int("limit")
+	from := cmd.Timestamp("from")
+	to := cmd.Timestamp("to")
+
+	sinkNames := cmd.StringArgs("sink")
+
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	if cmd.Bool("json") && cmd.String("format") != "" {
+		return errors.New("--json and --format cannot be used together")
+	}
+
+	if cmd.Bool("all") || len(sinkNames) > 1 {
+		return aggregateScrobbles(cmd, config, sinkNames)
+	}
+
+	var sinkName string
+	if len(sinkNames) == 1 {
+		sinkName = sinkNames[0]
+	}
+	sink, err := FindSink(config.SetupSinks(), sinkName)
+	if err != nil {
+		return err
+	}
+
+	columns, err := SelectScrobbleColumns(cmd.StringSlice("columns"), []string{"artists", "track", "album", "duration", "timestamp"})
+	if err != nil {
+		return err
+	}
+
+	scrobbles, err := sink.GetScrobbles(limit, from, to)
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+
+	err = OrderScrobbles(scrobbles, func(s Scrobble) Scrobble { return s }, cmd.String("sort"), cmd.Bool("reverse"))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case cmd.Bool("json"):
+		return ExportScrobbles(os.Stdout, ExportJSON, scrobbles)
+	case cmd.String("format") != "":
+		return PrintScrobbles(os.Stdout, cmd.String("format"), scrobbles)
+	}
+
+	tbl := NewTable(cmd.Bool("accessible"), scrobbleHeaders(columns)...)
+	for _, s := range scrobbles {
+		tbl.AddRow(scrobbleRow(columns, s)...)
+	}
+	tbl.Print()
+
+	return nil
+}
+
+func scrobbleHeaders(columns []ScrobbleColumn, extra ...string) []string {
+	headers := make([]string, 0, len(columns)+len(extra))
+	for _, column := range columns {
+		headers = append(headers, column.Header)
+	}
+	return append(headers, extra...)
+}
+
+func scrobbleRow(columns []ScrobbleColumn, scrobble Scrobble, extra ...any) []any {
+	row := make([]any, 0, len(columns)+len(extra))
+	for _, column := range columns {
+		row = append(row, column.Value(scrobble))
+	}
+	return append(row, extra...)
+}
+
+func ActionSearch(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sink, err := FindSink(config.SetupSinks(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("json") && cmd.String("format") != "" {
+		return errors.New("--json and --format cannot be used together")
+	}
+
+	query, err := ParseSearchQuery(cmd.StringArgs("query"), cmd.Bool("regex"))
+	if err != nil {
+		return err
+	}
+
+	scrobbles, err := sink.GetScrobbles(0, cmd.Timestamp("from"), cmd.Timestamp("to"))
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+	matches := SearchScrobbles(scrobbles, query, cmd.Int("limit"))
+
+	switch {
+	case cmd.Bool("json"):
+		return ExportScrobbles(os.Stdout, ExportJSON, matches)
+	case cmd.String("format") != "":
+		return PrintScrobbles(os.Stdout, cmd.String("format"), matches)
+	}
+
+	if len(matches) == 0 {
+		fmt.Println("No matching scrobbles found")
+		return nil
+	}
+
+	tbl := NewTable(cmd.Bool("accessible"), "ARTISTS", "TRACK", "ALBUM", "DURATION", "TIMESTAMP")
+	for _, s := range matches {
+		tbl.AddRow(s.JoinArtists(), s.Track, s.Album, s.PrettyDuration(), s.Timestamp.Format(time.RFC1123))
+	}
+	tbl.Print()
+
+	return nil
+}
+
+func ActionEdit(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sink, err := FindSink(config.SetupSinks(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	edit := ScrobbleEdit{Artists: nil, Track: cmd.String("track"), Album: cmd.String("album")}
+	if cmd.String("artist") != "" {
+		edit.Artists = []string{cmd.String("artist")}
+	}
+	if edit.Empty() {
+		return errors.New("nothing to change (use --artist, --track, or --album)")
+	}
+
+	selected, err := selectScrobbles(cmd, sink)
+	if err != nil || len(selected) == 0 {
+		return err
+	}
+
+	changed := func(before, after string) string {
+		if before == after {
+			return before
+		}
+		return before + " -> " + after
+	}
+	tbl := NewTable(cmd.Bool("accessible"), "ARTISTS", "TRACK", "ALBUM", "TIMESTAMP")
+	for _, s := range selected {
+		edited := edit.Apply(s)
+		tbl.AddRow(changed(s.JoinArtists(), edited.JoinArtists()), changed(s.Track, edited.Track), changed(s.Album, edited.Album), s.Timestamp.Format(time.RFC1123))
+	}
+	tbl.Print()
+
+	if !cmd.Bool("yes") {
+		fmt.Printf("Edit %d scrobbles in %s? [y/N] ", len(selected), sink.Name())
+
+		input := bufio.NewScanner(os.Stdin)
+		input.Scan()
+
+		if strings.ToLower(strings.TrimSpace(input.Text())) != "y" {
+			return errors.New("aborted")
+		}
+	}
+
+	edited, err := EditScrobbles(sink, selected, edit)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Edited %d scrobbles in %s\n", len(edited), sink.Name())
+	return nil
+}
+
+func ActionDelete(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sink, err := FindSink(config.SetupSinks(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	tombstoneSink, ok := UnwrapSink(sink).(TombstoneSink)
+	if !ok {
+		return fmt.Errorf("cannot delete scrobbles from sink %s", sink.Name())
+	}
+
+	selected, err := selectScrobbles(cmd, sink)
+	if err != nil || len(selected) == 0 {
+		return err
+	}
+
+	tbl := NewTable(cmd.Bool("accessible"), "ARTISTS", "TRACK", "ALBUM", "DURATION", "TIMESTAMP")
+	for _, s := range selected {
+		tbl.AddRow(s.JoinArtists(), s.Track, s.Album, s.PrettyDuration(), s.Timestamp.Format(time.RFC1123))
+	}
+	tbl.Print()
+
+	if !cmd.Bool("yes") {
+		fmt.Printf("Delete %d scrobbles from %s? [y/N] ", len(selected), sink.Name())
+
+		input := bufio.NewScanner(os.Stdin)
+		input.Scan()
+
+		if strings.ToLower(strings.TrimSpace(input.Text())) != "y" {
+			return errors.New("aborted")
+		}
+	}
+
+	if err := tombstoneSink.DeleteScrobbles(selected); err != nil {
+		return fmt.Errorf("error deleting scrobbles: %s", err.Error())
+	}
+
+	fmt.Printf("Deleted %d scrobbles from %s\n", len(selected), sink.Name())
+	return nil
+}
+
+func ActionUndo(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sinks := RetractableSinks(config.SetupSinks())
+	if name := cmd.StringArg("sink"); name != "" {
+		sink, err := FindSink(config.SetupSinks(), name)
+		if err != nil {
+			return err
+		}
+		if _, ok := UnwrapSink(sink).(RetractableSink); !ok {
+			return fmt.Errorf("cannot retract scrobbles from sink %s", sink.Name())
+		}
+		sinks = []Sink{sink}
+	}
+	if len(sinks) == 0 {
+		return errors.New("no configured sink can retract scrobbles")
+	}
+
+	window := time.Duration(config.UndoWindow) * time.Second
+	now := time.Now()
+
+	scrobble, stored, found, err := LastScrobble(sinks, now.Add(-window), now)
+	if err != nil {
+		log.Warn().Str("error", err.Error()).Msg("error fetching scrobbles")
+	}
+	if !found {
+		return fmt.Errorf("no scrobbles within the last %s (see undo_window)", window)
+	}
+
+	names := make([]string, 0, len(stored))
+	for _, sink := range stored {
+		names = append(names, sink.Name())
+	}
+
+	tbl := NewTable(cmd.Bool("accessible"), "ARTISTS", "TRACK", "ALBUM", "TIMESTAMP", "SINKS")
+	tbl.AddRow(scrobble.JoinArtists(), scrobble.Track, scrobble.Album, scrobble.Timestamp.Format(time.RFC1123), strings.Join(names, ", "))
+	tbl.Print()
+
+	if !cmd.Bool("yes") {
+		fmt.Print("Retract this scrobble? [y/N] ")
+
+		input := bufio.NewScanner(os.Stdin)
+		input.Scan()
+
+		if strings.ToLower(strings.TrimSpace(input.Text())) != "y" {
+			return errors.New("aborted")
+		}
+	}
+
+	retracted, err := RetractScrobble(stored, scrobble)
+	if len(retracted) > 0 {
+		fmt.Printf("Retracted the scrobble from %s\n", strings.Join(retracted, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("error retracting scrobble: %s", err.Error())
+	}
+	return nil
+}
+
+// selectScrobbles returns the scrobbles of the sink selected by the --at flag
+// and the search query of the edit and delete commands.
+func selectScrobbles(cmd *cli.Command, sink Sink) ([]Scrobble, error) {
+	var at time.Time
+	if cmd.String("at") != "" {
+		var err error
+		if at, err = ParseScrobbleTime(cmd.String("at")); err != nil {
+			return nil, fmt.Errorf("invalid timestamp: %s", err.Error())
+		}
+	}
+
+	terms := cmd.StringArgs("query")
+	if at.IsZero() && len(terms) == 0 {
+		return nil, errors.New("no scrobbles selected (use --at or a search query)")
+	}
+
+	query, err := ParseSearchQuery(terms, cmd.Bool("regex"))
+	if err != nil {
+		return nil, err
+	}
+
+	scrobbles, err := sink.GetScrobbles(0, time.Time{}, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+
+	selected := SelectScrobbles(scrobbles, at, query)
+	if len(selected) == 0 {
+		fmt.Println("No matching scrobbles found")
+	}
+	return selected, nil
+}
+
+// aggregateScrobbles prints the merged scrobbles of several sinks, with the
+// sinks each scrobble is missing from.
+func aggregateScrobbles(cmd *cli.Command, config Config, sinkNames []string) error {
+	sinks := config.SetupSinks()
+	if !cmd.Bool("all") {
+		var selected []Sink
+		for _, name := range sinkNames {
+			sink, err := FindSink(sinks, name)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err.Error())
+			}
+			selected = append(selected, sink)
+		}
+		sinks = selected
+	}
+	if len(sinks) == 0 {
+		return errors.New("no sinks are set up")
+	}
+
+	columns, err := SelectScrobbleColumns(cmd.StringSlice("columns"), []string{"artists", "track", "album", "timestamp"})
+	if err != nil {
+		return err
+	}
+
+	fetched, errs := FetchScrobbles(sinks, cmd.Int("limit"), cmd.Timestamp("from"), cmd.Timestamp("to"))
+	if len(fetched) == 0 {
+		return fmt.Errorf("error fetching scrobbles: %s", errors.Join(errs...).Error())
+	}
+	for _, err := range errs {
+		log.Warn().Err(err).Msg("error fetching scrobbles, sink is left out")
+	}
+
+	scrobbles := AggregateScrobbles(fetched, DefaultSyncTolerance)
+	err = OrderScrobbles(scrobbles, func(s AggregatedScrobble) Scrobble { return s.Scrobble }, cmd.String("sort"), cmd.Bool("reverse"))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case cmd.Bool("json"):
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(scrobbles)
+	case cmd.String("format") != "":
+		return PrintAggregatedScrobbles(os.Stdout, cmd.String("format"), scrobbles)
+	}
+
+	tbl := NewTable(cmd.Bool("accessible"), scrobbleHeaders(columns, "SINKS", "MISSING")...)
+	for _, s := range scrobbles {
+		tbl.AddRow(scrobbleRow(columns, s.Scrobble, strings.Join(s.Sinks, ", "), strings.Join(s.Missing, ", "))...)
+	}
+	tbl.Print()
+
+	return nil
+}
+
+func ActionExport(ctx context.Context, cmd *cli.Command) error {
+	format := ExportFormat(cmd.String("format"))
+	if !slices.Contains(ExportFormats, format) {
+		return fmt.Errorf("invalid export format: %s", format)
+	}
+
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sink, err := FindSink(config.SetupSinks(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	scrobbles, err := sink.GetScrobbles(0, cmd.Timestamp("from"), cmd.Timestamp("to"))
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+	SortScrobbles(scrobbles)
+
+	output := cmd.String("output")
+	if output == "" {
+		return ExportScrobbles(os.Stdout, format, scrobbles)
+	}
+
+	//nolint:gosec
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %s", err.Error())
+	}
+	if err := ExportScrobbles(file, format, scrobbles); err != nil {
+		CloseLogged(file)
+		return fmt.Errorf("error writing output file: %s", err.Error())
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing output file: %s", err.Error())
+	}
+
+	fmt.Printf("Exported %d scrobbles to %s\n", len(scrobbles), output)
+	return nil
+}
+
+func ActionTop(ctx context.Context, cmd *cli.Command) error {
+	top, ok := TopCharts[cmd.StringArg("chart")]
+	if !ok {
+		return errors.New("must specify artists, albums, or tracks")
+	}
+
+	since, err := ParseSince(cmd.String("since"))
+	if err != nil {
+		return err
+	}
+
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sink, err := FindSink(config.SetupSinks(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	to := time.Now()
+	var from time.Time
+	if since > 0 {
+		from = to.Add(-since)
+	}
+
+	scrobbles, err := sink.GetScrobbles(0, from, to)
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+	entries := top(scrobbles, cmd.Int("limit"))
+
+	if cmd.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	if cmd.Bool("accessible") {
+		tbl := NewTable(true, "RANK", strings.ToUpper(strings.TrimSuffix(cmd.StringArg("chart"), "s")), "SCROBBLES")
+		for i, entry := range entries {
+			tbl.AddRow(i+1, entry.String(), entry.Plays)
+		}
+		tbl.Print()
+		return nil
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No scrobbles")
+	}
+	for _, line := range ChartBars(entries) {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func ActionReport(ctx context.Context, cmd *cli.Command) error {
+	format := ReportFormat(cmd.String("format"))
+	if !slices.Contains(ReportFormats, format) {
+		return fmt.Errorf("invalid report format: %s", format)
+	}
+
+	to := time.Now()
+	from, err := ReportPeriod(cmd.String("period")).Start(to)
+	if err != nil {
+		return err
+	}
+
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sink, err := FindSink(config.SetupSinks(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	scrobbles, err := sink.GetScrobbles(0, from, to)
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+	// the earlier history is only needed for the new artists, so it may be
+	// slightly outdated
+	history, err := CachedScrobbles(config.SetupCache(), sink, time.Time{}, to)
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+
+	report := NewListeningReport(sink.Name(), scrobbles, history, from, to, cmd.Int("limit"))
+
+	output := cmd.String("output")
+	if output == "" {
+		return RenderReport(os.Stdout, format, report)
+	}
+
+	//nolint:gosec
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %s", err.Error())
+	}
+	if err := RenderReport(file, format, report); err != nil {
+		CloseLogged(file)
+		return fmt.Errorf("error writing output file: %s", err.Error())
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("error writing output file: %s", err.Error())
+	}
+
+	fmt.Printf("Wrote report to %s\n", output)
+	return nil
+}
+
+func ActionSync(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+	sinks := config.SetupSinks()
+
+	source, err := FindSink(sinks, cmd.StringArg("source"))
+	if err != nil {
+		return err
+	}
+	destination, err := FindSink(sinks, cmd.StringArg("destination"))
+	if err != nil {
+		return err
+	}
+	if source.Name() == destination.Name() {
+		return errors.New("cannot sync a sink with itself")
+	}
+
+	from := cmd.Timestamp("from")
+	to := cmd.Timestamp("to")
+	tolerance := cmd.Duration("tolerance")
+
+	scrobbles, err := source.GetScrobbles(0, from, to)
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles from %s: %s", source.Name(), err.Error())
+	}
+	// plays at the edges of the range may have a slightly different
+	// timestamp in the destination
+	existing, err := SyncDestinationScrobbles(destination, from.Add(-tolerance), to.Add(tolerance))
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles from %s: %s", destination.Name(), err.Error())
+	}
+
+	missing := MissingScrobbles(scrobbles, existing, tolerance)
+	SortScrobbles(missing)
+
+	if cmd.Bool("dry-run") {
+		tbl := NewTable(cmd.Bool("accessible"), "ARTISTS", "TRACK", "ALBUM", "DURATION", "TIMESTAMP")
+		for _, s := range missing {
+			tbl.AddRow(s.JoinArtists(), s.Track, s.Album, s.PrettyDuration(), s.Timestamp.Format(time.RFC1123))
+		}
+		tbl.Print()
+		return nil
+	}
+
+	if len(missing) == 0 {
+		fmt.Printf("%s has no missing scrobbles\n", destination.Name())
+		return nil
+	}
+
+	submitted, err := SyncScrobbles(destination, missing)
+	fmt.Printf("Submitted %d of %d missing scrobbles to %s\n", submitted, len(missing), destination.Name())
+	if err != nil {
+		return fmt.Errorf("error syncing scrobbles: %s", err.Error())
+	}
+	return nil
+}
+
+func ActionBackfill(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+	sinks := config.SetupSinks()
+
+	source, err := FindSink(sinks, cmd.StringArg("source"))
+	if err != nil {
+		return err
+	}
+	destination, err := FindSink(sinks, cmd.StringArg("destination"))
+	if err != nil {
+		return err
+	}
+
+	paged, ok := UnwrapSink(source).(PagedSink)
+	if !ok {
+		return fmt.Errorf("sink %s does not support fetching its full history", source.Name())
+	}
+
+	filename := BackfillFilename()
+	if cmd.Bool("restart") {
+		progress := BackfillProgress{Source: source.Name(), Destination: destination.Name(), To: time.Time{}, Page: 0, TotalPages: 0, Imported: 0}
+		if err := SaveBackfillProgress(filename, progress, true); err != nil {
+			return fmt.Errorf("error removing backfill progress: %s", err.Error())
+		}
+	}
+
+	backfill := Backfill{
+		Source:      paged,
+		Destination: destination,
+		Filename:    filename,
+		BatchPages:  DefaultBackfillBatchPages,
+		OnProgress: func(progress BackfillProgress) {
+			fmt.Printf("Fetched %d of %d pages, imported %d scrobbles\n", progress.Page-1, progress.TotalPages, progress.Imported)
+		},
+	}
+
+	progress, err := backfill.Run(time.Now())
+	if err != nil && progress.Page > 1 {
+		return fmt.Errorf("%s (run the command again to continue with page %d)", err.Error(), progress.Page)
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Printf("Backfilled %s with %d scrobbles from %s\n", destination.Name(), progress.Imported, source.Name())
+	return nil
+}
+
+func ActionRebuild(ctx context.Context, cmd *cli.Command) error {
+	sinkName := cmd.StringArg("sink")
+	fromName := cmd.String("from")
+
+	config := ctx.Value(ContextConfigKey).(Config)
+	sinks := config.SetupSinks()
+
+	sink, err := FindSink(sinks, sinkName)
+	if err != nil {
+		return err
+	}
+
+	editable, ok := UnwrapSink(sink).(EditableSink)
+	if !ok {
+		return fmt.Errorf("sink %s cannot be rebuilt", sink.Name())
+	}
+
+	var scrobbles []Scrobble
+	if fromName != "" {
+		from, err := FindSink(sinks, fromName)
+		if err != nil {
+			return err
+		}
+		if from.Name() == sink.Name() {
+			return errors.New("cannot rebuild a sink from itself")
+		}
+
+		scrobbles, err = from.GetScrobbles(0, time.Time{}, time.Now())
+		if err != nil {
+			return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+		}
+		SortScrobbles(scrobbles)
+	} else {
+		entries, err := ReadAuditLog(AuditLogFilename())
+		if err != nil {
+			return fmt.Errorf("error reading audit log: %s", err.Error())
+		}
+		scrobbles = AuditedScrobbles(entries)
+	}
+
+	scrobbles, err = WithoutDeleted(sink, scrobbles)
+	if err != nil {
+		return err
+	}
+
+	if !cmd.Bool("yes") {
+		fmt.Printf("Replace all scrobbles in %s with %d scrobbles? [y/N] ", sink.Name(), len(scrobbles))
+
+		input := bufio.NewScanner(os.Stdin)
+		input.Scan()
+
+		if strings.ToLower(strings.TrimSpace(input.Text())) != "y" {
+			return errors.New("aborted")
+		}
+	}
+
+	if err := editable.ReplaceScrobbles(scrobbles); err != nil {
+		return fmt.Errorf("error rebuilding sink: %s", err.Error())
+	}
+
+	fmt.Printf("Rebuilt %s with %d scrobbles\n", sink.Name(), len(scrobbles))
+	return nil
+}
+
+func ActionStats(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sink, err := FindSink(config.SetupSinks(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("resurface") {
+		return printResurfaced(sink, config.SetupCache(), cmd.Duration("not-played-for"), cmd.Int("min-plays"), cmd.Int("limit"), cmd.Bool("accessible"))
+	}
+	if !cmd.Bool("skips") {
+		return printSummary(sink, cmd.Timestamp("from"), cmd.Timestamp("to"), cmd.Bool("accessible"))
+	}
+
+	recorder, ok := UnwrapSink(sink).(PlayRecorder)
+	if !ok {
+		return fmt.Errorf("sink %s does not record plays", sink.Name())
+	}
+
+	plays, err := recorder.Plays()
+	if err != nil {
+		return fmt.Errorf("error reading plays: %s", err.Error())
+	}
+
+	stats := SkipStatsByArtist(plays)
+	if limit := cmd.Int("limit"); limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+
+	tbl := NewTable(cmd.Bool("accessible"), "ARTIST", "PLAYS", "COMPLETED", "SKIPPED", "ABANDONED", "SKIP RATE")
+	for _, s := range stats {
+		tbl.AddRow(s.Artist, s.Plays, s.Completed, s.Skipped, s.Abandoned, fmt.Sprintf("%.0f%%", s.SkipRate()*100))
+	}
+	tbl.Print()
+
+	return nil
+}
+
+func printSummary(sink Sink, from, to time.Time, accessible bool) error {
+	scrobbles, err := sink.GetScrobbles(0, from, to)
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+
+	summary := SummarizeScrobbles(scrobbles, time.Local)
+
+	tbl := NewTable(accessible, "SCROBBLES", "ARTISTS", "ALBUMS", "TRACKS")
+	tbl.AddRow(summary.Scrobbles, summary.Artists, summary.Albums, summary.Tracks)
+	tbl.Print()
+
+	if len(summary.Days) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	days := NewTable(accessible, "DAY", "SCROBBLES")
+	for _, day := range summary.Days {
+		days.AddRow(day.Day.Format(time.DateOnly), day.Scrobbles)
+	}
+	days.Print()
+
+	return nil
+}
+
+func printResurfaced(sink Sink, cache *Cache, age time.Duration, minPlays, limit int, accessible bool) error {
+	now := time.Now()
+
+	scrobbles, err := CachedScrobbles(cache, sink, time.Time{}, now)
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+
+	resurfaced := Resurface(scrobbles, now, age, minPlays)
+	if limit > 0 && len(resurfaced) > limit {
+		resurfaced = resurfaced[:limit]
+	}
+
+	tbl := NewTable(accessible, "ARTIST", "SCROBBLES", "LAST PLAYED")
+	for _, r := range resurfaced {
+		tbl.AddRow(r.Artist, r.Plays, r.LastPlayed.Format(time.DateOnly))
+	}
+	tbl.Print()
+
+	return nil
+}
+
+func ActionImportLastFm(ctx context.Context, cmd *cli.Command) error {
+	return importFile(ctx, cmd, ParseLastFmExport)
+}
+
+func ActionImportListenBrainz(ctx context.Context, cmd *cli.Command) error {
+	return importFile(ctx, cmd, ParseListenBrainzExport)
+}
+
+func ActionImportScrobblerLog(ctx context.Context, cmd *cli.Command) error {
+	return importFile(ctx, cmd, ParseScrobblerLog)
+}
+
+func importFile(ctx context.Context, cmd *cli.Command, parse ImportParser) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sink, err := FindImportSink(config.SetupSinks(), cmd.String("sink"))
+	if err != nil {
+		return err
+	}
+
+	filename := cmd.StringArg("file")
+	if filename == "" {
+		return errors.New("no file provided")
+	}
+
+	//nolint:gosec
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading file: %s", err.Error())
+	}
+
+	scrobbles, err := parse(data)
+	if err != nil {
+		return err
+	}
+
+	added, err := ImportScrobbles(sink, scrobbles)
+	if err != nil {
+		return fmt.Errorf("error importing scrobbles: %s", err.Error())
+	}
+
+	fmt.Printf("Imported %d of %d scrobbles into %s\n", added, len(scrobbles), sink.Name())
+	return nil
+}
+
+func ActionCacheClear(_ context.Context, _ *cli.Command) error {
+	removed, err := ClearCache(CacheDirname())
+	if err != nil {
+		return fmt.Errorf("error clearing cache: %s", err.Error())
+	}
+
+	fmt.Printf("Removed %d cache entries\n", removed)
+	return nil
+}
+
+func ActionDedupe(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sink, err := FindSink(config.SetupSinks(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	editable, ok := UnwrapSink(sink).(EditableSink)
+	if !ok {
+		return fmt.Errorf("sink %s cannot be edited", sink.Name())
+	}
+
+	scrobbles, err := sink.GetScrobbles(0, time.Time{}, time.Now())
+	if err != nil {
+		return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+	}
+
+	kept, duplicates := FindDuplicates(scrobbles, cmd.Duration("window"))
+	if len(duplicates) == 0 {
+		fmt.Printf("%s has no duplicate scrobbles\n", sink.Name())
+		return nil
+	}
+
+	tbl := NewTable(cmd.Bool("accessible"), "ARTISTS", "TRACK", "ALBUM", "DURATION", "TIMESTAMP")
+	for _, s := range duplicates {
+		tbl.AddRow(s.JoinArtists(), s.Track, s.Album, s.PrettyDuration(), s.Timestamp.Format(time.RFC1123))
+	}
+	tbl.Print()
+
+	if cmd.Bool("dry-run") {
+		return nil
+	}
+
+	if !cmd.Bool("yes") {
+		fmt.Printf("Remove %d duplicate scrobbles from %s? [y/N] ", len(duplicates), sink.Name())
+
+		input := bufio.NewScanner(os.Stdin)
+		input.Scan()
+
+		if strings.ToLower(strings.TrimSpace(input.Text())) != "y" {
+			return errors.New("aborted")
+		}
+	}
+
+	if err := editable.ReplaceScrobbles(kept); err != nil {
+		return fmt.Errorf("error removing duplicates: %s", err.Error())
+	}
+
+	fmt.Printf("Removed %d duplicate scrobbles from %s\n", len(duplicates), sink.Name())
+	return nil
+}
+
+func ActionPurge(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	sink, err := FindSink(config.SetupSinks(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	tombstoneSink, ok := UnwrapSink(sink).(TombstoneSink)
+	if !ok {
+		return fmt.Errorf("sink %s does not store tombstones", sink.Name())
+	}
+
+	purged, err := tombstoneSink.PurgeTombstones(time.Now().Add(-cmd.Duration("older-than")))
+	if err != nil {
+		return fmt.Errorf("error purging tombstones: %s", err.Error())
+	}
+
+	fmt.Printf("Removed %d tombstones from %s\n", purged, sink.Name())
+	return nil
+}
+
+func ActionReplay(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	filename := cmd.StringArg("file")
+	if filename == "" {
+		return errors.New("no recording provided")
+	}
+
+	//nolint:gosec
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading recording: %s", err.Error())
+	}
+
+	frames, err := ParseReplayFrames(string(data))
+	if err != nil {
+		return fmt.Errorf("error parsing recording: %s", err.Error())
+	}
+
+	// pauses insert frames, so they are applied last and from back to front to
+	// keep all frame numbers relative to the original recording
+	var events []ReplayEvent
+	for _, eventType := range []ReplayEventType{ReplaySeek, ReplayFlap, ReplayCrash, ReplayPause} {
+		var typeEvents []ReplayEvent
+		for _, input := range cmd.StringSlice(string(eventType)) {
+			event, err := ParseReplayEvent(eventType, input)
+			if err != nil {
+				return err
+			}
+			typeEvents = append(typeEvents, event)
+		}
+		slices.SortStableFunc(typeEvents, func(a, b ReplayEvent) int {
+			return b.Frame - a.Frame
+		})
+		events = append(events, typeEvents...)
+	}
+
+	for _, event := range events {
+		if frames, err = InjectReplayEvent(frames, event); err != nil {
+			return err
+		}
+	}
+
+	result := RunReplay(frames, config.LoopOptions())
+
+	for _, scrobble := range result.ScrobbleLog {
+		fmt.Printf("scrobbled: %s %c %s\n", scrobble.JoinArtists(), RuneEmDash, scrobble.Track)
+	}
+	fmt.Printf("%d frames, %d now playing updates, %d scrobbles\n",
+		len(frames), len(result.NowPlayingLog), len(result.ScrobbleLog))
+
+	return nil
+}
+
+func ActionSoak(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config).SoakConfig(cmd.Bool("submit"))
+
+	sinks := config.SetupSinks()
+	if len(sinks) == 0 {
+		return errors.New("no sinks configured")
+	}
+
+	report := RunSoak(sinks, config.LoopOptions(), cmd.Duration("duration"), cmd.Duration("interval"))
+
+	tbl := NewTable(cmd.Bool("accessible"), "SINK", "REQUESTS", "ERRORS", "ERROR RATE", "P50", "P95", "P99", "MAX")
+	for _, s := range report.Sinks {
+		tbl.AddRow(
+			s.Sink,
+			s.Requests,
+			s.Errors,
+			fmt.Sprintf("%.1f%%", s.ErrorRate()*100),
+			s.P50.Round(time.Microsecond),
+			s.P95.Round(time.Microsecond),
+			s.P99.Round(time.Microsecond),
+			s.Max.Round(time.Microsecond),
+		)
+	}
+	tbl.Print()
+
+	fmt.Printf("%d iterations, %d tracks in %s\n", report.Iterations, report.Tracks, report.Duration.Round(time.Second))
+	fmt.Printf("heap: %d KiB at start, %d KiB at end (%+d KiB), %d KiB peak\n",
+		report.HeapStart/1024, report.HeapEnd/1024, report.HeapGrowth()/1024, report.HeapPeak/1024)
+
+	for _, s := range report.Sinks {
+		if s.Errors > 0 {
+			return errors.New("soak test finished with errors")
+		}
+	}
+	return nil
+}
+
+func ActionVerifyArchive(ctx context.Context, cmd *cli.Command) error {
+	filename := cmd.String("file")
+	if filename == "" {
+		config := ctx.Value(ContextConfigKey).(Config)
+
+		sink, err := FindSink(config.SetupSinks(), cmd.StringArg("sink"))
+		if err != nil {
+			return err
+		}
+
+		csvSink, ok := UnwrapSink(sink).(CSVSink)
+		if !ok {
+			return fmt.Errorf("sink %s is not a local archive", sink.Name())
+		}
+		filename = csvSink.Filename
+	}
+
+	publicKeyFilename := cmd.String("public-key")
+	if publicKeyFilename == "" {
+		publicKeyFilename = PublicKeyFilename()
+	}
+
+	publicKey, err := LoadPublicKey(publicKeyFilename)
+	if err != nil {
+		return fmt.Errorf("error reading public key: %s", err.Error())
+	}
+
+	result, err := VerifyArchive(filename, publicKey)
+	if err != nil {
+		return fmt.Errorf("error reading archive: %s", err.Error())
+	}
+
+	fmt.Printf("%d valid, %d unsigned, %d invalid\n", result.Valid, result.Unsigned, len(result.Invalid))
+	for _, line := range result.Invalid {
+		fmt.Printf("invalid signature on line %d\n", line)
+	}
+
+	if len(result.Invalid) > 0 {
+		return errors.New("archive contains invalid signatures")
+	}
+	return nil
+}
+
+var importSinkFlag = &cli.StringFlag{
+	Name:  "sink",
+	Usage: "import into this local sink (required if there are multiple local sinks)",
+}
+
+var nativeServiceFlag = &cli.BoolFlag{
+	Name:  "native",
+	Usage: "use a native Windows service instead of a logon task (requires administrator rights)",
+}
+
+func serviceDefinition(cmd *cli.Command) (ServiceDefinition, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return ServiceDefinition{}, err
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return ServiceDefinition{}, err
 	}
 
-	cmd.Before = func(ctx context.Context, _ *cli.Command) (context.Context, error) {
-		SetupLogger(cmd)
+	config, err := filepath.Abs(ConfigFilename(cmd))
+	if err != nil {
+		return ServiceDefinition{}, err
+	}
 
-		filename := ConfigFilename(cmd)
-		config, err := ReadConfig(filename)
-		if err != nil {
-			return ctx, fmt.Errorf("cannot read config file: %s", err.Error())
+	if cmd.Bool("native") {
+		if runtime.GOOS != "windows" {
+			return ServiceDefinition{}, errors.New("native services are only supported on Windows")
 		}
+		return NewWindowsServiceDefinition(executable, config), nil
+	}
 
-		ctx = context.WithValue(ctx, ContextConfigKey, config)
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ServiceDefinition{}, err
+	}
 
-		return ctx, nil
+	return NewServiceDefinition(runtime.GOOS, executable, config, home)
+}
+
+func ActionServiceInstall(_ context.Context, cmd *cli.Command) error {
+	definition, err := serviceDefinition(cmd)
+	if err != nil {
+		return err
+	}
+	return definition.InstallService()
+}
+
+func ActionServiceUninstall(_ context.Context, cmd *cli.Command) error {
+	definition, err := serviceDefinition(cmd)
+	if err != nil {
+		return err
 	}
+	return definition.UninstallService()
+}
 
-	if err := cmd.Run(context.Background(), os.Args); err != nil {
-		fmt.Println("Error:", err.Error())
+func ActionServiceStatus(_ context.Context, cmd *cli.Command) error {
+	definition, err := serviceDefinition(cmd)
+	if err != nil {
+		return err
+	}
+	return definition.PrintStatus()
+}
+
+func ActionCtlGuest(_ context.Context, cmd *cli.Command) error {
+	arguments := []string{cmd.StringArg("state")}
+	switch {
+	case arguments[0] != "on" && arguments[0] != "off":
+		return errors.New("guest mode must be turned `on` or `off`")
+	case arguments[0] == "on" && cmd.Duration("for") > 0:
+		arguments = append(arguments, cmd.Duration("for").String())
+	}
+
+	return sendDaemonCommand("guest", arguments...)
+}
+
+func ActionCtlPause(_ context.Context, cmd *cli.Command) error {
+	var arguments []string
+	if cmd.Duration("for") > 0 {
+		arguments = append(arguments, cmd.Duration("for").String())
 	}
+
+	return sendDaemonCommand("pause", arguments...)
+}
+
+func ActionCtlResume(_ context.Context, _ *cli.Command) error {
+	return sendDaemonCommand("resume")
 }
 
-func ActionRun(ctx context.Context, _ *cli.Command) error {
+func ActionQueueList(ctx context.Context, cmd *cli.Command) error {
 	config := ctx.Value(ContextConfigKey).(Config)
 
-	RunMainLoop(config)
+	sinks, err := queuedSinks(config, cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	queued, err := QueuedScrobbles(sinks)
+	if err != nil {
+		return fmt.Errorf("error reading queue: %s", err.Error())
+	}
+	if len(queued) == 0 {
+		fmt.Println("No scrobbles queued")
+		return nil
+	}
+
+	tbl := NewTable(cmd.Bool("accessible"), "SINK", "ARTISTS", "TRACK", "ALBUM", "TIMESTAMP")
+	for _, sink := range slices.Sorted(maps.Keys(queued)) {
+		for _, s := range queued[sink] {
+			tbl.AddRow(sink, s.JoinArtists(), s.Track, s.Album, s.Timestamp.Format(time.RFC1123))
+		}
+	}
+	tbl.Print()
 
 	return nil
 }
 
-func ActionScrobbles(ctx context.Context, cmd *cli.Command) error {
-	limit := cmd.Int("limit")
-	from := cmd.Timestamp("from")
-	to := cmd.Timestamp("to")
+func ActionQueueFlush(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
 
-	sinkName := cmd.StringArg("sink")
+	sinks, err := queuedSinks(config, cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	// the running daemon submits the scrobbles, so they share its rate limits
+	if _, ok := QueryDaemonStatus(); ok {
+		var arguments []string
+		if cmd.StringArg("sink") != "" {
+			arguments = append(arguments, sinks[0].Name())
+		}
+		return sendDaemonCommand("flush-queue", arguments...)
+	}
+
+	remaining, err := FlushQueues(sinks)
+	if err != nil {
+		return fmt.Errorf("error submitting queued scrobbles: %s", err.Error())
+	}
+
+	fmt.Printf("%d scrobbles still queued\n", remaining)
+	return nil
+}
 
+func ActionQueueClear(ctx context.Context, cmd *cli.Command) error {
 	config := ctx.Value(ContextConfigKey).(Config)
 
-	if sinkName == "" {
-		return errors.New("no sink provided (run `goscrobble list-sinks` to list all configured sinks)")
+	sinks, err := queuedSinks(config, cmd.StringArg("sink"))
+	if err != nil {
+		return err
 	}
 
-	var sink Sink
-	for _, s := range config.SetupSinks() {
-		if s.Name() == sinkName {
-			sink = s
-			break
+	before := time.Now().Add(-cmd.Duration("older-than"))
+
+	if !cmd.Bool("yes") {
+		fmt.Printf("Remove queued scrobbles from before %s without submitting them? [y/N] ", before.Format(time.RFC1123))
+
+		input := bufio.NewScanner(os.Stdin)
+		input.Scan()
+
+		if strings.ToLower(strings.TrimSpace(input.Text())) != "y" {
+			return errors.New("aborted")
 		}
 	}
 
-	if sink == nil {
-		return errors.New("invalid sink name (run `goscrobble list-sinks` to list all configured sinks)")
+	removed, err := ClearQueues(sinks, before)
+	if err != nil {
+		return fmt.Errorf("error clearing queue: %s", err.Error())
 	}
 
-	scrobbles, err := sink.GetScrobbles(limit, from, to)
+	fmt.Printf("Removed %d queued scrobbles\n", removed)
+	return nil
+}
+
+// queuedSinks returns the sinks with an offline queue, or only the given one.
+func queuedSinks(config Config, sinkName string) ([]Sink, error) {
+	if !config.OfflineQueue {
+		return nil, errors.New("the offline queue is disabled (set offline_queue = true in the config file)")
+	}
+
+	sinks := config.SetupSinks()
+	if sinkName != "" {
+		sink, err := FindSink(sinks, sinkName)
+		if err != nil {
+			return nil, err
+		}
+		sinks = []Sink{sink}
+	}
+
+	var queued []Sink
+	for _, sink := range sinks {
+		if _, ok := sink.(QueuedSink); ok {
+			queued = append(queued, sink)
+		}
+	}
+	if len(queued) == 0 {
+		return nil, errors.New("no sink uses the offline queue")
+	}
+	return queued, nil
+}
+
+// sendDaemonCommand sends a command to the running daemon and prints its
+// response.
+func sendDaemonCommand(command string, arguments ...string) error {
+	response, err := SendControlRequest(ControlSocketFilename(), ControlRequest{Command: command, Arguments: arguments, Scrobble: nil})
+	if response.Error != "" {
+		return errors.New(response.Error)
+	} else if err != nil {
+		return fmt.Errorf("cannot send command to daemon (is `goscrobble run` active?): %s", err.Error())
+	}
+
+	fmt.Println(response.Message)
+	return nil
+}
+
+func ActionHealth(ctx context.Context, _ *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	if config.API == nil {
+		return errors.New("the HTTP API is not configured (add an [api] table to the config file)")
+	}
+
+	report, err := QueryHealth(config.API.Address)
 	if err != nil {
-		return fmt.Errorf("error fetching scrobbles: %s", err.Error())
+		return fmt.Errorf("cannot query daemon health: %s", err.Error())
 	}
 
-	tbl := table.New("ARTISTS", "TRACK", "ALBUM", "DURATION", "TIMESTAMP")
-	for _, s := range scrobbles {
-		tbl.AddRow(s.JoinArtists(), s.Track, s.Album, s.PrettyDuration(), s.Timestamp.Format(time.RFC1123))
+	fmt.Println(report.Summary(time.Now()))
+
+	if !report.Healthy {
+		return errors.New("daemon is unhealthy")
+	}
+	return nil
+}
+
+func ActionCheckConfig(_ context.Context, cmd *cli.Command) error {
+	filename := ConfigFilename(cmd)
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		if _, err := ReadConfig(filename); err != nil {
+			return fmt.Errorf("cannot create config file: %s", err.Error())
+		}
+	}
+
+	findings := CheckConfigFile(filename, cmd.Bool("strict"), KeyringAvailable())
+
+	errs := 0
+	for _, finding := range findings {
+		if finding.Severity == FindingError {
+			errs++
+		}
+	}
+
+	if cmd.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		result := struct {
+			Valid    bool            `json:"valid"`
+			Findings []ConfigFinding `json:"findings"`
+		}{
+			Valid:    errs == 0,
+			Findings: findings,
+		}
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		if errs == 0 {
+			fmt.Println("Configuration is valid")
+		}
+		for _, finding := range findings {
+			fmt.Println(finding.String())
+		}
+	}
+
+	if errs > 0 {
+		return cli.Exit("configuration is invalid", 1)
+	}
+	return nil
+}
+
+func ActionConfigShow(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	fmt.Printf("# effective configuration of %s\n", ConfigFilename(cmd))
+	return config.Redacted().Encode(os.Stdout)
+}
+
+func ActionConfigMigrate(_ context.Context, cmd *cli.Command) error {
+	filename := ConfigFilename(cmd)
+	backup := ConfigBackupFilename(filename, time.Now())
+	dryRun := cmd.Bool("dry-run")
+
+	applied, migrated, err := MigrateConfigFile(filename, backup, dryRun)
+	if err != nil {
+		return fmt.Errorf("cannot migrate config file: %s", err.Error())
+	}
+	if len(applied) == 0 {
+		fmt.Printf("%s is up to date\n", filename)
+		return nil
+	}
+
+	for _, description := range applied {
+		fmt.Println("- " + description)
+	}
+	if dryRun {
+		fmt.Printf("\n# migrated configuration of %s\n", filename)
+		_, _ = os.Stdout.Write(migrated)
+		return nil
+	}
+
+	fmt.Printf("migrated %s, the original was saved to %s\n", filename, backup)
+	return nil
+}
+
+func ActionInit(_ context.Context, cmd *cli.Command) error {
+	filename := ConfigFilename(cmd)
+	wizard := NewInitWizard(os.Stdin, os.Stdout)
+
+	if _, err := os.Stat(filename); err == nil {
+		if !wizard.Confirm(fmt.Sprintf("%s already exists, overwrite it?", filename), false) {
+			return errors.New("aborted by user")
+		}
 	}
-	tbl.Print()
 
+	config, err := wizard.Run(runtime.GOOS)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := config.Sinks.LastFm["default"]; ok && wizard.Confirm("Authenticate last.fm now?", true) {
+		if _, err := (LastFmAuthenticator{Key: "default"}).Login(&config, LoginOptions{NoBrowser: false, NoKeyring: false}); err != nil {
+			fmt.Println("Error authenticating last.fm:", err.Error())
+			fmt.Println("Run `goscrobble auth login last.fm:default` to try again")
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	if err := config.WriteCommented(filename); err != nil {
+		return fmt.Errorf("cannot write config file: %s", err.Error())
+	}
+
+	fmt.Println("Wrote", filename)
+	fmt.Println("Run `goscrobble doctor` to check the setup, and `goscrobble run` to start scrobbling")
 	return nil
 }
 
-func ActionCheckConfig(ctx context.Context, _ *cli.Command) error {
-	_ = ctx.Value(ContextConfigKey).(Config)
+func ActionDoctor(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	checks := config.Doctor(time.Now())
+
+	tbl := NewTable(cmd.Bool("accessible"), "CHECK", "STATUS", "RESULT")
+	for _, check := range checks {
+		tbl.AddRow(check.Name, string(check.Status), check.Message)
+	}
+	tbl.Print()
+
+	failed := 0
+	printed := false
+	for _, check := range checks {
+		if check.Status == DoctorError {
+			failed++
+		}
+		if check.Hint == "" {
+			continue
+		}
+		if !printed {
+			fmt.Println()
+			printed = true
+		}
+		fmt.Printf("%s: %s\n", check.Name, check.Hint)
+	}
 
-	fmt.Println("Configuration is valid")
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
 	return nil
 }
 
@@ -184,6 +2734,8 @@ func ActionListSources(ctx context.Context, _ *cli.Command) error {
 		fmt.Println(sink.Name())
 	}
 
+	PrintDaemonSummary()
+
 	return nil
 }
 
@@ -194,71 +2746,218 @@ func ActionListSinks(ctx context.Context, _ *cli.Command) error {
 		fmt.Println(sink.Name())
 	}
 
+	PrintDaemonSummary()
+
 	return nil
 }
 
-func ActionLastFmAuth(ctx context.Context, cmd *cli.Command) error {
-	key := cmd.StringArg("key")
+// PrintDaemonSummary prints runtime information if a daemon is running.
+func PrintDaemonSummary() {
+	if status, ok := QueryDaemonStatus(); ok {
+		fmt.Println()
+		fmt.Println(status.Summary(time.Now()))
+	}
+}
 
+func ActionAuthList(ctx context.Context, cmd *cli.Command) error {
 	config := ctx.Value(ContextConfigKey).(Config)
 
-	if len(config.Sinks.LastFm) == 0 {
-		return errors.New("no last.fm sink is configured")
-	} else if len(config.Sinks.LastFm) > 1 && key == "" {
-		return errors.New("must specify a key when more than one last.fm sink is configured")
-	} else if _, ok := config.Sinks.LastFm[key]; !ok {
-		return errors.New("no last.fm sink with this key exists")
+	authenticators := config.Authenticators()
+	if len(authenticators) == 0 {
+		fmt.Println("No sinks or sources require authentication")
+		return nil
+	}
+
+	now := time.Now()
+
+	tbl := NewTable(cmd.Bool("accessible"), "NAME", "ACCOUNT", "STATUS", "EXPIRES", "SCOPES")
+	for _, authenticator := range authenticators {
+		status := authenticator.Status(config)
+		tbl.AddRow(authenticator.Name(), status.Account, status.State(now), status.PrettyExpires(), status.PrettyScopes())
+	}
+	tbl.Print()
+
+	return nil
+}
+
+func ActionAuthLogin(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	authenticator, err := FindAuthenticator(config.Authenticators(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	return authLogin(config, ConfigFilename(cmd), authenticator, LoginOptions{NoBrowser: cmd.Bool("no-browser"), NoKeyring: cmd.Bool("no-keyring")})
+}
+
+func ActionAuthLogout(ctx context.Context, cmd *cli.Command) error {
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	authenticator, err := FindAuthenticator(config.Authenticators(), cmd.StringArg("sink"))
+	if err != nil {
+		return err
+	}
+
+	changed, err := authenticator.Logout(&config)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		if err := config.Write(ConfigFilename(cmd)); err != nil {
+			return fmt.Errorf("cannnot write updated config file: %s", err.Error())
+		}
+	}
+
+	fmt.Println("Removed credentials of", authenticator.Name())
+	return nil
+}
+
+func ActionKeyringList(_ context.Context, cmd *cli.Command) error {
+	config, err := readKeyringConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	secrets := config.Secrets()
+	if len(secrets) == 0 {
+		fmt.Println("No secrets in the config file")
+		return nil
 	}
 
-	lastFmConfig := config.Sinks.LastFm[key]
+	tbl := NewTable(cmd.Bool("accessible"), "NAME", "STORAGE", "ACCOUNT")
+	for _, secret := range secrets {
+		tbl.AddRow(secret.Name, string(secret.Storage), secret.Account)
+	}
+	tbl.Print()
 
-	if lastFmConfig.SessionKey != "" && lastFmConfig.Username != "" {
-		return errors.New("last.fm is already authenticated")
+	if !KeyringAvailable() {
+		fmt.Println()
+		fmt.Println("No system keyring available")
 	}
 
-	client, err := lastfm.NewDesktopClient(lastfm.BaseURL, lastFmConfig.Key, lastFmConfig.Secret)
+	return nil
+}
+
+func ActionKeyringSet(_ context.Context, cmd *cli.Command) error {
+	config, err := readKeyringConfig(cmd)
 	if err != nil {
-		return fmt.Errorf("cannot set up last.fm client: %s", err.Error())
+		return err
 	}
 
-	token, err := client.AuthGetToken()
+	secret, err := config.FindSecret(cmd.StringArg("secret"))
 	if err != nil {
-		return fmt.Errorf("cannot get authorization token: %s", err.Error())
+		return err
 	}
+	account := cmp.Or(secret.Account, secret.Name)
 
-	fmt.Println("Warning: authenticating last.fm will rewrite your config file and remove all comments!")
+	value, err := readSecret(fmt.Sprintf("Enter %s: ", secret.Name))
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return errors.New("empty secret")
+	}
 
-	authURL := client.DesktopAuthorizationURL(token.Token)
-	if err := OpenURL(authURL); err != nil {
-		fmt.Println("Error opening URL in default browser:", err.Error())
+	if err := KeyringSet(account, value); err != nil {
+		return fmt.Errorf("error saving %s in the system keyring: %s", secret.Name, err.Error())
 	}
+	fmt.Printf("Saved %s in the system keyring\n", secret.Name)
 
-	fmt.Println("Please open the following URL in your browser and authorize the application:", authURL)
-	fmt.Print("Finished authorization? [Y/n] ")
+	switch secret.Storage {
+	case SecretCommand:
+		fmt.Printf("Remove %s_cmd and set %s to %q in the config file to use it\n", secret.Name, secret.Name, KeyringPrefix)
+	case SecretUnset, SecretPlaintext:
+		fmt.Printf("Set %s to %q in the config file to use it\n", secret.Name, KeyringPrefix)
+	}
 
-	input := bufio.NewScanner(os.Stdin)
-	input.Scan()
+	return nil
+}
 
-	response := strings.ToLower(strings.TrimSpace(input.Text()))
-	if response != "y" && response != "" {
-		return errors.New("invalid input")
+func ActionKeyringDelete(_ context.Context, cmd *cli.Command) error {
+	config, err := readKeyringConfig(cmd)
+	if err != nil {
+		return err
 	}
 
-	session, err := client.AuthGetSession(token.Token)
+	secret, err := config.FindSecret(cmd.StringArg("secret"))
 	if err != nil {
-		return fmt.Errorf("cannot fetch session key from last.fm API: %s", err.Error())
+		return err
 	}
 
-	fmt.Println("Logged in with user:", session.Session.Name)
+	if err := KeyringDelete(cmp.Or(secret.Account, secret.Name)); err != nil {
+		return fmt.Errorf("error removing %s from the system keyring: %s", secret.Name, err.Error())
+	}
+	fmt.Printf("Removed %s from the system keyring\n", secret.Name)
 
-	lastFmConfig.SessionKey = session.Session.Key
-	lastFmConfig.Username = session.Session.Name
-	config.Sinks.LastFm[key] = lastFmConfig
+	if secret.Storage == SecretKeyring {
+		fmt.Printf("Replace %s in the config file, it still references the system keyring\n", secret.Name)
+	}
 
-	filename := ConfigFilename(cmd)
+	return nil
+}
+
+// readKeyringConfig reads the config file and its drop-ins without resolving
+// secrets, so secrets can be managed while they are missing from the system
+// keyring.
+func readKeyringConfig(cmd *cli.Command) (Config, error) {
+	var config Config
+	if _, err := DecodeConfigFile(ConfigFilename(cmd), &config); err != nil {
+		return Config{}, fmt.Errorf("cannot read config file: %s", err.Error())
+	}
+	return config, nil
+}
+
+// readSecret reads a line from standard input, without echoing it if standard
+// input is a terminal.
+func readSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		input := bufio.NewScanner(os.Stdin)
+		input.Scan()
+		return strings.TrimSpace(input.Text()), input.Err()
+	}
+
+	fmt.Print(prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+func ActionLastFmAuth(ctx context.Context, cmd *cli.Command) error {
+	fmt.Println("Warning: `lastfm-auth` is deprecated, use `goscrobble auth <sink>` instead")
+
+	key := cmd.StringArg("key")
+
+	config := ctx.Value(ContextConfigKey).(Config)
+
+	if len(config.Sinks.LastFm) == 0 {
+		return errors.New("no last.fm sink is configured")
+	} else if len(config.Sinks.LastFm) > 1 && key == "" {
+		return errors.New("must specify a key when more than one last.fm sink is configured")
+	} else if _, ok := config.Sinks.LastFm[key]; !ok {
+		return errors.New("no last.fm sink with this key exists")
+	}
+
+	return authLogin(config, ConfigFilename(cmd), LastFmAuthenticator{Key: key}, LoginOptions{NoBrowser: cmd.Bool("no-browser"), NoKeyring: cmd.Bool("no-keyring")})
+}
+
+func authLogin(config Config, filename string, authenticator Authenticator, options LoginOptions) error {
+	fmt.Println("Warning: authenticating will rewrite your config file and remove all comments!")
 
-	if err := config.Write(filename); err != nil {
-		return fmt.Errorf("cannnot write updated config file: %s", err.Error())
+	changed, err := authenticator.Login(&config, options)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		if err := config.Write(filename); err != nil {
+			return fmt.Errorf("cannnot write updated config file: %s", err.Error())
+		}
 	}
 
 	return nil
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// ManualScrobble prepares a scrobble entered using `goscrobble scrobble`,
+// e.g. for a vinyl record. The regexes of the config are applied like for
+// tracks detected by sources, and the timestamp defaults to now. Timestamps in
+// the future are rejected instead of being replaced.
+func (c Config) ManualScrobble(scrobble Scrobble, now time.Time) (Scrobble, error) {
+	if len(scrobble.Artists) == 0 || scrobble.Track == "" {
+		return Scrobble{}, errors.New("artist and track are required")
+	}
+
+	if scrobble.Timestamp.IsZero() {
+		scrobble.Timestamp = now
+	} else if scrobble.Timestamp.After(now.Add(maxFutureTimestamp)) {
+		return Scrobble{}, errors.New("timestamp is in the future")
+	}
+	scrobble.Timestamp = CheckTimestamp(scrobble.Timestamp, now)
+
+	scrobble.RegexReplace(c.ParseRegexes())
+	return scrobble, nil
+}
@@ -0,0 +1,43 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManualScrobble(t *testing.T) {
+	now := time.Unix(1699225080, 0)
+
+	config := main.DefaultConfig
+	config.Regexes = []main.RegexReplace{{Match: ` \(Remastered\)$`, Replace: "", Artist: false, Track: true, Album: false}}
+
+	scrobble, err := config.ManualScrobble(main.Scrobble{
+		Artists: []string{"Placebo"},
+		Track:   "Pure Morning (Remastered)",
+	}, now)
+	require.NoError(t, err)
+	require.Equal(t, "Pure Morning", scrobble.Track)
+	require.Equal(t, now, scrobble.Timestamp)
+
+	played := now.Add(-time.Hour)
+	scrobble, err = config.ManualScrobble(main.Scrobble{
+		Artists:   []string{"Placebo"},
+		Track:     "Pure Morning",
+		Timestamp: played,
+	}, now)
+	require.NoError(t, err)
+	require.Equal(t, played, scrobble.Timestamp)
+
+	_, err = config.ManualScrobble(main.Scrobble{
+		Artists:   []string{"Placebo"},
+		Track:     "Pure Morning",
+		Timestamp: now.Add(time.Hour),
+	}, now)
+	require.Error(t, err)
+
+	_, err = config.ManualScrobble(main.Scrobble{Track: "Pure Morning"}, now)
+	require.Error(t, err)
+}
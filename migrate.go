@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ConfigMigration upgrades an older config file layout. Apply changes the
+// decoded config file in place and reports whether it changed anything.
+type ConfigMigration struct {
+	Description string
+	Apply       func(config map[string]any) bool
+}
+
+// ConfigMigrations are applied in order by `goscrobble config migrate`.
+var ConfigMigrations = []ConfigMigration{
+	{
+		Description: "moved the settings of [sinks.lastfm] to [sinks.lastfm.default]",
+		Apply:       keySinkTable("lastfm"),
+	},
+	{
+		Description: "moved the settings of [sinks.csv] to [sinks.csv.default]",
+		Apply:       keySinkTable("csv"),
+	},
+	{
+		Description: "converted the player names of `blacklist` to [[blacklist]] rules",
+		Apply:       structuredBlacklist,
+	},
+}
+
+// structuredBlacklist converts the player blacklist, which used to be a list of
+// regular expressions matched against player names, to filter rules for the
+// player field.
+func structuredBlacklist(config map[string]any) bool {
+	entries, ok := config["blacklist"].([]any)
+	if !ok {
+		return false
+	}
+
+	changed := false
+	for i, entry := range entries {
+		if match, ok := entry.(string); ok {
+			entries[i] = map[string]any{"match": match, "player": true}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// keySinkTable returns a migration for sinks that were configured as a single
+// table before sinks of the same type were keyed by name. Settings directly
+// in the table are moved to the `default` sink, both for the top-level sinks
+// and those of user profiles.
+func keySinkTable(sinkType string) func(map[string]any) bool {
+	migrate := func(sinks map[string]any) bool {
+		table, ok := sinks[sinkType].(map[string]any)
+		if !ok {
+			return false
+		}
+
+		defaultSink, ok := table["default"].(map[string]any)
+		if !ok {
+			defaultSink = map[string]any{}
+		}
+
+		changed := false
+		for key, value := range table {
+			if _, ok := value.(map[string]any); ok {
+				continue
+			}
+			if _, exists := defaultSink[key]; !exists {
+				defaultSink[key] = value
+			}
+			delete(table, key)
+			changed = true
+		}
+		if changed {
+			table["default"] = defaultSink
+		}
+		return changed
+	}
+
+	return func(config map[string]any) bool {
+		changed := false
+		if sinks, ok := config["sinks"].(map[string]any); ok {
+			changed = migrate(sinks) || changed
+		}
+
+		users, _ := config["users"].(map[string]any)
+		for _, user := range users {
+			if user, ok := user.(map[string]any); ok {
+				if sinks, ok := user["sinks"].(map[string]any); ok {
+					changed = migrate(sinks) || changed
+				}
+			}
+		}
+		return changed
+	}
+}
+
+// MigrateConfig applies all migrations to the decoded config file and returns
+// the descriptions of those that changed it.
+func MigrateConfig(config map[string]any) []string {
+	var applied []string
+	for _, migration := range ConfigMigrations {
+		if migration.Apply(config) {
+			applied = append(applied, migration.Description)
+		}
+	}
+	return applied
+}
+
+// MigrateConfigFile upgrades a config file to the current layout and returns
+// the applied migrations and the migrated file. Unless dryRun is set, the
+// original file is copied to backup first. Comments are not preserved.
+func MigrateConfigFile(filename, backup string, dryRun bool) ([]string, []byte, error) {
+	//nolint:gosec
+	original, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := map[string]any{}
+	if err := toml.Unmarshal(original, &config); err != nil {
+		return nil, nil, err
+	}
+
+	applied := MigrateConfig(config)
+	if len(applied) == 0 {
+		return nil, nil, nil
+	}
+
+	var migrated bytes.Buffer
+	encoder := toml.NewEncoder(&migrated)
+	encoder.Indent = ""
+	if err := encoder.Encode(config); err != nil {
+		return nil, nil, err
+	}
+
+	// the migrated file must be readable, otherwise the original is kept
+	var decoded Config
+	if err := toml.Unmarshal(migrated.Bytes(), &decoded); err != nil {
+		return nil, nil, fmt.Errorf("migrated config file is still invalid: %s", err.Error())
+	}
+
+	if dryRun {
+		return applied, migrated.Bytes(), nil
+	}
+
+	if err := os.WriteFile(backup, original, 0600); err != nil {
+		return nil, nil, fmt.Errorf("error writing backup: %s", err.Error())
+	}
+	temporary := filename + ".tmp"
+	if err := os.WriteFile(temporary, migrated.Bytes(), 0600); err != nil {
+		return nil, nil, err
+	}
+	return applied, migrated.Bytes(), os.Rename(temporary, filename)
+}
+
+// ConfigBackupFilename returns the name of the backup written before a config
+// file is migrated, e.g. `config.toml.20250601-120000.bak`.
+func ConfigBackupFilename(filename string, now time.Time) string {
+	return fmt.Sprintf("%s.%s.bak", filename, now.Format("20060102-150405"))
+}
@@ -0,0 +1,89 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+const legacyConfig = `poll_rate = 2
+blacklist = ["chromium"]
+
+[sinks.lastfm]
+key = "key"
+secret = "secret"
+session_key = "session key"
+
+[sinks.csv]
+filename = "scrobbles.csv"
+
+[users.guest.sinks.lastfm]
+key = "guest key"
+`
+
+func TestMigrateConfig(t *testing.T) {
+	config := map[string]any{
+		"sinks": map[string]any{
+			"lastfm": map[string]any{"key": "key", "secret": "secret"},
+			"csv":    map[string]any{"default": map[string]any{"filename": "scrobbles.csv"}},
+		},
+	}
+
+	applied := main.MigrateConfig(config)
+	require.Equal(t, []string{main.ConfigMigrations[0].Description}, applied)
+	require.Equal(t, map[string]any{
+		"lastfm": map[string]any{"default": map[string]any{"key": "key", "secret": "secret"}},
+		"csv":    map[string]any{"default": map[string]any{"filename": "scrobbles.csv"}},
+	}, config["sinks"])
+
+	// migrations are only applied once
+	require.Empty(t, main.MigrateConfig(config))
+
+	config = map[string]any{"blacklist": []any{"chromium", map[string]any{"match": "Podcast", "album": true}}}
+	require.Equal(t, []string{main.ConfigMigrations[2].Description}, main.MigrateConfig(config))
+	require.Equal(t, []any{
+		map[string]any{"match": "chromium", "player": true},
+		map[string]any{"match": "Podcast", "album": true},
+	}, config["blacklist"])
+}
+
+func TestMigrateConfigFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(filename, []byte(legacyConfig), 0600))
+
+	_, err := main.ReadConfig(filename)
+	require.ErrorContains(t, err, "goscrobble config migrate")
+
+	backup := main.ConfigBackupFilename(filename, time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	require.Equal(t, filename+".20250601-120000.bak", backup)
+
+	// dry runs do not change the config file
+	applied, _, err := main.MigrateConfigFile(filename, backup, true)
+	require.NoError(t, err)
+	require.Len(t, applied, 3)
+	require.NoFileExists(t, backup)
+
+	applied, _, err = main.MigrateConfigFile(filename, backup, false)
+	require.NoError(t, err)
+	require.Len(t, applied, 3)
+
+	original, err := os.ReadFile(backup)
+	require.NoError(t, err)
+	require.Equal(t, legacyConfig, string(original))
+
+	config, err := main.ReadConfig(filename)
+	require.NoError(t, err)
+	require.Equal(t, 2, config.PollRate)
+	require.Equal(t, main.LastFmConfig{Key: "key", Secret: "secret", SessionKey: "session key"}, config.Sinks.LastFm["default"])
+	require.Equal(t, "scrobbles.csv", config.Sinks.CSV["default"].Filename)
+	require.Equal(t, "guest key", config.Users["guest"].Sinks.LastFm["default"].Key)
+	require.Equal(t, []main.FilterRule{{Match: "chromium", Player: true}}, config.Blacklist)
+
+	applied, _, err = main.MigrateConfigFile(filename, backup, false)
+	require.NoError(t, err)
+	require.Empty(t, applied)
+}
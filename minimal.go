@@ -0,0 +1,49 @@
+package main
+
+import "fmt"
+
+// MinimalBuildError is returned by features that are not included in minimal
+// builds.
+type MinimalBuildError struct {
+	Feature string
+}
+
+func (e MinimalBuildError) Error() string {
+	return fmt.Sprintf("%s is not included in minimal builds", e.Feature)
+}
+
+// DisableExcluded disables configured features that are not included in
+// minimal builds.
+func (c *Config) DisableExcluded() []ConfigWarning {
+	if !MinimalBuild {
+		return nil
+	}
+
+	var warnings configWarnings
+
+	if c.Sources.Roon != nil {
+		warnings.add("", "Roon source is not included in minimal builds, disabling it")
+		c.Sources.Roon = nil
+	}
+	for name, user := range c.Users {
+		if user.Sources.Roon != nil {
+			warnings.add(fmt.Sprintf("user=%s", name), "Roon source is not included in minimal builds, disabling it")
+			user.Sources.Roon = nil
+			c.Users[name] = user
+		}
+	}
+	if len(c.Plugins) > 0 {
+		warnings.add("", "WebAssembly plugins are not included in minimal builds, disabling them")
+		c.Plugins = nil
+	}
+	if c.Events != nil && c.Events.MQTT != nil {
+		warnings.add("", "MQTT event output is not included in minimal builds, disabling it")
+		c.Events.MQTT = nil
+	}
+	for key, sink := range c.Sinks.CSV {
+		if CompressionFromFilename(sink.Filename) == CompressionZstd {
+			warnings.add(fmt.Sprintf("key=%s filename=%s", key, sink.Filename), "zstd compression is not included in minimal builds, use gzip (.gz) instead")
+		}
+	}
+	return warnings
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os/exec"
 
 	"github.com/rs/zerolog/log"
@@ -27,3 +28,12 @@ func SendNotification(_ uint32, summary, body string) (uint32, error) {
 		Msg("sent desktop notification using terminal-notifier")
 	return 0, nil
 }
+
+// NotificationsAvailable returns an error if terminal-notifier is not
+// installed.
+func NotificationsAvailable() error {
+	if _, err := exec.LookPath("terminal-notifier"); err != nil {
+		return errors.New("terminal-notifier is not installed")
+	}
+	return nil
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"errors"
+	"slices"
+
 	"github.com/godbus/dbus/v5"
 	"github.com/rs/zerolog/log"
 )
 
 func SendNotification(replacesID uint32, summary, body string) (uint32, error) {
+	if InSandbox() {
+		return SendPortalNotification(replacesID, summary, body)
+	}
+
 	conn, err := dbus.ConnectSessionBus()
 	if err != nil {
 		return 0, err
@@ -33,3 +40,31 @@ func SendNotification(replacesID uint32, summary, body string) (uint32, error) {
 		Msg("sent desktop notification using dbus")
 	return id, nil
 }
+
+// NotificationsAvailable returns an error if no notification daemon is
+// running or can be started by the session bus.
+func NotificationsAvailable() error {
+	if InSandbox() {
+		// notifications are sent using the portal
+		return nil
+	}
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(conn)
+
+	var names, activatable []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		return err
+	}
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListActivatableNames", 0).Store(&activatable); err != nil {
+		return err
+	}
+
+	if !slices.Contains(names, "org.freedesktop.Notifications") && !slices.Contains(activatable, "org.freedesktop.Notifications") {
+		return errors.New("no notification daemon is running")
+	}
+	return nil
+}
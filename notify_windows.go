@@ -0,0 +1,22 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SendNotification only logs the notification, since desktop notifications
+// are not supported on Windows yet. When running as a service, the message
+// ends up in the event log.
+func SendNotification(_ uint32, summary, body string) (uint32, error) {
+	log.Info().
+		Str("summary", summary).
+		Str("body", body).
+		Msg("notification")
+	return 0, nil
+}
+
+func NotificationsAvailable() error {
+	return errors.New("desktop notifications are not supported on Windows, they are logged instead")
+}
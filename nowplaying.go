@@ -0,0 +1,87 @@
+package main
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// NowPlayingEntry is the playback status of a single player. Position and
+// duration are in seconds, to make the JSON output easy to use in scripts.
+type NowPlayingEntry struct {
+	Player   string        `json:"player"`
+	Source   string        `json:"source"`
+	State    PlaybackState `json:"state"`
+	Artists  []string      `json:"artists"`
+	Track    string        `json:"track"`
+	Album    string        `json:"album"`
+	Position int           `json:"position"`
+	Duration int           `json:"duration"`
+	// whether the metadata is complete enough to scrobble the track
+	Valid bool `json:"valid"`
+}
+
+// QueryNowPlaying queries all sources once and returns their players, sorted
+// by player name. Player names are prefixed with prefix (e.g., the name of a
+// user profile). Errors of single sources are logged, so the players of the
+// other sources are still returned.
+func QueryNowPlaying(
+	sources []Source,
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+	prefix string,
+) []NowPlayingEntry {
+	var entries []NowPlayingEntry
+	for _, source := range sources {
+		players, err := source.GetInfo(playerBlacklist, regexes)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("source", source.Name()).
+				Msg("error getting current playback status")
+		}
+
+		for _, player := range slices.Sorted(maps.Keys(players)) {
+			status := players[player]
+			artists := status.Artists
+			if artists == nil {
+				artists = []string{}
+			}
+
+			entries = append(entries, NowPlayingEntry{
+				Player:   prefix + player,
+				Source:   source.Name(),
+				State:    status.State,
+				Artists:  artists,
+				Track:    status.Track,
+				Album:    status.Album,
+				Position: int(status.Position.Seconds()),
+				Duration: int(status.Duration.Seconds()),
+				Valid:    status.IsValid(),
+			})
+		}
+	}
+
+	slices.SortStableFunc(entries, func(a, b NowPlayingEntry) int {
+		return cmp.Compare(a.Player, b.Player)
+	})
+	return entries
+}
+
+// PrettyPosition returns the position and duration (e.g., "01:10 / 04:11"),
+// or only the position if the duration is unknown.
+func (e NowPlayingEntry) PrettyPosition() string {
+	return prettyPosition(time.Duration(e.Position)*time.Second, time.Duration(e.Duration)*time.Second)
+}
+
+func prettyPosition(position, duration time.Duration) string {
+	prettyPosition := cmp.Or(formatDuration(position), "00:00")
+	prettyDuration := formatDuration(duration)
+	if prettyDuration == "" {
+		return prettyPosition
+	}
+	return prettyPosition + " / " + prettyDuration
+}
@@ -0,0 +1,40 @@
+package main_test
+
+import (
+	"errors"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryNowPlaying(t *testing.T) {
+	paused := defaultPlaybackStatus
+	paused.State = main.PlaybackPaused
+	sources := []main.Source{
+		&multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"vlc": paused, "spotify": defaultPlaybackStatus}, err: nil},
+		&multiPlayerSource{name: "upnp", players: nil, err: errors.New("no renderer found")},
+		&multiPlayerSource{name: "webhook", players: map[string]main.PlaybackStatus{"jellyfin": {}}, err: nil},
+	}
+
+	entries := main.QueryNowPlaying(sources, nil, nil, "alice/")
+	require.Len(t, entries, 3)
+
+	require.Equal(t, "alice/jellyfin", entries[0].Player)
+	require.Equal(t, "webhook", entries[0].Source)
+	require.Empty(t, entries[0].Artists)
+	require.False(t, entries[0].Valid)
+
+	require.Equal(t, "alice/spotify", entries[1].Player)
+	require.Equal(t, main.PlaybackPlaying, entries[1].State)
+	require.Equal(t, defaultScrobble.Artists, entries[1].Artists)
+	require.Equal(t, 110, entries[1].Position)
+	require.Equal(t, 251, entries[1].Duration)
+	require.True(t, entries[1].Valid)
+	require.Equal(t, "01:50 / 04:11", entries[1].PrettyPosition())
+
+	require.Equal(t, "alice/vlc", entries[2].Player)
+	require.Equal(t, main.PlaybackPaused, entries[2].State)
+
+	require.Empty(t, main.QueryNowPlaying(nil, nil, nil, ""))
+}
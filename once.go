@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// RunOnce polls all sources a single time, submits the tracks that reached
+// the scrobble threshold, and returns, e.g. for `goscrobble run --once` from
+// cron. The playback journal is used even if it is disabled (but not in dry
+// runs), so the time played between runs counts toward the threshold. It
+// returns the errors of sources and sinks during the run.
+func RunOnce(config Config, run RunOptions) error {
+	config.PlaybackJournal = true
+	config = run.Apply(config)
+
+	state := NewLoopState()
+	events := NewEventBus()
+	events.SetOutputs(config.SetupEventOutputs())
+	options := config.LoopOptions()
+	options.Events = events
+
+	sources := config.SetupSources()
+	sinks := config.SetupSinks()
+
+	if config.PlaybackJournal {
+		state.Journal = NewPlaybackJournal(JournalFilename())
+		state.Journal.Recover(state, options, sinks, SendNotification)
+	}
+	profiles := config.SetupUserProfiles(nil, options)
+	defer CloseSources(append(UserSources(profiles), sources...))
+
+	RunMainLoopOnce(state, options, sources, sinks, SendNotification)
+	for _, profile := range profiles {
+		RunMainLoopOnce(profile.State, options, profile.Sources, profile.Sinks, SendNotification)
+	}
+
+	states := []*LoopState{state}
+	for _, profile := range profiles {
+		states = append(states, profile.State)
+	}
+
+	var errs []error
+	for _, s := range states {
+		for _, failed := range []map[string]string{s.SourceErrors, s.SinkErrors} {
+			for _, name := range slices.Sorted(maps.Keys(failed)) {
+				errs = append(errs, fmt.Errorf("%s: %s", name, failed[name]))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
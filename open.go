@@ -1,23 +1,79 @@
 package main
 
 import (
+	"errors"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strings"
 )
 
-func OpenURL(url string) error {
-	var cmd string
-	var args []string
+// OpenURL opens a URL using the configured opener, the commands listed in
+// $BROWSER, or the default opener of the platform, in this order. Inside a
+// sandbox, the desktop portal is tried first.
+func OpenURL(opener []string, url string) error {
+	var errs []error
 
+	if len(opener) == 0 && InSandbox() {
+		err := PortalOpenURI(url)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	for _, command := range OpenerCommands(opener, os.Getenv("BROWSER"), url) {
+		//nolint:gosec
+		err := exec.Command(command[0], command[1:]...).Run()
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// OpenerCommands returns the commands to try for opening a URL. Arguments
+// containing `%s` are replaced with the URL, otherwise it is appended.
+func OpenerCommands(opener []string, browser, url string) [][]string {
+	if len(opener) > 0 {
+		return [][]string{openerCommand(opener, url)}
+	}
+
+	var commands [][]string
+
+	// https://wiki.archlinux.org/title/Environment_variables#Default_programs
+	for _, entry := range filepath.SplitList(browser) {
+		if fields := strings.Fields(entry); len(fields) > 0 {
+			commands = append(commands, openerCommand(fields, url))
+		}
+	}
+
+	return append(commands, []string{defaultOpener(), url})
+}
+
+func defaultOpener() string {
 	switch runtime.GOOS {
 	case "darwin":
-		cmd = "open"
-		args = []string{url}
+		return "open"
 	default:
-		cmd = "xdg-open"
-		args = []string{url}
+		return "xdg-open"
 	}
+}
 
-	//nolint:gosec
-	return exec.Command(cmd, args...).Run()
+func openerCommand(opener []string, url string) []string {
+	var command []string
+	substituted := false
+	for _, arg := range opener {
+		if strings.Contains(arg, "%s") {
+			arg = strings.ReplaceAll(arg, "%s", url)
+			substituted = true
+		}
+		command = append(command, arg)
+	}
+
+	if !substituted {
+		command = append(command, url)
+	}
+	return command
 }
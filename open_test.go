@@ -0,0 +1,24 @@
+package main_test
+
+import (
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenerCommands(t *testing.T) {
+	url := "https://www.last.fm/api/auth"
+
+	commands := main.OpenerCommands([]string{"firefox", "--new-window"}, "chromium", url)
+	require.Equal(t, [][]string{{"firefox", "--new-window", url}}, commands)
+
+	commands = main.OpenerCommands([]string{"qutebrowser", "--target=window", "open %s"}, "", url)
+	require.Equal(t, [][]string{{"qutebrowser", "--target=window", "open " + url}}, commands)
+
+	commands = main.OpenerCommands([]string{}, "w3m:firefox %s", url)
+	require.Len(t, commands, 3)
+	require.Equal(t, []string{"w3m", url}, commands[0])
+	require.Equal(t, []string{"firefox", url}, commands[1])
+	require.Equal(t, url, commands[2][1])
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rodaine/table"
+)
+
+// Table prints rows of values, either aligned in columns or, in accessible
+// mode, as linear text for screen readers.
+type Table interface {
+	AddRow(values ...any)
+	Print()
+}
+
+func NewTable(accessible bool, columns ...string) Table {
+	if accessible {
+		return &AccessibleTable{Columns: columns, Rows: nil, Writer: os.Stdout}
+	}
+
+	headers := make([]any, 0, len(columns))
+	for _, column := range columns {
+		headers = append(headers, column)
+	}
+	return columnTable{table: table.New(headers...)}
+}
+
+type columnTable struct {
+	table table.Table
+}
+
+func (t columnTable) AddRow(values ...any) {
+	t.table.AddRow(values...)
+}
+
+func (t columnTable) Print() {
+	t.table.Print()
+}
+
+// AccessibleTable prints one `label: value` line per column and separates
+// rows with blank lines, so screen readers do not read out padding.
+type AccessibleTable struct {
+	Columns []string
+	Rows    [][]any
+	Writer  io.Writer
+}
+
+func (t *AccessibleTable) AddRow(values ...any) {
+	t.Rows = append(t.Rows, values)
+}
+
+func (t *AccessibleTable) Print() {
+	if len(t.Rows) == 0 {
+		_, _ = fmt.Fprintln(t.Writer, "No results")
+		return
+	}
+
+	for i, row := range t.Rows {
+		if i > 0 {
+			_, _ = fmt.Fprintln(t.Writer)
+		}
+		for j, column := range t.Columns {
+			value := "none"
+			if j < len(row) {
+				if text := strings.TrimSpace(fmt.Sprint(row[j])); text != "" {
+					value = text
+				}
+			}
+			_, _ = fmt.Fprintf(t.Writer, "%s: %s\n", AccessibleLabel(column), value)
+		}
+	}
+}
+
+// AccessibleLabel turns a column header (e.g., `SKIP RATE`) into a label that
+// is read out as words (e.g., `Skip rate`).
+func AccessibleLabel(column string) string {
+	label := strings.ToLower(column)
+	if label == "" {
+		return label
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
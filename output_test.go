@@ -0,0 +1,24 @@
+package main_test
+
+import (
+	"bytes"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessibleTable(t *testing.T) {
+	var buffer bytes.Buffer
+	tbl := &main.AccessibleTable{Columns: []string{"ARTIST", "SKIP RATE", "ALBUM"}, Writer: &buffer}
+	tbl.AddRow("Placebo", "25%", "")
+	tbl.AddRow("Muse", "0%", "Absolution")
+	tbl.Print()
+
+	require.Equal(t, "Artist: Placebo\nSkip rate: 25%\nAlbum: none\n\nArtist: Muse\nSkip rate: 0%\nAlbum: Absolution\n", buffer.String())
+	require.NotContains(t, buffer.String(), "  ")
+
+	buffer.Reset()
+	(&main.AccessibleTable{Columns: []string{"ARTIST"}, Writer: &buffer}).Print()
+	require.Equal(t, "No results\n", buffer.String())
+}
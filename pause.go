@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PauseMode stops scrobbling until it is resumed using the control socket, or
+// until the pause expires.
+type PauseMode struct {
+	mutex  sync.Mutex
+	paused bool
+	// zero if the pause does not end automatically
+	until time.Time
+	// set once the pause ended, until Resumed is called
+	resumed bool
+}
+
+// Pause stops scrobbling. If duration is positive, scrobbling continues
+// automatically after that time.
+func (p *PauseMode) Pause(now time.Time, duration time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.paused = true
+	p.resumed = false
+	p.until = time.Time{}
+	if duration > 0 {
+		p.until = now.Add(duration)
+	}
+}
+
+func (p *PauseMode) Resume() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.paused {
+		p.resumed = true
+	}
+	p.paused = false
+	p.until = time.Time{}
+}
+
+// Active reports whether scrobbling is paused, resuming it once the pause
+// expired.
+func (p *PauseMode) Active(now time.Time) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.paused && !p.until.IsZero() && !now.Before(p.until) {
+		log.Info().Msg("pause expired, resumed scrobbling")
+		p.paused = false
+		p.resumed = true
+		p.until = time.Time{}
+	}
+	return p.paused
+}
+
+// Until returns the time the pause ends, or the zero time if it does not end
+// automatically.
+func (p *PauseMode) Until() time.Time {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.until
+}
+
+// Resumed reports whether the pause ended since the last call. The tracks
+// playing at that time were at least partly played during the pause, so they
+// should not be scrobbled.
+func (p *PauseMode) Resumed() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	resumed := p.resumed
+	p.resumed = false
+	return resumed
+}
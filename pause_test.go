@@ -0,0 +1,39 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseMode(t *testing.T) {
+	now := time.Date(2025, 1, 1, 20, 0, 0, 0, time.UTC)
+
+	var pauseMode main.PauseMode
+	require.False(t, pauseMode.Active(now))
+	require.False(t, pauseMode.Resumed())
+
+	pauseMode.Pause(now, 0)
+	require.True(t, pauseMode.Active(now.Add(24*time.Hour)))
+	require.True(t, pauseMode.Until().IsZero())
+	require.False(t, pauseMode.Resumed())
+
+	pauseMode.Resume()
+	require.False(t, pauseMode.Active(now))
+	require.True(t, pauseMode.Resumed())
+	require.False(t, pauseMode.Resumed())
+
+	pauseMode.Pause(now, 2*time.Hour)
+	require.Equal(t, now.Add(2*time.Hour), pauseMode.Until())
+	require.True(t, pauseMode.Active(now.Add(time.Hour)))
+	require.False(t, pauseMode.Resumed())
+	require.False(t, pauseMode.Active(now.Add(2*time.Hour)))
+	require.True(t, pauseMode.Resumed())
+	require.True(t, pauseMode.Until().IsZero())
+
+	// resuming without a pause does not skip any tracks
+	pauseMode.Resume()
+	require.False(t, pauseMode.Resumed())
+}
@@ -23,17 +23,17 @@ const (
 )
 
 type Scrobble struct {
-	Artists   []string
-	Track     string
-	Album     string
-	Duration  time.Duration
-	Timestamp time.Time
+	Artists   []string      `json:"artists"`
+	Track     string        `json:"track"`
+	Album     string        `json:"album"`
+	Duration  time.Duration `json:"duration"`
+	Timestamp time.Time     `json:"timestamp"`
 }
 
 type PlaybackStatus struct {
 	Scrobble
-	State    PlaybackState
-	Position time.Duration
+	State    PlaybackState `json:"state"`
+	Position time.Duration `json:"position"`
 }
 
 type ParsedRegexReplace struct {
@@ -48,12 +48,24 @@ func (s Scrobble) JoinArtists() string {
 	return strings.Join(s.Artists, ", ")
 }
 
+// Key identifies a single play of a track, independent of the sink it was
+// saved to.
+func (s Scrobble) Key() string {
+	return fmt.Sprintf("%d\x00%s\x00%s", s.Timestamp.Unix(), s.JoinArtists(), s.Track)
+}
+
 func (s Scrobble) PrettyDuration() string {
-	if s.Duration == 0 {
+	return formatDuration(s.Duration)
+}
+
+// formatDuration returns the duration as minutes and seconds (e.g., "04:11"),
+// or an empty string if it is zero.
+func formatDuration(duration time.Duration) string {
+	if duration == 0 {
 		return ""
 	}
-	minutes := int(s.Duration.Minutes())
-	seconds := int(s.Duration.Seconds()) % 60
+	minutes := int(duration.Minutes())
+	seconds := int(duration.Seconds()) % 60
 	return fmt.Sprintf("%02d:%02d", minutes, seconds)
 }
 
@@ -65,6 +77,12 @@ func (p PlaybackStatus) Equals(other PlaybackStatus) bool {
 }
 
 func (s Scrobble) IsValid() bool {
+	return s.HasMetadata() && s.Duration != 0
+}
+
+// HasMetadata reports whether the artists, track, and album are known. The
+// duration may still be missing.
+func (s Scrobble) HasMetadata() bool {
 	switch {
 	case s.JoinArtists() == "":
 		return false
@@ -72,8 +90,6 @@ func (s Scrobble) IsValid() bool {
 		return false
 	case s.Album == "":
 		return false
-	case s.Duration == 0:
-		return false
 	default:
 		return true
 	}
@@ -126,7 +142,12 @@ func ScrobbleFromCSV(input string) (Scrobble, error) {
 		return Scrobble{}, err
 	}
 
-	if len(parts) != 5 {
+	return ScrobbleFromRecord(parts)
+}
+
+func ScrobbleFromRecord(parts []string) (Scrobble, error) {
+	// signed records have two additional columns (provenance and signature)
+	if len(parts) != 5 && len(parts) != 7 {
 		return Scrobble{}, errors.New("input has invalid number of columns")
 	}
 
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"io"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// megabytes
+	DefaultPluginMemoryLimit = 64
+	// milliseconds
+	DefaultPluginTimeout = 100
+)
+
+// PluginEventType tells plugins where a track comes from.
+type PluginEventType string
+
+const (
+	// the current playback status of a player, sent on every poll
+	PluginEventPlayback = PluginEventType("playback")
+	// a finished play received by a source, e.g. the webhook source
+	PluginEventScrobble = PluginEventType("scrobble")
+)
+
+// PluginEvent is passed to plugins as JSON. Plugins that transform tracks
+// return it with changed artists, track, or album, all other fields are
+// ignored.
+type PluginEvent struct {
+	Event   PluginEventType `json:"event"`
+	Player  string          `json:"player"`
+	Artists []string        `json:"artists"`
+	Track   string          `json:"track"`
+	Album   string          `json:"album"`
+	// seconds
+	Duration float64 `json:"duration"`
+}
+
+// PluginSet is shared by the sources of a configuration and closed with the
+// last of them.
+type PluginSet struct {
+	Plugins []*Plugin
+
+	mu   sync.Mutex
+	refs int
+}
+
+// LoadPlugins loads all configured plugins. Plugins that cannot be loaded
+// are logged and left out.
+func LoadPlugins(configs []PluginConfig) *PluginSet {
+	set := &PluginSet{Plugins: nil, mu: sync.Mutex{}, refs: 0}
+
+	for _, config := range configs {
+		log.Debug().
+			Str("path", config.Path).
+			Msg("loading plugin")
+
+		plugin, err := LoadPlugin(config)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("path", config.Path).
+				Msg("failed to load plugin")
+			continue
+		}
+		set.Plugins = append(set.Plugins, plugin)
+	}
+
+	return set
+}
+
+// Apply runs all plugins in order on the track of a player and reports
+// whether it is kept. Plugins that fail are logged and skipped.
+func (s *PluginSet) Apply(eventType PluginEventType, player string, scrobble Scrobble) (Scrobble, bool) {
+	event := PluginEvent{
+		Event:    eventType,
+		Player:   player,
+		Artists:  scrobble.Artists,
+		Track:    scrobble.Track,
+		Album:    scrobble.Album,
+		Duration: scrobble.Duration.Seconds(),
+	}
+
+	for _, plugin := range s.Plugins {
+		transformed, keep, err := plugin.Apply(event)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("plugin", plugin.Name).
+				Str("player", player).
+				Msg("plugin failed, ignoring it for this track")
+			continue
+		}
+		if !keep {
+			log.Debug().
+				Str("plugin", plugin.Name).
+				Str("player", player).
+				Msg("track dropped by plugin")
+			return scrobble, false
+		}
+		event = transformed
+	}
+
+	scrobble.Artists = event.Artists
+	scrobble.Track = event.Track
+	scrobble.Album = event.Album
+	return scrobble, true
+}
+
+func (s *PluginSet) acquire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs++
+}
+
+func (s *PluginSet) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+	for _, plugin := range s.Plugins {
+		CloseLogged(plugin)
+	}
+}
+
+// PluginSource runs the plugins on the tracks of a source, after its
+// match/replace expressions.
+type PluginSource struct {
+	Source
+	Plugins *PluginSet
+}
+
+// WrapSourcesPlugins returns the sources unchanged if no plugins are
+// configured.
+func WrapSourcesPlugins(sources []Source, configs []PluginConfig) []Source {
+	if len(configs) == 0 || len(sources) == 0 {
+		return sources
+	}
+
+	plugins := LoadPlugins(configs)
+	wrapped := make([]Source, 0, len(sources))
+	for _, source := range sources {
+		plugins.acquire()
+		wrapped = append(wrapped, PluginSource{Source: source, Plugins: plugins})
+	}
+	return wrapped
+}
+
+func (s PluginSource) GetInfo(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) (map[string]PlaybackStatus, error) {
+	statuses, err := s.Source.GetInfo(playerBlacklist, regexes)
+
+	transformed := map[string]PlaybackStatus{}
+	for player, status := range statuses {
+		scrobble, keep := s.Plugins.Apply(PluginEventPlayback, player, status.Scrobble)
+		if !keep {
+			continue
+		}
+		status.Scrobble = scrobble
+		transformed[player] = status
+	}
+
+	return transformed, err
+}
+
+// ReceivedScrobbles runs the plugins on the scrobbles received by the wrapped
+// source. Sources that do not receive scrobbles return none.
+func (s PluginSource) ReceivedScrobbles(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) map[string][]Scrobble {
+	scrobbleSource, ok := s.Source.(ScrobbleSource)
+	if !ok {
+		return nil
+	}
+
+	transformed := map[string][]Scrobble{}
+	for player, scrobbles := range scrobbleSource.ReceivedScrobbles(playerBlacklist, regexes) {
+		for _, scrobble := range scrobbles {
+			scrobble, keep := s.Plugins.Apply(PluginEventScrobble, player, scrobble)
+			if !keep {
+				log.Info().
+					Str("source", s.Name()).
+					Str("player", player).
+					Interface("scrobble", scrobble).
+					Msg("ignoring received scrobble, track was dropped by a plugin")
+				continue
+			}
+			transformed[player] = append(transformed[player], scrobble)
+		}
+	}
+	return transformed
+}
+
+func (s PluginSource) Close() error {
+	defer s.Plugins.release()
+
+	if closer, ok := s.Source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
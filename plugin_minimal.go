@@ -0,0 +1,19 @@
+//go:build minimal
+
+package main
+
+type Plugin struct {
+	Name string
+}
+
+func LoadPlugin(_ PluginConfig) (*Plugin, error) {
+	return nil, MinimalBuildError{Feature: "WebAssembly plugins"}
+}
+
+func (p *Plugin) Apply(event PluginEvent) (PluginEvent, bool, error) {
+	return event, true, nil
+}
+
+func (p *Plugin) Close() error {
+	return nil
+}
@@ -0,0 +1,122 @@
+package main_test
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+// buildPlugin compiles the example plugin in testdata/plugin.
+func buildPlugin(t *testing.T) string {
+	t.Helper()
+
+	if main.MinimalBuild {
+		t.Skip("WebAssembly plugins are not included in minimal builds")
+	}
+	goBinary, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	filename := filepath.Join(t.TempDir(), "plugin.wasm")
+	//nolint:gosec
+	cmd := exec.Command(goBinary, "build", "-buildmode=c-shared", "-o", filename, ".")
+	cmd.Dir = filepath.Join("testdata", "plugin")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	return filename
+}
+
+func TestPlugin(t *testing.T) {
+	plugin, err := main.LoadPlugin(main.PluginConfig{Path: buildPlugin(t), MemoryLimit: 0, Timeout: 0})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, plugin.Close()) }()
+	require.Equal(t, "plugin.wasm", plugin.Name)
+
+	event := main.PluginEvent{
+		Event:    main.PluginEventPlayback,
+		Player:   "fake player",
+		Artists:  []string{"Placebo"},
+		Track:    "Pure Morning (Remastered)",
+		Album:    "Without You I'm Nothing",
+		Duration: 254,
+	}
+	transformed, keep, err := plugin.Apply(event)
+	require.NoError(t, err)
+	require.True(t, keep)
+	require.Equal(t, "Pure Morning", transformed.Track)
+	require.Equal(t, event.Artists, transformed.Artists)
+	require.Equal(t, event.Album, transformed.Album)
+
+	event.Artists = []string{"Nickelback"}
+	_, keep, err = plugin.Apply(event)
+	require.NoError(t, err)
+	require.False(t, keep)
+
+	// modules are started again after exceeding the timeout
+	event.Artists = []string{"Placebo"}
+	event.Track = "spin"
+	_, keep, err = plugin.Apply(event)
+	require.ErrorContains(t, err, "transform")
+	require.True(t, keep)
+
+	event.Track = "Pure Morning"
+	transformed, keep, err = plugin.Apply(event)
+	require.NoError(t, err)
+	require.True(t, keep)
+	require.Equal(t, event, transformed)
+}
+
+func TestPluginLimits(t *testing.T) {
+	filename := buildPlugin(t)
+
+	// the Go runtime needs more than a megabyte of memory
+	_, err := main.LoadPlugin(main.PluginConfig{Path: filename, MemoryLimit: 1, Timeout: 0})
+	require.Error(t, err)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.wasm")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a module"), 0600))
+	_, err = main.LoadPlugin(main.PluginConfig{Path: invalid, MemoryLimit: 0, Timeout: 0})
+	require.ErrorContains(t, err, "invalid WebAssembly module")
+}
+
+func TestPluginSource(t *testing.T) {
+	configs := []main.PluginConfig{
+		{Path: buildPlugin(t), MemoryLimit: 0, Timeout: 0},
+		{Path: "missing.wasm", MemoryLimit: 0, Timeout: 0},
+	}
+	require.Empty(t, main.WrapSourcesPlugins(nil, configs))
+
+	status := defaultPlaybackStatus
+	status.Track = "Without You I'm Nothing (Remastered)"
+	sources := main.WrapSourcesPlugins([]main.Source{
+		FakeSource{Empty: false, Error: false, PlaybackStatus: status},
+		FakeSource{Empty: false, Error: true, PlaybackStatus: status},
+	}, configs)
+	require.Len(t, sources, 2)
+
+	info, err := sources[1].GetInfo(nil, nil)
+	require.Error(t, err)
+	require.Equal(t, map[string]main.PlaybackStatus{"fake player": defaultPlaybackStatus}, info)
+
+	status.Artists = []string{"Nickelback"}
+	sources = append(sources, main.WrapSourcesPlugins([]main.Source{
+		FakeSource{Empty: false, Error: false, PlaybackStatus: status},
+	}, configs)...)
+	info, err = sources[2].GetInfo(nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, info)
+
+	for _, source := range sources {
+		closer, ok := source.(io.Closer)
+		require.True(t, ok)
+		require.NoError(t, closer.Close())
+	}
+}
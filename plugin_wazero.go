@@ -0,0 +1,217 @@
+//go:build !minimal
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Plugin is a WebAssembly module that filters or transforms tracks. Modules
+// are WASI reactors without access to the file system, the network, or the
+// real clock, and export the following functions:
+//
+//   - `alloc(size i32) -> i32`: returns a buffer of size bytes for the event,
+//     which is only used during the next call
+//   - `filter(ptr i32, size i32) -> i32` (optional): returns 0 to drop the track
+//   - `transform(ptr i32, size i32) -> i64` (optional): returns the address of
+//     the changed event in the upper and its size in the lower 32 bits, or 0 to
+//     keep the track unchanged
+//
+// Modules can log messages using the `log(ptr i32, size i32)` function of the
+// `goscrobble` module.
+type Plugin struct {
+	Name string
+
+	mu       sync.Mutex
+	timeout  time.Duration
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	module   api.Module
+}
+
+func LoadPlugin(config PluginConfig) (*Plugin, error) {
+	memoryLimit := config.MemoryLimit
+	if memoryLimit <= 0 {
+		memoryLimit = DefaultPluginMemoryLimit
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultPluginTimeout
+	}
+
+	//nolint:gosec
+	binary, err := os.ReadFile(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	plugin := &Plugin{
+		Name:     filepath.Base(config.Path),
+		mu:       sync.Mutex{},
+		timeout:  time.Duration(timeout) * time.Millisecond,
+		runtime:  nil,
+		compiled: nil,
+		module:   nil,
+	}
+
+	ctx := context.Background()
+	// 64 KiB per page
+	runtimeConfig := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(memoryLimit) * 16).
+		WithCloseOnContextDone(true)
+	plugin.runtime = wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, plugin.runtime); err != nil {
+		CloseLogged(plugin)
+		return nil, err
+	}
+	_, err = plugin.runtime.NewHostModuleBuilder("goscrobble").
+		NewFunctionBuilder().
+		WithFunc(plugin.log).
+		Export("log").
+		Instantiate(ctx)
+	if err != nil {
+		CloseLogged(plugin)
+		return nil, err
+	}
+
+	if plugin.compiled, err = plugin.runtime.CompileModule(ctx, binary); err != nil {
+		CloseLogged(plugin)
+		return nil, fmt.Errorf("invalid WebAssembly module: %s", err.Error())
+	}
+	exports := plugin.compiled.ExportedFunctions()
+	_, hasFilter := exports["filter"]
+	_, hasTransform := exports["transform"]
+	if _, ok := exports["alloc"]; !ok || (!hasFilter && !hasTransform) {
+		CloseLogged(plugin)
+		return nil, errors.New("module must export alloc and filter or transform")
+	}
+
+	if _, err := plugin.instance(ctx); err != nil {
+		CloseLogged(plugin)
+		return nil, err
+	}
+
+	return plugin, nil
+}
+
+func (p *Plugin) log(_ context.Context, module api.Module, ptr, size uint32) {
+	message, ok := module.Memory().Read(ptr, size)
+	if !ok {
+		return
+	}
+	log.Info().
+		Str("plugin", p.Name).
+		Msg(string(message))
+}
+
+// instance returns the running module. Modules are closed when a call times
+// out, so they are instantiated again.
+func (p *Plugin) instance(ctx context.Context) (api.Module, error) {
+	if p.module != nil && !p.module.IsClosed() {
+		return p.module, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	moduleConfig := wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize")
+	module, err := p.runtime.InstantiateModule(ctx, p.compiled, moduleConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error starting module: %s", err.Error())
+	}
+
+	p.module = module
+	return module, nil
+}
+
+// call passes the event to an exported function and returns its result, or
+// false if the module does not export it.
+func (p *Plugin) call(name string, event []byte) (uint64, bool, error) {
+	module, err := p.instance(context.Background())
+	if err != nil {
+		return 0, false, err
+	}
+	function := module.ExportedFunction(name)
+	if function == nil {
+		return 0, false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	results, err := module.ExportedFunction("alloc").Call(ctx, uint64(len(event)))
+	if err != nil {
+		return 0, false, err
+	}
+	ptr := uint32(results[0])
+	if !module.Memory().Write(ptr, event) {
+		return 0, false, errors.New("alloc returned an invalid buffer")
+	}
+
+	results, err = function.Call(ctx, uint64(ptr), uint64(len(event)))
+	if err != nil {
+		return 0, false, err
+	}
+	return results[0], true, nil
+}
+
+// Apply runs the filter and transform functions of the module and reports
+// whether the track is kept.
+func (p *Plugin) Apply(event PluginEvent) (PluginEvent, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return event, true, err
+	}
+
+	keep, ok, err := p.call("filter", encoded)
+	if err != nil {
+		return event, true, fmt.Errorf("error calling filter: %s", err.Error())
+	}
+	if ok && uint32(keep) == 0 {
+		return event, false, nil
+	}
+
+	result, ok, err := p.call("transform", encoded)
+	if err != nil {
+		return event, true, fmt.Errorf("error calling transform: %s", err.Error())
+	}
+	if !ok || result == 0 {
+		return event, true, nil
+	}
+
+	output, ok := p.module.Memory().Read(uint32(result>>32), uint32(result))
+	if !ok {
+		return event, true, errors.New("transform returned an invalid buffer")
+	}
+	var transformed PluginEvent
+	if err := json.Unmarshal(output, &transformed); err != nil {
+		return event, true, fmt.Errorf("transform returned an invalid event: %s", err.Error())
+	}
+
+	event.Artists = transformed.Artists
+	event.Track = transformed.Track
+	event.Album = transformed.Album
+	return event, true, nil
+}
+
+func (p *Plugin) Close() error {
+	return p.runtime.Close(context.Background())
+}
@@ -0,0 +1,16 @@
+package main
+
+import "time"
+
+// seconds
+const DefaultIdlePollRate = 30
+
+// NextPollInterval returns how long the main loop waits before polling the
+// sources again. While no source reports a player, it backs off to the idle
+// poll interval to save power.
+func (o LoopOptions) NextPollInterval(playbackStatus map[string]PlaybackStatus) time.Duration {
+	if len(playbackStatus) == 0 && o.IdlePollInterval > o.PollInterval {
+		return o.IdlePollInterval
+	}
+	return o.PollInterval
+}
@@ -0,0 +1,29 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextPollInterval(t *testing.T) {
+	options := replayOptions()
+	options.PollInterval = 2 * time.Second
+	options.IdlePollInterval = 30 * time.Second
+
+	require.Equal(t, 30*time.Second, options.NextPollInterval(map[string]main.PlaybackStatus{}))
+
+	playing := map[string]main.PlaybackStatus{"player": defaultPlaybackStatus}
+	require.Equal(t, 2*time.Second, options.NextPollInterval(playing))
+
+	// paused players are still polled quickly, so resuming is noticed
+	paused := defaultPlaybackStatus
+	paused.State = main.PlaybackPaused
+	require.Equal(t, 2*time.Second, options.NextPollInterval(map[string]main.PlaybackStatus{"player": paused}))
+
+	// disabled
+	options.IdlePollInterval = 0
+	require.Equal(t, 2*time.Second, options.NextPollInterval(map[string]main.PlaybackStatus{}))
+}
@@ -0,0 +1,18 @@
+package main_test
+
+import (
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInSandbox(t *testing.T) {
+	t.Setenv("FLATPAK_ID", "")
+	t.Setenv("SNAP", "/snap/goscrobble/1")
+	require.True(t, main.InSandbox())
+
+	t.Setenv("FLATPAK_ID", "io.github.p_mng.goscrobble")
+	t.Setenv("SNAP", "")
+	require.True(t, main.InSandbox())
+}
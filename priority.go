@@ -0,0 +1,80 @@
+package main
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// PlayerPolicy decides which players are scrobbled when several of them play
+// at the same time.
+type PlayerPolicy string
+
+const (
+	// every player is scrobbled (default)
+	PlayerPolicyAll = PlayerPolicy("all")
+	// only the playing player matching the earliest entry of the priority list
+	PlayerPolicyPriority = PlayerPolicy("priority")
+	// only the player that started playing most recently
+	PlayerPolicyRecent = PlayerPolicy("recent")
+)
+
+// UpdatePlayingSince records when each player started playing. Track changes
+// during continuous playback do not count as starting to play.
+func (s *LoopState) UpdatePlayingSince(playbackStatus map[string]PlaybackStatus, now time.Time) {
+	for player := range s.PlayingSince {
+		if status, ok := playbackStatus[player]; !ok || status.State != PlaybackPlaying {
+			delete(s.PlayingSince, player)
+		}
+	}
+
+	for player, status := range playbackStatus {
+		if _, ok := s.PlayingSince[player]; !ok && status.State == PlaybackPlaying {
+			s.PlayingSince[player] = now
+		}
+	}
+}
+
+// ActivePlayer returns the only player to scrobble according to the policy.
+// It returns false if all players are scrobbled or none of them is playing.
+// Players with the same priority are ranked by the time they started playing,
+// and finally by name.
+func ActivePlayer(
+	policy PlayerPolicy,
+	priority []*regexp.Regexp,
+	playbackStatus map[string]PlaybackStatus,
+	playingSince map[string]time.Time,
+) (string, bool) {
+	if policy != PlayerPolicyPriority && policy != PlayerPolicyRecent {
+		return "", false
+	}
+
+	rank := func(player string) int {
+		if policy == PlayerPolicyRecent {
+			return 0
+		}
+		for i, expression := range priority {
+			if expression.MatchString(player) {
+				return i
+			}
+		}
+		return len(priority)
+	}
+
+	active := ""
+	for _, player := range slices.Sorted(maps.Keys(playbackStatus)) {
+		if playbackStatus[player].State != PlaybackPlaying {
+			continue
+		}
+
+		switch {
+		case active == "",
+			rank(player) < rank(active),
+			rank(player) == rank(active) && playingSince[player].After(playingSince[active]):
+			active = player
+		}
+	}
+
+	return active, active != ""
+}
@@ -0,0 +1,87 @@
+package main_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivePlayer(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	paused := defaultPlaybackStatus
+	paused.State = main.PlaybackPaused
+
+	playbackStatus := map[string]main.PlaybackStatus{
+		"firefox": defaultPlaybackStatus,
+		"spotify": defaultPlaybackStatus,
+		"upnp":    paused,
+	}
+	playingSince := map[string]time.Time{
+		"firefox": now,
+		"spotify": now.Add(-time.Minute),
+	}
+	priority := []*regexp.Regexp{regexp.MustCompile("upnp"), regexp.MustCompile("spotify")}
+
+	_, ok := main.ActivePlayer(main.PlayerPolicyAll, priority, playbackStatus, playingSince)
+	require.False(t, ok)
+
+	player, ok := main.ActivePlayer(main.PlayerPolicyRecent, priority, playbackStatus, playingSince)
+	require.True(t, ok)
+	require.Equal(t, "firefox", player)
+
+	// paused players never win
+	player, ok = main.ActivePlayer(main.PlayerPolicyPriority, priority, playbackStatus, playingSince)
+	require.True(t, ok)
+	require.Equal(t, "spotify", player)
+
+	// unlisted players are ranked last
+	player, ok = main.ActivePlayer(main.PlayerPolicyPriority, priority[:1], playbackStatus, playingSince)
+	require.True(t, ok)
+	require.Equal(t, "firefox", player)
+
+	_, ok = main.ActivePlayer(main.PlayerPolicyRecent, priority, map[string]main.PlaybackStatus{"upnp": paused}, playingSince)
+	require.False(t, ok)
+}
+
+func TestPlayerPolicy(t *testing.T) {
+	state := main.NewLoopState()
+	options := replayOptions()
+	options.MinPlaybackDuration = 60
+	options.PlayerPolicy = main.PlayerPolicyPriority
+	options.PlayerPriority = []*regexp.Regexp{regexp.MustCompile("^spotify$")}
+	notifier := FakeNotifier{}
+	sink := &FakeSink{}
+
+	started := defaultPlaybackStatus
+	started.Position = 0
+	other := started
+	other.Track = "Every You Every Me"
+
+	source := &multiPlayerSource{
+		name:    "dbus",
+		players: map[string]main.PlaybackStatus{"firefox": other},
+		err:     nil,
+	}
+	main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	require.Len(t, sink.NowPlayingLog, 1)
+
+	// spotify takes over, firefox keeps playing in the background
+	source.players = map[string]main.PlaybackStatus{"firefox": other, "spotify": started}
+	main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	require.Len(t, sink.NowPlayingLog, 2)
+	require.Equal(t, started.Track, sink.NowPlayingLog[1].Track)
+
+	playing := started
+	playing.Position = 90 * time.Second
+	background := other
+	background.Position = 90 * time.Second
+	source.players = map[string]main.PlaybackStatus{"firefox": background, "spotify": playing}
+	main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	require.Len(t, sink.ScrobbleLog, 1)
+	require.Equal(t, started.Track, sink.ScrobbleLog[0].Track)
+	require.Equal(t, 2, state.Stats.TracksSeen)
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	QueueFileName      = "queue.db"
+	QueueRetryInterval = time.Minute
+)
+
+// ScrobbleQueue stores scrobbles that could not be submitted in a bolt
+// database, using one bucket per sink. The database is only opened while it is
+// accessed, so other goscrobble commands can read it while the daemon runs.
+type ScrobbleQueue struct {
+	Filename string
+}
+
+func QueueFilename() string {
+	return filepath.Join(StateDir(), QueueFileName)
+}
+
+func (q ScrobbleQueue) open(readOnly bool) (*bolt.DB, error) {
+	if !readOnly {
+		if err := os.MkdirAll(filepath.Dir(q.Filename), 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	//nolint:exhaustruct // the zero values are the defaults of bolt
+	return bolt.Open(q.Filename, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: readOnly})
+}
+
+// Push appends a scrobble to the queue of the given sink.
+func (q ScrobbleQueue) Push(sink string, scrobble Scrobble) error {
+	db, err := q.open(false)
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(db)
+
+	encoded, err := json.Marshal(scrobble)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(sink))
+		if err != nil {
+			return err
+		}
+
+		sequence, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(binary.BigEndian.AppendUint64(nil, sequence), encoded)
+	})
+}
+
+// Pending returns all queued scrobbles of the given sink in submission order.
+func (q ScrobbleQueue) Pending(sink string) ([]Scrobble, error) {
+	if _, err := os.Stat(q.Filename); os.IsNotExist(err) {
+		return []Scrobble{}, nil
+	}
+
+	db, err := q.open(true)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseLogged(db)
+
+	scrobbles := []Scrobble{}
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(sink))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(_, value []byte) error {
+			var scrobble Scrobble
+			if err := json.Unmarshal(value, &scrobble); err != nil {
+				return err
+			}
+			scrobbles = append(scrobbles, scrobble)
+			return nil
+		})
+	})
+
+	return scrobbles, err
+}
+
+// Flush submits the queued scrobbles of the given sink in order and removes
+// them from the queue. Scrobbles the sink rejects (see RejectedError) are
+// dropped, since they would block the queue for good. It stops at the first
+// other failed submission and returns the number of scrobbles still queued.
+// The database is not opened while scrobbles are submitted, so a slow sink
+// does not block other commands.
+func (q ScrobbleQueue) Flush(sink string, submit func(Scrobble) error) (int, error) {
+	keys, scrobbles, err := q.entries(sink)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, scrobble := range scrobbles {
+		err := submit(scrobble)
+		switch {
+		case IsRejected(err):
+			log.Error().
+				Err(err).
+				Str("sink", sink).
+				Interface("scrobble", scrobble).
+				Msg("dropped queued scrobble rejected by sink")
+		case err != nil:
+			return len(keys) - i, err
+		default:
+			log.Debug().
+				Str("sink", sink).
+				Interface("scrobble", scrobble).
+				Msg("submitted queued scrobble")
+		}
+
+		// removed right away, so a failed submission or a crash does not
+		// send already submitted scrobbles again
+		if err := q.remove(sink, keys[i]); err != nil {
+			return len(keys) - i, err
+		}
+	}
+
+	return 0, nil
+}
+
+// entries returns the keys and scrobbles of the given sink in submission
+// order.
+func (q ScrobbleQueue) entries(sink string) ([][]byte, []Scrobble, error) {
+	if _, err := os.Stat(q.Filename); os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+
+	db, err := q.open(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer CloseLogged(db)
+
+	var keys [][]byte
+	var scrobbles []Scrobble
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(sink))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key, value []byte) error {
+			var scrobble Scrobble
+			if err := json.Unmarshal(value, &scrobble); err != nil {
+				return err
+			}
+			// keys are only valid during the transaction
+			keys = append(keys, slices.Clone(key))
+			scrobbles = append(scrobbles, scrobble)
+			return nil
+		})
+	})
+
+	return keys, scrobbles, err
+}
+
+func (q ScrobbleQueue) remove(sink string, key []byte) error {
+	db, err := q.open(false)
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(db)
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(sink))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete(key)
+	})
+}
+
+// Clear removes the queued scrobbles of the given sink with a timestamp
+// before the given time and returns the number of removed scrobbles.
+func (q ScrobbleQueue) Clear(sink string, before time.Time) (int, error) {
+	if _, err := os.Stat(q.Filename); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	db, err := q.open(false)
+	if err != nil {
+		return 0, err
+	}
+	defer CloseLogged(db)
+
+	removed := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(sink))
+		if bucket == nil {
+			return nil
+		}
+
+		var keys [][]byte
+		if err := bucket.ForEach(func(key, value []byte) error {
+			var scrobble Scrobble
+			if err := json.Unmarshal(value, &scrobble); err != nil {
+				return err
+			}
+			if scrobble.Timestamp.Before(before) {
+				keys = append(keys, key)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return removed, nil
+}
+
+// QueueSink wraps another sink and queues scrobbles on disk if submitting them
+// fails. Queued scrobbles are retried before each new scrobble and
+// periodically from the main loop.
+type QueueSink struct {
+	Sink
+	Queue ScrobbleQueue
+
+	mutex     sync.Mutex
+	lastRetry time.Time
+}
+
+func NewQueueSink(sink Sink, queue ScrobbleQueue) *QueueSink {
+	return &QueueSink{Sink: sink, Queue: queue, mutex: sync.Mutex{}, lastRetry: time.Time{}}
+}
+
+func (s *QueueSink) Unwrap() Sink {
+	return s.Sink
+}
+
+func (s *QueueSink) Scrobble(scrobble Scrobble) error {
+	remaining, err := s.flush()
+	if remaining == 0 && err == nil {
+		err = s.Sink.Scrobble(scrobble)
+		// rejected scrobbles would be rejected again
+		if err == nil || IsRejected(err) {
+			return err
+		}
+	}
+
+	if queueErr := s.Queue.Push(s.Name(), scrobble); queueErr != nil {
+		log.Error().
+			Err(queueErr).
+			Str("sink", s.Name()).
+			Msg("error queueing scrobble")
+		return err
+	}
+
+	log.Warn().
+		Str("sink", s.Name()).
+		Interface("scrobble", scrobble).
+		Msg("queued scrobble for later submission")
+
+	return fmt.Errorf("queued scrobble for later submission: %w", err)
+}
+
+// RetryQueued submits queued scrobbles if the last attempt is older than
+// QueueRetryInterval.
+func (s *QueueSink) RetryQueued() {
+	s.mutex.Lock()
+	due := time.Since(s.lastRetry) >= QueueRetryInterval
+	s.mutex.Unlock()
+
+	if !due {
+		return
+	}
+
+	if _, err := s.flush(); err != nil {
+		log.Debug().
+			Err(err).
+			Str("sink", s.Name()).
+			Msg("error submitting queued scrobbles")
+	}
+}
+
+// FlushQueue submits queued scrobbles immediately and returns the number of
+// scrobbles still queued.
+func (s *QueueSink) FlushQueue() (int, error) {
+	return s.flush()
+}
+
+func (s *QueueSink) QueueDepth() (int, error) {
+	pending, err := s.Queue.Pending(s.Name())
+	return len(pending), err
+}
+
+func (s *QueueSink) QueuedScrobbles() ([]Scrobble, error) {
+	return s.Queue.Pending(s.Name())
+}
+
+// ClearQueue removes queued scrobbles with a timestamp before the given time
+// without submitting them.
+func (s *QueueSink) ClearQueue(before time.Time) (int, error) {
+	return s.Queue.Clear(s.Name(), before)
+}
+
+func (s *QueueSink) flush() (int, error) {
+	s.mutex.Lock()
+	s.lastRetry = time.Now()
+	s.mutex.Unlock()
+
+	return s.Queue.Flush(s.Name(), s.Sink.Scrobble)
+}
+
+// QueueDepth returns the total number of queued scrobbles of all sinks.
+func QueueDepth(sinks []Sink) int {
+	depth := 0
+	for _, sink := range sinks {
+		queuedSink, ok := sink.(QueuedSink)
+		if !ok {
+			continue
+		}
+
+		sinkDepth, err := queuedSink.QueueDepth()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Str("sink", sink.Name()).
+				Msg("error reading queue depth")
+		}
+		depth += sinkDepth
+	}
+	return depth
+}
+
+// QueuedScrobbles returns the queued scrobbles of all sinks by sink name.
+// Sinks without queued scrobbles are omitted.
+func QueuedScrobbles(sinks []Sink) (map[string][]Scrobble, error) {
+	queued := map[string][]Scrobble{}
+	for _, sink := range sinks {
+		queuedSink, ok := sink.(QueuedSink)
+		if !ok {
+			continue
+		}
+
+		scrobbles, err := queuedSink.QueuedScrobbles()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sink.Name(), err)
+		}
+		if len(scrobbles) > 0 {
+			queued[sink.Name()] = scrobbles
+		}
+	}
+	return queued, nil
+}
+
+// FlushQueues submits the queued scrobbles of all sinks immediately and
+// returns the total number of scrobbles still queued.
+func FlushQueues(sinks []Sink) (int, error) {
+	remaining := 0
+	var errs []error
+	for _, sink := range sinks {
+		queuedSink, ok := sink.(QueuedSink)
+		if !ok {
+			continue
+		}
+
+		sinkRemaining, err := queuedSink.FlushQueue()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+		remaining += sinkRemaining
+	}
+	return remaining, errors.Join(errs...)
+}
+
+// ClearQueues removes the queued scrobbles of all sinks with a timestamp
+// before the given time and returns the total number of removed scrobbles.
+func ClearQueues(sinks []Sink, before time.Time) (int, error) {
+	removed := 0
+	var errs []error
+	for _, sink := range sinks {
+		queuedSink, ok := sink.(QueuedSink)
+		if !ok {
+			continue
+		}
+
+		sinkRemoved, err := queuedSink.ClearQueue(before)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+		removed += sinkRemoved
+	}
+	return removed, errors.Join(errs...)
+}
@@ -0,0 +1,207 @@
+package main_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrobbleQueue(t *testing.T) {
+	queue := main.ScrobbleQueue{Filename: filepath.Join(t.TempDir(), main.QueueFileName)}
+
+	pending, err := queue.Pending("fake sink")
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	later := defaultScrobble
+	later.Timestamp = defaultScrobble.Timestamp.Add(time.Hour)
+
+	require.NoError(t, queue.Push("fake sink", defaultScrobble))
+	require.NoError(t, queue.Push("fake sink", later))
+	require.NoError(t, queue.Push("other sink", later))
+
+	fakeSink := &FakeSink{}
+
+	// first submission succeeds, second one fails
+	submitted := 0
+	remaining, err := queue.Flush("fake sink", func(scrobble main.Scrobble) error {
+		if submitted > 0 {
+			fakeSink.Error = true
+		}
+		submitted++
+
+		// the database is not locked while scrobbles are submitted
+		_, err := queue.Pending("other sink")
+		require.NoError(t, err)
+
+		return fakeSink.Scrobble(scrobble)
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, remaining)
+
+	pending, err = queue.Pending("fake sink")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.True(t, later.Timestamp.Equal(pending[0].Timestamp))
+
+	pending, err = queue.Pending("other sink")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+}
+
+func TestQueueSink(t *testing.T) {
+	queue := main.ScrobbleQueue{Filename: filepath.Join(t.TempDir(), main.QueueFileName)}
+
+	fakeSink := &FakeSink{Error: true}
+	sink := main.NewQueueSink(fakeSink, queue)
+
+	require.Error(t, sink.Scrobble(defaultScrobble))
+
+	pending, err := queue.Pending(sink.Name())
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	fakeSink.Error = false
+	require.NoError(t, sink.Scrobble(defaultScrobble))
+	require.Len(t, fakeSink.ScrobbleLog, 2)
+
+	pending, err = queue.Pending(sink.Name())
+	require.NoError(t, err)
+	require.Empty(t, pending)
+	require.Equal(t, fakeSink, main.UnwrapSink(sink))
+}
+
+// RejectingSink rejects scrobbles of the given track.
+type RejectingSink struct {
+	*FakeSink
+	Track string
+}
+
+func (s RejectingSink) Scrobble(scrobble main.Scrobble) error {
+	if scrobble.Track == s.Track {
+		return main.RejectedError{Err: errors.New("timestamp too old")}
+	}
+	return s.FakeSink.Scrobble(scrobble)
+}
+
+func TestQueueSinkRejected(t *testing.T) {
+	queue := main.ScrobbleQueue{Filename: filepath.Join(t.TempDir(), main.QueueFileName)}
+
+	fakeSink := &FakeSink{}
+	sink := main.NewQueueSink(RejectingSink{FakeSink: fakeSink, Track: "Pure Morning"}, queue)
+
+	rejected := defaultScrobble
+	rejected.Track = "Pure Morning"
+	later := defaultScrobble
+	later.Timestamp = defaultScrobble.Timestamp.Add(time.Hour)
+
+	// a rejected scrobble at the head of the queue does not block the others
+	require.NoError(t, queue.Push(sink.Name(), rejected))
+	require.NoError(t, queue.Push(sink.Name(), later))
+	require.NoError(t, sink.Scrobble(defaultScrobble))
+	require.Len(t, fakeSink.ScrobbleLog, 2)
+	require.True(t, later.Timestamp.Equal(fakeSink.ScrobbleLog[0].Timestamp))
+	require.True(t, defaultScrobble.Timestamp.Equal(fakeSink.ScrobbleLog[1].Timestamp))
+
+	pending, err := queue.Pending(sink.Name())
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	// rejected scrobbles are not queued
+	err = sink.Scrobble(rejected)
+	require.True(t, main.IsRejected(err))
+
+	pending, err = queue.Pending(sink.Name())
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestQueueSinkRetryTimeout(t *testing.T) {
+	queue := main.ScrobbleQueue{Filename: filepath.Join(t.TempDir(), main.QueueFileName)}
+
+	hangingSink := &HangingSink{Release: make(chan struct{})}
+	require.NoError(t, queue.Push(hangingSink.Name(), defaultScrobble))
+
+	options := replayOptions()
+	options.SinkTimeout = 100 * time.Millisecond
+	sinks := []main.Sink{main.NewQueueSink(hangingSink, queue)}
+
+	start := time.Now()
+	main.RunMainLoopOnce(main.NewLoopState(), options, nil, sinks, (&FakeNotifier{}).SendNotification)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	close(hangingSink.Release)
+	require.Eventually(t, func() bool {
+		pending, err := queue.Pending(hangingSink.Name())
+		return err == nil && len(pending) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestFlushQueues(t *testing.T) {
+	queue := main.ScrobbleQueue{Filename: filepath.Join(t.TempDir(), main.QueueFileName)}
+
+	fakeSink := &FakeSink{Error: true}
+	sinks := []main.Sink{main.NewQueueSink(fakeSink, queue), &FakeSink{}}
+
+	require.Error(t, sinks[0].Scrobble(defaultScrobble))
+	require.Equal(t, 1, main.QueueDepth(sinks))
+
+	queued, err := main.QueuedScrobbles(sinks)
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	require.Len(t, queued["fake sink"], 1)
+
+	remaining, err := main.FlushQueues(sinks)
+	require.Error(t, err)
+	require.Equal(t, 1, remaining)
+
+	fakeSink.Error = false
+	remaining, err = main.FlushQueues(sinks)
+	require.NoError(t, err)
+	require.Equal(t, 0, remaining)
+	require.Len(t, fakeSink.ScrobbleLog, 1)
+	require.Equal(t, 0, main.QueueDepth(sinks))
+
+	queued, err = main.QueuedScrobbles(sinks)
+	require.NoError(t, err)
+	require.Empty(t, queued)
+}
+
+func TestClearQueues(t *testing.T) {
+	queue := main.ScrobbleQueue{Filename: filepath.Join(t.TempDir(), main.QueueFileName)}
+
+	removed, err := queue.Clear("fake sink", time.Now())
+	require.NoError(t, err)
+	require.Zero(t, removed)
+
+	later := defaultScrobble
+	later.Timestamp = defaultScrobble.Timestamp.Add(time.Hour)
+
+	sinks := []main.Sink{main.NewQueueSink(&FakeSink{Error: true}, queue), &FakeSink{}}
+	require.Error(t, sinks[0].Scrobble(defaultScrobble))
+	require.Error(t, sinks[0].Scrobble(later))
+	require.NoError(t, queue.Push("other sink", defaultScrobble))
+
+	removed, err = main.ClearQueues(sinks, later.Timestamp)
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+
+	pending, err := queue.Pending(sinks[0].Name())
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.True(t, later.Timestamp.Equal(pending[0].Timestamp))
+
+	removed, err = main.ClearQueues(sinks, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	require.Zero(t, main.QueueDepth(sinks))
+
+	// other sinks keep their queued scrobbles
+	pending, err = queue.Pending("other sink")
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+}
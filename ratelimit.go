@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// last.fm allows 5 requests per second, averaged over 5 minutes
+	LastFmRequestInterval = 200 * time.Millisecond
+	// pause after a rate limit error without a Retry-After header
+	LastFmRateLimitPause = time.Minute
+	// longest pause that is waited out while paginating instead of failing
+	lastFmMaxPaginationWait = time.Minute
+	lastFmRateLimitCode     = 29
+)
+
+// RateLimitError is returned instead of sending a request while the server
+// asked to pause requests.
+type RateLimitError struct {
+	Until time.Time
+	Err   error
+}
+
+func (e RateLimitError) Error() string {
+	message := fmt.Sprintf("rate limited until %s", e.Until.Format(time.TimeOnly))
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimiter spaces out requests and pauses them after the server responded
+// with a rate limit error. A nil rate limiter allows all requests.
+type RateLimiter struct {
+	Interval time.Duration
+
+	mutex       sync.Mutex
+	next        time.Time
+	pausedUntil time.Time
+}
+
+func NewRateLimiter(interval time.Duration) *RateLimiter {
+	return &RateLimiter{Interval: interval, mutex: sync.Mutex{}, next: time.Time{}, pausedUntil: time.Time{}}
+}
+
+var (
+	lastFmRateLimitersMutex sync.Mutex
+	lastFmRateLimiters      = map[string]*RateLimiter{}
+)
+
+// LastFmRateLimiter returns the rate limiter shared by all sinks using the
+// same API key, which is kept when the configuration is reloaded.
+func LastFmRateLimiter(key string) *RateLimiter {
+	lastFmRateLimitersMutex.Lock()
+	defer lastFmRateLimitersMutex.Unlock()
+
+	limiter, ok := lastFmRateLimiters[key]
+	if !ok {
+		limiter = NewRateLimiter(LastFmRequestInterval)
+		lastFmRateLimiters[key] = limiter
+	}
+	return limiter
+}
+
+// Reserve returns how long to wait before sending the next request, or an
+// error while requests are paused.
+func (l *RateLimiter) Reserve(now time.Time) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Before(l.pausedUntil) {
+		return 0, RateLimitError{Until: l.pausedUntil, Err: nil}
+	}
+
+	wait := max(l.next.Sub(now), 0)
+	l.next = now.Add(wait + l.Interval)
+	return wait, nil
+}
+
+// Pause rejects all requests until the given time.
+func (l *RateLimiter) Pause(until time.Time) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+func (l *RateLimiter) PausedUntil() time.Time {
+	if l == nil {
+		return time.Time{}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.pausedUntil
+}
+
+// IsLastFmRateLimit reports whether the last.fm API rejected a request with
+// error 29 (rate limit exceeded).
+func IsLastFmRateLimit(err error) bool {
+	return err != nil && strings.HasSuffix(err.Error(), fmt.Sprintf("(code %d)", lastFmRateLimitCode))
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date. Invalid or missing values result in a
+// pause of LastFmRateLimitPause.
+func ParseRetryAfter(value string, now time.Time) time.Time {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date
+	}
+	return now.Add(LastFmRateLimitPause)
+}
+
+// RetryAfterTransport records when hosts responded with 429 Too Many
+// Requests, so the Retry-After header is seen even if the response is not
+// returned to the caller (e.g., because the body is not a valid API
+// response). A nil transport records nothing.
+type RetryAfterTransport struct {
+	Transport http.RoundTripper
+
+	mutex sync.Mutex
+	hosts map[string]time.Time
+}
+
+func NewRetryAfterTransport(transport http.RoundTripper) *RetryAfterTransport {
+	return &RetryAfterTransport{Transport: transport, mutex: sync.Mutex{}, hosts: map[string]time.Time{}}
+}
+
+func (t *RetryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.Transport.RoundTrip(req)
+	if err != nil || res.StatusCode != http.StatusTooManyRequests {
+		return res, err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.hosts[req.URL.Host] = ParseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+
+	return res, err
+}
+
+// Until returns the time until which the host asked to pause requests, or
+// the zero time.
+func (t *RetryAfterTransport) Until(host string, now time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	until, ok := t.hosts[host]
+	if !ok {
+		return time.Time{}
+	}
+	if !now.Before(until) {
+		delete(t.hosts, host)
+		return time.Time{}
+	}
+	return until
+}
+
+// rateLimitedUntil returns until when requests to the host have to be paused
+// after a failed request.
+func rateLimitedUntil(err error, transport *RetryAfterTransport, host string, now time.Time) (time.Time, bool) {
+	if until := transport.Until(host, now); !until.IsZero() {
+		return until, true
+	}
+	if IsLastFmRateLimit(err) {
+		return now.Add(LastFmRateLimitPause), true
+	}
+	return time.Time{}, false
+}
+
+// IsRateLimited reports whether the error was caused by a rate limit.
+func IsRateLimited(err error) bool {
+	var rateLimitErr RateLimitError
+	return errors.As(err, &rateLimitErr)
+}
@@ -0,0 +1,132 @@
+package main_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+type RateLimitedSink struct {
+	FakeSink
+	Attempts int
+}
+
+func (s *RateLimitedSink) Scrobble(_ main.Scrobble) error {
+	s.Attempts++
+	return main.RateLimitError{Until: time.Now().Add(time.Minute), Err: nil}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	limiter := main.NewRateLimiter(200 * time.Millisecond)
+
+	wait, err := limiter.Reserve(now)
+	require.NoError(t, err)
+	require.Zero(t, wait)
+
+	wait, err = limiter.Reserve(now)
+	require.NoError(t, err)
+	require.Equal(t, 200*time.Millisecond, wait)
+
+	wait, err = limiter.Reserve(now.Add(time.Second))
+	require.NoError(t, err)
+	require.Zero(t, wait)
+
+	limiter.Pause(now.Add(time.Minute))
+	limiter.Pause(now.Add(time.Second))
+	require.Equal(t, now.Add(time.Minute), limiter.PausedUntil())
+
+	_, err = limiter.Reserve(now.Add(30 * time.Second))
+	require.True(t, main.IsRateLimited(err))
+
+	_, err = limiter.Reserve(now.Add(2 * time.Minute))
+	require.NoError(t, err)
+
+	var nilLimiter *main.RateLimiter
+	wait, err = nilLimiter.Reserve(now)
+	require.NoError(t, err)
+	require.Zero(t, wait)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, now.Add(120*time.Second), main.ParseRetryAfter("120", now))
+	require.Equal(t, now.Add(time.Hour), main.ParseRetryAfter(now.Add(time.Hour).Format(http.TimeFormat), now))
+	require.Equal(t, now.Add(main.LastFmRateLimitPause), main.ParseRetryAfter("", now))
+}
+
+func TestIsLastFmRateLimit(t *testing.T) {
+	require.True(t, main.IsLastFmRateLimit(errors.New("Rate Limit Exceded (code 29)")))
+	require.False(t, main.IsLastFmRateLimit(errors.New("Invalid session key (code 9)")))
+	require.False(t, main.IsLastFmRateLimit(nil))
+
+	wrapped := fmt.Errorf("queued scrobble for later submission: %w", main.RateLimitError{Until: time.Now(), Err: nil})
+	require.True(t, main.IsRateLimited(wrapped))
+}
+
+func lastFmTestSink(t *testing.T, handler http.HandlerFunc) main.LastFmSink {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config := main.DefaultConfig.Sinks.LastFm["default"]
+	config.BaseURL = server.URL + "/"
+	config.Key = "0123456789abcdef0123456789abcdef"
+	config.Secret = "0123456789abcdef0123456789abcdef"
+	config.SessionKey = "session"
+	config.Username = "user"
+
+	sink, err := main.LastFmSinkFromConfig("default", config, main.NewLastFmHTTPClient())
+	require.NoError(t, err)
+	// every test server has its own key, so its limiter is not shared
+	sink.Limiter = main.NewRateLimiter(0)
+	return sink
+}
+
+func TestLastFmSinkRateLimit(t *testing.T) {
+	requests := 0
+	sink := lastFmTestSink(t, func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`<lfm status="failed"><error code="29">Rate Limit Exceeded</error></lfm>`))
+	})
+
+	err := sink.Scrobble(defaultScrobble)
+	require.True(t, main.IsRateLimited(err))
+	require.WithinDuration(t, time.Now().Add(main.LastFmRateLimitPause), sink.Limiter.PausedUntil(), 5*time.Second)
+
+	// paused requests are not sent
+	require.True(t, main.IsRateLimited(sink.NowPlaying(defaultScrobble)))
+	require.Equal(t, 1, requests)
+}
+
+func TestLastFmSinkRetryAfter(t *testing.T) {
+	sink := lastFmTestSink(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	err := sink.Scrobble(defaultScrobble)
+	require.True(t, main.IsRateLimited(err))
+	require.WithinDuration(t, time.Now().Add(10*time.Minute), sink.Limiter.PausedUntil(), 5*time.Second)
+
+	// the sink has its own HTTP client
+	require.Nil(t, http.DefaultClient.Transport)
+
+	// hanging requests fail before the sink timeout
+	require.Equal(t, main.LastFmTimeout, sink.Client.HTTP.Timeout)
+	require.Less(t, main.LastFmTimeout, main.DefaultSinkTimeout*time.Second)
+}
+
+func TestRetrySinkRateLimit(t *testing.T) {
+	rateLimitedSink := &RateLimitedSink{}
+	sink := main.RetrySink{Sink: rateLimitedSink, Policy: main.RetryPolicyFromConfig(nil), Sleep: func(time.Duration) {}}
+
+	require.Error(t, sink.Scrobble(defaultScrobble))
+	require.Equal(t, 1, rateLimitedSink.Attempts)
+}
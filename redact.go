@@ -0,0 +1,71 @@
+package main
+
+import "maps"
+
+// RedactedSecret replaces secrets in the output of `goscrobble config show`.
+const RedactedSecret = "<redacted>"
+
+// Redacted returns a copy of the config with all API secrets, session keys,
+// tokens, and passwords replaced by RedactedSecret, so it can be shared, e.g.
+// in a bug report. Empty secrets are left empty, so it is still visible which
+// ones are set.
+func (c Config) Redacted() Config {
+	if c.API != nil {
+		api := *c.API
+		api.Token = redact(api.Token)
+		c.API = &api
+	}
+
+	if c.Events != nil {
+		events := *c.Events
+		if events.Webhook != nil {
+			webhook := *events.Webhook
+			webhook.Token = redact(webhook.Token)
+			events.Webhook = &webhook
+		}
+		if events.MQTT != nil {
+			mqtt := *events.MQTT
+			mqtt.Password = redact(mqtt.Password)
+			events.MQTT = &mqtt
+		}
+		c.Events = &events
+	}
+
+	c.Sources = c.Sources.redacted()
+	c.Sinks = c.Sinks.redacted()
+
+	c.Users = maps.Clone(c.Users)
+	for name, user := range c.Users {
+		user.Sources = user.Sources.redacted()
+		user.Sinks = user.Sinks.redacted()
+		c.Users[name] = user
+	}
+
+	return c
+}
+
+func (s SourcesConfig) redacted() SourcesConfig {
+	if s.Webhook != nil {
+		webhook := *s.Webhook
+		webhook.Token = redact(webhook.Token)
+		s.Webhook = &webhook
+	}
+	return s
+}
+
+func (s SinksConfig) redacted() SinksConfig {
+	s.LastFm = maps.Clone(s.LastFm)
+	for key, sinkConfig := range s.LastFm {
+		sinkConfig.Secret = redact(sinkConfig.Secret)
+		sinkConfig.SessionKey = redact(sinkConfig.SessionKey)
+		s.LastFm[key] = sinkConfig
+	}
+	return s
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return RedactedSecret
+}
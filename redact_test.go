@@ -0,0 +1,42 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigRedacted(t *testing.T) {
+	config := main.DefaultConfig
+	config.API = &main.APIConfig{Address: "127.0.0.1:8080", Token: "api token"}
+	config.Events = &main.EventsConfig{MQTT: &main.EventMQTTConfig{Broker: "tcp://localhost:1883", Password: "mqtt password"}}
+	config.Sources.Webhook = &main.WebhookConfig{Address: ":9000", Token: "webhook token"}
+	config.Sinks.LastFm = map[string]main.LastFmConfig{"default": {Key: "key", Secret: "secret", SessionKey: ""}}
+	config.Users = map[string]main.UserConfig{"guest": {
+		Sinks: main.SinksConfig{LastFm: map[string]main.LastFmConfig{"default": {Key: "key", Secret: "secret", SessionKey: "session key"}}},
+	}}
+
+	redacted := config.Redacted()
+
+	require.Equal(t, main.RedactedSecret, redacted.API.Token)
+	require.Equal(t, "127.0.0.1:8080", redacted.API.Address)
+	require.Equal(t, main.RedactedSecret, redacted.Events.MQTT.Password)
+	require.Nil(t, redacted.Events.Webhook)
+	require.Equal(t, main.RedactedSecret, redacted.Sources.Webhook.Token)
+	require.Equal(t, main.LastFmConfig{Key: "key", Secret: main.RedactedSecret, SessionKey: ""}, redacted.Sinks.LastFm["default"])
+	require.Equal(t, main.RedactedSecret, redacted.Users["guest"].Sinks.LastFm["default"].SessionKey)
+
+	// the original config is not changed
+	require.Equal(t, "api token", config.API.Token)
+	require.Equal(t, "mqtt password", config.Events.MQTT.Password)
+	require.Equal(t, "webhook token", config.Sources.Webhook.Token)
+	require.Equal(t, "secret", config.Sinks.LastFm["default"].Secret)
+	require.Equal(t, "session key", config.Users["guest"].Sinks.LastFm["default"].SessionKey)
+
+	var builder strings.Builder
+	require.NoError(t, redacted.Encode(&builder))
+	require.NotContains(t, builder.String(), `"secret"`)
+	require.Contains(t, builder.String(), `secret = "<redacted>"`)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+// Reloaded is the part of the daemon that is rebuilt when the configuration
+// changes. The loop state (currently playing tracks, stats) is kept.
+type Reloaded struct {
+	Config  Config
+	Options LoopOptions
+	Sources []Source
+	Sinks   []Sink
+}
+
+// ReloadConfig reads the configuration file again and sets up new sources and
+// sinks. The previous sources are only closed once the new configuration was
+// read successfully, so a broken file does not stop the running daemon. A dry
+// run stays a dry run after reloading.
+func ReloadConfig(filename string, previous []Source, dryRun bool) (Reloaded, error) {
+	log.Info().
+		Str("filename", filename).
+		Msg("reloading configuration")
+
+	config, err := ReadConfig(filename)
+	if err != nil {
+		return Reloaded{}, err
+	}
+	if dryRun {
+		config = config.DryRunConfig()
+	}
+
+	// sources may listen on the same addresses as before
+	CloseSources(previous)
+
+	return Reloaded{
+		Config:  config,
+		Options: config.LoopOptions(),
+		Sources: config.SetupSources(),
+		Sinks:   config.SetupSinks(),
+	}, nil
+}
@@ -0,0 +1,56 @@
+package main_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	return address
+}
+
+func TestReloadConfig(t *testing.T) {
+	address := freeAddress(t)
+	filename := filepath.Join(t.TempDir(), main.DefaultConfigFileName)
+
+	previous := main.NewWebhookSource("secret", main.TimestampTrustDaemon)
+	require.NoError(t, previous.Listen(address))
+
+	t.Run("broken configuration", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filename, []byte("poll_rate = ["), 0600))
+
+		_, err := main.ReloadConfig(filename, []main.Source{previous}, false)
+		require.Error(t, err)
+
+		// the running source is kept
+		conn, err := net.Dial("tcp", address)
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+	})
+
+	t.Run("valid configuration", func(t *testing.T) {
+		content := fmt.Sprintf("poll_rate = 5\n\n[sources.webhook]\naddress = %q\ntoken = \"secret\"\n", address)
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0600))
+
+		// the new webhook source can only listen if the previous one was closed
+		reloaded, err := main.ReloadConfig(filename, []main.Source{previous}, false)
+		require.NoError(t, err)
+		require.Equal(t, 5, reloaded.Config.PollRate)
+		require.Len(t, reloaded.Sources, 1)
+		require.Empty(t, reloaded.Sinks)
+
+		main.CloseSources(reloaded.Sources)
+	})
+}
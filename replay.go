@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayFrame is the playback status of all players at a single poll.
+type ReplayFrame map[string]PlaybackStatus
+
+// ReplaySource returns one recorded frame per call to GetInfo, so a recording
+// can be fed through the main loop deterministically. Once all frames were
+// returned, all players disappear.
+type ReplaySource struct {
+	Frames []ReplayFrame
+
+	index int
+}
+
+func NewReplaySource(frames []ReplayFrame) *ReplaySource {
+	return &ReplaySource{Frames: frames, index: 0}
+}
+
+func (s *ReplaySource) Name() string {
+	return "replay"
+}
+
+func (s *ReplaySource) GetInfo(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) (map[string]PlaybackStatus, error) {
+	playerPlaybackStatus := map[string]PlaybackStatus{}
+	if s.Done() {
+		return playerPlaybackStatus, nil
+	}
+
+	for player, playbackStatus := range s.Frames[s.index] {
+		if IsPlayerBlacklisted(playerBlacklist, player) {
+			continue
+		}
+
+		playbackStatus.RegexReplace(regexes)
+
+		playerName := fmt.Sprintf("%s:%s", s.Name(), player)
+		playerPlaybackStatus[playerName] = playbackStatus
+	}
+	s.index++
+
+	return playerPlaybackStatus, nil
+}
+
+func (s *ReplaySource) Done() bool {
+	return s.index >= len(s.Frames)
+}
+
+// ParseReplayFrames reads a recording with one JSON encoded frame per line.
+func ParseReplayFrames(input string) ([]ReplayFrame, error) {
+	var frames []ReplayFrame
+
+	scanner := bufio.NewScanner(strings.NewReader(input))
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var frame ReplayFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("invalid frame %d: %w", len(frames), err)
+		}
+		frames = append(frames, frame)
+	}
+
+	return frames, scanner.Err()
+}
+
+type ReplayEventType string
+
+const (
+	// the player is paused for a number of frames, then continues
+	ReplayPause = ReplayEventType("pause")
+	// the position jumps by a number of seconds until the track changes
+	ReplaySeek = ReplayEventType("seek")
+	// the metadata is missing for a number of frames
+	ReplayFlap = ReplayEventType("flap")
+	// the player disappears for a number of frames
+	ReplayCrash = ReplayEventType("crash")
+)
+
+// ReplayEvent is an edge case injected into a recording.
+type ReplayEvent struct {
+	Type   ReplayEventType
+	Player string
+	Frame  int
+	// number of frames for pauses, flaps, and crashes, seconds for seeks
+	Value int
+}
+
+// ParseReplayEvent parses events in the form `<player>@<frame>:<value>`.
+func ParseReplayEvent(eventType ReplayEventType, input string) (ReplayEvent, error) {
+	player, rest, ok := strings.Cut(input, "@")
+	if !ok || player == "" {
+		return ReplayEvent{}, fmt.Errorf("invalid %s event: %s", eventType, input)
+	}
+
+	frameText, valueText, ok := strings.Cut(rest, ":")
+	if !ok {
+		return ReplayEvent{}, fmt.Errorf("invalid %s event: %s", eventType, input)
+	}
+
+	frame, err := strconv.Atoi(frameText)
+	if err != nil || frame < 0 {
+		return ReplayEvent{}, fmt.Errorf("invalid frame in %s event: %s", eventType, input)
+	}
+	value, err := strconv.Atoi(valueText)
+	if err != nil || (eventType != ReplaySeek && value <= 0) {
+		return ReplayEvent{}, fmt.Errorf("invalid value in %s event: %s", eventType, input)
+	}
+
+	return ReplayEvent{Type: eventType, Player: player, Frame: frame, Value: value}, nil
+}
+
+// InjectReplayEvent returns a copy of the frames with the event applied. The
+// original frames are not modified.
+func InjectReplayEvent(frames []ReplayFrame, event ReplayEvent) ([]ReplayFrame, error) {
+	if event.Frame >= len(frames) {
+		return nil, fmt.Errorf("%s event at frame %d is out of range", event.Type, event.Frame)
+	}
+	if _, ok := frames[event.Frame][event.Player]; !ok {
+		return nil, fmt.Errorf("player %s is not present in frame %d", event.Player, event.Frame)
+	}
+
+	injected := make([]ReplayFrame, 0, len(frames))
+	for _, frame := range frames {
+		injected = append(injected, maps.Clone(frame))
+	}
+
+	switch event.Type {
+	case ReplayPause:
+		paused := maps.Clone(injected[event.Frame])
+		status := paused[event.Player]
+		status.State = PlaybackPaused
+		paused[event.Player] = status
+
+		pauses := make([]ReplayFrame, 0, event.Value)
+		for range event.Value {
+			pauses = append(pauses, maps.Clone(paused))
+		}
+		injected = append(injected[:event.Frame], append(pauses, injected[event.Frame:]...)...)
+	case ReplaySeek:
+		seeked := injected[event.Frame][event.Player]
+		for _, frame := range injected[event.Frame:] {
+			status, ok := frame[event.Player]
+			if !ok || !status.Equals(seeked) {
+				break
+			}
+			status.Position = max(status.Position+time.Duration(event.Value)*time.Second, 0)
+			frame[event.Player] = status
+		}
+	case ReplayFlap:
+		for _, frame := range injected[event.Frame:min(event.Frame+event.Value, len(injected))] {
+			status, ok := frame[event.Player]
+			if !ok {
+				continue
+			}
+			status.Scrobble = Scrobble{
+				Artists:   []string{},
+				Track:     "",
+				Album:     "",
+				Duration:  0,
+				Timestamp: time.Time{},
+			}
+			frame[event.Player] = status
+		}
+	case ReplayCrash:
+		for _, frame := range injected[event.Frame:min(event.Frame+event.Value, len(injected))] {
+			delete(frame, event.Player)
+		}
+	default:
+		return nil, errors.New("invalid replay event type")
+	}
+
+	return injected, nil
+}
+
+// ReplaySink records everything sent to it instead of saving it.
+type ReplaySink struct {
+	NowPlayingLog []Scrobble
+	ScrobbleLog   []Scrobble
+}
+
+func (s *ReplaySink) Name() string {
+	return "replay"
+}
+
+func (s *ReplaySink) NowPlaying(scrobble Scrobble) error {
+	s.NowPlayingLog = append(s.NowPlayingLog, scrobble)
+	return nil
+}
+
+func (s *ReplaySink) Scrobble(scrobble Scrobble) error {
+	s.ScrobbleLog = append(s.ScrobbleLog, scrobble)
+	return nil
+}
+
+func (s *ReplaySink) GetScrobbles(_ int, _, _ time.Time) ([]Scrobble, error) {
+	return s.ScrobbleLog, nil
+}
+
+// RunReplay runs one main loop iteration per frame and returns everything
+// that would have been sent to sinks. Notifications are discarded.
+func RunReplay(frames []ReplayFrame, options LoopOptions) *ReplaySink {
+	source := NewReplaySource(frames)
+	sink := &ReplaySink{NowPlayingLog: []Scrobble{}, ScrobbleLog: []Scrobble{}}
+
+	options.NotifyOnScrobble = false
+	options.NotifyOnError = false
+
+	discard := func(uint32, string, string) (uint32, error) {
+		return 0, nil
+	}
+
+	state := NewLoopState()
+	for !source.Done() {
+		RunMainLoopOnce(state, options, []Source{source}, []Sink{sink}, discard)
+	}
+
+	return sink
+}
@@ -0,0 +1,112 @@
+package main_test
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+// one frame every 30 seconds of a 4:11 track
+func replayFrames(t *testing.T) []main.ReplayFrame {
+	var lines []string
+	for position := 0; position <= 240; position += 30 {
+		lines = append(lines, fmt.Sprintf(
+			`{"player": {"artists": ["Placebo"], "track": "Without You I'm Nothing", "album": "Without You I'm Nothing", `+
+				`"duration": 251000000000, "state": "Playing", "position": %d}}`,
+			position*int(time.Second),
+		))
+	}
+
+	frames, err := main.ParseReplayFrames(strings.Join(lines, "\n"))
+	require.NoError(t, err)
+	require.Len(t, frames, 9)
+
+	return frames
+}
+
+func replayOptions() main.LoopOptions {
+	return main.LoopOptions{
+		Blacklist:           nil,
+		Bans:                nil,
+		Whitelist:           nil,
+		ParsedRegexes:       []main.ParsedRegexReplace{},
+		PlayerGroups:        []main.ParsedPlayerGroup{},
+		PlayerPolicy:        main.PlayerPolicyAll,
+		PlayerPriority:      []*regexp.Regexp{},
+		MinPlaybackDuration: 4 * 60,
+		MinPlaybackPercent:  50,
+		Players:             map[string]main.PlayerConfig{},
+		DedupeWindow:        0,
+		ScrobbleTimestamp:   main.ScrobbleTimestampStart,
+		UnknownDuration:     main.UnknownDurationIgnore,
+		PollInterval:        0,
+		IdlePollInterval:    0,
+		NotifyOnScrobble:    false,
+		NotifyOnError:       false,
+		HoldUntilClockSync:  false,
+		NotifyTruncate:      main.TruncateConfig{Artist: 0, Track: 0, Album: 0},
+		SinkTimeout:         0,
+		NowPlayingRefresh:   0,
+		Events:              nil,
+	}
+}
+
+func TestReplay(t *testing.T) {
+	frames := replayFrames(t)
+
+	result := main.RunReplay(frames, replayOptions())
+	require.Len(t, result.NowPlayingLog, 1)
+	require.Len(t, result.ScrobbleLog, 1)
+
+	tests := map[string]struct {
+		event      string
+		eventType  main.ReplayEventType
+		frames     int
+		nowPlaying int
+		scrobbles  int
+	}{
+		"pause":         {"player@3:10", main.ReplayPause, 19, 1, 1},
+		"seek forward":  {"player@1:120", main.ReplaySeek, 9, 1, 1},
+		"metadata flap": {"player@2:2", main.ReplayFlap, 9, 1, 1},
+		"crash":         {"player@2:2", main.ReplayCrash, 9, 2, 1},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			event, err := main.ParseReplayEvent(test.eventType, test.event)
+			require.NoError(t, err)
+
+			injected, err := main.InjectReplayEvent(frames, event)
+			require.NoError(t, err)
+			require.Len(t, injected, test.frames)
+
+			result := main.RunReplay(injected, replayOptions())
+			require.Len(t, result.NowPlayingLog, test.nowPlaying)
+			require.Len(t, result.ScrobbleLog, test.scrobbles)
+		})
+	}
+
+	// the original recording is not modified
+	require.Len(t, frames, 9)
+	require.Equal(t, main.PlaybackPlaying, frames[3]["player"].State)
+}
+
+func TestParseReplayEvent(t *testing.T) {
+	event, err := main.ParseReplayEvent(main.ReplaySeek, "spotify@12:-30")
+	require.NoError(t, err)
+	require.Equal(t, main.ReplayEvent{Type: main.ReplaySeek, Player: "spotify", Frame: 12, Value: -30}, event)
+
+	_, err = main.ParseReplayEvent(main.ReplayPause, "spotify@12:-30")
+	require.Error(t, err)
+
+	_, err = main.ParseReplayEvent(main.ReplayPause, "spotify:12")
+	require.Error(t, err)
+
+	_, err = main.InjectReplayEvent(replayFrames(t), main.ReplayEvent{Type: main.ReplayCrash, Player: "vlc", Frame: 0, Value: 1})
+	require.Error(t, err)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const DefaultReportLimit = 5
+
+type ReportPeriod string
+
+const (
+	ReportWeek  = ReportPeriod("week")
+	ReportMonth = ReportPeriod("month")
+)
+
+var ReportPeriods = []ReportPeriod{ReportWeek, ReportMonth}
+
+// Start returns the start of the period ending at end: 7 days or one calendar
+// month earlier.
+func (p ReportPeriod) Start(end time.Time) (time.Time, error) {
+	switch p {
+	case ReportWeek:
+		return end.AddDate(0, 0, -7), nil
+	case ReportMonth:
+		return end.AddDate(0, -1, 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid report period: %s", p)
+	}
+}
+
+type ReportFormat string
+
+const (
+	ReportText     = ReportFormat("text")
+	ReportMarkdown = ReportFormat("markdown")
+	ReportHTML     = ReportFormat("html")
+)
+
+var ReportFormats = []ReportFormat{ReportText, ReportMarkdown, ReportHTML}
+
+// ChartEntry is an artist, album, or track with its number of scrobbles.
+type ChartEntry struct {
+	Name string `json:"name"`
+	// the artists of albums and tracks, empty for artists
+	Artist string `json:"artist,omitempty"`
+	Plays  int    `json:"plays"`
+}
+
+func (e ChartEntry) String() string {
+	if e.Artist == "" {
+		return e.Name
+	}
+	return fmt.Sprintf("%s %c %s", e.Artist, RuneEmDash, e.Name)
+}
+
+// TopArtists returns the most scrobbled artists, at most limit if it is
+// positive. Scrobbles with multiple artists count for each of them.
+func TopArtists(scrobbles []Scrobble, limit int) []ChartEntry {
+	return chart(scrobbles, limit, func(s Scrobble) []ChartEntry {
+		entries := make([]ChartEntry, 0, len(s.Artists))
+		for _, artist := range s.Artists {
+			entries = append(entries, ChartEntry{Name: artist, Artist: "", Plays: 0})
+		}
+		return entries
+	})
+}
+
+// TopAlbums returns the most scrobbled albums, at most limit if it is
+// positive. Scrobbles without an album are left out.
+func TopAlbums(scrobbles []Scrobble, limit int) []ChartEntry {
+	return chart(scrobbles, limit, func(s Scrobble) []ChartEntry {
+		if s.Album == "" {
+			return nil
+		}
+		return []ChartEntry{{Name: s.Album, Artist: s.JoinArtists(), Plays: 0}}
+	})
+}
+
+// TopTracks returns the most scrobbled tracks, at most limit if it is
+// positive.
+func TopTracks(scrobbles []Scrobble, limit int) []ChartEntry {
+	return chart(scrobbles, limit, func(s Scrobble) []ChartEntry {
+		return []ChartEntry{{Name: s.Track, Artist: s.JoinArtists(), Plays: 0}}
+	})
+}
+
+// chart counts the entries of all scrobbles, most played first. Names are
+// compared case-insensitively, the spelling of the first scrobble is kept.
+func chart(scrobbles []Scrobble, limit int, entries func(Scrobble) []ChartEntry) []ChartEntry {
+	counted := map[string]*ChartEntry{}
+	for _, scrobble := range scrobbles {
+		for _, entry := range entries(scrobble) {
+			key := strings.ToLower(entry.Artist + "\x00" + entry.Name)
+			if _, ok := counted[key]; !ok {
+				counted[key] = &entry
+			}
+			counted[key].Plays++
+		}
+	}
+
+	result := make([]ChartEntry, 0, len(counted))
+	for _, entry := range counted {
+		result = append(result, *entry)
+	}
+	slices.SortFunc(result, func(a, b ChartEntry) int {
+		return cmp.Or(
+			cmp.Compare(b.Plays, a.Plays),
+			cmp.Compare(strings.ToLower(a.String()), strings.ToLower(b.String())),
+		)
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// ListeningReport is a digest of the scrobbles of a period, e.g. to share
+// the listening of the last week.
+type ListeningReport struct {
+	Sink      string
+	From      time.Time
+	To        time.Time
+	Scrobbles int
+	// sum of the durations of all scrobbles with a known duration
+	ListeningTime time.Duration
+	Artists       []ChartEntry
+	Albums        []ChartEntry
+	Tracks        []ChartEntry
+	// artists first scrobbled within the period, in the order they were
+	// discovered
+	NewArtists []string
+}
+
+// NewListeningReport summarizes the scrobbles between from and to. The
+// earlier history is used to find the artists that were discovered in the
+// period, it may also contain scrobbles of the period.
+func NewListeningReport(sink string, scrobbles, history []Scrobble, from, to time.Time, limit int) ListeningReport {
+	report := ListeningReport{
+		Sink:          sink,
+		From:          from,
+		To:            to,
+		Scrobbles:     0,
+		ListeningTime: 0,
+		Artists:       nil,
+		Albums:        nil,
+		Tracks:        nil,
+		NewArtists:    nil,
+	}
+
+	var period []Scrobble
+	for _, scrobble := range scrobbles {
+		if scrobble.Timestamp.Before(from) || scrobble.Timestamp.After(to) {
+			continue
+		}
+		period = append(period, scrobble)
+		report.ListeningTime += scrobble.Duration
+	}
+	report.Scrobbles = len(period)
+	report.Artists = TopArtists(period, limit)
+	report.Albums = TopAlbums(period, limit)
+	report.Tracks = TopTracks(period, limit)
+
+	known := map[string]bool{}
+	for _, scrobble := range history {
+		if scrobble.Timestamp.Before(from) {
+			for _, artist := range scrobble.Artists {
+				known[strings.ToLower(artist)] = true
+			}
+		}
+	}
+
+	slices.SortStableFunc(period, func(a, b Scrobble) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	for _, scrobble := range period {
+		for _, artist := range scrobble.Artists {
+			if !known[strings.ToLower(artist)] {
+				known[strings.ToLower(artist)] = true
+				report.NewArtists = append(report.NewArtists, artist)
+			}
+		}
+	}
+
+	return report
+}
+
+// PrettyListeningTime formats the listening time in hours and minutes, e.g.
+// `12h 05m`.
+func (r ListeningReport) PrettyListeningTime() string {
+	minutes := int(r.ListeningTime.Minutes())
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+const reportTextTemplate = `Listening report for {{.Sink}}
+{{date .From}} to {{date .To}}
+
+{{.Scrobbles}} scrobbles, {{.PrettyListeningTime}} listened
+{{- range .Charts}}
+
+Top {{.Title}}
+{{- range $i, $entry := .Entries}}
+{{printf "%3d" (inc $i)}}. {{$entry}} ({{$entry.Plays}})
+{{- else}}
+  none
+{{- end}}
+{{- end}}
+
+New artists
+{{- range .NewArtists}}
+  {{.}}
+{{- else}}
+  none
+{{- end}}
+`
+
+const reportMarkdownTemplate = `# Listening report: {{date .From}} to {{date .To}}
+
+**{{.Scrobbles}} scrobbles**, {{.PrettyListeningTime}} listened ({{.Sink}})
+{{range .Charts}}
+## Top {{.Title}}
+
+{{range $i, $entry := .Entries -}}
+{{inc $i}}. {{markdown $entry.String}} ({{$entry.Plays}})
+{{else -}}
+None
+{{end -}}
+{{end}}
+## New artists
+
+{{range .NewArtists -}}
+- {{markdown .}}
+{{else -}}
+None
+{{end -}}
+`
+
+const reportHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Listening report: {{date .From}} to {{date .To}}</title>
+</head>
+<body>
+<h1>Listening report: {{date .From}} to {{date .To}}</h1>
+<p><strong>{{.Scrobbles}} scrobbles</strong>, {{.PrettyListeningTime}} listened ({{.Sink}})</p>
+{{- range .Charts}}
+<h2>Top {{.Title}}</h2>
+{{- if .Entries}}
+<ol>
+{{- range .Entries}}
+<li>{{.String}} ({{.Plays}})</li>
+{{- end}}
+</ol>
+{{- else}}
+<p>None</p>
+{{- end}}
+{{- end}}
+<h2>New artists</h2>
+{{- if .NewArtists}}
+<ul>
+{{- range .NewArtists}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- else}}
+<p>None</p>
+{{- end}}
+</body>
+</html>
+`
+
+// ReportChart is a titled chart of a report, e.g. the top artists.
+type ReportChart struct {
+	Title   string
+	Entries []ChartEntry
+}
+
+// Charts returns the top artists, albums, and tracks.
+func (r ListeningReport) Charts() []ReportChart {
+	return []ReportChart{
+		{Title: "artists", Entries: r.Artists},
+		{Title: "albums", Entries: r.Albums},
+		{Title: "tracks", Entries: r.Tracks},
+	}
+}
+
+var reportFuncs = map[string]any{
+	"date": func(t time.Time) string { return t.Local().Format("Mon, 02 Jan 2006") },
+	"inc":  func(i int) int { return i + 1 },
+	"markdown": func(text string) string {
+		return markdownEscaper.Replace(text)
+	},
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "#", `\#`, "<", `\<`,
+)
+
+// RenderReport writes the report to w in the given format.
+func RenderReport(w io.Writer, format ReportFormat, report ListeningReport) error {
+	switch format {
+	case ReportText:
+		return template.Must(template.New("report").Funcs(reportFuncs).Parse(reportTextTemplate)).Execute(w, report)
+	case ReportMarkdown:
+		return template.Must(template.New("report").Funcs(reportFuncs).Parse(reportMarkdownTemplate)).Execute(w, report)
+	case ReportHTML:
+		return htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(reportHTMLTemplate)).Execute(w, report)
+	default:
+		return fmt.Errorf("invalid report format: %s", format)
+	}
+}
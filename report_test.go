@@ -0,0 +1,75 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopCharts(t *testing.T) {
+	scrobbles := []main.Scrobble{
+		defaultScrobble,
+		{Artists: []string{"placebo"}, Track: "Meds", Album: "Meds", Duration: 0, Timestamp: time.Unix(1699225080, 0)},
+		{Artists: []string{"Placebo"}, Track: "Meds", Album: "", Duration: 0, Timestamp: time.Unix(1699225080, 0)},
+		{Artists: []string{"Muse"}, Track: "Uprising", Album: "The Resistance", Duration: 0, Timestamp: time.Unix(1699225080, 0)},
+	}
+
+	require.Equal(t, []main.ChartEntry{
+		{Name: "Placebo", Artist: "", Plays: 3},
+		{Name: "David Bowie", Artist: "", Plays: 1},
+	}, main.TopArtists(scrobbles, 2))
+
+	require.Equal(t, []main.ChartEntry{
+		{Name: "Meds", Artist: "placebo", Plays: 2},
+		{Name: "Uprising", Artist: "Muse", Plays: 1},
+		{Name: "Without You I'm Nothing", Artist: "Placebo, David Bowie", Plays: 1},
+	}, main.TopTracks(scrobbles, 0))
+
+	// scrobbles without an album are left out
+	albums := main.TopAlbums(scrobbles, 0)
+	require.Len(t, albums, 3)
+	for _, album := range albums {
+		require.Equal(t, 1, album.Plays)
+	}
+}
+
+func TestListeningReport(t *testing.T) {
+	to := time.Date(2023, 11, 12, 12, 0, 0, 0, time.UTC)
+	from, err := main.ReportWeek.Start(to)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2023, 11, 5, 12, 0, 0, 0, time.UTC), from)
+
+	_, err = main.ReportPeriod("year").Start(to)
+	require.Error(t, err)
+
+	scrobbles := []main.Scrobble{
+		{Artists: []string{"Muse"}, Track: "Uprising", Album: "The Resistance", Duration: 5 * time.Minute, Timestamp: to.Add(-time.Hour)},
+		{Artists: []string{"Placebo"}, Track: "Meds", Album: "Meds", Duration: 3 * time.Minute, Timestamp: to.Add(-2 * time.Hour)},
+		{Artists: []string{"Muse"}, Track: "Resistance", Album: "The Resistance", Duration: 0, Timestamp: to.Add(-3 * time.Hour)},
+	}
+	history := append([]main.Scrobble{
+		{Artists: []string{"placebo"}, Track: "Pure Morning", Album: "", Duration: 0, Timestamp: from.Add(-time.Hour)},
+	}, scrobbles...)
+
+	report := main.NewListeningReport("csv:default", scrobbles, history, from, to, main.DefaultReportLimit)
+	require.Equal(t, 3, report.Scrobbles)
+	require.Equal(t, 8*time.Minute, report.ListeningTime)
+	require.Equal(t, "0h 08m", report.PrettyListeningTime())
+	require.Equal(t, []main.ChartEntry{{Name: "Muse", Artist: "", Plays: 2}, {Name: "Placebo", Artist: "", Plays: 1}}, report.Artists)
+	require.Equal(t, []string{"Muse"}, report.NewArtists)
+
+	var markdown strings.Builder
+	require.NoError(t, main.RenderReport(&markdown, main.ReportMarkdown, report))
+	require.Contains(t, markdown.String(), "## Top artists\n\n1. Muse (2)\n2. Placebo (1)\n")
+	require.Contains(t, markdown.String(), "## New artists\n\n- Muse\n")
+
+	report.NewArtists = []string{"<script>"}
+	var html strings.Builder
+	require.NoError(t, main.RenderReport(&html, main.ReportHTML, report))
+	require.Contains(t, html.String(), "<li>&lt;script&gt;</li>")
+
+	require.Error(t, main.RenderReport(&html, main.ReportFormat("pdf"), report))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultResurfaceAge      = 365 * 24 * time.Hour
+	DefaultResurfaceMinPlays = 10
+)
+
+// Resurfaced is an artist that was played a lot but not for a long time.
+type Resurfaced struct {
+	Artist     string
+	Plays      int
+	LastPlayed time.Time
+}
+
+func (r Resurfaced) Summary(now time.Time) string {
+	return fmt.Sprintf("%d scrobbles, last played %d days ago", r.Plays, int(now.Sub(r.LastPlayed).Hours()/24))
+}
+
+// Resurface returns artists with at least minPlays scrobbles that were not
+// played within age, most played first.
+func Resurface(scrobbles []Scrobble, now time.Time, age time.Duration, minPlays int) []Resurfaced {
+	byArtist := map[string]*Resurfaced{}
+	for _, scrobble := range scrobbles {
+		for _, artist := range scrobble.Artists {
+			resurfaced, ok := byArtist[artist]
+			if !ok {
+				resurfaced = &Resurfaced{Artist: artist, Plays: 0, LastPlayed: time.Time{}}
+				byArtist[artist] = resurfaced
+			}
+
+			resurfaced.Plays++
+			if scrobble.Timestamp.After(resurfaced.LastPlayed) {
+				resurfaced.LastPlayed = scrobble.Timestamp
+			}
+		}
+	}
+
+	var result []Resurfaced
+	for _, resurfaced := range byArtist {
+		if resurfaced.Plays >= minPlays && now.Sub(resurfaced.LastPlayed) >= age {
+			result = append(result, *resurfaced)
+		}
+	}
+	slices.SortFunc(result, func(a, b Resurfaced) int {
+		return cmp.Or(cmp.Compare(b.Plays, a.Plays), cmp.Compare(a.Artist, b.Artist))
+	})
+
+	return result
+}
+
+// Resurfacer sends a daily listen-again notification from the history of a
+// local sink. A nil resurfacer does nothing.
+type Resurfacer struct {
+	Sink     string
+	Age      time.Duration
+	MinPlays int
+	Cache    *Cache
+
+	day string
+	// the goroutine reading the history
+	group sync.WaitGroup
+}
+
+// IsLocalSink reports whether the history of a sink can be read without
+// requests to a remote service, which is required for listen-again reminders.
+func IsLocalSink(name string) bool {
+	return name == "csv" || strings.HasPrefix(name, "csv:")
+}
+
+func (c Config) Resurfacer() *Resurfacer {
+	if c.Resurface == nil {
+		return nil
+	}
+
+	return &Resurfacer{
+		Sink:     c.Resurface.Sink,
+		Age:      time.Duration(c.Resurface.Days) * 24 * time.Hour,
+		MinPlays: c.Resurface.MinPlays,
+		Cache:    c.SetupCache(),
+		day:      "",
+		group:    sync.WaitGroup{},
+	}
+}
+
+// Check sends a notification on the first call of each day. Suggestions are
+// rotated daily, so the same artist is not suggested every day. The history is
+// read in the background, so the main loop is not delayed.
+func (r *Resurfacer) Check(now time.Time, sinks []Sink, notifier NotifierFunc) {
+	if r == nil {
+		return
+	}
+
+	day := now.Format(time.DateOnly)
+	if r.day == day {
+		return
+	}
+	r.day = day
+
+	sink, err := FindSink(sinks, r.Sink)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("sink", r.Sink).
+			Msg("cannot find sink for listen-again reminders")
+		return
+	}
+
+	r.group.Go(func() {
+		r.suggest(now, sink, notifier)
+	})
+}
+
+// Wait returns once the history read by Check was processed.
+func (r *Resurfacer) Wait() {
+	if r != nil {
+		r.group.Wait()
+	}
+}
+
+func (r *Resurfacer) suggest(now time.Time, sink Sink, notifier NotifierFunc) {
+	scrobbles, err := CachedScrobbles(r.Cache, sink, time.Time{}, now)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("sink", sink.Name()).
+			Msg("error reading scrobbles for listen-again reminders")
+		return
+	}
+
+	candidates := Resurface(scrobbles, now, r.Age, r.MinPlays)
+	if len(candidates) == 0 {
+		log.Debug().Msg("no artists to resurface")
+		return
+	}
+
+	suggestion := candidates[int(now.Unix()/(24*60*60))%len(candidates)]
+
+	log.Info().
+		Str("artist", suggestion.Artist).
+		Int("plays", suggestion.Plays).
+		Time("last_played", suggestion.LastPlayed).
+		Msg("suggesting artist to listen to again")
+
+	if _, err := notifier(
+		uint32(0),
+		fmt.Sprintf("%c listen again: %s", RuneBeamedSixteenthNotes, suggestion.Artist),
+		suggestion.Summary(now),
+	); err != nil {
+		log.Error().
+			Err(err).
+			Msg("error sending desktop notification")
+	}
+}
@@ -0,0 +1,84 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func resurfaceScrobbles(now time.Time) []main.Scrobble {
+	var scrobbles []main.Scrobble
+	add := func(artist string, count int, ago time.Duration) {
+		for range count {
+			scrobbles = append(scrobbles, main.Scrobble{Artists: []string{artist}, Track: "Track", Timestamp: now.Add(-ago)})
+		}
+	}
+
+	add("Placebo", 12, 400*24*time.Hour)
+	add("Muse", 30, 500*24*time.Hour)
+	add("Muse", 1, 10*24*time.Hour)
+	add("Blur", 3, 600*24*time.Hour)
+	add("Pulp", 15, 700*24*time.Hour)
+
+	return scrobbles
+}
+
+func TestResurface(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+	resurfaced := main.Resurface(resurfaceScrobbles(now), now, main.DefaultResurfaceAge, main.DefaultResurfaceMinPlays)
+	require.Len(t, resurfaced, 2)
+	require.Equal(t, "Pulp", resurfaced[0].Artist)
+	require.Equal(t, 15, resurfaced[0].Plays)
+	require.Equal(t, "Placebo", resurfaced[1].Artist)
+	require.Equal(t, now.Add(-400*24*time.Hour), resurfaced[1].LastPlayed)
+	require.Equal(t, "12 scrobbles, last played 400 days ago", resurfaced[1].Summary(now))
+
+	require.Empty(t, main.Resurface(resurfaceScrobbles(now), now, 800*24*time.Hour, 1))
+}
+
+func TestResurfacer(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	sink := &main.ReplaySink{ScrobbleLog: resurfaceScrobbles(now)}
+
+	config := main.DefaultConfig
+	config.Resurface = &main.ResurfaceConfig{Sink: "replay", Days: 365, MinPlays: 10}
+	resurfacer := config.Resurfacer()
+
+	notifier := FakeNotifier{}
+	resurfacer.Check(now, []main.Sink{sink}, notifier.SendNotification)
+	resurfacer.Check(now.Add(time.Hour), []main.Sink{sink}, notifier.SendNotification)
+	resurfacer.Wait()
+	require.Equal(t, 1, notifier.Notifications)
+
+	resurfacer.Check(now.Add(24*time.Hour), []main.Sink{sink}, notifier.SendNotification)
+	resurfacer.Wait()
+	require.Equal(t, 2, notifier.Notifications)
+
+	config.Resurface = nil
+	config.Resurfacer().Check(now, []main.Sink{sink}, notifier.SendNotification)
+	config.Resurfacer().Wait()
+	require.Equal(t, 2, notifier.Notifications)
+}
+
+func TestResurfaceConfigValidate(t *testing.T) {
+	config := main.DefaultConfig
+	config.Resurface = &main.ResurfaceConfig{Sink: "", Days: 0, MinPlays: 0}
+	warnings := config.Validate()
+	require.Contains(t, warnings, main.ConfigWarning{
+		Subject: "resurface.sink=",
+		Message: "no sink for listen-again reminders specified, using `csv`",
+	})
+	require.Equal(t, "csv", config.Resurface.Sink)
+
+	config.Resurface = &main.ResurfaceConfig{Sink: "csv:default", Days: 365, MinPlays: 10}
+	config.Validate()
+	require.NotNil(t, config.Resurface)
+
+	// reading the whole history of a remote sink would take many requests
+	config.Resurface = &main.ResurfaceConfig{Sink: "lastfm:default", Days: 365, MinPlays: 10}
+	config.Validate()
+	require.Nil(t, config.Resurface)
+}
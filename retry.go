@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var DefaultRetryConfig = RetryConfig{
+	MaxAttempts:  3,
+	InitialDelay: 1,
+	MaxDelay:     10,
+	MaxAge:       30,
+}
+
+// RetryPolicy describes how often and how long a failed submission is
+// retried.
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	MaxAge       time.Duration
+}
+
+// RetryPolicyFromConfig uses the default values for all unset fields.
+func RetryPolicyFromConfig(c *RetryConfig) RetryPolicy {
+	merged := DefaultRetryConfig
+	if c != nil {
+		if c.MaxAttempts > 0 {
+			merged.MaxAttempts = c.MaxAttempts
+		}
+		if c.InitialDelay > 0 {
+			merged.InitialDelay = c.InitialDelay
+		}
+		if c.MaxDelay > 0 {
+			merged.MaxDelay = c.MaxDelay
+		}
+		if c.MaxAge > 0 {
+			merged.MaxAge = c.MaxAge
+		}
+	}
+
+	return RetryPolicy{
+		MaxAttempts:  merged.MaxAttempts,
+		InitialDelay: time.Duration(merged.InitialDelay) * time.Second,
+		MaxDelay:     time.Duration(merged.MaxDelay) * time.Second,
+		MaxAge:       time.Duration(merged.MaxAge) * time.Second,
+	}
+}
+
+// Delay returns the time to wait before the given retry (starting at 1). The
+// delay doubles with every attempt up to MaxDelay, with up to half of it
+// randomized to avoid retrying in lockstep with other clients.
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	delay := p.InitialDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+
+	//nolint:gosec
+	return half + rand.N(half)
+}
+
+// RetrySink retries failed scrobbles using exponential backoff before giving
+// up. Now playing updates are not retried, since they are outdated quickly.
+type RetrySink struct {
+	Sink
+	Policy RetryPolicy
+	Sleep  func(time.Duration)
+}
+
+// WrapSinkRetry returns the sink unchanged if only a single attempt is
+// allowed.
+func WrapSinkRetry(sink Sink, c *RetryConfig) Sink {
+	policy := RetryPolicyFromConfig(c)
+	if policy.MaxAttempts <= 1 {
+		return sink
+	}
+	return RetrySink{Sink: sink, Policy: policy, Sleep: time.Sleep}
+}
+
+func (s RetrySink) Unwrap() Sink {
+	return s.Sink
+}
+
+func (s RetrySink) Scrobble(scrobble Scrobble) error {
+	start := time.Now()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = s.Sink.Scrobble(scrobble); err == nil {
+			return nil
+		}
+
+		// retrying before the pause ends would fail immediately, and
+		// rejected scrobbles would be rejected again
+		if attempt >= s.Policy.MaxAttempts || IsRateLimited(err) || IsRejected(err) {
+			return err
+		}
+
+		delay := s.Policy.Delay(attempt)
+		if time.Since(start)+delay > s.Policy.MaxAge {
+			return err
+		}
+
+		log.Warn().
+			Err(err).
+			Str("sink", s.Name()).
+			Int("attempt", attempt).
+			Dur("delay", delay).
+			Msg("error saving scrobble, retrying")
+
+		s.Sleep(delay)
+	}
+}
@@ -0,0 +1,59 @@
+package main_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+type FlakySink struct {
+	FakeSink
+	Failures int
+	Attempts int
+}
+
+func (s *FlakySink) Scrobble(p main.Scrobble) error {
+	s.Attempts++
+	if s.Attempts <= s.Failures {
+		return errors.New("fake error")
+	}
+	return s.FakeSink.Scrobble(p)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	//nolint:exhaustruct
+	policy := main.RetryPolicyFromConfig(&main.RetryConfig{MaxAttempts: 5})
+	require.Equal(t, 5, policy.MaxAttempts)
+	require.Equal(t, time.Second, policy.InitialDelay)
+
+	for retry, maxDelay := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 10: 10 * time.Second} {
+		delay := policy.Delay(retry)
+		require.GreaterOrEqual(t, delay, maxDelay/2)
+		require.LessOrEqual(t, delay, maxDelay)
+	}
+}
+
+func TestRetrySink(t *testing.T) {
+	var slept []time.Duration
+	sleep := func(d time.Duration) { slept = append(slept, d) }
+
+	flakySink := &FlakySink{Failures: 2}
+	sink := main.RetrySink{Sink: flakySink, Policy: main.RetryPolicyFromConfig(nil), Sleep: sleep}
+
+	require.NoError(t, sink.Scrobble(defaultScrobble))
+	require.Equal(t, 3, flakySink.Attempts)
+	require.Len(t, flakySink.ScrobbleLog, 1)
+	require.Len(t, slept, 2)
+
+	flakySink = &FlakySink{Failures: 5}
+	sink.Sink = flakySink
+
+	require.Error(t, sink.Scrobble(defaultScrobble))
+	require.Equal(t, main.DefaultRetryConfig.MaxAttempts, flakySink.Attempts)
+
+	//nolint:exhaustruct
+	require.Equal(t, flakySink, main.WrapSinkRetry(flakySink, &main.RetryConfig{MaxAttempts: 1}))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ServiceNotifier implements the systemd notification protocol, so the daemon
+// can be used with `Type=notify` and `WatchdogSec=`. All methods do nothing if
+// goscrobble was not started by systemd.
+//
+// https://www.freedesktop.org/software/systemd/man/latest/sd_notify.html
+type ServiceNotifier struct {
+	Socket   string
+	Watchdog time.Duration
+
+	lastPing time.Time
+	status   string
+}
+
+func NewServiceNotifier() *ServiceNotifier {
+	notifier := &ServiceNotifier{
+		Socket:   os.Getenv("NOTIFY_SOCKET"),
+		Watchdog: 0,
+		lastPing: time.Time{},
+		status:   "",
+	}
+
+	// the watchdog may be meant for a different process (e.g., a wrapper script)
+	pid := os.Getenv("WATCHDOG_PID")
+	if pid == "" || pid == strconv.Itoa(os.Getpid()) {
+		if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+			notifier.Watchdog = time.Duration(usec) * time.Microsecond
+		}
+	}
+
+	return notifier
+}
+
+func (n *ServiceNotifier) Notify(state string) error {
+	if n.Socket == "" {
+		return nil
+	}
+
+	// names starting with @ are abstract sockets, which are handled by net
+	conn, err := net.Dial("unixgram", n.Socket)
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(conn)
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+func (n *ServiceNotifier) Ready() {
+	if err := n.Notify("READY=1"); err != nil {
+		log.Error().
+			Err(err).
+			Msg("error notifying service manager")
+	}
+}
+
+// SetStatus updates the status shown by `systemctl status`. Unchanged status
+// lines are not sent again.
+func (n *ServiceNotifier) SetStatus(status string) {
+	if status == n.status {
+		return
+	}
+	n.status = status
+
+	if err := n.Notify("STATUS=" + status); err != nil {
+		log.Error().
+			Err(err).
+			Msg("error notifying service manager")
+	}
+}
+
+// Keepalive pings the watchdog. It is called on every main loop iteration and
+// every KeepaliveInterval while the loop waits, so the daemon is restarted if
+// the loop hangs, but only pings at half the watchdog interval.
+func (n *ServiceNotifier) Keepalive(now time.Time) {
+	if n.Watchdog == 0 || now.Sub(n.lastPing) < n.Watchdog/2 {
+		return
+	}
+	n.lastPing = now
+
+	if err := n.Notify("WATCHDOG=1"); err != nil {
+		log.Error().
+			Err(err).
+			Msg("error notifying service manager")
+	}
+}
+
+// KeepaliveInterval returns how often the main loop has to wake up to ping
+// the watchdog, or zero if there is no watchdog. It is well below half the
+// watchdog interval, so a wakeup that comes slightly early and is skipped by
+// Keepalive does not let the watchdog expire.
+func (n *ServiceNotifier) KeepaliveInterval() time.Duration {
+	return n.Watchdog / 4
+}
+
+// ServiceStatus describes the current playback for the service manager.
+func ServiceStatus(playbackStatus map[string]PlaybackStatus) string {
+	player, status, ok := KioskPlayer(playbackStatus)
+	if !ok || status.State != PlaybackPlaying {
+		return "nothing playing"
+	}
+	return "now playing: " + status.JoinArtists() + " " + string(RuneEmDash) + " " + status.Track + " (" + player + ")"
+}
@@ -0,0 +1,61 @@
+package main_test
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceNotifier(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+
+	conn, err := net.ListenPacket("unixgram", socket)
+	require.NoError(t, err)
+	defer main.CloseLogged(conn)
+
+	received := func() string {
+		buffer := make([]byte, 1024)
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := conn.ReadFrom(buffer)
+		require.NoError(t, err)
+		return string(buffer[:n])
+	}
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+	t.Setenv("WATCHDOG_USEC", "10000000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	notifier := main.NewServiceNotifier()
+	require.Equal(t, 10*time.Second, notifier.Watchdog)
+	require.Equal(t, 2500*time.Millisecond, notifier.KeepaliveInterval())
+
+	notifier.Ready()
+	require.Equal(t, "READY=1", received())
+
+	notifier.SetStatus("nothing playing")
+	notifier.SetStatus("nothing playing")
+	notifier.SetStatus(main.ServiceStatus(map[string]main.PlaybackStatus{"spotify": defaultPlaybackStatus}))
+	require.Equal(t, "STATUS=nothing playing", received())
+	require.Equal(t, "STATUS=now playing: Placebo, David Bowie — Without You I'm Nothing (spotify)", received())
+
+	now := time.Now()
+	notifier.Keepalive(now)
+	notifier.Keepalive(now.Add(time.Second))
+	notifier.Keepalive(now.Add(6 * time.Second))
+	require.Equal(t, "WATCHDOG=1", received())
+	require.Equal(t, "WATCHDOG=1", received())
+}
+
+func TestServiceNotifierDisabled(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	t.Setenv("WATCHDOG_USEC", "")
+
+	notifier := main.NewServiceNotifier()
+	require.Equal(t, time.Duration(0), notifier.Watchdog)
+	require.Equal(t, time.Duration(0), notifier.KeepaliveInterval())
+	require.NoError(t, notifier.Notify("READY=1"))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SearchFields are the field filters supported by search queries, e.g.
+// `artist:placebo`.
+var SearchFields = []string{"artist", "track", "album"}
+
+type searchTerm struct {
+	// empty if the term matches any field
+	field   string
+	pattern *regexp.Regexp
+}
+
+// SearchQuery matches scrobbles against all of its terms.
+type SearchQuery struct {
+	terms []searchTerm
+}
+
+// ParseSearchQuery parses the terms of a search query. A term prefixed with
+// one of SearchFields only matches that field, all other terms match the
+// artists, track, or album. Terms match case-insensitively, either as plain
+// text or, if regex is set, as regular expressions.
+func ParseSearchQuery(terms []string, regex bool) (SearchQuery, error) {
+	var query SearchQuery
+	for _, term := range terms {
+		field := ""
+		if prefix, value, ok := strings.Cut(term, ":"); ok && slices.Contains(SearchFields, strings.ToLower(prefix)) {
+			field = strings.ToLower(prefix)
+			term = value
+		}
+
+		if !regex {
+			term = regexp.QuoteMeta(term)
+		}
+		pattern, err := regexp.Compile("(?i)" + term)
+		if err != nil {
+			return SearchQuery{}, fmt.Errorf("invalid search term: %s", err.Error())
+		}
+		query.terms = append(query.terms, searchTerm{field: field, pattern: pattern})
+	}
+	return query, nil
+}
+
+// Match reports whether the scrobble matches all terms. An empty query
+// matches every scrobble.
+func (q SearchQuery) Match(scrobble Scrobble) bool {
+	for _, term := range q.terms {
+		if !term.match(scrobble) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t searchTerm) match(scrobble Scrobble) bool {
+	artists := slices.ContainsFunc(scrobble.Artists, t.pattern.MatchString)
+	switch t.field {
+	case "artist":
+		return artists
+	case "track":
+		return t.pattern.MatchString(scrobble.Track)
+	case "album":
+		return t.pattern.MatchString(scrobble.Album)
+	default:
+		return artists || t.pattern.MatchString(scrobble.Track) || t.pattern.MatchString(scrobble.Album)
+	}
+}
+
+// SearchScrobbles returns the scrobbles matching the query, newest first. If
+// limit is positive, at most limit scrobbles are returned.
+func SearchScrobbles(scrobbles []Scrobble, query SearchQuery, limit int) []Scrobble {
+	var matches []Scrobble
+	for _, scrobble := range scrobbles {
+		if query.Match(scrobble) {
+			matches = append(matches, scrobble)
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b Scrobble) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
@@ -0,0 +1,87 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	search := func(terms []string, regex bool) bool {
+		query, err := main.ParseSearchQuery(terms, regex)
+		require.NoError(t, err)
+		return query.Match(defaultScrobble)
+	}
+
+	require.True(t, search(nil, false))
+	require.True(t, search([]string{"bowie"}, false))
+	require.True(t, search([]string{"nothing"}, false))
+	require.True(t, search([]string{"artist:placebo", "track:without you"}, false))
+	require.True(t, search([]string{"Artist:David Bowie"}, false))
+	require.False(t, search([]string{"track:placebo"}, false))
+	require.False(t, search([]string{"placebo", "pure morning"}, false))
+
+	// regular expressions are only used with regex
+	require.False(t, search([]string{"track:^without"}, false))
+	require.True(t, search([]string{"track:^without"}, true))
+	require.True(t, search([]string{"artist:^(placebo|muse)$"}, true))
+	require.False(t, search([]string{"artist:^(muse|radiohead)$"}, true))
+
+	// unknown prefixes are part of the search term
+	track := defaultScrobble
+	track.Track = "Re: Stacks"
+	query, err := main.ParseSearchQuery([]string{"re: stacks"}, false)
+	require.NoError(t, err)
+	require.True(t, query.Match(track))
+
+	_, err = main.ParseSearchQuery([]string{"track:("}, true)
+	require.Error(t, err)
+}
+
+func TestSearchScrobbles(t *testing.T) {
+	at := func(track string, offset time.Duration) main.Scrobble {
+		scrobble := defaultScrobble
+		scrobble.Track = track
+		scrobble.Timestamp = defaultScrobble.Timestamp.Add(offset)
+		return scrobble
+	}
+	scrobbles := []main.Scrobble{
+		at("Pure Morning", 0),
+		at("Without You I'm Nothing", time.Hour),
+		at("Every You Every Me", 2*time.Hour),
+		at("Without You I'm Nothing", 3*time.Hour),
+	}
+
+	query, err := main.ParseSearchQuery([]string{"track:without you"}, false)
+	require.NoError(t, err)
+
+	matches := main.SearchScrobbles(scrobbles, query, 0)
+	require.Len(t, matches, 2)
+	require.True(t, defaultScrobble.Timestamp.Add(3*time.Hour).Equal(matches[0].Timestamp))
+
+	matches = main.SearchScrobbles(scrobbles, query, 1)
+	require.Len(t, matches, 1)
+	require.True(t, defaultScrobble.Timestamp.Add(3*time.Hour).Equal(matches[0].Timestamp))
+}
+
+func TestSearchScrobblesRange(t *testing.T) {
+	sink := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv")}
+	for hours := range 3 {
+		scrobble := defaultScrobble
+		scrobble.Timestamp = defaultScrobble.Timestamp.Add(time.Duration(hours) * time.Hour)
+		require.NoError(t, sink.Scrobble(scrobble))
+	}
+
+	query, err := main.ParseSearchQuery([]string{"track:without you"}, false)
+	require.NoError(t, err)
+
+	// matches after the end of the range are skipped
+	scrobbles, err := sink.GetScrobbles(0, defaultScrobble.Timestamp, defaultScrobble.Timestamp.Add(time.Hour))
+	require.NoError(t, err)
+	matches := main.SearchScrobbles(scrobbles, query, 0)
+	require.Len(t, matches, 2)
+	require.True(t, defaultScrobble.Timestamp.Add(time.Hour).Equal(matches[0].Timestamp))
+}
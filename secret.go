@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// secret commands may ask for a passphrase (e.g., gpg-agent for pass), so
+// they get more time than other commands
+const secretCommandTimeout = time.Minute
+
+// RunSecretCommand runs a command printing a secret, e.g. `pass show
+// lastfm/api-key`, and returns the first line of its output. Like pass, most
+// password managers print the secret on the first line.
+func RunSecretCommand(command []string) (string, error) {
+	if len(command) == 0 {
+		return "", errors.New("empty command")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	//nolint:gosec
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s: %s", err.Error(), message)
+		}
+		return "", err
+	}
+
+	secret, _, _ := strings.Cut(string(output), "\n")
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", errors.New("command printed no secret")
+	}
+	return secret, nil
+}
+
+// ResolveSecretCommands runs the commands of all secrets set with a `_cmd`
+// option (e.g., `secret_cmd`) and replaces the secrets with their output. A
+// command takes precedence over a secret in the config file.
+func (c *Config) ResolveSecretCommands() error {
+	return c.eachSecret(func(subject string, command []string, secret *string) error {
+		if len(command) == 0 {
+			return nil
+		}
+
+		resolved, err := RunSecretCommand(command)
+		if err != nil {
+			return fmt.Errorf("error running %s_cmd: %s", subject, err.Error())
+		}
+		*secret = resolved
+		return nil
+	})
+}
+
+// persisted returns a copy of the config as it is written to the config
+// file: secrets read from commands are left out, and secrets stored in the
+// system keyring are replaced by their reference.
+func (c Config) persisted() Config {
+	_ = c.eachSecret(func(subject string, command []string, secret *string) error {
+		account, inKeyring := c.keyringSecrets[subject]
+		switch {
+		case len(command) > 0:
+			*secret = ""
+		case inKeyring:
+			*secret = KeyringPrefix + account
+		}
+		return nil
+	})
+	return c
+}
+
+// eachSecret calls f with the name of every secret (e.g.,
+// `sinks.lastfm.default.secret`), the command it is read from, if any, and
+// the secret. Changes to the secret are kept. Maps and pointers are copied
+// first, so copies of the config are not changed.
+func (c *Config) eachSecret(f func(subject string, command []string, secret *string) error) error {
+	var errs []error
+	call := func(subject string, command []string, secret *string) {
+		if err := f(subject, command, secret); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if c.API != nil {
+		api := *c.API
+		call("api.token", api.TokenCmd, &api.Token)
+		c.API = &api
+	}
+
+	if c.Events != nil {
+		events := *c.Events
+		if events.Webhook != nil {
+			webhook := *events.Webhook
+			call("events.webhook.token", webhook.TokenCmd, &webhook.Token)
+			events.Webhook = &webhook
+		}
+		if events.MQTT != nil {
+			mqtt := *events.MQTT
+			call("events.mqtt.password", mqtt.PasswordCmd, &mqtt.Password)
+			events.MQTT = &mqtt
+		}
+		c.Events = &events
+	}
+
+	c.Sources = c.Sources.eachSecret("sources", call)
+	c.Sinks = c.Sinks.eachSecret("sinks", call)
+
+	c.Users = maps.Clone(c.Users)
+	for _, name := range slices.Sorted(maps.Keys(c.Users)) {
+		user := c.Users[name]
+		user.Sources = user.Sources.eachSecret("users."+name+".sources", call)
+		user.Sinks = user.Sinks.eachSecret("users."+name+".sinks", call)
+		c.Users[name] = user
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s SourcesConfig) eachSecret(prefix string, call func(string, []string, *string)) SourcesConfig {
+	if s.Webhook != nil {
+		webhook := *s.Webhook
+		call(prefix+".webhook.token", webhook.TokenCmd, &webhook.Token)
+		s.Webhook = &webhook
+	}
+	return s
+}
+
+func (s SinksConfig) eachSecret(prefix string, call func(string, []string, *string)) SinksConfig {
+	s.LastFm = maps.Clone(s.LastFm)
+	for _, key := range slices.Sorted(maps.Keys(s.LastFm)) {
+		sinkConfig := s.LastFm[key]
+		subject := prefix + ".lastfm." + key
+		call(subject+".key", sinkConfig.KeyCmd, &sinkConfig.Key)
+		call(subject+".secret", sinkConfig.SecretCmd, &sinkConfig.Secret)
+		call(subject+".session_key", sinkConfig.SessionKeyCmd, &sinkConfig.SessionKey)
+		s.LastFm[key] = sinkConfig
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSecretCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	secret, err := main.RunSecretCommand([]string{"sh", "-c", `printf 'secret\nurl: https://last.fm\n'`})
+	require.NoError(t, err)
+	require.Equal(t, "secret", secret)
+
+	_, err = main.RunSecretCommand([]string{"sh", "-c", "echo 'not in the password store' >&2; exit 1"})
+	require.ErrorContains(t, err, "not in the password store")
+
+	_, err = main.RunSecretCommand([]string{"true"})
+	require.Error(t, err)
+
+	_, err = main.RunSecretCommand(nil)
+	require.Error(t, err)
+}
+
+func TestSecretCommandsInConfigFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	filename := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(filename, []byte(`
+[api]
+token_cmd = ["echo", "api token"]
+
+[sinks.lastfm.default]
+key = "key"
+secret_cmd = ["echo", "secret"]
+session_key_cmd = ["echo", "session key"]
+username = "user"
+
+[users.guest.sinks.lastfm.default]
+key_cmd = ["echo", "guest key"]
+`), 0600))
+
+	config, err := main.ReadConfig(filename)
+	require.NoError(t, err)
+	require.Equal(t, "api token", config.API.Token)
+	require.Equal(t, "key", config.Sinks.LastFm["default"].Key)
+	require.Equal(t, "secret", config.Sinks.LastFm["default"].Secret)
+	require.Equal(t, "session key", config.Sinks.LastFm["default"].SessionKey)
+	require.Equal(t, "guest key", config.Users["guest"].Sinks.LastFm["default"].Key)
+	require.Empty(t, config.Lint(true))
+
+	// secrets read from commands are never written to the config file
+	require.NoError(t, config.Write(filename))
+	written, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Contains(t, string(written), "\nsecret = \"\"\n")
+	require.Contains(t, string(written), "\nsession_key = \"\"\n")
+	require.Contains(t, string(written), `secret_cmd = ["echo", "secret"]`)
+	require.Equal(t, "secret", config.Sinks.LastFm["default"].Secret)
+
+	require.NoError(t, os.WriteFile(filename, []byte(`
+[sinks.lastfm.default]
+secret_cmd = ["false"]
+`), 0600))
+	_, err = main.ReadConfig(filename)
+	require.ErrorContains(t, err, "sinks.lastfm.default.secret_cmd")
+}
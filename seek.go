@@ -0,0 +1,53 @@
+package main
+
+import "time"
+
+const (
+	// a track that jumps back to a position below this counts as started
+	// again
+	restartPosition = 15 * time.Second
+	// allows for jitter in the positions reported by players
+	seekTolerance = 3 * time.Second
+)
+
+// IsRestart reports whether a track started again, either because it is on
+// repeat or because it was skipped back to the start: the position of the
+// same track jumped back to the start.
+func IsRestart(last, current PlaybackStatus) bool {
+	return current.State == PlaybackPlaying &&
+		last.Equals(current) &&
+		current.Position < restartPosition &&
+		last.Position-current.Position >= restartPosition
+}
+
+// PlayedBetween returns how much of a track was played between two polls.
+// Time between two polls at which the track was paused does not count, even if
+// the player keeps advancing the position while paused. Jumps forward by more
+// than maxAdvance are seeks and jumps backward are rewinds, neither counts as
+// played. A maxAdvance of zero accepts any jump forward.
+func PlayedBetween(last, current PlaybackStatus, maxAdvance time.Duration) time.Duration {
+	if !last.Equals(current) || !playedBetween(last, current) {
+		return 0
+	}
+
+	played := current.Position - last.Position
+	if played <= 0 || (maxAdvance > 0 && played > maxAdvance) {
+		return 0
+	}
+	return played
+}
+
+// maxAdvance returns how far the position of a playing track can advance
+// between two polls, or zero if the poll interval is unknown.
+func (o LoopOptions) maxAdvance(elapsed time.Duration) time.Duration {
+	if o.PollInterval <= 0 {
+		return 0
+	}
+	return max(elapsed, o.PollInterval) + seekTolerance
+}
+
+// playedBetween reports whether the track played at some point between two
+// polls of the same player.
+func playedBetween(last, current PlaybackStatus) bool {
+	return last.State == PlaybackPlaying || current.State == PlaybackPlaying
+}
@@ -0,0 +1,134 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRestart(t *testing.T) {
+	last := defaultPlaybackStatus
+	last.Position = 249 * time.Second
+	current := defaultPlaybackStatus
+	current.Position = 1 * time.Second
+	require.True(t, main.IsRestart(last, current))
+
+	// skipping back to the start
+	last.Position = 60 * time.Second
+	require.True(t, main.IsRestart(last, current))
+
+	// small jumps back at the start of the track
+	last.Position = 10 * time.Second
+	require.False(t, main.IsRestart(last, current))
+
+	// seeking back to the middle of the track
+	last.Position = 249 * time.Second
+	current.Position = 100 * time.Second
+	require.False(t, main.IsRestart(last, current))
+
+	// another track
+	current.Position = 1 * time.Second
+	current.Track = "Pure Morning"
+	require.False(t, main.IsRestart(last, current))
+}
+
+func TestPlayedBetween(t *testing.T) {
+	last := defaultPlaybackStatus
+	last.Position = 10 * time.Second
+	current := defaultPlaybackStatus
+	current.Position = 12 * time.Second
+
+	require.Equal(t, 2*time.Second, main.PlayedBetween(last, current, 5*time.Second))
+
+	current.Position = 240 * time.Second
+	require.Zero(t, main.PlayedBetween(last, current, 5*time.Second))
+	require.Equal(t, 230*time.Second, main.PlayedBetween(last, current, 0))
+
+	current.Position = 5 * time.Second
+	require.Zero(t, main.PlayedBetween(last, current, 5*time.Second))
+
+	// paused intervals do not count, even if the position advances
+	current.Position = 12 * time.Second
+	last.State = main.PlaybackPaused
+	current.State = main.PlaybackPaused
+	require.Zero(t, main.PlayedBetween(last, current, 5*time.Second))
+
+	// the track played until it was paused or after it was resumed
+	current.State = main.PlaybackPlaying
+	require.Equal(t, 2*time.Second, main.PlayedBetween(last, current, 5*time.Second))
+}
+
+func TestLoopPause(t *testing.T) {
+	state := main.NewLoopState()
+	sink := &FakeSink{}
+	notifier := FakeNotifier{}
+
+	run := func(playbackState main.PlaybackState, position time.Duration) {
+		status := defaultPlaybackStatus
+		status.State = playbackState
+		status.Position = position
+		source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+		main.RunMainLoopOnce(state, replayOptions(), []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	}
+
+	// a player that keeps advancing the position while paused for an hour
+	run(main.PlaybackPlaying, 0)
+	run(main.PlaybackPlaying, 10*time.Second)
+	run(main.PlaybackPaused, 20*time.Second)
+	run(main.PlaybackPaused, time.Hour)
+	run(main.PlaybackPlaying, time.Hour+10*time.Second)
+	require.Empty(t, sink.ScrobbleLog)
+	require.Equal(t, 30*time.Second, state.PlayedTime["player"])
+}
+
+func TestLoopRepeat(t *testing.T) {
+	state := main.NewLoopState()
+	sink := &FakeSink{}
+	notifier := FakeNotifier{}
+
+	run := func(position time.Duration) {
+		status := defaultPlaybackStatus
+		status.Position = position
+		source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+		main.RunMainLoopOnce(state, replayOptions(), []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	}
+
+	for range 3 {
+		run(0)
+		run(200 * time.Second)
+		run(249 * time.Second)
+	}
+
+	require.Len(t, sink.ScrobbleLog, 3)
+	require.Len(t, sink.NowPlayingLog, 3)
+	require.Equal(t, 3, state.Stats.TracksSeen)
+}
+
+func TestLoopSeek(t *testing.T) {
+	state := main.NewLoopState()
+	options := replayOptions()
+	options.PollInterval = 2 * time.Second
+	sink := &FakeSink{}
+	notifier := FakeNotifier{}
+
+	run := func(position time.Duration) {
+		status := defaultPlaybackStatus
+		status.Position = position
+		source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+		main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	}
+
+	// seeking to the end does not count as played
+	run(0)
+	run(2 * time.Second)
+	run(240 * time.Second)
+	run(242 * time.Second)
+	require.Empty(t, sink.ScrobbleLog)
+	require.Equal(t, 4*time.Second, state.PlayedTime["player"])
+
+	// skipping back to the start is a new play
+	run(1 * time.Second)
+	require.Equal(t, 2, state.Stats.TracksSeen)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/template"
+)
+
+const (
+	ServiceName  = "goscrobble"
+	ServiceLabel = "io.github.p-mng.goscrobble"
+)
+
+// these mirror scripts/goscrobble.service and scripts/io.github.p-mng.goscrobble.plist
+var (
+	systemdUnitTemplate = template.Must(template.New("systemd").Parse(`[Unit]
+Description=A simple, cross-platform music scrobbler daemon
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=notify
+ExecStart="{{.Executable}}" --config "{{.Config}}" run
+ExecReload=/bin/kill -HUP $MAINPID
+WatchdogSec=120
+Restart=on-failure
+
+[Install]
+WantedBy=default.target
+`))
+	launchdPlistTemplate = template.Must(template.New("launchd").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+  <dict>
+    <key>Label</key>
+    <string>{{.Label}}</string>
+    <key>ProgramArguments</key>
+    <array>
+      <string>{{.Executable}}</string>
+      <string>--config</string>
+      <string>{{.Config}}</string>
+      <string>run</string>
+    </array>
+    <key>EnvironmentVariables</key>
+    <dict>
+      <key>PATH</key>
+      <!-- default: /usr/bin:/bin:/usr/sbin:/sbin -->
+      <string>/opt/homebrew/bin:/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin</string>
+    </dict>
+    <key>RunAtLoad</key>
+    <true/>
+    <key>KeepAlive</key>
+    <false/>
+    <key>StandardOutPath</key>
+    <string>{{.Home}}/Library/Logs/goscrobble.log</string>
+    <key>StandardErrorPath</key>
+    <string>{{.Home}}/Library/Logs/goscrobble.error.log</string>
+  </dict>
+</plist>
+`))
+)
+
+// ServiceDefinition describes how to register goscrobble for autostart with
+// the service manager of an operating system.
+type ServiceDefinition struct {
+	// file to write Content to, empty if the service manager does not use one
+	Filename  string
+	Content   string
+	Install   [][]string
+	Uninstall [][]string
+	Status    []string
+}
+
+type serviceTemplateData struct {
+	Executable string
+	Config     string
+	Label      string
+	Home       string
+}
+
+// NewServiceDefinition builds the service definition for the given operating
+// system, running the given executable with the given config file.
+func NewServiceDefinition(goos, executable, config, home string) (ServiceDefinition, error) {
+	data := serviceTemplateData{Executable: executable, Config: config, Label: ServiceLabel, Home: home}
+
+	switch goos {
+	case "linux":
+		content, err := executeServiceTemplate(systemdUnitTemplate, data)
+		if err != nil {
+			return ServiceDefinition{}, err
+		}
+
+		unit := ServiceName + ".service"
+		return ServiceDefinition{
+			Filename: filepath.Join(home, ".config", "systemd", "user", unit),
+			Content:  content,
+			Install: [][]string{
+				{"systemctl", "--user", "daemon-reload"},
+				{"systemctl", "--user", "enable", "--now", unit},
+			},
+			Uninstall: [][]string{
+				{"systemctl", "--user", "disable", "--now", unit},
+			},
+			Status: []string{"systemctl", "--user", "status", unit},
+		}, nil
+	case "darwin":
+		content, err := executeServiceTemplate(launchdPlistTemplate, data)
+		if err != nil {
+			return ServiceDefinition{}, err
+		}
+
+		filename := filepath.Join(home, "Library", "LaunchAgents", ServiceLabel+".plist")
+		return ServiceDefinition{
+			Filename:  filename,
+			Content:   content,
+			Install:   [][]string{{"launchctl", "load", "-w", filename}},
+			Uninstall: [][]string{{"launchctl", "unload", "-w", filename}},
+			Status:    []string{"launchctl", "list", ServiceLabel},
+		}, nil
+	case "windows":
+		// sources need access to the user session, so goscrobble is started on
+		// logon using the task scheduler instead of running as a system service
+		command := fmt.Sprintf(`"%s" --config "%s" run`, executable, config)
+		return ServiceDefinition{
+			Filename: "",
+			Content:  "",
+			Install: [][]string{
+				{"schtasks", "/Create", "/F", "/SC", "ONLOGON", "/TN", ServiceName, "/TR", command},
+				{"schtasks", "/Run", "/TN", ServiceName},
+			},
+			Uninstall: [][]string{
+				{"schtasks", "/End", "/TN", ServiceName},
+				{"schtasks", "/Delete", "/F", "/TN", ServiceName},
+			},
+			Status: []string{"schtasks", "/Query", "/V", "/FO", "LIST", "/TN", ServiceName},
+		}, nil
+	default:
+		return ServiceDefinition{}, fmt.Errorf("unsupported platform: %s", goos)
+	}
+}
+
+// NewWindowsServiceDefinition registers goscrobble as a native Windows
+// service, which starts at boot without a user session and logs to the event
+// log. Registering it requires administrator rights.
+func NewWindowsServiceDefinition(executable, config string) ServiceDefinition {
+	command := fmt.Sprintf(`"%s" --config "%s" run`, executable, config)
+	return ServiceDefinition{
+		Filename: "",
+		Content:  "",
+		Install: [][]string{
+			{"sc.exe", "create", ServiceName, "binPath=", command, "start=", "auto", "DisplayName=", ServiceName},
+			{"sc.exe", "start", ServiceName},
+		},
+		// a running service is removed once it stopped
+		Uninstall: [][]string{
+			{"sc.exe", "delete", ServiceName},
+			{"sc.exe", "stop", ServiceName},
+		},
+		Status: []string{"sc.exe", "query", ServiceName},
+	}
+}
+
+func executeServiceTemplate(t *template.Template, data serviceTemplateData) (string, error) {
+	var buffer bytes.Buffer
+	if err := t.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+func (d ServiceDefinition) InstallService() error {
+	if d.Filename != "" {
+		if err := os.MkdirAll(filepath.Dir(d.Filename), 0755); err != nil {
+			return err
+		}
+		//nolint:gosec
+		if err := os.WriteFile(d.Filename, []byte(d.Content), 0644); err != nil {
+			return err
+		}
+		fmt.Println("Wrote", d.Filename)
+	}
+
+	return runServiceCommands(d.Install)
+}
+
+func (d ServiceDefinition) UninstallService() error {
+	// the service may already be stopped or not be registered at all
+	if err := runServiceCommands(d.Uninstall); err != nil {
+		fmt.Println("Warning:", err.Error())
+	}
+
+	if d.Filename != "" {
+		if err := os.Remove(d.Filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		fmt.Println("Removed", d.Filename)
+	}
+
+	return nil
+}
+
+func (d ServiceDefinition) PrintStatus() error {
+	return runServiceCommands([][]string{d.Status})
+}
+
+func runServiceCommands(commands [][]string) error {
+	for _, command := range commands {
+		//nolint:gosec
+		cmd := exec.Command(command[0], command[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("error running %s: %w", command[0], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package main_test
+
+import (
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServiceDefinition(t *testing.T) {
+	definition, err := main.NewServiceDefinition("linux", "/usr/bin/goscrobble", "/home/user/.config/goscrobble/config.toml", "/home/user")
+	require.NoError(t, err)
+	require.Equal(t, "/home/user/.config/systemd/user/goscrobble.service", definition.Filename)
+	require.Contains(t, definition.Content,
+		`ExecStart="/usr/bin/goscrobble" --config "/home/user/.config/goscrobble/config.toml" run`)
+
+	definition, err = main.NewServiceDefinition("darwin", "/opt/homebrew/bin/goscrobble", "/Users/user/config.toml", "/Users/user")
+	require.NoError(t, err)
+	require.Equal(t, "/Users/user/Library/LaunchAgents/io.github.p-mng.goscrobble.plist", definition.Filename)
+	require.Contains(t, definition.Content, "<string>/Users/user/config.toml</string>")
+	require.Contains(t, definition.Content, "<string>/Users/user/Library/Logs/goscrobble.log</string>")
+
+	definition, err = main.NewServiceDefinition("windows", `C:\goscrobble.exe`, `C:\config.toml`, `C:\Users\user`)
+	require.NoError(t, err)
+	require.Empty(t, definition.Filename)
+	require.Contains(t, definition.Install[0], `"C:\goscrobble.exe" --config "C:\config.toml" run`)
+
+	_, err = main.NewServiceDefinition("plan9", "/bin/goscrobble", "/config.toml", "/")
+	require.Error(t, err)
+}
+
+func TestWindowsServiceDefinition(t *testing.T) {
+	definition := main.NewWindowsServiceDefinition(`C:\goscrobble.exe`, `C:\config.toml`)
+	require.Empty(t, definition.Filename)
+	require.Contains(t, definition.Install[0], `"C:\goscrobble.exe" --config "C:\config.toml" run`)
+	require.Equal(t, []string{"sc.exe", "query", main.ServiceName}, definition.Status)
+}
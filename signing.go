@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	SigningKeyFileName = "signing.key"
+	PublicKeyFileName  = "signing.pub"
+)
+
+// number of columns of a scrobble record, without provenance and signature
+const unsignedRecordColumns = 5
+
+// Provenance describes where and when an archived scrobble was recorded.
+type Provenance struct {
+	Host   string
+	Sink   string
+	Signed time.Time
+}
+
+func (p Provenance) String() string {
+	return fmt.Sprintf("host=%s;sink=%s;signed=%s", p.Host, p.Sink, p.Signed.UTC().Format(time.RFC3339))
+}
+
+func SigningKeyFilename() string {
+	return filepath.Join(StateDir(), SigningKeyFileName)
+}
+
+func PublicKeyFilename() string {
+	return filepath.Join(StateDir(), PublicKeyFileName)
+}
+
+// LoadSigningKey reads the ed25519 signing key from the state directory. A new
+// key pair is generated on first use, with the public key stored next to it.
+func LoadSigningKey() (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(SigningKeyFilename())
+	if os.IsNotExist(err) {
+		return generateSigningKey()
+	} else if err != nil {
+		return nil, err
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("invalid signing key")
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func generateSigningKey() (ed25519.PrivateKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(StateDir(), 0700); err != nil {
+		return nil, err
+	}
+
+	encodedSeed := base64.StdEncoding.EncodeToString(privateKey.Seed()) + "\n"
+	if err := os.WriteFile(SigningKeyFilename(), []byte(encodedSeed), 0600); err != nil {
+		return nil, err
+	}
+
+	encodedPublicKey := base64.StdEncoding.EncodeToString(publicKey) + "\n"
+	if err := os.WriteFile(PublicKeyFilename(), []byte(encodedPublicKey), 0600); err != nil {
+		return nil, err
+	}
+
+	return privateKey, nil
+}
+
+func LoadPublicKey(filename string) (ed25519.PublicKey, error) {
+	//nolint:gosec
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key")
+	}
+
+	return publicKey, nil
+}
+
+// SignRecord appends the provenance and a signature over all columns
+// (including the provenance) to a scrobble record.
+func SignRecord(key ed25519.PrivateKey, record []string, provenance Provenance) []string {
+	signed := append(slices.Clone(record), provenance.String())
+	signature := ed25519.Sign(key, signedMessage(signed))
+	return append(signed, base64.StdEncoding.EncodeToString(signature))
+}
+
+// VerifyRecord checks the signature of a signed scrobble record.
+func VerifyRecord(publicKey ed25519.PublicKey, record []string) error {
+	if len(record) != unsignedRecordColumns+2 {
+		return errors.New("record is not signed")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(record[len(record)-1])
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, signedMessage(record[:len(record)-1]), signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// signedMessage prefixes every column with its length, so moving text from one
+// column to the next changes the message.
+func signedMessage(columns []string) []byte {
+	var message []byte
+	for _, column := range columns {
+		message = binary.AppendUvarint(message, uint64(len(column)))
+		message = append(message, column...)
+	}
+	return message
+}
+
+type ArchiveVerification struct {
+	Valid    int
+	Unsigned int
+	// line numbers of records with invalid signatures
+	Invalid []int
+}
+
+// VerifyArchive checks the signatures of all records in a (possibly
+// compressed) CSV sink file.
+func VerifyArchive(filename string, publicKey ed25519.PublicKey) (ArchiveVerification, error) {
+	file, err := OpenCompressed(filename)
+	if err != nil {
+		return ArchiveVerification{}, err
+	}
+	defer CloseLogged(file)
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	result := ArchiveVerification{Valid: 0, Unsigned: 0, Invalid: []int{}}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return ArchiveVerification{}, err
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		switch {
+		case IsTombstoneRecord(record):
+			continue
+		case len(record) == unsignedRecordColumns:
+			result.Unsigned++
+		case VerifyRecord(publicKey, record) == nil:
+			result.Valid++
+		default:
+			result.Invalid = append(result.Invalid, line)
+		}
+	}
+
+	return result, nil
+}
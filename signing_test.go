@@ -0,0 +1,108 @@
+package main_test
+
+import (
+	"crypto/ed25519"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedCSVSink(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	filename := filepath.Join(t.TempDir(), "scrobbles.csv")
+
+	unsigned, err := main.CSVSinkFromConfig("default", main.CSVConfig{Filename: filename, Sign: false})
+	require.NoError(t, err)
+	require.NoError(t, unsigned.Scrobble(defaultScrobble))
+
+	signed, err := main.CSVSinkFromConfig("default", main.CSVConfig{Filename: filename, Sign: true})
+	require.NoError(t, err)
+	require.NoError(t, signed.Scrobble(defaultScrobble))
+	require.NoError(t, signed.Scrobble(defaultScrobble))
+
+	scrobbles, err := signed.GetScrobbles(0, defaultScrobble.Timestamp.Add(-1), defaultScrobble.Timestamp.Add(1))
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 3)
+
+	publicKey, err := main.LoadPublicKey(main.PublicKeyFilename())
+	require.NoError(t, err)
+
+	result, err := main.VerifyArchive(filename, publicKey)
+	require.NoError(t, err)
+	require.Equal(t, main.ArchiveVerification{Valid: 2, Unsigned: 1, Invalid: []int{}}, result)
+
+	//nolint:gosec
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filename, []byte(string(data[:len(data)-2])+"A\n"), 0600))
+
+	result, err = main.VerifyArchive(filename, publicKey)
+	require.NoError(t, err)
+	require.Equal(t, []int{3}, result.Invalid)
+}
+
+func TestSignedCSVSinkReplaceScrobbles(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	filename := filepath.Join(t.TempDir(), "scrobbles.csv")
+	sink, err := main.CSVSinkFromConfig("default", main.CSVConfig{Filename: filename, Sign: true})
+	require.NoError(t, err)
+
+	// a record signed on another host
+	signingKey, err := main.LoadSigningKey()
+	require.NoError(t, err)
+	provenance := main.Provenance{Host: "other-host", Sink: "csv:default", Signed: defaultScrobble.Timestamp}
+	record := main.SignRecord(signingKey, defaultScrobble.ToStringSlice(), provenance)
+	file, err := os.Create(filename)
+	require.NoError(t, err)
+	require.NoError(t, csv.NewWriter(file).WriteAll([][]string{record}))
+	require.NoError(t, file.Close())
+
+	second := defaultScrobble
+	second.Timestamp = second.Timestamp.Add(time.Hour)
+	require.NoError(t, sink.Scrobble(second))
+
+	//nolint:gosec
+	before, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	edited := second
+	edited.Track = "Pure Morning"
+	require.NoError(t, sink.ReplaceScrobbles([]main.Scrobble{defaultScrobble, edited}))
+
+	//nolint:gosec
+	after, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	beforeLines := strings.Split(string(before), "\n")
+	afterLines := strings.Split(string(after), "\n")
+	require.Equal(t, beforeLines[0], afterLines[0])
+	require.NotEqual(t, beforeLines[1], afterLines[1])
+
+	publicKey, err := main.LoadPublicKey(main.PublicKeyFilename())
+	require.NoError(t, err)
+
+	result, err := main.VerifyArchive(filename, publicKey)
+	require.NoError(t, err)
+	require.Equal(t, main.ArchiveVerification{Valid: 2, Unsigned: 0, Invalid: []int{}}, result)
+}
+
+func TestVerifyRecordColumns(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	provenance := main.Provenance{Host: "host", Sink: "csv:default", Signed: time.Unix(1699225080, 0)}
+	record := main.SignRecord(privateKey, []string{"Placebo", "Pure\x1fMorning", "", "251000", "x"}, provenance)
+	require.NoError(t, main.VerifyRecord(publicKey, record))
+
+	// the same text split into different columns
+	shifted := append([]string{"Placebo\x1fPure", "Morning"}, record[2:]...)
+	require.Error(t, main.VerifyRecord(publicKey, shifted))
+}
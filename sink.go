@@ -1,6 +1,10 @@
 package main
 
-import "time"
+import (
+	"errors"
+	"strings"
+	"time"
+)
 
 type Sink interface {
 	Name() string
@@ -8,3 +12,84 @@ type Sink interface {
 	Scrobble(Scrobble) error
 	GetScrobbles(limit int, from, to time.Time) ([]Scrobble, error)
 }
+
+// EditableSink is implemented by local sinks whose stored scrobbles can be
+// rewritten in place.
+type EditableSink interface {
+	Sink
+	ReplaceScrobbles(scrobbles []Scrobble) error
+}
+
+// WrappedSink is implemented by sinks that decorate another sink (e.g.,
+// TemplateSink).
+type WrappedSink interface {
+	Unwrap() Sink
+}
+
+// QueuedSink is implemented by sinks that keep failed scrobbles for later
+// submission (e.g., QueueSink).
+type QueuedSink interface {
+	Sink
+	RetryQueued()
+	FlushQueue() (int, error)
+	QueueDepth() (int, error)
+	QueuedScrobbles() ([]Scrobble, error)
+	ClearQueue(before time.Time) (int, error)
+}
+
+// RejectedError is returned by sinks if the service rejected a scrobble, so
+// submitting it again would fail as well (e.g., because it is too old).
+type RejectedError struct {
+	Err error
+}
+
+func (e RejectedError) Error() string {
+	return "scrobble rejected: " + e.Err.Error()
+}
+
+func (e RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// IsRejected reports whether the error was caused by a rejected scrobble.
+func IsRejected(err error) bool {
+	var rejectedErr RejectedError
+	return errors.As(err, &rejectedErr)
+}
+
+func UnwrapSink(sink Sink) Sink {
+	for {
+		wrapped, ok := sink.(WrappedSink)
+		if !ok {
+			return sink
+		}
+		sink = wrapped.Unwrap()
+	}
+}
+
+// FindSink looks up a sink by its full name (e.g., `csv:default`). The sink
+// type alone (e.g., `csv`) is accepted if only one sink of that type exists.
+func FindSink(sinks []Sink, name string) (Sink, error) {
+	if name == "" {
+		return nil, errors.New("no sink provided (run `goscrobble list-sinks` to list all configured sinks)")
+	}
+
+	var matches []Sink
+	for _, sink := range sinks {
+		if sink.Name() == name {
+			return sink, nil
+		}
+		if strings.HasPrefix(sink.Name(), name+":") {
+			matches = append(matches, sink)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, errors.New("invalid sink name (run `goscrobble list-sinks` to list all configured sinks)")
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, errors.New("ambiguous sink name (run `goscrobble list-sinks` to list all configured sinks)")
+	}
+}
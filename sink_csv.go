@@ -2,24 +2,43 @@ package main
 
 import (
 	"bufio"
+	"crypto/ed25519"
 	"encoding/csv"
+	"fmt"
+	"io"
 	"os"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
 type CSVSink struct {
+	Key      string
 	Filename string
+	// if set, every record is signed and includes provenance metadata
+	SigningKey ed25519.PrivateKey
+	// if set, the outcome of every play is stored in this file
+	PlaysFilename string
 }
 
-func CSVSinkFromConfig(c CSVConfig) CSVSink {
-	return CSVSink(c)
+func CSVSinkFromConfig(key string, c CSVConfig) (CSVSink, error) {
+	sink := CSVSink{Key: key, Filename: c.Filename, SigningKey: nil, PlaysFilename: c.Plays}
+
+	if c.Sign {
+		signingKey, err := LoadSigningKey()
+		if err != nil {
+			return CSVSink{}, fmt.Errorf("cannot load signing key: %w", err)
+		}
+		sink.SigningKey = signingKey
+	}
+
+	return sink, nil
 }
 
 func (s CSVSink) Name() string {
-	return "csv"
+	return fmt.Sprintf("csv:%s", s.Key)
 }
 
 func (s CSVSink) NowPlaying(_ Scrobble) error {
@@ -27,33 +46,266 @@ func (s CSVSink) NowPlaying(_ Scrobble) error {
 }
 
 func (s CSVSink) Scrobble(scrobble Scrobble) error {
-	var scrobbles [][]string
+	records, err := s.readRecords()
+	if err != nil {
+		return err
+	}
+
+	records, err = withoutTombstones(records, []Scrobble{scrobble})
+	if err != nil {
+		return err
+	}
+	records = append(records, s.record(scrobble))
+
+	return s.writeRecords(records)
+}
 
-	file, err := os.Open(s.Filename)
-	if err == nil {
-		defer CloseLogged(file)
+// ReplaceScrobbles overwrites all stored scrobbles with the given ones. The
+// given scrobbles are written even if they were deleted before, their
+// tombstones are removed and all other tombstones are kept. Records of
+// unchanged scrobbles are kept as they are, only new or changed scrobbles are
+// signed.
+func (s CSVSink) ReplaceScrobbles(scrobbles []Scrobble) error {
+	existing, err := s.readRecords()
+	if err != nil {
+		return err
+	}
 
-		scrobbles, err = csv.NewReader(file).ReadAll()
+	// stored records by their unsigned columns, so unchanged scrobbles keep
+	// their provenance and signature
+	stored := map[string][]string{}
+	for _, record := range existing {
+		if IsTombstoneRecord(record) {
+			continue
+		}
+		scrobble, err := ScrobbleFromRecord(record)
 		if err != nil {
 			return err
 		}
-	} else if !os.IsNotExist(err) {
+		key := string(signedMessage(scrobble.ToStringSlice()))
+		if _, ok := stored[key]; !ok {
+			stored[key] = record
+		}
+	}
+
+	records, err := withoutTombstones(existing, scrobbles)
+	if err != nil {
 		return err
 	}
+	records = slices.DeleteFunc(records, func(record []string) bool {
+		return !IsTombstoneRecord(record)
+	})
+
+	for _, scrobble := range scrobbles {
+		if record, ok := stored[string(signedMessage(scrobble.ToStringSlice()))]; ok {
+			records = append(records, record)
+			continue
+		}
+		records = append(records, s.record(scrobble))
+	}
+
+	log.Debug().
+		Str("filename", s.Filename).
+		Int("scrobbles", len(scrobbles)).
+		Msg("replacing scrobbles")
+
+	return s.writeRecords(records)
+}
+
+// DeleteScrobbles replaces the records of the given scrobbles with
+// tombstones. Scrobbles that are not stored in this sink get a tombstone as
+// well, so they are not added by a later sync.
+func (s CSVSink) DeleteScrobbles(scrobbles []Scrobble) error {
+	records, err := s.readRecords()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, scrobble := range scrobbles {
+		tombstone := Tombstone{Scrobble: scrobble, Deleted: now}.ToStringSlice()
+
+		found := false
+		for i, record := range records {
+			if IsTombstoneRecord(record) {
+				continue
+			}
+
+			stored, err := ScrobbleFromRecord(record)
+			if err != nil {
+				return err
+			}
+			if stored.Key() == scrobble.Key() {
+				records[i] = tombstone
+				found = true
+			}
+		}
+
+		if !found {
+			records = append(records, tombstone)
+		}
+	}
+
+	return s.writeRecords(records)
+}
+
+// withoutTombstones removes the tombstones of the given scrobbles, which are
+// written again.
+func withoutTombstones(records [][]string, scrobbles []Scrobble) ([][]string, error) {
+	keys := map[string]bool{}
+	for _, scrobble := range scrobbles {
+		keys[scrobble.Key()] = true
+	}
+
+	kept := make([][]string, 0, len(records))
+	for _, record := range records {
+		if IsTombstoneRecord(record) {
+			tombstone, err := TombstoneFromRecord(record)
+			if err != nil {
+				return nil, err
+			}
+			if keys[tombstone.Scrobble.Key()] {
+				continue
+			}
+		}
+		kept = append(kept, record)
+	}
+	return kept, nil
+}
+
+func (s CSVSink) Tombstones() ([]Tombstone, error) {
+	records, err := s.readRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	var tombstones []Tombstone
+	for _, record := range records {
+		if !IsTombstoneRecord(record) {
+			continue
+		}
+		tombstone, err := TombstoneFromRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		tombstones = append(tombstones, tombstone)
+	}
+
+	return tombstones, nil
+}
+
+// PurgeTombstones removes all tombstones of scrobbles deleted before the given
+// time and returns the number of removed tombstones.
+func (s CSVSink) PurgeTombstones(before time.Time) (int, error) {
+	records, err := s.readRecords()
+	if err != nil {
+		return 0, err
+	}
+
+	var kept [][]string
+	for _, record := range records {
+		if IsTombstoneRecord(record) {
+			tombstone, err := TombstoneFromRecord(record)
+			if err != nil {
+				return 0, err
+			}
+			if tombstone.Deleted.Before(before) {
+				continue
+			}
+		}
+		kept = append(kept, record)
+	}
 
-	scrobbles = append(scrobbles, scrobble.ToStringSlice())
+	purged := len(records) - len(kept)
+	if purged == 0 {
+		return 0, nil
+	}
+
+	return purged, s.writeRecords(kept)
+}
+
+func (s CSVSink) record(scrobble Scrobble) []string {
+	record := scrobble.ToStringSlice()
+	if s.SigningKey == nil {
+		return record
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		log.Warn().Err(err).Msg("cannot get hostname for scrobble provenance")
+	}
+
+	return SignRecord(s.SigningKey, record, Provenance{Host: host, Sink: s.Name(), Signed: time.Now()})
+}
 
-	newFile, err := os.Create(s.Filename)
+// RecordPlay appends the outcome of a play to the plays file, if configured.
+func (s CSVSink) RecordPlay(play PlayRecord) error {
+	if s.PlaysFilename == "" {
+		return nil
+	}
+
+	records, err := readCSVRecords(s.PlaysFilename)
 	if err != nil {
 		return err
 	}
-	defer CloseLogged(newFile)
 
-	return csv.NewWriter(newFile).WriteAll(scrobbles)
+	return writeCSVRecords(s.PlaysFilename, append(records, play.ToStringSlice()))
+}
+
+func (s CSVSink) Plays() ([]PlayRecord, error) {
+	if s.PlaysFilename == "" {
+		return nil, fmt.Errorf("sink %s does not record plays (set `plays` in its configuration)", s.Name())
+	}
+
+	records, err := readCSVRecords(s.PlaysFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	plays := make([]PlayRecord, 0, len(records))
+	for _, record := range records {
+		play, err := PlayRecordFromRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		plays = append(plays, play)
+	}
+
+	return plays, nil
+}
+
+func (s CSVSink) readRecords() ([][]string, error) {
+	return readCSVRecords(s.Filename)
+}
+
+func (s CSVSink) writeRecords(records [][]string) error {
+	return writeCSVRecords(s.Filename, records)
+}
+
+func readCSVRecords(filename string) ([][]string, error) {
+	file, err := OpenCompressed(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer CloseLogged(file)
+
+	// signed and unsigned records may be mixed
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	return reader.ReadAll()
+}
+
+func writeCSVRecords(filename string, records [][]string) error {
+	return WriteCompressedFile(filename, func(w io.Writer) error {
+		return csv.NewWriter(w).WriteAll(records)
+	})
 }
 
 func (s CSVSink) GetScrobbles(limit int, from, to time.Time) ([]Scrobble, error) {
-	file, err := os.Open(s.Filename)
+	file, err := OpenCompressed(s.Filename)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +314,7 @@ func (s CSVSink) GetScrobbles(limit int, from, to time.Time) ([]Scrobble, error)
 	scanner := bufio.NewScanner(file)
 
 	log.Debug().
-		Str("filename", file.Name()).
+		Str("filename", s.Filename).
 		Msg("reading scrobbles")
 
 	var lines []string
@@ -78,14 +330,19 @@ func (s CSVSink) GetScrobbles(limit int, from, to time.Time) ([]Scrobble, error)
 
 	var scrobbles []Scrobble
 	for _, line := range lines {
+		if strings.HasPrefix(line, TombstoneMarker+",") {
+			continue
+		}
+
 		scrobble, err := ScrobbleFromCSV(line)
 		if err != nil {
 			return nil, err
 		}
 
-		if scrobble.Timestamp.Before(from) {
+		// scrobbles are read newest first
+		if scrobble.Timestamp.After(to) {
 			continue
-		} else if scrobble.Timestamp.After(to) {
+		} else if scrobble.Timestamp.Before(from) {
 			break
 		}
 
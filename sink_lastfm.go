@@ -2,6 +2,10 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
@@ -9,13 +13,46 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// the maximum number of scrobbles per page of user.getRecentTracks
+	lastFmPageSize = 200
+	// requests that hang would otherwise keep holding the lock of the sink
+	LastFmTimeout = 30 * time.Second
+	// ignored scrobbles with this code can be submitted again the next day
+	//
+	// https://www.last.fm/api/scrobbling#ignored-messages
+	lastFmDailyLimitCode = 5
+)
+
+// errors of track.scrobble that submitting the scrobble again does not fix
+// (invalid parameters and invalid resource)
+//
+// https://www.last.fm/api/errorcodes
+var lastFmRejectedCodes = []int64{6, 7}
+
 type LastFmSink struct {
-	Client     lastfm.Client
+	Key        string
+	Client     LastFmClient
 	SessionKey string
 	Username   string
+	// host of the API, used to look up Retry-After responses
+	Host       string
+	Limiter    *RateLimiter
+	RetryAfter *RetryAfterTransport
+}
+
+// NewLastFmHTTPClient returns the HTTP client shared by the last.fm sinks of a
+// configuration, which records Retry-After responses.
+func NewLastFmHTTPClient() *http.Client {
+	return &http.Client{
+		Transport:     NewRetryAfterTransport(http.DefaultTransport),
+		CheckRedirect: nil,
+		Jar:           nil,
+		Timeout:       LastFmTimeout,
+	}
 }
 
-func LastFmSinkFromConfig(c LastFmConfig) (LastFmSink, error) {
+func LastFmSinkFromConfig(key string, c LastFmConfig, httpClient *http.Client) (LastFmSink, error) {
 	var sink LastFmSink
 
 	if c.SessionKey == "" || c.Username == "" {
@@ -29,39 +66,149 @@ func LastFmSinkFromConfig(c LastFmConfig) (LastFmSink, error) {
 		baseURL = lastfm.BaseURL
 	}
 
-	client, err := lastfm.NewDesktopClient(baseURL, c.Key, c.Secret)
+	client, err := NewLastFmClient(baseURL, c.Key, c.Secret, httpClient)
+	if err != nil {
+		return sink, err
+	}
+
+	parsed, err := url.Parse(baseURL)
 	if err != nil {
 		return sink, err
 	}
 
-	return LastFmSink{Client: client, SessionKey: c.SessionKey, Username: c.Username}, nil
+	// nil if the client does not record Retry-After responses
+	retryAfter, _ := httpClient.Transport.(*RetryAfterTransport)
+
+	return LastFmSink{
+		Key:        key,
+		Client:     client,
+		SessionKey: c.SessionKey,
+		Username:   c.Username,
+		Host:       parsed.Host,
+		Limiter:    LastFmRateLimiter(c.Key),
+		RetryAfter: retryAfter,
+	}, nil
 }
 
 func (s LastFmSink) Name() string {
-	return "last.fm"
+	return fmt.Sprintf("last.fm:%s", s.Key)
 }
 
 func (s LastFmSink) NowPlaying(scrobble Scrobble) error {
-	_, err := s.Client.TrackUpdateNowPlaying(lastfm.P{
-		"artist":   scrobble.JoinArtists(),
-		"track":    scrobble.Track,
-		"album":    scrobble.Album,
-		"duration": max(int(scrobble.Duration.Seconds()), 30),
-		"sk":       s.SessionKey,
+	return s.limit(func() error {
+		params := lastfm.P{
+			"artist": scrobble.JoinArtists(),
+			"track":  scrobble.Track,
+			"album":  scrobble.Album,
+			"sk":     s.SessionKey,
+		}
+		setLastFmDuration(params, scrobble.Duration)
+		_, err := s.Client.TrackUpdateNowPlaying(params)
+		return err
 	})
-	return err
 }
 
 func (s LastFmSink) Scrobble(scrobble Scrobble) error {
-	_, err := s.Client.TrackScrobble(lastfm.P{
-		"artist":    scrobble.JoinArtists(),
-		"track":     scrobble.Track,
-		"album":     scrobble.Album,
-		"duration":  max(int(scrobble.Duration.Seconds()), 30),
-		"timestamp": scrobble.Timestamp.Unix(),
-		"sk":        s.SessionKey,
+	return s.limit(func() error {
+		params := lastfm.P{
+			"artist":    scrobble.JoinArtists(),
+			"track":     scrobble.Track,
+			"album":     scrobble.Album,
+			"timestamp": scrobble.Timestamp.Unix(),
+			"sk":        s.SessionKey,
+		}
+		setLastFmDuration(params, scrobble.Duration)
+		response, err := s.Client.TrackScrobble(params)
+		if err != nil {
+			var apiErr LastFmError
+			if errors.As(err, &apiErr) && slices.Contains(lastFmRejectedCodes, apiErr.Code) {
+				return RejectedError{Err: err}
+			}
+			return err
+		}
+
+		if response.Scrobbles.Ignored == 0 || len(response.Scrobbles.Scrobbles) == 0 {
+			return nil
+		}
+		ignored := response.Scrobbles.Scrobbles[0].IgnoredMessage
+		err = fmt.Errorf("scrobble ignored by last.fm: %s (code %d)", strings.TrimSpace(ignored.Message), ignored.Code)
+		if ignored.Code == lastFmDailyLimitCode {
+			return err
+		}
+		return RejectedError{Err: err}
+	})
+}
+
+func (s LastFmSink) Love(scrobble Scrobble, love bool) error {
+	return s.limit(func() error {
+		params := lastfm.P{
+			"artist": scrobble.JoinArtists(),
+			"track":  scrobble.Track,
+			"sk":     s.SessionKey,
+		}
+		if love {
+			_, err := s.Client.TrackLove(params)
+			return err
+		}
+		_, err := s.Client.TrackUnlove(params)
+		return err
+	})
+}
+
+// setLastFmDuration adds the duration of a track to the request parameters,
+// unless it is unknown.
+func setLastFmDuration(params lastfm.P, duration time.Duration) {
+	if duration > 0 {
+		params["duration"] = max(int(duration.Seconds()), 30)
+	}
+}
+
+// TrackDuration looks up the duration of a track, which is zero if last.fm
+// does not know it.
+func (s LastFmSink) TrackDuration(scrobble Scrobble) (time.Duration, error) {
+	var duration time.Duration
+	err := s.limit(func() error {
+		info, err := s.Client.TrackGetInfo(lastfm.P{
+			"artist":      scrobble.JoinArtists(),
+			"track":       scrobble.Track,
+			"autocorrect": 1,
+		})
+		if err != nil {
+			return err
+		}
+		duration = time.Duration(info.Track.Duration) * time.Millisecond
+		return nil
 	})
-	return err
+	return duration, err
+}
+
+// limit sends a request once the rate limiter allows it. If the API responds
+// with a rate limit error, all further requests are paused instead of sent.
+func (s LastFmSink) limit(request func() error) error {
+	wait, err := s.Limiter.Reserve(time.Now())
+	if err != nil {
+		return err
+	}
+	time.Sleep(wait)
+
+	err = request()
+	if err == nil {
+		return nil
+	}
+
+	until, ok := rateLimitedUntil(err, s.RetryAfter, s.Host, time.Now())
+	if !ok {
+		return err
+	}
+
+	log.Warn().
+		Err(err).
+		Str("sink", s.Name()).
+		Time("until", until).
+		Msg("last.fm rate limit reached, pausing requests")
+	s.Limiter.Pause(until)
+
+	return RateLimitError{Until: until, Err: err}
 }
 
 func (s LastFmSink) GetScrobbles(limit int, from, to time.Time) ([]Scrobble, error) {
@@ -75,14 +222,29 @@ func (s LastFmSink) GetScrobbles(limit int, from, to time.Time) ([]Scrobble, err
 	var scrobbles []Scrobble
 outer:
 	for {
-		page, err := s.Client.UserGetRecentTracks(lastfm.P{
-			"limit":    min(limit, 200),
-			"user":     s.Username,
-			"page":     currentPage,
-			"from":     from.Unix(),
-			"extended": 1,
-			"to":       to.Unix(),
+		var page lastfm.UserGetRecentTracksResponse
+		err := s.limit(func() error {
+			var err error
+			page, err = s.Client.UserGetRecentTracks(lastfm.P{
+				"limit":    min(limit, lastFmPageSize),
+				"user":     s.Username,
+				"page":     currentPage,
+				"from":     from.Unix(),
+				"extended": 1,
+				"to":       to.Unix(),
+			})
+			return err
 		})
+
+		var rateLimitErr RateLimitError
+		if errors.As(err, &rateLimitErr) && time.Until(rateLimitErr.Until) <= lastFmMaxPaginationWait {
+			log.Debug().
+				Int("page", currentPage).
+				Time("until", rateLimitErr.Until).
+				Msg("waiting for last.fm rate limit before loading next page")
+			time.Sleep(time.Until(rateLimitErr.Until))
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -91,16 +253,9 @@ outer:
 			totalPages = page.RecentTracks.TotalPages
 		}
 
-		for _, track := range page.RecentTracks.Tracks {
+		for _, scrobble := range lastFmScrobbles(page) {
 			if noLimit || len(scrobbles) < limit {
-				scrobbles = append(scrobbles, Scrobble{
-					// FIXME: this does not work in some cases (e.g., "Tyler, the Creator")
-					Artists:   strings.Split(track.Artist.Name, ", "),
-					Track:     track.Name,
-					Album:     track.Album.Name,
-					Duration:  time.Duration(0),
-					Timestamp: time.Unix(track.Date.UTS, 0),
-				})
+				scrobbles = append(scrobbles, scrobble)
 			} else {
 				break outer
 			}
@@ -114,3 +269,46 @@ outer:
 
 	return scrobbles, nil
 }
+
+// ScrobblesPage returns a page of the scrobbles before to, newest first, and
+// the total number of pages. Pages do not shift while new scrobbles are added,
+// as long as to stays the same.
+func (s LastFmSink) ScrobblesPage(to time.Time, page int) ([]Scrobble, int, error) {
+	var response lastfm.UserGetRecentTracksResponse
+	err := s.limit(func() error {
+		var err error
+		response, err = s.Client.UserGetRecentTracks(lastfm.P{
+			"limit":    lastFmPageSize,
+			"user":     s.Username,
+			"page":     page,
+			"extended": 1,
+			"to":       to.Unix(),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return lastFmScrobbles(response), int(response.RecentTracks.TotalPages), nil
+}
+
+func lastFmScrobbles(response lastfm.UserGetRecentTracksResponse) []Scrobble {
+	scrobbles := make([]Scrobble, 0, len(response.RecentTracks.Tracks))
+	for _, track := range response.RecentTracks.Tracks {
+		// the currently playing track has no date
+		if track.Date.UTS == 0 {
+			continue
+		}
+
+		scrobbles = append(scrobbles, Scrobble{
+			// FIXME: this does not work in some cases (e.g., "Tyler, the Creator")
+			Artists:   strings.Split(track.Artist.Name, ", "),
+			Track:     track.Name,
+			Album:     track.Album.Name,
+			Duration:  time.Duration(0),
+			Timestamp: time.Unix(track.Date.UTS, 0),
+		})
+	}
+	return scrobbles
+}
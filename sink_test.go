@@ -2,9 +2,12 @@ package main_test
 
 import (
 	"errors"
+	"path/filepath"
+	"testing"
 	"time"
 
 	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
 )
 
 type FakeSink struct {
@@ -36,3 +39,61 @@ func (s *FakeSink) Scrobble(p main.Scrobble) error {
 func (s *FakeSink) GetScrobbles(_ int, _, _ time.Time) ([]main.Scrobble, error) {
 	return []main.Scrobble{}, nil
 }
+
+func TestFindSink(t *testing.T) {
+	sinks := []main.Sink{
+		main.CSVSink{Key: "default", Filename: "default.csv"},
+		main.CSVSink{Key: "network", Filename: "network.csv"},
+		&FakeSink{},
+	}
+
+	sink, err := main.FindSink(sinks, "csv:network")
+	require.NoError(t, err)
+	require.Equal(t, "csv:network", sink.Name())
+
+	_, err = main.FindSink(sinks, "csv")
+	require.Error(t, err)
+
+	sink, err = main.FindSink(sinks[1:], "csv")
+	require.NoError(t, err)
+	require.Equal(t, "csv:network", sink.Name())
+
+	_, err = main.FindSink(sinks, "")
+	require.Error(t, err)
+
+	_, err = main.FindSink(sinks, "last.fm")
+	require.Error(t, err)
+}
+
+func TestCSVSinkGetScrobblesRange(t *testing.T) {
+	sink := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv")}
+
+	var scrobbles []main.Scrobble
+	for i := range 3 {
+		scrobble := defaultScrobble
+		scrobble.Timestamp = defaultScrobble.Timestamp.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, sink.Scrobble(scrobble))
+		scrobbles = append(scrobbles, scrobble)
+	}
+
+	// newest first
+	stored, err := sink.GetScrobbles(0, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	require.Equal(t, scrobbles[2].Key(), stored[0].Key())
+
+	stored, err = sink.GetScrobbles(0, scrobbles[1].Timestamp.Add(-time.Minute), scrobbles[1].Timestamp.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	require.Equal(t, scrobbles[1].Key(), stored[0].Key())
+
+	stored, err = sink.GetScrobbles(0, time.Time{}, scrobbles[1].Timestamp)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	require.Equal(t, scrobbles[1].Key(), stored[0].Key())
+
+	stored, err = sink.GetScrobbles(1, scrobbles[1].Timestamp, time.Now())
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	require.Equal(t, scrobbles[2].Key(), stored[0].Key())
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultSoakDuration = time.Hour
+	DefaultSoakInterval = 10 * time.Millisecond
+	// playback advances by this much per poll of the soak source
+	SoakStep          = 30 * time.Second
+	SoakTrackDuration = 3 * time.Minute
+	// memory is sampled every n iterations, reading it stops the world
+	soakMemorySampleRate = 100
+)
+
+// SoakSource plays an endless stream of synthetic tracks. Each call to GetInfo
+// advances playback by SoakStep, so a track is scrobbled after a few polls.
+type SoakSource struct {
+	track    int
+	position time.Duration
+}
+
+func (s *SoakSource) Name() string {
+	return "soak"
+}
+
+func (s *SoakSource) GetInfo(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) (map[string]PlaybackStatus, error) {
+	playerPlaybackStatus := map[string]PlaybackStatus{}
+
+	playbackStatus := PlaybackStatus{
+		Scrobble: Scrobble{
+			Artists:   []string{"goscrobble"},
+			Track:     fmt.Sprintf("Soak Test %d", s.track+1),
+			Album:     "Soak Test",
+			Duration:  SoakTrackDuration,
+			Timestamp: time.Time{},
+		},
+		State:    PlaybackPlaying,
+		Position: s.position,
+	}
+
+	s.position += SoakStep
+	if s.position > SoakTrackDuration {
+		s.track++
+		s.position = 0
+	}
+
+	player := fmt.Sprintf("%s:player", s.Name())
+	if IsPlayerBlacklisted(playerBlacklist, player) {
+		return playerPlaybackStatus, nil
+	}
+
+	playbackStatus.RegexReplace(regexes)
+	playerPlaybackStatus[player] = playbackStatus
+
+	return playerPlaybackStatus, nil
+}
+
+// SoakSink measures the latency and errors of the wrapped sink. It does not
+// implement Unwrap on purpose, so plays of the synthetic tracks are not
+// recorded in local sinks.
+type SoakSink struct {
+	Sink
+
+	Requests  int
+	Errors    int
+	Latencies []time.Duration
+}
+
+func (s *SoakSink) NowPlaying(scrobble Scrobble) error {
+	return s.measure(func() error { return s.Sink.NowPlaying(scrobble) })
+}
+
+func (s *SoakSink) Scrobble(scrobble Scrobble) error {
+	return s.measure(func() error { return s.Sink.Scrobble(scrobble) })
+}
+
+func (s *SoakSink) measure(send func() error) error {
+	started := time.Now()
+	err := send()
+
+	s.Requests++
+	s.Latencies = append(s.Latencies, time.Since(started))
+	if err != nil {
+		s.Errors++
+	}
+
+	return err
+}
+
+// SoakSinkReport summarizes the requests sent to a single sink.
+type SoakSinkReport struct {
+	Sink     string
+	Requests int
+	Errors   int
+	P50      time.Duration
+	P95      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+func (r SoakSinkReport) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Requests)
+}
+
+// SoakReport is the result of a soak test. Heap sizes are measured in bytes
+// after a garbage collection, except for the peak.
+type SoakReport struct {
+	Duration   time.Duration
+	Iterations int
+	Tracks     int
+	Sinks      []SoakSinkReport
+	HeapStart  uint64
+	HeapEnd    uint64
+	HeapPeak   uint64
+}
+
+// HeapGrowth is the difference between the heap size at the end and at the
+// start of the soak test, which is negative if it shrunk.
+func (r SoakReport) HeapGrowth() int64 {
+	//nolint:gosec
+	return int64(r.HeapEnd) - int64(r.HeapStart)
+}
+
+// Percentile returns the p-th percentile (0 to 1) of the given latencies
+// using the nearest-rank method.
+func Percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+
+	rank := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// SoakConfig returns a copy of the config for soak tests. Unless submit is
+// set, all sinks are switched to dry runs. The audit log and offline queue
+// are disabled, so the synthetic tracks do not end up in either of them.
+func (c Config) SoakConfig(submit bool) Config {
+	c.AuditLog = false
+	c.OfflineQueue = false
+	c.NotifyOnScrobble = false
+	c.NotifyOnError = false
+
+	if !submit {
+		c.Sinks = c.Sinks.dryRun()
+	}
+
+	return c
+}
+
+// RunSoak runs the main loop against the soak source for the given duration,
+// pausing for interval between iterations. Notifications are discarded.
+func RunSoak(sinks []Sink, options LoopOptions, duration, interval time.Duration) SoakReport {
+	source := &SoakSource{track: 0, position: 0}
+
+	measured := make([]*SoakSink, 0, len(sinks))
+	wrapped := make([]Sink, 0, len(sinks))
+	for _, sink := range sinks {
+		soakSink := &SoakSink{Sink: sink, Requests: 0, Errors: 0, Latencies: nil}
+		measured = append(measured, soakSink)
+		wrapped = append(wrapped, soakSink)
+	}
+
+	options.NotifyOnScrobble = false
+	options.NotifyOnError = false
+	// every iteration advances the synthetic tracks by one step
+	options.PollInterval = SoakStep
+
+	discard := func(uint32, string, string) (uint32, error) {
+		return 0, nil
+	}
+
+	var memStats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&memStats)
+
+	report := SoakReport{
+		Duration:   0,
+		Iterations: 0,
+		Tracks:     0,
+		Sinks:      nil,
+		HeapStart:  memStats.HeapAlloc,
+		HeapEnd:    0,
+		HeapPeak:   memStats.HeapAlloc,
+	}
+
+	log.Info().
+		Dur("duration", duration).
+		Dur("interval", interval).
+		Int("sinks", len(sinks)).
+		Msg("starting soak test")
+
+	state := NewLoopState()
+	started := time.Now()
+	for time.Since(started) < duration {
+		RunMainLoopOnce(state, options, []Source{source}, wrapped, discard)
+		report.Iterations++
+
+		if report.Iterations%soakMemorySampleRate == 0 {
+			runtime.ReadMemStats(&memStats)
+			report.HeapPeak = max(report.HeapPeak, memStats.HeapAlloc)
+		}
+
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+	}
+	report.Duration = time.Since(started)
+	report.Tracks = state.Stats.TracksSeen
+
+	runtime.ReadMemStats(&memStats)
+	report.HeapPeak = max(report.HeapPeak, memStats.HeapAlloc)
+	runtime.GC()
+	runtime.ReadMemStats(&memStats)
+	report.HeapEnd = memStats.HeapAlloc
+
+	for _, sink := range measured {
+		report.Sinks = append(report.Sinks, SoakSinkReport{
+			Sink:     sink.Name(),
+			Requests: sink.Requests,
+			Errors:   sink.Errors,
+			P50:      Percentile(sink.Latencies, 0.5),
+			P95:      Percentile(sink.Latencies, 0.95),
+			P99:      Percentile(sink.Latencies, 0.99),
+			Max:      Percentile(sink.Latencies, 1),
+		})
+	}
+
+	return report
+}
@@ -0,0 +1,56 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSoak(t *testing.T) {
+	fakeSink := &FakeSink{}
+	failingSink := &FakeSink{Error: true}
+
+	config := main.DefaultConfig
+	report := main.RunSoak([]main.Sink{fakeSink, failingSink}, config.LoopOptions(), 20*time.Millisecond, 0)
+
+	require.Positive(t, report.Iterations)
+	require.Positive(t, report.Tracks)
+	require.NotEmpty(t, fakeSink.ScrobbleLog)
+	require.Len(t, report.Sinks, 2)
+
+	require.Equal(t, len(fakeSink.NowPlayingLog)+len(fakeSink.ScrobbleLog), report.Sinks[0].Requests)
+	require.Zero(t, report.Sinks[0].Errors)
+	require.Equal(t, report.Sinks[1].Requests, report.Sinks[1].Errors)
+	require.InDelta(t, 1.0, report.Sinks[1].ErrorRate(), 0.001)
+	require.LessOrEqual(t, report.Sinks[0].P50, report.Sinks[0].Max)
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	require.Equal(t, 50*time.Millisecond, main.Percentile(latencies, 0.5))
+	require.Equal(t, 95*time.Millisecond, main.Percentile(latencies, 0.95))
+	require.Equal(t, 100*time.Millisecond, main.Percentile(latencies, 1))
+	require.Equal(t, 1*time.Millisecond, main.Percentile(latencies, 0))
+	require.Zero(t, main.Percentile(nil, 0.5))
+}
+
+func TestSoakConfig(t *testing.T) {
+	config := main.DefaultConfig
+	config.AuditLog = true
+	config.OfflineQueue = true
+	config.Sinks.CSV = map[string]main.CSVConfig{"default": {Filename: "scrobbles.csv"}}
+
+	soak := config.SoakConfig(false)
+	require.True(t, soak.Sinks.CSV["default"].DryRun)
+	require.False(t, soak.AuditLog)
+	require.False(t, soak.OfflineQueue)
+	require.False(t, config.Sinks.CSV["default"].DryRun)
+
+	require.False(t, config.SoakConfig(true).Sinks.CSV["default"].DryRun)
+}
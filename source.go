@@ -1,11 +1,33 @@
 package main
 
-import "regexp"
+import (
+	"io"
+)
 
 type Source interface {
 	Name() string
 	GetInfo(
-		playerBlacklist []*regexp.Regexp,
+		playerBlacklist []ParsedFilterRule,
 		regexes []ParsedRegexReplace,
 	) (map[string]PlaybackStatus, error)
 }
+
+// ScrobbleSource is implemented by sources that receive finished scrobbles
+// directly instead of only reporting the current playback status.
+type ScrobbleSource interface {
+	Source
+	ReceivedScrobbles(
+		playerBlacklist []ParsedFilterRule,
+		regexes []ParsedRegexReplace,
+	) map[string][]Scrobble
+}
+
+// CloseSources releases the connections and listeners held by sources, so they
+// can be set up again after reloading the configuration.
+func CloseSources(sources []Source) {
+	for _, source := range sources {
+		if closer, ok := source.(io.Closer); ok {
+			CloseLogged(closer)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// fields returned by the scripts are separated by the ASCII unit separator,
+// which is very unlikely to appear in track metadata
+const appleScriptSeparator = "\x1f"
+
+// AppleScriptPlayer describes how to query a scriptable macOS player.
+type AppleScriptPlayer struct {
+	// name of the `tell application` target
+	Application string
+	// script printing state, artist, track, album, duration, and position
+	Script string
+	// multiplier to convert the reported duration to seconds
+	DurationScale float64
+}
+
+// both Music and Spotify support the same basic scripting terms
+const appleScriptPlayerScript = `set s to character id 31
+if player state is stopped then return "stopped"
+return (player state as text) & s & (artist of current track) & s & (name of current track) & s & ` +
+	`(album of current track) & s & (duration of current track as text) & s & (player position as text)`
+
+// AppleScriptPlayers are the players supported by the applescript source,
+// keyed by bundle identifier.
+var AppleScriptPlayers = map[string]AppleScriptPlayer{
+	"com.apple.Music": {
+		Application:   "Music",
+		Script:        appleScriptPlayerScript,
+		DurationScale: 1,
+	},
+	"com.spotify.client": {
+		Application:   "Spotify",
+		Script:        appleScriptPlayerScript,
+		DurationScale: 0.001,
+	},
+}
+
+// AppleScriptSource reads now playing information from the scriptable players
+// in AppleScriptPlayers using the `osascript` binary that ships with macOS, so
+// no third-party tools are required. It does not read the system-wide now
+// playing information of MediaRemote, other players need the media-control
+// source.
+type AppleScriptSource struct {
+	Players []string
+}
+
+func (s AppleScriptSource) Name() string {
+	return "applescript"
+}
+
+func (s AppleScriptSource) GetInfo(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) (map[string]PlaybackStatus, error) {
+	playerPlaybackStatus := map[string]PlaybackStatus{}
+
+	var errs []error
+	for _, bundleID := range s.Players {
+		player, ok := AppleScriptPlayers[bundleID]
+		if !ok || IsPlayerBlacklisted(playerBlacklist, bundleID) {
+			continue
+		}
+
+		running, err := runOSAScript(fmt.Sprintf(`application id "%s" is running`, bundleID))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if running != "true" {
+			continue
+		}
+
+		log.Debug().
+			Str("player", bundleID).
+			Msg("getting playback metadata using applescript")
+
+		script := fmt.Sprintf("tell application \"%s\"\n%s\nend tell", player.Application, player.Script)
+		output, err := runOSAScript(script)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		playbackStatus, err := ParseAppleScriptOutput(output, player.DurationScale)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("player", bundleID).
+				Str("output", output).
+				Msg("error parsing applescript output")
+			continue
+		}
+		if playbackStatus.State == PlaybackStopped {
+			continue
+		}
+
+		playbackStatus.RegexReplace(regexes)
+
+		playerName := fmt.Sprintf("%s:%s", s.Name(), bundleID)
+		playerPlaybackStatus[playerName] = playbackStatus
+	}
+
+	return playerPlaybackStatus, errors.Join(errs...)
+}
+
+func runOSAScript(script string) (string, error) {
+	output, err := exec.Command("osascript", "-e", script).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func ParseAppleScriptOutput(output string, durationScale float64) (PlaybackStatus, error) {
+	if output == "stopped" {
+		return PlaybackStatus{Scrobble: Scrobble{}, State: PlaybackStopped, Position: 0}, nil
+	}
+
+	parts := strings.Split(output, appleScriptSeparator)
+	if len(parts) != 6 {
+		return PlaybackStatus{}, errors.New("output has invalid number of fields")
+	}
+
+	// AppleScript may format reals using the system locale
+	duration, err := strconv.ParseFloat(strings.ReplaceAll(parts[4], ",", "."), 64)
+	if err != nil {
+		return PlaybackStatus{}, err
+	}
+	position, err := strconv.ParseFloat(strings.ReplaceAll(parts[5], ",", "."), 64)
+	if err != nil {
+		return PlaybackStatus{}, err
+	}
+
+	var state PlaybackState
+	switch parts[0] {
+	case "playing":
+		state = PlaybackPlaying
+	case "paused":
+		state = PlaybackPaused
+	default:
+		state = PlaybackStopped
+	}
+
+	return PlaybackStatus{
+		Scrobble: Scrobble{
+			Artists:   []string{parts[1]},
+			Track:     parts[2],
+			Album:     parts[3],
+			Duration:  time.Duration(duration * durationScale * float64(time.Second)),
+			Timestamp: time.Time{},
+		},
+		State:    state,
+		Position: time.Duration(position * float64(time.Second)),
+	}, nil
+}
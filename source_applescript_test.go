@@ -0,0 +1,45 @@
+package main_test
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAppleScriptOutput(t *testing.T) {
+	output := strings.Join([]string{"playing", "Placebo", "Meds", "Meds", "163000", "12,5"}, "\x1f")
+
+	status, err := main.ParseAppleScriptOutput(output, 0.001)
+	require.NoError(t, err)
+	require.Equal(t, main.PlaybackPlaying, status.State)
+	require.Equal(t, []string{"Placebo"}, status.Artists)
+	require.Equal(t, "Meds", status.Track)
+	require.Equal(t, 163*time.Second, status.Duration)
+	require.Equal(t, 12500*time.Millisecond, status.Position)
+
+	status, err = main.ParseAppleScriptOutput("stopped", 1)
+	require.NoError(t, err)
+	require.Equal(t, main.PlaybackStopped, status.State)
+
+	_, err = main.ParseAppleScriptOutput("playing\x1fPlacebo", 1)
+	require.Error(t, err)
+}
+
+func TestAppleScriptConfigValidate(t *testing.T) {
+	sources := main.SourcesConfig{AppleScript: &main.AppleScriptConfig{Players: []string{"com.apple.Music"}}}
+	warnings := sources.Validate()
+
+	if runtime.GOOS == "darwin" {
+		require.Empty(t, warnings)
+		require.NotNil(t, sources.AppleScript)
+		return
+	}
+	require.Equal(t, []main.ConfigWarning{
+		{Subject: "", Message: "applescript source is only supported on macOS, disabling it"},
+	}, warnings)
+	require.Nil(t, sources.AppleScript)
+}
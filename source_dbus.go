@@ -3,7 +3,6 @@ package main
 import (
 	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
@@ -15,12 +14,16 @@ type DBusSource struct {
 	Conn *dbus.Conn
 }
 
+func (s DBusSource) Close() error {
+	return s.Conn.Close()
+}
+
 func (s DBusSource) Name() string {
 	return "dbus"
 }
 
 func (s DBusSource) GetInfo(
-	playerBlacklist []*regexp.Regexp,
+	playerBlacklist []ParsedFilterRule,
 	regexes []ParsedRegexReplace,
 ) (map[string]PlaybackStatus, error) {
 	var dbusNames []string
@@ -33,7 +36,7 @@ func (s DBusSource) GetInfo(
 
 	var playerNames []string
 	for _, name := range dbusNames {
-		if strings.HasPrefix(name, "org.mpris.MediaPlayer2.") && !IsBlacklisted(playerBlacklist, name) {
+		if strings.HasPrefix(name, "org.mpris.MediaPlayer2.") && !IsPlayerBlacklisted(playerBlacklist, name) {
 			playerNames = append(playerNames, name)
 		}
 	}
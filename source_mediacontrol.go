@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
-	"regexp"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -20,7 +19,7 @@ func (s MediaControlSource) Name() string {
 }
 
 func (s MediaControlSource) GetInfo(
-	playerBlacklist []*regexp.Regexp,
+	playerBlacklist []ParsedFilterRule,
 	regexes []ParsedRegexReplace,
 ) (map[string]PlaybackStatus, error) {
 	log.Debug().Msg("getting playback metadata using media-control")
@@ -42,7 +41,7 @@ func (s MediaControlSource) GetInfo(
 		return map[string]PlaybackStatus{}, nil
 	}
 
-	if IsBlacklisted(playerBlacklist, outputParsed.BundleIdentifier) {
+	if IsPlayerBlacklisted(playerBlacklist, outputParsed.BundleIdentifier) {
 		return map[string]PlaybackStatus{}, nil
 	}
 
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// https://github.com/RoonLabs/node-roon-api
+const (
+	RoonTokenFileName   = "roon_token"
+	roonExtensionID     = "io.github.p-mng.goscrobble"
+	roonTransport       = "com.roonlabs.transport:2"
+	roonPing            = "com.roonlabs.ping:1"
+	roonReconnectPeriod = 10 * time.Second
+)
+
+// roonConnection is the websocket connection to the Roon core, which is not
+// included in minimal builds.
+type roonConnection interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// RoonSource registers goscrobble as a Roon extension and subscribes to zone
+// updates. The extension must be enabled once in Settings > Extensions.
+type RoonSource struct {
+	Address string
+
+	mutex sync.Mutex
+	zones map[string]RoonZone
+	conn  roonConnection
+	done  chan struct{}
+}
+
+type RoonZone struct {
+	ZoneID      string          `json:"zone_id"`
+	DisplayName string          `json:"display_name"`
+	State       string          `json:"state"`
+	NowPlaying  *RoonNowPlaying `json:"now_playing"`
+}
+
+type RoonNowPlaying struct {
+	SeekPosition float64 `json:"seek_position"`
+	Length       float64 `json:"length"`
+	ThreeLine    struct {
+		Line1 string `json:"line1"`
+		Line2 string `json:"line2"`
+		Line3 string `json:"line3"`
+	} `json:"three_line"`
+}
+
+type roonZonesMessage struct {
+	Zones            []RoonZone `json:"zones"`
+	ZonesAdded       []RoonZone `json:"zones_added"`
+	ZonesChanged     []RoonZone `json:"zones_changed"`
+	ZonesRemoved     []string   `json:"zones_removed"`
+	ZonesSeekChanged []struct {
+		ZoneID       string  `json:"zone_id"`
+		SeekPosition float64 `json:"seek_position"`
+	} `json:"zones_seek_changed"`
+}
+
+// RoonMessage is a single message of the MOO protocol used by the Roon API.
+type RoonMessage struct {
+	Verb      string
+	Name      string
+	RequestID int
+	Body      []byte
+}
+
+func NewRoonSource(address string) *RoonSource {
+	source := &RoonSource{
+		Address: address,
+		mutex:   sync.Mutex{},
+		zones:   map[string]RoonZone{},
+		conn:    nil,
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		for {
+			err := source.run()
+
+			select {
+			case <-source.done:
+				return
+			default:
+			}
+
+			if err != nil {
+				log.Error().
+					Err(err).
+					Str("address", address).
+					Msg("lost connection to Roon core")
+			}
+
+			source.mutex.Lock()
+			source.zones = map[string]RoonZone{}
+			source.mutex.Unlock()
+
+			select {
+			case <-time.After(roonReconnectPeriod):
+			case <-source.done:
+				return
+			}
+		}
+	}()
+
+	return source
+}
+
+// Close disconnects from the Roon core and stops reconnecting.
+func (s *RoonSource) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	close(s.done)
+	if s.conn == nil {
+		return nil
+	}
+	// unblock the pending read, the connection is closed by run
+	return s.conn.SetReadDeadline(time.Now())
+}
+
+func (s *RoonSource) Name() string {
+	return "roon"
+}
+
+func (s *RoonSource) GetInfo(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) (map[string]PlaybackStatus, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	playerPlaybackStatus := map[string]PlaybackStatus{}
+
+	for _, zone := range s.zones {
+		if zone.NowPlaying == nil || IsPlayerBlacklisted(playerBlacklist, zone.DisplayName) {
+			continue
+		}
+
+		playbackStatus := zone.PlaybackStatus()
+		playbackStatus.RegexReplace(regexes)
+
+		playerName := fmt.Sprintf("%s:%s", s.Name(), zone.DisplayName)
+		playerPlaybackStatus[playerName] = playbackStatus
+	}
+
+	return playerPlaybackStatus, nil
+}
+
+func (z RoonZone) PlaybackStatus() PlaybackStatus {
+	var state PlaybackState
+	switch z.State {
+	case "playing":
+		state = PlaybackPlaying
+	case "paused", "loading":
+		state = PlaybackPaused
+	default:
+		state = PlaybackStopped
+	}
+
+	return PlaybackStatus{
+		Scrobble: Scrobble{
+			// Roon joins multiple artists using " / "
+			Artists:   strings.Split(z.NowPlaying.ThreeLine.Line2, " / "),
+			Track:     z.NowPlaying.ThreeLine.Line1,
+			Album:     z.NowPlaying.ThreeLine.Line3,
+			Duration:  time.Duration(z.NowPlaying.Length * float64(time.Second)),
+			Timestamp: time.Time{},
+		},
+		State:    state,
+		Position: time.Duration(z.NowPlaying.SeekPosition * float64(time.Second)),
+	}
+}
+
+func (s *RoonSource) applyZones(message roonZonesMessage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, zones := range [][]RoonZone{message.Zones, message.ZonesAdded, message.ZonesChanged} {
+		for _, zone := range zones {
+			s.zones[zone.ZoneID] = zone
+		}
+	}
+	for _, zoneID := range message.ZonesRemoved {
+		delete(s.zones, zoneID)
+	}
+	for _, seek := range message.ZonesSeekChanged {
+		zone, ok := s.zones[seek.ZoneID]
+		if !ok || zone.NowPlaying == nil {
+			continue
+		}
+		nowPlaying := *zone.NowPlaying
+		nowPlaying.SeekPosition = seek.SeekPosition
+		zone.NowPlaying = &nowPlaying
+		s.zones[seek.ZoneID] = zone
+	}
+}
+
+func (m RoonMessage) Bytes() []byte {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "MOO/1 %s %s\n", m.Verb, m.Name)
+	fmt.Fprintf(&buffer, "Request-Id: %d\n", m.RequestID)
+	if len(m.Body) > 0 {
+		fmt.Fprintf(&buffer, "Content-Length: %d\n", len(m.Body))
+		buffer.WriteString("Content-Type: application/json\n")
+	}
+	buffer.WriteString("\n")
+	buffer.Write(m.Body)
+	return buffer.Bytes()
+}
+
+func ParseRoonMessage(data []byte) (RoonMessage, error) {
+	reader := bufio.NewReader(bytes.NewReader(data))
+
+	firstLine, err := reader.ReadString('\n')
+	if err != nil {
+		return RoonMessage{}, err
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(firstLine), " ", 3)
+	if len(parts) != 3 || parts[0] != "MOO/1" {
+		return RoonMessage{}, errors.New("invalid MOO message")
+	}
+
+	message := RoonMessage{Verb: parts[1], Name: parts[2], RequestID: -1, Body: nil}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return RoonMessage{}, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return RoonMessage{}, fmt.Errorf("invalid MOO header: %s", line)
+		}
+		if strings.TrimSpace(key) == "Request-Id" {
+			if message.RequestID, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return RoonMessage{}, err
+			}
+		}
+	}
+
+	message.Body, err = io.ReadAll(reader)
+	return message, err
+}
@@ -0,0 +1,7 @@
+//go:build minimal
+
+package main
+
+func (s *RoonSource) run() error {
+	return MinimalBuildError{Feature: "Roon source"}
+}
@@ -0,0 +1,44 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoonMessage(t *testing.T) {
+	message := main.RoonMessage{
+		Verb:      "REQUEST",
+		Name:      "com.roonlabs.transport:2/subscribe_zones",
+		RequestID: 2,
+		Body:      []byte(`{"subscription_key":0}`),
+	}
+
+	parsed, err := main.ParseRoonMessage(message.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, message, parsed)
+
+	_, err = main.ParseRoonMessage([]byte("HTTP/1.1 200 OK\n\n"))
+	require.Error(t, err)
+}
+
+func TestRoonZonePlaybackStatus(t *testing.T) {
+	zone := main.RoonZone{
+		ZoneID:      "1",
+		DisplayName: "Living Room",
+		State:       "playing",
+		NowPlaying:  &main.RoonNowPlaying{SeekPosition: 12, Length: 251},
+	}
+	zone.NowPlaying.ThreeLine.Line1 = "Without You I'm Nothing"
+	zone.NowPlaying.ThreeLine.Line2 = "Placebo / David Bowie"
+	zone.NowPlaying.ThreeLine.Line3 = "A Place For Us To Dream"
+
+	status := zone.PlaybackStatus()
+	require.Equal(t, main.PlaybackPlaying, status.State)
+	require.Equal(t, defaultScrobble.Artists, status.Artists)
+	require.Equal(t, defaultScrobble.Track, status.Track)
+	require.Equal(t, defaultScrobble.Duration, status.Duration)
+	require.Equal(t, 12*time.Second, status.Position)
+}
@@ -0,0 +1,139 @@
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+func (s *RoonSource) run() error {
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s/api", s.Address), nil)
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(conn)
+
+	s.mutex.Lock()
+	select {
+	case <-s.done:
+		s.mutex.Unlock()
+		return nil
+	default:
+		s.conn = conn
+	}
+	s.mutex.Unlock()
+
+	log.Info().
+		Str("address", s.Address).
+		Msg("connected to Roon core, waiting for extension to be enabled")
+
+	token, err := os.ReadFile(filepath.Join(StateDir(), RoonTokenFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	registration := map[string]any{
+		"extension_id":      roonExtensionID,
+		"display_name":      "goscrobble",
+		"display_version":   "1.0.0",
+		"publisher":         "goscrobble",
+		"email":             "",
+		"website":           "https://github.com/p-mng/goscrobble",
+		"required_services": []string{roonTransport},
+		"optional_services": []string{},
+		"provided_services": []string{roonPing},
+	}
+	if len(token) > 0 {
+		registration["token"] = strings.TrimSpace(string(token))
+	}
+
+	const registerRequestID = 1
+	const subscribeRequestID = 2
+
+	if err := s.send(conn, RoonMessage{
+		Verb:      "REQUEST",
+		Name:      "com.roonlabs.registry:1/register",
+		RequestID: registerRequestID,
+		Body:      nil,
+	}, registration); err != nil {
+		return err
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		message, err := ParseRoonMessage(data)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case message.Verb == "REQUEST" && strings.HasPrefix(message.Name, roonPing):
+			if err := s.send(conn, RoonMessage{
+				Verb:      "COMPLETE",
+				Name:      "Success",
+				RequestID: message.RequestID,
+				Body:      nil,
+			}, nil); err != nil {
+				return err
+			}
+		case message.RequestID == registerRequestID && message.Name == "Registered":
+			var registered struct {
+				Token       string `json:"token"`
+				DisplayName string `json:"display_name"`
+			}
+			if err := json.Unmarshal(message.Body, &registered); err != nil {
+				return err
+			}
+
+			log.Info().
+				Str("core", registered.DisplayName).
+				Msg("registered as Roon extension")
+
+			if err := os.MkdirAll(StateDir(), 0700); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(StateDir(), RoonTokenFileName), []byte(registered.Token), 0600); err != nil {
+				return err
+			}
+
+			if err := s.send(conn, RoonMessage{
+				Verb:      "REQUEST",
+				Name:      roonTransport + "/subscribe_zones",
+				RequestID: subscribeRequestID,
+				Body:      nil,
+			}, map[string]any{"subscription_key": 0}); err != nil {
+				return err
+			}
+		case message.RequestID == registerRequestID:
+			return fmt.Errorf("unexpected registration response: %s", message.Name)
+		case message.RequestID == subscribeRequestID:
+			var zones roonZonesMessage
+			if err := json.Unmarshal(message.Body, &zones); err != nil {
+				return err
+			}
+			s.applyZones(zones)
+		}
+	}
+}
+
+func (s *RoonSource) send(conn *websocket.Conn, message RoonMessage, body any) error {
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		message.Body = encoded
+	}
+	return conn.WriteMessage(websocket.BinaryMessage, message.Bytes())
+}
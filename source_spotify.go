@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+const (
+	SpotifyAPIURL = "https://api.spotify.com/v1"
+	// name of the authenticator and the stored token
+	SpotifyTokenName = "spotify"
+	spotifyTimeout   = 10 * time.Second
+)
+
+var spotifyEndpoint = oauth2.Endpoint{
+	AuthURL:       "https://accounts.spotify.com/authorize",
+	DeviceAuthURL: "",
+	TokenURL:      "https://accounts.spotify.com/api/token",
+	AuthStyle:     oauth2.AuthStyleInParams,
+}
+
+// SpotifyOAuthConfig uses the authorization code flow with PKCE, which does
+// not require a client secret.
+func SpotifyOAuthConfig(clientID string) oauth2.Config {
+	return oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: "",
+		Endpoint:     spotifyEndpoint,
+		RedirectURL:  "",
+		Scopes:       []string{"user-read-playback-state"},
+	}
+}
+
+// SpotifySource reads the playback state of the Spotify account from the Web
+// API, so Spotify Connect devices (e.g., speakers or a phone) are scrobbled
+// as well.
+type SpotifySource struct {
+	APIURL string
+	// sends requests with the OAuth token of the account
+	Client *http.Client
+}
+
+// NewSpotifySource returns a source using the token saved by `goscrobble auth
+// login spotify`. Refreshed tokens are saved in the token store.
+func NewSpotifySource(c SpotifyConfig, store *TokenStore, notifier NotifierFunc) (SpotifySource, error) {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Transport:     nil,
+		CheckRedirect: nil,
+		Jar:           nil,
+		Timeout:       spotifyTimeout,
+	})
+
+	tokens, err := store.TokenSource(ctx, SpotifyTokenName, SpotifyOAuthConfig(c.ClientID), notifier)
+	if err != nil {
+		return SpotifySource{}, err
+	}
+
+	client := oauth2.NewClient(ctx, tokens)
+	client.Timeout = spotifyTimeout
+
+	return SpotifySource{APIURL: SpotifyAPIURL, Client: client}, nil
+}
+
+func (s SpotifySource) Name() string {
+	return "spotify"
+}
+
+type spotifyPlayer struct {
+	Device               spotifyDevice `json:"device"`
+	IsPlaying            bool          `json:"is_playing"`
+	ProgressMs           int64         `json:"progress_ms"`
+	CurrentlyPlayingType string        `json:"currently_playing_type"`
+	Item                 *spotifyTrack `json:"item"`
+}
+
+type spotifyDevice struct {
+	Name string `json:"name"`
+}
+
+type spotifyTrack struct {
+	Name       string          `json:"name"`
+	DurationMs int64           `json:"duration_ms"`
+	Artists    []spotifyArtist `json:"artists"`
+	Album      spotifyAlbum    `json:"album"`
+}
+
+type spotifyArtist struct {
+	Name string `json:"name"`
+}
+
+type spotifyAlbum struct {
+	Name string `json:"name"`
+}
+
+func (s SpotifySource) GetInfo(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) (map[string]PlaybackStatus, error) {
+	playerPlaybackStatus := map[string]PlaybackStatus{}
+
+	log.Debug().Msg("getting playback state from the Spotify Web API")
+
+	response, err := s.Client.Get(s.APIURL + "/me/player")
+	if err != nil {
+		return playerPlaybackStatus, err
+	}
+	defer CloseLogged(response.Body)
+
+	// nothing is playing on any device
+	if response.StatusCode == http.StatusNoContent {
+		return playerPlaybackStatus, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return playerPlaybackStatus, fmt.Errorf("unexpected status from Spotify Web API: %s", response.Status)
+	}
+
+	var player spotifyPlayer
+	if err := json.NewDecoder(response.Body).Decode(&player); err != nil {
+		return playerPlaybackStatus, fmt.Errorf("invalid response from Spotify Web API: %s", err.Error())
+	}
+
+	// episodes and ads have no track
+	if player.CurrentlyPlayingType != "track" || player.Item == nil {
+		return playerPlaybackStatus, nil
+	}
+
+	playerName := fmt.Sprintf("%s:%s", s.Name(), player.Device.Name)
+	if IsPlayerBlacklisted(playerBlacklist, playerName) {
+		return playerPlaybackStatus, nil
+	}
+
+	artists := make([]string, 0, len(player.Item.Artists))
+	for _, artist := range player.Item.Artists {
+		artists = append(artists, artist.Name)
+	}
+
+	state := PlaybackPaused
+	if player.IsPlaying {
+		state = PlaybackPlaying
+	}
+
+	playbackStatus := PlaybackStatus{
+		Scrobble: Scrobble{
+			Artists:   artists,
+			Track:     player.Item.Name,
+			Album:     player.Item.Album.Name,
+			Duration:  time.Duration(player.Item.DurationMs) * time.Millisecond,
+			Timestamp: time.Time{},
+		},
+		State:    state,
+		Position: time.Duration(player.ProgressMs) * time.Millisecond,
+	}
+	playbackStatus.RegexReplace(regexes)
+
+	playerPlaybackStatus[playerName] = playbackStatus
+	return playerPlaybackStatus, nil
+}
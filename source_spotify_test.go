@@ -0,0 +1,70 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpotifySource(t *testing.T) {
+	status := http.StatusOK
+	body := `{
+		"device": {"name": "Kitchen"},
+		"is_playing": true,
+		"progress_ms": 12500,
+		"currently_playing_type": "track",
+		"item": {
+			"name": "Meds",
+			"duration_ms": 163000,
+			"artists": [{"name": "Placebo"}, {"name": "Alison Mosshart"}],
+			"album": {"name": "Meds"}
+		}
+	}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/me/player", r.URL.Path)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	source := main.SpotifySource{APIURL: server.URL, Client: server.Client()}
+
+	info, err := source.GetInfo(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]main.PlaybackStatus{"spotify:Kitchen": {
+		Scrobble: main.Scrobble{
+			Artists:   []string{"Placebo", "Alison Mosshart"},
+			Track:     "Meds",
+			Album:     "Meds",
+			Duration:  163 * time.Second,
+			Timestamp: time.Time{},
+		},
+		State:    main.PlaybackPlaying,
+		Position: 12500 * time.Millisecond,
+	}}, info)
+
+	blacklist := []main.ParsedFilterRule{{Match: regexp.MustCompile("Kitchen"), Player: true}}
+	info, err = source.GetInfo(blacklist, nil)
+	require.NoError(t, err)
+	require.Empty(t, info)
+
+	body = `{"device": {"name": "Kitchen"}, "is_playing": true, "currently_playing_type": "episode", "item": null}`
+	info, err = source.GetInfo(nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, info)
+
+	status, body = http.StatusNoContent, ""
+	info, err = source.GetInfo(nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, info)
+
+	status = http.StatusUnauthorized
+	_, err = source.GetInfo(nil, nil)
+	require.Error(t, err)
+}
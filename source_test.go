@@ -2,7 +2,6 @@ package main_test
 
 import (
 	"errors"
-	"regexp"
 
 	main "github.com/p-mng/goscrobble"
 )
@@ -18,7 +17,7 @@ func (m FakeSource) Name() string {
 }
 
 func (m FakeSource) GetInfo(
-	_ []*regexp.Regexp,
+	_ []main.ParsedFilterRule,
 	_ []main.ParsedRegexReplace,
 ) (map[string]main.PlaybackStatus, error) {
 	if m.Empty {
@@ -0,0 +1,647 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// https://upnp.org/specs/av/UPnP-av-AVTransport-v1-Service.pdf
+const (
+	UPnPAVTransport       = "urn:schemas-upnp-org:service:AVTransport:1"
+	upnpSSDPAddress       = "239.255.255.250:1900"
+	upnpSubscribeTimeout  = 30 * time.Minute
+	upnpRenewBefore       = 2 * time.Minute
+	upnpMaintenanceTicker = time.Minute
+	upnpDiscoverInterval  = 5 * time.Minute
+)
+
+// UPnPSource subscribes to AVTransport events of DLNA/UPnP media renderers.
+// Transport state and track metadata are evented, while the playback position
+// is requested using `GetPositionInfo` on every poll.
+type UPnPSource struct {
+	Locations []string
+	Discover  bool
+
+	client       *http.Client
+	listener     net.Listener
+	mutex        sync.Mutex
+	renderers    map[string]*upnpRenderer
+	lastDiscover time.Time
+	done         chan struct{}
+}
+
+type upnpRenderer struct {
+	Location     string
+	FriendlyName string
+	ControlURL   string
+	EventURL     string
+
+	SID             string
+	SubscribedUntil time.Time
+
+	State    PlaybackState
+	Metadata Scrobble
+}
+
+type upnpDeviceDescription struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+type upnpDevice struct {
+	FriendlyName string        `xml:"friendlyName"`
+	Services     []upnpService `xml:"serviceList>service"`
+	Devices      []upnpDevice  `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+	EventSubURL string `xml:"eventSubURL"`
+}
+
+type upnpPropertySet struct {
+	LastChange string `xml:"property>LastChange"`
+}
+
+type upnpValue struct {
+	Val string `xml:"val,attr"`
+}
+
+type upnpLastChange struct {
+	InstanceID struct {
+		TransportState       *upnpValue `xml:"TransportState"`
+		CurrentTrackMetaData *upnpValue `xml:"CurrentTrackMetaData"`
+		CurrentTrackDuration *upnpValue `xml:"CurrentTrackDuration"`
+	} `xml:"InstanceID"`
+}
+
+type upnpPositionInfo struct {
+	TrackDuration string `xml:"Body>GetPositionInfoResponse>TrackDuration"`
+	TrackMetaData string `xml:"Body>GetPositionInfoResponse>TrackMetaData"`
+	RelTime       string `xml:"Body>GetPositionInfoResponse>RelTime"`
+}
+
+type upnpDIDLLite struct {
+	Title   string   `xml:"item>title"`
+	Artists []string `xml:"item>artist"`
+	Creator string   `xml:"item>creator"`
+	Album   string   `xml:"item>album"`
+}
+
+func NewUPnPSource(c UPnPConfig) (*UPnPSource, error) {
+	listener, err := net.Listen("tcp", c.CallbackAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &UPnPSource{
+		Locations:    c.Devices,
+		Discover:     c.Discover,
+		client:       &http.Client{Transport: nil, CheckRedirect: nil, Jar: nil, Timeout: 5 * time.Second},
+		listener:     listener,
+		mutex:        sync.Mutex{},
+		renderers:    map[string]*upnpRenderer{},
+		lastDiscover: time.Time{},
+		done:         make(chan struct{}),
+	}
+
+	log.Info().
+		Str("address", listener.Addr().String()).
+		Msg("listening for UPnP events")
+
+	go func() {
+		if err := NewHTTPServer(http.HandlerFunc(source.handleNotify)).Serve(listener); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Error().
+				Err(err).
+				Msg("UPnP event server stopped")
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(upnpMaintenanceTicker)
+		defer ticker.Stop()
+		for {
+			source.maintain()
+			select {
+			case <-ticker.C:
+			case <-source.done:
+				return
+			}
+		}
+	}()
+
+	return source, nil
+}
+
+// Close stops receiving events and renewing subscriptions. Renderers drop the
+// subscriptions once they expire.
+func (s *UPnPSource) Close() error {
+	close(s.done)
+	return s.listener.Close()
+}
+
+func (s *UPnPSource) Name() string {
+	return "upnp"
+}
+
+func (s *UPnPSource) GetInfo(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) (map[string]PlaybackStatus, error) {
+	s.mutex.Lock()
+	var renderers []upnpRenderer
+	for _, renderer := range s.renderers {
+		renderers = append(renderers, *renderer)
+	}
+	s.mutex.Unlock()
+
+	playerPlaybackStatus := map[string]PlaybackStatus{}
+
+	var errs []error
+	for _, renderer := range renderers {
+		if renderer.State == "" || renderer.State == PlaybackStopped {
+			continue
+		}
+		if IsPlayerBlacklisted(playerBlacklist, renderer.FriendlyName) {
+			continue
+		}
+
+		info, err := s.positionInfo(renderer.ControlURL)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", renderer.FriendlyName, err))
+			continue
+		}
+
+		scrobble := renderer.Metadata
+		if scrobble.Track == "" {
+			if scrobble, err = ParseDIDLLite(info.TrackMetaData); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", renderer.FriendlyName, err))
+				continue
+			}
+		}
+		if duration, err := ParseUPnPDuration(info.TrackDuration); err == nil && duration > 0 {
+			scrobble.Duration = duration
+		}
+
+		position, err := ParseUPnPDuration(info.RelTime)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", renderer.FriendlyName, err))
+			continue
+		}
+
+		playbackStatus := PlaybackStatus{
+			Scrobble: scrobble,
+			State:    renderer.State,
+			Position: position,
+		}
+
+		playbackStatus.RegexReplace(regexes)
+
+		playerName := fmt.Sprintf("%s:%s", s.Name(), renderer.FriendlyName)
+		playerPlaybackStatus[playerName] = playbackStatus
+	}
+
+	return playerPlaybackStatus, errors.Join(errs...)
+}
+
+// maintain adds new renderers and keeps event subscriptions alive.
+func (s *UPnPSource) maintain() {
+	locations := slices.Clone(s.Locations)
+	if s.Discover && time.Since(s.lastDiscover) > upnpDiscoverInterval {
+		discovered, err := DiscoverUPnPRenderers(3 * time.Second)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Msg("error discovering UPnP renderers")
+		}
+		locations = append(locations, discovered...)
+		s.lastDiscover = time.Now()
+	}
+
+	for _, location := range locations {
+		s.mutex.Lock()
+		_, ok := s.renderers[location]
+		s.mutex.Unlock()
+		if ok {
+			continue
+		}
+
+		renderer, err := s.describe(location)
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("location", location).
+				Msg("error reading UPnP device description")
+			continue
+		}
+
+		log.Info().
+			Str("location", location).
+			Str("name", renderer.FriendlyName).
+			Msg("found UPnP renderer")
+
+		s.mutex.Lock()
+		s.renderers[location] = renderer
+		s.mutex.Unlock()
+	}
+
+	s.mutex.Lock()
+	var expiring []*upnpRenderer
+	for _, renderer := range s.renderers {
+		if time.Until(renderer.SubscribedUntil) < upnpRenewBefore {
+			expiring = append(expiring, renderer)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, renderer := range expiring {
+		if err := s.subscribe(renderer); err != nil {
+			log.Error().
+				Err(err).
+				Str("name", renderer.FriendlyName).
+				Msg("error subscribing to UPnP events")
+		}
+	}
+}
+
+func (s *UPnPSource) describe(location string) (*upnpRenderer, error) {
+	response, err := s.client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseLogged(response.Body)
+
+	var description upnpDeviceDescription
+	if err := xml.NewDecoder(response.Body).Decode(&description); err != nil {
+		return nil, err
+	}
+
+	base := location
+	if description.URLBase != "" {
+		base = description.URLBase
+	}
+
+	device, service, ok := findUPnPService(description.Device, UPnPAVTransport)
+	if !ok {
+		return nil, errors.New("device does not provide an AVTransport service")
+	}
+
+	controlURL, err1 := resolveUPnPURL(base, service.ControlURL)
+	eventURL, err2 := resolveUPnPURL(base, service.EventSubURL)
+	if err := errors.Join(err1, err2); err != nil {
+		return nil, err
+	}
+
+	return &upnpRenderer{
+		Location:        location,
+		FriendlyName:    device.FriendlyName,
+		ControlURL:      controlURL,
+		EventURL:        eventURL,
+		SID:             "",
+		SubscribedUntil: time.Time{},
+		State:           "",
+		Metadata:        Scrobble{},
+	}, nil
+}
+
+func findUPnPService(device upnpDevice, serviceType string) (upnpDevice, upnpService, bool) {
+	for _, service := range device.Services {
+		if service.ServiceType == serviceType {
+			return device, service, true
+		}
+	}
+	for _, child := range device.Devices {
+		if device, service, ok := findUPnPService(child, serviceType); ok {
+			return device, service, true
+		}
+	}
+	return upnpDevice{}, upnpService{}, false
+}
+
+func resolveUPnPURL(base, reference string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	referenceURL, err := url.Parse(reference)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(referenceURL).String(), nil
+}
+
+// subscribe creates or renews the GENA event subscription for a renderer.
+func (s *UPnPSource) subscribe(renderer *upnpRenderer) error {
+	s.mutex.Lock()
+	sid := renderer.SID
+	eventURL := renderer.EventURL
+	s.mutex.Unlock()
+
+	request, err := http.NewRequest("SUBSCRIBE", eventURL, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("TIMEOUT", fmt.Sprintf("Second-%d", int(upnpSubscribeTimeout.Seconds())))
+
+	if sid != "" {
+		request.Header.Set("SID", sid)
+	} else {
+		callback, err := s.callbackURL(eventURL)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("CALLBACK", fmt.Sprintf("<%s>", callback))
+		request.Header.Set("NT", "upnp:event")
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(response.Body)
+
+	if response.StatusCode == http.StatusPreconditionFailed && sid != "" {
+		// the renderer forgot about the subscription, start a new one
+		s.mutex.Lock()
+		renderer.SID = ""
+		s.mutex.Unlock()
+		return s.subscribe(renderer)
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	timeout := upnpSubscribeTimeout
+	if seconds, err := strconv.Atoi(strings.TrimPrefix(response.Header.Get("TIMEOUT"), "Second-")); err == nil {
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	s.mutex.Lock()
+	renderer.SID = response.Header.Get("SID")
+	renderer.SubscribedUntil = time.Now().Add(timeout)
+	s.mutex.Unlock()
+
+	log.Debug().
+		Str("name", renderer.FriendlyName).
+		Str("sid", renderer.SID).
+		Dur("timeout", timeout).
+		Msg("subscribed to UPnP events")
+
+	return nil
+}
+
+// callbackURL returns the URL of the event server as seen from the renderer.
+func (s *UPnPSource) callbackURL(eventURL string) (string, error) {
+	parsed, err := url.Parse(eventURL)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := net.Dial("udp", net.JoinHostPort(parsed.Hostname(), "1900"))
+	if err != nil {
+		return "", err
+	}
+	defer CloseLogged(conn)
+
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	port := s.listener.Addr().(*net.TCPAddr).Port
+
+	return fmt.Sprintf("http://%s/", net.JoinHostPort(localIP.String(), strconv.Itoa(port))), nil
+}
+
+func (s *UPnPSource) handleNotify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "NOTIFY" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var propertySet upnpPropertySet
+	if err := xml.NewDecoder(http.MaxBytesReader(w, r.Body, 1024*1024)).Decode(&propertySet); err != nil {
+		http.Error(w, "invalid event body", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var renderer *upnpRenderer
+	for _, candidate := range s.renderers {
+		if candidate.SID != "" && candidate.SID == r.Header.Get("SID") {
+			renderer = candidate
+			break
+		}
+	}
+	if renderer == nil {
+		http.Error(w, "unknown subscription", http.StatusPreconditionFailed)
+		return
+	}
+
+	if propertySet.LastChange != "" {
+		if err := renderer.applyLastChange(propertySet.LastChange); err != nil {
+			log.Error().
+				Err(err).
+				Str("name", renderer.FriendlyName).
+				Msg("error parsing UPnP LastChange event")
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *upnpRenderer) applyLastChange(lastChange string) error {
+	var event upnpLastChange
+	if err := xml.Unmarshal([]byte(lastChange), &event); err != nil {
+		return err
+	}
+
+	if state := event.InstanceID.TransportState; state != nil {
+		r.State = UPnPPlaybackState(state.Val)
+	}
+	if metadata := event.InstanceID.CurrentTrackMetaData; metadata != nil {
+		scrobble, err := ParseDIDLLite(metadata.Val)
+		if err != nil {
+			return err
+		}
+		r.Metadata = scrobble
+	}
+	if duration := event.InstanceID.CurrentTrackDuration; duration != nil {
+		if parsed, err := ParseUPnPDuration(duration.Val); err == nil {
+			r.Metadata.Duration = parsed
+		}
+	}
+
+	log.Debug().
+		Str("name", r.FriendlyName).
+		Str("state", string(r.State)).
+		Interface("metadata", r.Metadata).
+		Msg("received UPnP LastChange event")
+
+	return nil
+}
+
+func (s *UPnPSource) positionInfo(controlURL string) (upnpPositionInfo, error) {
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetPositionInfo xmlns:u="` + UPnPAVTransport + `"><InstanceID>0</InstanceID></u:GetPositionInfo></s:Body>` +
+		`</s:Envelope>`
+
+	request, err := http.NewRequest(http.MethodPost, controlURL, strings.NewReader(body))
+	if err != nil {
+		return upnpPositionInfo{}, err
+	}
+	request.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	request.Header.Set("SOAPACTION", fmt.Sprintf(`"%s#GetPositionInfo"`, UPnPAVTransport))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return upnpPositionInfo{}, err
+	}
+	defer CloseLogged(response.Body)
+
+	if response.StatusCode != http.StatusOK {
+		return upnpPositionInfo{}, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	var info upnpPositionInfo
+	if err := xml.NewDecoder(response.Body).Decode(&info); err != nil {
+		return upnpPositionInfo{}, err
+	}
+	return info, nil
+}
+
+// DiscoverUPnPRenderers sends an SSDP search for AVTransport services and
+// returns the device description URLs of all renderers that responded.
+func DiscoverUPnPRenderers(wait time.Duration) ([]string, error) {
+	address, err := net.ResolveUDPAddr("udp4", upnpSSDPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer CloseLogged(conn)
+
+	search := strings.Join([]string{
+		"M-SEARCH * HTTP/1.1",
+		"HOST: " + upnpSSDPAddress,
+		`MAN: "ssdp:discover"`,
+		fmt.Sprintf("MX: %d", max(int(wait.Seconds())-1, 1)),
+		"ST: " + UPnPAVTransport,
+		"", "",
+	}, "\r\n")
+
+	if _, err := conn.WriteTo([]byte(search), address); err != nil {
+		return nil, err
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(wait)); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var locations []string
+
+	buffer := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return locations, err
+		}
+
+		response, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buffer[:n])), nil)
+		if err != nil {
+			continue
+		}
+		CloseLogged(response.Body)
+
+		location := response.Header.Get("LOCATION")
+		if location != "" && !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+		}
+	}
+
+	log.Debug().
+		Strs("locations", locations).
+		Msg("discovered UPnP renderers")
+
+	return locations, nil
+}
+
+func UPnPPlaybackState(transportState string) PlaybackState {
+	switch transportState {
+	case "PLAYING", "TRANSITIONING":
+		return PlaybackPlaying
+	case "PAUSED_PLAYBACK", "PAUSED_RECORDING":
+		return PlaybackPaused
+	default:
+		return PlaybackStopped
+	}
+}
+
+// ParseDIDLLite reads track metadata from a DIDL-Lite document.
+func ParseDIDLLite(metadata string) (Scrobble, error) {
+	if metadata == "" || metadata == "NOT_IMPLEMENTED" {
+		return Scrobble{}, nil
+	}
+
+	var didl upnpDIDLLite
+	if err := xml.Unmarshal([]byte(metadata), &didl); err != nil {
+		return Scrobble{}, err
+	}
+
+	artists := didl.Artists
+	if len(artists) == 0 && didl.Creator != "" {
+		artists = []string{didl.Creator}
+	}
+
+	return Scrobble{
+		Artists:   artists,
+		Track:     didl.Title,
+		Album:     didl.Album,
+		Duration:  0,
+		Timestamp: time.Time{},
+	}, nil
+}
+
+// ParseUPnPDuration parses durations in the `H+:MM:SS[.F+]` format.
+func ParseUPnPDuration(value string) (time.Duration, error) {
+	if value == "" || value == "NOT_IMPLEMENTED" {
+		return 0, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.ParseFloat(parts[2], 64)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second)), nil
+}
@@ -0,0 +1,51 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDIDLLite(t *testing.T) {
+	metadata := `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">` +
+		`<item id="1" parentID="0" restricted="1">` +
+		`<dc:title>Meds</dc:title><dc:creator>Placebo</dc:creator>` +
+		`<upnp:artist>Placebo</upnp:artist><upnp:artist>Alison Mosshart</upnp:artist>` +
+		`<upnp:album>Meds</upnp:album></item></DIDL-Lite>`
+
+	scrobble, err := main.ParseDIDLLite(metadata)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Placebo", "Alison Mosshart"}, scrobble.Artists)
+	require.Equal(t, "Meds", scrobble.Track)
+	require.Equal(t, "Meds", scrobble.Album)
+
+	scrobble, err = main.ParseDIDLLite("NOT_IMPLEMENTED")
+	require.NoError(t, err)
+	require.Empty(t, scrobble.Track)
+}
+
+func TestParseUPnPDuration(t *testing.T) {
+	durations := map[string]time.Duration{
+		"0:02:43":     2*time.Minute + 43*time.Second,
+		"1:00:00.500": time.Hour + 500*time.Millisecond,
+		"":            0,
+	}
+
+	for k, v := range durations {
+		actual, err := main.ParseUPnPDuration(k)
+		require.NoError(t, err)
+		require.Equal(t, v, actual)
+	}
+
+	_, err := main.ParseUPnPDuration("02:43")
+	require.Error(t, err)
+}
+
+func TestUPnPPlaybackState(t *testing.T) {
+	require.Equal(t, main.PlaybackPlaying, main.UPnPPlaybackState("PLAYING"))
+	require.Equal(t, main.PlaybackPaused, main.UPnPPlaybackState("PAUSED_PLAYBACK"))
+	require.Equal(t, main.PlaybackStopped, main.UPnPPlaybackState("NO_MEDIA_PRESENT"))
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const DefaultWebhookPlayer = "default"
+
+type TimestampTrust string
+
+const (
+	// use the time the daemon received the request
+	TimestampTrustDaemon = TimestampTrust("daemon")
+	// use the timestamp reported by the client, if any
+	TimestampTrustSource = TimestampTrust("source")
+)
+
+// WebhookSource accepts plays from arbitrary clients over HTTP. Now playing
+// updates are reported like any other player, while finished scrobbles are
+// handed to the main loop as-is.
+type WebhookSource struct {
+	Token      string
+	Timestamps TimestampTrust
+
+	mutex      sync.Mutex
+	listener   net.Listener
+	nowPlaying map[string]webhookNowPlaying
+	scrobbles  []webhookScrobble
+}
+
+// Received is taken from time.Now() and therefore carries a monotonic clock
+// reading, so extrapolating the playback position is not affected by changes
+// to the wall clock.
+type webhookNowPlaying struct {
+	Status   PlaybackStatus
+	Received time.Time
+}
+
+type webhookScrobble struct {
+	Player   string
+	Scrobble Scrobble
+	Received time.Time
+}
+
+// WebhookPayload is the JSON body accepted by `POST /api/now-playing` and
+// `POST /api/scrobble`. Durations and positions are given in seconds, the
+// timestamp as a unix timestamp.
+type WebhookPayload struct {
+	Player    string        `json:"player"`
+	Artists   []string      `json:"artists"`
+	Track     string        `json:"track"`
+	Album     string        `json:"album"`
+	Duration  float64       `json:"duration"`
+	Position  float64       `json:"position"`
+	State     PlaybackState `json:"state"`
+	Timestamp int64         `json:"timestamp"`
+}
+
+func NewWebhookSource(token string, timestamps TimestampTrust) *WebhookSource {
+	return &WebhookSource{
+		Token:      token,
+		Timestamps: timestamps,
+		mutex:      sync.Mutex{},
+		listener:   nil,
+		nowPlaying: map[string]webhookNowPlaying{},
+		scrobbles:  []webhookScrobble{},
+	}
+}
+
+// Listen starts serving webhook requests on the given address in the
+// background.
+func (s *WebhookSource) Listen(address string) error {
+	if s.Token == "" {
+		return errors.New("webhook source requires a token")
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.listener = listener
+	s.mutex.Unlock()
+
+	log.Info().
+		Str("address", listener.Addr().String()).
+		Msg("listening for webhook requests")
+
+	go func() {
+		if err := NewHTTPServer(s.Handler()).Serve(listener); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Error().
+				Err(err).
+				Msg("webhook server stopped")
+		}
+	}()
+
+	return nil
+}
+
+// Close stops serving webhook requests.
+func (s *WebhookSource) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+func (s *WebhookSource) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSource) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/now-playing", s.handleNowPlaying)
+	mux.HandleFunc("POST /api/scrobble", s.handleScrobble)
+	return mux
+}
+
+func (s *WebhookSource) GetInfo(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) (map[string]PlaybackStatus, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	playerPlaybackStatus := map[string]PlaybackStatus{}
+
+	for player, entry := range s.nowPlaying {
+		status := entry.Status
+		if status.State == PlaybackPlaying {
+			status.Position += now.Sub(entry.Received)
+		}
+
+		if status.Duration > 0 && status.Position > status.Duration {
+			log.Debug().
+				Str("player", player).
+				Msg("webhook track has ended")
+			delete(s.nowPlaying, player)
+			continue
+		}
+
+		if IsPlayerBlacklisted(playerBlacklist, player) {
+			continue
+		}
+
+		status.RegexReplace(regexes)
+
+		playerName := fmt.Sprintf("%s:%s", s.Name(), player)
+		playerPlaybackStatus[playerName] = status
+	}
+
+	return playerPlaybackStatus, nil
+}
+
+func (s *WebhookSource) ReceivedScrobbles(
+	playerBlacklist []ParsedFilterRule,
+	regexes []ParsedRegexReplace,
+) map[string][]Scrobble {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	received := map[string][]Scrobble{}
+	for _, entry := range s.scrobbles {
+		if IsPlayerBlacklisted(playerBlacklist, entry.Player) {
+			continue
+		}
+
+		scrobble := entry.Scrobble
+		if s.Timestamps != TimestampTrustSource || scrobble.Timestamp.IsZero() {
+			scrobble.Timestamp = entry.Received
+		}
+		scrobble.RegexReplace(regexes)
+
+		playerName := fmt.Sprintf("%s:%s", s.Name(), entry.Player)
+		received[playerName] = append(received[playerName], scrobble)
+	}
+	s.scrobbles = []webhookScrobble{}
+
+	return received
+}
+
+func (s *WebhookSource) handleNowPlaying(w http.ResponseWriter, r *http.Request) {
+	payload, ok := s.readPayload(w, r)
+	if !ok {
+		return
+	}
+
+	state := payload.State
+	if state == "" {
+		state = PlaybackPlaying
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if state == PlaybackStopped {
+		delete(s.nowPlaying, payload.Player)
+	} else {
+		s.nowPlaying[payload.Player] = webhookNowPlaying{
+			Status: PlaybackStatus{
+				Scrobble: payload.Scrobble(),
+				State:    state,
+				Position: time.Duration(payload.Position * float64(time.Second)),
+			},
+			Received: time.Now(),
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *WebhookSource) handleScrobble(w http.ResponseWriter, r *http.Request) {
+	payload, ok := s.readPayload(w, r)
+	if !ok {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.scrobbles = append(s.scrobbles, webhookScrobble{
+		Player:   payload.Player,
+		Scrobble: payload.Scrobble(),
+		Received: time.Now(),
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *WebhookSource) readPayload(w http.ResponseWriter, r *http.Request) (WebhookPayload, bool) {
+	if !BearerTokenValid(r, s.Token) {
+		log.Warn().
+			Str("remote", r.RemoteAddr).
+			Str("path", r.URL.Path).
+			Msg("rejected unauthorized webhook request")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return WebhookPayload{}, false
+	}
+
+	var payload WebhookPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&payload); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %s", err.Error()), http.StatusBadRequest)
+		return WebhookPayload{}, false
+	}
+
+	if err := payload.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return WebhookPayload{}, false
+	}
+
+	log.Debug().
+		Str("remote", r.RemoteAddr).
+		Str("path", r.URL.Path).
+		Interface("payload", payload).
+		Msg("received webhook request")
+
+	return payload, true
+}
+
+func (p *WebhookPayload) Validate() error {
+	if p.Player == "" {
+		p.Player = DefaultWebhookPlayer
+	}
+
+	switch {
+	case len(p.Artists) == 0:
+		return errors.New("missing artists")
+	case p.Track == "":
+		return errors.New("missing track")
+	case p.Duration < 0 || p.Position < 0:
+		return errors.New("duration and position must not be negative")
+	}
+
+	switch p.State {
+	case "", PlaybackPlaying, PlaybackPaused, PlaybackStopped:
+		return nil
+	default:
+		return fmt.Errorf("invalid state: %s", p.State)
+	}
+}
+
+func (p WebhookPayload) Scrobble() Scrobble {
+	var timestamp time.Time
+	if p.Timestamp > 0 {
+		timestamp = time.Unix(p.Timestamp, 0)
+	}
+
+	return Scrobble{
+		Artists:   p.Artists,
+		Track:     p.Track,
+		Album:     p.Album,
+		Duration:  time.Duration(p.Duration * float64(time.Second)),
+		Timestamp: timestamp,
+	}
+}
@@ -0,0 +1,88 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSource(t *testing.T) {
+	source := main.NewWebhookSource("secret", main.TimestampTrustSource)
+	handler := source.Handler()
+
+	post := func(path, token, body string) int {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	body := `{"player":"phone","artists":["Placebo"],"track":"Meds","album":"Meds","duration":163,"position":10}`
+
+	require.Equal(t, http.StatusUnauthorized, post("/api/now-playing", "wrong", body))
+	require.Equal(t, http.StatusBadRequest, post("/api/now-playing", "secret", `{"track":"Meds"}`))
+	require.Equal(t, http.StatusNoContent, post("/api/now-playing", "secret", body))
+
+	status, err := source.GetInfo(nil, nil)
+	require.NoError(t, err)
+	require.Len(t, status, 1)
+	require.Equal(t, "Meds", status["webhook:phone"].Track)
+	require.Equal(t, main.PlaybackPlaying, status["webhook:phone"].State)
+	require.GreaterOrEqual(t, status["webhook:phone"].Position, 10*time.Second)
+
+	require.Equal(t, http.StatusNoContent, post("/api/now-playing", "secret", `{"player":"phone","artists":["Placebo"],"track":"Meds","state":"Stopped"}`))
+
+	status, err = source.GetInfo(nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, status)
+
+	require.Equal(t, http.StatusNoContent, post("/api/scrobble", "secret", `{"artists":["Placebo"],"track":"Meds","timestamp":1699225080}`))
+
+	received := source.ReceivedScrobbles(nil, nil)
+	require.Len(t, received["webhook:default"], 1)
+	require.Equal(t, time.Unix(1699225080, 0), received["webhook:default"][0].Timestamp)
+	require.Empty(t, source.ReceivedScrobbles(nil, nil))
+}
+
+func TestWebhookConfigDefaultTimestamps(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), main.DefaultConfigFileName)
+	require.NoError(t, os.WriteFile(filename, []byte(`poll_rate = 5
+min_playback_duration = 240
+min_playback_percent = 50
+
+[sources.webhook]
+address = "127.0.0.1:7635"
+token_cmd = ["echo", "secret"]
+`), 0600))
+
+	// leaving out timestamps is not a mistake
+	require.Empty(t, main.CheckConfigFile(filename, true, false))
+
+	config, err := main.ReadConfig(filename)
+	require.NoError(t, err)
+	require.Equal(t, main.TimestampTrustDaemon, config.Sources.Webhook.Timestamps)
+}
+
+func TestWebhookSourceTimestamps(t *testing.T) {
+	source := main.NewWebhookSource("secret", main.TimestampTrustDaemon)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/scrobble", strings.NewReader(
+		`{"artists":["Placebo"],"track":"Meds","timestamp":1699225080}`,
+	))
+	request.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	source.Handler().ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+
+	received := source.ReceivedScrobbles(nil, nil)
+	require.Len(t, received["webhook:default"], 1)
+	require.WithinDuration(t, time.Now(), received["webhook:default"][0].Timestamp, time.Minute)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+)
+
+// number of errors kept for the status command
+const maxRecentErrors = 10
+
+// RecentError is an error of a source or sink, reported by the status command.
+type RecentError struct {
+	Time  time.Time `json:"time"`
+	Name  string    `json:"name"`
+	Error string    `json:"error"`
+}
+
+// PlayerProgress is the playback of a single player and the time left until
+// its track is scrobbled.
+type PlayerProgress struct {
+	Player    string         `json:"player"`
+	Status    PlaybackStatus `json:"status"`
+	Scrobbled bool           `json:"scrobbled"`
+	// the time the track has to be played to be scrobbled
+	Threshold time.Duration `json:"threshold"`
+	// zero once the track was scrobbled
+	UntilScrobble time.Duration `json:"until_scrobble"`
+}
+
+// rememberError keeps the most recent errors of sources and sinks.
+func (s *LoopState) rememberError(name string, err error, now time.Time) {
+	if err == nil {
+		return
+	}
+
+	s.RecentErrors = append(s.RecentErrors, RecentError{Time: now, Name: name, Error: err.Error()})
+	if len(s.RecentErrors) > maxRecentErrors {
+		s.RecentErrors = slices.Clone(s.RecentErrors[len(s.RecentErrors)-maxRecentErrors:])
+	}
+}
+
+// PlayersProgress returns the progress of all players with a track, sorted by
+// player name. Players of user profiles are prefixed with the user name.
+func PlayersProgress(state *LoopState, options LoopOptions, profiles []*UserProfile) []PlayerProgress {
+	progress := state.playersProgress(options, "")
+	for _, profile := range profiles {
+		progress = append(progress, profile.State.playersProgress(options, profile.Name+"/")...)
+	}
+
+	slices.SortFunc(progress, func(a, b PlayerProgress) int {
+		return cmp.Compare(a.Player, b.Player)
+	})
+	return progress
+}
+
+func (s *LoopState) playersProgress(options LoopOptions, prefix string) []PlayerProgress {
+	var progress []PlayerProgress
+	for _, player := range slices.Sorted(maps.Keys(s.CurrentlyPlaying)) {
+		status := s.CurrentlyPlaying[player]
+		if !status.HasMetadata() {
+			continue
+		}
+
+		p := PlayerProgress{
+			Player:        prefix + player,
+			Status:        status,
+			Scrobbled:     s.ScrobbledPrevious[player],
+			Threshold:     0,
+			UntilScrobble: 0,
+		}
+		minPlaybackDuration, minPlaybackPercent := options.PlayerThresholds(player, s.PlayerSources[player])
+		if minPlayTime, err := MinPlayTime(status.Duration, minPlaybackDuration, minPlaybackPercent); err == nil {
+			p.Threshold = minPlayTime
+			if !p.Scrobbled {
+				p.UntilScrobble = max(minPlayTime-s.PlayedTime[player], 0)
+			}
+		}
+		progress = append(progress, p)
+	}
+	return progress
+}
+
+// LastSinkScrobbles returns the last scrobble saved by each sink, including
+// the sinks of user profiles.
+func LastSinkScrobbles(state *LoopState, profiles []*UserProfile) map[string]Scrobble {
+	scrobbles := maps.Clone(state.LastSinkScrobbles)
+	for _, profile := range profiles {
+		maps.Copy(scrobbles, profile.State.LastSinkScrobbles)
+	}
+	return scrobbles
+}
+
+// RecentErrors returns the most recent errors, including the errors of user
+// profiles, oldest first.
+func RecentErrors(state *LoopState, profiles []*UserProfile) []RecentError {
+	errs := slices.Clone(state.RecentErrors)
+	for _, profile := range profiles {
+		errs = append(errs, profile.State.RecentErrors...)
+	}
+
+	slices.SortStableFunc(errs, func(a, b RecentError) int {
+		return a.Time.Compare(b.Time)
+	})
+	if len(errs) > maxRecentErrors {
+		errs = errs[len(errs)-maxRecentErrors:]
+	}
+	return errs
+}
+
+// PrettyPosition returns the position and duration of the track (e.g.,
+// "01:10 / 04:11").
+func (p PlayerProgress) PrettyPosition() string {
+	return prettyPosition(p.Status.Position, p.Status.Duration)
+}
+
+// ThresholdProgress returns how much of the threshold was played, between 0
+// and 1.
+func (p PlayerProgress) ThresholdProgress() float64 {
+	if p.Scrobbled || p.Threshold <= 0 {
+		return 1
+	}
+	return min(max(float64(p.Threshold-p.UntilScrobble)/float64(p.Threshold), 0), 1)
+}
@@ -0,0 +1,57 @@
+package main_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDaemonStatusDetails(t *testing.T) {
+	state := main.NewLoopState()
+	options := replayOptions()
+	notifier := FakeNotifier{}
+	sink := &FakeSink{}
+
+	status := defaultPlaybackStatus
+	status.Position = 0
+	source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+
+	run := func() {
+		main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	}
+
+	run()
+	progress := main.PlayersProgress(state, options, nil)
+	require.Len(t, progress, 1)
+	require.Equal(t, "player", progress[0].Player)
+	require.False(t, progress[0].Scrobbled)
+	require.Positive(t, progress[0].UntilScrobble)
+	require.LessOrEqual(t, progress[0].UntilScrobble, 4*time.Minute)
+	require.Empty(t, main.LastSinkScrobbles(state, nil))
+
+	status.Position = 200 * time.Second
+	source.players["player"] = status
+	run()
+	progress = main.PlayersProgress(state, options, nil)
+	require.Len(t, progress, 1)
+	require.True(t, progress[0].Scrobbled)
+	require.Zero(t, progress[0].UntilScrobble)
+	require.Equal(t, "03:20 / 04:11", progress[0].PrettyPosition())
+
+	last := main.LastSinkScrobbles(state, nil)
+	require.Len(t, last, 1)
+	require.Equal(t, defaultScrobble.Track, last["fake sink"].Track)
+
+	// only the most recent errors are kept
+	source.err = errors.New("connection refused")
+	for range 15 {
+		run()
+	}
+	recent := main.RecentErrors(state, nil)
+	require.Len(t, recent, 10)
+	require.Equal(t, "dbus", recent[0].Name)
+	require.Equal(t, "connection refused", recent[0].Error)
+}
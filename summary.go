@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// ListeningSummary counts the scrobbles of a time range.
+type ListeningSummary struct {
+	Scrobbles int
+	Artists   int
+	Albums    int
+	Tracks    int
+	// one entry per day from the first to the last scrobble, including days
+	// without scrobbles
+	Days []DayCount
+}
+
+type DayCount struct {
+	Day       time.Time
+	Scrobbles int
+}
+
+// SummarizeScrobbles counts the scrobbles, unique artists, albums, and tracks,
+// and the scrobbles per day in the given location. Names are compared
+// case-insensitively and albums and tracks of different artists with the same
+// name are counted separately. Scrobbles with multiple artists count for each
+// of them.
+func SummarizeScrobbles(scrobbles []Scrobble, location *time.Location) ListeningSummary {
+	summary := ListeningSummary{Scrobbles: len(scrobbles), Artists: 0, Albums: 0, Tracks: 0, Days: nil}
+	if len(scrobbles) == 0 {
+		return summary
+	}
+
+	artists := map[string]bool{}
+	albums := map[string]bool{}
+	tracks := map[string]bool{}
+	perDay := map[time.Time]int{}
+	var first, last time.Time
+
+	for _, scrobble := range scrobbles {
+		for _, artist := range scrobble.Artists {
+			artists[strings.ToLower(artist)] = true
+		}
+		if scrobble.Album != "" {
+			albums[strings.ToLower(scrobble.JoinArtists()+"\x00"+scrobble.Album)] = true
+		}
+		tracks[dedupeKey(scrobble)] = true
+
+		day := startOfDay(scrobble.Timestamp.In(location))
+		perDay[day]++
+		if first.IsZero() || day.Before(first) {
+			first = day
+		}
+		if day.After(last) {
+			last = day
+		}
+	}
+
+	summary.Artists = len(artists)
+	summary.Albums = len(albums)
+	summary.Tracks = len(tracks)
+	// AddDate instead of adding 24 hours, days are shorter or longer when
+	// daylight saving time starts or ends
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		summary.Days = append(summary.Days, DayCount{Day: day, Scrobbles: perDay[day]})
+	}
+
+	return summary
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,70 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeScrobbles(t *testing.T) {
+	day := time.Date(2025, time.March, 29, 10, 0, 0, 0, time.UTC)
+	scrobble := func(artists []string, track, album string, timestamp time.Time) main.Scrobble {
+		return main.Scrobble{Artists: artists, Track: track, Album: album, Duration: 251 * time.Second, Timestamp: timestamp}
+	}
+
+	scrobbles := []main.Scrobble{
+		scrobble([]string{"Placebo"}, "Pure Morning", "Without You I'm Nothing", day),
+		scrobble([]string{"placebo"}, "pure morning", "Without You I'm Nothing", day.Add(time.Hour)),
+		scrobble([]string{"Placebo", "David Bowie"}, "Without You I'm Nothing", "Without You I'm Nothing", day.Add(2*time.Hour)),
+		scrobble([]string{"Placebo"}, "Special K", "Black Market Music", day.AddDate(0, 0, 3)),
+	}
+
+	summary := main.SummarizeScrobbles(scrobbles, time.UTC)
+	require.Equal(t, 4, summary.Scrobbles)
+	require.Equal(t, 2, summary.Artists)
+	require.Equal(t, 3, summary.Albums)
+	require.Equal(t, 3, summary.Tracks)
+
+	require.Len(t, summary.Days, 4)
+	require.Equal(t, []int{3, 0, 0, 1}, []int{summary.Days[0].Scrobbles, summary.Days[1].Scrobbles, summary.Days[2].Scrobbles, summary.Days[3].Scrobbles})
+	require.Equal(t, "2025-03-29", summary.Days[0].Day.Format(time.DateOnly))
+	require.Equal(t, "2025-04-01", summary.Days[3].Day.Format(time.DateOnly))
+
+	require.Equal(t, main.ListeningSummary{}, main.SummarizeScrobbles(nil, time.UTC))
+}
+
+func TestSummarizeScrobblesRange(t *testing.T) {
+	sink := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv")}
+	for days := range 4 {
+		scrobble := defaultScrobble
+		scrobble.Timestamp = defaultScrobble.Timestamp.AddDate(0, 0, days)
+		require.NoError(t, sink.Scrobble(scrobble))
+	}
+
+	// scrobbles after the end of the range are not counted
+	from := defaultScrobble.Timestamp.AddDate(0, 0, 1)
+	to := defaultScrobble.Timestamp.AddDate(0, 0, 2)
+	scrobbles, err := sink.GetScrobbles(0, from, to)
+	require.NoError(t, err)
+
+	summary := main.SummarizeScrobbles(scrobbles, time.UTC)
+	require.Equal(t, 2, summary.Scrobbles)
+	require.Len(t, summary.Days, 2)
+	require.Equal(t, from.UTC().Format(time.DateOnly), summary.Days[0].Day.Format(time.DateOnly))
+	require.Equal(t, to.UTC().Format(time.DateOnly), summary.Days[1].Day.Format(time.DateOnly))
+}
+
+func TestSummarizeScrobblesLocation(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	scrobbles := []main.Scrobble{
+		{Artists: []string{"Placebo"}, Track: "Pure Morning", Album: "", Duration: 0, Timestamp: time.Date(2025, time.March, 29, 23, 0, 0, 0, time.UTC)},
+	}
+
+	summary := main.SummarizeScrobbles(scrobbles, location)
+	require.Equal(t, 0, summary.Albums)
+	require.Len(t, summary.Days, 1)
+	require.Equal(t, "2025-03-30", summary.Days[0].Day.Format(time.DateOnly))
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"time"
+)
+
+// SuspendThreshold is the minimum difference between the wall clock and the
+// monotonic clock that is considered a system suspend. Smaller differences
+// are caused by clock adjustments (e.g., NTP).
+const SuspendThreshold = 30 * time.Second
+
+// SuspendDetector detects system suspends between main loop iterations. The
+// monotonic clock does not advance while the system is suspended, but the
+// wall clock does.
+type SuspendDetector struct {
+	last time.Time
+}
+
+// Check returns how long the system was suspended since the previous call.
+func (d *SuspendDetector) Check(now time.Time) (time.Duration, bool) {
+	last := d.last
+	d.last = now
+
+	// the clock was set for the first time (e.g., by NTP after booting a
+	// device without a real-time clock)
+	if last.IsZero() || !ClockPlausible(last) {
+		return 0, false
+	}
+
+	suspended := SuspendedDuration(now.Round(0).Sub(last.Round(0)), now.Sub(last))
+	return suspended, suspended > 0
+}
+
+// SuspendedDuration returns the time the system was suspended, given the
+// elapsed wall clock and monotonic time, or 0 if it was not suspended.
+func SuspendedDuration(wall, monotonic time.Duration) time.Duration {
+	if wall-monotonic < SuspendThreshold {
+		return 0
+	}
+	return wall - monotonic
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// SleepWatcher receives the logind PrepareForSleep signal, which is sent
+// right before the system suspends. A nil watcher never sends.
+type SleepWatcher struct {
+	Sleep chan struct{}
+
+	conn    *dbus.Conn
+	signals chan *dbus.Signal
+}
+
+// WatchSleep returns nil if the system bus is not available (e.g., inside a
+// container), suspends are then only detected using the monotonic clock.
+func WatchSleep() *SleepWatcher {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("cannot connect to system bus, not watching for suspend")
+		return nil
+	}
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.login1.Manager"),
+		dbus.WithMatchMember("PrepareForSleep"),
+	); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("cannot subscribe to logind signals, not watching for suspend")
+		CloseLogged(conn)
+		return nil
+	}
+
+	watcher := &SleepWatcher{
+		Sleep:   make(chan struct{}, 1),
+		conn:    conn,
+		signals: make(chan *dbus.Signal, 10),
+	}
+	conn.Signal(watcher.signals)
+
+	go func() {
+		for signal := range watcher.signals {
+			// the argument is true before suspending and false after resuming
+			if len(signal.Body) == 0 {
+				continue
+			}
+			if start, ok := signal.Body[0].(bool); !ok || !start {
+				continue
+			}
+
+			log.Debug().Msg("system is about to suspend")
+			select {
+			case watcher.Sleep <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return watcher
+}
+
+func (w *SleepWatcher) Channel() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	return w.Sleep
+}
+
+func (w *SleepWatcher) Close() error {
+	if w == nil {
+		return nil
+	}
+	return w.conn.Close()
+}
@@ -0,0 +1,50 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuspendedDuration(t *testing.T) {
+	require.Equal(t, time.Duration(0), main.SuspendedDuration(2*time.Second, 2*time.Second))
+	require.Equal(t, time.Duration(0), main.SuspendedDuration(12*time.Second, 2*time.Second))
+	require.Equal(t, 8*time.Hour, main.SuspendedDuration(8*time.Hour+2*time.Second, 2*time.Second))
+
+	var detector main.SuspendDetector
+	now := time.Now()
+	_, ok := detector.Check(now)
+	require.False(t, ok)
+	_, ok = detector.Check(now.Add(2 * time.Second))
+	require.False(t, ok)
+}
+
+func TestDiscardPlayback(t *testing.T) {
+	state := main.NewLoopState()
+	paused := defaultPlaybackStatus
+	paused.State = main.PlaybackPaused
+	fakeSource := &FakeSource{PlaybackStatus: defaultPlaybackStatus}
+	fakeSink := &FakeSink{}
+
+	options := replayOptions()
+	options.MinPlaybackDuration = 60
+	fakeNotifier := FakeNotifier{}
+
+	main.RunMainLoopOnce(state, options, []main.Source{fakeSource}, []main.Sink{fakeSink}, fakeNotifier.SendNotification)
+	fakeSource.PlaybackStatus = paused
+	main.RunMainLoopOnce(state, options, []main.Source{fakeSource}, []main.Sink{fakeSink}, fakeNotifier.SendNotification)
+
+	// paused tracks are discarded as well
+	require.Equal(t, 1, state.DiscardPlayback())
+	require.Equal(t, 0, state.DiscardPlayback())
+
+	fakeSource.PlaybackStatus = defaultPlaybackStatus
+	fakeSource.PlaybackStatus.Position = 200 * time.Second
+	main.RunMainLoopOnce(state, options, []main.Source{fakeSource}, []main.Sink{fakeSink}, fakeNotifier.SendNotification)
+	fakeSource.PlaybackStatus.Position = 210 * time.Second
+	main.RunMainLoopOnce(state, options, []main.Source{fakeSource}, []main.Sink{fakeSink}, fakeNotifier.SendNotification)
+	require.Empty(t, fakeSink.ScrobbleLog)
+	require.Len(t, fakeSink.NowPlayingLog, 1)
+}
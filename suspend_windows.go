@@ -0,0 +1,17 @@
+package main
+
+// SleepWatcher is not available on Windows, suspends are only detected using
+// the monotonic clock. A nil watcher never sends.
+type SleepWatcher struct{}
+
+func WatchSleep() *SleepWatcher {
+	return nil
+}
+
+func (w *SleepWatcher) Channel() <-chan struct{} {
+	return nil
+}
+
+func (w *SleepWatcher) Close() error {
+	return nil
+}
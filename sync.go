@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// sinks may store slightly different timestamps for the same play (e.g., the
+// start of the track and the time it was scrobbled), but repeated plays of a
+// track are at least a track length apart
+const DefaultSyncTolerance = 2 * time.Minute
+
+// MissingScrobbles returns the scrobbles of source that are not in
+// destination. Two scrobbles are the same play if they have the same artists
+// and track (ignoring case) and their timestamps are at most tolerance apart.
+// Every scrobble of destination matches at most one scrobble of source, so
+// repeated plays are not lost.
+func MissingScrobbles(source, destination []Scrobble, tolerance time.Duration) []Scrobble {
+	type candidate struct {
+		timestamp time.Time
+		matched   bool
+	}
+
+	candidates := map[string][]*candidate{}
+	for _, scrobble := range destination {
+		key := dedupeKey(scrobble)
+		candidates[key] = append(candidates[key], &candidate{timestamp: scrobble.Timestamp, matched: false})
+	}
+
+	var missing []Scrobble
+	for _, scrobble := range source {
+		var closest *candidate
+		var closestDistance time.Duration
+		for _, c := range candidates[dedupeKey(scrobble)] {
+			distance := c.timestamp.Sub(scrobble.Timestamp).Abs()
+			if c.matched || distance > tolerance {
+				continue
+			}
+			if closest == nil || distance < closestDistance {
+				closest = c
+				closestDistance = distance
+			}
+		}
+
+		if closest == nil {
+			missing = append(missing, scrobble)
+			continue
+		}
+		closest.matched = true
+	}
+
+	return missing
+}
+
+// SyncScrobbles submits scrobbles to the destination sink and returns the
+// number of scrobbles saved. Local sinks are rewritten once, all other sinks
+// receive the scrobbles one by one.
+func SyncScrobbles(destination Sink, scrobbles []Scrobble) (int, error) {
+	if _, ok := UnwrapSink(destination).(EditableSink); ok {
+		return ImportScrobbles(destination, scrobbles)
+	}
+
+	submitted := 0
+	for _, scrobble := range scrobbles {
+		if err := destination.Scrobble(scrobble); err != nil {
+			log.Error().
+				Err(err).
+				Str("sink", destination.Name()).
+				Interface("scrobble", scrobble).
+				Msg("error submitting scrobble")
+			continue
+		}
+		submitted++
+	}
+
+	if submitted < len(scrobbles) {
+		return submitted, fmt.Errorf("%d scrobbles could not be submitted", len(scrobbles)-submitted)
+	}
+	return submitted, nil
+}
+
+// SyncDestinationScrobbles returns the scrobbles of the destination sink
+// between from and to, including deleted scrobbles of local sinks, so sync
+// runs do not restore them.
+func SyncDestinationScrobbles(destination Sink, from, to time.Time) ([]Scrobble, error) {
+	// the file of a new local sink does not exist yet
+	scrobbles, err := destination.GetScrobbles(0, from, to)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	tombstoneSink, ok := UnwrapSink(destination).(TombstoneSink)
+	if !ok {
+		return scrobbles, nil
+	}
+
+	tombstones, err := tombstoneSink.Tombstones()
+	if err != nil {
+		return nil, err
+	}
+	for _, tombstone := range tombstones {
+		scrobbles = append(scrobbles, tombstone.Scrobble)
+	}
+	return scrobbles, nil
+}
@@ -0,0 +1,93 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissingScrobbles(t *testing.T) {
+	at := func(scrobble main.Scrobble, offset time.Duration) main.Scrobble {
+		scrobble.Timestamp = defaultScrobble.Timestamp.Add(offset)
+		return scrobble
+	}
+	other := defaultScrobble
+	other.Track = "Pure Morning"
+	renamed := defaultScrobble
+	renamed.Track = "without you i'm nothing"
+
+	source := []main.Scrobble{
+		at(defaultScrobble, 0),
+		// played again on repeat
+		at(defaultScrobble, 251*time.Second),
+		at(other, 10*time.Minute),
+		at(other, time.Hour),
+	}
+	destination := []main.Scrobble{
+		at(renamed, 30*time.Second),
+		at(other, 11*time.Minute),
+	}
+
+	missing := main.MissingScrobbles(source, destination, main.DefaultSyncTolerance)
+	require.Len(t, missing, 2)
+	require.Equal(t, defaultScrobble.Track, missing[0].Track)
+	require.True(t, defaultScrobble.Timestamp.Add(251*time.Second).Equal(missing[0].Timestamp))
+	require.Equal(t, "Pure Morning", missing[1].Track)
+	require.True(t, defaultScrobble.Timestamp.Add(time.Hour).Equal(missing[1].Timestamp))
+
+	require.Len(t, main.MissingScrobbles(source, destination, 0), 4)
+	require.Empty(t, main.MissingScrobbles(nil, destination, main.DefaultSyncTolerance))
+}
+
+func TestSyncScrobbles(t *testing.T) {
+	remote := &FakeSink{}
+	submitted, err := main.SyncScrobbles(remote, []main.Scrobble{defaultScrobble})
+	require.NoError(t, err)
+	require.Equal(t, 1, submitted)
+	require.Len(t, remote.ScrobbleLog, 1)
+
+	local := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv")}
+	submitted, err = main.SyncScrobbles(local, []main.Scrobble{defaultScrobble})
+	require.NoError(t, err)
+	require.Equal(t, 1, submitted)
+
+	scrobbles, err := local.GetScrobbles(0, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 1)
+}
+
+func TestSyncDestinationScrobbles(t *testing.T) {
+	local := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv")}
+	existing, err := main.SyncDestinationScrobbles(local, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, existing)
+
+	require.NoError(t, local.Scrobble(defaultScrobble))
+	require.NoError(t, local.DeleteScrobbles([]main.Scrobble{defaultScrobble}))
+
+	// deleted scrobbles are not restored
+	existing, err = main.SyncDestinationScrobbles(local, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, main.MissingScrobbles([]main.Scrobble{defaultScrobble}, existing, main.DefaultSyncTolerance))
+}
+
+func TestSyncDestinationScrobblesRange(t *testing.T) {
+	local := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv")}
+	scrobbles := make([]main.Scrobble, 0, 3)
+	for hours := range 3 {
+		scrobble := defaultScrobble
+		scrobble.Timestamp = defaultScrobble.Timestamp.Add(time.Duration(hours) * time.Hour)
+		scrobbles = append(scrobbles, scrobble)
+		require.NoError(t, local.Scrobble(scrobble))
+	}
+
+	// scrobbles after the end of the range are skipped, not the whole range
+	to := defaultScrobble.Timestamp.Add(time.Hour)
+	existing, err := main.SyncDestinationScrobbles(local, defaultScrobble.Timestamp, to)
+	require.NoError(t, err)
+	require.Len(t, existing, 2)
+	require.Empty(t, main.MissingScrobbles(scrobbles[:2], existing, main.DefaultSyncTolerance))
+}
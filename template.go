@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/rs/zerolog/log"
+)
+
+type ScrobbleTemplate struct {
+	Artist *template.Template
+	Track  *template.Template
+	Album  *template.Template
+}
+
+// TemplateSink rewrites the fields of each scrobble using the configured
+// templates before passing it on to the wrapped sink.
+type TemplateSink struct {
+	Sink
+	Template ScrobbleTemplate
+}
+
+// WrapSinkTemplate returns the sink unchanged if no template is configured.
+func WrapSinkTemplate(sink Sink, c *TemplateConfig) (Sink, error) {
+	if c == nil {
+		return sink, nil
+	}
+
+	parsed, err := ParseScrobbleTemplate(*c)
+	if err != nil {
+		return nil, err
+	}
+
+	return TemplateSink{Sink: sink, Template: parsed}, nil
+}
+
+func ParseScrobbleTemplate(c TemplateConfig) (ScrobbleTemplate, error) {
+	var parsed ScrobbleTemplate
+	var err error
+
+	if c.Artist != "" {
+		if parsed.Artist, err = parseFieldTemplate("artist", c.Artist); err != nil {
+			return ScrobbleTemplate{}, err
+		}
+	}
+	if c.Track != "" {
+		if parsed.Track, err = parseFieldTemplate("track", c.Track); err != nil {
+			return ScrobbleTemplate{}, err
+		}
+	}
+	if c.Album != "" {
+		if parsed.Album, err = parseFieldTemplate("album", c.Album); err != nil {
+			return ScrobbleTemplate{}, err
+		}
+	}
+
+	return parsed, nil
+}
+
+func parseFieldTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+// Apply evaluates the templates with the original scrobble as context. Fields
+// without a template are left unchanged.
+func (t ScrobbleTemplate) Apply(s Scrobble) (Scrobble, error) {
+	applied := s
+
+	if t.Artist != nil {
+		artist, err := executeFieldTemplate(t.Artist, s)
+		if err != nil {
+			return Scrobble{}, err
+		}
+		applied.Artists = []string{artist}
+	}
+	if t.Track != nil {
+		track, err := executeFieldTemplate(t.Track, s)
+		if err != nil {
+			return Scrobble{}, err
+		}
+		applied.Track = track
+	}
+	if t.Album != nil {
+		album, err := executeFieldTemplate(t.Album, s)
+		if err != nil {
+			return Scrobble{}, err
+		}
+		applied.Album = album
+	}
+
+	return applied, nil
+}
+
+// PrintScrobbles writes one line per scrobble, formatted using the template
+// (e.g., `{{.Track}} — {{.JoinArtists}}`).
+func PrintScrobbles(w io.Writer, format string, scrobbles []Scrobble) error {
+	return printTemplate(w, format, scrobbles)
+}
+
+// PrintAggregatedScrobbles is PrintScrobbles for the merged scrobbles of
+// several sinks, the template can also use `{{.Sinks}}` and `{{.Missing}}`.
+func PrintAggregatedScrobbles(w io.Writer, format string, scrobbles []AggregatedScrobble) error {
+	return printTemplate(w, format, scrobbles)
+}
+
+func printTemplate[T any](w io.Writer, format string, items []T) error {
+	t, err := parseFieldTemplate("format", format)
+	if err != nil {
+		return fmt.Errorf("invalid format: %s", err.Error())
+	}
+
+	for _, item := range items {
+		line, err := executeFieldTemplate(t, item)
+		if err != nil {
+			return fmt.Errorf("invalid format: %s", err.Error())
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func executeFieldTemplate(t *template.Template, data any) (string, error) {
+	var builder strings.Builder
+	if err := t.Execute(&builder, data); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+func (s TemplateSink) Unwrap() Sink {
+	return s.Sink
+}
+
+func (s TemplateSink) NowPlaying(scrobble Scrobble) error {
+	applied, err := s.Template.Apply(scrobble)
+	if err != nil {
+		return err
+	}
+	return s.Sink.NowPlaying(applied)
+}
+
+func (s TemplateSink) Scrobble(scrobble Scrobble) error {
+	applied, err := s.Template.Apply(scrobble)
+	if err != nil {
+		return err
+	}
+
+	log.Debug().
+		Str("sink", s.Name()).
+		Interface("scrobble", applied).
+		Msg("applied submission template")
+
+	return s.Sink.Scrobble(applied)
+}
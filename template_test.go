@@ -0,0 +1,63 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrobbleTemplateApply(t *testing.T) {
+	parsed, err := main.ParseScrobbleTemplate(main.TemplateConfig{
+		Artist: "",
+		Track:  "{{.JoinArtists}} - {{.Track}}",
+		Album:  "{{.Album}} ({{.PrettyDuration}})",
+	})
+	require.NoError(t, err)
+
+	applied, err := parsed.Apply(defaultScrobble)
+	require.NoError(t, err)
+
+	require.Equal(t, defaultScrobble.Artists, applied.Artists)
+	require.Equal(t, "Placebo, David Bowie - Without You I'm Nothing", applied.Track)
+	require.Equal(t, "A Place For Us To Dream (04:11)", applied.Album)
+	require.Equal(t, defaultScrobble.Timestamp, applied.Timestamp)
+
+	_, err = main.ParseScrobbleTemplate(main.TemplateConfig{
+		Artist: "{{.Artist",
+		Track:  "",
+		Album:  "",
+	})
+	require.Error(t, err)
+}
+
+func TestWrapSinkTemplate(t *testing.T) {
+	fakeSink := &FakeSink{}
+
+	unwrapped, err := main.WrapSinkTemplate(fakeSink, nil)
+	require.NoError(t, err)
+	require.Equal(t, fakeSink, unwrapped)
+
+	wrapped, err := main.WrapSinkTemplate(fakeSink, &main.TemplateConfig{
+		Artist: "{{index .Artists 0}}",
+		Track:  "",
+		Album:  "",
+	})
+	require.NoError(t, err)
+
+	err = wrapped.Scrobble(defaultScrobble)
+	require.NoError(t, err)
+	require.Len(t, fakeSink.ScrobbleLog, 1)
+	require.Equal(t, []string{"Placebo"}, fakeSink.ScrobbleLog[0].Artists)
+}
+
+func TestPrintScrobbles(t *testing.T) {
+	var builder strings.Builder
+	err := main.PrintScrobbles(&builder, "{{.Track}} — {{.JoinArtists}} ({{.Timestamp.Unix}})", []main.Scrobble{defaultScrobble, defaultScrobble})
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("Without You I'm Nothing — Placebo, David Bowie (1699225080)\n", 2), builder.String())
+
+	require.Error(t, main.PrintScrobbles(&builder, "{{.Track", nil))
+	require.Error(t, main.PrintScrobbles(&builder, "{{.Invalid}}", []main.Scrobble{defaultScrobble}))
+}
@@ -0,0 +1,77 @@
+//go:build wasip1
+
+// Command plugin is an example goscrobble plugin. It drops tracks by
+// Nickelback and removes ` (Remastered)` from track names. Build it as a WASI
+// reactor:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o plugin.wasm
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"unsafe"
+)
+
+type event struct {
+	Event    string   `json:"event"`
+	Player   string   `json:"player"`
+	Artists  []string `json:"artists"`
+	Track    string   `json:"track"`
+	Album    string   `json:"album"`
+	Duration float64  `json:"duration"`
+}
+
+var input, output []byte
+
+//go:wasmimport goscrobble log
+func hostLog(message unsafe.Pointer, size uint32)
+
+func logMessage(message string) {
+	hostLog(unsafe.Pointer(unsafe.StringData(message)), uint32(len(message)))
+}
+
+//go:wasmexport alloc
+func alloc(size uint32) unsafe.Pointer {
+	input = make([]byte, size)
+	return unsafe.Pointer(unsafe.SliceData(input))
+}
+
+func decode(size uint32) (event, bool) {
+	var e event
+	if err := json.Unmarshal(input[:size], &e); err != nil {
+		logMessage("invalid event: " + err.Error())
+		return e, false
+	}
+	return e, true
+}
+
+//go:wasmexport filter
+func filter(_ unsafe.Pointer, size uint32) uint32 {
+	e, ok := decode(size)
+	if ok && slices.Contains(e.Artists, "Nickelback") {
+		return 0
+	}
+	return 1
+}
+
+//go:wasmexport transform
+func transform(_ unsafe.Pointer, size uint32) uint64 {
+	e, ok := decode(size)
+	// used by the tests to exceed the timeout
+	for ok && e.Track == "spin" {
+	}
+	if !ok || !strings.HasSuffix(e.Track, " (Remastered)") {
+		return 0
+	}
+	e.Track = strings.TrimSuffix(e.Track, " (Remastered)")
+
+	var err error
+	if output, err = json.Marshal(e); err != nil {
+		return 0
+	}
+	return uint64(uintptr(unsafe.Pointer(unsafe.SliceData(output))))<<32 | uint64(len(output))
+}
+
+func main() {}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+)
+
+const mprisPrefix = "org.mpris.MediaPlayer2."
+
+// PlayerIdentity returns the short name of a player without the source
+// prefix, e.g. `spotify` for `dbus:org.mpris.MediaPlayer2.spotify` or
+// `firefox` for `dbus:org.mpris.MediaPlayer2.firefox.instance_1_23`.
+func PlayerIdentity(player, source string) string {
+	identity := strings.TrimPrefix(player, source+":")
+	if rest, ok := strings.CutPrefix(identity, mprisPrefix); ok {
+		identity, _, _ = strings.Cut(rest, ".")
+	}
+	return identity
+}
+
+// PlayerThresholds returns the minimum playback duration and percentage for a
+// player. Overrides are looked up by the full player name, then by its
+// identity, and finally by the name of its source. Fields that are not set in
+// the override fall back to the global values.
+func (o LoopOptions) PlayerThresholds(player, source string) (int, int) {
+	duration, percent := o.MinPlaybackDuration, o.MinPlaybackPercent
+
+	override, ok := o.Players[player]
+	if !ok {
+		override, ok = o.Players[PlayerIdentity(player, source)]
+	}
+	if !ok {
+		override, ok = o.Players[source]
+	}
+	if !ok {
+		return duration, percent
+	}
+
+	if override.MinPlaybackDuration > 0 {
+		duration = override.MinPlaybackDuration
+	}
+	if override.MinPlaybackPercent > 0 {
+		percent = override.MinPlaybackPercent
+	}
+	return duration, percent
+}
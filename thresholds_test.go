@@ -0,0 +1,59 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayerIdentity(t *testing.T) {
+	require.Equal(t, "spotify", main.PlayerIdentity("dbus:org.mpris.MediaPlayer2.spotify", "dbus"))
+	require.Equal(t, "firefox", main.PlayerIdentity("dbus:org.mpris.MediaPlayer2.firefox.instance_1_23", "dbus"))
+	require.Equal(t, "com.spotify.client", main.PlayerIdentity("applescript:com.spotify.client", "applescript"))
+	require.Equal(t, "spotify", main.PlayerIdentity("spotify", ""))
+}
+
+func TestPlayerThresholds(t *testing.T) {
+	options := replayOptions()
+	options.Players = map[string]main.PlayerConfig{
+		"firefox":          {MinPlaybackDuration: 0, MinPlaybackPercent: 90},
+		"upnp":             {MinPlaybackDuration: 30, MinPlaybackPercent: 0},
+		"upnp:living-room": {MinPlaybackDuration: 60, MinPlaybackPercent: 0},
+	}
+
+	duration, percent := options.PlayerThresholds("dbus:org.mpris.MediaPlayer2.firefox.instance_1_23", "dbus")
+	require.Equal(t, 4*60, duration)
+	require.Equal(t, 90, percent)
+
+	duration, percent = options.PlayerThresholds("upnp:kitchen", "upnp")
+	require.Equal(t, 30, duration)
+	require.Equal(t, 50, percent)
+
+	duration, _ = options.PlayerThresholds("upnp:living-room", "upnp")
+	require.Equal(t, 60, duration)
+
+	duration, percent = options.PlayerThresholds("dbus:org.mpris.MediaPlayer2.spotify", "dbus")
+	require.Equal(t, 4*60, duration)
+	require.Equal(t, 50, percent)
+}
+
+func TestPlayerThresholdsInLoop(t *testing.T) {
+	state := main.NewLoopState()
+	options := replayOptions()
+	options.Players = map[string]main.PlayerConfig{"dbus": {MinPlaybackDuration: 30, MinPlaybackPercent: 0}}
+	notifier := FakeNotifier{}
+	sink := &FakeSink{}
+
+	started := defaultPlaybackStatus
+	started.Position = 0
+	source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"dbus:player": started}, err: nil}
+	main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+
+	playing := started
+	playing.Position = 40 * time.Second
+	source.players = map[string]main.PlaybackStatus{"dbus:player": playing}
+	main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+	require.Len(t, sink.ScrobbleLog, 1)
+}
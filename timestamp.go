@@ -0,0 +1,24 @@
+package main
+
+import "time"
+
+// ScrobbleTimestamp decides which time is submitted as the timestamp of a
+// scrobble.
+type ScrobbleTimestamp string
+
+const (
+	// the time the track started playing, as required by the last.fm API
+	// (default)
+	ScrobbleTimestampStart = ScrobbleTimestamp("start")
+	// the time the track reached the minimum playback time
+	ScrobbleTimestampThreshold = ScrobbleTimestamp("threshold")
+)
+
+// StartTime returns when a track that was first seen at now and position
+// started playing.
+func StartTime(now time.Time, position time.Duration) time.Time {
+	if position <= 0 {
+		return now
+	}
+	return now.Add(-position)
+}
@@ -0,0 +1,44 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartTime(t *testing.T) {
+	now := time.Now()
+	require.Equal(t, now.Add(-30*time.Second), main.StartTime(now, 30*time.Second))
+	require.Equal(t, now, main.StartTime(now, 0))
+}
+
+func TestLoopScrobbleTimestamp(t *testing.T) {
+	for _, mode := range []main.ScrobbleTimestamp{main.ScrobbleTimestampStart, main.ScrobbleTimestampThreshold} {
+		t.Run(string(mode), func(t *testing.T) {
+			state := main.NewLoopState()
+			sink := &FakeSink{}
+			notifier := FakeNotifier{}
+			options := replayOptions()
+			options.ScrobbleTimestamp = mode
+
+			// the track started playing a minute before the daemon saw it
+			started := time.Now().Add(-time.Minute)
+			for _, position := range []time.Duration{time.Minute, 200 * time.Second} {
+				status := defaultPlaybackStatus
+				status.Position = position
+				source := &multiPlayerSource{name: "dbus", players: map[string]main.PlaybackStatus{"player": status}, err: nil}
+				main.RunMainLoopOnce(state, options, []main.Source{source}, []main.Sink{sink}, notifier.SendNotification)
+			}
+
+			require.Len(t, sink.ScrobbleLog, 1)
+			timestamp := sink.ScrobbleLog[0].Timestamp
+			if mode == main.ScrobbleTimestampStart {
+				require.WithinDuration(t, started, timestamp, time.Second)
+			} else {
+				require.WithinDuration(t, time.Now(), timestamp, time.Second)
+			}
+		})
+	}
+}
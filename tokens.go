@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// tokens are stored in the system keyring as JSON, with the account
+	// `tokens.<name>` (e.g., `tokens.spotify`)
+	tokenAccountPrefix = "tokens."
+	oauthTimeout       = 5 * time.Minute
+)
+
+// StoredToken is an OAuth token together with the scopes it was granted for.
+type StoredToken struct {
+	Token  *oauth2.Token `json:"token"`
+	Scopes []string      `json:"scopes"`
+}
+
+// TokenStore persists the OAuth tokens of sinks and sources, keyed by the name
+// of the sink or source.
+type TokenStore struct {
+	Get    func(account string) (string, error)
+	Set    func(account, secret string) error
+	Remove func(account string) error
+
+	mutex sync.Mutex
+}
+
+// NewTokenStore returns a token store that keeps the tokens in the system
+// keyring.
+func NewTokenStore() *TokenStore {
+	return &TokenStore{Get: KeyringGet, Set: KeyringSet, Remove: KeyringDelete, mutex: sync.Mutex{}}
+}
+
+func (s *TokenStore) Load(name string) (StoredToken, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := s.Get(tokenAccountPrefix + name)
+	if errors.Is(err, ErrKeyringNotFound) {
+		return StoredToken{}, false, nil
+	} else if err != nil {
+		return StoredToken{}, false, err
+	}
+
+	var token StoredToken
+	if err := json.Unmarshal([]byte(data), &token); err != nil {
+		return StoredToken{}, false, fmt.Errorf("invalid stored token: %s", err.Error())
+	}
+	return token, true, nil
+}
+
+func (s *TokenStore) Save(name string, token StoredToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return s.Set(tokenAccountPrefix+name, string(data))
+}
+
+func (s *TokenStore) Delete(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.Remove(tokenAccountPrefix + name); err != nil && !errors.Is(err, ErrKeyringNotFound) {
+		return err
+	}
+	return nil
+}
+
+// TokenSource returns a token source that refreshes the stored token once it
+// expires and saves refreshed tokens. If the refresh token is rejected, a
+// desktop notification asks the user to authenticate again.
+func (s *TokenStore) TokenSource(
+	ctx context.Context,
+	name string,
+	config oauth2.Config,
+	notifier NotifierFunc,
+) (oauth2.TokenSource, error) {
+	stored, ok, err := s.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s is not authenticated (run `goscrobble auth login %s`)", name, name)
+	}
+
+	return &refreshingTokenSource{
+		name:     name,
+		store:    s,
+		scopes:   stored.Scopes,
+		notifier: notifier,
+		mutex:    sync.Mutex{},
+		base:     config.TokenSource(ctx, stored.Token),
+		last:     stored.Token,
+		notified: false,
+	}, nil
+}
+
+type refreshingTokenSource struct {
+	name     string
+	store    *TokenStore
+	scopes   []string
+	notifier NotifierFunc
+
+	mutex    sync.Mutex
+	base     oauth2.TokenSource
+	last     *oauth2.Token
+	notified bool
+}
+
+func (t *refreshingTokenSource) Token() (*oauth2.Token, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	token, err := t.base.Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && !t.notified {
+			t.notified = true
+			t.notifyReauthorization(retrieveErr)
+		}
+		return nil, err
+	}
+	t.notified = false
+
+	if token.AccessToken != t.last.AccessToken {
+		log.Debug().
+			Str("name", t.name).
+			Time("expiry", token.Expiry).
+			Msg("refreshed OAuth token")
+
+		if err := t.store.Save(t.name, StoredToken{Token: token, Scopes: t.scopes}); err != nil {
+			log.Error().
+				Err(err).
+				Str("name", t.name).
+				Msg("error saving refreshed OAuth token")
+		}
+		t.last = token
+	}
+
+	return token, nil
+}
+
+func (t *refreshingTokenSource) notifyReauthorization(err error) {
+	log.Error().
+		Err(err).
+		Str("name", t.name).
+		Msg("OAuth token was rejected, re-authorization is required")
+
+	if _, err := t.notifier(
+		uint32(0),
+		fmt.Sprintf("%c re-authorization required (%s)", RuneWarningSign, t.name),
+		fmt.Sprintf("run `goscrobble auth login %s` to authenticate again", t.name),
+	); err != nil {
+		log.Error().
+			Err(err).
+			Msg("error sending desktop notification")
+	}
+}
+
+// OAuthLogin runs the authorization code flow with PKCE, receiving the code on
+// a temporary loopback listener. The redirect URL of the config is replaced.
+// If the browser runs on another device (options.NoBrowser), the loopback
+// address cannot be reached, so the URL the browser was redirected to can be
+// pasted instead.
+func OAuthLogin(ctx context.Context, config oauth2.Config, opener []string, options LoginOptions) (*oauth2.Token, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer CloseLogged(listener)
+
+	config.RedirectURL = "http://" + listener.Addr().String() + "/callback"
+	state := rand.Text()
+	verifier := oauth2.GenerateVerifier()
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+
+	callback := func(query url.Values) bool {
+		switch {
+		case query.Get("state") != state:
+			return false
+		case query.Get("error") != "":
+			select {
+			case errs <- fmt.Errorf("authorization failed: %s", query.Get("error")):
+			default:
+			}
+		default:
+			select {
+			case codes <- query.Get("code"):
+			default:
+			}
+		}
+		return true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /callback", func(w http.ResponseWriter, r *http.Request) {
+		if !callback(r.URL.Query()) {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprintln(w, "Authorization finished, you can close this window.")
+	})
+
+	go func() {
+		if err := NewHTTPServer(mux).Serve(listener); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Debug().
+				Err(err).
+				Msg("OAuth callback server stopped")
+		}
+	}()
+
+	authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(verifier))
+	if options.NoBrowser {
+		fmt.Println("Please open the following URL on any device and authorize the application:", authURL)
+		fmt.Println("The browser is then redirected to a page that cannot be loaded, paste its URL here:")
+
+		go func() {
+			input := bufio.NewScanner(os.Stdin)
+			for input.Scan() {
+				redirected, err := url.Parse(strings.TrimSpace(input.Text()))
+				if err == nil && callback(redirected.Query()) {
+					return
+				}
+				fmt.Println("Invalid URL, paste the full URL from the address bar:")
+			}
+		}()
+	} else {
+		if err := OpenURL(opener, authURL); err != nil {
+			fmt.Println("Error opening URL in default browser:", err.Error())
+		}
+		fmt.Println("Please open the following URL in your browser and authorize the application:", authURL)
+	}
+
+	select {
+	case code := <-codes:
+		return config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	case err := <-errs:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(oauthTimeout):
+		return nil, errors.New("timed out waiting for authorization")
+	}
+}
+
+// OAuthAuthenticator manages OAuth tokens in a token store. Tokens with a
+// refresh token are refreshed automatically, so they do not expire.
+type OAuthAuthenticator struct {
+	Integration string
+	Config      oauth2.Config
+	Store       *TokenStore
+}
+
+func (a OAuthAuthenticator) Name() string {
+	return a.Integration
+}
+
+func (a OAuthAuthenticator) Status(Config) AuthStatus {
+	stored, ok, err := a.Store.Load(a.Integration)
+	if err != nil || !ok || stored.Token == nil {
+		return AuthStatus{Authenticated: false, Account: "", Expires: time.Time{}, Scopes: nil}
+	}
+
+	expires := stored.Token.Expiry
+	if stored.Token.RefreshToken != "" {
+		expires = time.Time{}
+	}
+
+	return AuthStatus{Authenticated: true, Account: "", Expires: expires, Scopes: stored.Scopes}
+}
+
+func (a OAuthAuthenticator) Login(c *Config, options LoginOptions) (bool, error) {
+	if !KeyringAvailable() {
+		return false, errors.New("OAuth tokens are stored in the system keyring, which is not available")
+	}
+
+	token, err := OAuthLogin(context.Background(), a.Config, c.Opener, options)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Println("Logged in to", a.Integration)
+	return false, a.Store.Save(a.Integration, StoredToken{Token: token, Scopes: a.Config.Scopes})
+}
+
+func (a OAuthAuthenticator) Logout(*Config) (bool, error) {
+	return false, a.Store.Delete(a.Integration)
+}
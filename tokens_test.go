@@ -0,0 +1,97 @@
+package main_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestTokenStore(t *testing.T) {
+	keyring := fakeKeyring{}
+	store := &main.TokenStore{Get: keyring.Get, Set: keyring.Set, Remove: keyring.Delete}
+
+	_, ok, err := store.Load("spotify")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	token := main.StoredToken{Token: &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}, Scopes: []string{"read"}}
+	require.NoError(t, store.Save("spotify", token))
+
+	loaded, ok, err := store.Load("spotify")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "refresh", loaded.Token.RefreshToken)
+	require.Equal(t, []string{"read"}, loaded.Scopes)
+	require.Contains(t, keyring, "tokens.spotify")
+
+	authenticator := main.OAuthAuthenticator{Integration: "spotify", Config: oauth2.Config{}, Store: store}
+	status := authenticator.Status(main.DefaultConfig)
+	require.True(t, status.Authenticated)
+	require.Equal(t, "never", status.PrettyExpires())
+
+	changed, err := authenticator.Logout(nil)
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.False(t, authenticator.Status(main.DefaultConfig).Authenticated)
+	require.Empty(t, keyring)
+	require.NoError(t, store.Delete("spotify"))
+}
+
+func TestTokenSource(t *testing.T) {
+	reject := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if reject {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"refreshed","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	config := oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: server.URL}}
+	keyring := fakeKeyring{}
+	store := &main.TokenStore{Get: keyring.Get, Set: keyring.Set, Remove: keyring.Delete}
+	require.NoError(t, store.Save("spotify", main.StoredToken{
+		Token: &oauth2.Token{AccessToken: "expired", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)},
+	}))
+
+	notifier := FakeNotifier{}
+
+	_, err := store.TokenSource(context.Background(), "google-sheets", config, notifier.SendNotification)
+	require.Error(t, err)
+
+	source, err := store.TokenSource(context.Background(), "spotify", config, notifier.SendNotification)
+	require.NoError(t, err)
+
+	token, err := source.Token()
+	require.NoError(t, err)
+	require.Equal(t, "refreshed", token.AccessToken)
+
+	// the refreshed token keeps the previous refresh token
+	stored, _, err := store.Load("spotify")
+	require.NoError(t, err)
+	require.Equal(t, "refreshed", stored.Token.AccessToken)
+	require.Equal(t, "refresh", stored.Token.RefreshToken)
+
+	reject = true
+	require.NoError(t, store.Save("spotify", main.StoredToken{
+		Token: &oauth2.Token{AccessToken: "expired", RefreshToken: "revoked", Expiry: time.Now().Add(-time.Hour)},
+	}))
+
+	source, err = store.TokenSource(context.Background(), "spotify", config, notifier.SendNotification)
+	require.NoError(t, err)
+
+	_, err = source.Token()
+	require.Error(t, err)
+	_, err = source.Token()
+	require.Error(t, err)
+	require.Equal(t, 1, notifier.Notifications)
+}
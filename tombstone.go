@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TombstoneMarker is the first column of tombstone records in CSV sinks,
+// where regular records start with the artist.
+const TombstoneMarker = "goscrobble:tombstone"
+
+// Tombstone marks a deleted scrobble, so sync runs do not restore it from
+// other sinks.
+type Tombstone struct {
+	Scrobble Scrobble
+	Deleted  time.Time
+}
+
+// TombstoneSink is implemented by local sinks that soft-delete scrobbles.
+type TombstoneSink interface {
+	Sink
+	DeleteScrobbles(scrobbles []Scrobble) error
+	Tombstones() ([]Tombstone, error)
+	PurgeTombstones(before time.Time) (int, error)
+}
+
+func (t Tombstone) ToStringSlice() []string {
+	return []string{
+		TombstoneMarker,
+		t.Scrobble.JoinArtists(),
+		t.Scrobble.Track,
+		t.Scrobble.Timestamp.Format(time.RFC1123),
+		t.Deleted.Format(time.RFC1123),
+	}
+}
+
+func IsTombstoneRecord(record []string) bool {
+	return len(record) > 0 && record[0] == TombstoneMarker
+}
+
+func TombstoneFromRecord(record []string) (Tombstone, error) {
+	if !IsTombstoneRecord(record) || len(record) != 5 {
+		return Tombstone{}, errors.New("invalid tombstone record")
+	}
+
+	timestamp, err := time.Parse(time.RFC1123, record[3])
+	if err != nil {
+		return Tombstone{}, err
+	}
+	deleted, err := time.Parse(time.RFC1123, record[4])
+	if err != nil {
+		return Tombstone{}, err
+	}
+
+	return Tombstone{
+		Scrobble: Scrobble{
+			Artists:   strings.Split(record[1], ", "),
+			Track:     record[2],
+			Album:     "",
+			Duration:  0,
+			Timestamp: timestamp.In(time.Local),
+		},
+		Deleted: deleted.In(time.Local),
+	}, nil
+}
+
+// TombstoneKeys returns the keys of all deleted scrobbles.
+func TombstoneKeys(tombstones []Tombstone) map[string]bool {
+	keys := map[string]bool{}
+	for _, tombstone := range tombstones {
+		keys[tombstone.Scrobble.Key()] = true
+	}
+	return keys
+}
+
+// WithoutDeleted removes the scrobbles that were deleted from a local sink.
+// Deleted scrobbles are only restored by writing them explicitly, e.g. by
+// editing, not by imports or rebuilds.
+func WithoutDeleted(sink Sink, scrobbles []Scrobble) ([]Scrobble, error) {
+	tombstoneSink, ok := UnwrapSink(sink).(TombstoneSink)
+	if !ok {
+		return scrobbles, nil
+	}
+
+	// the file of a new local sink does not exist yet
+	tombstones, err := tombstoneSink.Tombstones()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error fetching tombstones: %s", err.Error())
+	}
+
+	deleted := TombstoneKeys(tombstones)
+	return slices.DeleteFunc(slices.Clone(scrobbles), func(scrobble Scrobble) bool {
+		return deleted[scrobble.Key()]
+	}), nil
+}
@@ -0,0 +1,73 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVSinkTombstones(t *testing.T) {
+	sink := main.CSVSink{Key: "default", Filename: filepath.Join(t.TempDir(), "scrobbles.csv"), SigningKey: nil}
+
+	later := defaultScrobble
+	later.Timestamp = defaultScrobble.Timestamp.Add(time.Hour)
+
+	require.NoError(t, sink.Scrobble(defaultScrobble))
+	require.NoError(t, sink.Scrobble(later))
+
+	other := defaultScrobble
+	other.Track = "Every You Every Me"
+
+	require.NoError(t, sink.DeleteScrobbles([]main.Scrobble{defaultScrobble, other}))
+
+	scrobbles, err := sink.GetScrobbles(0, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 1)
+	require.Equal(t, later.Key(), scrobbles[0].Key())
+
+	tombstones, err := sink.Tombstones()
+	require.NoError(t, err)
+	require.Len(t, tombstones, 2)
+	require.True(t, main.TombstoneKeys(tombstones)[defaultScrobble.Key()])
+	require.True(t, main.TombstoneKeys(tombstones)[other.Key()])
+
+	// imports do not restore deleted scrobbles
+	added, err := main.ImportScrobbles(sink, []main.Scrobble{defaultScrobble, later})
+	require.NoError(t, err)
+	require.Zero(t, added)
+
+	// scrobbles that are written again are restored
+	require.NoError(t, sink.ReplaceScrobbles([]main.Scrobble{defaultScrobble, later}))
+
+	scrobbles, err = sink.GetScrobbles(0, time.Time{}, time.Now())
+	require.NoError(t, err)
+	require.Len(t, scrobbles, 2)
+
+	tombstones, err = sink.Tombstones()
+	require.NoError(t, err)
+	require.Len(t, tombstones, 1)
+	require.True(t, main.TombstoneKeys(tombstones)[other.Key()])
+
+	require.NoError(t, sink.Scrobble(other))
+
+	tombstones, err = sink.Tombstones()
+	require.NoError(t, err)
+	require.Empty(t, tombstones)
+
+	require.NoError(t, sink.DeleteScrobbles([]main.Scrobble{defaultScrobble, other}))
+
+	purged, err := sink.PurgeTombstones(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 0, purged)
+
+	purged, err = sink.PurgeTombstones(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, 2, purged)
+
+	tombstones, err = sink.Tombstones()
+	require.NoError(t, err)
+	require.Empty(t, tombstones)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	DefaultTopLimit = 10
+	// maximum width of names and bars in charts printed by `goscrobble top`
+	topNameWidth = 40
+	topBarWidth  = 30
+)
+
+// TopCharts are the charts of `goscrobble top`, by name.
+var TopCharts = map[string]func([]Scrobble, int) []ChartEntry{
+	"artists": TopArtists,
+	"albums":  TopAlbums,
+	"tracks":  TopTracks,
+}
+
+// ParseSince parses the time range of `goscrobble top`: a number of days
+// (e.g., `30d`) or weeks (e.g., `2w`), a Go duration (e.g., `12h`), or `all`
+// for the whole history, which is returned as 0.
+func ParseSince(value string) (time.Duration, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "all" {
+		return 0, nil
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.Atoi(number)
+			if err != nil || count <= 0 {
+				return 0, fmt.Errorf("invalid time range: %q", value)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid time range: %q", value)
+	}
+	return duration, nil
+}
+
+// ChartBars renders a chart as lines with the rank, name, a bar scaled to the
+// most played entry, and the number of scrobbles.
+func ChartBars(entries []ChartEntry) []string {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	nameWidth := 0
+	maxPlays := 0
+	for _, entry := range entries {
+		name := TruncateText(entry.String(), topNameWidth)
+		names = append(names, name)
+		nameWidth = max(nameWidth, utf8.RuneCountInString(name))
+		maxPlays = max(maxPlays, entry.Plays)
+	}
+	rankWidth := len(strconv.Itoa(len(entries)))
+
+	lines := make([]string, 0, len(entries))
+	for i, entry := range entries {
+		bar := max(entry.Plays*topBarWidth/maxPlays, 1)
+		lines = append(lines, fmt.Sprintf("%*d. %s%s  %s %d",
+			rankWidth, i+1,
+			names[i], strings.Repeat(" ", nameWidth-utf8.RuneCountInString(names[i])),
+			strings.Repeat("█", bar), entry.Plays,
+		))
+	}
+	return lines
+}
@@ -0,0 +1,42 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSince(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"2W":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"all": 0,
+	} {
+		since, err := main.ParseSince(value)
+		require.NoError(t, err, value)
+		require.Equal(t, expected, since, value)
+	}
+
+	for _, value := range []string{"", "d", "-3d", "0d", "3x", "-1h"} {
+		_, err := main.ParseSince(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestChartBars(t *testing.T) {
+	require.Empty(t, main.ChartBars(nil))
+
+	lines := main.ChartBars([]main.ChartEntry{
+		{Name: "Placebo", Artist: "", Plays: 30},
+		{Name: "Muse", Artist: "", Plays: 15},
+		{Name: "Portishead", Artist: "", Plays: 1},
+	})
+	require.Equal(t, []string{
+		"1. Placebo     ██████████████████████████████ 30",
+		"2. Muse        ███████████████ 15",
+		"3. Portishead  █ 1",
+	}, lines)
+}
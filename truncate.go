@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const RuneEllipsis = '…'
+
+// TruncateSink shortens the fields of each scrobble before passing it on to
+// the wrapped sink, e.g. for services with length limits. The stored scrobble
+// itself is not modified.
+type TruncateSink struct {
+	Sink
+	Rules TruncateConfig
+}
+
+// WrapSinkTruncate returns the sink unchanged if no limits are configured.
+func WrapSinkTruncate(sink Sink, c TruncateConfig) Sink {
+	if c == (TruncateConfig{}) {
+		return sink
+	}
+	return TruncateSink{Sink: sink, Rules: c}
+}
+
+func (s TruncateSink) Unwrap() Sink {
+	return s.Sink
+}
+
+func (s TruncateSink) NowPlaying(scrobble Scrobble) error {
+	return s.Sink.NowPlaying(s.Rules.Apply(scrobble))
+}
+
+func (s TruncateSink) Scrobble(scrobble Scrobble) error {
+	return s.Sink.Scrobble(s.Rules.Apply(scrobble))
+}
+
+// Apply returns a copy of the scrobble with all fields shortened to the
+// configured number of characters. A limit of 0 disables truncation.
+func (c TruncateConfig) Apply(s Scrobble) Scrobble {
+	truncated := s
+
+	if c.Artist > 0 && utf8.RuneCountInString(s.JoinArtists()) > c.Artist {
+		truncated.Artists = []string{TruncateText(s.JoinArtists(), c.Artist)}
+	}
+	truncated.Track = TruncateText(s.Track, c.Track)
+	truncated.Album = TruncateText(s.Album, c.Album)
+
+	return truncated
+}
+
+// TruncateText shortens text to at most limit characters, including a
+// trailing ellipsis. It never splits a character from its combining marks.
+func TruncateText(text string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := limit - 1
+	for cut > 0 && unicode.Is(unicode.Mn, runes[cut]) {
+		cut--
+	}
+
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + string(RuneEllipsis)
+}
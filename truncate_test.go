@@ -0,0 +1,33 @@
+package main_test
+
+import (
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateText(t *testing.T) {
+	require.Equal(t, "Placebo", main.TruncateText("Placebo", 0))
+	require.Equal(t, "Placebo", main.TruncateText("Placebo", 7))
+	require.Equal(t, "Plac…", main.TruncateText("Placebo", 5))
+	require.Equal(t, "A…", main.TruncateText("A Place For Us To Dream", 3))
+	// the combining acute accent is never separated from its base character
+	require.Equal(t, "Beyonce\u0301", main.TruncateText("Beyonce\u0301", 8))
+	require.Equal(t, "Beyonc…", main.TruncateText("Beyonce\u0301 Knowles", 8))
+	require.Equal(t, "東京…", main.TruncateText("東京事変", 3))
+}
+
+func TestTruncateSink(t *testing.T) {
+	fakeSink := &FakeSink{}
+	sink := main.WrapSinkTruncate(fakeSink, main.TruncateConfig{Artist: 10, Track: 0, Album: 8})
+
+	require.NoError(t, sink.Scrobble(defaultScrobble))
+	require.Len(t, fakeSink.ScrobbleLog, 1)
+	require.Equal(t, []string{"Placebo,…"}, fakeSink.ScrobbleLog[0].Artists)
+	require.Equal(t, defaultScrobble.Track, fakeSink.ScrobbleLog[0].Track)
+	require.Equal(t, "A Place…", fakeSink.ScrobbleLog[0].Album)
+	require.Equal(t, []string{"Placebo", "David Bowie"}, defaultScrobble.Artists)
+
+	require.Equal(t, fakeSink, main.WrapSinkTruncate(fakeSink, main.TruncateConfig{Artist: 0, Track: 0, Album: 0}))
+}
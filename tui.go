@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// TUIState is the state of the running daemon shown by the terminal UI.
+type TUIState struct {
+	Running bool
+	Status  DaemonStatus
+	// recent scrobbles and the error reading them, by sink name
+	Scrobbles      map[string][]Scrobble
+	ScrobbleErrors map[string]string
+}
+
+// RunTUI shows the status of the running daemon until interrupted. Recent
+// scrobbles of the given sinks are only loaded again after a new scrobble,
+// so remote sinks are not queried on every refresh. In accessible mode, the
+// status is printed as linear text whenever it changes instead.
+func RunTUI(sinks []string, interval time.Duration, accessible bool) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	if !accessible {
+		fmt.Print(ansiHideCursor)
+		defer fmt.Print(ansiClearScreen + ansiShowCursor)
+	}
+
+	state := TUIState{
+		Running:        false,
+		Status:         DaemonStatus{},
+		Scrobbles:      map[string][]Scrobble{},
+		ScrobbleErrors: map[string]string{},
+	}
+	loaded := map[string]Scrobble{}
+
+	printed := ""
+	for {
+		state.Status, state.Running = QueryDaemonStatus()
+		if state.Running {
+			for _, sink := range sinks {
+				last, ok := state.Status.LastScrobbles[sink]
+				if _, fetched := state.Scrobbles[sink]; fetched && (!ok || last.Key() == loaded[sink].Key()) {
+					continue
+				}
+
+				scrobbles, err := tuiScrobbles(sink)
+				if err != nil {
+					state.ScrobbleErrors[sink] = err.Error()
+				} else {
+					delete(state.ScrobbleErrors, sink)
+				}
+				state.Scrobbles[sink] = scrobbles
+				loaded[sink] = last
+			}
+		}
+
+		if accessible {
+			text := RenderTUIAccessible(state)
+			if text != printed {
+				fmt.Println(text)
+				printed = text
+			}
+		} else {
+			width, height, err := term.GetSize(int(os.Stdout.Fd()))
+			if err != nil {
+				width, height = 80, 24
+			}
+			fmt.Print(RenderTUI(state, time.Now(), width, height))
+		}
+
+		select {
+		case <-signals:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tuiScrobbles loads the recent scrobbles of a sink using the daemon.
+func tuiScrobbles(sink string) ([]Scrobble, error) {
+	response, err := SendControlRequest(ControlSocketFilename(), ControlRequest{
+		Command:   "scrobbles",
+		Arguments: []string{sink, "10"},
+		Scrobble:  nil,
+	})
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	} else if err != nil {
+		return nil, err
+	}
+	return response.Scrobbles, nil
+}
+
+func RenderTUI(state TUIState, now time.Time, width, height int) string {
+	var lines []string
+	if !state.Running {
+		lines = append(lines, ansiDim+centerText("goscrobble is not running", width)+ansiReset)
+		padding := max((height-len(lines))/2, 0)
+		return ansiClearScreen + strings.Repeat("\n", padding) + strings.Join(lines, "\n")
+	}
+
+	lines = append(lines, ansiBold+TruncateText(state.Status.Summary(now), width)+ansiReset, "")
+
+	lines = append(lines, ansiBold+"NOW PLAYING"+ansiReset)
+	if len(state.Status.Players) == 0 {
+		lines = append(lines, ansiDim+"nothing playing"+ansiReset)
+	}
+	for _, p := range state.Status.Players {
+		track := fmt.Sprintf("%s %c %s", p.Status.JoinArtists(), RuneEmDash, p.Status.Track)
+		track = TruncateText(track, max(width-utf8.RuneCountInString(p.Player)-2, 10))
+		lines = append(lines, track+"  "+ansiDim+p.Player+ansiReset)
+
+		label := "scrobbled"
+		if !p.Scrobbled {
+			label = "scrobble in " + p.UntilScrobble.Truncate(time.Second).String()
+		}
+		barWidth := max(width-len(label)-1, 10)
+		filled := int(p.ThresholdProgress() * float64(barWidth))
+		lines = append(lines, strings.Repeat("━", filled)+strings.Repeat("─", barWidth-filled)+" "+label)
+	}
+
+	var errorLines []string
+	if len(state.Status.Errors) > 0 {
+		errorLines = append(errorLines, "", ansiBold+"ERRORS"+ansiReset)
+		// at most 3 errors, newest first
+		for _, e := range slices.Backward(state.Status.Errors[max(len(state.Status.Errors)-3, 0):]) {
+			errorLines = append(errorLines, TruncateText(fmt.Sprintf("%s %s: %s", e.Time.Local().Format(time.TimeOnly), e.Name, e.Error), width))
+		}
+	}
+
+	// the recent scrobbles fill the remaining lines
+	lines = append(lines, "", ansiBold+"RECENT SCROBBLES"+ansiReset)
+	remaining := height - len(lines) - len(errorLines)
+	for _, sink := range slices.Sorted(maps.Keys(state.Scrobbles)) {
+		if remaining < 2 {
+			break
+		}
+		lines = append(lines, ansiDim+sink+ansiReset)
+		remaining--
+
+		if message, ok := state.ScrobbleErrors[sink]; ok {
+			lines = append(lines, TruncateText("  "+message, width))
+			remaining--
+			continue
+		}
+		for _, s := range state.Scrobbles[sink] {
+			if remaining < 1 {
+				break
+			}
+			lines = append(lines, TruncateText(fmt.Sprintf("  %s  %s %c %s", s.Timestamp.Local().Format(time.TimeOnly), s.JoinArtists(), RuneEmDash, s.Track), width))
+			remaining--
+		}
+	}
+
+	lines = append(lines, errorLines...)
+	return ansiClearScreen + strings.Join(lines, "\n")
+}
+
+// RenderTUIAccessible describes the status as linear text. Positions are left
+// out, so the text only changes with the tracks, scrobbles, and errors.
+func RenderTUIAccessible(state TUIState) string {
+	if !state.Running {
+		return "goscrobble is not running\n"
+	}
+
+	var builder strings.Builder
+	if len(state.Status.Players) == 0 {
+		builder.WriteString("Nothing playing\n")
+	}
+	for _, p := range state.Status.Players {
+		scrobbled := "not scrobbled yet"
+		if p.Scrobbled {
+			scrobbled = "scrobbled"
+		}
+		_, _ = fmt.Fprintf(&builder, "Playing on %s: %s by %s, %s\n", p.Player, p.Status.Track, p.Status.JoinArtists(), scrobbled)
+	}
+	for _, sink := range slices.Sorted(maps.Keys(state.Scrobbles)) {
+		if scrobbles := state.Scrobbles[sink]; len(scrobbles) > 0 {
+			_, _ = fmt.Fprintf(&builder, "Last scrobble on %s: %s by %s\n", sink, scrobbles[0].Track, scrobbles[0].JoinArtists())
+		}
+	}
+	for _, e := range state.Status.Errors {
+		_, _ = fmt.Fprintf(&builder, "Error of %s at %s: %s\n", e.Name, e.Time.Local().Format(time.TimeOnly), e.Error)
+	}
+	_, _ = fmt.Fprintf(&builder, "Queued scrobbles: %d\n", state.Status.QueueDepth)
+	return builder.String()
+}
@@ -0,0 +1,78 @@
+package main_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func tuiState() main.TUIState {
+	now := time.Date(2025, 1, 1, 20, 0, 0, 0, time.UTC)
+	return main.TUIState{
+		Running: true,
+		Status: main.DaemonStatus{
+			Started: now.Add(-time.Hour),
+			Players: []main.PlayerProgress{{
+				Player:        "spotify",
+				Status:        defaultPlaybackStatus,
+				Scrobbled:     false,
+				Threshold:     2 * time.Minute,
+				UntilScrobble: time.Minute,
+			}},
+			Errors: []main.RecentError{{Time: now, Name: "last.fm:default", Error: "connection refused"}},
+		},
+		Scrobbles: map[string][]main.Scrobble{
+			"csv:default":     {defaultScrobble},
+			"last.fm:default": nil,
+		},
+		ScrobbleErrors: map[string]string{"last.fm:default": "invalid session key"},
+	}
+}
+
+func TestPlayerProgress(t *testing.T) {
+	progress := tuiState().Status.Players[0]
+	require.InDelta(t, 0.5, progress.ThresholdProgress(), 0.001)
+	require.Equal(t, "01:50 / 04:11", progress.PrettyPosition())
+
+	progress.Scrobbled = true
+	require.InDelta(t, 1, progress.ThresholdProgress(), 0.001)
+}
+
+func TestRenderTUI(t *testing.T) {
+	state := tuiState()
+	rendered := main.RenderTUI(state, state.Status.Started.Add(time.Hour), 80, 24)
+	require.Contains(t, rendered, "daemon running for 1h0m0s")
+	require.Contains(t, rendered, "Placebo, David Bowie — "+defaultPlaybackStatus.Track)
+	require.Contains(t, rendered, strings.Repeat("━", 31)+strings.Repeat("─", 32)+" scrobble in 1m0s")
+	require.Contains(t, rendered, "invalid session key")
+	require.Contains(t, rendered, "last.fm:default: connection refused")
+
+	// scrobbles are left out on small terminals, but errors are shown
+	rendered = main.RenderTUI(state, time.Now(), 80, 8)
+	require.NotContains(t, rendered, "invalid session key")
+	require.Contains(t, rendered, "connection refused")
+
+	state.Running = false
+	require.Contains(t, main.RenderTUI(state, time.Now(), 80, 24), "goscrobble is not running")
+}
+
+func TestRenderTUIAccessible(t *testing.T) {
+	state := tuiState()
+	rendered := main.RenderTUIAccessible(state)
+	require.Contains(t, rendered, "Playing on spotify: "+defaultPlaybackStatus.Track+" by Placebo, David Bowie, not scrobbled yet\n")
+	require.Contains(t, rendered, "Last scrobble on csv:default: "+defaultScrobble.Track)
+	require.Contains(t, rendered, "Queued scrobbles: 0\n")
+
+	// the text does not change with the position
+	state.Status.Players[0].Status.Position += 10 * time.Second
+	state.Status.Players[0].UntilScrobble -= 10 * time.Second
+	require.Equal(t, rendered, main.RenderTUIAccessible(state))
+
+	state.ScrobbleErrors = nil
+	state.Status.Errors = append(state.Status.Errors, main.RecentError{Time: time.Now(), Name: "dbus", Error: errors.New("no bus").Error()})
+	require.Contains(t, main.RenderTUIAccessible(state), "Error of dbus")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// seconds
+const DefaultUndoWindow = 3600
+
+// RetractableSink is implemented by sinks that can delete scrobbles after
+// they were submitted, e.g. local files or services with an API to delete
+// listens.
+type RetractableSink interface {
+	Sink
+	DeleteScrobbles(scrobbles []Scrobble) error
+}
+
+// RetractableSinks returns the sinks that can retract scrobbles.
+func RetractableSinks(sinks []Sink) []Sink {
+	var retractable []Sink
+	for _, sink := range sinks {
+		if _, ok := UnwrapSink(sink).(RetractableSink); ok {
+			retractable = append(retractable, sink)
+		}
+	}
+	return retractable
+}
+
+// LastScrobble returns the most recent scrobble of the sinks between since
+// and now, and the sinks that store it. It returns false if no sink has a
+// scrobble in that time.
+func LastScrobble(sinks []Sink, since, now time.Time) (Scrobble, []Sink, bool, error) {
+	var (
+		last   Scrobble
+		found  bool
+		stored = map[string][]Scrobble{}
+		errs   []error
+	)
+	for _, sink := range sinks {
+		scrobbles, err := sink.GetScrobbles(0, since, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", sink.Name(), err.Error()))
+			continue
+		}
+		stored[sink.Name()] = scrobbles
+
+		for _, scrobble := range scrobbles {
+			if !found || scrobble.Timestamp.After(last.Timestamp) {
+				last = scrobble
+				found = true
+			}
+		}
+	}
+	if !found {
+		return Scrobble{}, nil, false, errors.Join(errs...)
+	}
+
+	var matches []Sink
+	for _, sink := range sinks {
+		for _, scrobble := range stored[sink.Name()] {
+			if scrobble.Key() == last.Key() {
+				matches = append(matches, sink)
+				break
+			}
+		}
+	}
+	return last, matches, true, errors.Join(errs...)
+}
+
+// RetractScrobble deletes the scrobble from all given sinks and returns the
+// names of the sinks it was deleted from.
+func RetractScrobble(sinks []Sink, scrobble Scrobble) ([]string, error) {
+	var (
+		retracted []string
+		errs      []error
+	)
+	for _, sink := range sinks {
+		retractable, ok := UnwrapSink(sink).(RetractableSink)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: cannot retract scrobbles", sink.Name()))
+			continue
+		}
+		if err := retractable.DeleteScrobbles([]Scrobble{scrobble}); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", sink.Name(), err.Error()))
+			continue
+		}
+		retracted = append(retracted, sink.Name())
+	}
+	return retracted, errors.Join(errs...)
+}
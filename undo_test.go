@@ -0,0 +1,54 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetractableSinks(t *testing.T) {
+	csv := main.CSVSink{Key: "default", Filename: "scrobbles.csv"}
+	require.Equal(t, []main.Sink{csv}, main.RetractableSinks([]main.Sink{&FakeSink{}, csv}))
+}
+
+func TestUndoLastScrobble(t *testing.T) {
+	first := main.CSVSink{Key: "first", Filename: filepath.Join(t.TempDir(), "first.csv")}
+	second := main.CSVSink{Key: "second", Filename: filepath.Join(t.TempDir(), "second.csv")}
+
+	now := defaultScrobble.Timestamp.Add(time.Hour)
+	older := defaultScrobble
+	older.Track = "Pure Morning"
+	older.Timestamp = defaultScrobble.Timestamp.Add(-time.Minute)
+	require.NoError(t, first.ReplaceScrobbles([]main.Scrobble{older, defaultScrobble}))
+	require.NoError(t, second.ReplaceScrobbles([]main.Scrobble{older}))
+
+	sinks := []main.Sink{first, second}
+
+	// the scrobble is only retracted from the sinks that store it
+	scrobble, stored, found, err := main.LastScrobble(sinks, now.Add(-2*time.Hour), now)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, defaultScrobble.Key(), scrobble.Key())
+	require.Equal(t, []main.Sink{first}, stored)
+
+	retracted, err := main.RetractScrobble(stored, scrobble)
+	require.NoError(t, err)
+	require.Equal(t, []string{"csv:first"}, retracted)
+
+	scrobble, stored, found, err = main.LastScrobble(sinks, now.Add(-2*time.Hour), now)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, older.Key(), scrobble.Key())
+	require.Equal(t, sinks, stored)
+
+	// scrobbles outside of the window are never retracted
+	_, _, found, err = main.LastScrobble(sinks, now.Add(-time.Minute), now)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	_, err = main.RetractScrobble([]main.Sink{&FakeSink{}}, older)
+	require.Error(t, err)
+}
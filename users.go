@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const UsersDirName = "users"
+
+// UserConfig is the profile of one user of a shared instance (e.g., on a
+// family media server). Plays reported by the sources of a user are only sent
+// to the sinks of that user.
+type UserConfig struct {
+	Sources SourcesConfig `toml:"sources"`
+	Sinks   SinksConfig   `toml:"sinks"`
+}
+
+// UserProfile is the running part of a user profile. Its state is kept when
+// the configuration is reloaded.
+type UserProfile struct {
+	Name    string
+	State   *LoopState
+	Sources []Source
+	Sinks   []Sink
+}
+
+// ForUser returns the configuration of a user profile: the global
+// configuration with the sources and sinks of the user. Sink keys are prefixed
+// with the user name (e.g., `last.fm:alice/default`), so the offline queue and
+// audit log keep the sinks of different users apart.
+func (c Config) ForUser(name string) Config {
+	user := c.Users[name]
+
+	config := c
+	config.Users = map[string]UserConfig{}
+	config.Sources = user.Sources
+	config.Sinks = SinksConfig{
+		LastFm: map[string]LastFmConfig{},
+		CSV:    map[string]CSVConfig{},
+	}
+	for key, sink := range user.Sinks.LastFm {
+		config.Sinks.LastFm[name+"/"+key] = sink
+	}
+	for key, sink := range user.Sinks.CSV {
+		config.Sinks.CSV[name+"/"+key] = sink
+	}
+	return config
+}
+
+func UserJournalFilename(name string) string {
+	return filepath.Join(StateDir(), UsersDirName, name, JournalFileName)
+}
+
+// SetupUserProfiles sets up the sources and sinks of all user profiles. The
+// state of profiles in previous is kept, new profiles recover their playback
+// journal.
+func (c Config) SetupUserProfiles(previous []*UserProfile, options LoopOptions) []*UserProfile {
+	states := map[string]*LoopState{}
+	for _, profile := range previous {
+		states[profile.Name] = profile.State
+	}
+
+	var profiles []*UserProfile
+	for _, name := range slices.Sorted(maps.Keys(c.Users)) {
+		log.Debug().Str("user", name).Msg("setting up user profile")
+
+		config := c.ForUser(name)
+		profile := &UserProfile{
+			Name:    name,
+			State:   states[name],
+			Sources: config.SetupSources(),
+			Sinks:   config.SetupSinks(),
+		}
+
+		if profile.State == nil {
+			profile.State = NewLoopState()
+			if c.PlaybackJournal {
+				profile.State.Journal = NewPlaybackJournal(UserJournalFilename(name))
+				profile.State.Journal.Recover(profile.State, options, profile.Sinks, SendNotification)
+			}
+		} else if !c.PlaybackJournal {
+			profile.State.Journal = nil
+		} else if profile.State.Journal == nil {
+			profile.State.Journal = NewPlaybackJournal(UserJournalFilename(name))
+		}
+
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
+
+// UserSources returns the sources of all user profiles.
+func UserSources(profiles []*UserProfile) []Source {
+	var sources []Source
+	for _, profile := range profiles {
+		sources = append(sources, profile.Sources...)
+	}
+	return sources
+}
+
+// CurrentlyPlaying returns the players of the main profile and of all user
+// profiles, whose players are prefixed with the user name (e.g.,
+// `alice/upnp:living-room`).
+func CurrentlyPlaying(state *LoopState, profiles []*UserProfile) map[string]PlaybackStatus {
+	if len(profiles) == 0 {
+		return state.CurrentlyPlaying
+	}
+
+	playing := maps.Clone(state.CurrentlyPlaying)
+	for _, profile := range profiles {
+		for player, status := range profile.State.CurrentlyPlaying {
+			playing[profile.Name+"/"+player] = status
+		}
+	}
+	return playing
+}
+
+func (c *Config) validateUsers() []ConfigWarning {
+	var warnings configWarnings
+
+	for name, user := range c.Users {
+		if name == "" || strings.ContainsAny(name, `/\`) {
+			warnings.add(fmt.Sprintf("user=%s", name), "invalid user name, ignoring user profile")
+			delete(c.Users, name)
+			continue
+		}
+
+		warnings = append(warnings, user.Sources.Validate()...)
+		// the token of the Spotify source is not stored per user
+		if user.Sources.Spotify != nil {
+			warnings.add(fmt.Sprintf("user=%s", name), "Spotify source is not supported in user profiles, disabling it")
+			user.Sources.Spotify = nil
+		}
+		c.Users[name] = user
+
+		if len(user.Sinks.LastFm) == 0 && len(user.Sinks.CSV) == 0 {
+			warnings.add(fmt.Sprintf("user=%s", name), "user profile has no sinks, plays of its sources are not scrobbled")
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,61 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func userConfig(t *testing.T) main.Config {
+	config := main.DefaultConfig
+	config.AuditLog = false
+	config.OfflineQueue = false
+	config.PlaybackJournal = false
+	//nolint:exhaustruct
+	config.Users = map[string]main.UserConfig{
+		"alice": {
+			Sources: main.SourcesConfig{},
+			Sinks: main.SinksConfig{
+				CSV: map[string]main.CSVConfig{"default": {Filename: filepath.Join(t.TempDir(), "alice.csv")}},
+			},
+		},
+	}
+	return config
+}
+
+func TestConfigForUser(t *testing.T) {
+	config := userConfig(t).ForUser("alice")
+
+	require.Empty(t, config.Users)
+	require.Nil(t, config.Sources.DBus)
+	require.Empty(t, config.Sinks.LastFm)
+	require.Contains(t, config.Sinks.CSV, "alice/default")
+
+	sinks := config.SetupSinks()
+	require.Len(t, sinks, 1)
+	require.Equal(t, "csv:alice/default", sinks[0].Name())
+}
+
+func TestSetupUserProfiles(t *testing.T) {
+	config := userConfig(t)
+	options := replayOptions()
+
+	profiles := config.SetupUserProfiles(nil, options)
+	require.Len(t, profiles, 1)
+	require.Equal(t, "alice", profiles[0].Name)
+	require.Len(t, profiles[0].Sinks, 1)
+
+	// the state is kept when the configuration is reloaded
+	profiles[0].State.CurrentlyPlaying = map[string]main.PlaybackStatus{"upnp:kitchen": defaultPlaybackStatus}
+	reloaded := config.SetupUserProfiles(profiles, options)
+	require.Same(t, profiles[0].State, reloaded[0].State)
+
+	state := main.NewLoopState()
+	state.CurrentlyPlaying = map[string]main.PlaybackStatus{"spotify": defaultPlaybackStatus}
+	playing := main.CurrentlyPlaying(state, reloaded)
+	require.Len(t, playing, 2)
+	require.Contains(t, playing, "alice/upnp:kitchen")
+	require.Len(t, state.CurrentlyPlaying, 1)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WatchEventTypes returns the flags enabling only the given event types, or
+// all event types if none are given.
+func WatchEventTypes(names []string) (EventTypeFlags, error) {
+	types := EventTypeFlags{}
+	if len(names) == 0 {
+		return types, nil
+	}
+
+	for _, eventType := range EventTypes {
+		types[eventType] = false
+	}
+	for _, name := range names {
+		if !slices.Contains(EventTypes, EventType(name)) {
+			return nil, fmt.Errorf("unknown event type: %s", name)
+		}
+		types[EventType(name)] = true
+	}
+	return types, nil
+}
+
+// watch answers a `watch` request with a single response, followed by the
+// events of the daemon as JSON lines until the client disconnects.
+func (s *ControlServer) watch(conn net.Conn, request ControlRequest) {
+	s.mutex.Lock()
+	bus := s.events
+	s.mutex.Unlock()
+
+	encoder := json.NewEncoder(conn)
+
+	types, err := WatchEventTypes(request.Arguments)
+	if bus == nil {
+		err = errors.New("event stream is not available")
+	}
+	if err != nil {
+		_ = encoder.Encode(ControlError(err.Error()))
+		return
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return
+	}
+
+	events, cancel := bus.Subscribe(types)
+	defer cancel()
+
+	if err := encoder.Encode(ControlMessage("watching events")); err != nil {
+		return
+	}
+
+	// the client does not send anything else, so reading only returns once
+	// it disconnected
+	closed := make(chan struct{})
+	go func() {
+		_, _ = conn.Read(make([]byte, 1))
+		close(closed)
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event := <-events:
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// WatchEvents streams the events of a running daemon to handle until the
+// daemon stops or handle returns an error. If types are given, only events of
+// these types are received.
+func WatchEvents(filename string, types []string, handle func(Event) error) error {
+	conn, err := net.DialTimeout("unix", filename, controlTimeout)
+	if err != nil {
+		return err
+	}
+	defer CloseLogged(conn)
+
+	if err := conn.SetDeadline(time.Now().Add(controlTimeout)); err != nil {
+		return err
+	}
+	if err := json.NewEncoder(conn).Encode(ControlRequest{Command: "watch", Arguments: types, Scrobble: nil}); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+	var response ControlResponse
+	if err := decoder.Decode(&response); err != nil {
+		return err
+	}
+	if response.Error != "" {
+		return errors.New(response.Error)
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return err
+	}
+
+	for {
+		var event Event
+		if err := decoder.Decode(&event); errors.Is(err, io.EOF) {
+			return errors.New("the daemon stopped")
+		} else if err != nil {
+			return err
+		}
+
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+}
+
+// Summary describes the event in a single line, e.g. for `goscrobble watch`.
+func (e Event) Summary() string {
+	var builder strings.Builder
+	_, _ = fmt.Fprintf(&builder, "%s  %-17s", e.Time.Local().Format(time.TimeOnly), strings.ReplaceAll(string(e.Type), "_", " "))
+
+	if e.Scrobble != nil && e.Scrobble.Track != "" {
+		_, _ = fmt.Fprintf(&builder, "  %s %c %s", e.Scrobble.JoinArtists(), RuneEmDash, e.Scrobble.Track)
+	}
+	if e.Player != "" {
+		_, _ = fmt.Fprintf(&builder, " (%s)", e.Player)
+	}
+	if len(e.Sinks) > 0 {
+		builder.WriteString(" on " + strings.Join(e.Sinks, ", "))
+	}
+	if e.Error != "" {
+		_, _ = fmt.Fprintf(&builder, ": %s: %s", e.Sink, e.Error)
+	}
+
+	return builder.String()
+}
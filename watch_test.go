@@ -0,0 +1,68 @@
+package main_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/p-mng/goscrobble"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchEvents(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), main.ControlSocketFileName)
+
+	bus := main.NewEventBus()
+	server := main.NewControlServer()
+	server.StreamEvents(bus)
+	require.NoError(t, server.Listen(filename))
+	defer func() { require.NoError(t, server.Close()) }()
+
+	err := main.WatchEvents(filename, []string{"played"}, nil)
+	require.EqualError(t, err, "unknown event type: played")
+
+	stop := errors.New("stop")
+	received := make(chan main.Event, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- main.WatchEvents(filename, []string{string(main.EventScrobbled)}, func(event main.Event) error {
+			received <- event
+			return stop
+		})
+	}()
+
+	// events are only received once the client subscribed, and skipped
+	// events are filtered out
+	var event main.Event
+	require.Eventually(t, func() bool {
+		bus.Publish(main.NewEvent(main.EventSkipped, "vlc", defaultScrobble))
+		scrobbled := main.NewEvent(main.EventScrobbled, "vlc", defaultScrobble)
+		scrobbled.Sinks = []string{"csv:default"}
+		bus.Publish(scrobbled)
+
+		select {
+		case event = <-received:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, main.EventScrobbled, event.Type)
+	require.Equal(t, []string{"csv:default"}, event.Sinks)
+	require.ErrorIs(t, <-done, stop)
+
+	event.Time = time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local)
+	require.Equal(t, "12:00:00  scrobbled          Placebo, David Bowie — Without You I'm Nothing (vlc) on csv:default", event.Summary())
+
+	_, err = main.WatchEventTypes(nil)
+	require.NoError(t, err)
+
+	// without an event bus, watching is not possible
+	filename = filepath.Join(t.TempDir(), main.ControlSocketFileName)
+	unavailable := main.NewControlServer()
+	require.NoError(t, unavailable.Listen(filename))
+	defer func() { require.NoError(t, unavailable.Close()) }()
+	require.Error(t, main.WatchEvents(filename, nil, nil))
+}
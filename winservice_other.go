@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+// RunningAsWindowsService always returns false on other platforms.
+func RunningAsWindowsService() bool {
+	return false
+}
+
+// RunWindowsService runs run directly on other platforms.
+func RunWindowsService(run func()) error {
+	run()
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// RunningAsWindowsService reports whether goscrobble was started by the
+// Windows service control manager.
+func RunningAsWindowsService() bool {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Msg("cannot determine whether goscrobble runs as a Windows service")
+		return false
+	}
+	return isService
+}
+
+// RunWindowsService reports to the service control manager while run is
+// running, and logs to the Windows event log. It returns once the service is
+// stopped.
+func RunWindowsService(run func()) error {
+	// registering the event source requires administrator rights, which the
+	// service has, and fails if it already exists
+	_ = eventlog.InstallAsEventCreate(ServiceName, eventlog.Error|eventlog.Warning|eventlog.Info)
+
+	events, err := eventlog.Open(ServiceName)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Msg("cannot open Windows event log, logging to stderr")
+	} else {
+		defer CloseLogged(events)
+		log.Logger = log.Output(eventLogWriter{events: events}).With().Caller().Logger()
+	}
+
+	return svc.Run(ServiceName, windowsService{run: run})
+}
+
+type windowsService struct {
+	run func()
+}
+
+func (s windowsService) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	go s.run()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for request := range requests {
+		switch request.Cmd {
+		case svc.Interrogate:
+			status <- request.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			log.Info().Msg("stopping Windows service")
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		default:
+			log.Warn().
+				Uint32("command", uint32(request.Cmd)).
+				Msg("unexpected Windows service control request")
+		}
+	}
+
+	return false, 0
+}
+
+// eventLogWriter writes log messages to the Windows event log, using the event
+// type matching their level.
+type eventLogWriter struct {
+	events *eventlog.Log
+}
+
+const eventID = 1
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.InfoLevel, p)
+}
+
+func (w eventLogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	message := strings.TrimSpace(string(p))
+
+	var err error
+	switch {
+	case level >= zerolog.ErrorLevel:
+		err = w.events.Error(eventID, message)
+	case level == zerolog.WarnLevel:
+		err = w.events.Warning(eventID, message)
+	default:
+		err = w.events.Info(eventID, message)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}